@@ -97,6 +97,12 @@ initBuildArea() {
 		buildManpage 't3c-check';
 	)
 
+	(
+		cd t3c-check-parents;
+		go build -v -gcflags "$gcflags" -ldflags "${ldflags} -X main.GitRevision=$(git rev-parse HEAD) -X main.BuildTimestamp=$(date +'%Y-%M-%dT%H:%M:%s') -X main.Version=${TC_VERSION}";
+		buildManpage 't3c-check-parents';
+	)
+
 	(
 		cd t3c-check-refs;
 		go build -v -gcflags "$gcflags" -ldflags "${ldflags} -X main.GitRevision=$(git rev-parse HEAD) -X main.BuildTimestamp=$(date +'%Y-%M-%dT%H:%M:%s') -X main.Version=${TC_VERSION}";
@@ -115,6 +121,12 @@ initBuildArea() {
 		buildManpage 't3c-diff';
 	)
 
+	(
+		cd t3c-cache-proxy;
+		go build -v -gcflags "$gcflags" -ldflags "${ldflags} -X main.GitRevision=$(git rev-parse HEAD) -X main.BuildTimestamp=$(date +'%Y-%M-%dT%H:%M:%s') -X main.Version=${TC_VERSION}";
+		buildManpage 't3c-cache-proxy';
+	)
+
 	(
 		cd t3c-tail;
 		go build -v -gcflags "$gcflags" -ldflags "${ldflags} -X main.GitRevision=$(git rev-parse HEAD) -X main.BuildTimestamp=$(date +'%Y-%M-%dT%H:%M:%s') -X main.Version=${TC_VERSION}";
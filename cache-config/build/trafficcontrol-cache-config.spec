@@ -91,6 +91,14 @@ go_t3c_check_dir="$ccpath"/t3c-check
 	cp "$TC_DIR"/"$ccdir"/t3c-check/t3c-check.1 .
 ) || { echo "Could not copy go program at $(pwd): $!"; exit 1; }
 
+# copy t3c-check-parents binary
+go_t3c_check_parents_dir="$ccpath"/t3c-check-parents
+( mkdir -p "$go_t3c_check_parents_dir" && \
+	cd "$go_t3c_check_parents_dir" && \
+	cp "$TC_DIR"/"$ccdir"/t3c-check-parents/t3c-check-parents .
+	cp "$TC_DIR"/"$ccdir"/t3c-check-parents/t3c-check-parents.1 .
+) || { echo "Could not copy go program at $(pwd): $!"; exit 1; }
+
 # copy t3c-check-refs binary
 go_t3c_check_refs_dir="$ccpath"/t3c-check-refs
 ( mkdir -p "$go_t3c_check_refs_dir" && \
@@ -131,6 +139,14 @@ go_t3c_tail_dir="$ccpath"/t3c-tail
 	cp "$TC_DIR"/"$ccdir"/t3c-tail/t3c-tail.1 .
 ) || { echo "Could not copy go program at $(pwd): $!"; exit 1; }
 
+# copy t3c-cache-proxy binary
+go_t3c_cache_proxy_dir="$ccpath"/t3c-cache-proxy
+( mkdir -p "$go_t3c_cache_proxy_dir" && \
+	cd "$go_t3c_cache_proxy_dir" && \
+	cp "$TC_DIR"/"$ccdir"/t3c-cache-proxy/t3c-cache-proxy .
+	cp "$TC_DIR"/"$ccdir"/t3c-cache-proxy/t3c-cache-proxy.1 .
+) || { echo "Could not copy go program at $(pwd): $!"; exit 1; }
+
 %install
 ccdir="cache-config/"
 installdir="/usr/bin"
@@ -174,10 +190,18 @@ t3c_tail_src=src/github.com/apache/trafficcontrol/"$ccdir"/t3c-tail
 cp -p "$t3c_tail_src"/t3c-tail ${RPM_BUILD_ROOT}/"$installdir"
 gzip -c -9 "$src"/t3c-tail/t3c-tail.1 > ${RPM_BUILD_ROOT}/"$mandir"/"$man1dir"/t3c-tail.1.gz
 
+t3c_cache_proxy_src=src/github.com/apache/trafficcontrol/"$ccdir"/t3c-cache-proxy
+cp -p "$t3c_cache_proxy_src"/t3c-cache-proxy ${RPM_BUILD_ROOT}/"$installdir"
+gzip -c -9 "$src"/t3c-cache-proxy/t3c-cache-proxy.1 > ${RPM_BUILD_ROOT}/"$mandir"/"$man1dir"/t3c-cache-proxy.1.gz
+
 t3c_check_src=src/github.com/apache/trafficcontrol/"$ccdir"/t3c-check
 cp -p "$t3c_check_src"/t3c-check ${RPM_BUILD_ROOT}/"$installdir"
 gzip -c -9 "$src"/t3c-check/t3c-check.1 > ${RPM_BUILD_ROOT}/"$mandir"/"$man1dir"/t3c-check.1.gz
 
+t3c_check_parents_src=src/github.com/apache/trafficcontrol/"$ccdir"/t3c-check-parents
+cp -p "$t3c_check_parents_src"/t3c-check-parents ${RPM_BUILD_ROOT}/"$installdir"
+gzip -c -9 "$src"/t3c-check-parents/t3c-check-parents.1 > ${RPM_BUILD_ROOT}/"$mandir"/"$man1dir"/t3c-check-parents.1.gz
+
 t3c_check_refs_src=src/github.com/apache/trafficcontrol/"$ccdir"/t3c-check-refs
 cp -p "$t3c_check_refs_src"/t3c-check-refs ${RPM_BUILD_ROOT}/"$installdir"
 gzip -c -9 "$src"/t3c-check-refs/t3c-check-refs.1 > ${RPM_BUILD_ROOT}/"$mandir"/"$man1dir"/t3c-check-refs.1.gz
@@ -224,7 +248,9 @@ fi
 %attr(755, root, root)
 /usr/bin/t3c
 /usr/bin/t3c-apply
+/usr/bin/t3c-cache-proxy
 /usr/bin/t3c-check
+/usr/bin/t3c-check-parents
 /usr/bin/t3c-check-refs
 /usr/bin/t3c-check-reload
 /usr/bin/t3c-diff
@@ -235,7 +261,9 @@ fi
 /usr/bin/t3c-update
 /usr/share/man/man1/t3c.1.gz
 /usr/share/man/man1/t3c-apply.1.gz
+/usr/share/man/man1/t3c-cache-proxy.1.gz
 /usr/share/man/man1/t3c-check.1.gz
+/usr/share/man/man1/t3c-check-parents.1.gz
 /usr/share/man/man1/t3c-check-refs.1.gz
 /usr/share/man/man1/t3c-check-reload.1.gz
 /usr/share/man/man1/t3c-diff.1.gz
@@ -112,6 +112,12 @@ type Cfg struct {
 
 	ServiceAction          t3cutil.ApplyServiceActionFlag
 	NoConfirmServiceAction bool
+	// RollbackOnFailure is whether to restore the replaced config files and reload or restart again,
+	// if the service action fails or its confirmation finds errors or a failed HealthCheckURL.
+	RollbackOnFailure bool
+	// HealthCheckURL is a URL which must respond successfully after the service action, for it to be confirmed.
+	// Only used if RollbackOnFailure.
+	HealthCheckURL string
 
 	ReportOnly        bool
 	GoDirect          string
@@ -124,6 +130,27 @@ type Cfg struct {
 	GitRevision       string
 	LocalATSVersion   string
 	CacheType         string
+
+	// FromBundle is the path of a signed bundle from 't3c-request --export-bundle' to generate config from, instead of Traffic Ops.
+	// If set, Traffic Ops is never contacted.
+	FromBundle string
+	// BundlePublicKey is the path of the public key to verify FromBundle with.
+	BundlePublicKey string
+
+	// CacheProxyURL is the URL of a t3c-cache-proxy to request config data from, instead of Traffic Ops.
+	// If the proxy is unavailable, Traffic Ops is requested directly.
+	CacheProxyURL string
+
+	// CheckOnly is whether to only compare the generated config with the config files on disk,
+	// and print the files which have drifted, without applying anything.
+	CheckOnly bool
+	// ReportDrift is whether to report the drifted files to Traffic Ops. Only used if CheckOnly.
+	ReportDrift bool
+
+	// ExecPlugins is the comma-delimited, ordered list of external commands t3c-generate passes the generated files to.
+	ExecPlugins string
+	// ExecPluginTimeout is the maximum time each exec plugin may run. If zero, the t3c-generate default is used.
+	ExecPluginTimeout time.Duration
 }
 
 func (cfg Cfg) AppVersion() string { return t3cutil.VersionStr(AppName, cfg.Version, cfg.GitRevision) }
@@ -317,7 +344,7 @@ func GetCfg(appVersion string, gitRevision string) (Cfg, error) {
 	defaultClientTLSVersions := getopt.StringLong("default-client-tls-versions", 'V', "", "Comma-delimited list of default TLS versions for Delivery Services with no Parameter, e.g. --default-tls-versions='1.1,1.2,1.3'. If omitted, all versions are enabled.")
 	maxmindLocationPtr := getopt.StringLong("maxmind-location", 'M', "", "URL of a maxmind gzipped database file, to be installed into the trafficserver etc directory.")
 	verbosePtr := getopt.CounterLong("verbose", 'v', `Log verbosity. Logging is output to stderr. By default, errors are logged. To log warnings, pass '-v'. To log info, pass '-vv'. To omit error logging, see '-s'`)
-	cache := getopt.StringLong("cache", 'T', "ats", "Cache server type. Generate configuration files for specific cache server type, e.g. 'ats', 'varnish'.")
+	cache := getopt.StringLong("cache", 'T', "ats", "Cache server type. Generate configuration files for specific cache server type, e.g. 'ats', 'varnish', 'nginx'.")
 	const silentFlagName = "silent"
 	silentPtr := getopt.BoolLong(silentFlagName, 's', `Silent. Errors are not logged, and the 'verbose' flag is ignored. If a fatal error occurs, the return code will be non-zero but no text will be output to stderr`)
 
@@ -334,9 +361,18 @@ func GetCfg(appVersion string, gitRevision string) (Cfg, error) {
 	const noConfirmServiceActionFlagName = "no-confirm-service-action"
 	noConfirmServiceAction := getopt.BoolLong(noConfirmServiceActionFlagName, 0, "Whether to skip waiting and confirming the service action succeeded (reload or restart) via t3c-tail. Default is false.")
 
+	const rollbackOnFailureFlagName = "rollback-on-failure"
+	rollbackOnFailurePtr := getopt.BoolLong(rollbackOnFailureFlagName, 0, "Whether to restore the replaced config files, reload or restart again, and report the update failed to Traffic Ops, if the service action fails or t3c-tail or the --health-check-url find it failed. Default is false.")
+	healthCheckURLPtr := getopt.StringLong("health-check-url", 0, "", "URL which must respond with a 2xx status after the service action, for it to be confirmed. Only used with --rollback-on-failure")
+
 	const reportOnlyFlagName = "report-only"
 	reportOnlyPtr := getopt.BoolLong(reportOnlyFlagName, 'o', "Log information about necessary files and actions, but take no action. Default is false")
 
+	const checkOnlyFlagName = "check-only"
+	checkOnlyPtr := getopt.BoolLong(checkOnlyFlagName, 0, "Only compare the generated config with the config files on disk, ignoring comments, and print the files which have drifted as JSON, without applying anything or checking the update flag. Default is false")
+	const reportDriftFlagName = "report"
+	reportDriftPtr := getopt.BoolLong(reportDriftFlagName, 0, "Report the config files which have drifted to Traffic Ops. Requires --check-only. Default is false")
+
 	const filesFlagName = "files"
 	const defaultFiles = t3cutil.ApplyFilesFlagAll
 	filesPtr := getopt.EnumLong(filesFlagName, 'f', []string{string(t3cutil.ApplyFilesFlagAll), string(t3cutil.ApplyFilesFlagReval), ""}, "", "[all | reval] Which files to generate. If reval, the Traffic Ops server reval_pending flag is used instead of the upd_pending flag. Default is 'all'")
@@ -358,6 +394,13 @@ func GetCfg(appVersion string, gitRevision string) (Cfg, error) {
 	const useLocalATSVersionFlagName = "local-ats-version"
 	useLocalATSVersionPtr := getopt.BoolLong(useLocalATSVersionFlagName, 0, "[true | false] whether to use the local installed ATS version for config generation. If false, attempt to use the Server Package Parameter and fall back to ATS 5. If true and the local ATS version cannot be found, an error will be logged and the version set to ATS 5. Default is false")
 
+	const fromBundleFlagName = "from-bundle"
+	fromBundlePtr := getopt.StringLong(fromBundleFlagName, 0, "", "Path of a signed bundle from 't3c-request --export-bundle' to generate and apply config from, without connecting to Traffic Ops. Implies --ignore-update-flag and --no-unset-update-flag. Requires --bundle-public-key")
+	bundlePublicKeyPtr := getopt.StringLong("bundle-public-key", 0, "", "Path of the PEM Ed25519 public key to verify the --from-bundle with")
+	execPluginsPtr := getopt.StringLong("exec-plugin", 0, "", "Comma-delimited list of external commands for t3c-generate to pass the generated files and Traffic Ops data to, run in the order given. See t3c-generate --exec-plugin")
+	execPluginTimeoutPtr := getopt.DurationLong("exec-plugin-timeout", 0, 0, "Maximum time each exec plugin may run, e.g. '10s'. Default is the t3c-generate default")
+	cacheProxyURLPtr := getopt.StringLong("cache-proxy-url", 0, "", "URL of a t3c-cache-proxy to request config data from, instead of Traffic Ops. If the proxy is unavailable, Traffic Ops is requested directly")
+
 	const runModeFlagName = "run-mode"
 	runModePtr := getopt.StringLong(runModeFlagName, 'm', "", `[badass | report | revalidate | syncds] run mode. Optional, convenience flag which sets other flags for common usage scenarios.
 syncds     keeps the defaults:
@@ -598,6 +641,9 @@ If any of the related flags are also set, they override the mode's default behav
 		if cache != nil && *cache == "varnish" {
 			tsConfigDir = tsHome + "/etc/varnish"
 		}
+		if cache != nil && *cache == "nginx" {
+			tsConfigDir = tsHome + "/etc/nginx"
+		}
 		toInfoLog = append(toInfoLog, fmt.Sprintf("TSHome: %s, TSConfigDir: %s\n", TSHome, tsConfigDir))
 	}
 
@@ -610,25 +656,55 @@ If any of the related flags are also set, they override the mode's default behav
 	}
 	toInfoLog = append(toInfoLog, fmt.Sprintf("ATSVersionStr: '%s'\n", atsVersionStr))
 
-	usageStr := "basic usage: t3c-apply --traffic-ops-url=myurl --traffic-ops-user=myuser --traffic-ops-password=mypass --cache-host-name=my-cache"
-	if strings.TrimSpace(toURL) == "" {
-		fatalLogStrs = append(fatalLogStrs, "Missing required argument --traffic-ops-url or TO_URL environment variable. "+usageStr)
+	if *reportDriftPtr && !*checkOnlyPtr {
+		fatalLogStrs = append(fatalLogStrs, "--"+reportDriftFlagName+" requires --"+checkOnlyFlagName+".")
 	}
-	if strings.TrimSpace(toUser) == "" {
-		fatalLogStrs = append(fatalLogStrs, "Missing required argument --traffic-ops-user or TO_USER environment variable. "+usageStr)
-	}
-	if strings.TrimSpace(toPass) == "" {
-		fatalLogStrs = append(fatalLogStrs, "Missing required argument --traffic-ops-password or TO_PASS environment variable. "+usageStr)
+
+	usageStr := "basic usage: t3c-apply --traffic-ops-url=myurl --traffic-ops-user=myuser --traffic-ops-password=mypass --cache-host-name=my-cache"
+	ignoreUpdateFlag := *ignoreUpdateFlagPtr
+	noUnsetUpdateFlag := *noUnsetUpdateFlagPtr
+	if *fromBundlePtr != "" {
+		// Traffic Ops isn't contacted, so its credentials aren't needed, its update flags can't be unset,
+		// and the bundle's update flags are as old as the bundle, so they're ignored.
+		if strings.TrimSpace(*bundlePublicKeyPtr) == "" {
+			fatalLogStrs = append(fatalLogStrs, "Missing required argument --bundle-public-key, which is required by --"+fromBundleFlagName+".")
+		}
+		ignoreUpdateFlag = true
+		noUnsetUpdateFlag = true
+		modeLogStrs = append(modeLogStrs, "t3c-apply is applying bundle '"+*fromBundlePtr+"', setting --ignore-update-flag=true and --no-unset-update-flag=true")
+		if *reportDriftPtr {
+			fatalLogStrs = append(fatalLogStrs, "--"+reportDriftFlagName+" can't be used with --"+fromBundleFlagName+", which doesn't connect to Traffic Ops.")
+		}
+	} else {
+		if strings.TrimSpace(toURL) == "" {
+			fatalLogStrs = append(fatalLogStrs, "Missing required argument --traffic-ops-url or TO_URL environment variable. "+usageStr)
+		}
+		if strings.TrimSpace(toUser) == "" {
+			fatalLogStrs = append(fatalLogStrs, "Missing required argument --traffic-ops-user or TO_USER environment variable. "+usageStr)
+		}
+		if strings.TrimSpace(toPass) == "" {
+			fatalLogStrs = append(fatalLogStrs, "Missing required argument --traffic-ops-password or TO_PASS environment variable. "+usageStr)
+		}
+
+		toURLParsed, err := url.Parse(toURL)
+		if err != nil {
+			return Cfg{}, errors.New("parsing Traffic Ops URL from " + urlSourceStr + " '" + toURL + "': " + err.Error())
+		} else if err = validateURL(toURLParsed); err != nil {
+			return Cfg{}, errors.New("invalid Traffic Ops URL from " + urlSourceStr + " '" + toURL + "': " + err.Error())
+		}
 	}
 	if strings.TrimSpace(cacheHostName) == "" {
 		fatalLogStrs = append(fatalLogStrs, "Missing required argument --cache-host-name. "+usageStr)
 	}
-
-	toURLParsed, err := url.Parse(toURL)
-	if err != nil {
-		return Cfg{}, errors.New("parsing Traffic Ops URL from " + urlSourceStr + " '" + toURL + "': " + err.Error())
-	} else if err = validateURL(toURLParsed); err != nil {
-		return Cfg{}, errors.New("invalid Traffic Ops URL from " + urlSourceStr + " '" + toURL + "': " + err.Error())
+	if *rollbackOnFailurePtr && *noConfirmServiceAction {
+		fatalLogStrs = append(fatalLogStrs, "--"+rollbackOnFailureFlagName+" requires confirming the service action, and can't be used with --"+noConfirmServiceActionFlagName+".")
+	}
+	if *healthCheckURLPtr != "" {
+		if healthCheckURL, err := url.Parse(*healthCheckURLPtr); err != nil {
+			fatalLogStrs = append(fatalLogStrs, "parsing --health-check-url '"+*healthCheckURLPtr+"': "+err.Error())
+		} else if err := validateURL(healthCheckURL); err != nil {
+			fatalLogStrs = append(fatalLogStrs, "invalid --health-check-url '"+*healthCheckURLPtr+"': "+err.Error())
+		}
 	}
 
 	svcManagement := getOSSvcManagement()
@@ -669,15 +745,24 @@ If any of the related flags are also set, they override the mode's default behav
 		GoDirect:                    *goDirectPtr,
 		ServiceAction:               t3cutil.ApplyServiceActionFlag(*serviceActionPtr),
 		NoConfirmServiceAction:      *noConfirmServiceAction,
+		RollbackOnFailure:           *rollbackOnFailurePtr,
+		HealthCheckURL:              *healthCheckURLPtr,
 		ReportOnly:                  *reportOnlyPtr,
+		CheckOnly:                   *checkOnlyPtr,
+		ReportDrift:                 *reportDriftPtr,
 		Files:                       t3cutil.ApplyFilesFlag(*filesPtr),
 		InstallPackages:             *installPackagesPtr,
-		IgnoreUpdateFlag:            *ignoreUpdateFlagPtr,
-		NoUnsetUpdateFlag:           *noUnsetUpdateFlagPtr,
+		IgnoreUpdateFlag:            ignoreUpdateFlag,
+		NoUnsetUpdateFlag:           noUnsetUpdateFlag,
 		Version:                     appVersion,
 		GitRevision:                 gitRevision,
 		LocalATSVersion:             atsVersionStr,
 		CacheType:                   *cache,
+		FromBundle:                  *fromBundlePtr,
+		BundlePublicKey:             *bundlePublicKeyPtr,
+		CacheProxyURL:               *cacheProxyURLPtr,
+		ExecPlugins:                 *execPluginsPtr,
+		ExecPluginTimeout:           *execPluginTimeoutPtr,
 	}
 
 	if err = log.InitCfg(cfg); err != nil {
@@ -788,8 +873,16 @@ func printConfig(cfg Cfg) {
 	log.Debugf("WaitForParents: %v\n", cfg.WaitForParents)
 	log.Debugf("ServiceAction: %v\n", cfg.ServiceAction)
 	log.Debugf("NoConfirmServiceAction: %v\n", cfg.NoConfirmServiceAction)
+	log.Debugf("RollbackOnFailure: %v\n", cfg.RollbackOnFailure)
+	log.Debugf("HealthCheckURL: %s\n", cfg.HealthCheckURL)
 	log.Debugf("YumOptions: %s\n", cfg.YumOptions)
 	log.Debugf("MaxmindLocation: %s\n", cfg.MaxMindLocation)
+	log.Debugf("FromBundle: %s\n", cfg.FromBundle)
+	log.Debugf("CacheProxyURL: %s\n", cfg.CacheProxyURL)
+	log.Debugf("CheckOnly: %v\n", cfg.CheckOnly)
+	log.Debugf("ReportDrift: %v\n", cfg.ReportDrift)
+	log.Debugf("ExecPlugins: %v\n", cfg.ExecPlugins)
+	log.Debugf("ExecPluginTimeout: %v\n", cfg.ExecPluginTimeout)
 }
 
 func Usage() {
@@ -113,6 +113,10 @@ func Main() int {
 	log.Infoln("Acquired app lock")
 	defer lock.Unlock()
 
+	if cfg.CheckOnly {
+		return CheckConfigDrift(cfg)
+	}
+
 	// Note failing to load old metadata is not fatal!
 	// oldMetaData must always be checked for nil before usage!
 	oldMetaData, err := LoadMetaData(cfg)
@@ -314,6 +318,14 @@ func Main() int {
 	if err := trops.StartServices(&syncdsUpdate, metaData, cfg); err != nil {
 		log.Errorln("failed to start services: " + err.Error())
 		metaData.PartialSuccess = true
+		if cfg.RollbackOnFailure && trops.ServiceActionFailed {
+			if err := trops.RollbackConfigFiles(&syncdsUpdate, metaData); err != nil {
+				log.Errorln("failed to roll back config files: " + err.Error())
+			}
+			if err := trops.UpdateTrafficOps(&syncdsUpdate); err != nil {
+				log.Errorf("failed to update Traffic Ops: %s\n", err.Error())
+			}
+		}
 		return GitCommitAndExit(ExitCodeServicesError, PostConfigFailureExitMsg, cfg, metaData, oldMetaData)
 	}
 
@@ -372,6 +384,27 @@ func Main() int {
 	}
 }
 
+// CheckConfigDrift generates config and prints the config files which have drifted on disk as JSON to stdout.
+// It doesn't change any files, check the update flag, or write the metadata file.
+// Returns the application exit code.
+func CheckConfigDrift(cfg config.Cfg) int {
+	trops := torequest.NewTrafficOpsReq(cfg)
+	drifted, err := trops.CheckConfigDrift()
+	if drifted != nil {
+		if err := json.NewEncoder(os.Stdout).Encode(drifted); err != nil {
+			log.Errorln("writing config drift: " + err.Error())
+			return ExitCodeGeneralFailure
+		}
+		log.Infof("%d config files have drifted\n", len(drifted))
+	}
+	if err != nil {
+		log.Errorln("checking config drift: " + err.Error())
+		log.Infoln(FailureExitMsg)
+		return ExitCodeConfigFilesError
+	}
+	return ExitCodeSuccess
+}
+
 func LogPanic(f func() int) (exitCode int) {
 	defer func() {
 		if err := recover(); err != nil {
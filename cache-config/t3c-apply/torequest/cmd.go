@@ -105,6 +105,12 @@ func generate(cfg config.Cfg) ([]t3cutil.ATSConfigFile, error) {
 	args = append(args, "--disable-parent-config-comments="+strconv.FormatBool(cfg.DisableParentConfigComments))
 	args = append(args, "--use-strategies="+cfg.UseStrategies.String())
 	args = append(args, "--go-direct="+cfg.GoDirect)
+	if cfg.ExecPlugins != "" {
+		args = append(args, "--exec-plugin="+cfg.ExecPlugins)
+	}
+	if cfg.ExecPluginTimeout > 0 {
+		args = append(args, "--exec-plugin-timeout="+cfg.ExecPluginTimeout.String())
+	}
 
 	generatedFiles, stdErr, code := t3cutil.DoInput(configData, t3cpath, args...)
 	if code != 0 {
@@ -238,7 +244,7 @@ func getPackages(cfg config.Cfg) ([]Package, error) {
 
 // sendUpdate updates the given cache's queue update and reval status in Traffic Ops.
 // Note the statuses are the value to be set, not whether to set the value.
-func sendUpdate(cfg config.Cfg, configApplyTime, revalApplyTime *time.Time, configApplyBool, revalApplyBool *bool) error {
+func sendUpdate(cfg config.Cfg, configApplyTime, revalApplyTime *time.Time, configApplyBool, revalApplyBool, configUpdateFailed, revalUpdateFailed *bool) error {
 	args := []string{
 		`update`,
 		"--traffic-ops-timeout-milliseconds=" + strconv.FormatInt(int64(cfg.TOTimeoutMS), 10),
@@ -255,6 +261,12 @@ func sendUpdate(cfg config.Cfg, configApplyTime, revalApplyTime *time.Time, conf
 	if revalApplyTime != nil {
 		args = append(args, "--set-reval-apply-time="+(*revalApplyTime).Format(time.RFC3339Nano))
 	}
+	if configUpdateFailed != nil {
+		args = append(args, "--set-config-update-failed="+strconv.FormatBool(*configUpdateFailed))
+	}
+	if revalUpdateFailed != nil {
+		args = append(args, "--set-reval-update-failed="+strconv.FormatBool(*revalUpdateFailed))
+	}
 
 	// *** Compatability requirement until ATC (v7.0+) is deployed with the timestamp features
 	if configApplyBool != nil {
@@ -296,6 +308,49 @@ func sendUpdate(cfg config.Cfg, configApplyTime, revalApplyTime *time.Time, conf
 	return nil
 }
 
+// sendConfigDrift calls t3c-update to set the server's config drift in Traffic Ops to the given drifted files.
+func sendConfigDrift(cfg config.Cfg, drifted []tc.ConfigDriftFile) error {
+	args := []string{
+		`update`,
+		"--traffic-ops-timeout-milliseconds=" + strconv.FormatInt(int64(cfg.TOTimeoutMS), 10),
+		"--traffic-ops-insecure=" + strconv.FormatBool(cfg.TOInsecure),
+		"--cache-host-name=" + cfg.CacheHostName,
+		"--set-config-drift",
+	}
+	if cfg.LogLocationErr == log.LogLocationNull {
+		args = append(args, "-s")
+	}
+	if cfg.LogLocationWarn != log.LogLocationNull {
+		args = append(args, "-v")
+	}
+	if cfg.LogLocationInfo != log.LogLocationNull {
+		args = append(args, "-v")
+	}
+	if _, used := os.LookupEnv("TO_USER"); !used {
+		args = append(args, "--traffic-ops-user="+cfg.TOUser)
+	}
+	if _, used := os.LookupEnv("TO_PASS"); !used {
+		args = append(args, "--traffic-ops-password="+cfg.TOPass)
+	}
+	if _, used := os.LookupEnv("TO_URL"); !used {
+		args = append(args, "--traffic-ops-url="+cfg.TOURL)
+	}
+
+	input, err := json.Marshal(drifted)
+	if err != nil {
+		return errors.New("marshalling config drift: " + err.Error())
+	}
+	stdOut, stdErr, code := t3cutil.DoInput(input, t3cpath, args...)
+	if code != 0 {
+		logSubAppErr(t3cupd+` stdout`, stdOut)
+		logSubAppErr(t3cupd+` stderr`, stdErr)
+		return fmt.Errorf("%s returned non-zero exit code %v, see log for output", t3cupd, code)
+	}
+	logSubApp(t3cupd, stdErr)
+	log.Infoln(t3cupd + " config drift succeeded")
+	return nil
+}
+
 // doTail calls t3c-tail, which will read lines from the file at the provided
 // path, and will print lines matching the 'logMatch' regular expression.
 // When a line matching the 'endMatch' regular expression is encountered,
@@ -311,7 +366,8 @@ func sendUpdate(cfg config.Cfg, configApplyTime, revalApplyTime *time.Time, conf
 // ... where 'timeoutInS' is 1/1000 of 'timeoutInMS' and the string values of
 // arguments are otherwise substituted wherever they are found (GNU coreutils
 // are assumed to be present).
-func doTail(cfg config.Cfg, file string, logMatch string, endMatch string, timeoutInMS int) error {
+// Returns the printed lines, with their dates removed.
+func doTail(cfg config.Cfg, file string, logMatch string, endMatch string, timeoutInMS int) ([]string, error) {
 	args := []string{
 		"--file=" + filepath.Join(cfg.TsHome, file),
 		"--match=" + logMatch,
@@ -320,17 +376,20 @@ func doTail(cfg config.Cfg, file string, logMatch string, endMatch string, timeo
 	}
 	stdOut, stdErr, code := t3cutil.Do(`t3c-tail`, args...)
 	if code >= 1 {
-		return fmt.Errorf("t3c-tail returned error code %d stdout '%s' stderr '%s'", code, stdOut, stdErr)
+		return nil, fmt.Errorf("t3c-tail returned error code %d stdout '%s' stderr '%s'", code, stdOut, stdErr)
 	}
 	logSubApp(`t3c-tail`, stdErr)
 
 	stdOut = bytes.TrimSpace(stdOut)
-	lines := strings.Split(string(stdOut), "\n")
-	for _, line := range lines {
+	lines := []string{}
+	for _, line := range strings.Split(string(stdOut), "\n") {
 		line = stripDate.ReplaceAllString(line, "")
 		log.Infoln(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
 	}
-	return nil
+	return lines, nil
 }
 
 // diff calls t3c-diff to diff the given new file and the file on disk. Returns whether they're different.
@@ -534,15 +593,7 @@ func request(cfg config.Cfg, command string) ([]byte, error) {
 		args = append(args, "-v")
 	}
 
-	if _, used := os.LookupEnv("TO_USER"); !used {
-		args = append(args, "--traffic-ops-user="+cfg.TOUser)
-	}
-	if _, used := os.LookupEnv("TO_PASS"); !used {
-		args = append(args, "--traffic-ops-password="+cfg.TOPass)
-	}
-	if _, used := os.LookupEnv("TO_URL"); !used {
-		args = append(args, "--traffic-ops-url="+cfg.TOURL)
-	}
+	args = appendRequestSourceArgs(cfg, args)
 	stdOut, stdErr, code := t3cutil.Do(t3cpath, args...)
 	if code != 0 {
 		logSubAppErr(t3creq+` stdout`, stdOut)
@@ -561,7 +612,7 @@ func requestConfig(cfg config.Cfg) ([]byte, error) {
 	// TODO support /opt
 
 	cacheBts := ([]byte)(nil)
-	if !cfg.NoCache {
+	if !cfg.NoCache && cfg.FromBundle == "" {
 		err := error(nil)
 		if cacheBts, err = ioutil.ReadFile(t3cutil.ApplyCachePath); err != nil {
 			// don't log an error if the cache didn't exist
@@ -592,15 +643,7 @@ func requestConfig(cfg config.Cfg) ([]byte, error) {
 		args = append(args, "-v")
 	}
 
-	if _, used := os.LookupEnv("TO_USER"); !used {
-		args = append(args, "--traffic-ops-user="+cfg.TOUser)
-	}
-	if _, used := os.LookupEnv("TO_PASS"); !used {
-		args = append(args, "--traffic-ops-password="+cfg.TOPass)
-	}
-	if _, used := os.LookupEnv("TO_URL"); !used {
-		args = append(args, "--traffic-ops-url="+cfg.TOURL)
-	}
+	args = appendRequestSourceArgs(cfg, args)
 
 	stdOut := ([]byte)(nil)
 	stdErr := ([]byte)(nil)
@@ -617,13 +660,38 @@ func requestConfig(cfg config.Cfg) ([]byte, error) {
 	}
 	logSubApp(t3creq, stdErr)
 
-	if err := ioutil.WriteFile(t3cutil.ApplyCachePath, stdOut, 0600); err != nil {
-		log.Errorln("writing config data to cache failed: " + err.Error())
+	// Bundle data isn't cached, so the cache is always from Traffic Ops.
+	if cfg.FromBundle == "" {
+		if err := ioutil.WriteFile(t3cutil.ApplyCachePath, stdOut, 0600); err != nil {
+			log.Errorln("writing config data to cache failed: " + err.Error())
+		}
 	}
 
 	return stdOut, nil
 }
 
+// appendRequestSourceArgs appends the t3c-request args for where to get data from:
+// the bundle if cfg.FromBundle is set, otherwise the Traffic Ops credentials not already in the environment,
+// and the cache proxy if cfg.CacheProxyURL is set.
+func appendRequestSourceArgs(cfg config.Cfg, args []string) []string {
+	if cfg.FromBundle != "" {
+		return append(args, "--from-bundle="+cfg.FromBundle, "--bundle-public-key="+cfg.BundlePublicKey)
+	}
+	if cfg.CacheProxyURL != "" {
+		args = append(args, "--cache-proxy-url="+cfg.CacheProxyURL)
+	}
+	if _, used := os.LookupEnv("TO_USER"); !used {
+		args = append(args, "--traffic-ops-user="+cfg.TOUser)
+	}
+	if _, used := os.LookupEnv("TO_PASS"); !used {
+		args = append(args, "--traffic-ops-password="+cfg.TOPass)
+	}
+	if _, used := os.LookupEnv("TO_URL"); !used {
+		args = append(args, "--traffic-ops-url="+cfg.TOURL)
+	}
+	return args
+}
+
 func logSubApp(appName string, stdErr []byte)    { logSubAppWarnOrErr(appName, stdErr, false) }
 func logSubAppErr(appName string, stdErr []byte) { logSubAppWarnOrErr(appName, stdErr, true) }
 func logSubAppWarnOrErr(appName string, stdErr []byte, isErr bool) {
@@ -22,6 +22,8 @@ package torequest
 import (
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"os/user"
 	"path/filepath"
@@ -35,6 +37,7 @@ import (
 	"github.com/apache/trafficcontrol/v8/cache-config/t3c-apply/util"
 	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
 	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
 )
 
 type UpdateStatus int
@@ -53,6 +56,14 @@ const (
 	tailMatch            = `ET_(TASK|NET)\s\d{1,}`
 	tailRestartEnd       = "Traffic Server is fully initialized"
 	tailReloadEnd        = "remap.config finished loading"
+	// tailFailMatch matches ATS diags log lines which mean a reload or restart failed.
+	tailFailMatch = `(FATAL|ALERT|EMERGENCY):|(?i:failed to (re)?load)`
+
+	HealthCheckTimeOutMS      = 30000
+	healthCheckRequestTimeout = 5 * time.Second
+	healthCheckRetryInterval  = time.Second
+	backupDirPrefix           = "backup-"
+	backupDirTimeFormat       = "20060102150405"
 )
 
 type Package struct {
@@ -71,6 +82,14 @@ type TrafficOpsReq struct {
 	configFiles        map[string]*ConfigFile
 	configFileWarnings map[string][]string
 
+	backupDir     string               // directory config files are backed up to before they're replaced, if Cfg.RollbackOnFailure
+	serviceAction t3cutil.ServiceNeeds // the reload or restart StartServices performed, which RollbackConfigFiles performs again
+
+	// ServiceActionFailed is whether the reload or restart by StartServices failed, or its confirmation failed if Cfg.RollbackOnFailure.
+	ServiceActionFailed bool
+	// RolledBack is whether RollbackConfigFiles restored the replaced config files.
+	RolledBack bool
+
 	RestartData
 }
 
@@ -92,6 +111,7 @@ type RestartData struct {
 	RemapConfigReload    bool // remap.config should be reloaded
 	HitchReload          bool // hitch should be reloaded
 	VarnishReload        bool // varnish should be reloaded
+	NginxReload          bool // nginx should be reloaded
 }
 
 type ConfigFile struct {
@@ -198,6 +218,7 @@ func NewTrafficOpsReq(cfg config.Cfg) *TrafficOpsReq {
 		plugins:       map[string]bool{},
 		configFiles:   map[string]*ConfigFile{},
 		installedPkgs: map[string]struct{}{},
+		backupDir:     filepath.Join(config.TmpBase, backupDirPrefix+time.Now().Format(backupDirTimeFormat)),
 	}
 }
 
@@ -483,6 +504,12 @@ func (r *TrafficOpsReq) replaceCfgFile(cfg *ConfigFile) (*FileRestartData, error
 		return &FileRestartData{Name: cfg.Name}, nil
 	}
 
+	if r.Cfg.RollbackOnFailure {
+		if err := r.backupCfgFile(cfg); err != nil {
+			return &FileRestartData{Name: cfg.Name}, errors.New("Failed to back up config file '" + cfg.Path + "', not replacing it: " + err.Error())
+		}
+	}
+
 	tmpFileName := cfg.Path + configFileTempSuffix
 	log.Infof("Writing temp file '%s' with file mode: '%#o' \n", tmpFileName, cfg.Perm)
 
@@ -524,6 +551,7 @@ func (r *TrafficOpsReq) replaceCfgFile(cfg *ConfigFile) (*FileRestartData, error
 	sysCtlReload := cfg.Name == "sysctl.conf"
 	hitchReload := cfg.Name == "hitch.conf"
 	varnishReload := cfg.Name == "default.vcl"
+	nginxReload := cfg.Name == "trafficcontrol.conf"
 
 	log.Debugf("Reload state after %s: remap.config: %t reload: %t restart: %t ntpd: %t sysctl: %t", cfg.Name, remapConfigReload, trafficCtlReload, trafficServerRestart, ntpdRestart, sysCtlReload)
 
@@ -538,10 +566,77 @@ func (r *TrafficOpsReq) replaceCfgFile(cfg *ConfigFile) (*FileRestartData, error
 			RemapConfigReload:    remapConfigReload,
 			HitchReload:          hitchReload,
 			VarnishReload:        varnishReload,
+			NginxReload:          nginxReload,
 		},
 	}, nil
 }
 
+// backupCfgFile copies the config file on disk to its path under the backup directory, with its mode and owner, and sets cfg.CfgBackup to the copy.
+// If the file doesn't exist yet, no backup is made, and cfg.CfgBackup is empty.
+func (r *TrafficOpsReq) backupCfgFile(cfg *ConfigFile) error {
+	cfg.CfgBackup = ""
+	info, err := os.Stat(cfg.Path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.New("getting file info: " + err.Error())
+	}
+	body, err := os.ReadFile(cfg.Path)
+	if err != nil {
+		return errors.New("reading file: " + err.Error())
+	}
+	// the backup keeps the file's full path under the backup directory, so files with the same name in different directories don't overwrite each other's backups.
+	backupPath := filepath.Join(r.backupDir, cfg.Path)
+	if err := os.MkdirAll(filepath.Dir(backupPath), 0755); err != nil {
+		return errors.New("creating backup directory '" + filepath.Dir(backupPath) + "': " + err.Error())
+	}
+	uid, gid := fileOwner(info)
+	if _, err := util.WriteFileWithOwner(backupPath, body, &uid, &gid, info.Mode().Perm()); err != nil {
+		return err
+	}
+	cfg.CfgBackup = backupPath
+	log.Infof("Backed up '%s' to '%s'\n", cfg.Path, backupPath)
+	return nil
+}
+
+// restoreCfgFile restores the config file from its backup, or removes it if it didn't exist before it was replaced.
+func restoreCfgFile(cfg *ConfigFile) error {
+	if cfg.CfgBackup == "" {
+		if err := os.Remove(cfg.Path); err != nil && !os.IsNotExist(err) {
+			return errors.New("removing '" + cfg.Path + "', which didn't exist before: " + err.Error())
+		}
+		log.Infof("Removed '%s', which didn't exist before it was replaced\n", cfg.Path)
+		return nil
+	}
+	info, err := os.Stat(cfg.CfgBackup)
+	if err != nil {
+		return errors.New("getting backup '" + cfg.CfgBackup + "' file info: " + err.Error())
+	}
+	body, err := os.ReadFile(cfg.CfgBackup)
+	if err != nil {
+		return errors.New("reading backup '" + cfg.CfgBackup + "': " + err.Error())
+	}
+	// like replaceCfgFile, write a temp file and move it, so the restore is atomic.
+	tmpFileName := cfg.Path + configFileTempSuffix
+	uid, gid := fileOwner(info)
+	if _, err := util.WriteFileWithOwner(tmpFileName, body, &uid, &gid, info.Mode().Perm()); err != nil {
+		return errors.New("writing temp file to restore '" + cfg.Path + "': " + err.Error())
+	}
+	if err := os.Rename(tmpFileName, cfg.Path); err != nil {
+		return errors.New("moving temp '" + tmpFileName + "' to restore '" + cfg.Path + "': " + err.Error())
+	}
+	log.Infof("Restored '%s' from '%s'\n", cfg.Path, cfg.CfgBackup)
+	return nil
+}
+
+// fileOwner returns the uid and gid of the file, or the current user's if they can't be determined.
+func fileOwner(info os.FileInfo) (int, int) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int(stat.Uid), int(stat.Gid)
+	}
+	return os.Getuid(), os.Getgid()
+}
+
 // CheckSystemServices is used to verify that packages installed
 // are enabled for startup.
 func (r *TrafficOpsReq) CheckSystemServices() error {
@@ -690,6 +785,49 @@ func (r *TrafficOpsReq) GetConfigFileList() error {
 	return nil
 }
 
+// CheckConfigDrift generates the config files and returns the files which
+// have drifted on disk from the generated config. If r.Cfg.ReportDrift, the
+// drifted files are also reported to Traffic Ops. Nothing on disk is changed.
+func (r *TrafficOpsReq) CheckConfigDrift() ([]tc.ConfigDriftFile, error) {
+	allFiles, err := generate(r.Cfg)
+	if err != nil {
+		return nil, errors.New("requesting data generating config files: " + err.Error())
+	}
+	drifted, err := getConfigDrift(allFiles)
+	if err != nil {
+		return nil, errors.New("comparing config files: " + err.Error())
+	}
+	if r.Cfg.ReportDrift {
+		if err := sendConfigDrift(r.Cfg, drifted); err != nil {
+			return drifted, errors.New("reporting config drift to Traffic Ops: " + err.Error())
+		}
+	}
+	return drifted, nil
+}
+
+// getConfigDrift returns the files whose contents on disk differ from their
+// generated text, ignoring comments and whitespace like t3c-diff, or which
+// don't exist on disk.
+func getConfigDrift(files []t3cutil.ATSConfigFile) ([]tc.ConfigDriftFile, error) {
+	drifted := []tc.ConfigDriftFile{}
+	for _, file := range files {
+		path := filepath.Join(file.Path, file.Name)
+		onDisk, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			log.Infof("config file '%s' is missing\n", path)
+			drifted = append(drifted, tc.ConfigDriftFile{Name: file.Name, Path: file.Path, Status: tc.ConfigDriftMissing})
+			continue
+		} else if err != nil {
+			return nil, errors.New("reading config file '" + path + "': " + err.Error())
+		}
+		if t3cutil.NormalizeConfig(string(onDisk), file.LineComment) != t3cutil.NormalizeConfig(file.Text, file.LineComment) {
+			log.Infof("config file '%s' has drifted\n", path)
+			drifted = append(drifted, tc.ConfigDriftFile{Name: file.Name, Path: file.Path, Status: tc.ConfigDriftModified})
+		}
+	}
+	return drifted, nil
+}
+
 func (r *TrafficOpsReq) PrintWarnings() {
 	log.Infoln("======== Summary of config warnings that may need attention. ========")
 	for file, warning := range r.configFileWarnings {
@@ -818,6 +956,7 @@ func (r *TrafficOpsReq) CheckReloadRestart(data []FileRestartData) RestartData {
 		rd.RemapConfigReload = rd.RemapConfigReload || changedFile.RemapConfigReload
 		rd.HitchReload = rd.HitchReload || changedFile.HitchReload
 		rd.VarnishReload = rd.VarnishReload || changedFile.VarnishReload
+		rd.NginxReload = rd.NginxReload || changedFile.NginxReload
 	}
 	return rd
 }
@@ -1157,15 +1296,12 @@ func (r *TrafficOpsReq) StartServices(syncdsUpdate *UpdateStatus, metaData *t3cu
 	// If check-reload does not know about these and we do, then we should initiate
 	// a reload as well
 	if serviceNeeds != t3cutil.ServiceNeedsRestart && serviceNeeds != t3cutil.ServiceNeedsReload {
-		if r.TrafficCtlReload || r.RemapConfigReload || r.VarnishReload {
+		if r.TrafficCtlReload || r.RemapConfigReload || r.VarnishReload || r.NginxReload {
 			log.Infof("ATS config files unchanged, we updated files via t3c-apply, ATS needs reload")
 			serviceNeeds = t3cutil.ServiceNeedsReload
 		}
 	}
-	packageName := "trafficserver"
-	if cfg.CacheType == "varnish" {
-		packageName = "varnish"
-	}
+	packageName := servicePackageName(cfg)
 
 	if (serviceNeeds == t3cutil.ServiceNeedsRestart || serviceNeeds == t3cutil.ServiceNeedsReload) && !r.IsPackageInstalled(packageName) {
 		// TODO try to reload/restart anyway? To allow non-RPM installs?
@@ -1189,8 +1325,11 @@ func (r *TrafficOpsReq) StartServices(syncdsUpdate *UpdateStatus, metaData *t3cu
 		if svcStatus != util.SvcRunning {
 			startStr = "start"
 		}
+		r.serviceAction = t3cutil.ServiceNeedsRestart
 		if _, err := util.ServiceStart(packageName, startStr); err != nil {
 			t3cutil.WriteActionLog(t3cutil.ActionLogActionATSRestart, t3cutil.ActionLogStatusFailure, metaData)
+			r.ServiceActionFailed = true
+			failUpdate(syncdsUpdate)
 			return errors.New("failed to restart trafficserver")
 		}
 		t3cutil.WriteActionLog(t3cutil.ActionLogActionATSRestart, t3cutil.ActionLogStatusSuccess, metaData)
@@ -1198,7 +1337,12 @@ func (r *TrafficOpsReq) StartServices(syncdsUpdate *UpdateStatus, metaData *t3cu
 
 		if !r.Cfg.NoConfirmServiceAction {
 			log.Infoln("confirming ATS restart succeeded")
-			if err := doTail(r.Cfg, TailDiagsLogRelative, ".*", tailRestartEnd, TailRestartTimeOutMS); err != nil {
+			if err := r.confirmServiceAction(".*", tailRestartEnd, TailRestartTimeOutMS); err != nil {
+				if r.Cfg.RollbackOnFailure {
+					r.ServiceActionFailed = true
+					failUpdate(syncdsUpdate)
+					return errors.New("confirming ATS restart succeeded: " + err.Error())
+				}
 				log.Errorln("error running tail")
 			}
 		} else {
@@ -1216,14 +1360,10 @@ func (r *TrafficOpsReq) StartServices(syncdsUpdate *UpdateStatus, metaData *t3cu
 			log.Errorln("ATS configuration has changed.  The new config will be picked up the next time ATS is started.")
 		} else if serviceNeeds == t3cutil.ServiceNeedsReload {
 			log.Infoln("ATS configuration has changed, Running 'traffic_ctl config reload' now.")
-			reloadCommand := config.TSHome + config.TrafficCtl
-			reloadArgs := []string{"config", "reload"}
-			if cfg.CacheType == "varnish" {
-				reloadCommand = "/usr/sbin/varnishreload"
-				reloadArgs = []string{}
-			}
-			if _, _, err := util.ExecCommand(reloadCommand, reloadArgs...); err != nil {
+			r.serviceAction = t3cutil.ServiceNeedsReload
+			if err := reloadService(cfg); err != nil {
 				t3cutil.WriteActionLog(t3cutil.ActionLogActionATSReload, t3cutil.ActionLogStatusFailure, metaData)
+				r.ServiceActionFailed = true
 
 				if *syncdsUpdate == UpdateTropsNeeded {
 					*syncdsUpdate = UpdateTropsFailed
@@ -1239,7 +1379,12 @@ func (r *TrafficOpsReq) StartServices(syncdsUpdate *UpdateStatus, metaData *t3cu
 
 			if !r.Cfg.NoConfirmServiceAction {
 				log.Infoln("confirming ATS reload succeeded")
-				if err := doTail(r.Cfg, TailDiagsLogRelative, tailMatch, tailReloadEnd, TailReloadTimeOutMS); err != nil {
+				if err := r.confirmServiceAction(tailMatch, tailReloadEnd, TailReloadTimeOutMS); err != nil {
+					if r.Cfg.RollbackOnFailure {
+						r.ServiceActionFailed = true
+						failUpdate(syncdsUpdate)
+						return errors.New("confirming ATS reload succeeded: " + err.Error())
+					}
 					log.Errorln("error running tail: ", err)
 				}
 			} else {
@@ -1254,6 +1399,178 @@ func (r *TrafficOpsReq) StartServices(syncdsUpdate *UpdateStatus, metaData *t3cu
 	return nil
 }
 
+// servicePackageName returns the name of the package and service of the cache.
+func servicePackageName(cfg config.Cfg) string {
+	if cfg.CacheType == "varnish" {
+		return "varnish"
+	}
+	if cfg.CacheType == "nginx" {
+		return "nginx"
+	}
+	return "trafficserver"
+}
+
+// reloadService reloads the cache's config, with 'traffic_ctl config reload' for ATS.
+// For nginx, the config is tested first, because 'nginx -s reload' succeeds even
+// if the new config is invalid.
+func reloadService(cfg config.Cfg) error {
+	reloadCommand := config.TSHome + config.TrafficCtl
+	reloadArgs := []string{"config", "reload"}
+	if cfg.CacheType == "varnish" {
+		reloadCommand = "/usr/sbin/varnishreload"
+		reloadArgs = []string{}
+	}
+	if cfg.CacheType == "nginx" {
+		if _, _, err := util.ExecCommand("/usr/sbin/nginx", "-t"); err != nil {
+			return errors.New("testing nginx config: " + err.Error())
+		}
+		reloadCommand = "/usr/sbin/nginx"
+		reloadArgs = []string{"-s", "reload"}
+	}
+	_, _, err := util.ExecCommand(reloadCommand, reloadArgs...)
+	return err
+}
+
+// failUpdate sets the syncdsUpdate to UpdateTropsFailed, if an update was needed.
+func failUpdate(syncdsUpdate *UpdateStatus) {
+	if *syncdsUpdate == UpdateTropsNeeded || *syncdsUpdate == UpdateTropsSuccessful {
+		*syncdsUpdate = UpdateTropsFailed
+	}
+}
+
+// confirmServiceAction runs t3c-tail on the ATS diags log until a line matching endMatch is logged, logging the lines matching logMatch.
+//
+// If Cfg.RollbackOnFailure, it also returns an error if ATS logged a failure, the endMatch wasn't logged before the timeout,
+// or the Cfg.HealthCheckURL didn't respond successfully.
+// Otherwise, it only returns an error if t3c-tail failed.
+func (r *TrafficOpsReq) confirmServiceAction(logMatch string, endMatch string, timeoutMS int) error {
+	if !r.Cfg.RollbackOnFailure {
+		_, err := doTail(r.Cfg, TailDiagsLogRelative, logMatch, endMatch, timeoutMS)
+		return err
+	}
+	// Only ATS logs to the diags log, so other caches are confirmed by the service command's exit status, which the
+	// caller already checked, and the health check.
+	if servicePackageName(r.Cfg) == "trafficserver" {
+		lines, err := doTail(r.Cfg, TailDiagsLogRelative, logMatch+"|"+endMatch+"|"+tailFailMatch, endMatch+"|"+tailFailMatch, timeoutMS)
+		if err != nil {
+			return errors.New("running tail: " + err.Error())
+		}
+		if err := checkTailLines(lines, endMatch); err != nil {
+			return err
+		}
+	}
+	if r.Cfg.HealthCheckURL == "" {
+		return nil
+	}
+	if err := checkHealth(r.Cfg.HealthCheckURL, HealthCheckTimeOutMS*time.Millisecond); err != nil {
+		return errors.New("health check '" + r.Cfg.HealthCheckURL + "' failed: " + err.Error())
+	}
+	log.Infoln("health check '" + r.Cfg.HealthCheckURL + "' succeeded")
+	return nil
+}
+
+// checkTailLines returns an error if any of the lines printed by t3c-tail are ATS failures, or if none match endMatch,
+// which means t3c-tail timed out before ATS finished.
+func checkTailLines(lines []string, endMatch string) error {
+	failRe := regexp.MustCompile(tailFailMatch)
+	endRe := regexp.MustCompile(endMatch)
+	ended := false
+	for _, line := range lines {
+		if failRe.MatchString(line) {
+			return errors.New("ATS logged failure: " + line)
+		}
+		if endRe.MatchString(line) {
+			ended = true
+		}
+	}
+	if !ended {
+		return errors.New("ATS didn't log '" + endMatch + "' before timing out")
+	}
+	return nil
+}
+
+// checkHealth requests the URL until it responds with a 2xx status, or the timeout passes.
+func checkHealth(url string, timeout time.Duration) error {
+	client := &http.Client{Timeout: healthCheckRequestTimeout}
+	deadline := time.Now().Add(timeout)
+	for {
+		err := requestHealth(client, url)
+		if err == nil {
+			return nil
+		}
+		if time.Now().Add(healthCheckRetryInterval).After(deadline) {
+			return err
+		}
+		log.Infoln("health check failed, retrying: " + err.Error())
+		time.Sleep(healthCheckRetryInterval)
+	}
+}
+
+func requestHealth(client *http.Client, url string) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// RollbackConfigFiles restores the config files replaced by ProcessConfigFiles from their backups,
+// removes the config files it created, and reloads or restarts the service again.
+// It must only be called if Cfg.RollbackOnFailure, after StartServices failed with ServiceActionFailed.
+//
+// The syncdsUpdate is set to UpdateTropsFailed, and UpdateTrafficOps will then set the update failed in Traffic Ops.
+// Installed packages and other changes to the system are not rolled back.
+func (r *TrafficOpsReq) RollbackConfigFiles(syncdsUpdate *UpdateStatus, metaData *t3cutil.ApplyMetaData) error {
+	log.Infoln("======== Rolling back config files ========")
+	failUpdate(syncdsUpdate)
+	r.RolledBack = true
+
+	restoreErrs := []string{}
+	for _, cfg := range r.configFiles {
+		if !cfg.ChangeApplied {
+			continue
+		}
+		if err := restoreCfgFile(cfg); err != nil {
+			restoreErrs = append(restoreErrs, err.Error())
+			continue
+		}
+		cfg.ChangeApplied = false
+	}
+	if len(restoreErrs) > 0 {
+		t3cutil.WriteActionLog(t3cutil.ActionLogActionRollback, t3cutil.ActionLogStatusFailure, metaData)
+		return errors.New("restoring config files: " + strings.Join(restoreErrs, "; "))
+	}
+	t3cutil.WriteActionLog(t3cutil.ActionLogActionRollback, t3cutil.ActionLogStatusSuccess, metaData)
+
+	switch r.serviceAction {
+	case t3cutil.ServiceNeedsRestart:
+		if _, err := util.ServiceStart(servicePackageName(r.Cfg), "restart"); err != nil {
+			t3cutil.WriteActionLog(t3cutil.ActionLogActionATSRestart, t3cutil.ActionLogStatusFailure, metaData)
+			return errors.New("restarting after rolling back config files: " + err.Error())
+		}
+		t3cutil.WriteActionLog(t3cutil.ActionLogActionATSRestart, t3cutil.ActionLogStatusSuccess, metaData)
+		if err := r.confirmServiceAction(".*", tailRestartEnd, TailRestartTimeOutMS); err != nil {
+			return errors.New("confirming restart after rolling back config files: " + err.Error())
+		}
+	case t3cutil.ServiceNeedsReload:
+		if err := reloadService(r.Cfg); err != nil {
+			t3cutil.WriteActionLog(t3cutil.ActionLogActionATSReload, t3cutil.ActionLogStatusFailure, metaData)
+			return errors.New("reloading after rolling back config files: " + err.Error())
+		}
+		t3cutil.WriteActionLog(t3cutil.ActionLogActionATSReload, t3cutil.ActionLogStatusSuccess, metaData)
+		if err := r.confirmServiceAction(tailMatch, tailReloadEnd, TailReloadTimeOutMS); err != nil {
+			return errors.New("confirming reload after rolling back config files: " + err.Error())
+		}
+	}
+	log.Infoln("Config files were rolled back")
+	return nil
+}
+
 func (r *TrafficOpsReq) ShowUpdateStatus(flagType []string, start time.Time, curSetting, newSetting bool) {
 	for _, flag := range flagType {
 		log.Infof("%s flag currently set to %v, setting to %v took %v", flag, curSetting, newSetting, time.Since(start).Round(time.Millisecond))
@@ -1274,6 +1591,8 @@ func (r *TrafficOpsReq) UpdateTrafficOps(syncdsUpdate *UpdateStatus) error {
 	} else if *syncdsUpdate == UpdateTropsNotNeeded {
 		log.Errorln("Traffic Ops does not require an update at this time")
 		return nil
+	} else if *syncdsUpdate == UpdateTropsFailed && r.RolledBack {
+		return r.sendUpdateFailed()
 	} else if *syncdsUpdate == UpdateTropsFailed {
 		log.Errorln("Traffic Ops requires an update but, applying the update locally failed.  Traffic Ops is not being updated.")
 		return nil
@@ -1299,12 +1618,12 @@ func (r *TrafficOpsReq) UpdateTrafficOps(syncdsUpdate *UpdateStatus) error {
 			b = false
 			apply = append(apply, "update")
 			log.Infof("Update flag currently set to %v, setting to %v", serverStatus.UpdatePending, b)
-			err = sendUpdate(r.Cfg, serverStatus.ConfigUpdateTime, nil, &b, nil)
+			err = sendUpdate(r.Cfg, serverStatus.ConfigUpdateTime, nil, &b, nil, nil, nil)
 		} else if r.Cfg.Files == t3cutil.ApplyFilesFlagReval {
 			b = false
 			apply = append(apply, t3cutil.ApplyFilesFlagReval.String())
 			log.Infof("Reval flag currently set to %v, setting to %v", serverStatus.RevalPending, b)
-			err = sendUpdate(r.Cfg, nil, serverStatus.RevalidateUpdateTime, nil, &b)
+			err = sendUpdate(r.Cfg, nil, serverStatus.RevalidateUpdateTime, nil, &b, nil, nil)
 		}
 		if err != nil {
 			return errors.New("Traffic Ops Update failed: " + err.Error())
@@ -1314,3 +1633,24 @@ func (r *TrafficOpsReq) UpdateTrafficOps(syncdsUpdate *UpdateStatus) error {
 	}
 	return nil
 }
+
+// sendUpdateFailed sets in Traffic Ops that the update failed to apply and was rolled back.
+// The update is left pending, so it's applied again by the next run.
+func (r *TrafficOpsReq) sendUpdateFailed() error {
+	log.Errorln("Traffic Ops requires an update but, applying the update locally failed and was rolled back.  Setting the update failed in Traffic Ops.")
+	if r.Cfg.ReportOnly || r.Cfg.NoUnsetUpdateFlag {
+		return nil
+	}
+	failed := true
+	err := error(nil)
+	if r.Cfg.Files == t3cutil.ApplyFilesFlagReval {
+		err = sendUpdate(r.Cfg, nil, nil, nil, nil, nil, &failed)
+	} else {
+		err = sendUpdate(r.Cfg, nil, nil, nil, nil, &failed, nil)
+	}
+	if err != nil {
+		return errors.New("Traffic Ops update failed status failed: " + err.Error())
+	}
+	log.Infoln("Traffic Ops has been updated with the failed update.")
+	return nil
+}
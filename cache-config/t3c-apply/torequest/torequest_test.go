@@ -20,10 +20,18 @@ package torequest
  */
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/apache/trafficcontrol/v8/cache-config/t3c-apply/config"
 	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
 )
 
 var testCfg config.Cfg = config.Cfg{
@@ -138,3 +146,183 @@ func TestGetConfigFile(t *testing.T) {
 		t.Errorf("GetConfigFile('remap.config') failed, expected 'remap.config' got '" + cfg.Name + "'.")
 	}
 }
+
+func TestCheckTailLines(t *testing.T) {
+	succeeded := []string{
+		"[ET_TASK 2] NOTE: loading remap.config",
+		"[ET_TASK 2] NOTE: remap.config finished loading",
+	}
+	if err := checkTailLines(succeeded, tailReloadEnd); err != nil {
+		t.Errorf("expected reload that finished to succeed, actual error: %v", err)
+	}
+
+	failed := []string{
+		"[ET_TASK 2] NOTE: loading remap.config",
+		"[ET_TASK 2] ERROR: remap.config failed to load",
+	}
+	if err := checkTailLines(failed, tailReloadEnd); err == nil {
+		t.Error("expected reload that failed to load remap.config to fail, actual: success")
+	}
+
+	crashed := []string{
+		"[ET_NET 0] FATAL: unable to start",
+		"[ET_TASK 2] NOTE: Traffic Server is fully initialized",
+	}
+	if err := checkTailLines(crashed, tailRestartEnd); err == nil {
+		t.Error("expected restart that logged a fatal error to fail, actual: success")
+	}
+
+	if err := checkTailLines([]string{"[ET_TASK 2] NOTE: loading remap.config"}, tailReloadEnd); err == nil {
+		t.Error("expected reload that timed out to fail, actual: success")
+	}
+}
+
+func TestCheckHealth(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	if err := checkHealth(srv.URL, 5*time.Second); err != nil {
+		t.Errorf("expected health check to succeed after retrying, actual error: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("expected health check to request 2 times, actual %d", requests)
+	}
+
+	unhealthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer unhealthy.Close()
+	if err := checkHealth(unhealthy.URL, 0); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("expected unhealthy health check to fail with its status, actual error: %v", err)
+	}
+}
+
+func TestConfirmServiceActionNonATS(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+
+	// t3c-tail isn't installed when testing, so tailing the ATS diags log would fail.
+	for _, cacheType := range []string{"varnish", "nginx"} {
+		cfg := testCfg
+		cfg.CacheType = cacheType
+		cfg.RollbackOnFailure = true
+		r := &TrafficOpsReq{Cfg: cfg}
+		if err := r.confirmServiceAction(tailMatch, tailReloadEnd, TailReloadTimeOutMS); err != nil {
+			t.Errorf("expected %s reload without a health check to be confirmed, actual error: %v", cacheType, err)
+		}
+		r.Cfg.HealthCheckURL = healthy.URL
+		if err := r.confirmServiceAction(tailMatch, tailReloadEnd, TailReloadTimeOutMS); err != nil {
+			t.Errorf("expected %s reload with a healthy health check to be confirmed, actual error: %v", cacheType, err)
+		}
+	}
+
+	cfg := testCfg
+	cfg.CacheType = "ats"
+	cfg.RollbackOnFailure = true
+	r := &TrafficOpsReq{Cfg: cfg}
+	if err := r.confirmServiceAction(tailMatch, tailReloadEnd, TailReloadTimeOutMS); err == nil {
+		t.Errorf("expected ats reload to be confirmed by tailing the diags log, which fails without t3c-tail, actual nil error")
+	}
+}
+
+func TestBackupRestoreCfgFile(t *testing.T) {
+	dir := t.TempDir()
+	r := &TrafficOpsReq{Cfg: testCfg, backupDir: filepath.Join(dir, "backup")}
+
+	existing := &ConfigFile{Name: "records.config", Path: filepath.Join(dir, "records.config")}
+	if err := os.WriteFile(existing.Path, []byte("old"), 0640); err != nil {
+		t.Fatalf("writing test file: %v", err)
+	}
+	created := &ConfigFile{Name: "new.config", Path: filepath.Join(dir, "new.config")}
+	if err := os.Mkdir(filepath.Join(dir, "ssl"), 0750); err != nil {
+		t.Fatalf("creating test dir: %v", err)
+	}
+	sameName := &ConfigFile{Name: "records.config", Path: filepath.Join(dir, "ssl", "records.config")}
+	if err := os.WriteFile(sameName.Path, []byte("old ssl"), 0640); err != nil {
+		t.Fatalf("writing test file: %v", err)
+	}
+
+	for _, cfg := range []*ConfigFile{existing, created, sameName} {
+		if err := r.backupCfgFile(cfg); err != nil {
+			t.Fatalf("backing up '%s': %v", cfg.Name, err)
+		}
+		if err := os.WriteFile(cfg.Path, []byte("new"), 0600); err != nil {
+			t.Fatalf("replacing test file: %v", err)
+		}
+	}
+	if created.CfgBackup != "" {
+		t.Errorf("expected no backup of a file that didn't exist, actual '%s'", created.CfgBackup)
+	}
+
+	if existing.CfgBackup == sameName.CfgBackup {
+		t.Errorf("expected files with the same name in different directories to have different backups, actual both '%s'", existing.CfgBackup)
+	}
+
+	for _, cfg := range []*ConfigFile{existing, created, sameName} {
+		if err := restoreCfgFile(cfg); err != nil {
+			t.Fatalf("restoring '%s': %v", cfg.Name, err)
+		}
+	}
+	body, err := os.ReadFile(existing.Path)
+	if err != nil {
+		t.Fatalf("reading restored file: %v", err)
+	}
+	if string(body) != "old" {
+		t.Errorf("expected restored file to contain 'old', actual '%s'", body)
+	}
+	if body, err := os.ReadFile(sameName.Path); err != nil || string(body) != "old ssl" {
+		t.Errorf("expected restored file with the same name to contain 'old ssl', actual '%s' error %v", body, err)
+	}
+	if info, err := os.Stat(existing.Path); err != nil {
+		t.Errorf("getting restored file info: %v", err)
+	} else if info.Mode().Perm() != 0640 {
+		t.Errorf("expected restored file mode %#o, actual %#o", 0640, info.Mode().Perm())
+	}
+	if _, err := os.Stat(created.Path); !os.IsNotExist(err) {
+		t.Errorf("expected file that didn't exist to be removed, actual stat error: %v", err)
+	}
+}
+
+func TestGetConfigDrift(t *testing.T) {
+	dir := t.TempDir()
+	onDisk := map[string]string{
+		"remap.config":   "# DO NOT EDIT - Generated for odol-atsec-sea-22 by t3c-generate v1.0 on Mon Jan 15 12:00:00 UTC 2024\nmap http://a.example.net http://origin.example.net\n",
+		"parent.config":  "dest_domain=. go_direct=true\ndest_domain=origin.example.net parent=\"mid0:80|0.999\"\n",
+		"default.vcl":    "// hand edited\nvcl 4.1;\n",
+		"hosting.config": "hostname=* volume=1\n",
+	}
+	for name, text := range onDisk {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatalf("writing test file: %v", err)
+		}
+	}
+
+	generated := []t3cutil.ATSConfigFile{
+		{Name: "remap.config", Path: dir, LineComment: "#", Text: "# DO NOT EDIT - Generated for odol-atsec-sea-22 by t3c-generate v1.1 on Tue Jan 16 12:00:00 UTC 2024\nmap http://a.example.net  http://origin.example.net\n"},
+		{Name: "parent.config", Path: dir, LineComment: "#", Text: "dest_domain=. go_direct=true\n"},
+		{Name: "default.vcl", Path: dir, LineComment: "//", Text: "vcl 4.1;\n"},
+		{Name: "storage.config", Path: dir, LineComment: "#", Text: "/dev/sdb volume=1\n"},
+		{Name: "hosting.config", Path: dir, LineComment: "", Text: "hostname=* volume=1"},
+	}
+	drifted, err := getConfigDrift(generated)
+	if err != nil {
+		t.Fatalf("expected no error, actual: %v", err)
+	}
+	expected := []tc.ConfigDriftFile{
+		{Name: "parent.config", Path: dir, Status: tc.ConfigDriftModified},
+		{Name: "storage.config", Path: dir, Status: tc.ConfigDriftMissing},
+	}
+	if !reflect.DeepEqual(expected, drifted) {
+		t.Errorf("expected drifted files %+v, actual %+v", expected, drifted)
+	}
+}
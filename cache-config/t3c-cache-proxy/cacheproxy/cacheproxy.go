@@ -0,0 +1,470 @@
+// Package cacheproxy is a caching proxy of the Traffic Ops API, which serves
+// the CDN-wide objects requested by every t3c run from a cache, so the caches
+// behind it don't each request them from Traffic Ops.
+package cacheproxy
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/apache/trafficcontrol/v8/lib/go-rfc"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// cacheablePath matches the Traffic Ops API paths of the CDN-wide objects t3c requests.
+// Requests for anything else, including all keys and all server-specific data, are passed through to Traffic Ops.
+var cacheablePath = regexp.MustCompile(`^/api/[0-9]+\.[0-9]+/(servers|cachegroups|cdns|deliveryservices|deliveryserviceserver|deliveryservices_regexes|deliveryservices_required_capabilities|server_server_capabilities|topologies|jobs|parameters|profiles/name/[^/]+/parameters)/?$`)
+
+// ifNoneMatch is the If-None-Match request header, RFC7232§3.2.
+const ifNoneMatch = "If-None-Match"
+
+// apiVersion matches the API version prefix of a Traffic Ops API path.
+var apiVersion = regexp.MustCompile(`^/api/[0-9]+\.[0-9]+/`)
+
+// cachedHeaders are the Traffic Ops response headers stored with, and served from, cached objects.
+var cachedHeaders = []string{rfc.ContentType, rfc.LastModified, rfc.ETagHeader}
+
+// Config is the configuration of a Proxy.
+type Config struct {
+	// TOURL is the URL of the real Traffic Ops.
+	TOURL *url.URL
+
+	// Transport is used to pass requests through to Traffic Ops, and to check client credentials.
+	// If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// Login logs the proxy into Traffic Ops, and returns a client with the session.
+	// The client is used to fetch cached objects, and Login is called again if its session expires.
+	Login func() (*http.Client, error)
+
+	// RefreshInterval is how long a cached object is served before it is revalidated with Traffic Ops.
+	RefreshInterval time.Duration
+
+	// AuthCacheTime is how long client credentials are trusted after Traffic Ops accepted them.
+	AuthCacheTime time.Duration
+
+	// ExpireTime is how long a cached object is kept after it was last requested.
+	// Objects are cached by path and query, so this keeps objects requested with queries no cache repeats from accumulating.
+	ExpireTime time.Duration
+}
+
+// Proxy is an http.Handler which serves cacheable Traffic Ops API requests from its cache,
+// and passes all other requests through to Traffic Ops.
+type Proxy struct {
+	cfg         Config
+	passthrough *httputil.ReverseProxy
+
+	clientM *sync.Mutex
+	client  *http.Client
+
+	// entriesM guards entries, and the requested time of each entry.
+	entriesM *sync.Mutex
+	entries  map[string]*entry
+
+	authsM *sync.Mutex
+	auths  map[[sha256.Size]byte]authorization
+
+	// identities are the role and tenant of the proxy's own Traffic Ops user, by API version.
+	identitiesM *sync.Mutex
+	identities  map[string]authorization
+}
+
+// identity is the role and tenant of a Traffic Ops user, which determine the objects Traffic Ops returns to them.
+// Role and tenant are the raw JSON of the user/current response, which differ between API versions, but are only compared within one.
+type identity struct {
+	role   string
+	tenant string
+}
+
+// authorization is an identity Traffic Ops returned for some credentials, and when.
+type authorization struct {
+	identity identity
+	time     time.Time
+}
+
+// entry is a cached Traffic Ops response.
+type entry struct {
+	m         *sync.Mutex
+	body      []byte
+	header    http.Header
+	fetched   time.Time
+	requested time.Time
+}
+
+// New creates a new Proxy. The proxy is not logged in to Traffic Ops until the first cacheable request.
+func New(cfg Config) *Proxy {
+	if cfg.Transport == nil {
+		cfg.Transport = http.DefaultTransport
+	}
+	passthrough := httputil.NewSingleHostReverseProxy(cfg.TOURL)
+	passthrough.Transport = cfg.Transport
+	director := passthrough.Director
+	passthrough.Director = func(r *http.Request) {
+		director(r)
+		r.Host = cfg.TOURL.Host
+	}
+	return &Proxy{
+		cfg:         cfg,
+		passthrough: passthrough,
+		clientM:     &sync.Mutex{},
+		entriesM:    &sync.Mutex{},
+		entries:     map[string]*entry{},
+		authsM:      &sync.Mutex{},
+		auths:       map[[sha256.Size]byte]authorization{},
+		identitiesM: &sync.Mutex{},
+		identities:  map[string]authorization{},
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || !cacheablePath.MatchString(r.URL.Path) {
+		p.passthrough.ServeHTTP(w, r)
+		return
+	}
+
+	id, code, err := p.authorize(r)
+	if err != nil {
+		log.Warnf("cache proxy: %s %s from %s: %v\n", r.Method, r.URL.RequestURI(), r.RemoteAddr, err)
+		writeAlert(w, code, err.Error())
+		return
+	}
+
+	// Cached objects are fetched with the proxy's user, and Traffic Ops filters objects by the role and tenant of the user, so they're only served to clients with the same role and tenant.
+	// Others get what Traffic Ops returns for their own credentials.
+	if selfID, err := p.selfIdentity(r.URL.Path); err != nil {
+		log.Errorf("cache proxy: getting the proxy's Traffic Ops user: %v\n", err)
+		writeAlert(w, http.StatusBadGateway, "getting the proxy's Traffic Ops user failed")
+		return
+	} else if id != selfID {
+		log.Infof("cache proxy: %s %s from %s: client's role and tenant differ from the proxy's Traffic Ops user, passing through\n", r.Method, r.URL.RequestURI(), r.RemoteAddr)
+		p.passthrough.ServeHTTP(w, r)
+		return
+	}
+
+	key := r.URL.Path + "?" + r.URL.Query().Encode()
+	e := p.entry(key)
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	if time.Since(e.fetched) >= p.cfg.RefreshInterval {
+		if resp, err := p.refresh(r.URL, e); err != nil {
+			if e.body == nil {
+				log.Errorf("cache proxy: fetching '%s' from Traffic Ops: %v\n", key, err)
+				writeAlert(w, http.StatusBadGateway, "fetching from Traffic Ops failed")
+				return
+			}
+			// Don't retry until the next refresh, so a down Traffic Ops isn't requested by every cache.
+			log.Errorf("cache proxy: refreshing '%s' from Traffic Ops, serving stale object: %v\n", key, err)
+			e.fetched = time.Now()
+		} else if resp != nil {
+			// Traffic Ops returned an error for an object we don't have; pass the error on, don't cache it.
+			writeResponse(w, resp.StatusCode, resp.Header, resp.body)
+			return
+		}
+	}
+
+	if notModified(r, e.header) {
+		writeResponse(w, http.StatusNotModified, e.header, nil)
+		return
+	}
+	writeResponse(w, http.StatusOK, e.header, e.body)
+}
+
+// entry returns the cache entry of the key, creating it if it doesn't exist.
+// Entries which haven't been requested for the ExpireTime are removed when a new one is created, so the cache only grows with the objects still requested.
+func (p *Proxy) entry(key string) *entry {
+	p.entriesM.Lock()
+	defer p.entriesM.Unlock()
+	e, ok := p.entries[key]
+	if !ok {
+		for k, old := range p.entries {
+			if time.Since(old.requested) >= p.cfg.ExpireTime {
+				delete(p.entries, k)
+			}
+		}
+		e = &entry{m: &sync.Mutex{}}
+		p.entries[key] = e
+	}
+	e.requested = time.Now()
+	return e
+}
+
+// uncachedResponse is a Traffic Ops response which isn't cached.
+type uncachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	body       []byte
+}
+
+// refresh requests the object at u from Traffic Ops, conditionally if e already has it, and updates e.
+// Returns a non-nil uncachedResponse if e has no object and Traffic Ops returned an unsuccessful response.
+// Must be called with e.m held.
+func (p *Proxy) refresh(u *url.URL, e *entry) (*uncachedResponse, error) {
+	toURL := *p.cfg.TOURL
+	toURL.Path = strings.TrimSuffix(p.cfg.TOURL.Path, "/") + u.Path
+	toURL.RawQuery = u.RawQuery
+
+	reqHdr := http.Header{}
+	if e.body != nil {
+		if lm := e.header.Get(rfc.LastModified); lm != "" {
+			reqHdr.Set(rfc.IfModifiedSince, lm)
+		}
+		if etag := e.header.Get(rfc.ETagHeader); etag != "" {
+			reqHdr.Set(ifNoneMatch, etag)
+		}
+	}
+
+	code, respHdr, body, err := p.get(toURL.String(), reqHdr)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case code == http.StatusNotModified && e.body != nil:
+		log.Infof("cache proxy: '%s' not modified\n", u.RequestURI())
+	case code == http.StatusOK:
+		log.Infof("cache proxy: '%s' modified, caching new object\n", u.RequestURI())
+		e.body = body
+		e.header = http.Header{}
+		for _, name := range cachedHeaders {
+			if val := respHdr.Get(name); val != "" {
+				e.header.Set(name, val)
+			}
+		}
+	case e.body != nil:
+		return nil, errors.New("Traffic Ops returned " + http.StatusText(code))
+	default:
+		return &uncachedResponse{StatusCode: code, Header: respHdr, body: body}, nil
+	}
+	e.fetched = time.Now()
+	return nil, nil
+}
+
+// get requests the url from Traffic Ops with the proxy's session, logging in again if the session expired.
+func (p *Proxy) get(toURL string, reqHdr http.Header) (int, http.Header, []byte, error) {
+	for attempt := 0; ; attempt++ {
+		client, err := p.getClient(attempt > 0)
+		if err != nil {
+			return 0, nil, nil, errors.New("logging in to Traffic Ops: " + err.Error())
+		}
+		req, err := http.NewRequest(http.MethodGet, toURL, nil)
+		if err != nil {
+			return 0, nil, nil, errors.New("creating request: " + err.Error())
+		}
+		for name, vals := range reqHdr {
+			req.Header[name] = vals
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, nil, nil, errors.New("requesting Traffic Ops: " + err.Error())
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return 0, nil, nil, errors.New("reading Traffic Ops response: " + err.Error())
+		}
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			log.Infoln("cache proxy: Traffic Ops session expired, logging in again")
+			continue
+		}
+		return resp.StatusCode, resp.Header, body, nil
+	}
+}
+
+// getClient returns the proxy's logged-in client, logging in if there isn't one or relogin is true.
+func (p *Proxy) getClient(relogin bool) (*http.Client, error) {
+	p.clientM.Lock()
+	defer p.clientM.Unlock()
+	if p.client == nil || relogin {
+		client, err := p.cfg.Login()
+		if err != nil {
+			return nil, err
+		}
+		p.client = client
+	}
+	return p.client, nil
+}
+
+// authorize checks that the request has credentials Traffic Ops accepts, so cached objects are only served to clients who could get them from Traffic Ops.
+// Credentials are checked with Traffic Ops the first time they're seen, and then trusted for the AuthCacheTime.
+// Returns the identity of the client's user, or the status code to respond with and an error if the request isn't authorized.
+func (p *Proxy) authorize(r *http.Request) (identity, int, error) {
+	cookie := r.Header.Get(rfc.Cookie)
+	auth := r.Header.Get(rfc.Authorization)
+	if cookie == "" && auth == "" {
+		return identity{}, http.StatusUnauthorized, errors.New("Unauthorized, please log in.")
+	}
+	key := sha256.Sum256([]byte(cookie + "\n" + auth))
+
+	p.authsM.Lock()
+	authorized, ok := p.auths[key]
+	p.authsM.Unlock()
+	if ok && time.Since(authorized.time) < p.cfg.AuthCacheTime {
+		return authorized.identity, http.StatusOK, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, p.userCurrentURL(r.URL.Path), nil)
+	if err != nil {
+		return identity{}, http.StatusInternalServerError, errors.New("creating authorization request: " + err.Error())
+	}
+	if cookie != "" {
+		req.Header.Set(rfc.Cookie, cookie)
+	}
+	if auth != "" {
+		req.Header.Set(rfc.Authorization, auth)
+	}
+	resp, err := p.cfg.Transport.RoundTrip(req)
+	if err != nil {
+		return identity{}, http.StatusBadGateway, errors.New("checking authorization with Traffic Ops: " + err.Error())
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return identity{}, http.StatusBadGateway, errors.New("checking authorization with Traffic Ops: reading response: " + err.Error())
+	}
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return identity{}, resp.StatusCode, errors.New(http.StatusText(resp.StatusCode))
+		}
+		return identity{}, http.StatusBadGateway, errors.New("checking authorization with Traffic Ops: Traffic Ops returned " + http.StatusText(resp.StatusCode))
+	}
+	id, err := parseIdentity(body)
+	if err != nil {
+		return identity{}, http.StatusBadGateway, errors.New("checking authorization with Traffic Ops: " + err.Error())
+	}
+
+	p.authsM.Lock()
+	defer p.authsM.Unlock()
+	for k, a := range p.auths {
+		if time.Since(a.time) >= p.cfg.AuthCacheTime {
+			delete(p.auths, k)
+		}
+	}
+	p.auths[key] = authorization{identity: id, time: time.Now()}
+	return id, http.StatusOK, nil
+}
+
+// selfIdentity returns the identity of the proxy's own Traffic Ops user, in the API version of the given request path.
+// It's requested from Traffic Ops the first time it's needed for each version, and then trusted for the AuthCacheTime, like client credentials.
+func (p *Proxy) selfIdentity(path string) (identity, error) {
+	version := apiVersion.FindString(path)
+	p.identitiesM.Lock()
+	self, ok := p.identities[version]
+	p.identitiesM.Unlock()
+	if ok && time.Since(self.time) < p.cfg.AuthCacheTime {
+		return self.identity, nil
+	}
+
+	code, _, body, err := p.get(p.userCurrentURL(path), nil)
+	if err != nil {
+		return identity{}, err
+	}
+	if code != http.StatusOK {
+		return identity{}, errors.New("Traffic Ops returned " + http.StatusText(code))
+	}
+	id, err := parseIdentity(body)
+	if err != nil {
+		return identity{}, err
+	}
+
+	p.identitiesM.Lock()
+	defer p.identitiesM.Unlock()
+	p.identities[version] = authorization{identity: id, time: time.Now()}
+	return id, nil
+}
+
+// userCurrentURL returns the Traffic Ops URL of the current user, in the API version of the given request path.
+func (p *Proxy) userCurrentURL(path string) string {
+	toURL := *p.cfg.TOURL
+	toURL.Path = strings.TrimSuffix(p.cfg.TOURL.Path, "/") + apiVersion.FindString(path) + "user/current"
+	toURL.RawQuery = ""
+	return toURL.String()
+}
+
+// parseIdentity parses the role and tenant of a Traffic Ops user/current response.
+func parseIdentity(body []byte) (identity, error) {
+	resp := struct {
+		Response struct {
+			Role     json.RawMessage `json:"role"`
+			RoleName *string         `json:"roleName"`
+			TenantID json.RawMessage `json:"tenantId"`
+		} `json:"response"`
+	}{}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return identity{}, errors.New("decoding current user: " + err.Error())
+	}
+	role := string(resp.Response.Role)
+	if resp.Response.RoleName != nil {
+		role += " " + *resp.Response.RoleName // older API versions have the role's ID and name
+	}
+	return identity{role: role, tenant: string(resp.Response.TenantID)}, nil
+}
+
+// notModified returns whether the request's conditional headers match the cached object's header.
+func notModified(r *http.Request, hdr http.Header) bool {
+	if inm := r.Header.Get(ifNoneMatch); inm != "" {
+		return inm == hdr.Get(rfc.ETagHeader)
+	}
+	ims, ok := rfc.ParseHTTPDate(r.Header.Get(rfc.IfModifiedSince))
+	if !ok {
+		return false
+	}
+	lm, ok := rfc.ParseHTTPDate(hdr.Get(rfc.LastModified))
+	if !ok {
+		return false
+	}
+	return !lm.After(ims)
+}
+
+func writeResponse(w http.ResponseWriter, code int, hdr http.Header, body []byte) {
+	for _, name := range cachedHeaders {
+		if val := hdr.Get(name); val != "" {
+			w.Header().Set(name, val)
+		}
+	}
+	w.WriteHeader(code)
+	if body != nil {
+		w.Write(body)
+	}
+}
+
+// writeAlert writes a Traffic Ops API style error alert, so clients log it like a Traffic Ops error.
+func writeAlert(w http.ResponseWriter, code int, msg string) {
+	bts, err := json.Marshal(tc.CreateErrorAlerts(errors.New(msg)))
+	if err != nil {
+		log.Errorln("cache proxy: marshalling alerts: " + err.Error())
+	}
+	w.Header().Set(rfc.ContentType, rfc.ApplicationJSON)
+	w.WriteHeader(code)
+	w.Write(bts)
+}
@@ -0,0 +1,302 @@
+package cacheproxy
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-rfc"
+)
+
+// fakeTO is a minimal Traffic Ops serving a single cacheable endpoint.
+type fakeTO struct {
+	m            *sync.Mutex
+	sessions     map[string]bool
+	logins       int
+	serverHits   int
+	serverFail   bool
+	lastModified time.Time
+	srv          *httptest.Server
+}
+
+func newFakeTO(t *testing.T) *fakeTO {
+	to := &fakeTO{
+		m:            &sync.Mutex{},
+		sessions:     map[string]bool{"client-session": true, "other-tenant-session": true},
+		lastModified: time.Now().Add(-time.Hour).Truncate(time.Second),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/5.0/user/current", func(w http.ResponseWriter, r *http.Request) {
+		if !to.authorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if cookie, _ := r.Cookie("mojolicious"); cookie.Value == "other-tenant-session" {
+			w.Write([]byte(`{"response":{"role":"admin","tenantId":2}}`))
+			return
+		}
+		w.Write([]byte(`{"response":{"role":"admin","tenantId":1}}`))
+	})
+	mux.HandleFunc("/api/5.0/servers", func(w http.ResponseWriter, r *http.Request) {
+		if !to.authorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		to.m.Lock()
+		defer to.m.Unlock()
+		to.serverHits++
+		if to.serverFail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if ims, ok := rfc.ParseHTTPDate(r.Header.Get(rfc.IfModifiedSince)); ok && !to.lastModified.After(ims) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set(rfc.LastModified, to.lastModified.Format(rfc.LastModifiedFormat))
+		w.Write([]byte(`{"response":[{"hostName":"edge"}]}`))
+	})
+	mux.HandleFunc("/api/5.0/servers/edge/update_status", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`passthrough`))
+	})
+	to.srv = httptest.NewServer(mux)
+	t.Cleanup(to.srv.Close)
+	return to
+}
+
+func (to *fakeTO) authorized(r *http.Request) bool {
+	cookie, err := r.Cookie("mojolicious")
+	if err != nil {
+		return false
+	}
+	to.m.Lock()
+	defer to.m.Unlock()
+	return to.sessions[cookie.Value]
+}
+
+// login creates a new proxy session, as the Proxy Config.Login.
+func (to *fakeTO) login() (*http.Client, error) {
+	to.m.Lock()
+	to.logins++
+	session := "proxy-session-" + strconv.Itoa(to.logins)
+	to.sessions[session] = true
+	to.m.Unlock()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	u, _ := url.Parse(to.srv.URL)
+	jar.SetCookies(u, []*http.Cookie{{Name: "mojolicious", Value: session}})
+	return &http.Client{Jar: jar}, nil
+}
+
+func newTestProxy(t *testing.T, to *fakeTO, refresh time.Duration) *httptest.Server {
+	toURL, err := url.Parse(to.srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := New(Config{
+		TOURL:           toURL,
+		Transport:       http.DefaultTransport,
+		Login:           to.login,
+		RefreshInterval: refresh,
+		AuthCacheTime:   time.Minute,
+		ExpireTime:      time.Hour,
+	})
+	srv := httptest.NewServer(proxy)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(t *testing.T, u string, session string, hdr http.Header) (int, string) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, vals := range hdr {
+		req.Header[name] = vals
+	}
+	if session != "" {
+		req.AddCookie(&http.Cookie{Name: "mojolicious", Value: session})
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestProxyCaches(t *testing.T) {
+	to := newFakeTO(t)
+	proxy := newTestProxy(t, to, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		code, body := get(t, proxy.URL+"/api/5.0/servers", "client-session", nil)
+		if code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, actual %d", i, code)
+		}
+		if body != `{"response":[{"hostName":"edge"}]}` {
+			t.Errorf("request %d: unexpected body '%s'", i, body)
+		}
+	}
+	if to.serverHits != 1 {
+		t.Errorf("expected Traffic Ops to be requested once, actual %d", to.serverHits)
+	}
+
+	ims := http.Header{rfc.IfModifiedSince: {to.lastModified.Add(time.Second).Format(rfc.LastModifiedFormat)}}
+	if code, _ := get(t, proxy.URL+"/api/5.0/servers", "client-session", ims); code != http.StatusNotModified {
+		t.Errorf("If-Modified-Since after Last-Modified: expected 304, actual %d", code)
+	}
+	ims = http.Header{rfc.IfModifiedSince: {to.lastModified.Add(-time.Second).Format(rfc.LastModifiedFormat)}}
+	if code, _ := get(t, proxy.URL+"/api/5.0/servers", "client-session", ims); code != http.StatusOK {
+		t.Errorf("If-Modified-Since before Last-Modified: expected 200, actual %d", code)
+	}
+	if to.serverHits != 1 {
+		t.Errorf("expected Traffic Ops to be requested once, actual %d", to.serverHits)
+	}
+
+	// a different query is a different object
+	get(t, proxy.URL+"/api/5.0/servers?hostName=edge", "client-session", nil)
+	if to.serverHits != 2 {
+		t.Errorf("expected Traffic Ops to be requested for a new query, actual requests %d", to.serverHits)
+	}
+}
+
+func TestProxyRevalidates(t *testing.T) {
+	to := newFakeTO(t)
+	proxy := newTestProxy(t, to, 0)
+
+	get(t, proxy.URL+"/api/5.0/servers", "client-session", nil)
+	code, body := get(t, proxy.URL+"/api/5.0/servers", "client-session", nil)
+	if code != http.StatusOK || body != `{"response":[{"hostName":"edge"}]}` {
+		t.Errorf("revalidated object: expected 200 with the cached body, actual %d '%s'", code, body)
+	}
+	if to.serverHits != 2 {
+		t.Errorf("expected Traffic Ops to be requested twice, actual %d", to.serverHits)
+	}
+
+	to.m.Lock()
+	to.serverFail = true
+	to.m.Unlock()
+	code, body = get(t, proxy.URL+"/api/5.0/servers", "client-session", nil)
+	if code != http.StatusOK || body != `{"response":[{"hostName":"edge"}]}` {
+		t.Errorf("Traffic Ops failure: expected stale object, actual %d '%s'", code, body)
+	}
+
+	code, _ = get(t, proxy.URL+"/api/5.0/servers?hostName=edge", "client-session", nil)
+	if code != http.StatusInternalServerError {
+		t.Errorf("Traffic Ops failure with no cached object: expected Traffic Ops error 500, actual %d", code)
+	}
+}
+
+func TestProxyAuthorization(t *testing.T) {
+	to := newFakeTO(t)
+	proxy := newTestProxy(t, to, time.Hour)
+
+	if code, _ := get(t, proxy.URL+"/api/5.0/servers", "", nil); code != http.StatusUnauthorized {
+		t.Errorf("no credentials: expected 401, actual %d", code)
+	}
+	if code, _ := get(t, proxy.URL+"/api/5.0/servers", "bad-session", nil); code != http.StatusUnauthorized {
+		t.Errorf("bad credentials: expected 401, actual %d", code)
+	}
+	if to.serverHits != 0 {
+		t.Errorf("expected unauthorized requests not to request Traffic Ops, actual %d requests", to.serverHits)
+	}
+}
+
+func TestProxyOtherTenant(t *testing.T) {
+	to := newFakeTO(t)
+	proxy := newTestProxy(t, to, time.Hour)
+
+	get(t, proxy.URL+"/api/5.0/servers", "client-session", nil)
+	for i := 0; i < 2; i++ {
+		if code, _ := get(t, proxy.URL+"/api/5.0/servers", "other-tenant-session", nil); code != http.StatusOK {
+			t.Errorf("other tenant request %d: expected 200, actual %d", i, code)
+		}
+	}
+	if to.serverHits != 3 {
+		t.Errorf("expected requests from another tenant than the proxy's to be passed through to Traffic Ops, actual requests %d", to.serverHits)
+	}
+}
+
+func TestProxyRelogin(t *testing.T) {
+	to := newFakeTO(t)
+	proxy := newTestProxy(t, to, 0)
+
+	get(t, proxy.URL+"/api/5.0/servers", "client-session", nil)
+	to.m.Lock()
+	to.sessions["proxy-session-1"] = false
+	to.m.Unlock()
+
+	if code, _ := get(t, proxy.URL+"/api/5.0/servers", "client-session", nil); code != http.StatusOK {
+		t.Errorf("expired proxy session: expected 200, actual %d", code)
+	}
+	if to.logins != 2 {
+		t.Errorf("expired proxy session: expected proxy to log in again, actual logins %d", to.logins)
+	}
+}
+
+func TestProxyPassthrough(t *testing.T) {
+	to := newFakeTO(t)
+	proxy := newTestProxy(t, to, time.Hour)
+
+	code, body := get(t, proxy.URL+"/api/5.0/servers/edge/update_status", "", nil)
+	if code != http.StatusOK || body != "passthrough" {
+		t.Errorf("uncacheable path: expected to be passed through, actual %d '%s'", code, body)
+	}
+	if to.logins != 0 {
+		t.Errorf("uncacheable path: expected proxy not to log in, actual logins %d", to.logins)
+	}
+}
+
+func TestProxyExpires(t *testing.T) {
+	p := New(Config{TOURL: &url.URL{}, ExpireTime: time.Minute})
+
+	requested := p.entry("/api/4.0/servers?hostName=cache-0")
+	expired := p.entry("/api/4.0/servers?hostName=cache-1")
+	p.entriesM.Lock()
+	expired.requested = time.Now().Add(-time.Hour)
+	p.entriesM.Unlock()
+
+	p.entry("/api/4.0/servers?hostName=cache-2")
+	if _, ok := p.entries["/api/4.0/servers?hostName=cache-1"]; ok {
+		t.Errorf("expected entry not requested for longer than the expire time to be removed")
+	}
+	if p.entry("/api/4.0/servers?hostName=cache-0") != requested {
+		t.Errorf("expected entry requested within the expire time to be kept")
+	}
+	if len(p.entries) != 2 {
+		t.Errorf("expected 2 entries, actual %d", len(p.entries))
+	}
+}
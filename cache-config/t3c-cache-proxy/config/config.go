@@ -0,0 +1,208 @@
+package config
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/pborman/getopt/v2"
+)
+
+const AppName = "t3c-cache-proxy"
+
+type Cfg struct {
+	LogLocationDebug string
+	LogLocationWarn  string
+	LogLocationError string
+	LogLocationInfo  string
+
+	// Listen is the address to serve on, in the form host:port.
+	Listen string
+	// TLSCert and TLSKey are the paths of the certificate and key to serve HTTPS with. If empty, HTTP is served.
+	TLSCert string
+	TLSKey  string
+	// RefreshInterval is how long a cached object is served before it is revalidated with Traffic Ops.
+	RefreshInterval time.Duration
+	// AuthCacheTime is how long client credentials are trusted after Traffic Ops accepted them.
+	AuthCacheTime time.Duration
+	// ExpireTime is how long a cached object is kept after it was last requested.
+	ExpireTime time.Duration
+
+	TOInsecure  bool
+	TOTimeoutMS time.Duration
+	TOUser      string
+	TOPass      string
+	TOURL       *url.URL
+
+	Version     string
+	GitRevision string
+}
+
+func (cfg Cfg) AppVersion() string { return t3cutil.VersionStr(AppName, cfg.Version, cfg.GitRevision) }
+func (cfg Cfg) UserAgent() string  { return t3cutil.UserAgentStr(AppName, cfg.Version, cfg.GitRevision) }
+
+func (cfg Cfg) DebugLog() log.LogLocation   { return log.LogLocation(cfg.LogLocationDebug) }
+func (cfg Cfg) ErrorLog() log.LogLocation   { return log.LogLocation(cfg.LogLocationError) }
+func (cfg Cfg) InfoLog() log.LogLocation    { return log.LogLocation(cfg.LogLocationInfo) }
+func (cfg Cfg) WarningLog() log.LogLocation { return log.LogLocation(cfg.LogLocationWarn) }
+func (cfg Cfg) EventLog() log.LogLocation   { return log.LogLocation(log.LogLocationNull) } // event logging is not used.
+
+// Usage() writes command line options and usage to 'stderr'
+func Usage() {
+	getopt.PrintUsage(os.Stderr)
+	os.Exit(0)
+}
+
+// InitConfig() intializes the configuration variables and loggers.
+func InitConfig(appVersion string, gitRevision string) (Cfg, error) {
+	listenPtr := getopt.StringLong("listen", 'l', ":8443", "Address to serve on, in the form host:port")
+	tlsCertPtr := getopt.StringLong("tls-cert", 0, "", "Path of the certificate to serve HTTPS with. If omitted, HTTP is served. Requires --tls-key")
+	tlsKeyPtr := getopt.StringLong("tls-key", 0, "", "Path of the private key to serve HTTPS with. Requires --tls-cert")
+	refreshMSPtr := getopt.IntLong("refresh-interval-ms", 'r', 60000, "Milliseconds to serve a cached object before revalidating it with Traffic Ops, default is 60000")
+	authCacheMSPtr := getopt.IntLong("auth-cache-ms", 'a', 300000, "Milliseconds to trust client credentials after Traffic Ops accepted them, default is 300000")
+	expireMSPtr := getopt.IntLong("expire-ms", 0, 3600000, "Milliseconds to keep a cached object after it was last requested, default is 3600000")
+	toInsecurePtr := getopt.BoolLong("traffic-ops-insecure", 'I', "[true | false] ignore certificate errors from Traffic Ops")
+	toTimeoutMSPtr := getopt.IntLong("traffic-ops-timeout-milliseconds", 't', 30000, "Timeout in milli-seconds for Traffic Ops requests, default is 30000")
+	toURLPtr := getopt.StringLong("traffic-ops-url", 'u', "", "Traffic Ops URL. Must be the full URL, including the scheme. Required. May also be set with the environment variable TO_URL")
+	toUserPtr := getopt.StringLong("traffic-ops-user", 'U', "", "Traffic Ops username. Required. May also be set with the environment variable TO_USER")
+	toPassPtr := getopt.StringLong("traffic-ops-password", 'P', "", "Traffic Ops password. Required. May also be set with the environment variable TO_PASS")
+	helpPtr := getopt.BoolLong("help", 'h', "Print usage information and exit")
+	versionPtr := getopt.BoolLong("version", 'V', "Print the app version")
+	verbosePtr := getopt.CounterLong("verbose", 'v', `Log verbosity. Logging is output to stderr. By default, errors are logged. To log warnings, pass '-v'. To log info, pass '-vv'. To omit error logging, see '-s'`)
+	silentPtr := getopt.BoolLong("silent", 's', `Silent. Errors are not logged, and the 'verbose' flag is ignored. If a fatal error occurs, the return code will be non-zero but no text will be output to stderr`)
+
+	getopt.Parse()
+
+	if *helpPtr == true {
+		Usage()
+	} else if *versionPtr == true {
+		cfg := &Cfg{Version: appVersion, GitRevision: gitRevision}
+		fmt.Println(cfg.AppVersion())
+		os.Exit(0)
+	}
+
+	logLocationError := log.LogLocationStderr
+	logLocationWarn := log.LogLocationNull
+	logLocationInfo := log.LogLocationNull
+	logLocationDebug := log.LogLocationNull
+	if *silentPtr {
+		logLocationError = log.LogLocationNull
+	} else {
+		if *verbosePtr >= 1 {
+			logLocationWarn = log.LogLocationStderr
+		}
+		if *verbosePtr >= 2 {
+			logLocationInfo = log.LogLocationStderr
+			logLocationDebug = log.LogLocationStderr // t3c only has 3 verbosity options: none (-s), error (default or --verbose=0), warning (-v), and info (-vv). Any code calling log.Debug is treated as Info.
+		}
+	}
+
+	if *verbosePtr > 2 {
+		return Cfg{}, errors.New("Too many verbose options. The maximum log verbosity level is 2 (-vv or --verbose=2) for errors (0), warnings (1), and info (2)")
+	}
+
+	if (*tlsCertPtr == "") != (*tlsKeyPtr == "") {
+		return Cfg{}, errors.New("--tls-cert and --tls-key must be used together")
+	}
+	if *refreshMSPtr < 0 {
+		return Cfg{}, errors.New("--refresh-interval-ms must not be negative")
+	}
+	if *authCacheMSPtr < 0 {
+		return Cfg{}, errors.New("--auth-cache-ms must not be negative")
+	}
+	if *expireMSPtr < 0 {
+		return Cfg{}, errors.New("--expire-ms must not be negative")
+	}
+
+	toURL := *toURLPtr
+	toUser := *toUserPtr
+	toPass := *toPassPtr
+
+	urlSourceStr := "argument" // for error messages
+	if toURL == "" {
+		urlSourceStr = "environment variable"
+		toURL = os.Getenv("TO_URL")
+	}
+	if toUser == "" {
+		toUser = os.Getenv("TO_USER")
+	}
+	if toPass == "" {
+		toPass = os.Getenv("TO_PASS")
+	}
+	if toUser == "" || toPass == "" {
+		return Cfg{}, errors.New("Traffic Ops user and password are required")
+	}
+
+	toURLParsed, err := url.Parse(toURL)
+	if err != nil {
+		return Cfg{}, errors.New("parsing Traffic Ops URL from " + urlSourceStr + " '" + toURL + "': " + err.Error())
+	} else if err := t3cutil.ValidateURL(toURLParsed); err != nil {
+		return Cfg{}, errors.New("invalid Traffic Ops URL from " + urlSourceStr + " '" + toURL + "': " + err.Error())
+	}
+
+	cfg := Cfg{
+		LogLocationDebug: logLocationDebug,
+		LogLocationError: logLocationError,
+		LogLocationInfo:  logLocationInfo,
+		LogLocationWarn:  logLocationWarn,
+		Listen:           *listenPtr,
+		TLSCert:          *tlsCertPtr,
+		TLSKey:           *tlsKeyPtr,
+		RefreshInterval:  time.Millisecond * time.Duration(*refreshMSPtr),
+		AuthCacheTime:    time.Millisecond * time.Duration(*authCacheMSPtr),
+		ExpireTime:       time.Millisecond * time.Duration(*expireMSPtr),
+		TOInsecure:       *toInsecurePtr,
+		TOTimeoutMS:      time.Millisecond * time.Duration(*toTimeoutMSPtr),
+		TOUser:           toUser,
+		TOPass:           toPass,
+		TOURL:            toURLParsed,
+		Version:          appVersion,
+		GitRevision:      gitRevision,
+	}
+
+	if err := log.InitCfg(cfg); err != nil {
+		return Cfg{}, errors.New("initializing loggers: " + err.Error())
+	}
+
+	return cfg, nil
+}
+
+func (cfg Cfg) PrintConfig() {
+	log.Debugf("LogLocationDebug: %s\n", cfg.LogLocationDebug)
+	log.Debugf("LogLocationError: %s\n", cfg.LogLocationError)
+	log.Debugf("LogLocationInfo: %s\n", cfg.LogLocationInfo)
+	log.Debugf("LogLocationWarn: %s\n", cfg.LogLocationWarn)
+	log.Debugf("Listen: %s\n", cfg.Listen)
+	log.Debugf("TLSCert: %s\n", cfg.TLSCert)
+	log.Debugf("RefreshInterval: %s\n", cfg.RefreshInterval)
+	log.Debugf("AuthCacheTime: %s\n", cfg.AuthCacheTime)
+	log.Debugf("ExpireTime: %s\n", cfg.ExpireTime)
+	log.Debugf("TOInsecure: %v\n", cfg.TOInsecure)
+	log.Debugf("TOTimeoutMS: %s\n", cfg.TOTimeoutMS)
+	log.Debugf("TOUser: %s\n", cfg.TOUser)
+	log.Debugf("TOPass: xxxxxx\n")
+	log.Debugf("TOURL: %s\n", cfg.TOURL)
+}
@@ -0,0 +1,105 @@
+package main
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3c-cache-proxy/cacheproxy"
+	"github.com/apache/trafficcontrol/v8/cache-config/t3c-cache-proxy/config"
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil/toreq"
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil/toreq/torequtil"
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+)
+
+// Version is the application version.
+// This is overwritten by the build with the current project version.
+var Version = "0.4"
+
+// GitRevision is the git revision the application was built from.
+// This is overwritten by the build with the current project version.
+var GitRevision = "nogit"
+
+func main() {
+	cfg, err := config.InitConfig(Version, GitRevision)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		os.Exit(1)
+	}
+	log.Infoln("configuration initialized")
+	cfg.PrintConfig()
+
+	// Log in before serving, so bad credentials or an unreachable Traffic Ops fail at startup.
+	toClient, err := login(cfg)
+	if err != nil {
+		log.Errorf("%s\n", err)
+		os.Exit(2)
+	}
+	if toClient.FellBack() {
+		log.Warnln("Traffic Ops does not support the latest version supported by this app! Falling back to previous major Traffic Ops API version!")
+	}
+
+	startupSessionUsed := int32(0) // accessed atomically, because Login may be called concurrently
+	proxy := cacheproxy.New(cacheproxy.Config{
+		TOURL:     cfg.TOURL,
+		Transport: toClient.HTTPClient().Transport,
+		Login: func() (*http.Client, error) {
+			if atomic.CompareAndSwapInt32(&startupSessionUsed, 0, 1) {
+				return toClient.HTTPClient(), nil // only the first call may use the startup session.
+			}
+			newClient, err := login(cfg)
+			if err != nil {
+				return nil, err
+			}
+			return newClient.HTTPClient(), nil
+		},
+		RefreshInterval: cfg.RefreshInterval,
+		AuthCacheTime:   cfg.AuthCacheTime,
+		ExpireTime:      cfg.ExpireTime,
+	})
+
+	svr := &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           proxy,
+		ReadHeaderTimeout: cfg.TOTimeoutMS,
+		IdleTimeout:       time.Minute,
+	}
+	log.Infoln("serving on " + cfg.Listen)
+	if cfg.TLSCert != "" {
+		err = svr.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey)
+	} else {
+		err = svr.ListenAndServe()
+	}
+	log.Errorf("serving: %s\n", err)
+	os.Exit(3)
+}
+
+// login logs in to Traffic Ops with the password.
+// The cached cookie isn't used, because the proxy only logs in again when its session expired.
+func login(cfg config.Cfg) (*toreq.TOClient, error) {
+	if err := os.Remove(torequtil.CookieCachePath(cfg.TOUser)); err != nil && !os.IsNotExist(err) {
+		log.Warnf("removing cached cookie: %s\n", err)
+	}
+	return toreq.New(cfg.TOURL, cfg.TOUser, cfg.TOPass, cfg.TOInsecure, cfg.TOTimeoutMS, cfg.UserAgent())
+}
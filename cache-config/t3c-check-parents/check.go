@@ -0,0 +1,435 @@
+package main
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+// StrategyNamePrefix is the prefix of the names of the strategies t3c-generate creates for Delivery Services,
+// followed by the Delivery Service's XMLID.
+const StrategyNamePrefix = "strategy-"
+
+// Inconsistency is a difference between the parent.config and strategies.yaml parent selection of a remap rule,
+// or between either and the Topology of the rule's Delivery Service.
+type Inconsistency struct {
+	// Rule is the type and from-URL of the remap rule.
+	Rule string
+	// DS is the XMLID of the rule's Delivery Service, or empty if it isn't known.
+	DS  string
+	Msg string
+}
+
+func (in Inconsistency) String() string {
+	str := "remap '" + in.Rule + "'"
+	if in.DS != "" {
+		str += " ds '" + in.DS + "'"
+	}
+	return str + ": " + in.Msg
+}
+
+// checkRules returns the inconsistencies in the parent selection of every rule.
+// If data is nil, rules which use parent.config can't be matched to their strategies, and Topologies aren't checked.
+func checkRules(rules []remapRule, parentLines []parentDotConfigLine, strategies map[string]Selection, data *t3cutil.ConfigData) []Inconsistency {
+	inconsistencies := []Inconsistency{}
+	for _, rule := range rules {
+		add := func(ds string, msg string) {
+			inconsistencies = append(inconsistencies, Inconsistency{Rule: rule.Line, DS: ds, Msg: msg})
+		}
+
+		pl, ok := matchParentDotConfig(parentLines, rule.TargetHost, rule.TargetPort)
+		parentSel := &pl.Selection
+		if !ok {
+			add("", fmt.Sprintf("%s has no line for the target '%s:%d'", ParentDotConfigFileName, rule.TargetHost, rule.TargetPort))
+			parentSel = nil
+		}
+
+		dses := ruleDeliveryServices(rule, data)
+		if len(dses) == 0 {
+			if rule.Strategy == "" {
+				log.Infof("remap '%s' uses %s and its Delivery Service isn't known, not comparing it to a strategy\n", rule.Line, ParentDotConfigFileName)
+				continue
+			}
+			st, ok := strategies[rule.Strategy]
+			if !ok {
+				add("", fmt.Sprintf("uses strategy '%s', which %s doesn't define", rule.Strategy, StrategiesFileName))
+				continue
+			}
+			if parentSel != nil {
+				for _, msg := range compareSelections(*parentSel, st) {
+					add("", msg)
+				}
+			}
+			continue
+		}
+
+		for _, ds := range dses {
+			strategyName := rule.Strategy
+			if strategyName == "" {
+				strategyName = StrategyNamePrefix + ds.XMLID
+			}
+			st, ok := strategies[strategyName]
+			if !ok {
+				if rule.Strategy != "" {
+					add(ds.XMLID, fmt.Sprintf("uses strategy '%s', which %s doesn't define", rule.Strategy, StrategiesFileName))
+				} else {
+					add(ds.XMLID, fmt.Sprintf("%s has no strategy '%s' for the Delivery Service", StrategiesFileName, strategyName))
+				}
+			}
+			if ok && parentSel != nil {
+				for _, msg := range compareSelections(*parentSel, st) {
+					add(ds.XMLID, msg)
+				}
+			}
+
+			sels := []Selection{}
+			if parentSel != nil {
+				sels = append(sels, *parentSel)
+			}
+			if ok {
+				sels = append(sels, st)
+			}
+			for _, msg := range checkTopology(ds, sels, data) {
+				add(ds.XMLID, msg)
+			}
+		}
+	}
+	return inconsistencies
+}
+
+// ruleDeliveryServices returns the Delivery Services of the rule: the Delivery Service of its strategy if it has one,
+// else the Delivery Services with its target as their origin, preferring those assigned to the server.
+// Returns nil if data is nil, or no Delivery Service matches.
+func ruleDeliveryServices(rule remapRule, data *t3cutil.ConfigData) []atscfg.DeliveryService {
+	if data == nil {
+		return nil
+	}
+	if strings.HasPrefix(rule.Strategy, StrategyNamePrefix) {
+		xmlID := strings.TrimPrefix(rule.Strategy, StrategyNamePrefix)
+		for _, ds := range data.DeliveryServices {
+			if ds.XMLID == xmlID {
+				return []atscfg.DeliveryService{ds}
+			}
+		}
+	}
+
+	dses := []atscfg.DeliveryService{}
+	for _, ds := range data.DeliveryServices {
+		if ds.OrgServerFQDN == nil {
+			continue
+		}
+		host, port, ok := originHostPort(*ds.OrgServerFQDN)
+		if ok && host == rule.TargetHost && port == rule.TargetPort {
+			dses = append(dses, ds)
+		}
+	}
+	if len(dses) < 2 {
+		return dses
+	}
+	assigned := []atscfg.DeliveryService{}
+	for _, ds := range dses {
+		if dsAssigned(ds, data) {
+			assigned = append(assigned, ds)
+		}
+	}
+	if len(assigned) == 0 {
+		return dses
+	}
+	return assigned
+}
+
+// originHostPort returns the host and port of a Delivery Service origin URL, and whether it was valid.
+func originHostPort(origin string) (string, int, bool) {
+	originURL, err := url.Parse(origin)
+	if err != nil || originURL.Hostname() == "" {
+		return "", 0, false
+	}
+	port, err := strconv.Atoi(originURL.Port())
+	if err != nil {
+		port = 80
+		if strings.ToLower(originURL.Scheme) == "https" {
+			port = 443
+		}
+	}
+	return strings.ToLower(originURL.Hostname()), port, true
+}
+
+// dsAssigned returns whether the Delivery Service is assigned to the server, either by its Topology or directly.
+func dsAssigned(ds atscfg.DeliveryService, data *t3cutil.ConfigData) bool {
+	if data.Server == nil {
+		return false
+	}
+	if ds.Topology != nil {
+		topo, ok := findTopology(*ds.Topology, data.Topologies)
+		if !ok {
+			return false
+		}
+		_, ok = topologyNode(topo, data.Server.CacheGroup)
+		return ok
+	}
+	if ds.ID == nil {
+		return false
+	}
+	for _, dss := range data.DeliveryServiceServers {
+		if dss.DeliveryService == *ds.ID && dss.Server == data.Server.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func findTopology(name string, topologies []tc.TopologyV5) (tc.TopologyV5, bool) {
+	for _, topo := range topologies {
+		if topo.Name == name {
+			return topo, true
+		}
+	}
+	return tc.TopologyV5{}, false
+}
+
+// topologyNode returns the node of the Cache Group in the Topology, and whether it exists.
+func topologyNode(topo tc.TopologyV5, cacheGroup string) (tc.TopologyNodeV5, bool) {
+	for _, node := range topo.Nodes {
+		if node.Cachegroup == cacheGroup {
+			return node, true
+		}
+	}
+	return tc.TopologyNodeV5{}, false
+}
+
+// compareSelections returns the differences between the parent.config and strategies.yaml selections.
+func compareSelections(pc Selection, st Selection) []string {
+	diffs := []string{}
+	differ := func(field string, pcVal string, stVal string) {
+		diffs = append(diffs, fmt.Sprintf("%s differs: %s '%s', %s strategy '%s' '%s'", field, pc.Source, pcVal, st.Source, st.Name, stVal))
+	}
+
+	if pc.Policy != st.Policy {
+		differ("policy", pc.Policy, st.Policy)
+	}
+	if pc.GoDirect != st.GoDirect {
+		differ("go_direct", strconv.FormatBool(pc.GoDirect), strconv.FormatBool(st.GoDirect))
+	}
+
+	if pc.Policy == PolicyConsistentHash && st.Policy == PolicyConsistentHash {
+		if pc.HashKey != st.HashKey {
+			differ("hash key", pc.HashKey, st.HashKey)
+		}
+		if !parentsEqual(sortedParents(pc.Parents), sortedParents(st.Parents), true) {
+			differ("parents", parentsStr(sortedParents(pc.Parents)), parentsStr(sortedParents(st.Parents)))
+		}
+		if !parentsEqual(sortedParents(pc.SecondaryParents), sortedParents(st.SecondaryParents), true) {
+			differ("secondary parents", parentsStr(sortedParents(pc.SecondaryParents)), parentsStr(sortedParents(st.SecondaryParents)))
+		}
+		if st.RingMode == RingModePeering {
+			diffs = append(diffs, fmt.Sprintf("%s strategy '%s' uses %s with %d peers, which %s can't represent", st.Source, st.Name, RingModePeering, len(st.Peers), pc.Source))
+		} else if len(pc.SecondaryParents) > 0 && len(st.SecondaryParents) > 0 && pc.RingMode != st.RingMode {
+			differ("secondary mode", pc.RingMode, st.RingMode)
+		}
+	} else {
+		// Without consistent hashing, parent.config has a single list of parents, tried in order.
+		pcParents := append(append([]Parent{}, pc.Parents...), pc.SecondaryParents...)
+		stParents := append(append([]Parent{}, st.Parents...), st.SecondaryParents...)
+		if !parentsEqual(pcParents, stParents, false) {
+			differ("parents", parentsStr(pcParents), parentsStr(stParents))
+		}
+	}
+
+	if pc.MaxSimpleRetries != nil && st.MaxSimpleRetries != nil && *pc.MaxSimpleRetries != *st.MaxSimpleRetries {
+		differ("max simple retries", strconv.Itoa(*pc.MaxSimpleRetries), strconv.Itoa(*st.MaxSimpleRetries))
+	}
+	if pc.SimpleRetryCodes != nil && st.SimpleRetryCodes != nil && !codesEqual(pc.SimpleRetryCodes, st.SimpleRetryCodes) {
+		differ("simple retry codes", codesStr(pc.SimpleRetryCodes), codesStr(st.SimpleRetryCodes))
+	}
+	if pc.MaxUnavailableRetries != nil && st.MaxUnavailableRetries != nil && *pc.MaxUnavailableRetries != *st.MaxUnavailableRetries {
+		differ("max unavailable retries", strconv.Itoa(*pc.MaxUnavailableRetries), strconv.Itoa(*st.MaxUnavailableRetries))
+	}
+	if pc.UnavailableRetryCodes != nil && st.UnavailableRetryCodes != nil && !codesEqual(pc.UnavailableRetryCodes, st.UnavailableRetryCodes) {
+		differ("unavailable retry codes", codesStr(pc.UnavailableRetryCodes), codesStr(st.UnavailableRetryCodes))
+	}
+	return diffs
+}
+
+// parentsEqual returns whether the parents have the same hosts and ports in the same order,
+// and if compareWeights the same weights, to the precision strategies.yaml is generated with.
+func parentsEqual(a []Parent, b []Parent, compareWeights bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Host != b[i].Host || a[i].Port != b[i].Port {
+			return false
+		}
+		if compareWeights && math.Round(a[i].Weight*1000) != math.Round(b[i].Weight*1000) {
+			return false
+		}
+	}
+	return true
+}
+
+func parentsStr(parents []Parent) string {
+	strs := []string{}
+	for _, pa := range parents {
+		strs = append(strs, pa.String())
+	}
+	return strings.Join(strs, ";")
+}
+
+func codesEqual(a []int, b []int) bool {
+	return codesStr(a) == codesStr(b)
+}
+
+// codesStr returns the codes, sorted and comma-delimited.
+func codesStr(codes []int) string {
+	sorted := append([]int{}, codes...)
+	sort.Ints(sorted)
+	strs := []string{}
+	for _, code := range sorted {
+		strs = append(strs, strconv.Itoa(code))
+	}
+	return strings.Join(strs, ",")
+}
+
+// checkTopology returns the inconsistencies between the parents of each selection and the Topology of the Delivery Service.
+//
+// Parents must be servers in the parent Cache Groups of the server's Cache Group, primary parents in the first and
+// secondary parents in the second, or the Delivery Service's origin if the server's Cache Group has no parents.
+// Every parent Cache Group with an available server must have a parent in each selection.
+//
+// Returns nil if data is nil or the Delivery Service has no Topology.
+func checkTopology(ds atscfg.DeliveryService, sels []Selection, data *t3cutil.ConfigData) []string {
+	if data == nil || data.Server == nil || ds.Topology == nil {
+		return nil
+	}
+	topo, ok := findTopology(*ds.Topology, data.Topologies)
+	if !ok {
+		return []string{"Topology '" + *ds.Topology + "' doesn't exist"}
+	}
+	node, ok := topologyNode(topo, data.Server.CacheGroup)
+	if !ok {
+		return []string{"the server's Cache Group '" + data.Server.CacheGroup + "' isn't in Topology '" + topo.Name + "'"}
+	}
+
+	if len(node.Parents) == 0 {
+		originHost, _, ok := originHostPort(util.CoalesceToDefault(ds.OrgServerFQDN))
+		if !ok {
+			return nil
+		}
+		msgs := []string{}
+		for _, sel := range sels {
+			for _, pa := range append(append([]Parent{}, sel.Parents...), sel.SecondaryParents...) {
+				if pa.Host != originHost {
+					msgs = append(msgs, fmt.Sprintf("%s parent '%s' isn't the origin '%s', but Cache Group '%s' has no parents in Topology '%s'", selectionStr(sel), pa.Host, originHost, node.Cachegroup, topo.Name))
+				}
+			}
+		}
+		return msgs
+	}
+
+	parentCGs := []string{}
+	for _, parentIdx := range node.Parents {
+		if parentIdx >= 0 && parentIdx < len(topo.Nodes) {
+			parentCGs = append(parentCGs, topo.Nodes[parentIdx].Cachegroup)
+		}
+	}
+	cgServers := make([]map[string]struct{}, len(parentCGs))
+	cgAvailable := make([]bool, len(parentCGs))
+	for i, cg := range parentCGs {
+		cgServers[i] = map[string]struct{}{}
+		for _, sv := range data.Servers {
+			if sv.CacheGroup != cg || sv.CDNID != data.Server.CDNID {
+				continue
+			}
+			cgServers[i][strings.ToLower(sv.HostName+"."+sv.DomainName)] = struct{}{}
+			if sv.Status == string(tc.CacheStatusReported) || sv.Status == string(tc.CacheStatusOnline) {
+				cgAvailable[i] = true
+			}
+		}
+	}
+
+	msgs := []string{}
+	for _, sel := range sels {
+		rings := [][]Parent{sel.Parents, sel.SecondaryParents}
+		if sel.Source == ParentDotConfigFileName && sel.Policy != PolicyConsistentHash {
+			// parent.config without consistent hashing merges the primary and secondary parents into one list.
+			rings = [][]Parent{append(append([]Parent{}, sel.Parents...), sel.SecondaryParents...)}
+		}
+		hasParentIn := make([]bool, len(parentCGs))
+		for ringIdx, ring := range rings {
+			for _, pa := range ring {
+				found := false
+				for cgIdx := range parentCGs {
+					if len(rings) > 1 && cgIdx != ringIdx {
+						continue
+					}
+					if _, ok := cgServers[cgIdx][pa.Host]; ok {
+						hasParentIn[cgIdx] = true
+						found = true
+					}
+				}
+				if !found {
+					msgs = append(msgs, fmt.Sprintf("%s %s '%s' isn't a server in %s", selectionStr(sel), ringName(ringIdx, len(rings)), pa.Host, parentCGsStr(parentCGs, ringIdx, len(rings), topo.Name)))
+				}
+			}
+		}
+		for cgIdx, cg := range parentCGs {
+			if cgAvailable[cgIdx] && !hasParentIn[cgIdx] {
+				msgs = append(msgs, fmt.Sprintf("%s has no parents in the Topology '%s' parent Cache Group '%s', which has available servers", selectionStr(sel), topo.Name, cg))
+			}
+		}
+	}
+	return msgs
+}
+
+func selectionStr(sel Selection) string {
+	if sel.Source == StrategiesFileName {
+		return sel.Source + " strategy '" + sel.Name + "'"
+	}
+	return sel.Source
+}
+
+func ringName(ringIdx int, numRings int) string {
+	if numRings > 1 && ringIdx > 0 {
+		return "secondary parent"
+	}
+	return "parent"
+}
+
+// parentCGsStr returns the description of the parent Cache Groups a ring's parents must be in.
+func parentCGsStr(parentCGs []string, ringIdx int, numRings int, topoName string) string {
+	if numRings > 1 {
+		if ringIdx >= len(parentCGs) {
+			return "a " + ringName(ringIdx, numRings) + " Cache Group, which Topology '" + topoName + "' doesn't have"
+		}
+		return "the Topology '" + topoName + "' " + ringName(ringIdx, numRings) + " Cache Group '" + parentCGs[ringIdx] + "'"
+	}
+	return "the Topology '" + topoName + "' parent Cache Groups '" + strings.Join(parentCGs, "', '") + "'"
+}
@@ -0,0 +1,135 @@
+package config
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+
+	"github.com/pborman/getopt/v2"
+)
+
+const AppName = "t3c-check-parents"
+
+// InputStdin is the value of the --generated and --config-data flags to read from stdin.
+const InputStdin = "stdin"
+
+type Cfg struct {
+	LogLocationDebug       string
+	LogLocationWarn        string
+	LogLocationError       string
+	LogLocationInfo        string
+	TrafficServerConfigDir string
+	// Generated is the path of the t3c-generate output to check, or InputStdin.
+	// If empty, the files in TrafficServerConfigDir are checked.
+	Generated string
+	// ConfigData is the path of the t3c-request config data to check the parents against the Topologies of, or InputStdin.
+	// If empty, the Topologies are not checked.
+	ConfigData  string
+	Version     string
+	GitRevision string
+}
+
+var defaultATSConfigDir = "/opt/trafficserver/etc/trafficserver"
+
+func (cfg Cfg) AppVersion() string { return t3cutil.VersionStr(AppName, cfg.Version, cfg.GitRevision) }
+
+func (cfg Cfg) DebugLog() log.LogLocation   { return log.LogLocation(cfg.LogLocationDebug) }
+func (cfg Cfg) ErrorLog() log.LogLocation   { return log.LogLocation(cfg.LogLocationError) }
+func (cfg Cfg) InfoLog() log.LogLocation    { return log.LogLocation(cfg.LogLocationInfo) }
+func (cfg Cfg) WarningLog() log.LogLocation { return log.LogLocation(cfg.LogLocationWarn) }
+func (cfg Cfg) EventLog() log.LogLocation   { return log.LogLocation(log.LogLocationNull) } // event logging is not used.
+
+// Usage() writes command line options and usage to 'stderr'
+func Usage() {
+	getopt.PrintUsage(os.Stderr)
+	os.Exit(0)
+}
+
+// InitConfig() intializes the configuration variables and loggers.
+func InitConfig(appVersion string, gitRevision string) (Cfg, error) {
+	versionPtr := getopt.BoolLong("version", 'V', "Print version information and exit.")
+	atsConfigDirPtr := getopt.StringLong("trafficserver-config-dir", 'c', defaultATSConfigDir, "directory where the ATS remap.config, parent.config, and strategies.yaml to check are stored.")
+	generatedPtr := getopt.StringLong("generated", 'g', "", "path of the t3c-generate output to check, or 'stdin'. If given, the files in the trafficserver-config-dir are not checked.")
+	configDataPtr := getopt.StringLong("config-data", 'd', "", "path of the 't3c-request --get-data=config' output to check the parents against Traffic Ops Topologies, or 'stdin'.")
+	helpPtr := getopt.BoolLong("help", 'h', "Print usage information and exit")
+	verbosePtr := getopt.CounterLong("verbose", 'v', `Log verbosity. Logging is output to stderr. By default, errors are logged. To log warnings, pass '-v'. To log info, pass '-vv'. To omit error logging, see '-s'`)
+	silentPtr := getopt.BoolLong("silent", 's', `Silent. Errors are not logged, and the 'verbose' flag is ignored. If a fatal error occurs, the return code will be non-zero but no text will be output to stderr`)
+
+	getopt.Parse()
+
+	if *helpPtr == true {
+		Usage()
+		os.Exit(0)
+	} else if *versionPtr {
+		cfg := &Cfg{Version: appVersion, GitRevision: gitRevision}
+		fmt.Println(cfg.AppVersion())
+		os.Exit(0)
+	}
+
+	logLocationError := log.LogLocationStderr
+	logLocationWarn := log.LogLocationNull
+	logLocationInfo := log.LogLocationNull
+	logLocationDebug := log.LogLocationNull
+	if *silentPtr {
+		logLocationError = log.LogLocationNull
+	} else {
+		if *verbosePtr >= 1 {
+			logLocationWarn = log.LogLocationStderr
+		}
+		if *verbosePtr >= 2 {
+			logLocationInfo = log.LogLocationStderr
+			logLocationDebug = log.LogLocationStderr // t3c only has 3 verbosity options: none (-s), error (default or --verbose=0), warning (-v), and info (-vv). Any code calling log.Debug is treated as Info.
+		}
+	}
+
+	if *verbosePtr > 2 {
+		return Cfg{}, errors.New("Too many verbose options. The maximum log verbosity level is 2 (-vv or --verbose=2) for errors (0), warnings (1), and info (2)")
+	}
+
+	generated := strings.TrimSpace(*generatedPtr)
+	configData := strings.TrimSpace(*configDataPtr)
+	if strings.ToLower(generated) == InputStdin && strings.ToLower(configData) == InputStdin {
+		return Cfg{}, errors.New("only one of --generated and --config-data may be read from stdin")
+	}
+
+	cfg := Cfg{
+		LogLocationDebug:       logLocationDebug,
+		LogLocationError:       logLocationError,
+		LogLocationInfo:        logLocationInfo,
+		LogLocationWarn:        logLocationWarn,
+		TrafficServerConfigDir: *atsConfigDirPtr,
+		Generated:              generated,
+		ConfigData:             configData,
+		Version:                appVersion,
+		GitRevision:            gitRevision,
+	}
+
+	if err := log.InitCfg(cfg); err != nil {
+		return Cfg{}, errors.New("initializing loggers: " + err.Error())
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,511 @@
+package main
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3c-diff/semdiff"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ParentDotConfigFileName = "parent.config"
+	StrategiesFileName      = "strategies.yaml"
+	RemapDotConfigFileName  = "remap.config"
+)
+
+// The policies of a Selection, as named in strategies.yaml.
+const (
+	PolicyConsistentHash = "consistent_hash"
+	PolicyRoundRobinIP   = "rr_ip"
+	PolicyRoundRobin     = "rr_strict"
+	PolicyFirstLive      = "first_live"
+	PolicyLatched        = "latched"
+)
+
+// The secondary modes of a Selection, as named in strategies.yaml.
+const (
+	RingModeAlternate = "alternate_ring"
+	RingModeExhaust   = "exhaust_ring"
+	RingModePeering   = "peering_ring"
+)
+
+// Parent is a single parent of a Selection.
+type Parent struct {
+	Host   string
+	Port   int
+	Weight float64
+}
+
+// String returns the parent in the parent.config format.
+func (pa Parent) String() string {
+	return pa.Host + ":" + strconv.Itoa(pa.Port) + "|" + strconv.FormatFloat(pa.Weight, 'f', -1, 64)
+}
+
+// Selection is the effective parent selection of a remap rule, from either parent.config or strategies.yaml.
+type Selection struct {
+	// Source is the file the selection is from.
+	Source string
+	// Name is the parent.config destination or strategies.yaml strategy name.
+	Name             string
+	Parents          []Parent
+	SecondaryParents []Parent
+	// Policy is the selection policy, one of the Policy constants.
+	Policy string
+	// HashKey is the part of the request hashed for PolicyConsistentHash, either "path" or "path+query".
+	HashKey  string
+	GoDirect bool
+	// RingMode is how SecondaryParents are tried, one of the RingMode constants.
+	RingMode string
+	// Peers are the peers of a RingModePeering selection.
+	Peers []Parent
+	// MaxSimpleRetries and the other retry fields are nil if the file doesn't set them.
+	MaxSimpleRetries      *int
+	SimpleRetryCodes      []int
+	MaxUnavailableRetries *int
+	UnavailableRetryCodes []int
+}
+
+// parentDotConfigLine is a single parent.config line, with the destination it matches.
+type parentDotConfigLine struct {
+	DestDomain string
+	DestHost   string
+	DestIP     string
+	// Port is the port the line matches, or 0 for all ports.
+	Port      int
+	Selection Selection
+}
+
+// splitQuoted splits the line on whitespace, except whitespace in double quotes.
+func splitQuoted(line string) ([]string, error) {
+	fields := []string{}
+	field := strings.Builder{}
+	quoted := false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			field.WriteRune(r)
+		case !quoted && (r == ' ' || r == '\t'):
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+		default:
+			field.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, errors.New("unterminated quote")
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+	return fields, nil
+}
+
+// parseParentDotConfig parses the lines of a parent.config.
+func parseParentDotConfig(file string) ([]parentDotConfigLine, error) {
+	lines := []parentDotConfigLine{}
+	for _, line := range semdiff.ConfigLines(file) {
+		pl, err := parseParentDotConfigLine(line)
+		if err != nil {
+			return nil, errors.New("malformed line '" + line + "': " + err.Error())
+		}
+		lines = append(lines, pl)
+	}
+	return lines, nil
+}
+
+func parseParentDotConfigLine(line string) (parentDotConfigLine, error) {
+	fields, err := splitQuoted(line)
+	if err != nil {
+		return parentDotConfigLine{}, err
+	}
+	pl := parentDotConfigLine{Selection: Selection{
+		Source:   ParentDotConfigFileName,
+		Policy:   PolicyFirstLive,
+		HashKey:  "path+query",
+		GoDirect: true,
+		RingMode: RingModeAlternate,
+	}}
+	parentRetry := ""
+	var maxSimpleRetries *int
+	var maxUnavailableRetries *int
+	for _, field := range fields {
+		nameVal := strings.SplitN(field, "=", 2)
+		if len(nameVal) != 2 {
+			return parentDotConfigLine{}, errors.New("malformed directive '" + field + "'")
+		}
+		name := strings.ToLower(nameVal[0])
+		val := strings.Trim(nameVal[1], `"`)
+		switch name {
+		case "dest_domain":
+			pl.DestDomain = strings.ToLower(val)
+		case "dest_host":
+			pl.DestHost = strings.ToLower(val)
+		case "dest_ip":
+			pl.DestIP = val
+		case "port":
+			if pl.Port, err = strconv.Atoi(val); err != nil {
+				return parentDotConfigLine{}, errors.New("malformed port '" + val + "'")
+			}
+		case "parent":
+			if pl.Selection.Parents, err = parseParentList(val); err != nil {
+				return parentDotConfigLine{}, errors.New("malformed parent: " + err.Error())
+			}
+		case "secondary_parent":
+			if pl.Selection.SecondaryParents, err = parseParentList(val); err != nil {
+				return parentDotConfigLine{}, errors.New("malformed secondary_parent: " + err.Error())
+			}
+		case "round_robin":
+			switch strings.ToLower(val) {
+			case "true":
+				pl.Selection.Policy = PolicyRoundRobinIP
+			case "strict":
+				pl.Selection.Policy = PolicyRoundRobin
+			case "false":
+				pl.Selection.Policy = PolicyFirstLive
+			case "consistent_hash":
+				pl.Selection.Policy = PolicyConsistentHash
+			case "latched":
+				pl.Selection.Policy = PolicyLatched
+			default:
+				return parentDotConfigLine{}, errors.New("unknown round_robin '" + val + "'")
+			}
+		case "qstring":
+			if strings.ToLower(val) == "ignore" {
+				pl.Selection.HashKey = "path"
+			} else {
+				pl.Selection.HashKey = "path+query"
+			}
+		case "go_direct":
+			pl.Selection.GoDirect = strings.ToLower(val) == "true"
+		case "secondary_mode":
+			if val == "2" {
+				pl.Selection.RingMode = RingModeExhaust
+			} else {
+				pl.Selection.RingMode = RingModeAlternate
+			}
+		case "parent_retry":
+			parentRetry = strings.ToLower(val)
+		case "max_simple_retries":
+			num, err := strconv.Atoi(val)
+			if err != nil {
+				return parentDotConfigLine{}, errors.New("malformed max_simple_retries '" + val + "'")
+			}
+			maxSimpleRetries = &num
+		case "max_unavailable_server_retries":
+			num, err := strconv.Atoi(val)
+			if err != nil {
+				return parentDotConfigLine{}, errors.New("malformed max_unavailable_server_retries '" + val + "'")
+			}
+			maxUnavailableRetries = &num
+		case "simple_server_retry_responses":
+			if pl.Selection.SimpleRetryCodes, err = parseCodes(val); err != nil {
+				return parentDotConfigLine{}, errors.New("malformed simple_server_retry_responses: " + err.Error())
+			}
+		case "unavailable_server_retry_responses":
+			if pl.Selection.UnavailableRetryCodes, err = parseCodes(val); err != nil {
+				return parentDotConfigLine{}, errors.New("malformed unavailable_server_retry_responses: " + err.Error())
+			}
+		}
+	}
+	if pl.DestDomain == "" && pl.DestHost == "" && pl.DestIP == "" {
+		return parentDotConfigLine{}, errors.New("no dest_domain, dest_host, or dest_ip")
+	}
+
+	// Retry counts only apply if parent_retry enables their kind of retry.
+	if parentRetry == "simple_retry" || parentRetry == "both" {
+		pl.Selection.MaxSimpleRetries = maxSimpleRetries
+	}
+	if parentRetry == "unavailable_server_retry" || parentRetry == "both" {
+		pl.Selection.MaxUnavailableRetries = maxUnavailableRetries
+	}
+
+	pl.Selection.Name = pl.dest()
+	return pl, nil
+}
+
+// dest returns the destination directives of the line, as they'd appear in parent.config.
+func (pl parentDotConfigLine) dest() string {
+	dest := ""
+	switch {
+	case pl.DestHost != "":
+		dest = "dest_host=" + pl.DestHost
+	case pl.DestIP != "":
+		dest = "dest_ip=" + pl.DestIP
+	default:
+		dest = "dest_domain=" + pl.DestDomain
+	}
+	if pl.Port != 0 {
+		dest += " port=" + strconv.Itoa(pl.Port)
+	}
+	return dest
+}
+
+// parseParentList parses a parent.config parent list, of the form "host:port|weight;host:port|weight".
+func parseParentList(val string) ([]Parent, error) {
+	parents := []Parent{}
+	for _, parentStr := range strings.FieldsFunc(val, func(r rune) bool { return r == ';' || r == ',' }) {
+		parentStr = strings.TrimSpace(parentStr)
+		weight := 1.0
+		if pipe := strings.Index(parentStr, "|"); pipe >= 0 {
+			var err error
+			if weight, err = strconv.ParseFloat(parentStr[pipe+1:], 64); err != nil {
+				return nil, errors.New("malformed weight in '" + parentStr + "'")
+			}
+			parentStr = parentStr[:pipe]
+		}
+		host, portStr, err := net.SplitHostPort(parentStr)
+		if err != nil {
+			return nil, errors.New("malformed parent '" + parentStr + "': " + err.Error())
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			return nil, errors.New("malformed port in '" + parentStr + "'")
+		}
+		parents = append(parents, Parent{Host: strings.ToLower(host), Port: port, Weight: weight})
+	}
+	return parents, nil
+}
+
+func parseCodes(val string) ([]int, error) {
+	codes := []int{}
+	for _, codeStr := range strings.Split(val, ",") {
+		codeStr = strings.TrimSpace(codeStr)
+		if codeStr == "" {
+			continue
+		}
+		code, err := strconv.Atoi(codeStr)
+		if err != nil {
+			return nil, errors.New("malformed code '" + codeStr + "'")
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// matchParentDotConfig returns the parent.config line ATS uses for requests to the host and port,
+// and whether any line matched.
+//
+// A dest_host or dest_ip match is used over a dest_domain match, a longer dest_domain over a shorter,
+// and a line with a port over one without. Of equally specific lines, the first is used.
+func matchParentDotConfig(lines []parentDotConfigLine, host string, port int) (parentDotConfigLine, bool) {
+	host = strings.ToLower(host)
+	best := parentDotConfigLine{}
+	bestScore := -1
+	for _, line := range lines {
+		if line.Port != 0 && line.Port != port {
+			continue
+		}
+		score := -1
+		switch {
+		case line.DestHost != "" && line.DestHost == host:
+			score = math.MaxInt16
+		case line.DestIP != "" && line.DestIP == host:
+			score = math.MaxInt16
+		case line.DestDomain == ".":
+			score = 0
+		case line.DestDomain != "" && (host == line.DestDomain || strings.HasSuffix(host, "."+strings.TrimPrefix(line.DestDomain, "."))):
+			score = len(line.DestDomain)
+		}
+		if score < 0 {
+			continue
+		}
+		score *= 2
+		if line.Port != 0 {
+			score++
+		}
+		if score > bestScore {
+			best = line
+			bestScore = score
+		}
+	}
+	return best, bestScore >= 0
+}
+
+// strategiesYAML is the part of a strategies.yaml used to determine parent selection.
+// The hosts and groups sections are referenced by the strategies as YAML anchors, so they don't need to be decoded separately.
+type strategiesYAML struct {
+	Strategies []struct {
+		Strategy        string            `yaml:"strategy"`
+		Policy          string            `yaml:"policy"`
+		HashKey         string            `yaml:"hash_key"`
+		GoDirect        *bool             `yaml:"go_direct"`
+		CachePeerResult *bool             `yaml:"cache_peer_result"`
+		Groups          [][]strategyHost  `yaml:"groups"`
+		Failover        *strategyFailover `yaml:"failover"`
+	} `yaml:"strategies"`
+}
+
+type strategyHost struct {
+	Host     string `yaml:"host"`
+	Protocol []struct {
+		Scheme string `yaml:"scheme"`
+		Port   int    `yaml:"port"`
+	} `yaml:"protocol"`
+	Weight *float64 `yaml:"weight"`
+}
+
+type strategyFailover struct {
+	RingMode              string `yaml:"ring_mode"`
+	MaxSimpleRetries      *int   `yaml:"max_simple_retries"`
+	ResponseCodes         []int  `yaml:"response_codes"`
+	MaxUnavailableRetries *int   `yaml:"max_unavailable_retries"`
+	MarkdownCodes         []int  `yaml:"markdown_codes"`
+}
+
+// parseStrategies parses a strategies.yaml into the Selection of each strategy, by name.
+func parseStrategies(file string) (map[string]Selection, error) {
+	sy := strategiesYAML{}
+	if err := yaml.Unmarshal([]byte(file), &sy); err != nil {
+		return nil, errors.New("unmarshalling yaml: " + err.Error())
+	}
+	selections := map[string]Selection{}
+	for _, st := range sy.Strategies {
+		if st.Strategy == "" {
+			return nil, errors.New("malformed strategy with no name")
+		}
+		sel := Selection{
+			Source:   StrategiesFileName,
+			Name:     st.Strategy,
+			Policy:   st.Policy,
+			HashKey:  st.HashKey,
+			GoDirect: st.GoDirect == nil || *st.GoDirect,
+			RingMode: RingModeExhaust,
+		}
+		if sel.HashKey == "" {
+			sel.HashKey = "path"
+		}
+		if st.Failover != nil {
+			if st.Failover.RingMode != "" {
+				sel.RingMode = st.Failover.RingMode
+			}
+			sel.MaxSimpleRetries = st.Failover.MaxSimpleRetries
+			sel.SimpleRetryCodes = st.Failover.ResponseCodes
+			sel.MaxUnavailableRetries = st.Failover.MaxUnavailableRetries
+			sel.UnavailableRetryCodes = st.Failover.MarkdownCodes
+		}
+		groups := make([][]Parent, 0, len(st.Groups))
+		for _, group := range st.Groups {
+			parents := []Parent{}
+			for _, host := range group {
+				pa := Parent{Host: strings.ToLower(host.Host), Weight: 1.0}
+				if len(host.Protocol) > 0 {
+					pa.Port = host.Protocol[0].Port
+				}
+				if host.Weight != nil {
+					pa.Weight = *host.Weight
+				}
+				parents = append(parents, pa)
+			}
+			groups = append(groups, parents)
+		}
+		if sel.RingMode == RingModePeering && len(groups) > 0 {
+			sel.Peers = groups[0]
+			groups = groups[1:]
+		}
+		if len(groups) > 0 {
+			sel.Parents = groups[0]
+		}
+		if len(groups) > 1 {
+			sel.SecondaryParents = groups[1]
+		}
+		selections[sel.Name] = sel
+	}
+	return selections, nil
+}
+
+// remapRule is a remap.config rule which proxies requests to a target, and so selects parents for them.
+type remapRule struct {
+	// Line is the rule's type and from-URL, which identify it in reports.
+	Line       string
+	TargetHost string
+	TargetPort int
+	// Strategy is the name of the strategies.yaml strategy the rule uses, or empty if it uses parent.config.
+	Strategy string
+}
+
+// remapRuleTypes are the remap.config rule types which proxy requests, rather than redirecting them.
+var remapRuleTypes = map[string]struct{}{
+	"map":                {},
+	"map_with_recv_port": {},
+	"map_with_referer":   {},
+	"regex_map":          {},
+}
+
+// parseRemapDotConfig parses the rules of a remap.config which select parents.
+func parseRemapDotConfig(file string) ([]remapRule, error) {
+	rules := []remapRule{}
+	for _, line := range semdiff.ConfigLines(file) {
+		fields := strings.Fields(line)
+		if _, ok := remapRuleTypes[fields[0]]; !ok {
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, errors.New("malformed line '" + line + "'")
+		}
+		target, err := url.Parse(fields[2])
+		if err != nil {
+			return nil, errors.New("malformed target URL in line '" + line + "': " + err.Error())
+		}
+		rule := remapRule{
+			Line:       fields[0] + " " + fields[1],
+			TargetHost: strings.ToLower(target.Hostname()),
+		}
+		if rule.TargetPort, err = strconv.Atoi(target.Port()); err != nil {
+			rule.TargetPort = 80
+			if strings.ToLower(target.Scheme) == "https" {
+				rule.TargetPort = 443
+			}
+		}
+		for i := 3; i < len(fields); i++ {
+			if strings.HasPrefix(fields[i], "@strategy=") {
+				rule.Strategy = strings.TrimPrefix(fields[i], "@strategy=")
+			} else if fields[i] == "@plugin=parent_select.so" && i+2 < len(fields) && strings.HasPrefix(fields[i+2], "@pparam=") {
+				// the parent_select plugin's params are the strategies.yaml path followed by the strategy name.
+				rule.Strategy = strings.TrimPrefix(fields[i+2], "@pparam=")
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// sortedParents returns a copy of the parents, sorted by host and port.
+func sortedParents(parents []Parent) []Parent {
+	sorted := append([]Parent{}, parents...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Host != sorted[j].Host {
+			return sorted[i].Host < sorted[j].Host
+		}
+		return sorted[i].Port < sorted[j].Port
+	})
+	return sorted
+}
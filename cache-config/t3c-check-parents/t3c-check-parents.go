@@ -0,0 +1,162 @@
+package main
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3c-check-parents/config"
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+)
+
+// Version is the application version.
+// This is overwritten by the build with the current project version.
+var Version = "0.4"
+
+// GitRevision is the git revision the application was built from.
+// This is overwritten by the build with the current project version.
+var GitRevision = "nogit"
+
+const ExitCodeSuccess = 0
+const ExitCodeInconsistent = 1
+const ExitCodeErr = 2
+
+func main() {
+	cfg, err := config.InitConfig(Version, GitRevision)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		os.Exit(ExitCodeErr)
+	}
+
+	files, err := loadFiles(cfg)
+	if err != nil {
+		log.Errorf("loading config files: %s\n", err.Error())
+		os.Exit(ExitCodeErr)
+	}
+	rules, err := parseRemapDotConfig(files[RemapDotConfigFileName])
+	if err != nil {
+		log.Errorf("parsing %s: %s\n", RemapDotConfigFileName, err.Error())
+		os.Exit(ExitCodeErr)
+	}
+	parentLines, err := parseParentDotConfig(files[ParentDotConfigFileName])
+	if err != nil {
+		log.Errorf("parsing %s: %s\n", ParentDotConfigFileName, err.Error())
+		os.Exit(ExitCodeErr)
+	}
+	strategies, err := parseStrategies(files[StrategiesFileName])
+	if err != nil {
+		log.Errorf("parsing %s: %s\n", StrategiesFileName, err.Error())
+		os.Exit(ExitCodeErr)
+	}
+
+	var data *t3cutil.ConfigData
+	if cfg.ConfigData != "" {
+		if data, err = loadConfigData(cfg.ConfigData); err != nil {
+			log.Errorf("loading config data: %s\n", err.Error())
+			os.Exit(ExitCodeErr)
+		}
+	}
+
+	inconsistencies := checkRules(rules, parentLines, strategies, data)
+	for _, in := range inconsistencies {
+		fmt.Println(in.String())
+	}
+	if len(inconsistencies) > 0 {
+		log.Errorf("found %d parent selection inconsistencies in %d remap rules\n", len(inconsistencies), len(rules))
+		os.Exit(ExitCodeInconsistent)
+	}
+	log.Infof("the parent selection of all %d remap rules is consistent\n", len(rules))
+	os.Exit(ExitCodeSuccess)
+}
+
+// loadFiles returns the text of the remap.config, parent.config, and strategies.yaml to check, by name.
+// They're read from the t3c-generate output if cfg.Generated is set, else from cfg.TrafficServerConfigDir.
+func loadFiles(cfg config.Cfg) (map[string]string, error) {
+	names := []string{RemapDotConfigFileName, ParentDotConfigFileName, StrategiesFileName}
+	files := map[string]string{}
+	if cfg.Generated == "" {
+		for _, name := range names {
+			path := filepath.Join(cfg.TrafficServerConfigDir, name)
+			bts, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, errors.New("reading '" + path + "': " + err.Error())
+			}
+			files[name] = string(bts)
+		}
+		return files, nil
+	}
+
+	rd, closeFunc, err := openFileOrStdin(cfg.Generated)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFunc()
+	generated := []t3cutil.ATSConfigFile{}
+	if err := json.NewDecoder(rd).Decode(&generated); err != nil {
+		return nil, errors.New("decoding t3c-generate output: " + err.Error())
+	}
+	for _, file := range generated {
+		files[file.Name] = file.Text
+	}
+	for _, name := range names {
+		if _, ok := files[name]; !ok {
+			return nil, errors.New("t3c-generate output has no " + name)
+		}
+	}
+	return files, nil
+}
+
+// loadConfigData reads the 't3c-request --get-data=config' output at the path, or stdin.
+func loadConfigData(path string) (*t3cutil.ConfigData, error) {
+	rd, closeFunc, err := openFileOrStdin(path)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFunc()
+	data := &t3cutil.ConfigData{}
+	if err := json.NewDecoder(rd).Decode(data); err != nil {
+		return nil, errors.New("decoding: " + err.Error())
+	}
+	if data.Server == nil {
+		return nil, errors.New("config data has no server")
+	}
+	return data, nil
+}
+
+// openFileOrStdin opens the file, or if path is 'stdin', returns stdin.
+// Returns the reader, and a func to close it.
+func openFileOrStdin(path string) (io.Reader, func(), error) {
+	if strings.ToLower(path) == config.InputStdin {
+		return os.Stdin, func() {}, nil
+	}
+	fi, err := os.Open(path)
+	if err != nil {
+		return nil, nil, errors.New("opening '" + path + "': " + err.Error())
+	}
+	return fi, func() { fi.Close() }, nil
+}
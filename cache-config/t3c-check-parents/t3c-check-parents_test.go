@@ -0,0 +1,203 @@
+package main
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+// makeTopologyData returns the data of an edge with a topology Delivery Service, whose edge Cache Group edgeCG has
+// the primary parent mid0 in midCG0 and the secondary parent mid1 in midCG1.
+func makeTopologyData() *t3cutil.ConfigData {
+	ds := atscfg.DeliveryService{}
+	ds.ID = util.Ptr(10)
+	ds.XMLID = "ds0"
+	ds.Type = util.Ptr(string(tc.DSTypeHTTP))
+	ds.OrgServerFQDN = util.Ptr("http://origin.example.net")
+	ds.Topology = util.Ptr("topo0")
+
+	servers := make([]atscfg.Server, 3)
+	for i, name := range []string{"edge0", "mid0", "mid1"} {
+		servers[i].ID = i + 1
+		servers[i].HostName = name
+		servers[i].DomainName = "example.net"
+		servers[i].CacheGroup = "midCG" + strconv.Itoa(i-1)
+		servers[i].CDN = "mycdn"
+		servers[i].Profiles = []string{"serverprofile"}
+		servers[i].TCPPort = util.Ptr(80)
+		servers[i].Type = tc.MidTypePrefix
+		servers[i].Status = string(tc.CacheStatusReported)
+	}
+	servers[0].CacheGroup = "edgeCG"
+	servers[0].Type = tc.EdgeTypePrefix
+
+	return &t3cutil.ConfigData{
+		Server:           &servers[0],
+		Servers:          servers,
+		DeliveryServices: []atscfg.DeliveryService{ds},
+		CacheGroups: []tc.CacheGroupNullableV5{
+			{ID: util.Ptr(1), Name: util.Ptr("edgeCG"), Type: util.Ptr(tc.CacheGroupEdgeTypeName)},
+			{ID: util.Ptr(2), Name: util.Ptr("midCG0"), Type: util.Ptr(tc.CacheGroupMidTypeName)},
+			{ID: util.Ptr(3), Name: util.Ptr("midCG1"), Type: util.Ptr(tc.CacheGroupMidTypeName)},
+		},
+		CDN: &tc.CDNV5{Name: "mycdn", DomainName: "mycdn.example.net"},
+		ParentConfigParams: []tc.ParameterV5{{
+			Name:       atscfg.ParentConfigRetryKeysDefault.Algorithm,
+			ConfigFile: "parent.config",
+			Value:      tc.AlgorithmConsistentHash,
+			Profiles:   []byte(`["serverprofile"]`),
+		}},
+		// ATS 9 parent.config has secondary parents, like strategies.yaml
+		ServerParams: []tc.ParameterV5{{Name: "trafficserver", ConfigFile: "package", Value: "9", Profiles: []byte(`["serverprofile"]`)}},
+		Topologies: []tc.TopologyV5{{
+			Name: "topo0",
+			Nodes: []tc.TopologyNodeV5{
+				{Cachegroup: "edgeCG", Parents: []int{1, 2}},
+				{Cachegroup: "midCG0"},
+				{Cachegroup: "midCG1"},
+			},
+		}},
+	}
+}
+
+// generate returns the parent.config and strategies.yaml t3c-generate creates from the data.
+func generate(t *testing.T, data *t3cutil.ConfigData) (string, string) {
+	t.Helper()
+	parentDotConfig, err := atscfg.MakeParentDotConfig(data.DeliveryServices, data.Server, data.Servers, data.Topologies, data.ServerParams, data.ParentConfigParams, data.ServerCapabilities, data.DSRequiredCapabilities, data.CacheGroups, data.DeliveryServiceServers, data.CDN, &atscfg.ParentConfigOpts{})
+	if err != nil {
+		t.Fatalf("making parent.config: %v", err)
+	}
+	strategies, err := atscfg.MakeStrategiesDotYAML(data.DeliveryServices, data.Server, data.Servers, data.Topologies, data.ServerParams, data.ParentConfigParams, data.ServerCapabilities, data.DSRequiredCapabilities, data.CacheGroups, data.DeliveryServiceServers, data.CDN, &atscfg.StrategiesYAMLOpts{})
+	if err != nil {
+		t.Fatalf("making strategies.yaml: %v", err)
+	}
+	return parentDotConfig.Text, strategies.Text
+}
+
+func check(t *testing.T, remap string, parent string, strategiesYAML string, data *t3cutil.ConfigData) []Inconsistency {
+	t.Helper()
+	rules, err := parseRemapDotConfig(remap)
+	if err != nil {
+		t.Fatalf("parsing remap.config: %v", err)
+	}
+	parentLines, err := parseParentDotConfig(parent)
+	if err != nil {
+		t.Fatalf("parsing parent.config: %v", err)
+	}
+	strategies, err := parseStrategies(strategiesYAML)
+	if err != nil {
+		t.Fatalf("parsing strategies.yaml: %v", err)
+	}
+	return checkRules(rules, parentLines, strategies, data)
+}
+
+const testRemap = "map http://ds0.mycdn.example.net/ http://origin.example.net/ @plugin=cachekey.so @pparam=cachekey.config\n"
+
+func TestCheckRulesGenerated(t *testing.T) {
+	data := makeTopologyData()
+	parent, strategies := generate(t, data)
+
+	if ins := check(t, testRemap, parent, strategies, data); len(ins) != 0 {
+		t.Errorf("expected generated parent.config and strategies.yaml to be consistent, actual: %+v\nparent.config:\n%s\nstrategies.yaml:\n%s", ins, parent, strategies)
+	}
+
+	strategyRemap := strings.Replace(testRemap, "\n", " @strategy=strategy-ds0\n", 1)
+	if ins := check(t, strategyRemap, parent, strategies, nil); len(ins) != 0 {
+		t.Errorf("expected generated parent.config and strategies.yaml to be consistent for a rule with a strategy and no config data, actual: %+v", ins)
+	}
+}
+
+func TestCheckRulesInconsistent(t *testing.T) {
+	data := makeTopologyData()
+	parent, strategies := generate(t, data)
+
+	parent = strings.Replace(parent, "round_robin=consistent_hash", "round_robin=true", 1)
+	ins := check(t, testRemap, parent, strategies, data)
+	if len(ins) == 0 || !strings.Contains(ins[0].String(), "policy differs") {
+		t.Errorf("expected policy inconsistency, actual: %+v", ins)
+	}
+	if len(ins) > 0 && (ins[0].Rule != "map http://ds0.mycdn.example.net/" || ins[0].DS != "ds0") {
+		t.Errorf("expected inconsistency for rule 'map http://ds0.mycdn.example.net/' ds 'ds0', actual: %+v", ins[0])
+	}
+
+	strategyRemap := strings.Replace(testRemap, "\n", " @strategy=strategy-nonexistent\n", 1)
+	ins = check(t, strategyRemap, parent, strategies, nil)
+	if len(ins) != 1 || !strings.Contains(ins[0].Msg, "doesn't define") {
+		t.Errorf("expected undefined strategy inconsistency, actual: %+v", ins)
+	}
+}
+
+func TestCheckRulesTopology(t *testing.T) {
+	data := makeTopologyData()
+	parent, strategies := generate(t, data)
+
+	// the files were generated before mid1 moved to another Cache Group, so both now have a secondary parent which isn't in the Topology
+	data.Servers[2].CacheGroup = "otherCG"
+	ins := check(t, testRemap, parent, strategies, data)
+	numSecondary := 0
+	for _, in := range ins {
+		if strings.Contains(in.Msg, "secondary parent 'mid1.example.net' isn't a server in the Topology 'topo0' secondary parent Cache Group 'midCG1'") {
+			numSecondary++
+		}
+	}
+	if numSecondary != 2 {
+		t.Errorf("expected parent.config and strategies.yaml secondary parent inconsistencies, actual: %+v", ins)
+	}
+
+	data = makeTopologyData()
+	data.Topologies[0].Nodes[0].Cachegroup = "otherEdgeCG"
+	ins = check(t, testRemap, parent, strategies, data)
+	if len(ins) != 1 || !strings.Contains(ins[0].Msg, "isn't in Topology 'topo0'") {
+		t.Errorf("expected inconsistency for server Cache Group not in the Topology, actual: %+v", ins)
+	}
+}
+
+func TestMatchParentDotConfig(t *testing.T) {
+	lines, err := parseParentDotConfig(`dest_domain=example.net parent="a.example.net:80|1"
+dest_domain=origin.example.net port=80 parent="b.example.net:80|1"
+dest_domain=origin.example.net parent="c.example.net:80|1"
+dest_domain=. go_direct=true`)
+	if err != nil {
+		t.Fatalf("parsing parent.config: %v", err)
+	}
+	tests := []struct {
+		host     string
+		port     int
+		expected string
+	}{
+		{"origin.example.net", 80, "dest_domain=origin.example.net port=80"},
+		{"origin.example.net", 8080, "dest_domain=origin.example.net"},
+		{"other.example.net", 80, "dest_domain=example.net"},
+		{"example.com", 80, "dest_domain=."},
+	}
+	for _, test := range tests {
+		line, ok := matchParentDotConfig(lines, test.host, test.port)
+		if !ok || line.dest() != test.expected {
+			t.Errorf("expected %s:%d to match '%s', actual: '%s' %v", test.host, test.port, test.expected, line.dest(), ok)
+		}
+	}
+}
@@ -42,8 +42,9 @@ var Version = "0.4"
 var GitRevision = "nogit"
 
 var commands = map[string]struct{}{
-	"refs":   {},
-	"reload": {},
+	"parents": {},
+	"refs":    {},
+	"reload":  {},
 }
 
 const ExitCodeSuccess = 0
@@ -107,6 +108,7 @@ For the arguments of a command, see 't3c-check <command> --help'.
 
 These are the available commands:
 
+  parents if parent.config and strategies.yaml select the same parents
   reload  if a reload or restart is needed
   refs    if a config file's referenced plugins and files are valid
 `
@@ -0,0 +1,333 @@
+// Package semdiff compares ATS config files by their entries, rather than line-by-line,
+// so reordered but otherwise identical files have no diff.
+package semdiff
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileType is a type of config file which can be compared semantically.
+type FileType string
+
+const (
+	FileTypeRecords = FileType("records.config")
+	FileTypeRemap   = FileType("remap.config")
+	FileTypeParent  = FileType("parent.config")
+	FileTypeSNI     = FileType("sni.yaml")
+)
+
+// ChangeType is how an entry changed between two files.
+type ChangeType string
+
+const (
+	ChangeAdded   = ChangeType("added")
+	ChangeRemoved = ChangeType("removed")
+	ChangeChanged = ChangeType("changed")
+)
+
+// Change is a single entry which was added, removed, or changed.
+type Change struct {
+	Type ChangeType `json:"type"`
+	// Key identifies the entry, such as the records.config name or the remap.config rule type and from-URL.
+	// It is empty for changes of a line diff.
+	Key string `json:"key,omitempty"`
+	// Old is the normalized entry in the first file. It is empty if the entry was added.
+	Old string `json:"old,omitempty"`
+	// New is the normalized entry in the second file. It is empty if the entry was removed.
+	New string `json:"new,omitempty"`
+}
+
+// ParseFileType returns the FileType of the given name, and whether it is a type which can be compared semantically.
+func ParseFileType(name string) (FileType, bool) {
+	switch ft := FileType(strings.ToLower(strings.TrimSpace(name))); ft {
+	case FileTypeRecords, FileTypeRemap, FileTypeParent, FileTypeSNI:
+		return ft, true
+	}
+	return "", false
+}
+
+// FileTypeOfPath returns the FileType of the file at the given path, from its name,
+// and whether it is a type which can be compared semantically.
+func FileTypeOfPath(path string) (FileType, bool) {
+	return ParseFileType(filepath.Base(path))
+}
+
+// Diff parses the files a and b as the given type, and returns the entries added, removed, and changed in b, sorted by key.
+// The order of entries in the files is ignored, as are comments and whitespace.
+func Diff(fileType FileType, a string, b string) ([]Change, error) {
+	var parse func(string) (entries, error)
+	switch fileType {
+	case FileTypeRecords:
+		parse = parseRecords
+	case FileTypeRemap:
+		parse = parseRemap
+	case FileTypeParent:
+		parse = parseParent
+	case FileTypeSNI:
+		parse = parseSNI
+	default:
+		return nil, errors.New("file type '" + string(fileType) + "' can't be compared semantically")
+	}
+	entriesA, err := parse(a)
+	if err != nil {
+		return nil, errors.New("parsing first file: " + err.Error())
+	}
+	entriesB, err := parse(b)
+	if err != nil {
+		return nil, errors.New("parsing second file: " + err.Error())
+	}
+	return diffEntries(entriesA, entriesB), nil
+}
+
+// entries is the normalized entries of a config file, by their keys.
+type entries map[string]string
+
+// add adds the entry. Entries with the same key as an existing entry are numbered, so none are lost.
+func (es entries) add(key string, entry string) {
+	uniqueKey := key
+	for i := 2; ; i++ {
+		if _, ok := es[uniqueKey]; !ok {
+			break
+		}
+		uniqueKey = fmt.Sprintf("%s (%d)", key, i)
+	}
+	es[uniqueKey] = entry
+}
+
+func diffEntries(a entries, b entries) []Change {
+	keys := []string{}
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	changes := []Change{}
+	for _, key := range keys {
+		entryA, inA := a[key]
+		entryB, inB := b[key]
+		switch {
+		case !inA:
+			changes = append(changes, Change{Type: ChangeAdded, Key: key, New: entryB})
+		case !inB:
+			changes = append(changes, Change{Type: ChangeRemoved, Key: key, Old: entryA})
+		case entryA != entryB:
+			changes = append(changes, Change{Type: ChangeChanged, Key: key, Old: entryA, New: entryB})
+		}
+	}
+	return changes
+}
+
+// ConfigLines returns the non-empty, non-comment lines of the ATS config file, with backslash-continued lines joined.
+func ConfigLines(file string) []string {
+	lines := []string{}
+	continued := ""
+	for _, line := range strings.Split(strings.ReplaceAll(file, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasSuffix(line, `\`) {
+			continued += strings.TrimSuffix(line, `\`) + " "
+			continue
+		}
+		line = strings.TrimSpace(continued + line)
+		continued = ""
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if continued = strings.TrimSpace(continued); continued != "" && !strings.HasPrefix(continued, "#") {
+		lines = append(lines, continued)
+	}
+	return lines
+}
+
+// parseRecords parses a records.config, keyed by record name.
+func parseRecords(file string) (entries, error) {
+	es := entries{}
+	for _, line := range ConfigLines(file) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, errors.New("malformed line '" + line + "'")
+		}
+		es.add(fields[1], strings.Join(fields, " "))
+	}
+	return es, nil
+}
+
+// parseRemap parses a remap.config, with rules keyed by their type and from-URL, and filter definitions and includes by their name.
+//
+// Because a filter applies to the rules after it is activated, each rule's entry includes the filters active for it,
+// so moving an activation relative to the rules is a change of those rules.
+func parseRemap(file string) (entries, error) {
+	es := entries{}
+	activeFilters := []string{}
+	for _, line := range ConfigLines(file) {
+		fields := strings.Fields(line)
+		if strings.HasPrefix(fields[0], ".") {
+			switch fields[0] {
+			case ".activatefilter":
+				if len(fields) > 1 {
+					activeFilters = append(removeString(activeFilters, fields[1]), fields[1])
+				}
+			case ".deactivatefilter":
+				if len(fields) > 1 {
+					activeFilters = removeString(activeFilters, fields[1])
+				}
+			case ".definefilter", ".include":
+				if len(fields) < 2 {
+					return nil, errors.New("malformed line '" + line + "'")
+				}
+				es.add(fields[0]+" "+fields[1], strings.Join(fields, " "))
+			default:
+				es.add(strings.Join(fields, " "), strings.Join(fields, " "))
+			}
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, errors.New("malformed line '" + line + "'")
+		}
+		entry := strings.Join(fields, " ")
+		if len(activeFilters) > 0 {
+			entry += " [active filters: " + strings.Join(activeFilters, " ") + "]"
+		}
+		es.add(fields[0]+" "+fields[1], entry)
+	}
+	return es, nil
+}
+
+func removeString(strs []string, str string) []string {
+	newStrs := []string{}
+	for _, s := range strs {
+		if s != str {
+			newStrs = append(newStrs, s)
+		}
+	}
+	return newStrs
+}
+
+// parentDestParams are the parent.config parameters which select the requests a line applies to, rather than what is done with them.
+var parentDestParams = map[string]struct{}{
+	"dest_domain": {},
+	"dest_host":   {},
+	"dest_ip":     {},
+	"host_regex":  {},
+	"url_regex":   {},
+	"port":        {},
+	"scheme":      {},
+	"prefix":      {},
+	"suffix":      {},
+	"method":      {},
+	"time":        {},
+	"src_ip":      {},
+	"internal":    {},
+}
+
+// parseParent parses a parent.config, keyed by the destination parameters of each line.
+// Each entry is its destination parameters followed by its other parameters, both sorted.
+func parseParent(file string) (entries, error) {
+	es := entries{}
+	for _, line := range ConfigLines(file) {
+		params, err := splitQuoted(line)
+		if err != nil {
+			return nil, errors.New("malformed line '" + line + "': " + err.Error())
+		}
+		dest := []string{}
+		other := []string{}
+		for _, param := range params {
+			name := strings.ToLower(strings.SplitN(param, "=", 2)[0])
+			if _, ok := parentDestParams[name]; ok {
+				dest = append(dest, param)
+			} else {
+				other = append(other, param)
+			}
+		}
+		if len(dest) == 0 {
+			return nil, errors.New("malformed line '" + line + "': no destination")
+		}
+		sort.Strings(dest)
+		sort.Strings(other)
+		es.add(strings.Join(dest, " "), strings.Join(append(dest, other...), " "))
+	}
+	return es, nil
+}
+
+// splitQuoted splits the line on whitespace, except whitespace in double quotes.
+func splitQuoted(line string) ([]string, error) {
+	fields := []string{}
+	field := strings.Builder{}
+	quoted := false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			field.WriteRune(r)
+		case !quoted && (r == ' ' || r == '\t'):
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+		default:
+			field.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, errors.New("unterminated quote")
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+	return fields, nil
+}
+
+// parseSNI parses an sni.yaml, keyed by fqdn. Each entry is the JSON of its YAML, which has sorted keys.
+func parseSNI(file string) (entries, error) {
+	sni := struct {
+		SNI []map[string]interface{} `yaml:"sni"`
+	}{}
+	if err := yaml.Unmarshal([]byte(file), &sni); err != nil {
+		return nil, errors.New("unmarshalling yaml: " + err.Error())
+	}
+	es := entries{}
+	for _, entry := range sni.SNI {
+		fqdn, ok := entry["fqdn"].(string)
+		if !ok {
+			return nil, errors.New("malformed entry with no fqdn")
+		}
+		bts, err := json.Marshal(entry)
+		if err != nil {
+			return nil, errors.New("marshalling entry '" + fqdn + "': " + err.Error())
+		}
+		es.add(fqdn, string(bts))
+	}
+	return es, nil
+}
@@ -0,0 +1,162 @@
+package semdiff
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffRecords(t *testing.T) {
+	a := `# DO NOT EDIT - Generated for odol-atsec-sea-22 by Traffic Ops on Mon Jan 01 00:00:00 UTC 2024
+CONFIG proxy.config.http.server_ports STRING 80 80:ipv6
+CONFIG proxy.config.diags.debug.enabled INT 0
+CONFIG proxy.config.log.max_space_mb_for_logs INT 25000
+`
+	b := `# DO NOT EDIT - Generated for odol-atsec-sea-22 by Traffic Ops on Tue Jan 02 00:00:00 UTC 2024
+CONFIG proxy.config.diags.debug.enabled   INT 1
+CONFIG proxy.config.http.server_ports STRING 80 80:ipv6
+CONFIG proxy.config.http.cache.http INT 1
+`
+	changes, err := Diff(FileTypeRecords, a, b)
+	if err != nil {
+		t.Fatalf("expected nil error, actual: %v", err)
+	}
+	expected := []Change{
+		{Type: ChangeChanged, Key: "proxy.config.diags.debug.enabled", Old: "CONFIG proxy.config.diags.debug.enabled INT 0", New: "CONFIG proxy.config.diags.debug.enabled INT 1"},
+		{Type: ChangeAdded, Key: "proxy.config.http.cache.http", New: "CONFIG proxy.config.http.cache.http INT 1"},
+		{Type: ChangeRemoved, Key: "proxy.config.log.max_space_mb_for_logs", Old: "CONFIG proxy.config.log.max_space_mb_for_logs INT 25000"},
+	}
+	if !reflect.DeepEqual(expected, changes) {
+		t.Errorf("expected changes %+v, actual: %+v", expected, changes)
+	}
+}
+
+func TestDiffRemap(t *testing.T) {
+	a := `map http://a.example.net/ http://origin-a.example.net/ @plugin=header_rewrite.so @pparam=hdr_rw_a.config
+map http://b.example.net/ http://origin-b.example.net/
+.definefilter deny_purge @action=deny @method=PURGE
+.activatefilter deny_purge
+map http://c.example.net/ http://origin-c.example.net/
+.deactivatefilter deny_purge
+`
+	reordered := `.definefilter deny_purge @action=deny @method=PURGE
+.activatefilter deny_purge
+map http://c.example.net/ http://origin-c.example.net/
+.deactivatefilter deny_purge
+map http://b.example.net/ \
+    http://origin-b.example.net/
+map http://a.example.net/ http://origin-a.example.net/ @plugin=header_rewrite.so @pparam=hdr_rw_a.config
+`
+	changes, err := Diff(FileTypeRemap, a, reordered)
+	if err != nil {
+		t.Fatalf("expected nil error, actual: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("expected reordered remap.config to have no changes, actual: %+v", changes)
+	}
+
+	filterMoved := `map http://a.example.net/ http://origin-a.example.net/ @plugin=header_rewrite.so @pparam=hdr_rw_a.config
+.definefilter deny_purge @action=deny @method=PURGE
+.activatefilter deny_purge
+map http://b.example.net/ http://origin-b.example.net/
+map http://c.example.net/ http://origin-c.example.net/
+.deactivatefilter deny_purge
+`
+	changes, err = Diff(FileTypeRemap, a, filterMoved)
+	if err != nil {
+		t.Fatalf("expected nil error, actual: %v", err)
+	}
+	expected := []Change{{
+		Type: ChangeChanged,
+		Key:  "map http://b.example.net/",
+		Old:  "map http://b.example.net/ http://origin-b.example.net/",
+		New:  "map http://b.example.net/ http://origin-b.example.net/ [active filters: deny_purge]",
+	}}
+	if !reflect.DeepEqual(expected, changes) {
+		t.Errorf("expected moving a filter activation to change the rule it now applies to %+v, actual: %+v", expected, changes)
+	}
+}
+
+func TestDiffParent(t *testing.T) {
+	a := `dest_domain=a.example.net port=80 parent="mid-01.example.net:80|0.999;mid-02.example.net:80|0.999" round_robin=consistent_hash go_direct=false qstring=ignore
+dest_domain=. parent="mid-01.example.net:80|0.999" round_robin=consistent_hash go_direct=false
+`
+	b := `dest_domain=. go_direct=false round_robin=consistent_hash parent="mid-01.example.net:80|0.999"
+port=80 dest_domain=a.example.net parent="mid-01.example.net:80|0.999;mid-02.example.net:80|0.999" round_robin=consistent_hash go_direct=true qstring=ignore
+`
+	changes, err := Diff(FileTypeParent, a, b)
+	if err != nil {
+		t.Fatalf("expected nil error, actual: %v", err)
+	}
+	expected := []Change{{
+		Type: ChangeChanged,
+		Key:  "dest_domain=a.example.net port=80",
+		Old:  `dest_domain=a.example.net port=80 go_direct=false parent="mid-01.example.net:80|0.999;mid-02.example.net:80|0.999" qstring=ignore round_robin=consistent_hash`,
+		New:  `dest_domain=a.example.net port=80 go_direct=true parent="mid-01.example.net:80|0.999;mid-02.example.net:80|0.999" qstring=ignore round_robin=consistent_hash`,
+	}}
+	if !reflect.DeepEqual(expected, changes) {
+		t.Errorf("expected changes %+v, actual: %+v", expected, changes)
+	}
+
+	if _, err := Diff(FileTypeParent, a, `dest_domain=a.example.net parent="mid-01.example.net:80`); err == nil {
+		t.Errorf("expected error for unterminated quote, actual: nil")
+	}
+}
+
+func TestDiffSNI(t *testing.T) {
+	a := `sni:
+- fqdn: a.example.net
+  http2: on
+  valid_tls_versions_in: [ TLSv1_2, TLSv1_3 ]
+- fqdn: b.example.net
+  verify_client: NONE
+`
+	b := `# reordered
+sni:
+- fqdn: b.example.net
+  verify_client: STRICT
+- valid_tls_versions_in: [ TLSv1_2, TLSv1_3 ]
+  http2: on
+  fqdn: a.example.net
+`
+	changes, err := Diff(FileTypeSNI, a, b)
+	if err != nil {
+		t.Fatalf("expected nil error, actual: %v", err)
+	}
+	expected := []Change{{
+		Type: ChangeChanged,
+		Key:  "b.example.net",
+		Old:  `{"fqdn":"b.example.net","verify_client":"NONE"}`,
+		New:  `{"fqdn":"b.example.net","verify_client":"STRICT"}`,
+	}}
+	if !reflect.DeepEqual(expected, changes) {
+		t.Errorf("expected changes %+v, actual: %+v", expected, changes)
+	}
+}
+
+func TestFileTypeOfPath(t *testing.T) {
+	if ft, ok := FileTypeOfPath("/opt/trafficserver/etc/trafficserver/remap.config"); !ok || ft != FileTypeRemap {
+		t.Errorf("expected remap.config, actual: '%s' %v", ft, ok)
+	}
+	if ft, ok := FileTypeOfPath("/opt/trafficserver/etc/trafficserver/hdr_rw_a.config"); ok {
+		t.Errorf("expected header rewrite config to not be a semantic file type, actual: '%s'", ft)
+	}
+}
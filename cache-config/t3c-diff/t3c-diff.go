@@ -20,6 +20,7 @@ package main
  */
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -27,6 +28,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/apache/trafficcontrol/v8/cache-config/t3c-diff/semdiff"
 	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
 	"github.com/apache/trafficcontrol/v8/lib/go-log"
 
@@ -53,6 +55,9 @@ func main() {
 	gid := getopt.IntLong("file-gid", 'g', 0, "Group id the file being checked should have, default is running process's gid")
 	fa := getopt.StringLong("file-a", 'a', "", "first diff file")
 	fb := getopt.StringLong("file-b", 'b', "", "second diff file")
+	semantic := getopt.BoolLong("semantic", 's', "Compare records.config, remap.config, parent.config, and sni.yaml files by their entries, ignoring their order")
+	fileTypeStr := getopt.StringLong("file-type", 0, "", "File type to compare semantically, if not the name of the file which isn't stdin")
+	jsonOutput := getopt.BoolLong("json", 'j', "Print the changes as JSON")
 	getopt.ParseV2()
 
 	log.Init(os.Stderr, os.Stderr, os.Stderr, os.Stderr, os.Stderr)
@@ -97,24 +102,32 @@ func main() {
 		os.Exit(6)
 	}
 
-	fileALines := strings.Split(string(fileA), "\n")
-	fileALines = t3cutil.UnencodeFilter(fileALines)
-	fileALines = t3cutil.CommentsFilter(fileALines, *lineComment)
-	fileA = strings.Join(fileALines, "\n")
-	fileA = t3cutil.NewLineFilter(fileA)
-
-	fileBLines := strings.Split(string(fileB), "\n")
-	fileBLines = t3cutil.UnencodeFilter(fileBLines)
-	fileBLines = t3cutil.CommentsFilter(fileBLines, *lineComment)
-	fileB = strings.Join(fileBLines, "\n")
-	fileB = t3cutil.NewLineFilter(fileB)
+	fileType := semdiff.FileType("")
+	if *semantic {
+		fileType = semanticFileType(*fileTypeStr, fileNameA, fileNameB)
+	}
+	changes, isSemantic := []semdiff.Change(nil), false
+	if fileType != "" {
+		changes, err = semdiff.Diff(fileType, fileA, fileB)
+		if err != nil {
+			log.Warnf("comparing semantically, falling back to line diff: %s\n", err.Error())
+		} else {
+			isSemantic = true
+		}
+	}
+	if !isSemantic {
+		changes = lineDiff(fileA, fileB, *lineComment)
+	}
 
-	if fileA != fileB {
-		match := regexp.MustCompile(`(?m)^\+.*|^-.*`)
-		changes := diff.Diff(fileA, fileB)
-		for _, change := range match.FindAllString(changes, -1) {
-			fmt.Println(change)
+	if *jsonOutput {
+		if err := json.NewEncoder(os.Stdout).Encode(DiffResult{Semantic: isSemantic, FileType: fileType, Changes: changes}); err != nil {
+			log.Errorf("error writing json: %s\n", err.Error())
+			os.Exit(3)
 		}
+	} else {
+		printChanges(changes)
+	}
+	if len(changes) > 0 {
 		os.Exit(1)
 	}
 	if fileAExisted != fileBExisted {
@@ -145,7 +158,7 @@ func main() {
 }
 
 const usageStr = `usage: t3c-diff [--help]
-        -a <file-a> -b <file-b> -l <line comment> -m <file mode> -u <file uid> -g <file gid>
+        -a <file-a> -b <file-b> -l <line comment> -m <file mode> -u <file uid> -g <file gid> [-s [--file-type <type>]] [-j]
 
 Either file may be 'stdin', in which case that file is read from stdin.
 Either file may not exist.
@@ -156,6 +169,12 @@ If one file exists but the other doesn't, it will always be a diff.
 Mode is file permissions in octal format, default is 0644.
 Line comment is a character that signals the line is a comment, default is #
 
+Semantic compares records.config, remap.config, parent.config, and sni.yaml files by their entries,
+reporting added, removed, and changed entries, and ignoring their order. The file type is the name
+of the file which isn't stdin, unless file-type is given. Other files are compared line-by-line.
+
+JSON prints the changes as a JSON object, rather than as lines prefixed with + and -.
+
 Uid is the User id the file being checked should have, default is running process's uid.
 Gid is the Group id the file being checked should have, default is running process's gid.
 
@@ -181,3 +200,65 @@ func readFileOrStdin(fileOrStdin string) (string, bool, error) {
 	}
 	return string(bts), true, nil
 }
+
+// DiffResult is the output of t3c-diff --json.
+type DiffResult struct {
+	// Semantic is whether the files were compared by their entries, rather than line-by-line.
+	Semantic bool `json:"semantic"`
+	// FileType is the type the files were compared as, if Semantic.
+	FileType semdiff.FileType `json:"fileType,omitempty"`
+	Changes  []semdiff.Change `json:"changes"`
+}
+
+// semanticFileType returns the type to compare the files as, from the --file-type if given, else the name of the file which isn't stdin.
+// Returns the empty string if the files can't be compared semantically.
+func semanticFileType(fileTypeStr string, fileNameA string, fileNameB string) semdiff.FileType {
+	if fileTypeStr != "" {
+		fileType, ok := semdiff.ParseFileType(fileTypeStr)
+		if !ok {
+			log.Warnln("file type '" + fileTypeStr + "' can't be compared semantically, falling back to line diff")
+		}
+		return fileType
+	}
+	for _, fileName := range []string{fileNameA, fileNameB} {
+		if strings.ToLower(fileName) == "stdin" {
+			continue
+		}
+		if fileType, ok := semdiff.FileTypeOfPath(fileName); ok {
+			return fileType
+		}
+	}
+	return ""
+}
+
+// lineDiff returns the lines removed from and added to fileA, ignoring comments and whitespace.
+func lineDiff(fileA string, fileB string, lineComment string) []semdiff.Change {
+	fileA = t3cutil.NormalizeConfig(fileA, lineComment)
+	fileB = t3cutil.NormalizeConfig(fileB, lineComment)
+
+	changes := []semdiff.Change{}
+	if fileA == fileB {
+		return changes
+	}
+	match := regexp.MustCompile(`(?m)^\+.*|^-.*`)
+	for _, change := range match.FindAllString(diff.Diff(fileA, fileB), -1) {
+		if strings.HasPrefix(change, "+") {
+			changes = append(changes, semdiff.Change{Type: semdiff.ChangeAdded, New: change[1:]})
+		} else {
+			changes = append(changes, semdiff.Change{Type: semdiff.ChangeRemoved, Old: change[1:]})
+		}
+	}
+	return changes
+}
+
+// printChanges prints the changes to stdout as lines, prefixed with - for the old entry and + for the new entry.
+func printChanges(changes []semdiff.Change) {
+	for _, change := range changes {
+		if change.Type != semdiff.ChangeAdded {
+			fmt.Println("-" + change.Old)
+		}
+		if change.Type != semdiff.ChangeRemoved {
+			fmt.Println("+" + change.New)
+		}
+	}
+}
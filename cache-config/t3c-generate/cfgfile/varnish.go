@@ -29,7 +29,6 @@ import (
 )
 
 // GetVarnishConfigs returns varnish configuration files
-// TODO: add varnishncsa and hitch configs
 func GetVarnishConfigs(toData *t3cutil.ConfigData, cfg config.Cfg) ([]t3cutil.ATSConfigFile, error) {
 	vclBuilder := varnishcfg.NewVCLBuilder(toData)
 	vcl, warnings, err := vclBuilder.BuildVCLFile()
@@ -43,8 +42,35 @@ func GetVarnishConfigs(toData *t3cutil.ConfigData, cfg config.Cfg) ([]t3cutil.AT
 		Path:        cfg.Dir,
 		ContentType: "text/plain; charset=us-ascii",
 		LineComment: "//",
+		// url_sig keys are written into the VCL
+		Secure: len(toData.URLSigKeys) > 0,
+	})
+
+	paramsTxt, paramsWarnings := varnishcfg.GetVarnishParams(toData.ServerParams, cfg.Dir)
+	logWarnings("Generating varnish params files: ", paramsWarnings)
+	configs = append(configs, t3cutil.ATSConfigFile{
+		Name:        varnishcfg.VarnishParamsFileName,
+		Text:        paramsTxt,
+		Path:        cfg.Dir,
+		ContentType: "text/plain; charset=us-ascii",
+		LineComment: "#",
 		Secure:      false,
 	})
+
+	ncsaTxt, ncsaWarnings := varnishcfg.GetVarnishncsaFormat(toData.ServerParams, toData.Server.HostName)
+	logWarnings("Generating varnishncsa configuration files: ", ncsaWarnings)
+	if ncsaTxt != "" {
+		configs = append(configs, t3cutil.ATSConfigFile{
+			Name:        varnishcfg.VarnishncsaFormatFileName,
+			Text:        ncsaTxt,
+			Path:        cfg.Dir,
+			ContentType: "text/plain; charset=us-ascii",
+			// varnishncsa reads the whole file as the format, so it can't have comments
+			LineComment: "",
+			Secure:      false,
+		})
+	}
+
 	txt, hitchWarnings := varnishcfg.GetHitchConfig(toData.DeliveryServices, filepath.Join(cfg.Dir, "ssl/"))
 	warnings = append(warnings, hitchWarnings...)
 	logWarnings("Generating hitch configuration files: ", hitchWarnings)
@@ -25,6 +25,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
 	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
@@ -63,6 +64,8 @@ type Cfg struct {
 	Version            string
 	GitRevision        string
 	Cache              string
+	ExecPlugins        []string
+	ExecPluginTimeout  time.Duration
 }
 
 func (cfg Cfg) ErrorLog() log.LogLocation   { return log.LogLocation(cfg.LogLocationErr) }
@@ -89,7 +92,9 @@ func GetCfg(appVersion string, gitRevision string) (Cfg, error) {
 	atsVersion := getopt.StringLong("ats-version", 'a', "", "The ATS version, e.g. 9.1.2-42.abc123.el7.x86_64. If omitted, generation will attempt to get the ATS version from the Server Parameters, and fall back to lib/go-atscfg.DefaultATSVersion")
 	verbosePtr := getopt.CounterLong("verbose", 'v', `Log verbosity. Logging is output to stderr. By default, errors are logged. To log warnings, pass '-v'. To log info, pass '-vv'. To omit error logging, see '-s'`)
 	silentPtr := getopt.BoolLong("silent", 's', `Silent. Errors are not logged, and the 'verbose' flag is ignored. If a fatal error occurs, the return code will be non-zero but no text will be output to stderr`)
-	cache := getopt.StringLong("cache", 'C', "ats", "Cache server type. Generate configuration files for specific cache server type, e.g. 'ats', 'varnish'.")
+	cache := getopt.StringLong("cache", 'C', "ats", "Cache server type. Generate configuration files for specific cache server type, e.g. 'ats', 'varnish', 'nginx'.")
+	execPlugins := getopt.ListLong("exec-plugin", 0, "External command to pass the generated files and Traffic Ops data to as JSON on stdin, which may write modified or additional files to stdout. May be given multiple times, or comma-delimited, and commands are run in the order given.")
+	execPluginTimeout := getopt.DurationLong("exec-plugin-timeout", 0, 30*time.Second, "Maximum time each exec plugin may run, e.g. '10s'. Default is 30s.")
 
 	const useStrategiesFlagName = "use-strategies"
 	const defaultUseStrategies = t3cutil.UseStrategiesFlagFalse
@@ -188,6 +193,14 @@ func GetCfg(appVersion string, gitRevision string) (Cfg, error) {
 		UseStrategies:      t3cutil.UseStrategiesFlag(*useStrategiesPtr),
 		GoDirect:           *goDirectPtr,
 		Cache:              *cache,
+		ExecPlugins:        *execPlugins,
+		ExecPluginTimeout:  *execPluginTimeout,
+	}
+	if len(cfg.ExecPlugins) > 0 && cfg.Cache != "ats" {
+		return Cfg{}, errors.New("exec-plugin is only supported with cache 'ats'")
+	}
+	if cfg.ExecPluginTimeout <= 0 {
+		return Cfg{}, errors.New("exec-plugin-timeout must be positive")
 	}
 	if err := log.InitCfg(cfg); err != nil {
 		return Cfg{}, errors.New("Initializing loggers: " + err.Error() + "\n")
@@ -0,0 +1,146 @@
+package plugin
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+)
+
+// DefaultExecPluginTimeout is the default maximum time a single exec plugin may run.
+const DefaultExecPluginTimeout = 30 * time.Second
+
+// ExecPluginInput is the JSON object written to the stdin of each exec plugin.
+type ExecPluginInput struct {
+	Files []t3cutil.ATSConfigFile `json:"files"`
+	Data  *t3cutil.ConfigData     `json:"data"`
+}
+
+// ExecPlugins runs the external commands in d.Cfg.ExecPlugins, in order, and returns the resulting config files.
+//
+// Each command is given an ExecPluginInput as JSON on stdin, with the files as returned by the previous command.
+// A command may write a JSON array of config files to stdout. Returned files with the same path and name
+// as an existing file replace it, and all other returned files are added. Empty output leaves the files unchanged.
+//
+// Returns an error if any command fails, times out, or writes invalid output.
+func ExecPlugins(d ModifyFilesData) ([]t3cutil.ATSConfigFile, error) {
+	timeout := d.Cfg.ExecPluginTimeout
+	if timeout <= 0 {
+		timeout = DefaultExecPluginTimeout
+	}
+	files := d.Files
+	for _, cmd := range d.Cfg.ExecPlugins {
+		log.Infoln("plugins.ExecPlugins running '" + cmd + "'")
+		returned, err := execPlugin(cmd, timeout, ExecPluginInput{Files: files, Data: d.TOData})
+		if err != nil {
+			return nil, errors.New("exec plugin '" + cmd + "': " + err.Error())
+		}
+		files = mergeFiles(files, returned)
+	}
+	return files, nil
+}
+
+// execPlugin runs a single exec plugin command, and returns the files it wrote to stdout.
+func execPlugin(command string, timeout time.Duration, input ExecPluginInput) ([]t3cutil.ATSConfigFile, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil, errors.New("empty command")
+	}
+
+	inputBts, err := json.Marshal(input)
+	if err != nil {
+		return nil, errors.New("marshalling input: " + err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd := exec.Command(fields[0], fields[1:]...)
+	cmd.Stdin = bytes.NewReader(inputBts)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// The plugin runs in its own process group, so the whole group can be killed on timeout.
+	// Killing only the plugin isn't enough, because Wait also waits for any processes it started which hold its stdout or stderr.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		return nil, errors.New("starting: " + err.Error())
+	}
+	waitErr := make(chan error, 1)
+	go func() { waitErr <- cmd.Wait() }()
+	select {
+	case err = <-waitErr:
+	case <-ctx.Done():
+		if killErr := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); killErr != nil {
+			log.Errorln("plugins.ExecPlugins '" + command + "' killing timed out process group: " + killErr.Error())
+		}
+		err = <-waitErr
+	}
+	if errStr := strings.TrimSpace(stderr.String()); errStr != "" {
+		log.Infoln("plugins.ExecPlugins '" + command + "' stderr: " + errStr)
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("timed out after %v", timeout)
+	} else if err != nil {
+		return nil, errors.New("running: " + err.Error())
+	}
+
+	if len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+		return nil, nil
+	}
+
+	files := []t3cutil.ATSConfigFile{}
+	if err := json.Unmarshal(stdout.Bytes(), &files); err != nil {
+		return nil, errors.New("parsing output: " + err.Error())
+	}
+	for _, fi := range files {
+		if fi.Name == "" {
+			return nil, errors.New("output had a file with no name")
+		}
+	}
+	return files, nil
+}
+
+// mergeFiles returns files with each of returned either replacing the file with the same path and name, or appended.
+func mergeFiles(files []t3cutil.ATSConfigFile, returned []t3cutil.ATSConfigFile) []t3cutil.ATSConfigFile {
+	idx := map[string]int{}
+	for i, fi := range files {
+		idx[path.Join(fi.Path, fi.Name)] = i
+	}
+	merged := append([]t3cutil.ATSConfigFile{}, files...)
+	for _, fi := range returned {
+		key := path.Join(fi.Path, fi.Name)
+		if i, ok := idx[key]; ok {
+			merged[i] = fi
+			continue
+		}
+		idx[key] = len(merged)
+		merged = append(merged, fi)
+	}
+	return merged
+}
@@ -0,0 +1,112 @@
+package plugin
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3c-generate/config"
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+)
+
+func writeTestScript(t *testing.T, name string, body string) string {
+	t.Helper()
+	fileName := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(fileName, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatalf("writing test script: %v", err)
+	}
+	return fileName
+}
+
+func TestExecPlugins(t *testing.T) {
+	// addFile adds a file, named after its argument, with the host name in the input data.
+	addFile := writeTestScript(t, "add.sh", `host=$(sed 's/.*"hostName":"\([^"]*\)".*/\1/')
+printf '%s' '[{"name":"'$1'","path":"/etc/trafficserver/","text":"'$host'\n"}]'
+`)
+	// replace replaces remap.config, and only succeeds if the input includes the first plugin's file.
+	replace := writeTestScript(t, "replace.sh", `grep -q '"name":"first.config"' || exit 1
+printf '%s' '[{"name":"remap.config","path":"/etc/trafficserver/","text":"replaced\n"}]'
+`)
+	noop := writeTestScript(t, "noop.sh", "cat > /dev/null\n")
+
+	d := ModifyFilesData{
+		Cfg: config.Cfg{
+			ExecPlugins:       []string{addFile + " first.config", noop, replace},
+			ExecPluginTimeout: 5 * time.Second,
+		},
+		TOData: &t3cutil.ConfigData{Server: &atscfg.Server{HostName: "myserver"}},
+		Files: []t3cutil.ATSConfigFile{
+			{Name: "remap.config", Path: "/etc/trafficserver/", Text: "original\n"},
+			{Name: "records.config", Path: "/etc/trafficserver/", Text: "records\n"},
+		},
+	}
+
+	files, err := ExecPlugins(d)
+	if err != nil {
+		t.Fatalf("ExecPlugins expected nil error, actual: %v", err)
+	}
+
+	expected := []t3cutil.ATSConfigFile{
+		{Name: "remap.config", Path: "/etc/trafficserver/", Text: "replaced\n"},
+		{Name: "records.config", Path: "/etc/trafficserver/", Text: "records\n"},
+		{Name: "first.config", Path: "/etc/trafficserver/", Text: "myserver\n"},
+	}
+	if !reflect.DeepEqual(expected, files) {
+		t.Errorf("expected files %+v, actual %+v", expected, files)
+	}
+	if d.Files[0].Text != "original\n" {
+		t.Errorf("expected input files to be unmodified, actual remap.config text '%v'", d.Files[0].Text)
+	}
+}
+
+func TestExecPluginsErrors(t *testing.T) {
+	tests := map[string]string{
+		"exit code":    "cat > /dev/null\nexit 1\n",
+		"invalid json": "cat > /dev/null\necho 'not json'\n",
+		"no name":      "cat > /dev/null\necho '[{\"path\":\"/etc/trafficserver/\"}]'\n",
+		"timeout":      "sleep 5\n",
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			script := writeTestScript(t, "plugin.sh", body)
+			d := ModifyFilesData{
+				Cfg:    config.Cfg{ExecPlugins: []string{script}, ExecPluginTimeout: 100 * time.Millisecond},
+				TOData: &t3cutil.ConfigData{},
+			}
+			start := time.Now()
+			_, err := ExecPlugins(d)
+			if err == nil {
+				t.Fatal("expected error, actual nil")
+			}
+			if name == "timeout" && !strings.Contains(err.Error(), "timed out") {
+				t.Errorf("expected timeout error, actual: %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("expected plugin and the processes it started to be killed on timeout, actual ran for %v", elapsed)
+			}
+		})
+	}
+}
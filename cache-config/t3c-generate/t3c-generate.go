@@ -99,6 +99,19 @@ func main() {
 		os.Exit(config.ExitCodeSuccess)
 	}
 
+	if cfg.Cache == "nginx" {
+		configs, err := cfgfile.GetNginxConfigs(toData, cfg)
+		if err != nil {
+			log.Errorln("Generating nginx config for '" + toData.Server.HostName + "': " + err.Error())
+			os.Exit(config.ExitCodeErrGeneric)
+		}
+		if err := cfgfile.WriteConfigs(configs, os.Stdout); err != nil {
+			log.Errorln("Writing configs for '" + toData.Server.HostName + "': " + err.Error())
+			os.Exit(config.ExitCodeErrGeneric)
+		}
+		os.Exit(config.ExitCodeSuccess)
+	}
+
 	configs, err := cfgfile.GetAllConfigs(toData, cfg)
 	if err != nil {
 		log.Errorln("Getting config for'" + toData.Server.HostName + "': " + err.Error())
@@ -108,6 +121,15 @@ func main() {
 	modifyFilesData := plugin.ModifyFilesData{Cfg: cfg, TOData: toData, Files: configs}
 	configs = plugins.ModifyFiles(modifyFilesData)
 
+	if len(cfg.ExecPlugins) > 0 {
+		modifyFilesData.Files = configs
+		configs, err = plugin.ExecPlugins(modifyFilesData)
+		if err != nil {
+			log.Errorln("Running exec plugins for '" + toData.Server.HostName + "': " + err.Error())
+			os.Exit(config.ExitCodeErrGeneric)
+		}
+	}
+
 	sort.Sort(t3cutil.ATSConfigFiles(configs))
 
 	if err := cfgfile.WriteConfigs(configs, os.Stdout); err != nil {
@@ -42,6 +42,20 @@ type Cfg struct {
 	LogLocationError string
 	LogLocationInfo  string
 	LoginDispersion  time.Duration
+
+	// ExportBundle is the path to write a signed bundle of all data for the cache to, instead of writing GetData.
+	ExportBundle string
+	// FromBundle is the path of a signed bundle to read GetData from, instead of Traffic Ops.
+	FromBundle string
+	// BundleKey is the path of the private key to sign an ExportBundle with.
+	BundleKey string
+	// BundlePublicKey is the path of the public key to verify a FromBundle with.
+	BundlePublicKey string
+
+	// CacheProxyURL is the URL of a t3c-cache-proxy to request data from, instead of the TOURL.
+	// If the proxy is unavailable, the TOURL is requested directly.
+	CacheProxyURL *url.URL
+
 	t3cutil.TCCfg
 	Version     string
 	GitRevision string
@@ -75,6 +89,11 @@ func InitConfig(appVersion string, gitRevision string) (Cfg, error) {
 	disableProxyPtr := getopt.BoolLong("traffic-ops-disable-proxy", 'p', "[true | false] whether to not use any configure Traffic Ops proxy parameter. Only used if get-data is config")
 	toPassPtr := getopt.StringLong("traffic-ops-password", 'P', "", "Traffic Ops password. Required. May also be set with the environment variable TO_PASS    ")
 	oldCfgPtr := getopt.StringLong("old-config", 'c', "", "Old config from a previous config request. Optional. May be a file path, or 'stdin' to read from stdin. Used to make conditional requests.")
+	exportBundlePtr := getopt.StringLong("export-bundle", 0, "", "Path to write a signed bundle of all Traffic Ops data for the cache to, for use with --from-bundle, instead of writing get-data. Requires --bundle-key")
+	fromBundlePtr := getopt.StringLong("from-bundle", 0, "", "Path of a signed bundle from --export-bundle to get data from, instead of Traffic Ops. Requires --bundle-public-key")
+	bundleKeyPtr := getopt.StringLong("bundle-key", 0, "", "Path of the PEM Ed25519 private key to sign the --export-bundle with")
+	bundlePublicKeyPtr := getopt.StringLong("bundle-public-key", 0, "", "Path of the PEM Ed25519 public key to verify the --from-bundle with")
+	cacheProxyURLPtr := getopt.StringLong("cache-proxy-url", 0, "", "URL of a t3c-cache-proxy to request data from, instead of Traffic Ops. If the proxy is unavailable, Traffic Ops is requested directly")
 	helpPtr := getopt.BoolLong("help", 'h', "Print usage information and exit")
 	versionPtr := getopt.BoolLong("version", 'V', "Print the app version")
 	verbosePtr := getopt.CounterLong("verbose", 'v', `Log verbosity. Logging is output to stderr. By default, errors are logged. To log warnings, pass '-v'. To log info, pass '-vv'. To omit error logging, see '-s'`)
@@ -128,11 +147,34 @@ func InitConfig(appVersion string, gitRevision string) (Cfg, error) {
 		toPass = os.Getenv("TO_PASS")
 	}
 
-	toURLParsed, err := url.Parse(toURL)
-	if err != nil {
-		return Cfg{}, errors.New("parsing Traffic Ops URL from " + urlSourceStr + " '" + toURL + "': " + err.Error())
-	} else if err := t3cutil.ValidateURL(toURLParsed); err != nil {
-		return Cfg{}, errors.New("invalid Traffic Ops URL from " + urlSourceStr + " '" + toURL + "': " + err.Error())
+	if *exportBundlePtr != "" && *fromBundlePtr != "" {
+		return Cfg{}, errors.New("only one of --export-bundle and --from-bundle may be used")
+	} else if *exportBundlePtr != "" && *bundleKeyPtr == "" {
+		return Cfg{}, errors.New("--export-bundle requires --bundle-key")
+	} else if *fromBundlePtr != "" && *bundlePublicKeyPtr == "" {
+		return Cfg{}, errors.New("--from-bundle requires --bundle-public-key")
+	}
+
+	// Traffic Ops isn't used when getting data from a bundle, so the URL isn't required.
+	toURLParsed := (*url.URL)(nil)
+	err := error(nil)
+	if *fromBundlePtr == "" {
+		toURLParsed, err = url.Parse(toURL)
+		if err != nil {
+			return Cfg{}, errors.New("parsing Traffic Ops URL from " + urlSourceStr + " '" + toURL + "': " + err.Error())
+		} else if err := t3cutil.ValidateURL(toURLParsed); err != nil {
+			return Cfg{}, errors.New("invalid Traffic Ops URL from " + urlSourceStr + " '" + toURL + "': " + err.Error())
+		}
+	}
+
+	cacheProxyURL := (*url.URL)(nil)
+	if *cacheProxyURLPtr != "" {
+		cacheProxyURL, err = url.Parse(*cacheProxyURLPtr)
+		if err != nil {
+			return Cfg{}, errors.New("parsing cache proxy URL '" + *cacheProxyURLPtr + "': " + err.Error())
+		} else if err := t3cutil.ValidateURL(cacheProxyURL); err != nil {
+			return Cfg{}, errors.New("invalid cache proxy URL '" + *cacheProxyURLPtr + "': " + err.Error())
+		}
 	}
 
 	var cacheHostName string
@@ -152,6 +194,11 @@ func InitConfig(appVersion string, gitRevision string) (Cfg, error) {
 		LogLocationInfo:  logLocationInfo,
 		LogLocationWarn:  logLocationWarn,
 		LoginDispersion:  dispersion,
+		ExportBundle:     *exportBundlePtr,
+		FromBundle:       *fromBundlePtr,
+		BundleKey:        *bundleKeyPtr,
+		BundlePublicKey:  *bundlePublicKeyPtr,
+		CacheProxyURL:    cacheProxyURL,
 		TCCfg: t3cutil.TCCfg{
 			CacheHostName:  cacheHostName,
 			GetData:        *getDataPtr,
@@ -197,6 +244,9 @@ func (cfg Cfg) PrintConfig() {
 	log.Debugf("TOUser: %s\n", cfg.TOUser)
 	log.Debugf("TOPass: xxxxxx\n")
 	log.Debugf("TOURL: %s\n", cfg.TOURL)
+	log.Debugf("ExportBundle: %s\n", cfg.ExportBundle)
+	log.Debugf("FromBundle: %s\n", cfg.FromBundle)
+	log.Debugf("CacheProxyURL: %s\n", cfg.CacheProxyURL)
 }
 
 func LoadOldCfg(path string) (*t3cutil.ConfigData, error) {
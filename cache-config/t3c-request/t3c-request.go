@@ -20,8 +20,14 @@ package main
  */
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"sync/atomic"
+	"time"
 
 	"github.com/apache/trafficcontrol/v8/cache-config/t3c-request/config"
 	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
@@ -46,15 +52,17 @@ func main() {
 	}
 	log.Infoln("configuration initialized")
 
+	if cfg.FromBundle != "" {
+		if err := writeBundleData(cfg); err != nil {
+			log.Errorf("writing data from bundle: %s\n", err.Error())
+			os.Exit(3)
+		}
+		return
+	}
+
 	// login to traffic ops.
-	cfg.TCCfg.TOClient, err = toreq.New(
-		cfg.TOURL,
-		cfg.TOUser,
-		cfg.TOPass,
-		cfg.TOInsecure,
-		cfg.TOTimeoutMS,
-		cfg.UserAgent(),
-	)
+	usingCacheProxy := false
+	cfg.TCCfg.TOClient, usingCacheProxy, err = login(cfg)
 	if err != nil {
 		log.Errorf("%s\n", err)
 		os.Exit(2)
@@ -63,11 +71,148 @@ func main() {
 		log.Warnln("Traffic Ops does not support the latest version supported by this app! Falling back to previous major Traffic Ops API version!")
 	}
 
-	if cfg.GetData != "" {
-		if err := t3cutil.WriteData(cfg.TCCfg); err != nil {
-			log.Errorf("writing data: %s\n", err.Error())
-			os.Exit(3)
+	if !usingCacheProxy {
+		err = getData(cfg, false)
+	} else {
+		// The cache proxy already caches Traffic Ops, so it must not be replaced by a Traffic Ops proxy parameter.
+		disableProxy := cfg.TCCfg.TODisableProxy
+		cfg.TCCfg.TODisableProxy = true
+		failures := watchProxyFailures(cfg.TCCfg.TOClient.HTTPClient())
+		err = getData(cfg, true)
+		if err != nil && failures.failed() {
+			log.Warnln("getting data from cache proxy '" + cfg.CacheProxyURL.String() + "' failed, falling back to Traffic Ops: " + err.Error())
+			cfg.TCCfg.TODisableProxy = disableProxy
+			if cfg.TCCfg.TOClient, err = toreq.New(cfg.TOURL, cfg.TOUser, cfg.TOPass, cfg.TOInsecure, cfg.TOTimeoutMS, cfg.UserAgent()); err != nil {
+				log.Errorf("%s\n", err)
+				os.Exit(2)
+			}
+			err = getData(cfg, false)
 		}
 	}
+	if err != nil {
+		log.Errorf("%s\n", err)
+		os.Exit(3)
+	}
 	cfg.TCCfg.TOClient.WriteFsCookie(torequtil.CookieCachePath(cfg.TOUser))
 }
+
+// getData exports the bundle or writes the data requested by cfg, if any.
+// If buffered, data is only written to stdout once all of it was gotten, so a failed request may be retried without writing partial data.
+func getData(cfg config.Cfg, buffered bool) error {
+	if cfg.ExportBundle != "" {
+		if err := exportBundle(cfg); err != nil {
+			return errors.New("exporting bundle: " + err.Error())
+		}
+		return nil
+	}
+	if cfg.GetData == "" {
+		return nil
+	}
+	if !buffered {
+		if err := t3cutil.WriteData(cfg.TCCfg); err != nil {
+			return errors.New("writing data: " + err.Error())
+		}
+		return nil
+	}
+	buf := &bytes.Buffer{}
+	if err := t3cutil.WriteDataTo(cfg.TCCfg, buf); err != nil {
+		return errors.New("writing data: " + err.Error())
+	}
+	if _, err := io.Copy(os.Stdout, buf); err != nil {
+		return errors.New("writing data: " + err.Error())
+	}
+	return nil
+}
+
+// proxyFailures is an http.RoundTripper which records whether any request failed, or got a server error response.
+// Either means the cache proxy, or Traffic Ops behind it, is unavailable, so Traffic Ops should be requested directly.
+type proxyFailures struct {
+	rt        http.RoundTripper
+	numFailed int32 // accessed atomically
+}
+
+// watchProxyFailures wraps the transport of the client, so failures of its requests are recorded in the returned proxyFailures.
+func watchProxyFailures(client *http.Client) *proxyFailures {
+	rt := client.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	failures := &proxyFailures{rt: rt}
+	client.Transport = failures
+	return failures
+}
+
+func (f *proxyFailures) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := f.rt.RoundTrip(req)
+	if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+		atomic.AddInt32(&f.numFailed, 1)
+	}
+	return resp, err
+}
+
+func (f *proxyFailures) failed() bool {
+	return atomic.LoadInt32(&f.numFailed) != 0
+}
+
+// login logs in to Traffic Ops, through the cache proxy if one is configured.
+// If the cache proxy can't be logged in to, Traffic Ops is logged in to directly.
+// Returns the client, whether it is using the cache proxy, and any error.
+func login(cfg config.Cfg) (*toreq.TOClient, bool, error) {
+	if cfg.CacheProxyURL != nil {
+		toClient, err := toreq.New(cfg.CacheProxyURL, cfg.TOUser, cfg.TOPass, cfg.TOInsecure, cfg.TOTimeoutMS, cfg.UserAgent())
+		if err == nil {
+			log.Infoln("using cache proxy '" + cfg.CacheProxyURL.String() + "'")
+			return toClient, true, nil
+		}
+		log.Warnln("logging in to cache proxy '" + cfg.CacheProxyURL.String() + "' failed, falling back to Traffic Ops: " + err.Error())
+	}
+	toClient, err := toreq.New(cfg.TOURL, cfg.TOUser, cfg.TOPass, cfg.TOInsecure, cfg.TOTimeoutMS, cfg.UserAgent())
+	return toClient, false, err
+}
+
+// exportBundle gets all data for the cache from Traffic Ops, and writes it as a signed bundle to cfg.ExportBundle.
+func exportBundle(cfg config.Cfg) error {
+	key, err := t3cutil.LoadBundlePrivateKey(cfg.BundleKey)
+	if err != nil {
+		return errors.New("loading bundle key: " + err.Error())
+	}
+	bundle, err := t3cutil.MakeBundle(cfg.TCCfg)
+	if err != nil {
+		return errors.New("getting bundle data: " + err.Error())
+	}
+	fi, err := os.OpenFile(cfg.ExportBundle, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.New("opening bundle file '" + cfg.ExportBundle + "': " + err.Error())
+	}
+	if err := t3cutil.WriteBundle(bundle, key, fi); err != nil {
+		fi.Close()
+		return err
+	}
+	if err := fi.Close(); err != nil {
+		return errors.New("closing bundle file '" + cfg.ExportBundle + "': " + err.Error())
+	}
+	log.Infoln("wrote bundle '" + cfg.ExportBundle + "'")
+	return nil
+}
+
+// writeBundleData verifies the bundle cfg.FromBundle, and writes its cfg.GetData to stdout.
+func writeBundleData(cfg config.Cfg) error {
+	key, err := t3cutil.LoadBundlePublicKey(cfg.BundlePublicKey)
+	if err != nil {
+		return errors.New("loading bundle public key: " + err.Error())
+	}
+	fi, err := os.Open(cfg.FromBundle)
+	if err != nil {
+		return errors.New("opening bundle file '" + cfg.FromBundle + "': " + err.Error())
+	}
+	defer fi.Close()
+	bundle, err := t3cutil.ReadBundle(fi, key)
+	if err != nil {
+		return errors.New("reading bundle '" + cfg.FromBundle + "': " + err.Error())
+	}
+	if bundle.CacheHostName != cfg.CacheHostName {
+		return errors.New("bundle '" + cfg.FromBundle + "' is for cache '" + bundle.CacheHostName + "', not '" + cfg.CacheHostName + "'")
+	}
+	log.Infof("using bundle '%s' created %s by t3c %s\n", cfg.FromBundle, bundle.Created.Format(time.RFC3339), bundle.T3CVersion)
+	return t3cutil.WriteBundleData(bundle, cfg.GetData, os.Stdout)
+}
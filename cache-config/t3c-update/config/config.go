@@ -20,6 +20,7 @@ package config
  */
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/url"
@@ -28,6 +29,7 @@ import (
 
 	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
 	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
 	"github.com/pborman/getopt/v2"
 )
 
@@ -47,6 +49,12 @@ type Cfg struct {
 	RevalApplyTime   *time.Time
 	ConfigApplyBool  *bool
 	RevalApplyBool   *bool
+	// ConfigUpdateFailed is whether to set that the server failed to apply its config update, or nil to not set it.
+	ConfigUpdateFailed *bool
+	// RevalUpdateFailed is whether to set that the server failed to apply its revalidate update, or nil to not set it.
+	RevalUpdateFailed *bool
+	// ConfigDrift is the server's drifted config files to set, read from stdin, or nil to not set the config drift.
+	ConfigDrift []tc.ConfigDriftFile
 	t3cutil.TCCfg
 	Version     string
 	GitRevision string
@@ -61,6 +69,13 @@ func (cfg Cfg) InfoLog() log.LogLocation    { return log.LogLocation(cfg.LogLoca
 func (cfg Cfg) WarningLog() log.LogLocation { return log.LogLocation(cfg.LogLocationWarn) }
 func (cfg Cfg) EventLog() log.LogLocation   { return log.LogLocation(log.LogLocationNull) } // event logging is not used.
 
+// SetsUpdateStatus returns whether any of the server's update statuses are set.
+func (cfg Cfg) SetsUpdateStatus() bool {
+	return cfg.ConfigApplyTime != nil || cfg.RevalApplyTime != nil ||
+		cfg.ConfigUpdateFailed != nil || cfg.RevalUpdateFailed != nil ||
+		cfg.ConfigApplyBool != nil || cfg.RevalApplyBool != nil
+}
+
 // Usage() writes command line options and usage to 'stderr'
 func Usage() {
 	getopt.PrintUsage(os.Stderr)
@@ -75,6 +90,12 @@ func InitConfig(appVersion string, gitRevision string) (Cfg, error) {
 	configApplyTimeStringPtr := getopt.StringLong(setConfigApplyTimeFlagName, 'q', "", "[RFC3339Nano Timestamp] sets the server's config apply time")
 	const setRevalApplyTimeFlagName = "set-reval-apply-time"
 	revalApplyTimeStringPtr := getopt.StringLong(setRevalApplyTimeFlagName, 'a', "", "[RFC3339Nano Timestamp] sets the server's reval apply time")
+	const setConfigUpdateFailedFlagName = "set-config-update-failed"
+	configUpdateFailedPtr := getopt.BoolLong(setConfigUpdateFailedFlagName, 0, "[true | false] sets whether the server failed to apply its config update")
+	const setRevalUpdateFailedFlagName = "set-reval-update-failed"
+	revalUpdateFailedPtr := getopt.BoolLong(setRevalUpdateFailedFlagName, 0, "[true | false] sets whether the server failed to apply its reval update")
+	const setConfigDriftFlagName = "set-config-drift"
+	configDriftPtr := getopt.BoolLong(setConfigDriftFlagName, 0, "sets the server's config drift to the JSON array of drifted config files read from stdin")
 	toInsecurePtr := getopt.BoolLong("traffic-ops-insecure", 'I', "[true | false] ignore certificate errors from Traffic Ops")
 	toTimeoutMSPtr := getopt.IntLong("traffic-ops-timeout-milliseconds", 't', 30000, "Timeout in milli-seconds for Traffic Ops requests, default is 30000")
 	toURLPtr := getopt.StringLong("traffic-ops-url", 'u', "", "Traffic Ops URL. Must be the full URL, including the scheme. Required. May also be set with     the environment variable TO_URL")
@@ -104,8 +125,10 @@ func InitConfig(appVersion string, gitRevision string) (Cfg, error) {
 
 	// Verify at least one flag is passed
 	if (!getopt.IsSet(setConfigApplyTimeFlagName) && !getopt.IsSet(setRevalApplyTimeFlagName)) &&
-		(!getopt.IsSet(setConfigApplyBoolFlagName) && !getopt.IsSet(setRevalApplyBoolFlagName)) { // TODO: Remove once ATC (v7.0+) is deployed
-		fmt.Printf("Must set either %s or %s. One is at least required.\n", setConfigApplyTimeFlagName, setRevalApplyTimeFlagName)
+		(!getopt.IsSet(setConfigUpdateFailedFlagName) && !getopt.IsSet(setRevalUpdateFailedFlagName)) &&
+		(!getopt.IsSet(setConfigApplyBoolFlagName) && !getopt.IsSet(setRevalApplyBoolFlagName)) && // TODO: Remove once ATC (v7.0+) is deployed
+		!*configDriftPtr {
+		fmt.Printf("Must set either %s, %s, %s, %s or %s. One is at least required.\n", setConfigApplyTimeFlagName, setRevalApplyTimeFlagName, setConfigUpdateFailedFlagName, setRevalUpdateFailedFlagName, setConfigDriftFlagName)
 		os.Exit(0)
 	}
 
@@ -126,6 +149,22 @@ func InitConfig(appVersion string, gitRevision string) (Cfg, error) {
 		revalApplyTimePtr = &parsed
 	}
 
+	var configUpdateFailed, revalUpdateFailed *bool
+	if getopt.IsSet(setConfigUpdateFailedFlagName) {
+		configUpdateFailed = configUpdateFailedPtr
+	}
+	if getopt.IsSet(setRevalUpdateFailedFlagName) {
+		revalUpdateFailed = revalUpdateFailedPtr
+	}
+
+	var configDrift []tc.ConfigDriftFile
+	if *configDriftPtr {
+		configDrift = []tc.ConfigDriftFile{}
+		if err := json.NewDecoder(os.Stdin).Decode(&configDrift); err != nil {
+			return Cfg{}, errors.New("reading config drift from stdin: " + err.Error())
+		}
+	}
+
 	// TODO: Remove once ATC (v7.0+) is deployed
 	var configApplyBoolPtr, revalApplyBoolPtr *bool
 	if getopt.IsSet(setConfigApplyBoolFlagName) {
@@ -211,16 +250,19 @@ func InitConfig(appVersion string, gitRevision string) (Cfg, error) {
 	}
 
 	cfg := Cfg{
-		CommandArgs:      getopt.Args(),
-		LogLocationDebug: logLocationDebug,
-		LogLocationError: logLocationError,
-		LogLocationInfo:  logLocationInfo,
-		LogLocationWarn:  logLocationWarn,
-		LoginDispersion:  dispersion,
-		ConfigApplyTime:  configApplyTimePtr,
-		RevalApplyTime:   revalApplyTimePtr,
-		ConfigApplyBool:  configApplyBoolPtr,
-		RevalApplyBool:   revalApplyBoolPtr,
+		CommandArgs:        getopt.Args(),
+		LogLocationDebug:   logLocationDebug,
+		LogLocationError:   logLocationError,
+		LogLocationInfo:    logLocationInfo,
+		LogLocationWarn:    logLocationWarn,
+		LoginDispersion:    dispersion,
+		ConfigApplyTime:    configApplyTimePtr,
+		RevalApplyTime:     revalApplyTimePtr,
+		ConfigApplyBool:    configApplyBoolPtr,
+		RevalApplyBool:     revalApplyBoolPtr,
+		ConfigUpdateFailed: configUpdateFailed,
+		RevalUpdateFailed:  revalUpdateFailed,
+		ConfigDrift:        configDrift,
 		TCCfg: t3cutil.TCCfg{
 			CacheHostName: cacheHostName,
 			GetData:       "update-status",
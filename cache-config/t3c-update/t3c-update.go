@@ -65,9 +65,20 @@ func main() {
 		log.Warnln("Traffic Ops does not support the latest version supported by this app! Falling back to previous major Traffic Ops API version!")
 	}
 
+	if cfg.ConfigDrift != nil {
+		if err := t3cutil.SetConfigDrift(cfg.TCCfg, tc.CacheName(cfg.TCCfg.CacheHostName), cfg.ConfigDrift); err != nil {
+			log.Errorf("%s, %s\n", err, cfg.TCCfg.CacheHostName)
+			os.Exit(5)
+		}
+		if !cfg.SetsUpdateStatus() {
+			cfg.TCCfg.TOClient.WriteFsCookie(torequtil.CookieCachePath(cfg.TOUser))
+			os.Exit(0)
+		}
+	}
+
 	// *** Compatability requirement until ATC (v7.0+) is deployed with the timestamp features
 	// Use SetUpdateStatus is preferred
-	err = t3cutil.SetUpdateStatus(cfg.TCCfg, tc.CacheName(cfg.TCCfg.CacheHostName), cfg.ConfigApplyTime, cfg.RevalApplyTime)
+	err = t3cutil.SetUpdateStatus(cfg.TCCfg, tc.CacheName(cfg.TCCfg.CacheHostName), cfg.ConfigApplyTime, cfg.RevalApplyTime, cfg.ConfigUpdateFailed, cfg.RevalUpdateFailed)
 	//err = t3cutil.SetUpdateStatusCompat(cfg.TCCfg, tc.CacheName(cfg.TCCfg.CacheHostName), cfg.ConfigApplyTime, cfg.RevalApplyTime, cfg.ConfigApplyBool, cfg.RevalApplyBool)
 	if err != nil {
 		log.Errorf("%s, %s\n", err, cfg.TCCfg.CacheHostName)
@@ -59,6 +59,9 @@ const (
 	// ActionLogActionATSReload is calling service restart on ATS.
 	ActionLogActionATSRestart = ActionLogAction("ats-restart")
 
+	// ActionLogActionRollback is restoring the config files replaced by the run, after the ATS reload or restart failed.
+	ActionLogActionRollback = ActionLogAction("rollback")
+
 	// ActionLogActionApplyEnd is the end of the t3c-apply run.
 	ActionLogActionApplyEnd = ActionLogAction("apply-end")
 )
@@ -0,0 +1,187 @@
+package t3cutil
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+)
+
+// BundleFormatVersion is the version of the bundle format written by this app.
+// It must be incremented whenever the Bundle changes in a way older apps can't read.
+const BundleFormatVersion = 1
+
+// Bundle is all the Traffic Ops data needed to generate and apply config for a single cache,
+// so config can be generated and applied without connecting to Traffic Ops.
+type Bundle struct {
+	// FormatVersion is the BundleFormatVersion of the app which created the bundle.
+	FormatVersion int `json:"format_version"`
+
+	// T3CVersion is the version of the t3c app which created the bundle.
+	T3CVersion string `json:"t3c_version"`
+
+	// CacheHostName is the cache the bundle was created for.
+	CacheHostName string `json:"cache_host_name"`
+
+	// Created is when the bundle's data was fetched from Traffic Ops.
+	Created time.Time `json:"created"`
+
+	// Data is the output of each GetDataFuncs request, keyed by the request name.
+	Data map[string]json.RawMessage `json:"data"`
+}
+
+// SignedBundle is the serialized form of a Bundle, with the signature of the serialized Bundle.
+type SignedBundle struct {
+	Bundle    json.RawMessage `json:"bundle"`
+	Signature []byte          `json:"signature"`
+}
+
+// MakeBundle gets all data from Traffic Ops for every GetDataFuncs request, for cfg.CacheHostName.
+// The cfg.OldCfg is not used, because the bundle must have all config data, not just what changed.
+func MakeBundle(cfg TCCfg) (*Bundle, error) {
+	cfg.OldCfg = nil
+	cfg.RevalOnly = false
+	bundle := &Bundle{
+		FormatVersion: BundleFormatVersion,
+		T3CVersion:    cfg.T3CVersion,
+		CacheHostName: cfg.CacheHostName,
+		Created:       time.Now(),
+		Data:          map[string]json.RawMessage{},
+	}
+	for name, dataF := range GetDataFuncs() {
+		log.Infoln("Getting bundle data '" + name + "'")
+		buf := &bytes.Buffer{}
+		if err := dataF(cfg, buf); err != nil {
+			return nil, errors.New("getting '" + name + "': " + err.Error())
+		}
+		bundle.Data[name] = json.RawMessage(bytes.TrimSpace(buf.Bytes()))
+	}
+	return bundle, nil
+}
+
+// WriteBundle serializes the bundle, signs it with key, and writes the SignedBundle to output.
+func WriteBundle(bundle *Bundle, key ed25519.PrivateKey, output io.Writer) error {
+	bundleBts, err := json.Marshal(bundle)
+	if err != nil {
+		return errors.New("encoding bundle: " + err.Error())
+	}
+	signed := SignedBundle{Bundle: bundleBts, Signature: ed25519.Sign(key, bundleBts)}
+	if err := json.NewEncoder(output).Encode(signed); err != nil {
+		return errors.New("encoding signed bundle: " + err.Error())
+	}
+	return nil
+}
+
+// ReadBundle reads a SignedBundle from input, verifies its signature with key, and returns the Bundle.
+// Returns an error if the signature is invalid, or the bundle has a format version this app can't read.
+func ReadBundle(input io.Reader, key ed25519.PublicKey) (*Bundle, error) {
+	signed := SignedBundle{}
+	if err := json.NewDecoder(input).Decode(&signed); err != nil {
+		return nil, errors.New("decoding signed bundle: " + err.Error())
+	}
+	if len(signed.Bundle) == 0 {
+		return nil, errors.New("signed bundle has no bundle")
+	}
+	if !ed25519.Verify(key, signed.Bundle, signed.Signature) {
+		return nil, errors.New("bundle signature is invalid")
+	}
+	bundle := &Bundle{}
+	if err := json.Unmarshal(signed.Bundle, bundle); err != nil {
+		return nil, errors.New("decoding bundle: " + err.Error())
+	}
+	if bundle.FormatVersion != BundleFormatVersion {
+		return nil, fmt.Errorf("bundle format version %v is not supported, expected %v", bundle.FormatVersion, BundleFormatVersion)
+	}
+	return bundle, nil
+}
+
+// WriteBundleData writes the bundle's data for the GetDataFuncs request getData to output.
+// This is the same as WriteData, but from the bundle instead of Traffic Ops.
+func WriteBundleData(bundle *Bundle, getData string, output io.Writer) error {
+	if _, ok := GetDataFuncs()[getData]; !ok {
+		return errors.New("unknown data request '" + getData + "'")
+	}
+	data, ok := bundle.Data[getData]
+	if !ok {
+		return errors.New("bundle has no data '" + getData + "'")
+	}
+	if _, err := output.Write(append(data, '\n')); err != nil {
+		return errors.New("writing bundle data '" + getData + "': " + err.Error())
+	}
+	return nil
+}
+
+// LoadBundlePrivateKey loads the PEM-encoded PKCS #8 Ed25519 private key at path, used to sign bundles.
+// Such a key may be created with 'openssl genpkey -algorithm ed25519'.
+func LoadBundlePrivateKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEMFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.New("parsing bundle private key '" + path + "': " + err.Error())
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("bundle private key '%s' must be Ed25519, was %T", path, key)
+	}
+	return edKey, nil
+}
+
+// LoadBundlePublicKey loads the PEM-encoded PKIX Ed25519 public key at path, used to verify bundles.
+// Such a key may be created from the private key with 'openssl pkey -pubout'.
+func LoadBundlePublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEMFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.New("parsing bundle public key '" + path + "': " + err.Error())
+	}
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("bundle public key '%s' must be Ed25519, was %T", path, key)
+	}
+	return edKey, nil
+}
+
+func readPEMFile(path string) (*pem.Block, error) {
+	bts, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.New("reading key file '" + path + "': " + err.Error())
+	}
+	block, _ := pem.Decode(bts)
+	if block == nil {
+		return nil, errors.New("key file '" + path + "' has no PEM data")
+	}
+	return block, nil
+}
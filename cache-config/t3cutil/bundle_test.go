@@ -0,0 +1,156 @@
+package t3cutil
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func makeTestBundle() *Bundle {
+	return &Bundle{
+		FormatVersion: BundleFormatVersion,
+		T3CVersion:    "test",
+		CacheHostName: "edge0",
+		Created:       time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Data: map[string]json.RawMessage{
+			"config":        json.RawMessage(`{"version":"test"}`),
+			"update-status": json.RawMessage(`{"upd_pending":true}`),
+		},
+	}
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := WriteBundle(makeTestBundle(), priv, buf); err != nil {
+		t.Fatalf("writing bundle: %v", err)
+	}
+
+	bundle, err := ReadBundle(bytes.NewReader(buf.Bytes()), pub)
+	if err != nil {
+		t.Fatalf("reading bundle: %v", err)
+	}
+	if bundle.CacheHostName != "edge0" || !bundle.Created.Equal(makeTestBundle().Created) {
+		t.Errorf("expected bundle for edge0 created %v, actual %+v", makeTestBundle().Created, bundle)
+	}
+
+	out := &bytes.Buffer{}
+	if err := WriteBundleData(bundle, "update-status", out); err != nil {
+		t.Fatalf("writing bundle data: %v", err)
+	}
+	if actual := strings.TrimSpace(out.String()); actual != `{"upd_pending":true}` {
+		t.Errorf("expected update-status data, actual '%s'", actual)
+	}
+	if err := WriteBundleData(bundle, "packages", out); err == nil {
+		t.Errorf("expected error writing data missing from bundle, actual nil")
+	}
+	if err := WriteBundleData(bundle, "bogus", out); err == nil {
+		t.Errorf("expected error writing unknown data, actual nil")
+	}
+}
+
+func TestBundleVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := WriteBundle(makeTestBundle(), priv, buf); err != nil {
+		t.Fatalf("writing bundle: %v", err)
+	}
+	if _, err := ReadBundle(bytes.NewReader(buf.Bytes()), otherPub); err == nil {
+		t.Errorf("expected error reading bundle signed with a different key, actual nil")
+	}
+
+	tampered := bytes.Replace(buf.Bytes(), []byte("edge0"), []byte("edge1"), 1)
+	if _, err := ReadBundle(bytes.NewReader(tampered), pub); err == nil {
+		t.Errorf("expected error reading tampered bundle, actual nil")
+	}
+
+	future := makeTestBundle()
+	future.FormatVersion = BundleFormatVersion + 1
+	buf.Reset()
+	if err := WriteBundle(future, priv, buf); err != nil {
+		t.Fatalf("writing bundle: %v", err)
+	}
+	if _, err := ReadBundle(bytes.NewReader(buf.Bytes()), pub); err == nil {
+		t.Errorf("expected error reading bundle with unsupported format version, actual nil")
+	}
+}
+
+func TestLoadBundleKeys(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("marshalling private key: %v", err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatalf("marshalling public key: %v", err)
+	}
+
+	dir := t.TempDir()
+	privPath := filepath.Join(dir, "bundle.key")
+	pubPath := filepath.Join(dir, "bundle.pub")
+	if err := ioutil.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0600); err != nil {
+		t.Fatalf("writing private key: %v", err)
+	}
+	if err := ioutil.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0600); err != nil {
+		t.Fatalf("writing public key: %v", err)
+	}
+
+	loadedPriv, err := LoadBundlePrivateKey(privPath)
+	if err != nil {
+		t.Fatalf("loading private key: %v", err)
+	} else if !loadedPriv.Equal(priv) {
+		t.Errorf("expected loaded private key to equal generated key")
+	}
+	loadedPub, err := LoadBundlePublicKey(pubPath)
+	if err != nil {
+		t.Fatalf("loading public key: %v", err)
+	} else if !loadedPub.Equal(pub) {
+		t.Errorf("expected loaded public key to equal generated key")
+	}
+	if _, err := LoadBundlePublicKey(privPath); err == nil {
+		t.Errorf("expected error loading private key as public key, actual nil")
+	}
+}
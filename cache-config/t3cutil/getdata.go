@@ -80,12 +80,17 @@ func GetServerUpdateStatus(cfg TCCfg) (*atscfg.ServerUpdateStatus, error) {
 }
 
 func WriteData(cfg TCCfg) error {
+	return WriteDataTo(cfg, os.Stdout)
+}
+
+// WriteDataTo writes the data requested by cfg.GetData to output.
+func WriteDataTo(cfg TCCfg, output io.Writer) error {
 	log.Infoln("Getting data '" + cfg.GetData + "'")
 	dataF, ok := GetDataFuncs()[cfg.GetData]
 	if !ok {
 		return errors.New("unknown data request '" + cfg.GetData + "'")
 	}
-	return dataF(cfg, os.Stdout)
+	return dataF(cfg, output)
 }
 
 const SystemInfoParamConfigFile = `global`
@@ -237,15 +242,25 @@ type ChkConfigEntry struct {
 }
 
 // SetUpdateStatus sets the queue and reval status of serverName in Traffic Ops.
-func SetUpdateStatus(cfg TCCfg, serverName tc.CacheName, configApply, revalApply *time.Time) error {
+// The configUpdateFailed and revalUpdateFailed may be nil, to not set whether the update failed.
+func SetUpdateStatus(cfg TCCfg, serverName tc.CacheName, configApply, revalApply *time.Time, configUpdateFailed, revalUpdateFailed *bool) error {
 	// TODO need to move to toreq, add fallback
-	reqInf, err := cfg.TOClient.SetServerUpdateStatus(serverName, configApply, revalApply)
+	reqInf, err := cfg.TOClient.SetServerUpdateStatus(serverName, configApply, revalApply, configUpdateFailed, revalUpdateFailed)
 	if err != nil {
 		return errors.New("setting update statuses (Traffic Ops '" + torequtil.MaybeIPStr(reqInf.RemoteAddr) + "'): " + err.Error())
 	}
 	return nil
 }
 
+// SetConfigDrift sets the config drift of serverName in Traffic Ops to the given drifted files.
+func SetConfigDrift(cfg TCCfg, serverName tc.CacheName, files []tc.ConfigDriftFile) error {
+	reqInf, err := cfg.TOClient.SetServerConfigDrift(serverName, files)
+	if err != nil {
+		return errors.New("setting config drift (Traffic Ops '" + torequtil.MaybeIPStr(reqInf.RemoteAddr) + "'): " + err.Error())
+	}
+	return nil
+}
+
 // SetUpdateStatusCompat sets the queue and reval status of serverName in Traffic Ops.
 // *** Compatability requirement until ATC (v7.0+) is deployed with the timestamp features
 /*func SetUpdateStatusCompat(cfg TCCfg, serverName tc.CacheName, configApply, revalApply *time.Time, configApplyBool, revalApplyBool *bool) error {
@@ -131,6 +131,18 @@ func NewLineFilter(str string) string {
 	return strings.TrimSpace(str)
 }
 
+// NormalizeConfig returns the text of a config file with whitespace, HTML
+// escapes, comment lines and line endings normalized, so config files which
+// only differ in those can be compared equal. If lineComment is empty, no lines
+// are treated as comments.
+func NormalizeConfig(body string, lineComment string) string {
+	lines := UnencodeFilter(strings.Split(body, "\n"))
+	if lineComment != "" {
+		lines = CommentsFilter(lines, lineComment)
+	}
+	return NewLineFilter(strings.Join(lines, "\n"))
+}
+
 // ReadFile reads a file and returns the
 // file contents.
 func ReadFile(f string) []byte {
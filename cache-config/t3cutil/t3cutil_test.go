@@ -70,3 +70,21 @@ func TestSortAndCombineStrs(t *testing.T) {
 		}
 	}
 }
+
+func TestNormalizeConfig(t *testing.T) {
+	expected := "map http://a.example.net http://origin.example.net\ndest_domain=. go_direct=true"
+	inputs := []string{
+		"# DO NOT EDIT\nmap http://a.example.net http://origin.example.net\ndest_domain=. go_direct=true\n",
+		"map  http://a.example.net\thttp://origin.example.net\r\n# comment\r\n  dest_domain=. go_direct=true  \r\n\r\n",
+		"map http://a.example.net http://origin.example.net\ndest_domain=. go_direct=true",
+	}
+	for _, input := range inputs {
+		if actual := NormalizeConfig(input, "#"); actual != expected {
+			t.Errorf("expected normalized %q to be %q, actual %q", input, expected, actual)
+		}
+	}
+
+	if actual := NormalizeConfig("# not a comment\n", ""); actual != "# not a comment" {
+		t.Errorf("expected no lines to be comments with an empty line comment, actual %q", actual)
+	}
+}
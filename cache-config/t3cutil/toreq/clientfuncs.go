@@ -764,8 +764,16 @@ func (cl *TOClient) GetServerUpdateStatus(cacheHostName tc.CacheName, reqHdr htt
 }
 
 // SetServerUpdateStatus sets the server's update and reval statuses in Traffic Ops.
-func (cl *TOClient) SetServerUpdateStatus(cacheHostName tc.CacheName, configApply, revalApply *time.Time) (toclientlib.ReqInf, error) {
+// The configUpdateFailed and revalUpdateFailed may be nil, to not set whether the update failed.
+// Setting whether an update failed is not supported by older Traffic Ops APIs.
+func (cl *TOClient) SetServerUpdateStatus(cacheHostName tc.CacheName, configApply, revalApply *time.Time, configUpdateFailed, revalUpdateFailed *bool) (toclientlib.ReqInf, error) {
 	if cl.c == nil {
+		if configUpdateFailed != nil || revalUpdateFailed != nil {
+			if configApply == nil && revalApply == nil {
+				return toclientlib.ReqInf{}, errors.New("Traffic Ops older version doesn't support setting whether updates failed")
+			}
+			log.Warnln("Traffic Ops older version doesn't support setting whether updates failed, only setting apply times")
+		}
 		/*	var updateStatus, revalStatus *bool
 			if configApply != nil {
 				*updateStatus = true
@@ -780,7 +788,7 @@ func (cl *TOClient) SetServerUpdateStatus(cacheHostName tc.CacheName, configAppl
 
 	reqInf := toclientlib.ReqInf{}
 	err := torequtil.GetRetry(cl.NumRetries, "set_server_update_status_"+string(cacheHostName), nil, func(obj interface{}) error {
-		_, toReqInf, err := cl.c.SetUpdateServerStatusTimes(string(cacheHostName), configApply, revalApply, nil, nil, *ReqOpts(nil))
+		_, toReqInf, err := cl.c.SetUpdateServerStatusTimes(string(cacheHostName), configApply, revalApply, configUpdateFailed, revalUpdateFailed, *ReqOpts(nil))
 		if err != nil {
 			return errors.New("setting server update status in Traffic Ops '" + torequtil.MaybeIPStr(reqInf.RemoteAddr) + "': " + err.Error())
 		}
@@ -793,6 +801,28 @@ func (cl *TOClient) SetServerUpdateStatus(cacheHostName tc.CacheName, configAppl
 	return reqInf, nil
 }
 
+// SetServerConfigDrift sets the server's config drift in Traffic Ops to the given drifted files.
+// Config drift is not supported by older Traffic Ops APIs.
+func (cl *TOClient) SetServerConfigDrift(cacheHostName tc.CacheName, files []tc.ConfigDriftFile) (toclientlib.ReqInf, error) {
+	if cl.c == nil {
+		return toclientlib.ReqInf{}, errors.New("Traffic Ops older version doesn't support config drift")
+	}
+
+	reqInf := toclientlib.ReqInf{}
+	err := torequtil.GetRetry(cl.NumRetries, "set_server_config_drift_"+string(cacheHostName), nil, func(obj interface{}) error {
+		_, toReqInf, err := cl.c.SetServerConfigDrift(string(cacheHostName), files, *ReqOpts(nil))
+		if err != nil {
+			return errors.New("setting server config drift in Traffic Ops '" + torequtil.MaybeIPStr(reqInf.RemoteAddr) + "': " + err.Error())
+		}
+		reqInf = toReqInf
+		return nil
+	})
+	if err != nil {
+		return reqInf, errors.New("setting server config drift: " + err.Error())
+	}
+	return reqInf, nil
+}
+
 /*// SetServerUpdateStatusBoolCompat sets the server's update and reval statuses in Traffic Ops.
 // *** Compatability requirement until ATC (v7.0+) is deployed with the timestamp features
 func (cl *TOClient) SetServerUpdateStatusBoolCompat(cacheHostName tc.CacheName, configApply *time.Time, revalApply *time.Time, configApplyBool *bool, revalApplyBool *bool) (toclientlib.ReqInf, error) {
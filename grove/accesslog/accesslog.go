@@ -0,0 +1,229 @@
+package accesslog
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// accesslog exists to write client requests to access logs, in configurable formats, so Grove logs can be consumed by the same pipelines as ATS logs.
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/grove/config"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Selection is a remap rule's selection of an access log to write its requests to.
+type Selection struct {
+	Name string `json:"name"`
+	// SampleRate is the fraction of requests to log, from 0 to 1. If nil, the log's sample rate is used.
+	SampleRate *float64 `json:"sample_rate"`
+}
+
+func (s Selection) Validate() error {
+	if s.Name == "" {
+		return errors.New("name missing")
+	}
+	if s.SampleRate != nil && (*s.SampleRate < 0 || *s.SampleRate > 1) {
+		return errors.New("sample_rate must be from 0 to 1")
+	}
+	return nil
+}
+
+type accessLog struct {
+	name       string
+	format     *Format
+	sampleRate float64
+	writer     *writer
+}
+
+// Logs is the access logs of the config. It's threadsafe, and shared by all handlers, including after config reloads, so logs whose location is unchanged keep writing to the same file.
+type Logs struct {
+	logs   []*accessLog
+	byName map[string]*accessLog
+	m      sync.RWMutex
+}
+
+func New() *Logs {
+	return &Logs{byName: map[string]*accessLog{}}
+}
+
+// Update sets the access logs from the given config. If the config is invalid or a log can't be opened, the existing logs are kept and an error is returned. Logs whose location and write config are unchanged keep their writer; other writers are closed after writing their queued lines.
+func (l *Logs) Update(cfgs []config.AccessLog) error {
+	newLogs := make([]*accessLog, 0, len(cfgs))
+	newWriterCfgs := make([]writerConfig, 0, len(cfgs))
+	names := map[string]struct{}{}
+	locations := map[string]struct{}{}
+	for _, cfg := range cfgs {
+		if cfg.Name == "" {
+			return errors.New("access log name missing")
+		}
+		if _, ok := names[cfg.Name]; ok {
+			return errors.New("access log '" + cfg.Name + "' is duplicated")
+		}
+		names[cfg.Name] = struct{}{}
+		if cfg.Location == "" {
+			return errors.New("access log '" + cfg.Name + "' location missing")
+		}
+		if _, ok := locations[cfg.Location]; ok {
+			return errors.New("access log '" + cfg.Name + "' location '" + cfg.Location + "' is used by another log")
+		}
+		locations[cfg.Location] = struct{}{}
+
+		lg := &accessLog{name: cfg.Name, sampleRate: 1}
+		if cfg.SampleRate != nil {
+			if *cfg.SampleRate < 0 || *cfg.SampleRate > 1 {
+				return errors.New("access log '" + cfg.Name + "' sample_rate must be from 0 to 1")
+			}
+			lg.sampleRate = *cfg.SampleRate
+		}
+
+		err := error(nil)
+		switch cfg.Format {
+		case FormatText, "":
+			text := cfg.Text
+			if text == "" {
+				text = DefaultText
+			}
+			lg.format, err = NewTextFormat(text)
+		case FormatJSON:
+			lg.format, err = NewJSONFormat(cfg.Fields)
+		default:
+			err = errors.New("unknown format '" + cfg.Format + "'")
+		}
+		if err != nil {
+			return errors.New("access log '" + cfg.Name + "': " + err.Error())
+		}
+
+		if cfg.BufferBytes < 0 || cfg.FlushMS < 0 || cfg.RotateBytes < 0 || cfg.RotateKeep < 0 {
+			return errors.New("access log '" + cfg.Name + "' buffer_bytes, flush_ms, rotate_bytes, and rotate_keep must not be negative")
+		}
+		wCfg := writerConfig{location: cfg.Location, bufferBytes: cfg.BufferBytes, flush: time.Duration(cfg.FlushMS) * time.Millisecond, rotateBytes: cfg.RotateBytes, rotateKeep: cfg.RotateKeep}
+		if wCfg.bufferBytes == 0 {
+			wCfg.bufferBytes = DefaultBufferBytes
+		}
+		if wCfg.flush == 0 {
+			wCfg.flush = DefaultFlushMS * time.Millisecond
+		}
+		if wCfg.rotateKeep == 0 {
+			wCfg.rotateKeep = DefaultRotateKeep
+		}
+		newLogs = append(newLogs, lg)
+		newWriterCfgs = append(newWriterCfgs, wCfg)
+	}
+
+	oldWriters := map[string]*writer{}
+	l.m.RLock()
+	for _, lg := range l.logs {
+		oldWriters[lg.writer.cfg.location] = lg.writer
+	}
+	l.m.RUnlock()
+
+	created := []*writer{}
+	for i, lg := range newLogs {
+		if w, ok := oldWriters[newWriterCfgs[i].location]; ok && w.cfg == newWriterCfgs[i] {
+			lg.writer = w
+			continue
+		}
+		w, err := newWriter(newWriterCfgs[i])
+		if err != nil {
+			for _, w := range created {
+				w.close()
+			}
+			return errors.New("access log '" + lg.name + "' opening '" + newWriterCfgs[i].location + "': " + err.Error())
+		}
+		lg.writer = w
+		created = append(created, w)
+	}
+
+	byName := make(map[string]*accessLog, len(newLogs))
+	kept := map[*writer]struct{}{}
+	for _, lg := range newLogs {
+		byName[lg.name] = lg
+		kept[lg.writer] = struct{}{}
+	}
+
+	l.m.Lock()
+	l.logs, l.byName = newLogs, byName
+	l.m.Unlock()
+
+	// Log writes while holding the read lock, so no request is writing to the old writers after the swap.
+	for _, w := range oldWriters {
+		if _, ok := kept[w]; !ok {
+			w.close()
+		}
+	}
+	return nil
+}
+
+// Has returns whether an access log with the given name exists.
+func (l *Logs) Has(name string) bool {
+	l.m.RLock()
+	defer l.m.RUnlock()
+	_, ok := l.byName[name]
+	return ok
+}
+
+// Empty returns whether there are no access logs, so callers can skip creating entries.
+func (l *Logs) Empty() bool {
+	l.m.RLock()
+	defer l.m.RUnlock()
+	return len(l.logs) == 0
+}
+
+// Log writes the entry to the selected logs, sampled by the selection's or log's sample rate. If selections is nil, the entry is written to all logs. Selections of logs which don't exist are ignored.
+func (l *Logs) Log(e *Entry, selections []Selection) {
+	l.m.RLock()
+	defer l.m.RUnlock()
+	if selections == nil {
+		for _, lg := range l.logs {
+			lg.log(e, lg.sampleRate)
+		}
+		return
+	}
+	for _, sel := range selections {
+		lg, ok := l.byName[sel.Name]
+		if !ok {
+			continue
+		}
+		sampleRate := lg.sampleRate
+		if sel.SampleRate != nil {
+			sampleRate = *sel.SampleRate
+		}
+		lg.log(e, sampleRate)
+	}
+}
+
+// Close writes all queued lines and closes all logs. Entries logged after Close are dropped.
+func (l *Logs) Close() {
+	l.m.Lock()
+	defer l.m.Unlock()
+	for _, lg := range l.logs {
+		lg.writer.close()
+	}
+	l.logs, l.byName = nil, map[string]*accessLog{}
+}
+
+func (lg *accessLog) log(e *Entry, sampleRate float64) {
+	if sampleRate < 1 && rand.Float64() >= sampleRate {
+		return
+	}
+	lg.writer.write(lg.format.Line(e))
+}
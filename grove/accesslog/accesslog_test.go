@@ -0,0 +1,134 @@
+package accesslog
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/grove/config"
+)
+
+func readLines(t *testing.T, path string) []string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %v: %v", path, err)
+	}
+	return strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+}
+
+func TestLogSelections(t *testing.T) {
+	dir := t.TempDir()
+	zero := 0.0
+	logs := New()
+	err := logs.Update([]config.AccessLog{
+		{Name: "a", Location: filepath.Join(dir, "a.log"), Text: "a %<reqid>"},
+		{Name: "b", Location: filepath.Join(dir, "b.log"), Text: "b %<reqid>"},
+		{Name: "never", Location: filepath.Join(dir, "never.log"), Text: "never %<reqid>", SampleRate: &zero},
+	})
+	if err != nil {
+		t.Fatalf("Update error expected nil, actual %v", err)
+	}
+
+	e := testEntry()
+	e.RequestID = 1
+	logs.Log(e, nil)
+	e.RequestID = 2
+	logs.Log(e, []Selection{{Name: "b"}, {Name: "unknown"}})
+	e.RequestID = 3
+	logs.Log(e, []Selection{})
+	e.RequestID = 4
+	one := 1.0
+	logs.Log(e, []Selection{{Name: "a", SampleRate: &zero}, {Name: "never", SampleRate: &one}})
+	logs.Close()
+
+	if lines := readLines(t, filepath.Join(dir, "a.log")); len(lines) != 1 || lines[0] != "a 1" {
+		t.Errorf("log a expected [a 1], actual %v", lines)
+	}
+	if lines := readLines(t, filepath.Join(dir, "b.log")); len(lines) != 2 || lines[0] != "b 1" || lines[1] != "b 2" {
+		t.Errorf("log b expected [b 1 b 2], actual %v", lines)
+	}
+	if lines := readLines(t, filepath.Join(dir, "never.log")); len(lines) != 1 || lines[0] != "never 4" {
+		t.Errorf("log never expected only the rule sampled line [never 4], actual %v", lines)
+	}
+}
+
+func TestUpdateKeepsWriters(t *testing.T) {
+	dir := t.TempDir()
+	logs := New()
+	cfg := config.AccessLog{Name: "a", Location: filepath.Join(dir, "a.log"), Text: "%<reqid>"}
+	if err := logs.Update([]config.AccessLog{cfg}); err != nil {
+		t.Fatalf("Update error expected nil, actual %v", err)
+	}
+	w := logs.byName["a"].writer
+
+	renamed := cfg
+	renamed.Name = "renamed"
+	renamed.Format = FormatJSON
+	renamed.Fields = []string{"reqid"}
+	if err := logs.Update([]config.AccessLog{renamed}); err != nil {
+		t.Fatalf("Update error expected nil, actual %v", err)
+	}
+	if logs.byName["renamed"].writer != w {
+		t.Errorf("Update with the same location expected to keep the writer, actual new writer")
+	}
+
+	invalid := [][]config.AccessLog{
+		{{Name: "", Location: filepath.Join(dir, "x.log")}},
+		{{Name: "x"}},
+		{{Name: "x", Location: filepath.Join(dir, "x.log")}, {Name: "x", Location: filepath.Join(dir, "y.log")}},
+		{{Name: "x", Location: filepath.Join(dir, "x.log")}, {Name: "y", Location: filepath.Join(dir, "x.log")}},
+		{{Name: "x", Location: filepath.Join(dir, "x.log"), Format: "xml"}},
+		{{Name: "x", Location: filepath.Join(dir, "x.log"), Text: "%<nope>"}},
+		{{Name: "x", Location: filepath.Join(dir, "nodir", "x.log")}},
+	}
+	for _, cfgs := range invalid {
+		if err := logs.Update(cfgs); err == nil {
+			t.Errorf("Update(%+v) expected error, actual nil", cfgs)
+		}
+	}
+	if !logs.Has("renamed") {
+		t.Errorf("Update with invalid config expected to keep existing logs, actual removed")
+	}
+	logs.Close()
+}
+
+func TestRotate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.log")
+	logs := New()
+	if err := logs.Update([]config.AccessLog{{Name: "a", Location: path, Text: "%<reqid>", RotateBytes: 4, RotateKeep: 2}}); err != nil {
+		t.Fatalf("Update error expected nil, actual %v", err)
+	}
+	e := testEntry()
+	for i := uint64(10); i < 15; i++ { // each line is 3 bytes, so every second line rotates
+		e.RequestID = i
+		logs.Log(e, nil)
+	}
+	logs.Close()
+
+	expected := map[string][]string{path: {"14"}, path + ".1": {"12", "13"}, path + ".2": {"10", "11"}}
+	for p, lines := range expected {
+		if actual := readLines(t, p); strings.Join(actual, ",") != strings.Join(lines, ",") {
+			t.Errorf("file %v expected %v, actual %v", p, lines, actual)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("file %v expected not to exist beyond rotate_keep, actual %v", path+".3", err)
+	}
+}
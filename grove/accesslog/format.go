@@ -0,0 +1,257 @@
+package accesslog
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/grove/cachedata"
+	"github.com/apache/trafficcontrol/v8/grove/web"
+)
+
+// DefaultText is the text format of logs without a text or fields, which is the ATS squid-style line Grove has always logged. Note cqhn is a historical misspelling of the cqhm label, kept so existing log pipelines continue to parse it.
+const DefaultText = `%<cqtq> chi=%<chi> phn=%<phn> php=%<php> shn=%<shn> url=%<url> cqhn=%<cqhm> cqhv=%<cqhv> pssc=%<pssc> ttms=%<ttms> b=%<b> sssc=%<sssc> sscl=%<sscl> cfsc=%<cfsc> pfsc=%<pfsc> crc=%<crc> phr=%<phr> pqsn=%<pqsn> uas="%<uas>" xmt="%<xmt>" reqid=%<reqid>`
+
+const NSPerSec = 1000000000
+
+// Entry is the data of a client request and response, to be logged.
+type Entry struct {
+	// Time is when the response finished.
+	Time time.Time
+	// RespHeader is the header of the response to the client.
+	RespHeader http.Header
+	// BytesSent is the number of bytes sent to the client.
+	BytesSent uint64
+	RequestID uint64
+	cachedata.ReqData
+	cachedata.SrvrData
+	cachedata.ParentRespData
+	cachedata.RespData
+}
+
+// NewEntry returns the Entry of a response which just finished, from the data given to plugins. The w must be the ResponseWriter the response was written to.
+func NewEntry(w http.ResponseWriter, reqID uint64, reqData cachedata.ReqData, srvrData cachedata.SrvrData, parentRespData cachedata.ParentRespData, respData cachedata.RespData) *Entry {
+	return &Entry{
+		Time:           time.Now(),
+		RespHeader:     w.Header(),
+		BytesSent:      web.TryGetBytesWritten(w, reqData.Conn, respData.BytesWritten),
+		RequestID:      reqID,
+		ReqData:        reqData,
+		SrvrData:       srvrData,
+		ParentRespData: parentRespData,
+		RespData:       respData,
+	}
+}
+
+// field is a loggable value of an Entry. Numeric fields are written as JSON numbers, and all others as strings.
+type field struct {
+	name    string
+	numeric bool
+	value   func(e *Entry) string
+}
+
+// fields are the fields which may be logged, named after their ATS logging.yaml equivalents where one exists. Headers are logged with the {Header-Name}cqh and {Header-Name}psh fields, which aren't in this map.
+var fields = map[string]field{
+	"cqtq": {numeric: true, value: func(e *Entry) string { return unixMilliStr(e.Time) }},
+	"chi":  {value: func(e *Entry) string { return e.ClientIP }},
+	"phn":  {value: func(e *Entry) string { return e.Hostname }},
+	"php":  {value: func(e *Entry) string { return e.Port }},
+	"shn":  {value: func(e *Entry) string { return e.ToFQDN }},
+	"url":  {value: func(e *Entry) string { return e.Scheme + "://" + e.Req.Host + e.Req.URL.String() }},
+	"cqhm": {value: func(e *Entry) string { return e.Req.Method }},
+	"cqhv": {value: func(e *Entry) string { return e.Req.Proto }},
+	"pssc": {numeric: true, value: func(e *Entry) string { return strconv.Itoa(e.RespCode) }},
+	"ttms": {numeric: true, value: func(e *Entry) string { return strconv.FormatInt(int64(e.Time.Sub(e.ReqTime)/time.Millisecond), 10) }},
+	"b":    {numeric: true, value: func(e *Entry) string { return strconv.FormatUint(e.BytesSent, 10) }},
+	"sssc": {numeric: true, value: func(e *Entry) string { return strconv.Itoa(e.OriginCode) }},
+	"sscl": {numeric: true, value: func(e *Entry) string { return strconv.FormatUint(e.OriginBytes, 10) }},
+	"cfsc": {value: func(e *Entry) string { return finStr(e.RespSuccess) }},
+	"pfsc": {value: func(e *Entry) string { return finStr(e.OriginReqSuccess) }},
+	"crc":  {value: func(e *Entry) string { return cacheHitStr(e.CacheHit, e.OriginConnectFailed) }},
+	"phr":  {value: func(e *Entry) string { phr, _ := parentStrs(e.RespCode, e.CacheHit, e.ProxyStr, e.ToFQDN); return phr }},
+	"pqsn": {value: func(e *Entry) string {
+		_, pqsn := parentStrs(e.RespCode, e.CacheHit, e.ProxyStr, e.ToFQDN)
+		return pqsn
+	}},
+	"uas":   {value: func(e *Entry) string { return e.Req.UserAgent() }},
+	"xmt":   {value: func(e *Entry) string { return dashIfEmpty(e.Req.Header.Get("X-Money-Trace")) }},
+	"reqid": {numeric: true, value: func(e *Entry) string { return strconv.FormatUint(e.RequestID, 10) }},
+	"rule":  {value: func(e *Entry) string { return dashIfEmpty(e.RemapRule) }},
+}
+
+// getField returns the field with the given name, including header fields, and whether it exists.
+func getField(name string) (field, bool) {
+	if f, ok := fields[name]; ok {
+		f.name = name
+		return f, true
+	}
+	if !strings.HasPrefix(name, "{") {
+		return field{}, false
+	}
+	end := strings.Index(name, "}")
+	if end < 2 {
+		return field{}, false
+	}
+	hdr := http.CanonicalHeaderKey(name[1:end])
+	switch name[end+1:] {
+	case "cqh":
+		return field{name: name, value: func(e *Entry) string { return dashIfEmpty(e.Req.Header.Get(hdr)) }}, true
+	case "psh":
+		return field{name: name, value: func(e *Entry) string { return dashIfEmpty(e.RespHeader.Get(hdr)) }}, true
+	}
+	return field{}, false
+}
+
+// Format formats Entries as log lines. It's either a text format, of literal text and %<field> fields like ATS logging.yaml, or a JSON object of a list of fields.
+type Format struct {
+	json   bool
+	fields []field
+	text   []string // the literal text before each field, and after the last; unused for JSON
+}
+
+// NewTextFormat returns the Format of the given text, in which each %<field> is replaced by the field's value.
+func NewTextFormat(text string) (*Format, error) {
+	f := &Format{}
+	for {
+		start := strings.Index(text, "%<")
+		if start == -1 {
+			break
+		}
+		end := strings.Index(text[start:], ">")
+		if end == -1 {
+			return nil, errors.New("unterminated field at '" + text[start:] + "'")
+		}
+		end += start
+		fl, ok := getField(text[start+2 : end])
+		if !ok {
+			return nil, errors.New("unknown field '" + text[start+2:end] + "'")
+		}
+		f.text = append(f.text, text[:start])
+		f.fields = append(f.fields, fl)
+		text = text[end+1:]
+	}
+	f.text = append(f.text, text)
+	return f, nil
+}
+
+// NewJSONFormat returns the Format of a JSON object of the given fields, keyed by field name.
+func NewJSONFormat(fieldNames []string) (*Format, error) {
+	if len(fieldNames) == 0 {
+		return nil, errors.New("no fields")
+	}
+	f := &Format{json: true}
+	for _, name := range fieldNames {
+		fl, ok := getField(name)
+		if !ok {
+			return nil, errors.New("unknown field '" + name + "'")
+		}
+		f.fields = append(f.fields, fl)
+	}
+	return f, nil
+}
+
+// Line returns the log line of the given Entry, terminated by a newline.
+func (f *Format) Line(e *Entry) []byte {
+	b := make([]byte, 0, 512)
+	if !f.json {
+		for i, fl := range f.fields {
+			b = append(b, f.text[i]...)
+			b = append(b, fl.value(e)...)
+		}
+		b = append(b, f.text[len(f.text)-1]...)
+		return append(b, '\n')
+	}
+
+	b = append(b, '{')
+	for i, fl := range f.fields {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendJSONStr(b, fl.name)
+		b = append(b, ':')
+		if fl.numeric {
+			b = append(b, fl.value(e)...)
+		} else {
+			b = appendJSONStr(b, fl.value(e))
+		}
+	}
+	return append(b, '}', '\n')
+}
+
+func appendJSONStr(b []byte, s string) []byte {
+	bts, err := json.Marshal(s)
+	if err != nil {
+		return append(b, `""`...) // should never happen, strings always marshal
+	}
+	return append(b, bts...)
+}
+
+// unixMilliStr returns the Unix time of t in seconds, with three decimal places, like ATS logs.
+func unixMilliStr(t time.Time) string {
+	unixNano := t.UnixNano()
+	unixSec := unixNano / NSPerSec
+	unixFrac := (unixNano / (NSPerSec / 1000)) - (unixSec * 1000)
+	unixFracStr := strconv.FormatInt(unixFrac, 10)
+	for len(unixFracStr) < 3 {
+		unixFracStr = "0" + unixFracStr // leading zeros, so e.g. a fraction of '42' becomes '1234.042' not '1234.42'
+	}
+	return strconv.FormatInt(unixSec, 10) + "." + unixFracStr
+}
+
+func finStr(success bool) string {
+	if success {
+		return "FIN"
+	}
+	return "INTR"
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// parentStrs returns the phr and pqsn ATS log strings (in that order).
+// This covers almost all occurences that we currently see from ATS.
+func parentStrs(code int, hit bool, proxyStr string, toFQDN string) (string, string) {
+	// the most common case (hopefully), do this first
+	if hit {
+		return "NONE", "-"
+	}
+	if code >= 200 {
+		if proxyStr != "" {
+			return "PARENT_HIT", strings.Split(proxyStr, ":")[0]
+		}
+		return "DIRECT", toFQDN
+	}
+	return "EMPTY", "-"
+}
+
+// cacheHitStr returns the crc ATS log string, for whether the request was a cache hit.
+func cacheHitStr(hit bool, originConnectFailed bool) string {
+	if originConnectFailed {
+		return "ERR_CONNECT_FAIL"
+	}
+	if hit {
+		return "TCP_HIT"
+	}
+	return "TCP_MISS"
+}
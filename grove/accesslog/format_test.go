@@ -0,0 +1,149 @@
+package accesslog
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/grove/cachedata"
+)
+
+func testEntry() *Entry {
+	req := httptest.NewRequest(http.MethodGet, "/path?q=1", nil)
+	req.Host = "foo.example.net"
+	req.Header.Set("User-Agent", "test-agent")
+	req.Header.Set("Referer", "http://ref.example.net/")
+	reqTime := time.Unix(1505408269, 0)
+	return &Entry{
+		Time:       reqTime.Add(42 * time.Millisecond),
+		RespHeader: http.Header{"Content-Type": {"text/plain"}},
+		BytesSent:  1778,
+		RequestID:  7,
+		ReqData:    cachedata.ReqData{Req: req, ClientIP: "192.0.2.1", ReqTime: reqTime, ToFQDN: "origin.example.net", RemapRule: "foo"},
+		SrvrData:   cachedata.SrvrData{Hostname: "grove.example.net", Port: "80", Scheme: "http"},
+		ParentRespData: cachedata.ParentRespData{
+			OriginCode:       200,
+			OriginBytes:      1700,
+			OriginReqSuccess: true,
+		},
+		RespData: cachedata.RespData{RespCode: 200, RespSuccess: true},
+	}
+}
+
+func TestTimeFractionalSeconds(t *testing.T) {
+	f, err := NewTextFormat("%<cqtq> chi=%<chi>")
+	if err != nil {
+		t.Fatalf("NewTextFormat error expected nil, actual %v", err)
+	}
+
+	testTimes := []int64{
+		1563936732547355432,
+		1563937732000355432,
+		1563936732000000000,
+		1463136732999000000,
+		1563916732009000000,
+		1503936232090000000,
+		1563936732099000000,
+		1563936722900000000,
+		1563236282909000000,
+	}
+	for _, testTime := range testTimes {
+		e := testEntry()
+		e.Time = time.Unix(0, testTime)
+
+		logFields := strings.Fields(string(f.Line(e)))
+		if len(logFields) < 1 {
+			t.Fatalf("Line expected >1 fields, actual %v", len(logFields))
+		}
+
+		timeField := logFields[0]
+
+		// the time field should be the Unix timestamp in seconds, as a float with 3 decimal places.
+		unixSec := float64(e.Time.UnixNano()) / float64(NSPerSec)
+		unixSecThreeDecimalPts := fmt.Sprintf("%.3f", unixSec)
+
+		if timeField != unixSecThreeDecimalPts {
+			t.Errorf("Line time expected '%v' actual '%v'", unixSecThreeDecimalPts, timeField)
+		}
+	}
+}
+
+func TestDefaultText(t *testing.T) {
+	f, err := NewTextFormat(DefaultText)
+	if err != nil {
+		t.Fatalf("NewTextFormat(DefaultText) error expected nil, actual %v", err)
+	}
+	expected := `1505408269.042 chi=192.0.2.1 phn=grove.example.net php=80 shn=origin.example.net url=http://foo.example.net/path?q=1 cqhn=GET cqhv=HTTP/1.1 pssc=200 ttms=42 b=1778 sssc=200 sscl=1700 cfsc=FIN pfsc=FIN crc=TCP_MISS phr=DIRECT pqsn=origin.example.net uas="test-agent" xmt="-" reqid=7` + "\n"
+	if actual := string(f.Line(testEntry())); actual != expected {
+		t.Errorf("Line expected '%v' actual '%v'", expected, actual)
+	}
+}
+
+func TestTextHeaders(t *testing.T) {
+	f, err := NewTextFormat("%<rule> %<{referer}cqh> %<{Content-Type}psh> %<{X-Missing}cqh>|")
+	if err != nil {
+		t.Fatalf("NewTextFormat error expected nil, actual %v", err)
+	}
+	expected := "foo http://ref.example.net/ text/plain -|\n"
+	if actual := string(f.Line(testEntry())); actual != expected {
+		t.Errorf("Line expected '%v' actual '%v'", expected, actual)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	f, err := NewJSONFormat([]string{"cqtq", "chi", "url", "pssc", "b", "crc", "{User-Agent}cqh"})
+	if err != nil {
+		t.Fatalf("NewJSONFormat error expected nil, actual %v", err)
+	}
+	line := f.Line(testEntry())
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(line, &obj); err != nil {
+		t.Fatalf("Line expected JSON, actual '%s' error %v", line, err)
+	}
+	expected := map[string]interface{}{
+		"cqtq":            1505408269.042,
+		"chi":             "192.0.2.1",
+		"url":             "http://foo.example.net/path?q=1",
+		"pssc":            float64(200),
+		"b":               float64(1778),
+		"crc":             "TCP_MISS",
+		"{User-Agent}cqh": "test-agent",
+	}
+	for k, v := range expected {
+		if obj[k] != v {
+			t.Errorf("Line field %v expected %v actual %v", k, v, obj[k])
+		}
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	for _, text := range []string{"%<nope>", "%<chi", "%<{}cqh>", "%<{Referer}xyz>"} {
+		if _, err := NewTextFormat(text); err == nil {
+			t.Errorf("NewTextFormat('%v') expected error, actual nil", text)
+		}
+	}
+	if _, err := NewJSONFormat(nil); err == nil {
+		t.Errorf("NewJSONFormat with no fields expected error, actual nil")
+	}
+	if _, err := NewJSONFormat([]string{"chi", "nope"}); err == nil {
+		t.Errorf("NewJSONFormat with an unknown field expected error, actual nil")
+	}
+}
@@ -0,0 +1,166 @@
+package accesslog
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+)
+
+const (
+	DefaultBufferBytes = 64 * 1024
+	DefaultFlushMS     = 1000
+	DefaultRotateKeep  = 5
+	// QueueLines is the number of lines which may be waiting to be written to each log. When the queue is full, lines are dropped rather than blocking requests, and the number dropped is logged as a warning.
+	QueueLines = 10000
+)
+
+const (
+	LocationStdout = "stdout"
+	LocationStderr = "stderr"
+)
+
+type writerConfig struct {
+	location    string
+	bufferBytes int
+	flush       time.Duration
+	rotateBytes int64
+	rotateKeep  int
+}
+
+// writer asynchronously writes lines to a log location, buffered, rotating files when they exceed the rotate size.
+type writer struct {
+	cfg     writerConfig
+	lines   chan []byte
+	done    chan struct{}
+	dropped uint64 // Atomic - DO NOT access or modify without atomic operations
+}
+
+func newWriter(cfg writerConfig) (*writer, error) {
+	f, size, err := openLocation(cfg.location)
+	if err != nil {
+		return nil, err
+	}
+	w := &writer{cfg: cfg, lines: make(chan []byte, QueueLines), done: make(chan struct{})}
+	go w.run(f, size)
+	return w, nil
+}
+
+// openLocation returns the file of the location, and its size.
+func openLocation(location string) (*os.File, int64, error) {
+	switch location {
+	case LocationStdout:
+		return os.Stdout, 0, nil
+	case LocationStderr:
+		return os.Stderr, 0, nil
+	}
+	f, err := os.OpenFile(location, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, 0, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, fi.Size(), nil
+}
+
+func (w *writer) isFile() bool {
+	return w.cfg.location != LocationStdout && w.cfg.location != LocationStderr
+}
+
+// write queues the line to be written. It never blocks; if the queue is full, the line is dropped.
+func (w *writer) write(line []byte) {
+	select {
+	case w.lines <- line:
+	default:
+		atomic.AddUint64(&w.dropped, 1)
+	}
+}
+
+// close writes all queued lines, and closes the file. It must not be called concurrently with write.
+func (w *writer) close() {
+	close(w.lines)
+	<-w.done
+}
+
+func (w *writer) run(f *os.File, size int64) {
+	buf := bufio.NewWriterSize(f, w.cfg.bufferBytes)
+	ticker := time.NewTicker(w.cfg.flush)
+	defer ticker.Stop()
+	reportedDropped := uint64(0)
+	for {
+		select {
+		case line, ok := <-w.lines:
+			if !ok {
+				if err := buf.Flush(); err != nil {
+					log.Errorf("access log %v: writing: %v\n", w.cfg.location, err)
+				}
+				if w.isFile() {
+					f.Close()
+				}
+				close(w.done)
+				return
+			}
+			if _, err := buf.Write(line); err != nil {
+				log.Errorf("access log %v: writing: %v\n", w.cfg.location, err)
+				buf.Reset(f) // a bufio.Writer fails all writes after an error, so later lines must get a new buffer
+			}
+			size += int64(len(line))
+			if w.isFile() && w.cfg.rotateBytes > 0 && size >= w.cfg.rotateBytes {
+				f, size = w.rotate(buf, f, size)
+			}
+		case <-ticker.C:
+			if err := buf.Flush(); err != nil {
+				log.Errorf("access log %v: writing: %v\n", w.cfg.location, err)
+				buf.Reset(f)
+			}
+			if dropped := atomic.LoadUint64(&w.dropped); dropped != reportedDropped {
+				log.Warnf("access log %v: dropped %v lines, because writing couldn't keep up\n", w.cfg.location, dropped-reportedDropped)
+				reportedDropped = dropped
+			}
+		}
+	}
+}
+
+// rotate flushes the buffer, renames the file to location.1, shifting older files up to the rotate keep, and opens a new file. Returns the file to write to and its size. If the new file can't be opened, the old file continues to be written to.
+func (w *writer) rotate(buf *bufio.Writer, f *os.File, size int64) (*os.File, int64) {
+	if err := buf.Flush(); err != nil {
+		log.Errorf("access log %v: writing: %v\n", w.cfg.location, err)
+	}
+	path := w.cfg.location
+	os.Remove(path + "." + strconv.Itoa(w.cfg.rotateKeep))
+	for i := w.cfg.rotateKeep - 1; i > 0; i-- {
+		os.Rename(path+"."+strconv.Itoa(i), path+"."+strconv.Itoa(i+1)) // older files may not exist yet
+	}
+	if err := os.Rename(path, path+".1"); err != nil {
+		log.Errorf("access log %v: rotating: %v\n", path, err)
+		return f, size
+	}
+	newF, newSize, err := openLocation(path)
+	if err != nil {
+		log.Errorf("access log %v: opening rotated file, continuing to write to %v.1: %v\n", path, path, err)
+		return f, size
+	}
+	f.Close()
+	buf.Reset(newF)
+	return newF, newSize
+}
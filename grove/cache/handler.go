@@ -22,11 +22,17 @@ import (
 	"time"
 	"unsafe"
 
+	"github.com/apache/trafficcontrol/v8/grove/accesslog"
 	"github.com/apache/trafficcontrol/v8/grove/cachedata"
+	"github.com/apache/trafficcontrol/v8/grove/icache"
+	"github.com/apache/trafficcontrol/v8/grove/peer"
 	"github.com/apache/trafficcontrol/v8/grove/plugin"
+	"github.com/apache/trafficcontrol/v8/grove/purge"
 
 	"github.com/apache/trafficcontrol/v8/grove/remap"
+	"github.com/apache/trafficcontrol/v8/grove/remapdata"
 	"github.com/apache/trafficcontrol/v8/grove/stat"
+	"github.com/apache/trafficcontrol/v8/grove/stream"
 	"github.com/apache/trafficcontrol/v8/grove/thread"
 	"github.com/apache/trafficcontrol/v8/grove/web"
 
@@ -54,22 +60,30 @@ func (h *HandlerPointer) Set(newHandler *Handler) {
 }
 
 type Handler struct {
-	remapper        remap.HTTPRequestRemapper
-	getter          thread.Getter
-	ruleThrottlers  map[string]thread.Throttler // doesn't need threadsafe keys, because it's never added to or deleted after creation. TODO fix for hot rule reloading
-	scheme          string
-	port            string
-	hostname        string
-	strictRFC       bool
-	stats           stat.Stats
-	conns           *web.ConnMap
-	connectionClose bool
-	plugins         plugin.Plugins
-	pluginContext   map[string]*interface{}
-	httpConns       *web.ConnMap
-	httpsConns      *web.ConnMap
-	interfaceName   string
-	requestID       uint64 // Atomic - DO NOT access or modify without atomic operations
+	remapper         remap.HTTPRequestRemapper
+	getter           thread.Getter
+	ruleThrottlers   *RuleThrottlers
+	rateLimiters     *RuleRateLimiters
+	scheme           string
+	port             string
+	hostname         string
+	strictRFC        bool
+	stats            stat.Stats
+	conns            *web.ConnMap
+	connectionClose  bool
+	plugins          plugin.Plugins
+	pluginContext    map[string]*interface{}
+	httpConns        *web.ConnMap
+	httpsConns       *web.ConnMap
+	interfaceName    string
+	requestID        uint64 // Atomic - DO NOT access or modify without atomic operations
+	streams          *stream.Streams
+	streamChunkBytes int
+	purges           *purge.Rules
+	purgeToken       string
+	peers            *peer.Peers
+	accessLogs       *accesslog.Logs
+	reloadRules      func() error
 	// keyThrottlers     Throttlers
 	// nocacheThrottlers Throttlers
 }
@@ -100,7 +114,9 @@ type Handler struct {
 // The connectionClose parameter determines whether to send a `Connection: close` header. This is primarily designed for maintenance, to drain the cache of incoming requestors. This overrides rule-specific `connection-close: false` configuration, under the assumption that draining a cache is a temporary maintenance operation, and if connectionClose is true on the service and false on some rules, those rules' configuration is probably a permament setting whereas the operator probably wants to drain all connections if the global setting is true. If it's necessary to leave connection close false on some rules, set all other rules' connectionClose to true and leave the global connectionClose unset.
 func NewHandler(
 	remapper remap.HTTPRequestRemapper,
-	ruleLimit uint64,
+	ruleThrottlers *RuleThrottlers,
+	rateLimiters *RuleRateLimiters,
+	getter thread.Getter,
 	stats stat.Stats,
 	scheme string,
 	port string,
@@ -112,6 +128,13 @@ func NewHandler(
 	httpConns *web.ConnMap,
 	httpsConns *web.ConnMap,
 	interfaceName string,
+	streams *stream.Streams,
+	streamChunkBytes int,
+	purges *purge.Rules,
+	purgeToken string,
+	peers *peer.Peers,
+	accessLogs *accesslog.Logs,
+	reloadRules func() error,
 ) *Handler {
 	hostname, err := os.Hostname()
 	if err != nil {
@@ -119,37 +142,63 @@ func NewHandler(
 	}
 
 	return &Handler{
-		remapper:        remapper,
-		getter:          thread.NewGetter(),
-		ruleThrottlers:  makeRuleThrottlers(remapper, ruleLimit),
-		strictRFC:       strictRFC,
-		scheme:          scheme,
-		port:            port,
-		hostname:        hostname,
-		stats:           stats,
-		conns:           conns,
-		connectionClose: connectionClose,
-		plugins:         plugins,
-		pluginContext:   pluginContext,
-		httpConns:       httpConns,
-		httpsConns:      httpsConns,
-		interfaceName:   interfaceName,
+		remapper:         remapper,
+		getter:           getter,
+		ruleThrottlers:   ruleThrottlers,
+		rateLimiters:     rateLimiters,
+		strictRFC:        strictRFC,
+		scheme:           scheme,
+		port:             port,
+		hostname:         hostname,
+		stats:            stats,
+		conns:            conns,
+		connectionClose:  connectionClose,
+		plugins:          plugins,
+		pluginContext:    pluginContext,
+		httpConns:        httpConns,
+		httpsConns:       httpsConns,
+		interfaceName:    interfaceName,
+		streams:          streams,
+		streamChunkBytes: streamChunkBytes,
+		purges:           purges,
+		purgeToken:       purgeToken,
+		peers:            peers,
+		accessLogs:       accessLogs,
+		reloadRules:      reloadRules,
 		// keyThrottlers:     NewThrottlers(keyLimit),
 		// nocacheThrottlers: NewThrottlers(nocacheLimit),
 	}
 }
 
-func makeRuleThrottlers(remapper remap.HTTPRequestRemapper, limit uint64) map[string]thread.Throttler {
-	remapRules := remapper.Rules()
-	ruleThrottlers := make(map[string]thread.Throttler, len(remapRules))
-	for _, rule := range remapRules {
-		ruleLimit := uint64(rule.ConcurrentRuleRequests)
-		if rule.ConcurrentRuleRequests == 0 {
-			ruleLimit = limit
+// rateLimited takes a token from each rate limit of the given rule, and returns whether the request was limited. If so, it responds with the limit's response code and Retry-After.
+func (h *Handler) rateLimited(r *http.Request, responder *Responder, ruleName string, clientIP string, reqTime time.Time, connectionClose bool, reqID uint64) bool {
+	for _, limiter := range h.rateLimiters.Get(ruleName) {
+		key, ok := limiter.Key(r, clientIP)
+		if !ok {
+			continue
 		}
-		ruleThrottlers[rule.Name] = thread.NewThrottler(ruleLimit)
+		allowed, retry := limiter.Allow(key, reqTime)
+		if allowed {
+			continue
+		}
+		limit := limiter.Limit()
+		log.Debugf("rule %v rate limit %v limited key '%v' (reqid %v)\n", ruleName, limit.Name, key, reqID)
+		h.stats.AddRateLimited()
+		if remapStats, ok := h.stats.Remap().Stats(r.Host); ok {
+			remapStats.AddRateLimited(limit.Name)
+		}
+
+		code := limit.ResponseCode
+		hdrs := http.Header{}
+		if secs, ok := limit.RetryAfterSeconds(retry); ok {
+			hdrs.Set("Retry-After", strconv.Itoa(secs))
+		}
+		body := []byte(http.StatusText(code))
+		responder.SetResponse(&code, &hdrs, &body, connectionClose)
+		responder.Do()
+		return true
 	}
-	return ruleThrottlers
+	return false
 }
 
 func copyPluginContext(context map[string]*interface{}) map[string]*interface{} {
@@ -171,6 +220,14 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if stop {
 		return
 	}
+	if r.URL.Path == PurgeEndpoint {
+		h.servePurgeRules(w, r)
+		return
+	}
+	if r.URL.Path == ReloadEndpoint {
+		h.serveReload(w, r)
+		return
+	}
 
 	conn := (*web.InterceptConn)(nil)
 	if realConn, ok := h.conns.Get(r.RemoteAddr); !ok {
@@ -181,7 +238,12 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	remappingProducer, err := h.remapper.RemappingProducer(r, h.scheme)
+	scheme := h.scheme
+	peerScheme, fromPeer := h.peers.FromPeer(r)
+	if fromPeer {
+		scheme = peerScheme // peer requests are always HTTP, so they're remapped with the scheme the peer's client requested
+	}
+	remappingProducer, err := h.remapper.RemappingProducer(r, scheme)
 
 	if err == nil { // if we failed to get a remapping, there's no DSCP to set.
 		if err := conn.SetDSCP(remappingProducer.DSCP()); err != nil {
@@ -193,14 +255,18 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	clientIP, _ := web.GetClientIPPort(r)
 
 	toFQDN := ""
+	ruleName := ""
 	pluginCfg := map[string]interface{}{}
+	accessLogSelections := []accesslog.Selection(nil) // requests without a rule are written to all access logs
 	if remappingProducer != nil {
 		toFQDN = remappingProducer.FirstFQDN()
+		ruleName = remappingProducer.Name()
 		pluginCfg = remappingProducer.PluginCfg()
+		accessLogSelections = remappingProducer.AccessLogs()
 	}
 
-	reqData := cachedata.ReqData{Req: r, Conn: conn, ClientIP: clientIP, ReqTime: reqTime, ToFQDN: toFQDN}
-	responder := NewResponder(w, pluginCfg, pluginContext, srvrData, reqData, h.plugins, h.stats, reqID)
+	reqData := cachedata.ReqData{Req: r, Conn: conn, ClientIP: clientIP, ReqTime: reqTime, ToFQDN: toFQDN, RemapRule: ruleName}
+	responder := NewResponder(w, pluginCfg, pluginContext, srvrData, reqData, h.plugins, h.stats, h.accessLogs, accessLogSelections, reqID)
 
 	if err != nil {
 		switch err {
@@ -223,6 +289,10 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	connectionClose := h.connectionClose || remappingProducer.ConnectionClose()
 
+	if !fromPeer && h.rateLimited(r, responder, remappingProducer.Name(), clientIP, reqTime, connectionClose, reqID) {
+		return // peer requests were already limited by the peer the client requested
+	}
+
 	beforeCacheLookUpData := plugin.BeforeCacheLookUpData{Req: r, DefaultCacheKey: remappingProducer.CacheKey(), CacheKeyOverrideFunc: remappingProducer.OverrideCacheKey}
 	h.plugins.OnBeforeCacheLookup(remappingProducer.PluginCfg(), pluginContext, beforeCacheLookUpData)
 
@@ -231,8 +301,27 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	cache := remappingProducer.Cache()
 
+	if r.Method == remapdata.MethodPurge {
+		h.servePurge(r, responder, cache, cacheKey, reqID)
+		return
+	}
+
+	if remappingProducer.Stream() {
+		if chunkCache, ok := cache.(icache.ChunkCache); ok && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+			h.serveStream(r, responder, remappingProducer, chunkCache, reqHeader, reqTime, reqCacheControl, connectionClose, pluginContext, reqID)
+			return
+		}
+	}
+
 	var reqHost *string
-	cacheObj, ok := cache.Get(cacheKey)
+	cacheObj, objKey, ok := getVaried(cache, cacheKey, reqHeader)
+	if objKey != cacheKey {
+		remappingProducer.OverrideCacheKey(objKey) // the response varies, so parent requests must get and store this request's variant
+		cacheKey = objKey
+	}
+	if ok && cacheObj.Chunked {
+		ok = false // objects stored in chunks can only be served by streaming, so they're replaced if streaming was disabled
+	}
 	if !ok {
 		log.Debugf("cache.Handler.ServeHTTP: '%v' not in cache (reqid %v)\n", cacheKey, reqID)
 		beforeParentRequestData := plugin.BeforeParentRequestData{Req: r, RemapRule: remappingProducer.Name()}
@@ -266,12 +355,19 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	reqHeaders := r.Header
 	canReuseStored := rfc.CanReuseStored(reqHeaders, cacheObj.RespHeaders, reqCacheControl, cacheObj.RespCacheControl, cacheObj.ReqHeaders, cacheObj.ReqRespTime, cacheObj.RespRespTime, h.strictRFC)
+	canReuseStored = h.purgedReuse(cacheKey, cacheObj, canReuseStored, reqID)
 
 	if canReuseStored != rfc.ReuseCan { // run the BeforeParentRequest hook for revalidations / ReuseCannot
 		beforeParentRequestData := plugin.BeforeParentRequestData{Req: r, RemapRule: remappingProducer.Name()}
 		h.plugins.OnBeforeParentRequest(remappingProducer.PluginCfg(), pluginContext, beforeParentRequestData)
 	}
 
+	if canStaleWhileRevalidate(cacheObj, canReuseStored, remappingProducer) {
+		log.Debugf("cache.Handler.ServeHTTP: '%v' stale, serving while revalidating (reqid %v)\n", cacheKey, reqID)
+		h.revalidateInBackground(retrier, r, cacheObj, cacheKey, reqID)
+		canReuseStored = rfc.ReuseCan
+	}
+
 	switch canReuseStored {
 	case rfc.ReuseCan:
 		log.Debugf("cache.Handler.ServeHTTP: '%v' cache hit! (reqid %v)\n", cacheKey, reqID)
@@ -298,6 +394,9 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			log.Errorf("retrying get error - serving stale as allowed: %v (reqid %v)\n", err, reqID)
 			cacheObj = oldCacheObj
+		} else if canStaleIfError(oldCacheObj, canReuseStored, cacheObj.Code, remappingProducer) {
+			log.Errorf("retrying get returned %v - serving stale as allowed by stale-if-error (reqid %v)\n", cacheObj.Code, reqID)
+			cacheObj = oldCacheObj
 		}
 	}
 	log.Debugf("cache.Handler.ServeHTTP: '%v' responding with %v (reqid %v)\n", cacheKey, cacheObj.Code, reqID)
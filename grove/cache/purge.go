@@ -0,0 +1,152 @@
+package cache
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/grove/cacheobj"
+	"github.com/apache/trafficcontrol/v8/grove/icache"
+	"github.com/apache/trafficcontrol/v8/grove/purge"
+	"github.com/apache/trafficcontrol/v8/grove/web"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/apache/trafficcontrol/v8/lib/go-rfc"
+)
+
+// PurgeEndpoint is the path of the endpoint to get and add regex invalidation rules.
+const PurgeEndpoint = "/_purge"
+
+// PurgeRuleInput is the body of a request to add a regex invalidation rule to the PurgeEndpoint. The rule starts when it's added, and ends after TTLHours.
+type PurgeRuleInput struct {
+	Regex    string     `json:"regex"`
+	Type     purge.Type `json:"type"`
+	TTLHours int        `json:"ttl_hours"`
+}
+
+// authorizeAdmin returns whether the request may use an administrative endpoint or method, such as purging, adding invalidation rules, or reloading remap rules, and the HTTP code to respond with if not. The given name of the request is used for logging. Administrative requests require both the client IP to be allowed by the stats rules, and the purge token in an `Authorization: Bearer` header. If no purge token is configured, administrative requests are disabled.
+func (h *Handler) authorizeAdmin(r *http.Request, name string) (int, bool) {
+	if h.purgeToken == "" {
+		log.Debugln(name + " request, but no purge token is configured, forbidding")
+		return http.StatusForbidden, false
+	}
+	ip, err := web.GetIP(r)
+	if err != nil {
+		log.Errorln(name + " request failed to get IP: " + err.Error())
+		return http.StatusInternalServerError, false
+	}
+	if !h.remapper.StatRules().Allowed(ip) {
+		log.Debugln(name + " request IP " + ip.String() + " FORBIDDEN")
+		return http.StatusForbidden, false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.purgeToken)) != 1 {
+		log.Infoln(name + " request from " + ip.String() + " with invalid token, unauthorized")
+		return http.StatusUnauthorized, false
+	}
+	return 0, true
+}
+
+// servePurgeRules serves the PurgeEndpoint. GET returns the current regex invalidation rules, and POST adds a rule from a PurgeRuleInput.
+func (h *Handler) servePurgeRules(w http.ResponseWriter, r *http.Request) {
+	if code, ok := h.authorizeAdmin(r, "purge"); !ok {
+		web.ServeErr(w, code)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		bts, err := json.Marshal(purge.RulesJSON{Rules: h.purges.Get()})
+		if err != nil {
+			log.Errorln("purge rules: marshalling JSON: " + err.Error())
+			web.ServeErr(w, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(bts)
+	case http.MethodPost:
+		input := PurgeRuleInput{}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("malformed JSON: " + err.Error()))
+			return
+		}
+		if input.TTLHours <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("ttl_hours must be positive"))
+			return
+		}
+		now := time.Now()
+		rule := purge.Rule{Regex: input.Regex, Type: input.Type, Start: now, End: now.Add(time.Duration(input.TTLHours) * time.Hour)}
+		if err := h.purges.Add(rule); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		log.Infof("purge rule added: %+v\n", rule)
+		bts, err := json.Marshal(rule)
+		if err != nil {
+			log.Errorln("purge rules: marshalling JSON: " + err.Error())
+			web.ServeErr(w, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(bts)
+	default:
+		web.ServeErr(w, http.StatusMethodNotAllowed)
+	}
+}
+
+// servePurge removes the object with the given key from the cache, along with all its variants, for a PURGE request. Responds OK if the object was cached, and Not Found if it wasn't.
+func (h *Handler) servePurge(r *http.Request, responder *Responder, cache icache.Cache, cacheKey string, reqID uint64) {
+	code, ok := h.authorizeAdmin(r, "purge")
+	if ok {
+		code = http.StatusNotFound
+		if removeVaried(cache, cacheKey) {
+			log.Infof("purged '%v' (reqid %v)\n", cacheKey, reqID)
+			code = http.StatusOK
+		}
+	}
+	*responder.ResponseCode = code
+	responder.Do()
+}
+
+// purgedReuse returns how the given cached object may be reused, after applying any purge rules invalidating it. Objects invalidated by a refetch rule can't be reused, and objects invalidated by a refresh rule must be revalidated with the parent.
+func (h *Handler) purgedReuse(cacheKey string, obj *cacheobj.CacheObj, canReuse rfc.Reuse, reqID uint64) rfc.Reuse {
+	purgeType, ok := h.purges.Check(keyURL(cacheKey), obj.ReqRespTime)
+	if !ok {
+		return canReuse
+	}
+	log.Debugf("'%v' invalidated by %v purge rule (reqid %v)\n", cacheKey, purgeType, reqID)
+	if purgeType == purge.TypeRefetch {
+		return rfc.ReuseCannot
+	}
+	if canReuse == rfc.ReuseCannot {
+		return canReuse
+	}
+	return rfc.ReuseMustRevalidate
+}
+
+// keyURL returns the URL of the given cache key, without the method or any variant.
+func keyURL(key string) string {
+	key = primaryKey(key)
+	if i := strings.Index(key, ":"); i >= 0 {
+		key = key[i+1:]
+	}
+	return key
+}
@@ -0,0 +1,52 @@
+package cache
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/grove/memcache"
+)
+
+func TestKeyURL(t *testing.T) {
+	const url = "http://example.net/foo?bar=baz"
+	if actual := keyURL("GET:" + url); actual != url {
+		t.Errorf("keyURL expected '%v', actual '%v'", url, actual)
+	}
+	if actual := keyURL(variantKey("GET:"+url, []string{"Accept-Encoding"}, http.Header{"Accept-Encoding": {"gzip"}})); actual != url {
+		t.Errorf("keyURL of variant expected '%v', actual '%v'", url, actual)
+	}
+}
+
+func TestRemoveVaried(t *testing.T) {
+	cache := memcache.New(1024 * 1024)
+	const key = "GET:http://example.net/foo"
+
+	gzipHdr := http.Header{"Accept-Encoding": {"gzip"}}
+	addVaried(cache, key, varyObj("Accept-Encoding", gzipHdr, "gzipped"), 2)
+	addVaried(cache, key, varyObj("Accept-Encoding", http.Header{}, "plain"), 2)
+	_, variant, _ := getVaried(cache, key, gzipHdr)
+
+	if !removeVaried(cache, variant) {
+		t.Errorf("removeVaried of cached variant expected true, actual false")
+	}
+	if len(cache.Keys()) != 0 {
+		t.Errorf("removeVaried expected primary object and all variants removed, actual keys %v", cache.Keys())
+	}
+	if removeVaried(cache, key) {
+		t.Errorf("removeVaried of uncached object expected false, actual true")
+	}
+}
@@ -0,0 +1,69 @@
+package cache
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"sync"
+
+	"github.com/apache/trafficcontrol/v8/grove/ratelimit"
+	"github.com/apache/trafficcontrol/v8/grove/remapdata"
+)
+
+// RuleRateLimiters are the rate limiters of client requests for each remap rule. They're shared by all Handlers, and kept across remap rule reloads, so clients which are limited stay limited after a reload.
+type RuleRateLimiters struct {
+	limiters map[string][]*ratelimit.Limiter
+	m        sync.RWMutex
+}
+
+func NewRuleRateLimiters() *RuleRateLimiters {
+	return &RuleRateLimiters{limiters: map[string][]*ratelimit.Limiter{}}
+}
+
+// Update sets the limiters to those of the given rules, whose limits must have their defaults set.
+//
+// Limits are matched by rule and limit name. Limits which still exist with the same key keep their limiter and buckets, with the new rate and burst. Limiters of new limits, and of limits whose key changed, are created, and those of removed limits are deleted.
+func (l *RuleRateLimiters) Update(rules []remapdata.RemapRule) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	limiters := make(map[string][]*ratelimit.Limiter, len(rules))
+	for _, rule := range rules {
+		if len(rule.RateLimits) == 0 {
+			continue
+		}
+		oldLimiters := make(map[string]*ratelimit.Limiter, len(l.limiters[rule.Name]))
+		for _, limiter := range l.limiters[rule.Name] {
+			oldLimiters[limiter.Limit().Name] = limiter
+		}
+		ruleLimiters := make([]*ratelimit.Limiter, 0, len(rule.RateLimits))
+		for _, limit := range rule.RateLimits {
+			limiter, ok := oldLimiters[limit.Name]
+			if ok && limiter.Limit().Key == limit.Key && limiter.Limit().Header == limit.Header {
+				limiter.SetLimit(limit)
+			} else {
+				limiter = ratelimit.NewLimiter(limit)
+			}
+			ruleLimiters = append(ruleLimiters, limiter)
+		}
+		limiters[rule.Name] = ruleLimiters
+	}
+	l.limiters = limiters
+}
+
+// Get returns the limiters of the given rule name, or nil if the rule has no limits.
+func (l *RuleRateLimiters) Get(ruleName string) []*ratelimit.Limiter {
+	l.m.RLock()
+	defer l.m.RUnlock()
+	return l.limiters[ruleName]
+}
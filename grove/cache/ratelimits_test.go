@@ -0,0 +1,75 @@
+package cache
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/grove/ratelimit"
+	"github.com/apache/trafficcontrol/v8/grove/remapdata"
+)
+
+func TestRuleRateLimitersUpdate(t *testing.T) {
+	rule := func(name string, limits ...ratelimit.Limit) remapdata.RemapRule {
+		for i, limit := range limits {
+			limits[i], _ = limit.WithDefaults()
+		}
+		return remapdata.RemapRule{RemapRuleBase: remapdata.RemapRuleBase{Name: name, RateLimits: limits}}
+	}
+	ip := ratelimit.Limit{Key: ratelimit.KeyClientIP, Rate: 1, Burst: 1}
+	header := ratelimit.Limit{Name: "api", Key: ratelimit.KeyHeader, Header: "X-Api-Key", Rate: 1, Burst: 1}
+
+	limiters := NewRuleRateLimiters()
+	limiters.Update([]remapdata.RemapRule{rule("kept", ip, header), rule("unlimited")})
+	if actual := limiters.Get("unlimited"); actual != nil {
+		t.Errorf("expected no limiters for rule without limits, actual %v", actual)
+	}
+	kept := limiters.Get("kept")
+	if len(kept) != 2 {
+		t.Fatalf("expected 2 limiters, actual %v", len(kept))
+	}
+	now := time.Now()
+	kept[0].Allow("192.0.2.1", now)
+	kept[1].Allow("sekrit", now)
+
+	// the client_ip limit is kept with a new burst, and the header limit has a new header, so it's replaced
+	ip.Burst = 2
+	header.Header = "X-Other-Key"
+	limiters.Update([]remapdata.RemapRule{rule("kept", ip, header)})
+	updated := limiters.Get("kept")
+	if len(updated) != 2 {
+		t.Fatalf("expected 2 limiters after update, actual %v", len(updated))
+	}
+	if updated[0] != kept[0] {
+		t.Errorf("expected limit with the same key to keep its limiter")
+	}
+	if allowed, _ := updated[0].Allow("192.0.2.1", now); allowed {
+		t.Errorf("expected kept limiter to keep its empty bucket, actual allowed")
+	}
+	for i := 0; i < 2; i++ {
+		if allowed, _ := updated[0].Allow("192.0.2.2", now); !allowed {
+			t.Errorf("expected kept limiter to allow request %v of a new key within the new burst, actual limited", i)
+		}
+	}
+	if updated[1] == kept[1] {
+		t.Errorf("expected limit with a changed key to get a new limiter")
+	}
+
+	limiters.Update(nil)
+	if actual := limiters.Get("kept"); actual != nil {
+		t.Errorf("expected no limiters for removed rule, actual %v", actual)
+	}
+}
@@ -0,0 +1,49 @@
+package cache
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"net/http"
+
+	"github.com/apache/trafficcontrol/v8/grove/web"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+)
+
+// ReloadEndpoint is the path of the endpoint to reload the remap rules file. It requires the same authorization as purging, and is equivalent to the remap rules being reloaded by a SIGHUP, without reloading the config file.
+const ReloadEndpoint = "/_reload"
+
+// serveReload serves the ReloadEndpoint. POST reloads the remap rules, and responds with an error if they failed to load, in which case the existing rules are kept.
+func (h *Handler) serveReload(w http.ResponseWriter, r *http.Request) {
+	if code, ok := h.authorizeAdmin(r, "reload"); !ok {
+		web.ServeErr(w, code)
+		return
+	}
+	if r.Method != http.MethodPost {
+		web.ServeErr(w, http.StatusMethodNotAllowed)
+		return
+	}
+	if h.reloadRules == nil {
+		web.ServeErr(w, http.StatusNotImplemented)
+		return
+	}
+	if err := h.reloadRules(); err != nil {
+		log.Errorln("reload request: " + err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("reloading remap rules: " + err.Error()))
+		return
+	}
+	w.Write([]byte("remap rules reloaded\n"))
+}
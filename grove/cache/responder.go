@@ -15,8 +15,10 @@ package cache
 */
 
 import (
+	"io"
 	"net/http"
 
+	"github.com/apache/trafficcontrol/v8/grove/accesslog"
 	"github.com/apache/trafficcontrol/v8/grove/cachedata"
 	"github.com/apache/trafficcontrol/v8/grove/plugin"
 	"github.com/apache/trafficcontrol/v8/grove/stat"
@@ -36,6 +38,9 @@ type Responder struct {
 	Stats         stat.Stats
 	F             RespondFunc
 	ResponseCode  *int
+	// AccessLogs are written to after responding, selected by AccessLogSelections. If AccessLogSelections is nil, all access logs are written to.
+	AccessLogs          *accesslog.Logs
+	AccessLogSelections []accesslog.Selection
 	cachedata.ParentRespData
 	cachedata.SrvrData
 	cachedata.ReqData
@@ -60,18 +65,20 @@ func DefaultRespCode() *int {
 type RespondFunc func() (uint64, error)
 
 // NewResponder creates a Responder, which defaults to a generic error response.
-func NewResponder(w http.ResponseWriter, pluginCfg map[string]interface{}, pluginContext map[string]*interface{}, srvrData cachedata.SrvrData, reqData cachedata.ReqData, plugins plugin.Plugins, stats stat.Stats, reqID uint64) *Responder {
+func NewResponder(w http.ResponseWriter, pluginCfg map[string]interface{}, pluginContext map[string]*interface{}, srvrData cachedata.SrvrData, reqData cachedata.ReqData, plugins plugin.Plugins, stats stat.Stats, accessLogs *accesslog.Logs, accessLogSelections []accesslog.Selection, reqID uint64) *Responder {
 	responder := &Responder{
-		W:              w,
-		RequestID:      reqID,
-		PluginCfg:      pluginCfg,
-		Plugins:        plugins,
-		PluginContext:  pluginContext,
-		Stats:          stats,
-		ResponseCode:   DefaultRespCode(),
-		ParentRespData: DefaultParentRespData(),
-		SrvrData:       srvrData,
-		ReqData:        reqData,
+		W:                   w,
+		RequestID:           reqID,
+		PluginCfg:           pluginCfg,
+		Plugins:             plugins,
+		PluginContext:       pluginContext,
+		Stats:               stats,
+		ResponseCode:        DefaultRespCode(),
+		AccessLogs:          accessLogs,
+		AccessLogSelections: accessLogSelections,
+		ParentRespData:      DefaultParentRespData(),
+		SrvrData:            srvrData,
+		ReqData:             reqData,
 	}
 	responder.F = func() (uint64, error) { return web.ServeErr(w, *responder.ResponseCode) }
 	return responder
@@ -88,7 +95,19 @@ func (r *Responder) SetResponse(code *int, hdrs *http.Header, body *[]byte, conn
 	}
 }
 
-// Do responds to the client, according to the data in r, with the given code, headers, and body. It additionally writes to the event log, and adds statistics about this request. This should always be called for the final response to a client, in order to properly log, stat, and other final operations.
+// SetStreamResponse is a helper which sets the RespondFunc of r to `web.RespondStream` with the given code, headers, body, and connectionClose. The body is closed after responding.
+func (r *Responder) SetStreamResponse(code *int, hdrs *http.Header, body io.ReadCloser, connectionClose bool) {
+	r.ResponseCode = code
+	r.F = func() (uint64, error) {
+		defer body.Close()
+		if r.Req.Method == http.MethodHead {
+			return web.Respond(r.W, *code, *hdrs, nil, connectionClose)
+		}
+		return web.RespondStream(r.W, *code, *hdrs, body, connectionClose)
+	}
+}
+
+// Do responds to the client, according to the data in r, with the given code, headers, and body. It additionally writes to the access logs, and calls the AfterRespond plugins, which write to the event log and add statistics about this request. This should always be called for the final response to a client, in order to properly log, stat, and other final operations.
 // For cache misses, reuse should be ReuseCannot.
 // For parent connect failures, originCode should be 0.
 func (r *Responder) Do() {
@@ -103,6 +122,9 @@ func (r *Responder) Do() {
 	respData := cachedata.RespData{RespCode: *r.ResponseCode, BytesWritten: bytesSent, RespSuccess: respSuccess, CacheHit: isCacheHit(r.Reuse, r.OriginCode)}
 	arData := plugin.AfterRespondData{W: r.W, Stats: r.Stats, ReqData: r.ReqData, SrvrData: r.SrvrData, ParentRespData: r.ParentRespData, RespData: respData, RequestID: r.RequestID}
 	r.Plugins.OnAfterRespond(r.PluginCfg, r.PluginContext, arData)
+	if !r.AccessLogs.Empty() {
+		r.AccessLogs.Log(accesslog.NewEntry(r.W, r.RequestID, r.ReqData, r.SrvrData, r.ParentRespData, respData), r.AccessLogSelections)
+	}
 }
 
 func isCacheHit(reuse rfc.Reuse, originCode int) bool {
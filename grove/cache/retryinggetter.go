@@ -57,12 +57,16 @@ func (r *Retrier) Get(req *http.Request, obj *cacheobj.CacheObj) (*cacheobj.Cach
 	retryGetFunc := func(remapping remap.Remapping, retryFailures bool, obj *cacheobj.CacheObj) *cacheobj.CacheObj {
 		// return true for Revalidate, and issue revalidate requests separately.
 		canReuse := func(cacheObj *cacheobj.CacheObj) bool {
-			return cacheobj.CanReuse(r.ReqHdr, r.ReqCacheControl, cacheObj, r.H.strictRFC, true)
+			return cacheobj.CanReuse(r.ReqHdr, r.ReqCacheControl, cacheObj, r.H.strictRFC, true) && varyMatches(cacheObj, r.ReqHdr)
 		}
 		getAndCache := func() *cacheobj.CacheObj {
-			return GetAndCache(remapping.Request, remapping.ProxyURL, remapping.CacheKey, remapping.Name, remapping.Request.Header, r.ReqTime, r.H.strictRFC, remapping.Cache, r.H.ruleThrottlers[remapping.Name], obj, remapping.Timeout, retryFailures, remapping.RetryNum, remapping.RetryCodes, remapping.Transport, r.ReqID)
+			return GetAndCache(remapping.Request, remapping.ProxyURL, remapping.CacheKey, remapping.Name, remapping.Request.Header, r.ReqTime, r.H.strictRFC, remapping.Cache, r.H.ruleThrottlers.Get(remapping.Name), obj, remapping.Timeout, retryFailures, remapping.RetryNum, remapping.RetryCodes, remapping.MaxVariants, remapping.Transport, r.ReqID)
 		}
 		gotObj, getReqID := r.H.getter.Get(remapping.CacheKey, getAndCache, canReuse, r.ReqID)
+		if getReqID == r.ReqID {
+			// only the request which made the parent request reports it, so waiters for the same object don't count it again
+			r.RemappingProducer.ReportResult(remapping, !isFailure(gotObj, remapping.RetryCodes))
+		}
 
 		req := remapping.Request
 		log.Debugf("Retrier.Get Y URI %v %v %v remapping.CacheKey %v rule %v parent %v code %v headers %+v len(body) %v getterid %v (reqid %v)\n", req.URL.Scheme, req.URL.Host, req.URL.EscapedPath(), remapping.CacheKey, remapping.Name, remapping.ProxyURL, gotObj.Code, gotObj.RespHeaders, len(gotObj.Body), getReqID, r.ReqID)
@@ -103,6 +107,7 @@ func isFailure(o *cacheobj.CacheObj, retryCodes map[int]struct{}) bool {
 const ModifiedSinceHdr = "If-Modified-Since"
 
 // GetAndCache makes a client request for the given `http.Request` and caches it if `CanCache`.
+// If the response has a Vary header, it's cached as a variant of the primary object for the cacheKey, of which at most `maxVariants` are kept.
 // THe `ruleThrottler` may be nil, in which case the request will be unthrottled.
 func GetAndCache(
 	req *http.Request,
@@ -119,6 +124,7 @@ func GetAndCache(
 	cacheFailure bool,
 	retryNum int,
 	retryCodes map[int]struct{},
+	maxVariants int,
 	transport *http.Transport,
 	reqID uint64,
 ) *cacheobj.CacheObj {
@@ -191,7 +197,7 @@ func GetAndCache(
 				HitCount:         revalidateObj.HitCount, // no need to +1 here, the cache Get did that
 			}
 		}
-		cache.Add(cacheKey, obj) // TODO store pointer?
+		addVaried(cache, cacheKey, obj, maxVariants) // TODO store pointer?
 		return obj
 	}
 
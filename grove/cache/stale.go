@@ -0,0 +1,126 @@
+package cache
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/grove/cacheobj"
+	"github.com/apache/trafficcontrol/v8/grove/remap"
+	"github.com/apache/trafficcontrol/v8/grove/stream"
+	"github.com/apache/trafficcontrol/v8/grove/web"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/apache/trafficcontrol/v8/lib/go-rfc"
+)
+
+const (
+	// StaleWhileRevalidateDirective is the Cache-Control directive for the seconds a stale response may be served while it's revalidated in the background, per RFC5861§3.
+	StaleWhileRevalidateDirective = "stale-while-revalidate"
+	// StaleIfErrorDirective is the Cache-Control directive for the seconds a stale response may be served if revalidating it fails with an error, per RFC5861§4.
+	StaleIfErrorDirective = "stale-if-error"
+)
+
+// staleness returns how long the given object has been stale. This is negative if the object is fresh.
+func staleness(obj *cacheobj.CacheObj) time.Duration {
+	return -rfc.FreshFor(obj.RespHeaders, obj.RespCacheControl, obj.ReqRespTime, obj.RespRespTime)
+}
+
+// staleWindow returns the duration of the given Cache-Control directive of the response, or ruleDefault if the response doesn't have the directive or its value is invalid.
+func staleWindow(respCC rfc.CacheControlMap, directive string, ruleDefault time.Duration) time.Duration {
+	val, ok := respCC[directive]
+	if !ok {
+		return ruleDefault
+	}
+	seconds, err := strconv.ParseUint(strings.TrimSpace(val), 10, 64)
+	if err != nil {
+		return ruleDefault
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// inStaleWindow returns whether the given stale object is within the window of the given Cache-Control directive, or the remap rule default if the response doesn't have the directive.
+func inStaleWindow(obj *cacheobj.CacheObj, directive string, ruleDefault time.Duration) bool {
+	window := staleWindow(obj.RespCacheControl, directive, ruleDefault)
+	return window > 0 && staleness(obj) <= window
+}
+
+// canStaleWhileRevalidate returns whether the given stale object may be served while it's revalidated in the background. The reuse must be the object's reuse after any purge rules, and stale objects which must be revalidated are never served.
+func canStaleWhileRevalidate(obj *cacheobj.CacheObj, reuse rfc.Reuse, remappingProducer *remap.RemappingProducer) bool {
+	return reuse == rfc.ReuseMustRevalidateCanStale && inStaleWindow(obj, StaleWhileRevalidateDirective, remappingProducer.StaleWhileRevalidate())
+}
+
+// canStaleIfError returns whether the given stale object may be served instead of the parent response with the given code. The reuse must be the object's reuse after any purge rules, and stale objects which must be revalidated are never served.
+func canStaleIfError(obj *cacheobj.CacheObj, reuse rfc.Reuse, code int, remappingProducer *remap.RemappingProducer) bool {
+	return reuse == rfc.ReuseMustRevalidateCanStale && isStaleErrorCode(code) && inStaleWindow(obj, StaleIfErrorDirective, remappingProducer.StaleIfError())
+}
+
+// isStaleErrorCode returns whether the given parent response code is an error for which a stale-if-error object may be served, per RFC5861§4.
+func isStaleErrorCode(code int) bool {
+	switch code {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backgroundRequest returns copies of the client's request and RemappingProducer, for a revalidation in the background. The client's request may not be used after ServeHTTP returns, and the client's RemappingProducer is still used to respond to it. The copied request isn't canceled when the client's request is.
+func backgroundRequest(r *http.Request, remappingProducer *remap.RemappingProducer) (*http.Request, *remap.RemappingProducer) {
+	return r.Clone(context.Background()), remappingProducer.Copy()
+}
+
+// revalidateInBackground starts revalidating the given stale object with the parent in a goroutine, for an object served stale while revalidating. Concurrent revalidations of the same object are coalesced by the Handler's Getter.
+func (h *Handler) revalidateInBackground(retrier *Retrier, r *http.Request, obj *cacheobj.CacheObj, cacheKey string, reqID uint64) {
+	r, remappingProducer := backgroundRequest(r, retrier.RemappingProducer)
+	retrier = NewRetrier(h, web.CopyHeader(retrier.ReqHdr), retrier.ReqTime, retrier.ReqCacheControl, remappingProducer, reqID)
+	go func() {
+		newObj, _, err := retrier.Get(r, obj)
+		if err != nil {
+			log.Errorf("revalidating '%v' in background: %v (reqid %v)\n", cacheKey, err, reqID)
+			return
+		}
+		log.Debugf("revalidated '%v' in background: %v (reqid %v)\n", cacheKey, newObj.Code, reqID)
+	}()
+}
+
+// revalidateStreamInBackground starts revalidating the given stale object with the parent in a goroutine, storing the response in the given Body, for a streamed object served stale while revalidating. Concurrent requests for the object read the Body as usual.
+func (h *Handler) revalidateStreamInBackground(
+	body *stream.Body,
+	r *http.Request,
+	remappingProducer *remap.RemappingProducer,
+	reqHeader http.Header,
+	reqTime time.Time,
+	revalidateObj *cacheobj.CacheObj,
+	reqID uint64,
+) {
+	r, remappingProducer = backgroundRequest(r, remappingProducer)
+	reqHeader = web.CopyHeader(reqHeader)
+	go func() {
+		go h.fetchStream(body, r, remappingProducer, reqHeader, reqTime, revalidateObj, true, reqID)
+		obj, _, err := body.WaitObj()
+		if err != nil {
+			log.Errorf("revalidating '%v' in background: %v (reqid %v)\n", remappingProducer.CacheKey(), err, reqID)
+			return
+		}
+		if passthrough := body.Passthrough(); passthrough != nil {
+			passthrough.Close() // the response can't be stored, and nothing else may read it
+		}
+		log.Debugf("revalidated '%v' in background: %v (reqid %v)\n", remappingProducer.CacheKey(), obj.Code, reqID)
+	}()
+}
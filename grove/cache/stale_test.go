@@ -0,0 +1,246 @@
+package cache
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/grove/accesslog"
+	"github.com/apache/trafficcontrol/v8/grove/cacheobj"
+	"github.com/apache/trafficcontrol/v8/grove/health"
+	"github.com/apache/trafficcontrol/v8/grove/icache"
+	"github.com/apache/trafficcontrol/v8/grove/memcache"
+	"github.com/apache/trafficcontrol/v8/grove/peer"
+	"github.com/apache/trafficcontrol/v8/grove/plugin"
+	"github.com/apache/trafficcontrol/v8/grove/purge"
+	"github.com/apache/trafficcontrol/v8/grove/remap"
+	"github.com/apache/trafficcontrol/v8/grove/stat"
+	"github.com/apache/trafficcontrol/v8/grove/stream"
+	"github.com/apache/trafficcontrol/v8/grove/thread"
+	"github.com/apache/trafficcontrol/v8/grove/web"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-rfc"
+)
+
+func TestStaleWindow(t *testing.T) {
+	type testCase struct {
+		cacheControl string
+		ruleDefault  time.Duration
+		expected     time.Duration
+	}
+	testCases := []testCase{
+		{cacheControl: "max-age=60", ruleDefault: 0, expected: 0},
+		{cacheControl: "max-age=60", ruleDefault: 30 * time.Second, expected: 30 * time.Second},
+		{cacheControl: "max-age=60, stale-while-revalidate=10", ruleDefault: 30 * time.Second, expected: 10 * time.Second},
+		{cacheControl: "max-age=60, stale-while-revalidate=0", ruleDefault: 30 * time.Second, expected: 0},
+		{cacheControl: "max-age=60, stale-while-revalidate=foo", ruleDefault: 30 * time.Second, expected: 30 * time.Second},
+	}
+	for _, tc := range testCases {
+		respCC := rfc.ParseCacheControl(http.Header{"Cache-Control": {tc.cacheControl}})
+		if actual := staleWindow(respCC, StaleWhileRevalidateDirective, tc.ruleDefault); actual != tc.expected {
+			t.Errorf("staleWindow('%v', %v) expected %v, actual %v", tc.cacheControl, tc.ruleDefault, tc.expected, actual)
+		}
+	}
+}
+
+func TestInStaleWindow(t *testing.T) {
+	staleObj := func(cacheControl string, stale time.Duration) *cacheobj.CacheObj {
+		respTime := time.Now().Add(-(time.Minute + stale))
+		respHdr := http.Header{"Cache-Control": {cacheControl}, "Date": {respTime.Format(http.TimeFormat)}}
+		return cacheobj.New(http.Header{}, nil, http.StatusOK, http.StatusOK, "", respHdr, respTime, respTime, respTime, respTime)
+	}
+
+	if obj := staleObj("max-age=60, stale-if-error=30", 10*time.Second); !inStaleWindow(obj, StaleIfErrorDirective, 0) {
+		t.Errorf("inStaleWindow 10s stale with stale-if-error=30 expected true, actual false")
+	}
+	if obj := staleObj("max-age=60, stale-if-error=30", 40*time.Second); inStaleWindow(obj, StaleIfErrorDirective, 0) {
+		t.Errorf("inStaleWindow 40s stale with stale-if-error=30 expected false, actual true")
+	}
+	if obj := staleObj("max-age=60", 10*time.Second); inStaleWindow(obj, StaleIfErrorDirective, 0) {
+		t.Errorf("inStaleWindow without directive or rule default expected false, actual true")
+	}
+	if obj := staleObj("max-age=60", 10*time.Second); !inStaleWindow(obj, StaleIfErrorDirective, 30*time.Second) {
+		t.Errorf("inStaleWindow without directive with rule default 30s expected true, actual false")
+	}
+}
+
+func TestIsStaleErrorCode(t *testing.T) {
+	for _, code := range []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout} {
+		if !isStaleErrorCode(code) {
+			t.Errorf("isStaleErrorCode(%v) expected true, actual false", code)
+		}
+	}
+	for _, code := range []int{http.StatusOK, http.StatusNotModified, http.StatusNotFound, http.StatusNotImplemented} {
+		if isStaleErrorCode(code) {
+			t.Errorf("isStaleErrorCode(%v) expected false, actual true", code)
+		}
+	}
+}
+
+// testParent is a parent whose responses are set by tests, and which records the requests it receives.
+type testParent struct {
+	srv     *httptest.Server
+	m       sync.Mutex
+	code    int
+	cc      string
+	body    string
+	reqHdrs []http.Header
+}
+
+func newTestParent(t *testing.T) *testParent {
+	p := &testParent{code: http.StatusOK}
+	p.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.m.Lock()
+		code, cc, body := p.code, p.cc, p.body
+		p.reqHdrs = append(p.reqHdrs, r.Header.Clone())
+		p.m.Unlock()
+		// the Date is in the past, so responses are already stale when they're stored
+		w.Header().Set("Date", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+		w.Header().Set("Last-Modified", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+		w.Header().Set("Cache-Control", cc)
+		w.WriteHeader(code)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(p.srv.Close)
+	return p
+}
+
+func (p *testParent) set(code int, cc string, body string) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	p.code, p.cc, p.body = code, cc, body
+}
+
+func (p *testParent) requests() []http.Header {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return append([]http.Header(nil), p.reqHdrs...)
+}
+
+// newTestHandler creates a Handler with a single remap rule from http://foo.example.net to the given parent, with the given extra rule JSON fields, and a memory cache.
+func newTestHandler(t *testing.T, parentURL string, ruleFields string) (*Handler, icache.Cache) {
+	rulesPath := filepath.Join(t.TempDir(), "remap.json")
+	rules := `{"retry_num": 0, "retry_codes": [], "parent_selection": "consistent-hash", "timeout_ms": 5000, "rules": [{"name": "foo", "from": "http://foo.example.net", "to": [{"url": "` + parentURL + `"}]` + ruleFields + `}]}`
+	if err := os.WriteFile(rulesPath, []byte(rules), 0600); err != nil {
+		t.Fatalf("writing remap rules: %v", err)
+	}
+	cache := memcache.New(1024 * 1024)
+	caches := map[string]icache.Cache{"": cache}
+	transport := remap.NewRemappingTransport(5*time.Second, 5*time.Second, 10, 5*time.Second)
+	peers := peer.New(transport)
+	plugins := plugin.Get([]string{})
+	remapper, err := remap.LoadRemapper(rulesPath, plugins.LoadFuncs(), caches, transport, health.New(), peers)
+	if err != nil {
+		t.Fatalf("loading remap rules: %v", err)
+	}
+	ruleThrottlers := NewRuleThrottlers()
+	ruleThrottlers.Update(remapper.Rules(), 0)
+	rateLimiters := NewRuleRateLimiters()
+	rateLimiters.Update(remapper.Rules())
+	httpConns, httpsConns := web.NewConnMap(), web.NewConnMap()
+	stats := stat.New(remapper.Rules(), caches, 1024*1024, httpConns, httpsConns, "test")
+	h := NewHandler(remapper, ruleThrottlers, rateLimiters, thread.NewGetter(), stats, "http", "80", httpConns, false, false, plugins, map[string]*interface{}{}, httpConns, httpsConns, "", stream.NewStreams(), 1024, purge.New(), "", peers, accesslog.New(), func() error { return nil })
+	return h, cache
+}
+
+// get requests the given path from the handler, and returns the response code and body.
+func get(t *testing.T, h *Handler, path string) (int, string) {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	r.Host = "foo.example.net"
+	r.Header.Set("X-Client", "client-value")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	body, err := io.ReadAll(w.Result().Body)
+	if err != nil {
+		t.Fatalf("reading response body: %v", err)
+	}
+	return w.Code, string(body)
+}
+
+// waitFor polls until f returns true, failing the test if it doesn't within a few seconds.
+func waitFor(t *testing.T, msg string, f func() bool) {
+	for deadline := time.Now().Add(5 * time.Second); !f(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %v", msg)
+		}
+	}
+}
+
+func TestHandlerStaleWhileRevalidate(t *testing.T) {
+	for _, streamed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("stream=%v", streamed), func(t *testing.T) {
+			parent := newTestParent(t)
+			parent.set(http.StatusOK, "max-age=1, stale-while-revalidate=3600", "first")
+			h, _ := newTestHandler(t, parent.srv.URL, fmt.Sprintf(`, "stream": %v`, streamed))
+
+			if code, body := get(t, h, "/obj"); code != http.StatusOK || body != "first" {
+				t.Fatalf("expected miss to be 200 'first', actual %v '%v'", code, body)
+			}
+
+			parent.set(http.StatusOK, "max-age=1, stale-while-revalidate=3600", "second")
+			if code, body := get(t, h, "/obj"); code != http.StatusOK || body != "first" {
+				t.Errorf("expected stale object to be served while revalidating, 200 'first', actual %v '%v'", code, body)
+			}
+			waitFor(t, "the background revalidation", func() bool { return len(parent.requests()) == 2 })
+			if reqHdr := parent.requests()[1]; reqHdr.Get("X-Client") != "client-value" || reqHdr.Get(ModifiedSinceHdr) == "" {
+				t.Errorf("expected the background revalidation to have the client's headers and If-Modified-Since, actual %v", reqHdr)
+			}
+
+			// the revalidated object is also stale, so it's served while revalidating again
+			waitFor(t, "the refreshed object", func() bool {
+				_, body := get(t, h, "/obj")
+				return body == "second"
+			})
+		})
+	}
+}
+
+func TestHandlerStaleIfError(t *testing.T) {
+	for _, streamed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("stream=%v", streamed), func(t *testing.T) {
+			parent := newTestParent(t)
+			parent.set(http.StatusOK, "max-age=1, stale-if-error=3600", "first")
+			h, _ := newTestHandler(t, parent.srv.URL, fmt.Sprintf(`, "stream": %v`, streamed))
+
+			if code, body := get(t, h, "/obj"); code != http.StatusOK || body != "first" {
+				t.Fatalf("expected miss to be 200 'first', actual %v '%v'", code, body)
+			}
+			if code, body := get(t, h, "/other"); code != http.StatusOK || body != "first" {
+				t.Fatalf("expected miss to be 200 'first', actual %v '%v'", code, body)
+			}
+
+			parent.set(http.StatusServiceUnavailable, "no-store", "error")
+			if code, body := get(t, h, "/obj"); code != http.StatusOK || body != "first" {
+				t.Errorf("expected stale object to be served on parent error, 200 'first', actual %v '%v'", code, body)
+			}
+			if n := len(parent.requests()); n != 3 {
+				t.Errorf("expected the stale object to be revalidated with the parent, actual %v parent requests", n)
+			}
+
+			parent.set(http.StatusNotFound, "no-store", "not found")
+			if code, body := get(t, h, "/other"); code != http.StatusNotFound || body != "not found" {
+				t.Errorf("expected parent response which isn't an error to be served, 404 'not found', actual %v '%v'", code, body)
+			}
+		})
+	}
+}
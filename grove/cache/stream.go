@@ -0,0 +1,305 @@
+package cache
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/grove/cacheobj"
+	"github.com/apache/trafficcontrol/v8/grove/icache"
+	"github.com/apache/trafficcontrol/v8/grove/plugin"
+	"github.com/apache/trafficcontrol/v8/grove/remap"
+	"github.com/apache/trafficcontrol/v8/grove/stream"
+	"github.com/apache/trafficcontrol/v8/grove/thread"
+	"github.com/apache/trafficcontrol/v8/grove/web"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/apache/trafficcontrol/v8/lib/go-rfc"
+)
+
+// serveStream serves a request for a remap rule with streaming enabled. Cache misses are served to the client as they're received from the parent, while being stored in chunks in the cache, and concurrent requests for the same object read it as it's received, rather than making their own parent request.
+func (h *Handler) serveStream(
+	r *http.Request,
+	responder *Responder,
+	remappingProducer *remap.RemappingProducer,
+	cache icache.ChunkCache,
+	reqHeader http.Header,
+	reqTime time.Time,
+	reqCacheControl rfc.CacheControlMap,
+	connectionClose bool,
+	pluginContext map[string]*interface{},
+	reqID uint64,
+) {
+	cacheKey := remappingProducer.CacheKey()
+
+	cacheObj, objKey, ok := getVaried(cache, cacheKey, reqHeader)
+	if objKey != cacheKey {
+		remappingProducer.OverrideCacheKey(objKey)
+		cacheKey = objKey
+	}
+	if ok && !cacheObj.Chunked {
+		ok = false // objects stored before streaming was enabled are replaced
+	}
+
+	canReuseStored := rfc.ReuseCannot
+	if ok {
+		canReuseStored = rfc.CanReuseStored(r.Header, cacheObj.RespHeaders, reqCacheControl, cacheObj.RespCacheControl, cacheObj.ReqHeaders, cacheObj.ReqRespTime, cacheObj.RespRespTime, h.strictRFC)
+		canReuseStored = h.purgedReuse(cacheKey, cacheObj, canReuseStored, reqID)
+		if canReuseStored == rfc.ReuseCan {
+			log.Debugf("cache.Handler.serveStream: '%v' cache hit! (reqid %v)\n", cacheKey, reqID)
+			h.respondStream(r, responder, remappingProducer, cacheObj, stream.NewCacheReader(cache, cacheKey, cacheObj), canReuseStored, connectionClose, pluginContext)
+			return
+		}
+	}
+
+	beforeParentRequestData := plugin.BeforeParentRequestData{Req: r, RemapRule: remappingProducer.Name()}
+	h.plugins.OnBeforeParentRequest(remappingProducer.PluginCfg(), pluginContext, beforeParentRequestData)
+
+	revalidateObj := (*cacheobj.CacheObj)(nil)
+	if canReuseStored == rfc.ReuseMustRevalidate || canReuseStored == rfc.ReuseMustRevalidateCanStale {
+		revalidateObj = cacheObj
+	}
+
+	body, isAuthor := h.streams.GetOrCreate(cacheKey, cache)
+	if canStaleWhileRevalidate(cacheObj, canReuseStored, remappingProducer) {
+		log.Debugf("cache.Handler.serveStream: '%v' stale, serving while revalidating (reqid %v)\n", cacheKey, reqID)
+		if isAuthor {
+			h.revalidateStreamInBackground(body, r, remappingProducer, reqHeader, reqTime, revalidateObj, reqID)
+		}
+		h.respondStream(r, responder, remappingProducer, cacheObj, stream.NewCacheReader(cache, cacheKey, cacheObj), rfc.ReuseCan, connectionClose, pluginContext)
+		return
+	}
+	if isAuthor {
+		go h.fetchStream(body, r, remappingProducer, reqHeader, reqTime, revalidateObj, true, reqID)
+	}
+
+	obj, shared, err := body.WaitObj()
+	reader := io.ReadCloser(nil)
+	if err == nil {
+		if shared && varyMatches(obj, reqHeader) {
+			reader, shared = body.NewReader()
+		} else if isAuthor {
+			reader = body.Passthrough()
+		}
+		if reader == nil {
+			// The object can't be shared, so this request makes its own, which isn't stored.
+			log.Debugf("cache.Handler.serveStream: '%v' in-flight object can't be shared, requesting (reqid %v)\n", cacheKey, reqID)
+			body = stream.NewBody(cacheKey, nil)
+			go h.fetchStream(body, r, remappingProducer, reqHeader, reqTime, nil, false, reqID)
+			obj, _, err = body.WaitObj()
+			reader = body.Passthrough()
+		}
+	}
+
+	if err != nil {
+		if canReuseStored == rfc.ReuseMustRevalidateCanStale {
+			log.Errorf("streaming get error - serving stale as allowed: %v (reqid %v)\n", err, reqID)
+			h.respondStream(r, responder, remappingProducer, cacheObj, stream.NewCacheReader(cache, cacheKey, cacheObj), canReuseStored, connectionClose, pluginContext)
+			return
+		}
+		log.Errorf("streaming get error: %v (reqid %v)\n", err, reqID)
+		responder.OriginConnectFailed = true
+		responder.Do()
+		return
+	}
+	if canStaleIfError(cacheObj, canReuseStored, obj.Code, remappingProducer) {
+		log.Errorf("streaming get returned %v - serving stale as allowed by stale-if-error (reqid %v)\n", obj.Code, reqID)
+		reader.Close()
+		h.respondStream(r, responder, remappingProducer, cacheObj, stream.NewCacheReader(cache, cacheKey, cacheObj), canReuseStored, connectionClose, pluginContext)
+		return
+	}
+	h.respondStream(r, responder, remappingProducer, obj, reader, canReuseStored, connectionClose, pluginContext)
+}
+
+// respondStream responds to the client with the given object and body reader, after calling the BeforeRespond plugins. If a plugin changes the code or sets a body, that response is sent instead, and the reader is closed.
+func (h *Handler) respondStream(
+	r *http.Request,
+	responder *Responder,
+	remappingProducer *remap.RemappingProducer,
+	obj *cacheobj.CacheObj,
+	reader io.ReadCloser,
+	reuse rfc.Reuse,
+	connectionClose bool,
+	pluginContext map[string]*interface{},
+) {
+	// create new pointers, so plugins don't modify the cacheObj
+	codePtr, hdrsPtr, bodyPtr := obj.Code, obj.RespHeaders, []byte(nil)
+	responder.OriginReqSuccess = true
+	responder.Reuse = reuse
+	responder.OriginCode = obj.OriginCode
+	responder.OriginBytes = obj.Size
+	responder.ProxyStr = obj.ProxyURL
+	beforeRespData := plugin.BeforeRespondData{Req: r, CacheObj: obj, Code: &codePtr, Hdr: &hdrsPtr, Body: &bodyPtr, RemapRule: remappingProducer.Name(), Stream: true}
+	h.plugins.OnBeforeRespond(remappingProducer.PluginCfg(), pluginContext, beforeRespData)
+	if codePtr != obj.Code || bodyPtr != nil {
+		reader.Close()
+		responder.SetResponse(&codePtr, &hdrsPtr, &bodyPtr, connectionClose)
+	} else {
+		responder.SetStreamResponse(&codePtr, &hdrsPtr, reader, connectionClose)
+	}
+	responder.Do()
+}
+
+// fetchStream requests the object from the parent, retrying according to the RemappingProducer, and sets the response in the given body. If store, and the response is cacheable, the body is written in chunks of the Handler's stream chunk size, and the object is added to the cache when the body is complete. Otherwise, the body is a passthrough, and this returns when it's closed.
+func (h *Handler) fetchStream(
+	body *stream.Body,
+	r *http.Request,
+	remappingProducer *remap.RemappingProducer,
+	reqHeader http.Header,
+	reqTime time.Time,
+	revalidateObj *cacheobj.CacheObj,
+	store bool,
+	reqID uint64,
+) {
+	ruleThrottler := h.ruleThrottlers.Get(remappingProducer.Name())
+	if ruleThrottler == nil {
+		log.Errorf("rule %v not in ruleThrottlers map. Requesting with no origin limit! (reqid %v)\n", remappingProducer.Name(), reqID)
+		ruleThrottler = thread.NewNoThrottler()
+	}
+	ruleThrottler.Throttle(func() {
+		h.fetchStreamUnthrottled(body, r, remappingProducer, reqHeader, reqTime, revalidateObj, store, reqID)
+	})
+}
+
+func (h *Handler) fetchStreamUnthrottled(
+	body *stream.Body,
+	r *http.Request,
+	remappingProducer *remap.RemappingProducer,
+	reqHeader http.Header,
+	reqTime time.Time,
+	revalidateObj *cacheobj.CacheObj,
+	store bool,
+	reqID uint64,
+) {
+	cacheKey := remappingProducer.CacheKey()
+	resp := (*http.Response)(nil)
+	remapping := remap.Remapping{}
+	reqRespTime := time.Time{}
+	err := errors.New("remapping producer allows no requests") // should never happen
+	for {
+		retryAllowed := false
+		remapping, retryAllowed, err = remappingProducer.GetNext(r)
+		if err == remap.ErrNoMoreRetries {
+			err = errors.New("all parents failed")
+			break
+		} else if err != nil {
+			break
+		}
+
+		req := remapping.Request
+		if store {
+			req.Method = http.MethodGet // HEAD uses the same key as GET, so the whole object must be requested to store it
+		}
+		if revalidateObj != nil {
+			req.Header.Set(ModifiedSinceHdr, revalidateObj.LastModified.Format(time.RFC1123))
+		} else if store {
+			req.Header.Del(ModifiedSinceHdr) // the client's revalidation can't be used to store the object
+		}
+
+		reqTime = time.Now()
+		resp, err = remapping.Transport.RoundTrip(req)
+		reqRespTime = time.Now()
+		if err != nil {
+			log.Errorf("Parent error for URI %v %v %v cacheKey %v rule %v error %v (reqid %v)\n", req.URL.Scheme, req.URL.Host, req.URL.EscapedPath(), cacheKey, remapping.Name, err, reqID)
+			remappingProducer.ReportResult(remapping, false)
+			continue
+		}
+		_, isRetryCode := remapping.RetryCodes[resp.StatusCode]
+		remappingProducer.ReportResult(remapping, !isRetryCode)
+		if isRetryCode && !retryAllowed {
+			resp.Body.Close()
+			err = errors.New("parent returned retry code " + http.StatusText(resp.StatusCode))
+			continue
+		}
+		break
+	}
+	if err != nil {
+		body.Fail(err)
+		return
+	}
+
+	proxyURLStr := ""
+	if remapping.ProxyURL != nil {
+		proxyURLStr = remapping.ProxyURL.Host
+	}
+	respRespTime, ok := rfc.GetHTTPDate(resp.Header, "Date")
+	if !ok {
+		log.Errorf("request %v returned no Date header - RFC Violation! Using local response timestamp (reqid %v)\n", r.RequestURI, reqID)
+		respRespTime = reqRespTime // if no Date was returned using the client response time simulates latency 0
+	}
+	lastModified, ok := rfc.GetHTTPDate(resp.Header, "Last-Modified")
+	if !ok {
+		lastModified = respRespTime
+	}
+
+	if revalidateObj != nil && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		log.Debugf("fetchStream revalidating %v (reqid %v)\n", cacheKey, reqID)
+		// must copy, because this cache object may be concurrently read by other goroutines
+		obj := *revalidateObj
+		obj.RespHeaders = web.CopyHeader(revalidateObj.RespHeaders)
+		obj.RespHeaders.Set("Date", resp.Header.Get("Date"))
+		obj.OriginCode = resp.StatusCode
+		obj.ProxyURL = proxyURLStr
+		obj.ReqTime = reqTime
+		obj.ReqRespTime = reqRespTime
+		obj.RespRespTime = respRespTime
+		addVaried(remappingProducer.Cache(), cacheKey, &obj, remappingProducer.MaxVariants())
+		body.FinishCached(&obj)
+		return
+	}
+
+	obj := cacheobj.New(reqHeader, nil, resp.StatusCode, resp.StatusCode, proxyURLStr, resp.Header, reqTime, reqRespTime, respRespTime, lastModified)
+	vary, canVary := varyHeaders(resp.Header)
+	if !store || !canVary || !rfc.CanCache(http.MethodGet, reqHeader, resp.StatusCode, resp.Header, h.strictRFC) {
+		<-body.SetPassthrough(obj, resp.Body)
+		return
+	}
+	storeKey := primaryKey(cacheKey)
+	if len(vary) > 0 {
+		storeKey = variantKey(storeKey, vary, reqHeader)
+	}
+	obj.Chunked = true
+	obj.ChunkID = cacheobj.NewChunkID()
+	body.SetObj(storeKey, obj)
+
+	defer resp.Body.Close()
+	for {
+		chunk := make([]byte, h.streamChunkBytes)
+		n, err := io.ReadFull(resp.Body, chunk)
+		if n > 0 {
+			body.Write(chunk[:n])
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			log.Errorf("reading parent body for cacheKey %v rule %v: %v (reqid %v)\n", cacheKey, remapping.Name, err, reqID)
+			body.Finish(err)
+			return
+		}
+	}
+
+	if body.Stored() {
+		// must copy, because obj may be concurrently read by other goroutines
+		storedObj := *obj
+		storedObj.ChunkCount = body.Count()
+		storedObj.Size = body.Size()
+		addVaried(remappingProducer.Cache(), cacheKey, &storedObj, remappingProducer.MaxVariants())
+	}
+	body.Finish(nil)
+}
@@ -0,0 +1,65 @@
+package cache
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"sync"
+
+	"github.com/apache/trafficcontrol/v8/grove/remapdata"
+	"github.com/apache/trafficcontrol/v8/grove/thread"
+)
+
+// RuleThrottlers are the throttlers of parent requests for each remap rule. They're shared by all Handlers, and kept across remap rule reloads, so each rule's limit applies to all its parent requests, including those which started before a reload.
+type RuleThrottlers struct {
+	throttlers map[string]thread.ResizableThrottler
+	m          sync.RWMutex
+}
+
+func NewRuleThrottlers() *RuleThrottlers {
+	return &RuleThrottlers{throttlers: map[string]thread.ResizableThrottler{}}
+}
+
+// Update sets the throttlers to those of the given rules. Rules without a concurrent_rule_requests limit use the defaultLimit.
+//
+// Rules which still exist keep their throttler, with the new limit. Throttlers of new rules are created, and those of removed rules are deleted, but requests already using them continue to be throttled until they finish.
+func (t *RuleThrottlers) Update(rules []remapdata.RemapRule, defaultLimit uint64) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	throttlers := make(map[string]thread.ResizableThrottler, len(rules))
+	for _, rule := range rules {
+		limit := uint64(rule.ConcurrentRuleRequests)
+		if rule.ConcurrentRuleRequests == 0 {
+			limit = defaultLimit
+		}
+		throttler, ok := t.throttlers[rule.Name]
+		if !ok {
+			throttler = thread.NewResizableThrottler(limit)
+		} else if throttler.Max() != limit {
+			throttler.SetMax(limit)
+		}
+		throttlers[rule.Name] = throttler
+	}
+	t.throttlers = throttlers
+}
+
+// Get returns the throttler of the given rule name, or nil if there is no rule with that name.
+func (t *RuleThrottlers) Get(ruleName string) thread.Throttler {
+	t.m.RLock()
+	defer t.m.RUnlock()
+	if throttler, ok := t.throttlers[ruleName]; ok {
+		return throttler
+	}
+	return nil // must be an untyped nil, so callers can check it
+}
@@ -0,0 +1,76 @@
+package cache
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/grove/remapdata"
+	"github.com/apache/trafficcontrol/v8/grove/thread"
+)
+
+func TestRuleThrottlersUpdate(t *testing.T) {
+	rule := func(name string, limit int) remapdata.RemapRule {
+		return remapdata.RemapRule{RemapRuleBase: remapdata.RemapRuleBase{Name: name, ConcurrentRuleRequests: limit}}
+	}
+	throttlers := NewRuleThrottlers()
+	throttlers.Update([]remapdata.RemapRule{rule("kept", 1), rule("removed", 0)}, 10)
+
+	kept := throttlers.Get("kept")
+	if kept == nil {
+		t.Fatalf("expected kept rule throttler, actual nil")
+	}
+	if removed := throttlers.Get("removed"); removed == nil {
+		t.Errorf("expected removed rule throttler before update, actual nil")
+	} else if max := removed.(thread.ResizableThrottler).Max(); max != 10 {
+		t.Errorf("expected rule with no limit to have default limit 10, actual %v", max)
+	}
+
+	// a request in progress on the kept rule, which must still count against its limit after the update
+	started, finish, finished := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	go kept.Throttle(func() {
+		close(started)
+		<-finish
+	})
+	<-started
+
+	throttlers.Update([]remapdata.RemapRule{rule("kept", 1), rule("added", 2)}, 10)
+	if actual := throttlers.Get("kept"); actual != kept {
+		t.Errorf("expected kept rule to keep its throttler")
+	}
+	if actual := throttlers.Get("removed"); actual != nil {
+		t.Errorf("expected no removed rule throttler after update, actual %v", actual)
+	}
+	if actual := throttlers.Get("added"); actual == nil {
+		t.Errorf("expected added rule throttler after update, actual nil")
+	}
+
+	go throttlers.Get("kept").Throttle(func() { close(finished) })
+	select {
+	case <-finished:
+		t.Errorf("expected request to wait for the request in progress before the update")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// increasing the limit lets the waiting request proceed
+	throttlers.Update([]remapdata.RemapRule{rule("kept", 2)}, 10)
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Errorf("expected waiting request to proceed after the limit increased")
+	}
+	close(finish)
+}
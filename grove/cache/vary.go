@@ -0,0 +1,250 @@
+package cache
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/apache/trafficcontrol/v8/grove/cacheobj"
+	"github.com/apache/trafficcontrol/v8/grove/icache"
+)
+
+// varySep separates the primary key of a varied object from the request header values identifying the variant. It can't occur in a URL, so variant keys never collide with primary keys.
+const varySep = "\x00vary\x00"
+
+// variantsM serializes updating the variant lists of primary objects, so concurrent requests for different variants don't lose each other's variants.
+var variantsM sync.Mutex
+
+// varyHeaders returns the canonical, sorted, unique names of the request headers in the Vary header of the given response header, and false if the response varies by `*`, and thus never matches a later request.
+func varyHeaders(respHdr http.Header) ([]string, bool) {
+	names := []string{}
+	seen := map[string]struct{}{}
+	for _, val := range respHdr.Values("Vary") {
+		for _, name := range strings.Split(val, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if name == "*" {
+				return nil, false
+			}
+			name = http.CanonicalHeaderKey(name)
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			seen[name] = struct{}{}
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, true
+}
+
+// normalizeVaryValue returns the value of the given request header, normalized so requests which must get the same variant get the same value.
+//
+// Accept-Encoding is normalized to the sorted, unique, lower-case codings which aren't refused with q=0, with the x-gzip and x-compress aliases replaced. Other headers are the comma-delimited values with surrounding whitespace removed.
+func normalizeVaryValue(name string, vals []string) string {
+	if name != "Accept-Encoding" {
+		parts := []string{}
+		for _, val := range vals {
+			for _, part := range strings.Split(val, ",") {
+				if part = strings.TrimSpace(part); part != "" {
+					parts = append(parts, part)
+				}
+			}
+		}
+		return strings.Join(parts, ",")
+	}
+
+	codings := []string{}
+	seen := map[string]struct{}{}
+	for _, val := range vals {
+		for _, part := range strings.Split(val, ",") {
+			params := strings.Split(part, ";")
+			coding := strings.ToLower(strings.TrimSpace(params[0]))
+			if coding == "" || refused(params[1:]) {
+				continue
+			}
+			switch coding {
+			case "x-gzip":
+				coding = "gzip"
+			case "x-compress":
+				coding = "compress"
+			}
+			if _, ok := seen[coding]; ok {
+				continue
+			}
+			seen[coding] = struct{}{}
+			codings = append(codings, coding)
+		}
+	}
+	sort.Strings(codings)
+	return strings.Join(codings, ",")
+}
+
+// refused returns whether the given Accept-Encoding coding parameters have a quality of 0.
+func refused(params []string) bool {
+	for _, param := range params {
+		param = strings.ToLower(strings.Replace(param, " ", "", -1))
+		if !strings.HasPrefix(param, "q=") {
+			continue
+		}
+		q := strings.TrimRight(strings.TrimPrefix(param, "q="), "0")
+		return q == "" || q == "0." || q == "0"
+	}
+	return false
+}
+
+// variantKey returns the key of the variant of the object with the given primary key, for a request with the given header, for a response varying by the given header names.
+func variantKey(primary string, vary []string, reqHdr http.Header) string {
+	b := strings.Builder{}
+	b.WriteString(primary)
+	b.WriteString(varySep)
+	for i, name := range vary {
+		if i > 0 {
+			b.WriteString("\x00")
+		}
+		b.WriteString(name)
+		b.WriteString(":")
+		b.WriteString(normalizeVaryValue(name, reqHdr.Values(name)))
+	}
+	return b.String()
+}
+
+// primaryKey returns the primary key of the given key, which is the key itself if it isn't a variant key.
+func primaryKey(key string) string {
+	if i := strings.Index(key, varySep); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
+
+// varyMatches returns whether the given object may be served to a request with the given header, according to the object's Vary header.
+func varyMatches(obj *cacheobj.CacheObj, reqHdr http.Header) bool {
+	vary, ok := varyHeaders(obj.RespHeaders)
+	if !ok {
+		return false
+	}
+	if len(vary) == 0 {
+		return true
+	}
+	return variantKey("", vary, obj.ReqHeaders) == variantKey("", vary, reqHdr)
+}
+
+// getVaried gets the object with the given key from the cache. If the object is the primary object of a varied response, the variant for the given request header is returned instead. Returns the key of the returned object, which is the variant key if the response varies, even if the variant isn't in the cache.
+func getVaried(cache icache.Cache, key string, reqHdr http.Header) (*cacheobj.CacheObj, string, bool) {
+	obj, ok := cache.Get(key)
+	if !ok || len(obj.Vary) == 0 {
+		return obj, key, ok
+	}
+	key = variantKey(key, obj.Vary, reqHdr)
+	obj, ok = cache.Get(key)
+	return obj, key, ok
+}
+
+// addVaried adds the given object to the cache. If the object's response doesn't vary, it's added under the primary key of the given key. If it does, it's added under the variant key for its request headers, and the primary key is set to an object listing its variants, removing the oldest beyond maxVariants. Objects which vary by `*` aren't added.
+func addVaried(cache icache.Cache, key string, obj *cacheobj.CacheObj, maxVariants int) {
+	vary, ok := varyHeaders(obj.RespHeaders)
+	if !ok {
+		return
+	}
+	primary := primaryKey(key)
+
+	if len(vary) == 0 {
+		if primary != key {
+			// the object varied when it was last stored, but no longer does
+			variantsM.Lock()
+			defer variantsM.Unlock()
+			if old, ok := cache.Peek(primary); ok {
+				for _, variant := range old.Variants {
+					cache.Remove(variant)
+				}
+			}
+		}
+		cache.Add(primary, obj)
+		return
+	}
+
+	key = variantKey(primary, vary, obj.ReqHeaders)
+
+	variantsM.Lock()
+	defer variantsM.Unlock()
+
+	variants := []string{}
+	if old, ok := cache.Peek(primary); ok && len(old.Vary) > 0 {
+		for _, variant := range old.Variants {
+			if variant == key {
+				continue
+			}
+			if !equalStrs(old.Vary, vary) {
+				cache.Remove(variant) // the response varies by different headers, so old variants will never be requested
+				continue
+			}
+			variants = append(variants, variant)
+		}
+	}
+	variants = append(variants, key)
+	for maxVariants > 0 && len(variants) > maxVariants {
+		cache.Remove(variants[0])
+		variants = variants[1:]
+	}
+
+	cache.Add(key, obj)
+
+	primaryObj := &cacheobj.CacheObj{
+		RespHeaders:  http.Header{"Vary": obj.RespHeaders.Values("Vary")},
+		ReqTime:      obj.ReqTime,
+		ReqRespTime:  obj.ReqRespTime,
+		RespRespTime: obj.RespRespTime,
+		LastModified: obj.LastModified,
+		Vary:         vary,
+		Variants:     variants,
+	}
+	for _, variant := range variants {
+		primaryObj.Size += uint64(len(variant))
+	}
+	cache.Add(primary, primaryObj)
+}
+
+func equalStrs(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// removeVaried removes the object with the given key from the cache, along with all its variants if it's the primary object of a varied response. Returns whether the object was in the cache.
+func removeVaried(cache icache.Cache, key string) bool {
+	key = primaryKey(key)
+	variantsM.Lock()
+	defer variantsM.Unlock()
+	obj, ok := cache.Peek(key)
+	if !ok {
+		return false
+	}
+	for _, variant := range obj.Variants {
+		cache.Remove(variant)
+	}
+	cache.Remove(key)
+	return true
+}
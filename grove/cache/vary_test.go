@@ -0,0 +1,150 @@
+package cache
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/grove/cacheobj"
+	"github.com/apache/trafficcontrol/v8/grove/memcache"
+)
+
+func TestVaryHeaders(t *testing.T) {
+	type testCase struct {
+		vary     []string
+		expected []string
+		ok       bool
+	}
+	testCases := []testCase{
+		{vary: nil, expected: []string{}, ok: true},
+		{vary: []string{"accept-encoding"}, expected: []string{"Accept-Encoding"}, ok: true},
+		{vary: []string{"User-Agent, Accept-Encoding", "accept-encoding"}, expected: []string{"Accept-Encoding", "User-Agent"}, ok: true},
+		{vary: []string{"Accept-Encoding, *"}, expected: nil, ok: false},
+	}
+	for _, tc := range testCases {
+		hdr := http.Header{}
+		for _, v := range tc.vary {
+			hdr.Add("Vary", v)
+		}
+		actual, ok := varyHeaders(hdr)
+		if ok != tc.ok {
+			t.Errorf("varyHeaders(%v) expected ok %v, actual %v", tc.vary, tc.ok, ok)
+		}
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("varyHeaders(%v) expected %v, actual %v", tc.vary, tc.expected, actual)
+		}
+	}
+}
+
+func TestNormalizeVaryValue(t *testing.T) {
+	type testCase struct {
+		name     string
+		vals     []string
+		expected string
+	}
+	testCases := []testCase{
+		{name: "Accept-Encoding", vals: nil, expected: ""},
+		{name: "Accept-Encoding", vals: []string{"gzip, deflate, br"}, expected: "br,deflate,gzip"},
+		{name: "Accept-Encoding", vals: []string{"br", "GZIP;q=1.0 , deflate"}, expected: "br,deflate,gzip"},
+		{name: "Accept-Encoding", vals: []string{"x-gzip, gzip"}, expected: "gzip"},
+		{name: "Accept-Encoding", vals: []string{"gzip;q=0, br;q=0.000, deflate;q=0.5"}, expected: "deflate"},
+		{name: "Accept-Encoding", vals: []string{"gzip; q=0.0"}, expected: ""},
+		{name: "Accept-Language", vals: []string{" en-US , fr", "de"}, expected: "en-US,fr,de"},
+	}
+	for _, tc := range testCases {
+		if actual := normalizeVaryValue(tc.name, tc.vals); actual != tc.expected {
+			t.Errorf("normalizeVaryValue(%v, %v) expected '%v', actual '%v'", tc.name, tc.vals, tc.expected, actual)
+		}
+	}
+}
+
+func varyObj(vary string, reqHdr http.Header, body string) *cacheobj.CacheObj {
+	respHdr := http.Header{}
+	if vary != "" {
+		respHdr.Set("Vary", vary)
+	}
+	return &cacheobj.CacheObj{ReqHeaders: reqHdr, RespHeaders: respHdr, Code: http.StatusOK, Body: []byte(body), Size: uint64(len(body))}
+}
+
+func TestAddGetVaried(t *testing.T) {
+	cache := memcache.New(1024 * 1024)
+	const key = "http://example.net/foo"
+
+	gzipHdr := http.Header{"Accept-Encoding": {"gzip, deflate"}}
+	gzipHdr2 := http.Header{"Accept-Encoding": {"deflate;q=0.8,GZIP"}}
+	identityHdr := http.Header{}
+
+	addVaried(cache, key, varyObj("Accept-Encoding", gzipHdr, "gzipped"), 2)
+	addVaried(cache, key, varyObj("Accept-Encoding", identityHdr, "plain"), 2)
+
+	obj, objKey, ok := getVaried(cache, key, gzipHdr2)
+	if !ok || string(obj.Body) != "gzipped" {
+		t.Errorf("getVaried equivalent Accept-Encoding expected 'gzipped', actual ok %v obj %+v", ok, obj)
+	}
+	if primaryKey(objKey) != key || objKey == key {
+		t.Errorf("getVaried expected variant key of '%v', actual '%v'", key, objKey)
+	}
+	if obj, _, ok := getVaried(cache, key, identityHdr); !ok || string(obj.Body) != "plain" {
+		t.Errorf("getVaried no Accept-Encoding expected 'plain', actual ok %v obj %+v", ok, obj)
+	}
+	if _, _, ok := getVaried(cache, key, http.Header{"Accept-Encoding": {"br"}}); ok {
+		t.Errorf("getVaried unstored variant expected not ok, actual ok")
+	}
+
+	// a third variant beyond max 2 removes the oldest
+	addVaried(cache, key, varyObj("Accept-Encoding", http.Header{"Accept-Encoding": {"br"}}, "brotli"), 2)
+	if _, _, ok := getVaried(cache, key, gzipHdr); ok {
+		t.Errorf("getVaried oldest variant beyond max expected not ok, actual ok")
+	}
+	if obj, _, ok := getVaried(cache, key, identityHdr); !ok || string(obj.Body) != "plain" {
+		t.Errorf("getVaried no Accept-Encoding expected 'plain', actual ok %v obj %+v", ok, obj)
+	}
+	if primary, ok := cache.Peek(key); !ok || len(primary.Variants) != 2 {
+		t.Errorf("primary object expected 2 variants, actual ok %v obj %+v", ok, primary)
+	}
+
+	// a response which no longer varies replaces the variants
+	_, objKey, _ = getVaried(cache, key, identityHdr)
+	addVaried(cache, objKey, varyObj("", identityHdr, "unvaried"), 2)
+	if obj, objKey, ok := getVaried(cache, key, gzipHdr); !ok || objKey != key || string(obj.Body) != "unvaried" {
+		t.Errorf("getVaried after response stopped varying expected 'unvaried', actual ok %v key '%v' obj %+v", ok, objKey, obj)
+	}
+	if _, ok := cache.Peek(objKey); ok {
+		t.Errorf("variant after response stopped varying expected removed, actual exists")
+	}
+
+	addVaried(cache, key, varyObj("*", identityHdr, "star"), 2)
+	if obj, _, _ := getVaried(cache, key, identityHdr); string(obj.Body) != "unvaried" {
+		t.Errorf("addVaried with Vary * expected not stored, actual '%s'", obj.Body)
+	}
+}
+
+func TestVaryMatches(t *testing.T) {
+	obj := varyObj("Accept-Encoding", http.Header{"Accept-Encoding": {"gzip"}}, "")
+	if !varyMatches(obj, http.Header{"Accept-Encoding": {"x-gzip"}}) {
+		t.Errorf("varyMatches equivalent Accept-Encoding expected true, actual false")
+	}
+	if varyMatches(obj, http.Header{}) {
+		t.Errorf("varyMatches different Accept-Encoding expected false, actual true")
+	}
+	if !varyMatches(varyObj("", http.Header{}, ""), http.Header{"Accept-Encoding": {"br"}}) {
+		t.Errorf("varyMatches unvaried expected true, actual false")
+	}
+	if varyMatches(varyObj("*", http.Header{}, ""), http.Header{}) {
+		t.Errorf("varyMatches Vary * expected false, actual true")
+	}
+}
@@ -48,6 +48,8 @@ type ReqData struct {
 	ClientIP string
 	ReqTime  time.Time
 	ToFQDN   string
+	// RemapRule is the name of the remap rule of the request, or empty if no rule matched.
+	RemapRule string
 }
 
 type RespData struct {
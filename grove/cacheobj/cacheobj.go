@@ -16,6 +16,7 @@ package cacheobj
 
 import (
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/apache/trafficcontrol/v8/lib/go-rfc"
@@ -35,6 +36,22 @@ type CacheObj struct {
 	LastModified     time.Time // the origin LastModified if it exists, or Date if it doesn't
 	Size             uint64
 	HitCount         uint64 // the number of times this object was hit
+	// Chunked is whether the body is stored in ChunkCount separate chunks in an icache.ChunkCache, rather than in Body. If so, Size is the size of all chunks.
+	Chunked    bool
+	ChunkCount int
+	// ChunkID identifies the chunks of this object among the chunks stored under its key, so a new object's chunks can be stored while this object's are read. See NewChunkID.
+	ChunkID uint64
+	// Vary is the canonical names of the request headers the response varies by, set only on the primary object of a varied response. Such an object has no response of its own, and its variants are stored under the keys in Variants, oldest first.
+	Vary     []string
+	Variants []string
+}
+
+// lastChunkID is the last ID returned by NewChunkID. It starts from the time Grove started, so IDs aren't reused by the objects of disk caches persisted across restarts.
+var lastChunkID = uint64(time.Now().UnixNano()) // Atomic - DO NOT access or modify without atomic operations
+
+// NewChunkID returns a new unique ID for the chunks of a Chunked object.
+func NewChunkID() uint64 {
+	return atomic.AddUint64(&lastChunkID, 1)
 }
 
 // ComputeSize computes the size of the given CacheObj. This computation is expensive, as the headers must be iterated over. Thus, the size should be computed once and stored, not computed on-the-fly for every new request for the cached object.
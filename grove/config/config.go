@@ -18,6 +18,8 @@ import (
 	"encoding/json"
 	"io/ioutil"
 
+	"github.com/apache/trafficcontrol/v8/grove/health"
+
 	"github.com/apache/trafficcontrol/v8/lib/go-log"
 )
 
@@ -61,6 +63,50 @@ type Config struct {
 	CacheFiles           map[string][]CacheFile `json:"cache_files"`
 	// FileMemBytes is the amount of memory to use as an LRU in front of each name in CacheFiles, that is, each named group of files. E.g. if there are 10 files, the amount of memory used will be 10*FileMemBytes+CacheSizeBytes.
 	FileMemBytes int `json:"file_mem_bytes"`
+	// StreamChunkBytes is the size of the chunks the bodies of objects are stored in, for remap rules with streaming enabled.
+	StreamChunkBytes int `json:"stream_chunk_bytes"`
+	// PurgeRulesFile is the JSON file of regex invalidation rules, typically generated from Traffic Ops invalidation jobs by grovetccfg. It is reloaded with the config. May be empty, for no file rules.
+	PurgeRulesFile string `json:"purge_rules_file"`
+	// PurgeToken is the bearer token required to PURGE objects and to add invalidation rules. If empty, purging is disabled.
+	PurgeToken string `json:"purge_token"`
+	// Peers are the Grove caches of this cache's cachegroup, including this cache. Remap rules with peer_cache request objects from the peer each cache key is consistent-hashed to, before their parents. May be empty, for no peering.
+	Peers []Peer `json:"peers"`
+	// PeerSelf is the Host of this cache in Peers. It must be set if Peers is.
+	PeerSelf string `json:"peer_self"`
+	// PeerHealthCheck is the health tracking of Peers. Peers marked down are skipped, and their objects requested from the parent. If nil, peers are tracked by failed requests with the health.Check defaults.
+	PeerHealthCheck *health.Check `json:"peer_health_check"`
+	// AccessLogs are the access logs to write each client request to. Remap rules may select which logs their requests are written to. May be empty, for no access logs besides the ats_log plugin.
+	AccessLogs []AccessLog `json:"access_logs"`
+}
+
+type AccessLog struct {
+	// Name is the unique name of the log, used by remap rules to select it.
+	Name string `json:"name"`
+	// Location is the file to write to, or stdout or stderr.
+	Location string `json:"location"`
+	// Format is either text or json. If empty, text is used.
+	Format string `json:"format"`
+	// Text is the line of text formats, with %<field> fields. If empty, the ATS squid-style line of the ats_log plugin is used.
+	Text string `json:"text"`
+	// Fields are the fields of json formats.
+	Fields []string `json:"fields"`
+	// SampleRate is the fraction of requests to log, from 0 to 1. If nil, all requests are logged. Remap rules may override it.
+	SampleRate *float64 `json:"sample_rate"`
+	// BufferBytes is the size of the write buffer. If 0, a default is used.
+	BufferBytes int `json:"buffer_bytes"`
+	// FlushMS is the interval in milliseconds to flush the write buffer. If 0, a default is used.
+	FlushMS int `json:"flush_ms"`
+	// RotateBytes is the size at which the file is rotated. If 0, the file isn't rotated.
+	RotateBytes int64 `json:"rotate_bytes"`
+	// RotateKeep is the number of rotated files to keep. If 0, a default is used.
+	RotateKeep int `json:"rotate_keep"`
+}
+
+type Peer struct {
+	// Host is the host and port of the peer's HTTP server, e.g. grove1.example.net:80.
+	Host string `json:"host"`
+	// Weight is the weight of the peer in consistent hashing. If 0, the weight is 1.
+	Weight float64 `json:"weight"`
 }
 
 type CacheFile struct {
@@ -109,6 +155,7 @@ var DefaultConfig = Config{
 	ServerWriteTimeoutMS:   3 * MSPerSec,
 	ServerReadTimeoutMS:    3 * MSPerSec,
 	FileMemBytes:           bytesPerMebibyte * 100,
+	StreamChunkBytes:       bytesPerMebibyte,
 }
 
 // LoadConfig loads the given config file. If an empty string is passed, the default config is returned.
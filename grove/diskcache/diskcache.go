@@ -18,6 +18,9 @@ import (
 	"bytes"
 	"encoding/gob"
 	"errors"
+	"fmt"
+	"strconv"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -34,11 +37,43 @@ type DiskCache struct {
 	sizeBytes    uint64
 	maxSizeBytes uint64
 	lru          *lru.LRU
+
+	// accessed is the last access time of keys gotten since the index was last flushed.
+	accessed  map[string]time.Time
+	accessedM sync.Mutex
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
 const BucketName = "b"
 
+// IndexBucketName is the bucket of the persisted LRU index, of the size and last access time of each object. See indexEntry.
+const IndexBucketName = "i"
+
+// IndexFlushInterval is how often the last access times of gotten objects are written to the persisted index. Sizes are written with their objects, so only the LRU order since the last flush is lost if the service is killed.
+const IndexFlushInterval = time.Minute
+
+// New opens the disk cache at the given path, creating it if it doesn't exist, and loads its LRU from the persisted index, so the cache is warm with the same eviction order as when it was closed. If the file has no index, it's rebuilt from the stored objects, in an arbitrary order.
 func New(path string, cacheSizeBytes uint64) (*DiskCache, error) {
+	db, err := openDB(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &DiskCache{db: db, maxSizeBytes: cacheSizeBytes, lru: lru.NewLRU(), sizeBytes: 0, accessed: map[string]time.Time{}, done: make(chan struct{})}
+	if err := c.loadIndex(); err != nil {
+		db.Close()
+		return nil, errors.New("loading index for database '" + path + "': " + err.Error())
+	}
+	if size := c.Size(); size > c.maxSizeBytes {
+		go c.gc(size)
+	}
+	go c.flushIndexPeriodically()
+	return c, nil
+}
+
+// openDB opens the bolt database at the given path, and creates the object bucket if it doesn't exist. The index bucket is created when the index is loaded.
+func openDB(path string) (*bolt.DB, error) {
 	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
 	if err != nil {
 		return nil, errors.New("opening database '" + path + "': " + err.Error())
@@ -51,31 +86,10 @@ func New(path string, cacheSizeBytes uint64) (*DiskCache, error) {
 		return nil
 	})
 	if err != nil {
+		db.Close()
 		return nil, errors.New("creating bucket for database '" + path + "': " + err.Error())
 	}
-
-	return &DiskCache{db: db, maxSizeBytes: cacheSizeBytes, lru: lru.NewLRU(), sizeBytes: 0}, nil
-}
-
-// ResetAfterRestart rebuilds the LRU with an arbirtrary order and sets sizeBytes. This seems crazy, but it is better than doing nothing, sice gc is based on the LRU and sizeBytes. In the future, we may want to periodically sync the LRU to disk, but we'll still need to iterate over all keys in the disk DB to avoid orphaning objects.
-// Note: this assumes the LRU is empty. Don't run twice
-func (c *DiskCache) ResetAfterRestart() {
-	go c.db.View(func(tx *bolt.Tx) error {
-		log.Infof("Starting cache recovery from disk for: %s... ", c.db.Path())
-		size := 0
-		b := tx.Bucket([]byte(BucketName))
-
-		cursor := b.Cursor()
-
-		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
-			c.lru.Add(string(k), uint64(len(v)))
-			size += len(v)
-		}
-
-		atomic.AddUint64(&c.sizeBytes, uint64(size))
-		log.Infof("Cache recovery from disk for %s done (%d bytes). ", c.db.Path(), c.sizeBytes)
-		return nil
-	})
+	return db, nil
 }
 
 // Add takes a key and value to add. Returns whether an eviction occurred
@@ -94,21 +108,39 @@ func (c *DiskCache) Add(key string, val *cacheobj.CacheObj) bool {
 	}
 	valBytes := buf.Bytes()
 
+	size := uint64(len(valBytes))
+	if val.Chunked {
+		size += val.Size
+	}
+
 	err := c.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(BucketName))
 		if b == nil {
 			return errors.New("bucket does not exist")
 		}
-		return b.Put([]byte(key), valBytes)
+		// the chunks of other objects under the key are removed, and their sizes replaced in the index and LRU below
+		if !val.Chunked || val.ChunkCount == 0 {
+			if err := deleteChunks(b, key); err != nil {
+				return errors.New("deleting old chunks: " + err.Error())
+			}
+		} else if chunkCount := countChunks(b, key, val.ChunkID); chunkCount != val.ChunkCount {
+			return fmt.Errorf("object has %v chunks, but %v were stored", val.ChunkCount, chunkCount)
+		} else if err := deleteOtherChunks(b, key, val.ChunkID); err != nil {
+			return errors.New("deleting old chunks: " + err.Error())
+		}
+		if err := b.Put([]byte(key), valBytes); err != nil {
+			return err
+		}
+		return putIndexEntry(tx, key, indexEntry{Size: size, LastAccess: time.Now()})
 	})
 	if err != nil {
 		log.Errorln("DiskCache.Add inserting '" + key + "' in database: " + err.Error())
 		return eviction
 	}
 
-	c.lru.Add(key, uint64(len(valBytes)))
+	oldSize := c.lru.Add(key, size)
 
-	newSizeBytes := atomic.AddUint64(&c.sizeBytes, uint64(len(valBytes)))
+	newSizeBytes := atomic.AddUint64(&c.sizeBytes, size-oldSize)
 	if newSizeBytes > c.maxSizeBytes {
 		go c.gc(newSizeBytes)
 	}
@@ -117,6 +149,159 @@ func (c *DiskCache) Add(key string, val *cacheobj.CacheObj) bool {
 	return eviction
 }
 
+// AddChunk adds the chunk of the body of the given key. See icache.ChunkCache.
+func (c *DiskCache) AddChunk(key string, id uint64, idx int, chunk []byte) bool {
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(BucketName))
+		if b == nil {
+			return errors.New("bucket does not exist")
+		}
+		if idx == 0 && b.Get(chunkKey(key, id, 0)) != nil {
+			return errors.New("chunk ID already stored")
+		} else if idx > 0 && b.Get(chunkKey(key, id, idx-1)) == nil {
+			return errors.New("previous chunk was removed or evicted")
+		}
+		entry := indexEntry{Size: uint64(len(chunk)), LastAccess: time.Now()}
+		if oldEntry, ok := getIndexEntry(tx, key); ok {
+			entry.Size += oldEntry.Size // the size of the key includes the object and the chunks of every ID
+		}
+		if err := b.Put(chunkKey(key, id, idx), chunk); err != nil {
+			return err
+		}
+		return putIndexEntry(tx, key, entry)
+	})
+	if err != nil {
+		log.Errorf("DiskCache.AddChunk inserting '%v' chunk %v in database: %v\n", key, idx, err)
+		return false
+	}
+
+	c.lru.Grow(key, uint64(len(chunk)))
+	if newSizeBytes := atomic.AddUint64(&c.sizeBytes, uint64(len(chunk))); newSizeBytes > c.maxSizeBytes {
+		go c.gc(newSizeBytes)
+	}
+	return true
+}
+
+// GetChunk returns the chunk of the body of the given key. See icache.ChunkCache.
+func (c *DiskCache) GetChunk(key string, id uint64, idx int) ([]byte, bool) {
+	chunk := []byte(nil)
+	err := c.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(BucketName))
+		if b == nil {
+			return errors.New("bucket does not exist")
+		}
+		if val := b.Get(chunkKey(key, id, idx)); val != nil {
+			chunk = make([]byte, len(val)) // bolt values are only valid inside the transaction
+			copy(chunk, val)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorf("DiskCache.GetChunk getting '%v' chunk %v from cache: %v\n", key, idx, err)
+		return nil, false
+	}
+	return chunk, chunk != nil
+}
+
+// Remove removes the object with the given key, and any chunks of its body.
+func (c *DiskCache) Remove(key string) {
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		return deleteObj(tx, key)
+	})
+	if err != nil {
+		log.Errorln("DiskCache.Remove removing '" + key + "' from cache: " + err.Error())
+	}
+	if sizeBytes, ok := c.lru.Remove(key); ok {
+		atomic.AddUint64(&c.sizeBytes, ^uint64(sizeBytes-1)) // subtract sizeBytes
+	}
+}
+
+// chunkSep separates the key of an object from the chunk ID and index of its chunks, in the keys chunks are stored under. Cache keys are URLs, which can't contain it.
+const chunkSep = "\x00chunk\x00"
+
+// chunkIDSep separates the chunk ID from the chunk index, in the keys chunks are stored under.
+const chunkIDSep = "."
+
+func chunkKey(key string, id uint64, idx int) []byte {
+	return []byte(chunkIDPrefix(key, id) + strconv.Itoa(idx))
+}
+
+// chunkIDPrefix returns the prefix of the keys the chunks of the given key and chunk ID are stored under.
+func chunkIDPrefix(key string, id uint64) string {
+	return key + chunkSep + strconv.FormatUint(id, 10) + chunkIDSep
+}
+
+// chunkID returns the chunk ID of the given stored chunk key, and false if it isn't a valid chunk key.
+func chunkID(storedKey []byte) (uint64, bool) {
+	i := bytes.Index(storedKey, []byte(chunkSep))
+	if i < 0 {
+		return 0, false
+	}
+	idAndIdx := storedKey[i+len(chunkSep):]
+	j := bytes.Index(idAndIdx, []byte(chunkIDSep))
+	if j < 0 {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(string(idAndIdx[:j]), 10, 64)
+	return id, err == nil
+}
+
+// deleteObj deletes the object with the given key, all its chunks, and its index entry.
+func deleteObj(tx *bolt.Tx, key string) error {
+	b := tx.Bucket([]byte(BucketName))
+	if b == nil {
+		return errors.New("bucket does not exist")
+	}
+	if err := b.Delete([]byte(key)); err != nil {
+		return err
+	}
+	if err := deleteChunks(b, key); err != nil {
+		return err
+	}
+	return deleteIndexEntry(tx, key)
+}
+
+// deleteChunks deletes all the chunks of the given key, of every chunk ID.
+func deleteChunks(b *bolt.Bucket, key string) error {
+	prefix := []byte(key + chunkSep)
+	cursor := b.Cursor()
+	for k, _ := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cursor.Seek(prefix) {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteOtherChunks deletes the chunks of the given key whose chunk ID isn't the given ID.
+func deleteOtherChunks(b *bolt.Bucket, key string, keepID uint64) error {
+	prefix := []byte(key + chunkSep)
+	others := [][]byte{}
+	cursor := b.Cursor()
+	for k, _ := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cursor.Next() {
+		if id, ok := chunkID(k); !ok || id != keepID {
+			others = append(others, append([]byte(nil), k...)) // bolt keys are only valid inside the transaction, and deleting while iterating skips keys
+		}
+	}
+	for _, k := range others {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countChunks returns the number of chunks stored for the given key and chunk ID.
+func countChunks(b *bolt.Bucket, key string, id uint64) int {
+	prefix := []byte(chunkIDPrefix(key, id))
+	count := 0
+	cursor := b.Cursor()
+	for k, _ := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cursor.Next() {
+		count++
+	}
+	return count
+}
+
 // gc does garbage collection, deleting stored entries until the DiskCache's size is less than maxSizeBytes. This is threadsafe, and should be called in a goroutine to avoid blocking the caller.
 // The given cacheSizeBytes must be `c.Size()`; it's passed here, because gc should be called immediately after an insert updates the size, so it saves an atomic instruction to pass rather than calling Size() again.
 func (c *DiskCache) gc(cacheSizeBytes uint64) {
@@ -132,13 +317,7 @@ func (c *DiskCache) gc(cacheSizeBytes uint64) {
 
 		log.Debugf("DiskCache.gc deleting key '" + key + "'")
 		err := c.db.Update(func(tx *bolt.Tx) error {
-			b := tx.Bucket([]byte(BucketName))
-			if b == nil {
-				return errors.New("bucket does not exist")
-			}
-			b.Delete([]byte(key))
-
-			return b.Delete([]byte(key))
+			return deleteObj(tx, key)
 		})
 		if err != nil {
 			log.Errorln("removing '" + key + "' from cache: " + err.Error())
@@ -152,7 +331,10 @@ func (c *DiskCache) gc(cacheSizeBytes uint64) {
 func (c *DiskCache) Get(key string) (*cacheobj.CacheObj, bool) {
 	val, found := c.Peek(key)
 	if found {
-		c.lru.Add(key, val.Size) // TODO directly call c.ll.MoveToFront
+		c.lru.Touch(key)
+		c.accessedM.Lock()
+		c.accessed[key] = time.Now()
+		c.accessedM.Unlock()
 		log.Debugln("DiskCache.Get getting '" + key + "' from cache and updating LRU")
 		atomic.AddUint64(&val.HitCount, 1)
 		return val, true
@@ -199,8 +381,15 @@ func (c *DiskCache) Size() uint64 {
 	return atomic.LoadUint64(&c.sizeBytes)
 }
 
+// Close writes the last access times of gotten objects to the persisted index, and closes the database. It is safe to call multiple times.
 func (c *DiskCache) Close() {
-	c.db.Close()
+	c.closeOnce.Do(func() {
+		close(c.done)
+		if err := c.flushIndex(); err != nil {
+			log.Errorln("DiskCache.Close flushing index for '" + c.db.Path() + "': " + err.Error())
+		}
+		c.db.Close()
+	})
 }
 
 func (c *DiskCache) Keys() []string {
@@ -0,0 +1,323 @@
+package diskcache
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bytes"
+	"encoding/gob"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/grove/cacheobj"
+	"github.com/apache/trafficcontrol/v8/grove/config"
+
+	"github.com/dchest/siphash"
+	bolt "go.etcd.io/bbolt"
+)
+
+func testObj(body string) *cacheobj.CacheObj {
+	now := time.Now()
+	return cacheobj.New(http.Header{}, []byte(body), http.StatusOK, http.StatusOK, "", http.Header{}, now, now, now, now)
+}
+
+func TestIndexPersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	c, err := New(path, 1024*1024)
+	if err != nil {
+		t.Fatalf("creating cache: %v", err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		c.Add(key, testObj("body of "+key))
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Fatalf("expected a in cache")
+	}
+	expectedKeys := c.Keys()
+	expectedSize := c.Size()
+	c.Close()
+
+	c, err = New(path, 1024*1024)
+	if err != nil {
+		t.Fatalf("reopening cache: %v", err)
+	}
+	defer c.Close()
+	if keys := c.Keys(); !reflect.DeepEqual(keys, expectedKeys) {
+		t.Errorf("expected reopened LRU keys %v, actual %v", expectedKeys, keys)
+	}
+	if !reflect.DeepEqual(expectedKeys, []string{"b", "c", "a"}) {
+		t.Errorf("expected LRU keys oldest first [b c a], actual %v", expectedKeys)
+	}
+	if size := c.Size(); size != expectedSize {
+		t.Errorf("expected reopened size %v, actual %v", expectedSize, size)
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Errorf("expected b in reopened cache")
+	}
+}
+
+func TestIndexRemoveAndChunks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	c, err := New(path, 1024*1024)
+	if err != nil {
+		t.Fatalf("creating cache: %v", err)
+	}
+	obj := testObj("")
+	obj.Chunked = true
+	obj.ChunkID = cacheobj.NewChunkID()
+	for i, chunk := range []string{"chunk0", "chunk1"} {
+		if !c.AddChunk("chunked", obj.ChunkID, i, []byte(chunk)) {
+			t.Fatalf("expected adding chunk %v to succeed", i)
+		}
+		obj.Size += uint64(len(chunk))
+		obj.ChunkCount++
+	}
+	c.Add("chunked", obj)
+	c.Add("removed", testObj("removed"))
+	c.Remove("removed")
+	expectedSize := c.Size()
+	c.Close()
+
+	c, err = New(path, 1024*1024)
+	if err != nil {
+		t.Fatalf("reopening cache: %v", err)
+	}
+	defer c.Close()
+	if keys := c.Keys(); !reflect.DeepEqual(keys, []string{"chunked"}) {
+		t.Errorf("expected reopened LRU keys [chunked], actual %v", keys)
+	}
+	if size := c.Size(); size != expectedSize {
+		t.Errorf("expected reopened size %v, actual %v", expectedSize, size)
+	}
+}
+
+func TestChunkIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	c, err := New(path, 1024*1024)
+	if err != nil {
+		t.Fatalf("creating cache: %v", err)
+	}
+	old := testObj("")
+	old.Chunked = true
+	old.ChunkID = cacheobj.NewChunkID()
+	for i, chunk := range []string{"old0", "old1"} {
+		c.AddChunk("chunked", old.ChunkID, i, []byte(chunk))
+		old.Size += uint64(len(chunk))
+		old.ChunkCount++
+	}
+	c.Add("chunked", old)
+
+	obj := *old
+	obj.ChunkID = cacheobj.NewChunkID()
+	if !c.AddChunk("chunked", obj.ChunkID, 0, []byte("new0")) {
+		t.Fatalf("expected adding chunk of new object to succeed")
+	}
+	if chunk, ok := c.GetChunk("chunked", old.ChunkID, 1); !ok || string(chunk) != "old1" {
+		t.Errorf("expected stored object's chunk 'old1' while new object's chunks are added, actual '%s' %v", chunk, ok)
+	}
+	if c.AddChunk("chunked", obj.ChunkID, 2, []byte("new2")) {
+		t.Errorf("expected adding chunk after a missing chunk to fail")
+	}
+	c.AddChunk("chunked", obj.ChunkID, 1, []byte("new1"))
+	c.AddChunk("chunked", cacheobj.NewChunkID(), 0, []byte("unfinished")) // a stream which never finished
+	c.Add("chunked", &obj)
+	if _, ok := c.GetChunk("chunked", old.ChunkID, 0); ok {
+		t.Errorf("expected replaced object's chunks to be removed")
+	}
+	if chunk, ok := c.GetChunk("chunked", obj.ChunkID, 1); !ok || string(chunk) != "new1" {
+		t.Errorf("expected new object's chunk 'new1', actual '%s' %v", chunk, ok)
+	}
+	expectedSize := c.Size()
+
+	c.AddChunk("chunked", cacheobj.NewChunkID(), 0, []byte("unfinished")) // a stream which never finished
+	c.Close()
+
+	stats, err := Inspect([]config.CacheFile{{Path: path, Bytes: 1024 * 1024}})
+	if err != nil {
+		t.Fatalf("inspecting: %v", err)
+	}
+	if s := stats[0]; s.Objects != 1 || s.Chunks != 3 || s.OrphanChunks != 1 || s.BadObjects != 0 || s.Unindexed != 1 {
+		t.Errorf("expected 1 object with 2 chunks and 1 unfinished chunk, which is orphaned and indexed with the object, actual %+v", s)
+	}
+	if err := Compact([]config.CacheFile{{Path: path, Bytes: 1024 * 1024}}); err != nil {
+		t.Fatalf("compacting: %v", err)
+	}
+
+	c, err = New(path, 1024*1024)
+	if err != nil {
+		t.Fatalf("reopening cache: %v", err)
+	}
+	defer c.Close()
+	if size := c.Size(); size != expectedSize {
+		t.Errorf("expected compacted size %v without the orphan chunk, actual %v", expectedSize, size)
+	}
+	if chunk, ok := c.GetChunk("chunked", obj.ChunkID, 0); !ok || string(chunk) != "new0" {
+		t.Errorf("expected compacted object's chunk 'new0', actual '%s' %v", chunk, ok)
+	}
+}
+
+func TestPlacementStable(t *testing.T) {
+	files := []config.CacheFile{{Path: "/a", Bytes: 1000}, {Path: "/b", Bytes: 1000}, {Path: "/c", Bytes: 1000}}
+	oldHash := placementHash(files)
+	newHash := placementHash(append(files, config.CacheFile{Path: "/d", Bytes: 1000}))
+
+	const numKeys = 10000
+	moved := 0
+	for i := 0; i < numKeys; i++ {
+		key := "GET:http://example.net/" + strconv.Itoa(i)
+		oldIdx, newIdx := fileIdx(oldHash, key), fileIdx(newHash, key)
+		if oldIdx == newIdx {
+			continue
+		}
+		if newIdx != 3 {
+			t.Fatalf("expected key '%v' to only move to the added file, actual moved from %v to %v", key, oldIdx, newIdx)
+		}
+		moved++
+	}
+	// the added file should get about a quarter of the keys
+	if moved < numKeys/8 || moved > numKeys/2 {
+		t.Errorf("expected about %v keys to move to the added file, actual %v", numKeys/4, moved)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+	files := []config.CacheFile{{Path: filepath.Join(dir, "a.db"), Bytes: 1024 * 1024}, {Path: filepath.Join(dir, "b.db"), Bytes: 1024 * 1024}}
+	c, err := NewMulti(files)
+	if err != nil {
+		t.Fatalf("creating cache: %v", err)
+	}
+	const numKeys = 100
+	for i := 0; i < numKeys; i++ {
+		c.Add("GET:http://example.net/"+strconv.Itoa(i), testObj(strconv.Itoa(i)))
+	}
+	c.AddChunk("GET:http://example.net/incomplete", cacheobj.NewChunkID(), 0, []byte("chunk0")) // a stream which never finished
+	c.Close()
+
+	files = append(files, config.CacheFile{Path: filepath.Join(dir, "c.db"), Bytes: 1024 * 1024})
+	c, err = NewMulti(files)
+	if err != nil {
+		t.Fatalf("creating cache with added file: %v", err)
+	}
+	c.Close()
+
+	stats, err := Inspect(files)
+	if err != nil {
+		t.Fatalf("inspecting: %v", err)
+	}
+	misplaced, orphans, stale := 0, 0, 0
+	for _, s := range stats {
+		misplaced += s.Misplaced
+		orphans += s.OrphanChunks
+		stale += s.StaleIndex
+	}
+	if misplaced == 0 {
+		t.Errorf("expected objects misplaced by the added file, actual none")
+	}
+	if orphans != 1 || stale != 1 {
+		t.Errorf("expected 1 orphan chunk and 1 stale index entry, actual %v and %v", orphans, stale)
+	}
+
+	if err := Compact(files); err != nil {
+		t.Fatalf("compacting: %v", err)
+	}
+	stats, err = Inspect(files)
+	if err != nil {
+		t.Fatalf("inspecting compacted: %v", err)
+	}
+	objects := 0
+	for _, s := range stats {
+		objects += s.Objects
+		if s.Misplaced != 0 || s.OrphanChunks != 0 || s.StaleIndex != 0 || s.Unindexed != 0 || s.BadObjects != 0 {
+			t.Errorf("expected compacted file to have no problems, actual %+v", s)
+		}
+		if s.IndexEntries != s.Objects {
+			t.Errorf("expected compacted file to index all %v objects, actual %v", s.Objects, s.IndexEntries)
+		}
+	}
+	if objects != numKeys {
+		t.Errorf("expected compacted files to have %v objects, actual %v", numKeys, objects)
+	}
+
+	c, err = NewMulti(files)
+	if err != nil {
+		t.Fatalf("opening compacted cache: %v", err)
+	}
+	defer c.Close()
+	for i := 0; i < numKeys; i++ {
+		if obj, ok := c.Get("GET:http://example.net/" + strconv.Itoa(i)); !ok || string(obj.Body) != strconv.Itoa(i) {
+			t.Errorf("expected compacted cache to have object %v", i)
+		}
+	}
+}
+
+func TestMigrateLegacyPlacement(t *testing.T) {
+	dir := t.TempDir()
+	files := []config.CacheFile{{Path: filepath.Join(dir, "a.db"), Bytes: 1024 * 1024}, {Path: filepath.Join(dir, "b.db"), Bytes: 1024 * 1024}, {Path: filepath.Join(dir, "c.db"), Bytes: 1024 * 1024}}
+
+	// write files as older versions did, with no index, and keys placed by siphash modulo the number of files
+	dbs := []*bolt.DB{}
+	for _, file := range files {
+		db, err := openDB(file.Path)
+		if err != nil {
+			t.Fatalf("creating legacy file: %v", err)
+		}
+		dbs = append(dbs, db)
+	}
+	const numKeys = 100
+	for i := 0; i < numKeys; i++ {
+		key := "GET:http://example.net/" + strconv.Itoa(i)
+		buf := bytes.Buffer{}
+		if err := gob.NewEncoder(&buf).Encode(testObj(strconv.Itoa(i))); err != nil {
+			t.Fatalf("encoding object: %v", err)
+		}
+		db := dbs[siphash.Hash(0, 0, []byte(key))%uint64(len(dbs))]
+		if err := db.Update(func(tx *bolt.Tx) error { return tx.Bucket([]byte(BucketName)).Put([]byte(key), buf.Bytes()) }); err != nil {
+			t.Fatalf("writing legacy object: %v", err)
+		}
+	}
+	for _, db := range dbs {
+		db.Close()
+	}
+
+	c, err := NewMulti(files)
+	if err != nil {
+		t.Fatalf("opening legacy files: %v", err)
+	}
+	for i := 0; i < numKeys; i++ {
+		if obj, ok := c.Get("GET:http://example.net/" + strconv.Itoa(i)); !ok || string(obj.Body) != strconv.Itoa(i) {
+			t.Errorf("expected legacy object %v to be found after migration", i)
+		}
+	}
+	c.Close()
+
+	stats, err := Inspect(files)
+	if err != nil {
+		t.Fatalf("inspecting: %v", err)
+	}
+	objects := 0
+	for _, s := range stats {
+		objects += s.Objects
+		if s.Misplaced != 0 || s.Unindexed != 0 || s.StaleIndex != 0 {
+			t.Errorf("expected migrated file to have no misplaced or unindexed objects, actual %+v", s)
+		}
+	}
+	if objects != numKeys {
+		t.Errorf("expected migrated files to have %v objects, actual %v", numKeys, objects)
+	}
+}
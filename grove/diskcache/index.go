@@ -0,0 +1,218 @@
+package diskcache
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// indexEntry is the persisted LRU index entry of an object, stored in the IndexBucketName bucket under the object's key.
+type indexEntry struct {
+	// Size is the size of the object in the LRU, that is, the stored object and all its chunks.
+	Size uint64
+	// LastAccess is the time the object was last added or gotten. The LRU is loaded in order of LastAccess.
+	LastAccess time.Time
+}
+
+// indexEntryLen is the length of an encoded indexEntry: the size, and the last access in Unix nanoseconds.
+const indexEntryLen = 16
+
+func (e indexEntry) bytes() []byte {
+	b := make([]byte, indexEntryLen)
+	binary.BigEndian.PutUint64(b[:8], e.Size)
+	binary.BigEndian.PutUint64(b[8:], uint64(e.LastAccess.UnixNano()))
+	return b
+}
+
+func parseIndexEntry(b []byte) (indexEntry, error) {
+	if len(b) != indexEntryLen {
+		return indexEntry{}, errors.New("malformed index entry")
+	}
+	return indexEntry{
+		Size:       binary.BigEndian.Uint64(b[:8]),
+		LastAccess: time.Unix(0, int64(binary.BigEndian.Uint64(b[8:]))),
+	}, nil
+}
+
+func getIndexEntry(tx *bolt.Tx, key string) (indexEntry, bool) {
+	ib := tx.Bucket([]byte(IndexBucketName))
+	if ib == nil {
+		return indexEntry{}, false
+	}
+	entry, err := parseIndexEntry(ib.Get([]byte(key)))
+	return entry, err == nil
+}
+
+func putIndexEntry(tx *bolt.Tx, key string, entry indexEntry) error {
+	ib := tx.Bucket([]byte(IndexBucketName))
+	if ib == nil {
+		return errors.New("index bucket does not exist")
+	}
+	return ib.Put([]byte(key), entry.bytes())
+}
+
+func deleteIndexEntry(tx *bolt.Tx, key string) error {
+	ib := tx.Bucket([]byte(IndexBucketName))
+	if ib == nil {
+		return errors.New("index bucket does not exist")
+	}
+	return ib.Delete([]byte(key))
+}
+
+// objKey returns the key of the object the given stored key belongs to, and whether the stored key is a chunk of the object's body.
+func objKey(storedKey []byte) (string, bool) {
+	if i := bytes.Index(storedKey, []byte(chunkSep)); i >= 0 {
+		return string(storedKey[:i]), true
+	}
+	return string(storedKey), false
+}
+
+// objSizes returns the size of each object in the object bucket, including its chunks.
+func objSizes(b *bolt.Bucket) map[string]uint64 {
+	sizes := map[string]uint64{}
+	cursor := b.Cursor()
+	for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+		key, _ := objKey(k)
+		sizes[key] += uint64(len(v))
+	}
+	return sizes
+}
+
+// createIndex creates the index bucket from the stored objects, if it doesn't exist, as in files from older versions. Every object is given the current time as its last access.
+func createIndex(tx *bolt.Tx) error {
+	if tx.Bucket([]byte(IndexBucketName)) != nil {
+		return nil
+	}
+	ib, err := tx.CreateBucket([]byte(IndexBucketName))
+	if err != nil {
+		return errors.New("creating index bucket: " + err.Error())
+	}
+	sizes := objSizes(tx.Bucket([]byte(BucketName)))
+	if len(sizes) > 0 {
+		log.Infoln("DiskCache '" + tx.DB().Path() + "' has no index, rebuilding from stored objects")
+	}
+	now := time.Now()
+	for key, size := range sizes {
+		if err := ib.Put([]byte(key), indexEntry{Size: size, LastAccess: now}.bytes()); err != nil {
+			return errors.New("adding index entry: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// hasLegacyIndex returns whether the file has objects but no index, which means it was written by an older version.
+func hasLegacyIndex(tx *bolt.Tx) bool {
+	if tx.Bucket([]byte(IndexBucketName)) != nil {
+		return false
+	}
+	b := tx.Bucket([]byte(BucketName))
+	if b == nil {
+		return false
+	}
+	k, _ := b.Cursor().First()
+	return k != nil
+}
+
+// loadIndex loads the LRU and size from the persisted index. If the index doesn't exist, as in files from older versions, it's created from the stored objects, which requires reading every object.
+//
+// This assumes the LRU is empty, and must only be called once, before the cache is used.
+func (c *DiskCache) loadIndex() error {
+	if err := c.db.Update(createIndex); err != nil {
+		return err
+	}
+
+	type keyEntry struct {
+		key   string
+		entry indexEntry
+	}
+	entries := []keyEntry{}
+	err := c.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(IndexBucketName)).ForEach(func(k, v []byte) error {
+			entry, err := parseIndexEntry(v)
+			if err != nil {
+				log.Errorln("DiskCache '" + c.db.Path() + "' index entry '" + string(k) + "': " + err.Error() + ", skipping")
+				return nil
+			}
+			entries = append(entries, keyEntry{key: string(k), entry: entry})
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	// add the least recently used first, so the most recently used are at the front
+	sort.Slice(entries, func(i, j int) bool { return entries[i].entry.LastAccess.Before(entries[j].entry.LastAccess) })
+	size := uint64(0)
+	for _, e := range entries {
+		c.lru.Add(e.key, e.entry.Size)
+		size += e.entry.Size
+	}
+	atomic.StoreUint64(&c.sizeBytes, size)
+	log.Infof("DiskCache '%v' loaded index of %v objects (%v bytes)\n", c.db.Path(), len(entries), size)
+	return nil
+}
+
+// flushIndex writes the last access times of objects gotten since the last flush to the persisted index.
+func (c *DiskCache) flushIndex() error {
+	c.accessedM.Lock()
+	accessed := c.accessed
+	c.accessed = map[string]time.Time{}
+	c.accessedM.Unlock()
+	if len(accessed) == 0 {
+		return nil
+	}
+	return c.db.Update(func(tx *bolt.Tx) error {
+		for key, lastAccess := range accessed {
+			entry, ok := getIndexEntry(tx, key)
+			if !ok {
+				continue // removed since it was gotten
+			}
+			if entry.LastAccess.After(lastAccess) {
+				continue // added since it was gotten
+			}
+			entry.LastAccess = lastAccess
+			if err := putIndexEntry(tx, key, entry); err != nil {
+				return errors.New("updating index entry '" + key + "': " + err.Error())
+			}
+		}
+		return nil
+	})
+}
+
+// flushIndexPeriodically flushes the index every IndexFlushInterval, until the cache is closed.
+func (c *DiskCache) flushIndexPeriodically() {
+	ticker := time.NewTicker(IndexFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.flushIndex(); err != nil {
+				log.Errorln("DiskCache flushing index for '" + c.db.Path() + "': " + err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package diskcache
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/grove/cacheobj"
+	"github.com/apache/trafficcontrol/v8/grove/chash"
+	"github.com/apache/trafficcontrol/v8/grove/config"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// FileStats are the statistics of a disk cache file, from Inspect.
+type FileStats struct {
+	Path string
+	// FileBytes is the size of the file on disk, including free pages which may be reclaimed by Compact.
+	FileBytes int64
+	Objects   int
+	Chunks    int
+	// StoredBytes is the size of all objects and chunks.
+	StoredBytes uint64
+	// IndexEntries is the number of entries in the persisted LRU index.
+	IndexEntries int
+	// Unindexed is the number of objects with no index entry, or an entry with the wrong size.
+	Unindexed int
+	// StaleIndex is the number of index entries with no object.
+	StaleIndex int
+	// BadObjects is the number of objects which can't be decoded, or whose chunks weren't all stored.
+	BadObjects int
+	// OrphanChunks is the number of chunks with no object, or of another object than the one stored under their key.
+	OrphanChunks int
+	// Misplaced is the number of objects in a different file than their key is placed in, typically because files were added or removed. Misplaced objects are never served.
+	Misplaced int
+}
+
+// compactTxMaxBytes is the maximum size of each transaction when copying a file to compact it.
+const compactTxMaxBytes = 64 * 1024 * 1024
+
+// unknownLastAccess is the last access time of objects with no index entry, so they're the first evicted.
+var unknownLastAccess = time.Unix(0, 0)
+
+// fileScan is the result of scanning a disk cache file, with the stats and the changes needed to repair it.
+type fileScan struct {
+	stats        FileStats
+	sizes        map[string]uint64 // the size of each good object, including its chunks
+	badObjects   []string
+	orphanChunks [][]byte
+	staleIndex   []string
+	unindexed    []string
+	misplaced    []string
+}
+
+// scanFile scans the objects and index of the disk cache file with the given index in the given placement hash.
+func scanFile(tx *bolt.Tx, h chash.ATSConsistentHash, idx int) fileScan {
+	s := fileScan{sizes: map[string]uint64{}}
+	b := tx.Bucket([]byte(BucketName))
+	if b == nil {
+		return s
+	}
+
+	chunkKeys := map[string][][]byte{}
+	objChunkCounts := map[string]int{} // the number of chunks each object should have
+	objChunkIDs := map[string]uint64{}
+	b.ForEach(func(k, v []byte) error {
+		s.stats.StoredBytes += uint64(len(v))
+		key, isChunk := objKey(k)
+		s.sizes[key] += uint64(len(v))
+		if isChunk {
+			s.stats.Chunks++
+			chunkKeys[key] = append(chunkKeys[key], append([]byte(nil), k...))
+			return nil
+		}
+		s.stats.Objects++
+		obj := cacheobj.CacheObj{}
+		if err := gob.NewDecoder(bytes.NewReader(v)).Decode(&obj); err != nil {
+			s.badObjects = append(s.badObjects, key)
+			return nil
+		}
+		objChunkCounts[key] = 0
+		if obj.Chunked {
+			objChunkCounts[key] = obj.ChunkCount
+			objChunkIDs[key] = obj.ChunkID
+		}
+		return nil
+	})
+
+	for key, chunkCount := range objChunkCounts {
+		objChunks := 0
+		others := [][]byte{} // chunks left over from other objects under the key, e.g. streams which never finished
+		for _, k := range chunkKeys[key] {
+			if id, ok := chunkID(k); ok && chunkCount > 0 && id == objChunkIDs[key] {
+				objChunks++
+			} else {
+				others = append(others, k)
+			}
+		}
+		if objChunks != chunkCount {
+			s.badObjects = append(s.badObjects, key)
+			continue
+		}
+		s.orphanChunks = append(s.orphanChunks, others...)
+		s.sizes[key] -= chunksSize(b, others)
+	}
+	bad := map[string]struct{}{}
+	for _, key := range s.badObjects {
+		bad[key] = struct{}{}
+		delete(objChunkCounts, key)
+		delete(s.sizes, key)
+	}
+	for key, keys := range chunkKeys {
+		if _, ok := objChunkCounts[key]; ok {
+			continue
+		}
+		if _, ok := bad[key]; !ok {
+			s.orphanChunks = append(s.orphanChunks, keys...) // chunks of bad objects are deleted with them
+		}
+		delete(s.sizes, key)
+	}
+	s.stats.BadObjects = len(s.badObjects)
+	s.stats.OrphanChunks = len(s.orphanChunks)
+
+	indexed := map[string]struct{}{}
+	if ib := tx.Bucket([]byte(IndexBucketName)); ib != nil {
+		ib.ForEach(func(k, v []byte) error {
+			s.stats.IndexEntries++
+			key := string(k)
+			size, ok := s.sizes[key]
+			if !ok {
+				s.staleIndex = append(s.staleIndex, key)
+				return nil
+			}
+			if entry, err := parseIndexEntry(v); err == nil && entry.Size == size {
+				indexed[key] = struct{}{}
+			}
+			return nil
+		})
+	}
+	for key := range s.sizes {
+		if _, ok := indexed[key]; !ok {
+			s.unindexed = append(s.unindexed, key)
+		}
+		if h != nil && fileIdx(h, key) != idx {
+			s.misplaced = append(s.misplaced, key)
+		}
+	}
+	s.stats.StaleIndex = len(s.staleIndex)
+	s.stats.Unindexed = len(s.unindexed)
+	s.stats.Misplaced = len(s.misplaced)
+	return s
+}
+
+func chunksSize(b *bolt.Bucket, keys [][]byte) uint64 {
+	size := uint64(0)
+	for _, k := range keys {
+		size += uint64(len(b.Get(k)))
+	}
+	return size
+}
+
+// Inspect returns the statistics of the given group of disk cache files. The files must not be in use by a running Grove, and are opened read-only. Files which don't exist have empty statistics.
+func Inspect(files []config.CacheFile) ([]FileStats, error) {
+	h := placementHash(files)
+	stats := []FileStats{}
+	for i, file := range files {
+		fi, err := os.Stat(file.Path)
+		if os.IsNotExist(err) {
+			stats = append(stats, FileStats{Path: file.Path}) // created when Grove or Compact opens it
+			continue
+		} else if err != nil {
+			return nil, errors.New("getting file info for '" + file.Path + "': " + err.Error())
+		}
+		db, err := bolt.Open(file.Path, 0600, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: true})
+		if err != nil {
+			return nil, errors.New("opening database '" + file.Path + "': " + err.Error())
+		}
+		s := fileScan{}
+		db.View(func(tx *bolt.Tx) error {
+			s = scanFile(tx, h, i)
+			return nil
+		})
+		db.Close()
+		s.stats.Path = file.Path
+		s.stats.FileBytes = fi.Size()
+		stats = append(stats, s.stats)
+	}
+	return stats, nil
+}
+
+// Compact repairs and compacts the given group of disk cache files. The files must not be in use by a running Grove.
+//
+// Misplaced objects are moved to the file their key is placed in, bad objects and orphan chunks are removed, the index is made consistent with the objects, and each file is copied to reclaim the space of removed objects. Objects with no index entry are given the oldest last access time, so they're the first evicted.
+func Compact(files []config.CacheFile) error {
+	h := placementHash(files)
+	dbs := make([]*bolt.DB, len(files))
+	defer func() {
+		for _, db := range dbs {
+			if db != nil {
+				db.Close()
+			}
+		}
+	}()
+	for i, file := range files {
+		db, err := openDB(file.Path)
+		if err != nil {
+			return err
+		}
+		dbs[i] = db
+		if err := db.Update(func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists([]byte(IndexBucketName))
+			return err
+		}); err != nil {
+			return errors.New("creating index bucket for database '" + file.Path + "': " + err.Error())
+		}
+	}
+
+	for i, db := range dbs {
+		s := fileScan{}
+		db.View(func(tx *bolt.Tx) error {
+			s = scanFile(tx, h, i)
+			return nil
+		})
+		for _, key := range s.misplaced {
+			if err := moveObj(db, dbs[fileIdx(h, key)], key); err != nil {
+				return errors.New("moving '" + key + "' from '" + files[i].Path + "': " + err.Error())
+			}
+		}
+	}
+
+	for i, db := range dbs {
+		if err := db.Update(func(tx *bolt.Tx) error { return repairFile(tx, scanFile(tx, h, i)) }); err != nil {
+			return errors.New("repairing '" + files[i].Path + "': " + err.Error())
+		}
+		db.Close()
+		dbs[i] = nil
+		if err := compactFile(files[i].Path); err != nil {
+			return errors.New("compacting '" + files[i].Path + "': " + err.Error())
+		}
+	}
+	return nil
+}
+
+// repairFile removes the bad objects, orphan chunks, and stale index entries of the given scan, and indexes unindexed objects.
+func repairFile(tx *bolt.Tx, s fileScan) error {
+	b := tx.Bucket([]byte(BucketName))
+	for _, key := range s.badObjects {
+		if err := deleteObj(tx, key); err != nil {
+			return errors.New("deleting bad object '" + key + "': " + err.Error())
+		}
+	}
+	for _, k := range s.orphanChunks {
+		if err := b.Delete(k); err != nil {
+			return errors.New("deleting orphan chunk '" + string(k) + "': " + err.Error())
+		}
+	}
+	for _, key := range s.staleIndex {
+		if err := deleteIndexEntry(tx, key); err != nil {
+			return errors.New("deleting stale index entry '" + key + "': " + err.Error())
+		}
+	}
+	for _, key := range s.unindexed {
+		entry, ok := getIndexEntry(tx, key)
+		if !ok {
+			entry.LastAccess = unknownLastAccess
+		}
+		entry.Size = s.sizes[key]
+		if err := putIndexEntry(tx, key, entry); err != nil {
+			return errors.New("indexing '" + key + "': " + err.Error())
+		}
+	}
+	return nil
+}
+
+// moveObj moves the object with the given key, its chunks, and its index entry, from the src to the dst database, replacing any object with the same key in dst.
+func moveObj(src *bolt.DB, dst *bolt.DB, key string) error {
+	kvs := [][2][]byte{}
+	entry, hasEntry := indexEntry{}, false
+	src.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(BucketName))
+		prefix := []byte(key + chunkSep)
+		cursor := b.Cursor()
+		for k, v := cursor.Seek([]byte(key)); k != nil && (string(k) == key || bytes.HasPrefix(k, prefix)); k, v = cursor.Next() {
+			kvs = append(kvs, [2][]byte{append([]byte(nil), k...), append([]byte(nil), v...)})
+		}
+		entry, hasEntry = getIndexEntry(tx, key)
+		return nil
+	})
+	if !hasEntry {
+		entry.LastAccess = unknownLastAccess // the size is fixed when the dst file is repaired
+	}
+
+	err := dst.Update(func(tx *bolt.Tx) error {
+		if err := deleteObj(tx, key); err != nil {
+			return err
+		}
+		b := tx.Bucket([]byte(BucketName))
+		for _, kv := range kvs {
+			if err := b.Put(kv[0], kv[1]); err != nil {
+				return err
+			}
+		}
+		return putIndexEntry(tx, key, entry)
+	})
+	if err != nil {
+		return errors.New("adding to destination: " + err.Error())
+	}
+	return src.Update(func(tx *bolt.Tx) error { return deleteObj(tx, key) })
+}
+
+// compactFile copies the bolt database at the given path to a new file, and replaces it, which reclaims the space of deleted data.
+func compactFile(path string) error {
+	tmpPath := path + ".compact"
+	if err := os.Remove(tmpPath); err != nil && !os.IsNotExist(err) {
+		return errors.New("removing old temporary file: " + err.Error())
+	}
+	src, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: true})
+	if err != nil {
+		return errors.New("opening: " + err.Error())
+	}
+	defer src.Close()
+	dst, err := bolt.Open(tmpPath, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return errors.New("opening temporary file: " + err.Error())
+	}
+	if err := bolt.Compact(dst, src, compactTxMaxBytes); err != nil {
+		dst.Close()
+		os.Remove(tmpPath)
+		return errors.New("copying: " + err.Error())
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmpPath)
+		return errors.New("closing temporary file: " + err.Error())
+	}
+	return os.Rename(tmpPath, path)
+}
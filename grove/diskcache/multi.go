@@ -16,66 +16,181 @@ package diskcache
 
 import (
 	"errors"
+	"os"
 
 	"github.com/apache/trafficcontrol/v8/grove/cacheobj"
+	"github.com/apache/trafficcontrol/v8/grove/chash"
 	"github.com/apache/trafficcontrol/v8/grove/config"
 
 	"github.com/apache/trafficcontrol/v8/lib/go-log"
 
-	"github.com/dchest/siphash"
+	bolt "go.etcd.io/bbolt"
 )
 
-// MultiDiskCache is a disk cache using multiple files. It exists primarily to allow caching across multiple physical disks, but may be used for other purposes. For example, it may be more performant to use multiple files, or it may be advantageous to keep each remap rule in its own file. Keys are evenly distributed across the given files via consistent hashing.
-type MultiDiskCache []*DiskCache
+// MultiDiskCache is a disk cache using multiple files. It exists primarily to allow caching across multiple physical disks, but may be used for other purposes. For example, it may be more performant to use multiple files, or it may be advantageous to keep each remap rule in its own file. Keys are distributed across the given files via consistent hashing, weighted by the size of each file, so adding or removing a file only moves the keys of a fraction of the objects.
+type MultiDiskCache struct {
+	caches []*DiskCache
+	hash   chash.ATSConsistentHash
+}
 
+// NewMulti opens the given group of disk cache files, creating any which don't exist. Files written by older versions, which placed keys by siphash rather than placementHash, are migrated first, see migrateLegacyPlacement.
 func NewMulti(files []config.CacheFile) (*MultiDiskCache, error) {
+	if err := migrateLegacyPlacement(files); err != nil {
+		return nil, errors.New("migrating disk cache files from older version: " + err.Error())
+	}
 	caches := make([]*DiskCache, len(files), len(files))
 	for i, file := range files {
 		cache, err := New(file.Path, file.Bytes)
 		if err != nil {
+			for _, opened := range caches[:i] {
+				opened.Close()
+			}
 			return nil, errors.New("creating disk cache '" + file.Path + "': " + err.Error())
 		}
-		cache.ResetAfterRestart() // should this be optional?
 		caches[i] = cache
 	}
+	return &MultiDiskCache{caches: caches, hash: placementHash(files)}, nil
+}
 
-	mdc := MultiDiskCache(caches)
-	return &mdc, nil
+// placementHash returns the consistent hash of keys to the given files. Files are hashed by their path, so a file's objects are only found if its path doesn't change.
+func placementHash(files []config.CacheFile) chash.ATSConsistentHash {
+	totalBytes := uint64(0)
+	for _, file := range files {
+		totalBytes += file.Bytes
+	}
+	h := chash.NewSimpleATSConsistentHash(chash.DefaultSimpleATSConsistentHashReplicas)
+	for i, file := range files {
+		weight := 1.0
+		if totalBytes > 0 {
+			weight = float64(file.Bytes) * float64(len(files)) / float64(totalBytes)
+		}
+		h.Insert(&chash.ATSConsistentHashNode{Name: file.Path, Index: i}, weight)
+	}
+	return h
 }
 
-// KeyIdx gets the consistent-hashed index of which DiskCache the key is mapped to.
+// migrateLegacyPlacement moves objects in files written by older versions, which placed keys by siphash modulo the number of files, to the file placementHash places them in, so they aren't lost on upgrade. Files from older versions have objects but no persisted index, so this only moves objects the first time a group of files is opened by this version; after that, every file has an index, and nothing is opened here.
+func migrateLegacyPlacement(files []config.CacheFile) error {
+	dbs := make([]*bolt.DB, 0, len(files))
+	defer func() {
+		for _, db := range dbs {
+			if db != nil {
+				db.Close()
+			}
+		}
+	}()
+	legacy := false
+	for _, file := range files {
+		if _, err := os.Stat(file.Path); os.IsNotExist(err) {
+			dbs = append(dbs, nil) // created with an index by New
+			continue
+		}
+		db, err := openDB(file.Path)
+		if err != nil {
+			return err
+		}
+		dbs = append(dbs, db)
+		db.View(func(tx *bolt.Tx) error {
+			legacy = legacy || hasLegacyIndex(tx)
+			return nil
+		})
+	}
+	if !legacy {
+		return nil
+	}
+
+	// every file needs an index before objects are moved, because moveObj moves index entries, and a file with an index isn't rebuilt by New
+	for i, file := range files {
+		if dbs[i] == nil {
+			db, err := openDB(file.Path)
+			if err != nil {
+				return err
+			}
+			dbs[i] = db
+		}
+		if err := dbs[i].Update(createIndex); err != nil {
+			return errors.New("creating index for database '" + file.Path + "': " + err.Error())
+		}
+	}
+
+	h := placementHash(files)
+	for i, db := range dbs {
+		misplaced := []string{}
+		db.View(func(tx *bolt.Tx) error {
+			for key := range objSizes(tx.Bucket([]byte(BucketName))) {
+				if fileIdx(h, key) != i {
+					misplaced = append(misplaced, key)
+				}
+			}
+			return nil
+		})
+		if len(misplaced) > 0 {
+			log.Infof("DiskCache '%v' moving %v objects from older version to the files they're now placed in\n", files[i].Path, len(misplaced))
+		}
+		for _, key := range misplaced {
+			if err := moveObj(db, dbs[fileIdx(h, key)], key); err != nil {
+				return errors.New("moving '" + key + "' from '" + files[i].Path + "': " + err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// fileIdx returns the index of the file the given key is placed in, by the given placementHash.
+func fileIdx(h chash.ATSConsistentHash, key string) int {
+	iter, _, err := h.Lookup(key)
+	if err != nil {
+		return 0 // only happens for empty keys, which are never stored
+	}
+	return iter.Val().Index
+}
+
+// keyIdx gets the consistent-hashed index of which DiskCache the key is mapped to.
 func (c *MultiDiskCache) keyIdx(key string) int {
-	return int(siphash.Hash(0, 0, []byte(key)) % uint64(len(*c)))
+	return fileIdx(c.hash, key)
 }
 
 func (c *MultiDiskCache) Add(key string, val *cacheobj.CacheObj) bool {
 	i := c.keyIdx(key)
 	log.Debugf("MultiDiskCache.Add key '%+v' size '%+v' mapped to %+v\n", key, val.Size, i)
-	return (*c)[i].Add(key, val)
+	return c.caches[i].Add(key, val)
 }
 
 func (c *MultiDiskCache) Get(key string) (*cacheobj.CacheObj, bool) {
 	i := c.keyIdx(key)
 	log.Debugf("MultiDiskCache.Get key '%+v' mapped to %+v\n", key, i)
-	return (*c)[i].Get(key)
+	return c.caches[i].Get(key)
 }
 
 func (c *MultiDiskCache) Peek(key string) (*cacheobj.CacheObj, bool) {
 	i := c.keyIdx(key)
 	log.Debugf("MultiDiskCache.Get key '%+v' mapped to %+v\n", key, i)
-	return (*c)[i].Peek(key)
+	return c.caches[i].Peek(key)
+}
+
+// AddChunk adds the chunk to the DiskCache the key is mapped to, so all chunks are stored with their object.
+func (c *MultiDiskCache) AddChunk(key string, id uint64, idx int, chunk []byte) bool {
+	return c.caches[c.keyIdx(key)].AddChunk(key, id, idx, chunk)
+}
+
+func (c *MultiDiskCache) GetChunk(key string, id uint64, idx int) ([]byte, bool) {
+	return c.caches[c.keyIdx(key)].GetChunk(key, id, idx)
+}
+
+func (c *MultiDiskCache) Remove(key string) {
+	c.caches[c.keyIdx(key)].Remove(key)
 }
 
 func (c *MultiDiskCache) Size() uint64 {
 	sum := uint64(0)
-	for _, cache := range *c {
+	for _, cache := range c.caches {
 		sum += cache.Size()
 	}
 	return sum
 }
 
 func (c *MultiDiskCache) Close() {
-	for _, cache := range *c {
+	for _, cache := range c.caches {
 		cache.Close()
 	}
 }
@@ -83,7 +198,7 @@ func (c *MultiDiskCache) Close() {
 func (c *MultiDiskCache) Keys() []string {
 	// TODO Fix this - each cache is an independent LRU, and the below doesn't make sense.
 	arr := make([]string, 0)
-	for _, cache := range *c {
+	for _, cache := range c.caches {
 		arr = append(arr, cache.Keys()...)
 	}
 	return arr
@@ -91,7 +206,7 @@ func (c *MultiDiskCache) Keys() []string {
 
 func (c *MultiDiskCache) Capacity() uint64 {
 	sum := uint64(0)
-	for _, cache := range *c {
+	for _, cache := range c.caches {
 		sum += cache.Capacity()
 	}
 	return sum
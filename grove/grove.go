@@ -29,6 +29,7 @@ import (
 	"runtime/pprof"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/net/http2"
@@ -36,15 +37,21 @@ import (
 
 	"github.com/apache/trafficcontrol/v8/lib/go-log"
 
+	"github.com/apache/trafficcontrol/v8/grove/accesslog"
 	"github.com/apache/trafficcontrol/v8/grove/cache"
 	"github.com/apache/trafficcontrol/v8/grove/config"
 	"github.com/apache/trafficcontrol/v8/grove/diskcache"
+	"github.com/apache/trafficcontrol/v8/grove/health"
 	"github.com/apache/trafficcontrol/v8/grove/icache"
 	"github.com/apache/trafficcontrol/v8/grove/memcache"
+	"github.com/apache/trafficcontrol/v8/grove/peer"
 	"github.com/apache/trafficcontrol/v8/grove/plugin"
+	"github.com/apache/trafficcontrol/v8/grove/purge"
 	"github.com/apache/trafficcontrol/v8/grove/remap"
 	"github.com/apache/trafficcontrol/v8/grove/remapdata"
 	"github.com/apache/trafficcontrol/v8/grove/stat"
+	"github.com/apache/trafficcontrol/v8/grove/stream"
+	"github.com/apache/trafficcontrol/v8/grove/thread"
 	"github.com/apache/trafficcontrol/v8/grove/tiercache"
 	"github.com/apache/trafficcontrol/v8/grove/web"
 )
@@ -93,12 +100,30 @@ func main() {
 	reqIdleConnTimeout := time.Duration(cfg.ReqIdleConnTimeoutMS) * time.Millisecond
 	baseTransport := remap.NewRemappingTransport(reqTimeout, reqKeepAlive, reqMaxIdleConns, reqIdleConnTimeout)
 
+	// parentHealth is shared by all remap rules, including after config reloads, so parents marked down stay down.
+	parentHealth := health.New()
+
+	// accessLogs is shared by all handlers, including after config reloads, so logs whose location is unchanged aren't reopened and lose no lines.
+	accessLogs := accesslog.New()
+	if err := accessLogs.Update(cfg.AccessLogs); err != nil {
+		log.Errorf("starting service: loading access logs: %v\n", err)
+		os.Exit(1)
+	}
+
+	// peers is shared by all remap rules, including after config reloads, so peers marked down stay down.
+	peers := peer.New(baseTransport)
+	if err := peers.Update(cfg.Peers, cfg.PeerSelf, cfg.PeerHealthCheck); err != nil {
+		log.Errorf("starting service: loading peers: %v\n", err)
+		os.Exit(1)
+	}
+
 	plugins := plugin.Get(cfg.Plugins)
-	remapper, err := remap.LoadRemapper(cfg.RemapRulesFile, plugins.LoadFuncs(), caches, baseTransport)
+	remapper, err := remap.LoadRemapper(cfg.RemapRulesFile, plugins.LoadFuncs(), caches, baseTransport, parentHealth, peers)
 	if err != nil {
 		log.Errorf("starting service: loading remap rules: %v\n", err)
 		os.Exit(1)
 	}
+	parentHealth.Update(remap.HealthChecks(remapper.Rules()))
 
 	certs, err := loadCerts(remapper.Rules())
 	if err != nil {
@@ -133,10 +158,39 @@ func main() {
 	// TODO pass total size for all file groups?
 	stats := stat.New(remapper.Rules(), caches, uint64(cfg.CacheSizeBytes), httpConns, httpsConns, Version)
 
-	buildHandler := func(scheme string, port string, conns *web.ConnMap, stats stat.Stats, pluginContext map[string]*interface{}) *cache.HandlerPointer {
-		return cache.NewHandlerPointer(cache.NewHandler(
+	// streams is shared by all handlers, including after config reloads, so concurrent requests for the same in-flight object are always coalesced.
+	streams := stream.NewStreams()
+
+	// purges is shared by all handlers, including after config reloads, so rules added via the purge endpoint are kept.
+	purges := purge.New()
+	if err := purges.LoadFile(cfg.PurgeRulesFile); err != nil {
+		log.Errorln("starting service: loading purge rules, no file rules will be applied: " + err.Error())
+	}
+
+	// ruleThrottlers and getter are shared by all handlers, including after config reloads, so each rule's limit applies to all its parent requests, and concurrent requests for the same object are always coalesced.
+	ruleThrottlers := cache.NewRuleThrottlers()
+	ruleThrottlers.Update(remapper.Rules(), uint64(cfg.ConcurrentRuleRequests))
+	getter := thread.NewGetter()
+	// rateLimiters is shared by all handlers, including after config reloads, so clients which are limited stay limited.
+	rateLimiters := cache.NewRuleRateLimiters()
+	rateLimiters.Update(remapper.Rules())
+
+	pluginContext := map[string]*interface{}{}
+
+	// reloadM serializes reloads, which may be requested by both signals and the reload endpoint.
+	reloadM := sync.Mutex{}
+
+	// reloadRules is set after the handlers are created, because it replaces them. Handlers call it via reloadRulesFunc.
+	reloadRules := (func() error)(nil)
+	reloadRulesFunc := func() error { return reloadRules() }
+
+	// newHandler creates a handler with the current config, remap rules, and stats.
+	newHandler := func(scheme string, port string, conns *web.ConnMap) *cache.Handler {
+		return cache.NewHandler(
 			remapper,
-			uint64(cfg.ConcurrentRuleRequests),
+			ruleThrottlers,
+			rateLimiters,
+			getter,
 			stats,
 			scheme,
 			port,
@@ -148,13 +202,47 @@ func main() {
 			httpConns,
 			httpsConns,
 			cfg.InterfaceName,
-		))
+			streams,
+			cfg.StreamChunkBytes,
+			purges,
+			cfg.PurgeToken,
+			peers,
+			accessLogs,
+			reloadRulesFunc,
+		)
 	}
 
-	pluginContext := map[string]*interface{}{}
+	httpHandler := cache.NewHandlerPointer(newHandler("http", strconv.Itoa(cfg.Port), httpConns))
+	httpsHandler := cache.NewHandlerPointer(newHandler("https", strconv.Itoa(cfg.HTTPSPort), httpsConns))
+
+	// applyRemapper replaces the handlers with new handlers using the given remap rules. Rules which still exist keep their throttlers, rate limits, and stats, parents which still exist keep their health, and requests in progress finish with the rules they started with. Must be called with reloadM locked.
+	applyRemapper := func(newRemapper remap.HTTPRequestRemapper) {
+		remapper = newRemapper
+		parentHealth.Update(remap.HealthChecks(remapper.Rules()))
+		warnUnknownAccessLogs(remapper.Rules(), accessLogs)
+		ruleThrottlers.Update(remapper.Rules(), uint64(cfg.ConcurrentRuleRequests))
+		rateLimiters.Update(remapper.Rules())
+		stats = stats.Reload(remapper.Rules(), httpConns, httpsConns)
+		httpHandler.Set(newHandler("http", strconv.Itoa(cfg.Port), httpConns))
+		httpsHandler.Set(newHandler("https", strconv.Itoa(cfg.HTTPSPort), httpsConns))
+		plugins.OnStartup(remapper.PluginCfg(), pluginContext, plugin.StartupData{Config: cfg, Shared: remapper.PluginSharedCfg()})
+		stats.System().AddConfigReload()
+		stats.System().SetLastReload(time.Now())
+	}
 
-	httpHandler := buildHandler("http", strconv.Itoa(cfg.Port), httpConns, stats, pluginContext)
-	httpsHandler := buildHandler("https", strconv.Itoa(cfg.HTTPSPort), httpsConns, stats, pluginContext)
+	reloadRules = func() error {
+		reloadM.Lock()
+		defer reloadM.Unlock()
+		log.Infoln("reloading remap rules")
+		stats.System().AddConfigReloadRequests()
+		stats.System().SetLastReloadRequest(time.Now())
+		newRemapper, err := remap.LoadRemapper(cfg.RemapRulesFile, plugins.LoadFuncs(), caches, baseTransport, parentHealth, peers)
+		if err != nil {
+			return errors.New("loading remap rules, keeping existing rules: " + err.Error())
+		}
+		applyRemapper(newRemapper)
+		return nil
+	}
 
 	idleTimeout := time.Duration(cfg.ServerIdleTimeoutMS) * time.Millisecond
 	readTimeout := time.Duration(cfg.ServerReadTimeoutMS) * time.Millisecond
@@ -170,7 +258,11 @@ func main() {
 	}
 
 	reloadConfig := func() {
+		reloadM.Lock()
+		defer reloadM.Unlock()
 		log.Infoln("reloading config")
+		stats.System().AddConfigReloadRequests()
+		stats.System().SetLastReloadRequest(time.Now())
 		err := error(nil)
 		oldCfg := cfg
 		cfg, err = config.LoadConfig(*configFileName)
@@ -194,11 +286,9 @@ func main() {
 		}
 
 		plugins = plugin.Get(cfg.Plugins)
-		oldRemapper := remapper
-		remapper, err = remap.LoadRemapper(cfg.RemapRulesFile, plugins.LoadFuncs(), caches, baseTransport)
+		newRemapper, err := remap.LoadRemapper(cfg.RemapRulesFile, plugins.LoadFuncs(), caches, baseTransport, parentHealth, peers)
 		if err != nil {
 			log.Errorln("reloading config: failed to load remap rules, keeping existing rules: " + err.Error())
-			remapper = oldRemapper
 			return
 		}
 
@@ -219,43 +309,19 @@ func main() {
 			}
 		}
 
-		stats = stat.New(remapper.Rules(), caches, uint64(cfg.CacheSizeBytes), httpConns, httpsConns, Version) // TODO copy stats from old stats object?
+		if err := purges.LoadFile(cfg.PurgeRulesFile); err != nil {
+			log.Errorln("reloading config: failed to load purge rules, keeping existing rules: " + err.Error())
+		}
 
-		httpCacheHandler := cache.NewHandler(
-			remapper,
-			uint64(cfg.ConcurrentRuleRequests),
-			stats,
-			"http",
-			strconv.Itoa(cfg.Port),
-			httpConns,
-			cfg.RFCCompliant,
-			cfg.ConnectionClose,
-			plugins,
-			pluginContext,
-			httpConns,
-			httpsConns,
-			cfg.InterfaceName,
-		)
-		httpHandler.Set(httpCacheHandler)
+		if err := peers.Update(cfg.Peers, cfg.PeerSelf, cfg.PeerHealthCheck); err != nil {
+			log.Errorln("reloading config: failed to load peers, keeping existing peers: " + err.Error())
+		}
 
-		httpsCacheHandler := cache.NewHandler(
-			remapper,
-			uint64(cfg.ConcurrentRuleRequests),
-			stats,
-			"https",
-			strconv.Itoa(cfg.HTTPSPort),
-			httpsConns,
-			cfg.RFCCompliant,
-			cfg.ConnectionClose,
-			plugins,
-			pluginContext,
-			httpConns,
-			httpsConns,
-			cfg.InterfaceName,
-		)
-		httpsHandler.Set(httpsCacheHandler)
+		if err := accessLogs.Update(cfg.AccessLogs); err != nil {
+			log.Errorln("reloading config: failed to load access logs, keeping existing logs: " + err.Error())
+		}
 
-		plugins.OnStartup(remapper.PluginCfg(), pluginContext, plugin.StartupData{Config: cfg, Shared: remapper.PluginSharedCfg()})
+		applyRemapper(newRemapper)
 
 		if cfg.Port != oldCfg.Port {
 			ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
@@ -293,9 +359,34 @@ func main() {
 	if *pprof {
 		profile()
 	}
+	go closeOnShutdown(caches, accessLogs)
 	signalReloader(unix.SIGHUP, reloadConfig)
 }
 
+// closeOnShutdown closes all caches and access logs and exits when the service is terminated, so disk caches persist their LRU index and are warm when the service is restarted, and buffered access log lines are written.
+func closeOnShutdown(caches map[string]icache.Cache, accessLogs *accesslog.Logs) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, unix.SIGTERM, unix.SIGINT)
+	sig := <-c
+	log.Infof("received %v, closing caches and access logs and shutting down\n", sig)
+	for _, cache := range caches {
+		cache.Close()
+	}
+	accessLogs.Close()
+	os.Exit(0)
+}
+
+// warnUnknownAccessLogs logs a warning for each access log selected by a remap rule which doesn't exist. Requests aren't written to unknown logs, but rules are still loaded, so a log may be removed from the config without changing the rules.
+func warnUnknownAccessLogs(rules []remapdata.RemapRule, accessLogs *accesslog.Logs) {
+	for _, rule := range rules {
+		for _, sel := range rule.AccessLogs {
+			if !accessLogs.Has(sel.Name) {
+				log.Warnf("remap rule %v selects access log '%v', which doesn't exist\n", rule.Name, sel.Name)
+			}
+		}
+	}
+}
+
 func profile() {
 	go func() {
 		count := 0
@@ -0,0 +1,104 @@
+package main
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// grovecachetool inspects, repairs, and compacts the disk cache files of a stopped Grove service.
+//
+// Usage: grovecachetool [-cfg /etc/grove/grove.cfg] [-name cache-name] inspect|compact
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/apache/trafficcontrol/v8/grove/config"
+	"github.com/apache/trafficcontrol/v8/grove/diskcache"
+)
+
+const DefaultConfigPath = "/etc/grove/grove.cfg"
+
+// Exit codes are defined in the documentation, DO NOT change to iota, to avoid ambiguity.
+const (
+	ExitSuccess = 0
+	ExitError   = 1
+	ExitUsage   = 2
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags] inspect|compact\n\n", os.Args[0])
+	fmt.Fprintln(os.Stderr, "  inspect   print the objects, index, and problems of each disk cache file")
+	fmt.Fprintln(os.Stderr, "  compact   move misplaced objects, remove bad objects and orphan chunks, repair the index, and reclaim free space")
+	fmt.Fprintln(os.Stderr, "\nGrove must be stopped, or the cache files will fail to open.\n\nFlags:")
+	flag.PrintDefaults()
+}
+
+func main() {
+	cfgPath := flag.String("cfg", DefaultConfigPath, "The Grove config file, whose cache_files to use")
+	name := flag.String("name", "", "The name of the cache_files group to use. If empty, all groups are used")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 1 || (flag.Arg(0) != "inspect" && flag.Arg(0) != "compact") {
+		usage()
+		os.Exit(ExitUsage)
+	}
+
+	cfg, err := config.LoadConfig(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config '%s': %v\n", *cfgPath, err)
+		os.Exit(ExitError)
+	}
+
+	names := []string{}
+	for cacheName := range cfg.CacheFiles {
+		if *name == "" || cacheName == *name {
+			names = append(names, cacheName)
+		}
+	}
+	if len(names) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: config '%s' has no cache_files named '%s'\n", *cfgPath, *name)
+		os.Exit(ExitError)
+	}
+	sort.Strings(names)
+
+	for _, cacheName := range names {
+		files := cfg.CacheFiles[cacheName]
+		if flag.Arg(0) == "compact" {
+			if err := diskcache.Compact(files); err != nil {
+				fmt.Fprintf(os.Stderr, "Error compacting cache '%s': %v\n", cacheName, err)
+				os.Exit(ExitError)
+			}
+		}
+		stats, err := diskcache.Inspect(files)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error inspecting cache '%s': %v\n", cacheName, err)
+			os.Exit(ExitError)
+		}
+		printStats(cacheName, stats)
+	}
+	os.Exit(ExitSuccess)
+}
+
+func printStats(name string, stats []diskcache.FileStats) {
+	fmt.Printf("cache '%s':\n", name)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tFILE BYTES\tSTORED BYTES\tOBJECTS\tCHUNKS\tINDEXED\tUNINDEXED\tSTALE INDEX\tBAD OBJECTS\tORPHAN CHUNKS\tMISPLACED")
+	for _, s := range stats {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\n", s.Path, s.FileBytes, s.StoredBytes, s.Objects, s.Chunks, s.IndexEntries, s.Unindexed, s.StaleIndex, s.BadObjects, s.OrphanChunks, s.Misplaced)
+	}
+	w.Flush()
+}
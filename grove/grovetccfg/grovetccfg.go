@@ -34,10 +34,12 @@ import (
 	"strings"
 	"time"
 
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
 	"github.com/apache/trafficcontrol/v8/lib/go-tc"
 	to "github.com/apache/trafficcontrol/v8/traffic_ops/v3-client"
 
 	"github.com/apache/trafficcontrol/v8/grove/config"
+	"github.com/apache/trafficcontrol/v8/grove/purge"
 	"github.com/apache/trafficcontrol/v8/grove/remap"
 	"github.com/apache/trafficcontrol/v8/grove/remapdata"
 	"github.com/apache/trafficcontrol/v8/grove/web"
@@ -51,6 +53,10 @@ const GroveConfigFile = "grove.cfg"
 const GroveConfigPath = "/etc/grove/" + GroveConfigFile
 const ConfigHistory = "cfg_history/"
 const RemapHistory = "remap_history/"
+const PurgeHistory = "purge_history/"
+
+// MinPurgeTTL is the minimum time purge rules created from Traffic Ops invalidation jobs are in effect, for jobs with a missing or invalid TTL.
+const MinPurgeTTL = time.Hour
 
 // Exit codes are defined in the documentation, DO NOT change to iota, to avoid ambiguity.
 const (
@@ -75,6 +81,24 @@ func GetRemapPath() (string, error) {
 	return cfg.RemapRulesFile, nil
 }
 
+// GetPurgeRulesPath returns the purge rules file of the Grove config. If the config has no purge rules file, returns an empty string.
+func GetPurgeRulesPath() (string, error) {
+	cfg, err := config.LoadConfig(GroveConfigPath)
+	if err != nil {
+		return "", errors.New("loading Grove config file: " + err.Error())
+	}
+	return cfg.PurgeRulesFile, nil
+}
+
+// hasPurgeRules returns whether the current Grove config has a purge rules file, which invalidation jobs are applied to. If there's no config yet, returns false.
+func hasPurgeRules() (bool, error) {
+	if _, err := os.Stat(GroveConfigPath); os.IsNotExist(err) {
+		return false, nil
+	}
+	path, err := GetPurgeRulesPath()
+	return path != "", err
+}
+
 // CopyAndGzipFile reads the src file, gzips the contents, and writes the result to dst.
 func CopyAndGzipFile(src, dst string) error {
 	srcF, err := os.Open(src)
@@ -224,7 +248,15 @@ func main() {
 			fmt.Println(time.Now().Format(time.RFC3339Nano) + " Error checking Traffic Ops update pending: " + err.Error())
 			os.Exit(ExitError)
 		}
-		if !needsUpdate {
+		applyJobs := revalPendingStatus
+		if !needsUpdate && revalPendingStatus {
+			// invalidation jobs are only applied to purge rules, so without them a pending reval doesn't need an update
+			if applyJobs, err = hasPurgeRules(); err != nil {
+				fmt.Println(time.Now().Format(time.RFC3339Nano) + " Error getting purge rules path: " + err.Error())
+				os.Exit(ExitError)
+			}
+		}
+		if !needsUpdate && !applyJobs {
 			os.Exit(ExitSuccess) // if no error and no update necessary, return success and print nothing
 		}
 	}
@@ -332,6 +364,34 @@ func main() {
 		os.Exit(ExitError)
 	}
 
+	purgePath, err := GetPurgeRulesPath()
+	if err != nil {
+		fmt.Println(time.Now().Format(time.RFC3339Nano) + " Error getting purge rules path: " + err.Error())
+		os.Exit(ExitError)
+	}
+
+	if purgePath != "" {
+		purgeRules, err := createPurgeRules(toc, rules)
+		if err != nil {
+			fmt.Println(time.Now().Format(time.RFC3339Nano) + " Error creating purge rules: " + err.Error())
+			os.Exit(ExitError)
+		}
+		if *pretty {
+			bts, err = json.MarshalIndent(purgeRules, "", "  ")
+		} else {
+			bts, err = json.Marshal(purgeRules)
+		}
+		if err != nil {
+			fmt.Println(time.Now().Format(time.RFC3339Nano) + " Error marshalling purge rules JSON: " + err.Error())
+			os.Exit(ExitError)
+		}
+		if err := WriteAndBackup(purgePath, PurgeHistory, bts); err != nil {
+			fmt.Println(time.Now().Format(time.RFC3339Nano) + " Error writing new purge rules file: " + err.Error())
+			os.Exit(ExitError)
+		}
+		revalPendingStatus = false // the invalidation jobs were applied
+	}
+
 	if !*noServiceReload {
 		if err := exec.Command("service", "grove", "reload").Run(); err != nil {
 			fmt.Println(time.Now().Format(time.RFC3339Nano) + " Error restarting grove service (but successfully updated config file): " + err.Error())
@@ -449,12 +509,74 @@ func setConfigParameter(cfg *config.Config, name string, value string) error {
 		cfg.ServerReadTimeoutMS, err = strconv.Atoi(value)
 	case "file_mem_bytes":
 		cfg.FileMemBytes, err = strconv.Atoi(value)
+	case "purge_rules_file":
+		cfg.PurgeRulesFile = value
+	case "purge_token":
+		cfg.PurgeToken = value
 	default:
 		err = fmt.Errorf(time.Now().Format(time.RFC3339Nano) + "No such config parameter '" + name + "', parameter ignored")
 	}
 	return err
 }
 
+// createPurgeRules creates Grove purge rules from the Traffic Ops invalidation jobs of the Delivery Services of the given remap rules. Expired jobs are omitted.
+func createPurgeRules(toc *to.Session, rules remap.RemapRules) (purge.RulesJSON, error) {
+	jobs, _, err := toc.GetInvalidationJobsWithHdr(nil, nil, nil)
+	if err != nil {
+		return purge.RulesJSON{}, errors.New("getting invalidation jobs from Traffic Ops: " + err.Error())
+	}
+
+	dses := map[string]struct{}{}
+	for _, rule := range rules.Rules {
+		// rule names are created as xmlid.from.to.pattern, and XMLIDs can't contain periods.
+		dses[strings.SplitN(rule.Name, ".", 2)[0]] = struct{}{}
+	}
+
+	now := time.Now()
+	purgeRules := purge.RulesJSON{Rules: []purge.Rule{}}
+	for _, job := range jobs {
+		if job.AssetURL == nil || job.DeliveryService == nil || job.StartTime == nil {
+			continue
+		}
+		if _, ok := dses[*job.DeliveryService]; !ok {
+			continue
+		}
+		ttl := time.Duration(job.TTLHours()) * time.Hour
+		if ttl < MinPurgeTTL {
+			ttl = MinPurgeTTL
+		}
+		start := job.StartTime.Time
+		end := start.Add(ttl)
+		if end.Before(now) {
+			continue
+		}
+		assetURL := *job.AssetURL
+		purgeType := purge.TypeRefresh
+		if strings.HasSuffix(assetURL, atscfg.RefetchSuffix) {
+			purgeType = purge.TypeRefetch
+		}
+		assetURL = strings.TrimSuffix(strings.TrimSuffix(assetURL, atscfg.RefetchSuffix), atscfg.RefreshSuffix)
+		purgeRules.Rules = append(purgeRules.Rules, purge.Rule{Regex: jobRegex(assetURL), Type: purgeType, Start: start, End: end})
+	}
+	return purgeRules, nil
+}
+
+// jobRegex returns the purge rule regex for the given invalidation job asset URL.
+//
+// Job asset URLs are on the origin host, but Grove cache keys are on the host of the remap rule target, which may be a parent cache. So, the asset URL scheme and host are replaced with a pattern matching any scheme and host, and only the path is matched.
+func jobRegex(assetURL string) string {
+	path := assetURL
+	if i := strings.Index(path, "://"); i >= 0 {
+		path = path[i+len("://"):]
+	}
+	if i := strings.Index(path, "/"); i >= 0 {
+		path = path[i:]
+	} else {
+		path = "/"
+	}
+	return "^[^:]+://[^/]+" + path
+}
+
 func createRulesOldAPI(toc *to.Session, host string, certDir string, servers map[string]tc.ServerV30) (remap.RemapRules, error) {
 	cachegroupsArr, _, err := toc.GetCacheGroupsNullableWithHdr(nil)
 	if err != nil {
@@ -0,0 +1,268 @@
+package health
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// health exists to track the health of parents, so requests skip parents which are down instead of failing on them and retrying.
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+)
+
+const (
+	DefaultFailures        = 3
+	DefaultRecoverSeconds  = 30
+	DefaultProbeIntervalMS = 5000
+	DefaultProbeTimeoutMS  = 2000
+	DefaultSuccesses       = 1
+)
+
+// Check is the configuration of health tracking for a parent.
+type Check struct {
+	// Failures is the number of consecutive failed requests or probes after which a parent is marked down. A request fails if the parent can't be reached, or responds with a retry code.
+	Failures int `json:"failures"`
+	// RecoverSeconds is how long a parent marked down by failed requests is skipped, before requests are sent to it again. A parent which fails again is marked down for another RecoverSeconds, and one which succeeds is marked up. Unused if the parent is probed, because probed parents are skipped until a probe succeeds.
+	RecoverSeconds int `json:"recover_seconds"`
+	// ProbePath is the path of active probe requests, which are sent to the parent URL with this path. If empty, the parent isn't probed.
+	ProbePath string `json:"probe_path"`
+	// ProbeIntervalMS is how often the parent is probed.
+	ProbeIntervalMS int `json:"probe_interval_ms"`
+	// ProbeTimeoutMS is how long to wait for a probe response before the probe fails.
+	ProbeTimeoutMS int `json:"probe_timeout_ms"`
+	// Successes is the number of consecutive successful probes after which a parent marked down is marked up. A probe succeeds if the parent responds with a 2xx code.
+	Successes int `json:"successes"`
+}
+
+// WithDefaults returns the check with the default of each unset field, or an error if any field is negative.
+func (c Check) WithDefaults() (Check, error) {
+	if c.Failures < 0 || c.RecoverSeconds < 0 || c.ProbeIntervalMS < 0 || c.ProbeTimeoutMS < 0 || c.Successes < 0 {
+		return Check{}, errors.New("health check values must not be negative")
+	}
+	if c.Failures == 0 {
+		c.Failures = DefaultFailures
+	}
+	if c.RecoverSeconds == 0 {
+		c.RecoverSeconds = DefaultRecoverSeconds
+	}
+	if c.ProbeIntervalMS == 0 {
+		c.ProbeIntervalMS = DefaultProbeIntervalMS
+	}
+	if c.ProbeTimeoutMS == 0 {
+		c.ProbeTimeoutMS = DefaultProbeTimeoutMS
+	}
+	if c.Successes == 0 {
+		c.Successes = DefaultSuccesses
+	}
+	return c, nil
+}
+
+// ParentCheck is the health check of a parent, and the transport to probe it with.
+type ParentCheck struct {
+	Check     Check
+	Transport *http.Transport
+}
+
+type parent struct {
+	url       string
+	check     Check
+	transport *http.Transport
+	failures  int // consecutive failed requests and probes
+	successes int // consecutive successful probes
+	down      bool
+	downUntil time.Time // when a parent marked down by requests is tried again; unused if the parent is probed
+	stop      chan struct{}
+	m         sync.Mutex
+}
+
+// Parents tracks the health of parents by their URL. It's threadsafe, and shared by all remap rules, so rules with the same parent share its health. Parents which aren't tracked are always healthy.
+type Parents struct {
+	parents map[string]*parent
+	m       sync.RWMutex
+}
+
+func New() *Parents {
+	return &Parents{parents: map[string]*parent{}}
+}
+
+// Update sets the parents to track, keyed by URL. Parents which were already tracked keep their health, and removed parents stop being tracked and probed.
+func (p *Parents) Update(checks map[string]ParentCheck) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	for url, pa := range p.parents {
+		check, ok := checks[url]
+		if ok && check.Check == pa.check && check.Transport == pa.transport {
+			continue
+		}
+		pa.stopProbe()
+		if !ok {
+			delete(p.parents, url)
+			continue
+		}
+		pa.m.Lock()
+		pa.check = check.Check
+		pa.transport = check.Transport
+		pa.m.Unlock()
+		pa.startProbe()
+	}
+	for url, check := range checks {
+		if _, ok := p.parents[url]; ok {
+			continue
+		}
+		pa := &parent{url: url, check: check.Check, transport: check.Transport}
+		p.parents[url] = pa
+		pa.startProbe()
+	}
+}
+
+func (p *Parents) get(url string) *parent {
+	p.m.RLock()
+	defer p.m.RUnlock()
+	return p.parents[url]
+}
+
+// Healthy returns whether requests should be sent to the parent with the given URL.
+func (p *Parents) Healthy(url string) bool {
+	pa := p.get(url)
+	if pa == nil {
+		return true
+	}
+	pa.m.Lock()
+	defer pa.m.Unlock()
+	if !pa.down {
+		return true
+	}
+	return pa.check.ProbePath == "" && !time.Now().Before(pa.downUntil)
+}
+
+// Report records the result of a request to the parent with the given URL. A success marks the parent up, and Check.Failures consecutive failures mark it down.
+func (p *Parents) Report(url string, success bool) {
+	pa := p.get(url)
+	if pa == nil {
+		return
+	}
+	pa.m.Lock()
+	defer pa.m.Unlock()
+	if success {
+		pa.failures = 0
+		if pa.down {
+			pa.down = false
+			log.Infoln("parent " + url + " marked up, request succeeded")
+		}
+		return
+	}
+	pa.failures++
+	if pa.failures < pa.check.Failures {
+		return
+	}
+	if pa.check.ProbePath == "" {
+		pa.downUntil = time.Now().Add(time.Duration(pa.check.RecoverSeconds) * time.Second)
+	}
+	if !pa.down {
+		pa.down = true
+		pa.successes = 0
+		log.Warnf("parent %v marked down, %v consecutive requests failed\n", url, pa.failures)
+	}
+}
+
+// Down returns the URLs of the tracked parents which are marked down.
+func (p *Parents) Down() []string {
+	p.m.RLock()
+	defer p.m.RUnlock()
+	down := []string{}
+	for url, pa := range p.parents {
+		pa.m.Lock()
+		if pa.down {
+			down = append(down, url)
+		}
+		pa.m.Unlock()
+	}
+	return down
+}
+
+// startProbe starts probing the parent, if its check has a probe path. Must not be called while the parent is being probed.
+func (pa *parent) startProbe() {
+	pa.m.Lock()
+	defer pa.m.Unlock()
+	if pa.check.ProbePath == "" {
+		return
+	}
+	pa.stop = make(chan struct{})
+	go pa.probePeriodically(pa.stop, pa.check, pa.transport)
+}
+
+func (pa *parent) stopProbe() {
+	pa.m.Lock()
+	defer pa.m.Unlock()
+	if pa.stop != nil {
+		close(pa.stop)
+		pa.stop = nil
+	}
+}
+
+func (pa *parent) probePeriodically(stop chan struct{}, check Check, transport *http.Transport) {
+	client := &http.Client{Transport: transport, Timeout: time.Duration(check.ProbeTimeoutMS) * time.Millisecond}
+	ticker := time.NewTicker(time.Duration(check.ProbeIntervalMS) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		pa.probe(client, check)
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probe makes a probe request of the parent, and records the result.
+func (pa *parent) probe(client *http.Client, check Check) {
+	ctx, cancel := context.WithTimeout(context.Background(), client.Timeout)
+	defer cancel()
+	success := false
+	result := ""
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pa.url+check.ProbePath, nil)
+	if err != nil {
+		result = "creating request: " + err.Error()
+	} else if resp, err := client.Do(req); err != nil {
+		result = err.Error()
+	} else {
+		resp.Body.Close()
+		success = resp.StatusCode >= 200 && resp.StatusCode < 300
+		result = "code " + strconv.Itoa(resp.StatusCode)
+	}
+
+	pa.m.Lock()
+	defer pa.m.Unlock()
+	if success {
+		pa.successes++
+		pa.failures = 0
+		if pa.down && pa.successes >= check.Successes {
+			pa.down = false
+			log.Infof("parent %v marked up, %v consecutive probes succeeded\n", pa.url, pa.successes)
+		}
+		return
+	}
+	pa.successes = 0
+	pa.failures++
+	if !pa.down && pa.failures >= check.Failures {
+		pa.down = true
+		log.Warnf("parent %v marked down, %v consecutive requests or probes failed, last probe: %v\n", pa.url, pa.failures, result)
+	}
+}
@@ -0,0 +1,119 @@
+package health
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPassive(t *testing.T) {
+	p := New()
+	check, err := Check{Failures: 2, RecoverSeconds: 1}.WithDefaults()
+	if err != nil {
+		t.Fatalf("check with defaults: %v", err)
+	}
+	p.Update(map[string]ParentCheck{"http://a": {Check: check}})
+
+	p.Report("http://a", false)
+	if !p.Healthy("http://a") {
+		t.Errorf("expected parent healthy after 1 of 2 failures")
+	}
+	p.Report("http://a", false)
+	if p.Healthy("http://a") {
+		t.Errorf("expected parent down after 2 consecutive failures")
+	}
+	if down := p.Down(); len(down) != 1 || down[0] != "http://a" {
+		t.Errorf("expected down parents [http://a], actual %v", down)
+	}
+	if !p.Healthy("http://untracked") {
+		t.Errorf("expected untracked parent healthy")
+	}
+
+	// the parent keeps its health across updates
+	p.Update(map[string]ParentCheck{"http://a": {Check: check}, "http://b": {Check: check}})
+	if p.Healthy("http://a") {
+		t.Errorf("expected parent still down after update")
+	}
+
+	p.parents["http://a"].downUntil = time.Now().Add(-time.Millisecond) // recover_seconds passed
+	if !p.Healthy("http://a") {
+		t.Errorf("expected parent to be tried again after recover_seconds")
+	}
+	p.Report("http://a", false)
+	if p.Healthy("http://a") {
+		t.Errorf("expected parent down again after failing when tried again")
+	}
+	p.Report("http://a", true)
+	if !p.Healthy("http://a") {
+		t.Errorf("expected parent up after a success")
+	}
+
+	p.Update(map[string]ParentCheck{"http://b": {Check: check}})
+	if _, ok := p.parents["http://a"]; ok {
+		t.Errorf("expected removed parent to not be tracked")
+	}
+}
+
+func TestProbe(t *testing.T) {
+	up := int32(0)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" || atomic.LoadInt32(&up) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	p := New()
+	check, err := Check{Failures: 1, ProbePath: "/health", ProbeIntervalMS: 10, Successes: 2}.WithDefaults()
+	if err != nil {
+		t.Fatalf("check with defaults: %v", err)
+	}
+	p.Update(map[string]ParentCheck{srv.URL: {Check: check, Transport: &http.Transport{}}})
+	defer p.Update(nil) // stop probing
+
+	waitFor := func(healthy bool) bool {
+		for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+			if p.Healthy(srv.URL) == healthy {
+				return true
+			}
+		}
+		return false
+	}
+	if !waitFor(false) {
+		t.Fatalf("expected probed parent down after failed probe")
+	}
+	atomic.StoreInt32(&up, 1)
+	if !waitFor(true) {
+		t.Errorf("expected probed parent up after successful probes")
+	}
+}
+
+func TestCheckWithDefaults(t *testing.T) {
+	check, err := Check{Failures: 5}.WithDefaults()
+	if err != nil {
+		t.Fatalf("expected no error, actual %v", err)
+	}
+	expected := Check{Failures: 5, RecoverSeconds: DefaultRecoverSeconds, ProbeIntervalMS: DefaultProbeIntervalMS, ProbeTimeoutMS: DefaultProbeTimeoutMS, Successes: DefaultSuccesses}
+	if check != expected {
+		t.Errorf("expected %+v, actual %+v", expected, check)
+	}
+	if _, err := (Check{RecoverSeconds: -1}).WithDefaults(); err == nil {
+		t.Errorf("expected error for negative recover_seconds, actual nil")
+	}
+}
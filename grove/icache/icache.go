@@ -27,5 +27,20 @@ type Cache interface {
 	Peek(key string) (*cacheobj.CacheObj, bool)
 	Keys() []string
 	Size() uint64
+	// Remove removes the object with the given key, and any chunks of its body.
+	Remove(key string)
 	Close()
 }
+
+// ChunkCache is a Cache which can also store the bodies of objects in separate chunks, so large objects can be stored and served as they're received, without holding the entire body in memory.
+//
+// The chunks of an object must be added in order, starting from 0, under a new ID from cacheobj.NewChunkID, and then the object itself added via Add, with Chunked, ChunkCount, and ChunkID set. Objects whose chunks weren't all stored are not added.
+//
+// The chunks of each ID are separate, so storing the chunks of a new object doesn't change the body of the object already stored under the key, which may still be being read. Adding an object removes the chunks of every other ID under its key, and removing or evicting an object removes all the chunks under its key.
+type ChunkCache interface {
+	Cache
+	// AddChunk adds the chunk with the given index, of the body of the object with the given key and chunk ID. Returns whether the chunk was stored.
+	AddChunk(key string, id uint64, idx int, chunk []byte) bool
+	// GetChunk returns the chunk with the given index, of the body of the object with the given key and chunk ID, and whether it exists.
+	GetChunk(key string, id uint64, idx int) ([]byte, bool)
+}
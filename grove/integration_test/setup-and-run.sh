@@ -95,4 +95,10 @@ cp $GOPATH/src/github.com/apache/trafficcontrol/grove/integration_test/tests/plu
 pkill -HUP grove
 bash $GOPATH/src/github.com/apache/trafficcontrol/grove/integration_test/tests/plugins/range_req_handler/test.sh
 
+cp $GOPATH/src/github.com/apache/trafficcontrol/grove/integration_test/tests/vary/deflate.conf /etc/httpd/conf.d/
+httpd -k graceful
+cp $GOPATH/src/github.com/apache/trafficcontrol/grove/integration_test/tests/vary/remap.json /remap.json
+pkill -HUP grove
+bash $GOPATH/src/github.com/apache/trafficcontrol/grove/integration_test/tests/vary/test.sh
+
 
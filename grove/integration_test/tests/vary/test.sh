@@ -0,0 +1,41 @@
+#!/usr/bin/env bash -x
+
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+# http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+CMP_TOOL="${CMP_TOOL:-/compare_gets}"
+
+# The origin must be configured with deflate.conf, so responses have 'Vary: Accept-Encoding'.
+
+result=0
+testno=0
+
+# Each variant is requested after the others are cached, so serving the wrong variant fails the compare.
+# Equivalent Accept-Encoding values must be served the same variant as each other.
+for host in "mem-test.cdn.kabletown.net" "disk-test.cdn.kabletown.net" "max-variants-test.cdn.kabletown.net" "stream-test.cdn.kabletown.net"
+do
+  for ae in "gzip" "identity" "gzip,deflate" "deflate,gzip" "identity" "gzip;q=1.0" "GZIP" "identity"
+  do
+    test="${CMP_TOOL}  --chdrs \"Host:$host Accept-Encoding:${ae}\" --ohdrs \"Accept-Encoding:${ae}\" --path \"10Mb.txt\" --ignorehdrs \"Server,Date\""
+    testno=$(($testno+1))
+    echo -n "Test $testno ($test): "
+
+    ${CMP_TOOL}  --chdrs "Host:$host Accept-Encoding:${ae}" --ohdrs "Accept-Encoding:${ae}" --path "10Mb.txt" --ignorehdrs "Server,Date"
+
+    result=$(($result+$?))
+  done
+done
+
+echo "vary: $testno tests done, $result failed."
+
+exit $result
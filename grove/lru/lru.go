@@ -48,6 +48,43 @@ func (c *LRU) Add(key string, size uint64) uint64 {
 	return 0
 }
 
+// Grow adds size to the size of the key in the LRU, adding the key if it doesn't exist, and moves it to the front. Returns the new size.
+func (c *LRU) Grow(key string, size uint64) uint64 {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if elem, ok := c.lElems[key]; ok {
+		c.l.MoveToFront(elem)
+		elem.Value.(*listObj).size += size
+		return elem.Value.(*listObj).size
+	}
+	c.lElems[key] = c.l.PushFront(&listObj{key, size})
+	return size
+}
+
+// Touch moves the key to the front of the LRU, without changing its size. Returns whether the key existed.
+func (c *LRU) Touch(key string) bool {
+	c.m.Lock()
+	defer c.m.Unlock()
+	elem, ok := c.lElems[key]
+	if ok {
+		c.l.MoveToFront(elem)
+	}
+	return ok
+}
+
+// Remove removes the key from the LRU. Returns the size of the removed key, and whether it existed.
+func (c *LRU) Remove(key string) (uint64, bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	elem, ok := c.lElems[key]
+	if !ok {
+		return 0, false
+	}
+	c.l.Remove(elem)
+	delete(c.lElems, key)
+	return elem.Value.(*listObj).size, true
+}
+
 // RemoveOldest returns the key, size, and true if the LRU is nonempty; else false.
 func (c *LRU) RemoveOldest() (string, uint64, bool) {
 	c.m.Lock()
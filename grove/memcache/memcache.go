@@ -26,11 +26,12 @@ import (
 
 // MemCache is a threadsafe memory cache with a soft byte limit, enforced via LRU.
 type MemCache struct {
-	lru          *lru.LRU                      // threadsafe.
-	cache        map[string]*cacheobj.CacheObj // mutexed: MUST NOT access without locking cacheM. TODO test performance of sync.Map
-	cacheM       sync.RWMutex                  // TODO test performance of one mutex for lru+cache
-	sizeBytes    uint64                        // atomic: MUST NOT access without sync.atomic
-	maxSizeBytes uint64                        // constant: MUST NOT be modified after creation
+	lru          *lru.LRU                       // threadsafe.
+	cache        map[string]*cacheobj.CacheObj  // mutexed: MUST NOT access without locking cacheM. TODO test performance of sync.Map
+	chunks       map[string]map[uint64][][]byte // mutexed: MUST NOT access without locking cacheM. The body chunks of Chunked objects, by key and chunk ID, whose size is included in the key's LRU size.
+	cacheM       sync.RWMutex                   // TODO test performance of one mutex for lru+cache
+	sizeBytes    uint64                         // atomic: MUST NOT access without sync.atomic
+	maxSizeBytes uint64                         // constant: MUST NOT be modified after creation
 	gcChan       chan<- uint64
 }
 
@@ -40,6 +41,7 @@ func New(bytes uint64) *MemCache {
 	c := &MemCache{
 		lru:          lru.NewLRU(),
 		cache:        map[string]*cacheobj.CacheObj{},
+		chunks:       map[string]map[uint64][][]byte{},
 		maxSizeBytes: bytes,
 		gcChan:       gcChan,
 	}
@@ -51,7 +53,7 @@ func (c *MemCache) Get(key string) (*cacheobj.CacheObj, bool) {
 	c.cacheM.RLock()
 	obj, ok := c.cache[key]
 	if ok {
-		c.lru.Add(key, obj.Size) // TODO directly call c.ll.MoveToFront
+		c.lru.Touch(key) // not Add, because the key's size includes the chunks of any new object being stored
 		atomic.AddUint64(&obj.HitCount, 1)
 	}
 	c.cacheM.RUnlock()
@@ -67,6 +69,16 @@ func (c *MemCache) Peek(key string) (*cacheobj.CacheObj, bool) {
 
 func (c *MemCache) Add(key string, val *cacheobj.CacheObj) bool {
 	c.cacheM.Lock()
+	// the chunks of other objects under the key are removed, and their sizes replaced in the LRU below
+	if !val.Chunked || val.ChunkCount == 0 {
+		delete(c.chunks, key)
+	} else if chunks := c.chunks[key][val.ChunkID]; len(chunks) != val.ChunkCount {
+		c.cacheM.Unlock()
+		log.Errorf("MemCache.Add '%v' has %v chunks, but %v were stored, not adding\n", key, val.ChunkCount, len(chunks))
+		return false
+	} else {
+		c.chunks[key] = map[uint64][][]byte{val.ChunkID: chunks}
+	}
 	c.cache[key] = val
 	c.cacheM.Unlock()
 	oldSize := c.lru.Add(key, val.Size)
@@ -82,6 +94,48 @@ func (c *MemCache) Add(key string, val *cacheobj.CacheObj) bool {
 	return false // TODO remove eviction from interface; it's unnecessary and expensive
 }
 
+// AddChunk adds the chunk of the body of the given key. See icache.ChunkCache.
+func (c *MemCache) AddChunk(key string, id uint64, idx int, chunk []byte) bool {
+	c.cacheM.Lock()
+	if len(c.chunks[key][id]) != idx {
+		c.cacheM.Unlock()
+		return false // previous chunks were removed or evicted
+	}
+	if c.chunks[key] == nil {
+		c.chunks[key] = map[uint64][][]byte{}
+	}
+	c.chunks[key][id] = append(c.chunks[key][id], chunk)
+	c.cacheM.Unlock()
+
+	c.lru.Grow(key, uint64(len(chunk)))
+	if newSizeBytes := atomic.AddUint64(&c.sizeBytes, uint64(len(chunk))); newSizeBytes > c.maxSizeBytes {
+		c.doGC(newSizeBytes)
+	}
+	return true
+}
+
+// GetChunk returns the chunk of the body of the given key. See icache.ChunkCache.
+func (c *MemCache) GetChunk(key string, id uint64, idx int) ([]byte, bool) {
+	c.cacheM.RLock()
+	defer c.cacheM.RUnlock()
+	chunks := c.chunks[key][id]
+	if idx < 0 || idx >= len(chunks) {
+		return nil, false
+	}
+	return chunks[idx], true
+}
+
+// Remove removes the object with the given key, and any chunks of its body.
+func (c *MemCache) Remove(key string) {
+	c.cacheM.Lock()
+	delete(c.cache, key)
+	delete(c.chunks, key)
+	c.cacheM.Unlock()
+	if sizeBytes, ok := c.lru.Remove(key); ok {
+		atomic.AddUint64(&c.sizeBytes, ^uint64(sizeBytes-1)) // subtract sizeBytes
+	}
+}
+
 func (c *MemCache) Size() uint64 { return atomic.LoadUint64(&c.sizeBytes) }
 func (c *MemCache) Close()       {}
 
@@ -115,6 +169,7 @@ func (c *MemCache) gc(cacheSizeBytes uint64) {
 		log.Debugf("MemCache.gc deleting key '" + key + "'")
 		c.cacheM.Lock()
 		delete(c.cache, key)
+		delete(c.chunks, key)
 		c.cacheM.Unlock()
 
 		cacheSizeBytes = atomic.AddUint64(&c.sizeBytes, ^uint64(sizeBytes-1)) // subtract sizeBytes
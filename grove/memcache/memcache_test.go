@@ -0,0 +1,113 @@
+package memcache
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/grove/cacheobj"
+	"github.com/apache/trafficcontrol/v8/grove/lru"
+)
+
+// addChunked adds an object with the given chunks under the given key, and returns it.
+func addChunked(t *testing.T, c *MemCache, key string, chunks ...string) *cacheobj.CacheObj {
+	now := time.Now()
+	obj := cacheobj.New(http.Header{}, nil, http.StatusOK, http.StatusOK, "", http.Header{}, now, now, now, now)
+	obj.Chunked = true
+	obj.ChunkID = cacheobj.NewChunkID()
+	for i, chunk := range chunks {
+		if !c.AddChunk(key, obj.ChunkID, i, []byte(chunk)) {
+			t.Fatalf("AddChunk '%v' chunk %v expected stored, actual not stored", key, i)
+		}
+		obj.ChunkCount++
+		obj.Size += uint64(len(chunk))
+	}
+	c.Add(key, obj)
+	return obj
+}
+
+func TestGCRemovesChunks(t *testing.T) {
+	// no gcManager is started, so gc can be called synchronously
+	c := &MemCache{lru: lru.NewLRU(), cache: map[string]*cacheobj.CacheObj{}, chunks: map[string]map[uint64][][]byte{}, maxSizeBytes: 100, gcChan: make(chan uint64, 10)}
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		addChunked(t, c, key, "0123456789", "0123456789", "0123456789")
+	}
+	if n := len(c.chunks); n != 5 {
+		t.Fatalf("chunks before gc expected 5 keys, actual %v", n)
+	}
+
+	c.gc(c.Size())
+	if size := c.Size(); size > 100 {
+		t.Errorf("Size after gc expected at most 100, actual %v", size)
+	}
+	if n := len(c.chunks); n != 3 {
+		t.Errorf("chunks after gc expected 3 keys, actual %v", n)
+	}
+	for _, key := range []string{"a", "b"} {
+		if _, ok := c.chunks[key]; ok {
+			t.Errorf("expected chunks of evicted key %v to be removed, actual kept", key)
+		}
+		if _, ok := c.GetChunk(key, 0, 0); ok {
+			t.Errorf("GetChunk of evicted key %v expected not ok, actual ok", key)
+		}
+	}
+}
+
+func TestChunksOfNewObject(t *testing.T) {
+	c := New(1024 * 1024)
+	old := addChunked(t, c, "foo", "old0", "old1")
+
+	id := cacheobj.NewChunkID()
+	if !c.AddChunk("foo", id, 0, []byte("new0")) {
+		t.Fatalf("AddChunk of new object expected stored, actual not stored")
+	}
+	if chunk, ok := c.GetChunk("foo", old.ChunkID, 0); !ok || string(chunk) != "old0" {
+		t.Errorf("GetChunk of stored object while new object's chunks are added expected 'old0', actual '%s' %v", chunk, ok)
+	}
+	if obj, ok := c.Get("foo"); !ok || obj != old {
+		t.Errorf("Get while new object's chunks are added expected stored object, actual %v %v", obj, ok)
+	}
+	if c.AddChunk("foo", id, 0, []byte("new0")) {
+		t.Errorf("AddChunk of a chunk ID's existing chunk expected not stored, actual stored")
+	}
+
+	c.AddChunk("foo", id, 1, []byte("new1!"))
+	obj := *old
+	obj.ChunkID = id
+	obj.Size = uint64(len("new0") + len("new1!"))
+	c.Add("foo", &obj)
+	if _, ok := c.GetChunk("foo", old.ChunkID, 0); ok {
+		t.Errorf("GetChunk of replaced object expected not ok, actual ok")
+	}
+	if chunk, ok := c.GetChunk("foo", id, 1); !ok || string(chunk) != "new1!" {
+		t.Errorf("GetChunk of new object expected 'new1!', actual '%s' %v", chunk, ok)
+	}
+	if c.AddChunk("foo", old.ChunkID, 2, []byte("old2")) {
+		t.Errorf("AddChunk of replaced object expected not stored, actual stored")
+	}
+	if size := c.Size(); size != obj.Size {
+		t.Errorf("Size after replacing expected %v, actual %v", obj.Size, size)
+	}
+
+	c.Remove("foo")
+	if n := len(c.chunks); n != 0 {
+		t.Errorf("chunks after Remove expected 0 keys, actual %v", n)
+	}
+	if size := c.Size(); size != 0 {
+		t.Errorf("Size after Remove expected 0, actual %v", size)
+	}
+}
@@ -0,0 +1,173 @@
+package peer
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// peer exists to make the Grove caches of a cachegroup one logical cache, by consistent-hashing each cache key to an owning peer, which is requested before the parent.
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/apache/trafficcontrol/v8/grove/chash"
+	"github.com/apache/trafficcontrol/v8/grove/config"
+	"github.com/apache/trafficcontrol/v8/grove/health"
+	"github.com/apache/trafficcontrol/v8/grove/web"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+)
+
+// Header is the header of requests from a peer. Its value is the scheme of the client request the peer received, so the request is remapped by the same rule, though peer requests are always HTTP. Requests with it are never sent to another peer, so peers with different configs don't loop.
+const Header = "X-Grove-Peer"
+
+// RetryCodes are the codes of peer responses which are failures, after which the parent is requested. Peers have already retried their parents per the remap rule, so a rule's retry codes returned by a peer aren't retried.
+var RetryCodes = map[int]struct{}{
+	http.StatusBadGateway:         {},
+	http.StatusServiceUnavailable: {},
+	http.StatusGatewayTimeout:     {},
+}
+
+// Peers is the peers of this cache, and their health. It's threadsafe, and shared by all remap rules, including after config reloads, so peers marked down stay down.
+type Peers struct {
+	self      string // the URL of this cache
+	hash      chash.ATSConsistentHash
+	addrs     map[string]struct{} // the IPs of the peers, from which the Header is trusted
+	transport *http.Transport
+	health    *health.Parents
+	m         sync.RWMutex
+}
+
+// New returns Peers with no peers, which requests peers with the given transport. Peers must be set with Update.
+func New(transport *http.Transport) *Peers {
+	return &Peers{transport: transport, health: health.New()}
+}
+
+// URL returns the URL of the peer with the given host.
+func URL(host string) string {
+	return "http://" + host
+}
+
+// Update sets the peers from the given config. If the config is invalid, the existing peers are kept and an error is returned. Peers which were already tracked keep their health.
+func (p *Peers) Update(peers []config.Peer, self string, check *health.Check) error {
+	if len(peers) == 0 {
+		p.m.Lock()
+		p.self, p.hash, p.addrs = "", nil, nil
+		p.m.Unlock()
+		p.health.Update(nil)
+		return nil
+	}
+
+	peerCheck := health.Check{}
+	if check != nil {
+		peerCheck = *check
+	}
+	peerCheck, err := peerCheck.WithDefaults()
+	if err != nil {
+		return errors.New("peer_health_check: " + err.Error())
+	}
+
+	hash := chash.NewSimpleATSConsistentHash(chash.DefaultSimpleATSConsistentHashReplicas)
+	addrs := map[string]struct{}{}
+	checks := map[string]health.ParentCheck{}
+	foundSelf := false
+	for _, peer := range peers {
+		hostname, _, err := net.SplitHostPort(peer.Host)
+		if err != nil {
+			return errors.New("peer '" + peer.Host + "' host must be a host and port: " + err.Error())
+		}
+		if _, ok := checks[URL(peer.Host)]; ok {
+			return errors.New("peer '" + peer.Host + "' is duplicated")
+		}
+		weight := peer.Weight
+		if weight == 0 {
+			weight = 1
+		} else if weight < 0 {
+			return errors.New("peer '" + peer.Host + "' weight must not be negative")
+		}
+		if err := hash.Insert(&chash.ATSConsistentHashNode{Name: URL(peer.Host)}, weight); err != nil {
+			return errors.New("peer '" + peer.Host + "' hashing: " + err.Error())
+		}
+		if peer.Host == self {
+			foundSelf = true
+			continue // this cache is never requested, so it isn't tracked
+		}
+		checks[URL(peer.Host)] = health.ParentCheck{Check: peerCheck, Transport: p.transport}
+		ips, err := net.LookupHost(hostname)
+		if err != nil {
+			log.Warnf("peer '%v' lookup failed, requests from it won't be trusted: %v\n", peer.Host, err)
+			continue
+		}
+		for _, ip := range ips {
+			addrs[net.ParseIP(ip).String()] = struct{}{}
+		}
+	}
+	if !foundSelf {
+		return errors.New("peer_self '" + self + "' isn't in peers")
+	}
+
+	p.m.Lock()
+	p.self, p.hash, p.addrs = URL(self), hash, addrs
+	p.m.Unlock()
+	p.health.Update(checks)
+	return nil
+}
+
+// Owner returns the URL of the peer which owns the given cache key, and whether it should be requested. It shouldn't be requested if there are no peers, this cache is the owner, or the owner is marked down.
+func (p *Peers) Owner(cacheKey string) (string, bool) {
+	p.m.RLock()
+	self, hash := p.self, p.hash
+	p.m.RUnlock()
+	if hash == nil {
+		return "", false
+	}
+	iter, _, err := hash.Lookup(cacheKey)
+	if err != nil {
+		log.Errorf("peer lookup for '%v' failed, requesting parent: %v\n", cacheKey, err)
+		return "", false
+	}
+	owner := iter.Val().Name
+	if owner == self || !p.health.Healthy(owner) {
+		return "", false
+	}
+	return owner, true
+}
+
+// Report records the result of a request to the peer with the given URL, for its health.
+func (p *Peers) Report(url string, success bool) {
+	p.health.Report(url, success)
+}
+
+// Transport returns the transport to request peers with.
+func (p *Peers) Transport() *http.Transport {
+	return p.transport
+}
+
+// FromPeer returns the client scheme of the given request, if it's from a peer, and whether it is. Requests with the Header which aren't from the address of a peer return false, so clients can't use it to request HTTPS rules over HTTP.
+func (p *Peers) FromPeer(r *http.Request) (string, bool) {
+	scheme := strings.ToLower(r.Header.Get(Header))
+	if scheme != "http" && scheme != "https" {
+		return "", false
+	}
+	ip, err := web.GetIP(r)
+	if err != nil {
+		return "", false
+	}
+	p.m.RLock()
+	_, ok := p.addrs[ip.String()]
+	p.m.RUnlock()
+	return scheme, ok
+}
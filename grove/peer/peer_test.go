@@ -0,0 +1,136 @@
+package peer
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/grove/config"
+	"github.com/apache/trafficcontrol/v8/grove/health"
+)
+
+func testPeers() []config.Peer {
+	return []config.Peer{{Host: "127.0.0.1:1"}, {Host: "127.0.0.1:2"}, {Host: "127.0.0.1:3", Weight: 2}}
+}
+
+func TestOwner(t *testing.T) {
+	p := New(nil)
+	if err := p.Update(testPeers(), "127.0.0.1:1", nil); err != nil {
+		t.Fatalf("Update error expected nil, actual %v", err)
+	}
+
+	owned := map[string]int{}
+	selfOwned := 0
+	for i := 0; i < 1000; i++ {
+		key := "GET:http://example.net/" + strconv.Itoa(i)
+		owner, ok := p.Owner(key)
+		if !ok {
+			selfOwned++
+			continue
+		}
+		if owner == URL("127.0.0.1:1") {
+			t.Fatalf("Owner expected never to return self, actual %v", owner)
+		}
+		if again, _ := p.Owner(key); again != owner {
+			t.Errorf("Owner expected to be stable, actual %v then %v", owner, again)
+		}
+		owned[owner]++
+	}
+	if selfOwned == 0 || owned[URL("127.0.0.1:2")] == 0 || owned[URL("127.0.0.1:3")] == 0 {
+		t.Fatalf("Owner expected keys hashed to all peers, actual self %v others %+v", selfOwned, owned)
+	}
+	if owned[URL("127.0.0.1:3")] < owned[URL("127.0.0.1:2")] {
+		t.Errorf("Owner expected the peer with weight 2 to own more keys, actual %+v", owned)
+	}
+
+	for i := 0; i < health.DefaultFailures; i++ {
+		p.Report(URL("127.0.0.1:2"), false)
+	}
+	for i := 0; i < 1000; i++ {
+		if owner, ok := p.Owner("GET:http://example.net/" + strconv.Itoa(i)); ok && owner == URL("127.0.0.1:2") {
+			t.Fatalf("Owner expected to skip a peer marked down, actual %v", owner)
+		}
+	}
+
+	if err := p.Update(nil, "", nil); err != nil {
+		t.Fatalf("Update with no peers error expected nil, actual %v", err)
+	}
+	if owner, ok := p.Owner("GET:http://example.net/0"); ok {
+		t.Errorf("Owner with no peers expected false, actual %v", owner)
+	}
+}
+
+func TestUpdateInvalidKeepsPeers(t *testing.T) {
+	p := New(nil)
+	if err := p.Update(testPeers(), "127.0.0.1:1", nil); err != nil {
+		t.Fatalf("Update error expected nil, actual %v", err)
+	}
+
+	invalid := map[string]func() error{
+		"missing self": func() error { return p.Update(testPeers(), "127.0.0.1:4", nil) },
+		"no port":      func() error { return p.Update([]config.Peer{{Host: "127.0.0.1"}}, "127.0.0.1", nil) },
+		"duplicate": func() error {
+			return p.Update(append(testPeers(), config.Peer{Host: "127.0.0.1:2"}), "127.0.0.1:1", nil)
+		},
+		"weight": func() error { return p.Update([]config.Peer{{Host: "127.0.0.1:1", Weight: -1}}, "127.0.0.1:1", nil) },
+		"check": func() error {
+			return p.Update(testPeers(), "127.0.0.1:1", &health.Check{Failures: -1})
+		},
+	}
+	for name, update := range invalid {
+		if err := update(); err == nil {
+			t.Errorf("Update with %v expected error, actual nil", name)
+		}
+	}
+
+	found := false
+	for i := 0; i < 100 && !found; i++ {
+		_, found = p.Owner("GET:http://example.net/" + strconv.Itoa(i))
+	}
+	if !found {
+		t.Errorf("Update with invalid peers expected to keep the existing peers, actual no owners")
+	}
+}
+
+func TestFromPeer(t *testing.T) {
+	p := New(nil)
+	if err := p.Update(testPeers(), "127.0.0.1:1", nil); err != nil {
+		t.Fatalf("Update error expected nil, actual %v", err)
+	}
+
+	r := httptest.NewRequest("GET", "http://example.net/", nil)
+	r.RemoteAddr = "127.0.0.1:12345"
+	if _, ok := p.FromPeer(r); ok {
+		t.Errorf("FromPeer without header expected false, actual true")
+	}
+
+	r.Header.Set(Header, "https")
+	if scheme, ok := p.FromPeer(r); !ok || scheme != "https" {
+		t.Errorf("FromPeer expected https true, actual %v %v", scheme, ok)
+	}
+
+	r.Header.Set(Header, "ftp")
+	if _, ok := p.FromPeer(r); ok {
+		t.Errorf("FromPeer with invalid scheme expected false, actual true")
+	}
+
+	r.Header.Set(Header, "https")
+	r.RemoteAddr = "192.0.2.1:12345"
+	if _, ok := p.FromPeer(r); ok {
+		t.Errorf("FromPeer from an address which isn't a peer expected false, actual true")
+	}
+}
@@ -189,10 +189,8 @@ func cacheinspect(icfg interface{}, d OnRequestData) bool {
 	}
 
 	writeHTMLPageFooter(w)
-	clientIP, _ := web.GetClientIPPort(req)
-	now := time.Now()
 	// TODO add eventId?
-	log.EventRaw(atsEventLogStr(now, clientIP, d.Hostname, req.Host, d.Port, "-", d.Scheme, req.URL.String(), req.Method, req.Proto, respCode, now.Sub(reqTime), 0, 0, 0, true, true, getCacheHitStr(true, false), "-", "-", req.UserAgent(), req.Header.Get("X-Money-Trace"), 1))
+	atsLogEndpoint(d, respCode, reqTime, 1)
 
 	return true
 }
@@ -64,11 +64,8 @@ func callgc(icfg interface{}, d OnRequestData) bool {
 	respCode := http.StatusNoContent
 	w.WriteHeader(respCode)
 
-	clientIP, _ := web.GetClientIPPort(req)
-
-	now := time.Now()
 	// log, so we know if someone is hitting this endpoint when they shouldn't be. GC is expensive, this could become an accidental DDOS.
-	log.EventRaw(atsEventLogStr(now, clientIP, d.Hostname, req.Host, d.Port, "-", d.Scheme, req.URL.String(), req.Method, req.Proto, respCode, now.Sub(reqTime), 0, 0, 0, true, true, getCacheHitStr(true, false), "-", "-", req.UserAgent(), req.Header.Get("X-Money-Trace"), d.RequestID))
+	atsLogEndpoint(d, respCode, reqTime, d.RequestID)
 
 	return true
 }
@@ -103,7 +103,7 @@ func LoadSystemStats(stats stat.Stats, interfaceName string) stat.StatsSystemJSO
 func LoadRemapStats(stats stat.Stats, httpConns *web.ConnMap, httpsConns *web.ConnMap) map[string]interface{} {
 	statsRemaps := stats.Remap()
 	rules := statsRemaps.Rules()
-	jsonStats := make(map[string]interface{}, len(rules)*8) // remap has 8 members: in, out, 2xx, 3xx, 4xx, 5xx, hits, misses
+	jsonStats := make(map[string]interface{}, len(rules)*9) // remap has 9 members: in, out, 2xx, 3xx, 4xx, 5xx, hits, misses, rate limited, plus rate limited by each limit
 	jsonStats["server"] = "6.2.1"                           // emulate a good ATS version
 	for _, rule := range rules {
 		ruleName := rule
@@ -119,11 +119,16 @@ func LoadRemapStats(stats stat.Stats, httpConns *web.ConnMap, httpsConns *web.Co
 		jsonStats["plugin.remap_stats."+ruleName+".status_5xx"] = statsRemap.Status5xx()
 		jsonStats["plugin.remap_stats."+ruleName+".cache_hits"] = statsRemap.CacheHits()
 		jsonStats["plugin.remap_stats."+ruleName+".cache_misses"] = statsRemap.CacheMisses()
+		jsonStats["plugin.remap_stats."+ruleName+".rate_limited"] = statsRemap.RateLimited()
+		for limit, count := range statsRemap.RateLimitedByLimit() {
+			jsonStats["plugin.remap_stats."+ruleName+".rate_limited."+limit] = count
+		}
 	}
 
 	jsonStats["proxy.process.http.current_client_connections"] = httpConns.Len() + httpsConns.Len()
 	jsonStats["proxy.process.http.cache_hits"] = stats.CacheHits()
 	jsonStats["proxy.process.http.cache_misses"] = stats.CacheMisses()
+	jsonStats["proxy.process.http.rate_limited"] = stats.RateLimited()
 	jsonStats["proxy.process.http.cache_capacity_bytes"] = stats.CacheCapacity()
 	jsonStats["proxy.process.http.cache_size_bytes"] = stats.CacheSize()
 
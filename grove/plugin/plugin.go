@@ -90,6 +90,8 @@ type BeforeRespondData struct {
 	Body      *[]byte
 	RemapRule string
 	Context   *interface{}
+	// Stream is whether the response body is being streamed from the parent or the cache, in which case Body is empty. A plugin may still set Body or change Code, which replaces the streamed body.
+	Stream bool
 }
 
 type BeforeCacheLookUpData struct {
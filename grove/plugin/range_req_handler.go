@@ -149,6 +149,10 @@ func rangeReqHandleBeforeRespond(icfg interface{}, d BeforeRespondData) {
 	if cfg.Mode == "store_ranges" {
 		return // no need to do anything here.
 	}
+	if d.Stream {
+		log.Debugf("range_req_handler: streamed response, serving the entire object\n")
+		return // the body isn't in memory, so ranges can't be served from it
+	}
 
 	// mode != store_ranges
 	multipartBoundaryString := cfg.MultiPartBoundary
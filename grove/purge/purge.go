@@ -0,0 +1,167 @@
+package purge
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// purge exists to invalidate cached objects matching regular expressions, with the semantics of Traffic Ops invalidation jobs.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Type is the type of an invalidation, which determines what's done with cached objects it matches.
+type Type string
+
+const (
+	// TypeRefresh makes matching objects stale, so they're revalidated with the parent, and only fetched again if they changed.
+	TypeRefresh Type = "refresh"
+	// TypeRefetch makes matching objects cache misses, so they're always fetched again from the parent.
+	TypeRefetch Type = "refetch"
+)
+
+// Rule is a regex invalidation. Objects cached before Start whose URL matches Regex are invalidated, from Start until End.
+type Rule struct {
+	Regex string    `json:"regex"`
+	Type  Type      `json:"type"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// RulesJSON is the format of the purge rules file.
+type RulesJSON struct {
+	Rules []Rule `json:"rules"`
+}
+
+type compiledRule struct {
+	Rule
+	regex *regexp.Regexp
+}
+
+// Rules is a threadsafe set of regex invalidation rules, from a rules file and from individually added rules. Loading the file replaces the rules from the previous file, but not the added rules.
+type Rules struct {
+	file  []compiledRule
+	added []compiledRule
+	m     sync.RWMutex
+}
+
+func New() *Rules {
+	return &Rules{}
+}
+
+func compile(rule Rule) (compiledRule, error) {
+	if rule.Type != TypeRefresh && rule.Type != TypeRefetch {
+		return compiledRule{}, fmt.Errorf("rule '%v' type must be '%v' or '%v', was '%v'", rule.Regex, TypeRefresh, TypeRefetch, rule.Type)
+	}
+	if rule.End.Before(rule.Start) {
+		return compiledRule{}, fmt.Errorf("rule '%v' end %v must not be before start %v", rule.Regex, rule.End, rule.Start)
+	}
+	regex, err := regexp.Compile(rule.Regex)
+	if err != nil {
+		return compiledRule{}, fmt.Errorf("rule '%v' regex: %v", rule.Regex, err)
+	}
+	return compiledRule{Rule: rule, regex: regex}, nil
+}
+
+// LoadFile replaces the rules from the previous file with the rules in the given file. If the file name is empty or doesn't exist, the file rules are removed. If the file is invalid, the existing rules are kept, and an error is returned.
+func (r *Rules) LoadFile(fileName string) error {
+	rulesJSON := RulesJSON{}
+	if fileName != "" {
+		bts, err := ioutil.ReadFile(fileName)
+		if err != nil && !os.IsNotExist(err) {
+			return errors.New("reading file: " + err.Error())
+		}
+		if err == nil {
+			if err := json.Unmarshal(bts, &rulesJSON); err != nil {
+				return errors.New("unmarshalling JSON: " + err.Error())
+			}
+		}
+	}
+
+	rules := make([]compiledRule, 0, len(rulesJSON.Rules))
+	for _, rule := range rulesJSON.Rules {
+		compiled, err := compile(rule)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, compiled)
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.file = rules
+	return nil
+}
+
+// Add adds the given rule. Added rules are kept until they end, irrespective of loading rules files.
+func (r *Rules) Add(rule Rule) error {
+	compiled, err := compile(rule)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	r.m.Lock()
+	defer r.m.Unlock()
+	added := []compiledRule{compiled}
+	for _, old := range r.added {
+		if old.End.After(now) {
+			added = append(added, old)
+		}
+	}
+	r.added = added
+	return nil
+}
+
+// Get returns all current rules, from the file and added.
+func (r *Rules) Get() []Rule {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	rules := make([]Rule, 0, len(r.file)+len(r.added))
+	for _, rule := range r.file {
+		rules = append(rules, rule.Rule)
+	}
+	for _, rule := range r.added {
+		rules = append(rules, rule.Rule)
+	}
+	return rules
+}
+
+// Check returns whether an object with the given URL, cached at the given time, is invalidated by any rule in effect now, and the type of invalidation. If both refresh and refetch rules match, refetch is returned.
+func (r *Rules) Check(url string, cachedTime time.Time) (Type, bool) {
+	now := time.Now()
+	r.m.RLock()
+	defer r.m.RUnlock()
+	matchType := Type("")
+	for _, rules := range [][]compiledRule{r.file, r.added} {
+		for _, rule := range rules {
+			if now.Before(rule.Start) || now.After(rule.End) || !cachedTime.Before(rule.Start) {
+				continue
+			}
+			if !rule.regex.MatchString(url) {
+				continue
+			}
+			if rule.Type == TypeRefetch {
+				return TypeRefetch, true
+			}
+			matchType = TypeRefresh
+		}
+	}
+	return matchType, matchType != ""
+}
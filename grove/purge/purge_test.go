@@ -0,0 +1,112 @@
+package purge
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRulesCheck(t *testing.T) {
+	now := time.Now()
+	rules := New()
+	if err := rules.Add(Rule{Regex: `http://origin\.example/images/.*\.png`, Type: TypeRefresh, Start: now.Add(-time.Hour), End: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("Add refresh rule: expected no error, actual: %v", err)
+	}
+	if err := rules.Add(Rule{Regex: `http://origin\.example/images/logo\.png`, Type: TypeRefetch, Start: now.Add(-time.Hour), End: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("Add refetch rule: expected no error, actual: %v", err)
+	}
+	if err := rules.Add(Rule{Regex: `http://origin\.example/future/`, Type: TypeRefetch, Start: now.Add(time.Hour), End: now.Add(2 * time.Hour)}); err != nil {
+		t.Fatalf("Add future rule: expected no error, actual: %v", err)
+	}
+
+	type testCase struct {
+		url          string
+		cachedTime   time.Time
+		expectedType Type
+		expectedOK   bool
+	}
+	testCases := []testCase{
+		{url: "http://origin.example/images/a.png", cachedTime: now.Add(-2 * time.Hour), expectedType: TypeRefresh, expectedOK: true},
+		{url: "http://origin.example/images/a.png", cachedTime: now, expectedType: "", expectedOK: false},
+		{url: "http://origin.example/images/logo.png", cachedTime: now.Add(-2 * time.Hour), expectedType: TypeRefetch, expectedOK: true},
+		{url: "http://origin.example/images/a.jpg", cachedTime: now.Add(-2 * time.Hour), expectedType: "", expectedOK: false},
+		{url: "http://origin.example/future/a.png", cachedTime: now.Add(-2 * time.Hour), expectedType: "", expectedOK: false},
+	}
+	for _, tc := range testCases {
+		actualType, actualOK := rules.Check(tc.url, tc.cachedTime)
+		if actualType != tc.expectedType || actualOK != tc.expectedOK {
+			t.Errorf("Check(%v, %v) expected %v %v, actual %v %v", tc.url, tc.cachedTime, tc.expectedType, tc.expectedOK, actualType, actualOK)
+		}
+	}
+}
+
+func TestRulesInvalid(t *testing.T) {
+	now := time.Now()
+	rules := New()
+	if err := rules.Add(Rule{Regex: `(`, Type: TypeRefresh, Start: now, End: now.Add(time.Hour)}); err == nil {
+		t.Errorf("Add invalid regex: expected error, actual nil")
+	}
+	if err := rules.Add(Rule{Regex: `foo`, Type: "purge", Start: now, End: now.Add(time.Hour)}); err == nil {
+		t.Errorf("Add invalid type: expected error, actual nil")
+	}
+	if err := rules.Add(Rule{Regex: `foo`, Type: TypeRefresh, Start: now, End: now.Add(-time.Hour)}); err == nil {
+		t.Errorf("Add end before start: expected error, actual nil")
+	}
+	if len(rules.Get()) != 0 {
+		t.Errorf("Get after invalid rules: expected no rules, actual %+v", rules.Get())
+	}
+}
+
+func TestRulesLoadFile(t *testing.T) {
+	now := time.Now()
+	rules := New()
+	if err := rules.Add(Rule{Regex: `added`, Type: TypeRefresh, Start: now.Add(-time.Hour), End: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("Add: expected no error, actual: %v", err)
+	}
+
+	fileName := filepath.Join(t.TempDir(), "purge.json")
+	if err := ioutil.WriteFile(fileName, []byte(`{"rules":[{"regex":"file","type":"refetch","start":"2000-01-01T00:00:00Z","end":"2999-01-01T00:00:00Z"}]}`), 0644); err != nil {
+		t.Fatalf("writing rules file: %v", err)
+	}
+	if err := rules.LoadFile(fileName); err != nil {
+		t.Fatalf("LoadFile: expected no error, actual: %v", err)
+	}
+	if actual := len(rules.Get()); actual != 2 {
+		t.Errorf("Get after LoadFile: expected 2 rules, actual %v", actual)
+	}
+	if typ, ok := rules.Check("http://file/a", time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC)); !ok || typ != TypeRefetch {
+		t.Errorf("Check file rule: expected %v true, actual %v %v", TypeRefetch, typ, ok)
+	}
+
+	if err := ioutil.WriteFile(fileName, []byte(`{"rules":[{"regex":"(","type":"refetch"}]}`), 0644); err != nil {
+		t.Fatalf("writing rules file: %v", err)
+	}
+	if err := rules.LoadFile(fileName); err == nil {
+		t.Errorf("LoadFile invalid: expected error, actual nil")
+	}
+	if actual := len(rules.Get()); actual != 2 {
+		t.Errorf("Get after invalid LoadFile: expected existing 2 rules, actual %v", actual)
+	}
+
+	if err := rules.LoadFile(filepath.Join(t.TempDir(), "nonexistent.json")); err != nil {
+		t.Fatalf("LoadFile nonexistent: expected no error, actual: %v", err)
+	}
+	if actual := len(rules.Get()); actual != 1 {
+		t.Errorf("Get after LoadFile nonexistent: expected only added rule, actual %v", actual)
+	}
+}
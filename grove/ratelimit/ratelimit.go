@@ -0,0 +1,223 @@
+package ratelimit
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// ratelimit exists to limit the rate of client requests with token buckets, so abusive clients are rejected at the edge rather than reaching parents.
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	KeyClientIP = "client_ip"
+	KeyRule     = "rule"
+	KeyHeader   = "header"
+
+	DefaultResponseCode = http.StatusTooManyRequests
+
+	// DefaultMaxKeys is the maximum number of keys with buckets in each limiter, for limits which don't specify max_keys.
+	DefaultMaxKeys = 100000
+
+	// SweepInterval is how often each limiter deletes the buckets of keys which have refilled, so clients which stopped requesting don't use memory.
+	SweepInterval = time.Minute
+)
+
+// Limit is the configuration of a token bucket rate limit of a remap rule.
+type Limit struct {
+	// Name identifies the limit in stats, and across reloads. It must be unique within a rule. If empty, it defaults to the key, or to the header name for header keys.
+	Name string `json:"name"`
+	// Key is what requests are limited by: client_ip, limiting each client IP separately; rule, limiting all requests to the rule together; or header, limiting each value of Header separately.
+	Key string `json:"key"`
+	// Header is the request header whose values are limited, for the header key, e.g. an API key. Requests without the header aren't limited. Clients may send any value, so a header limit can be bypassed by varying it, unless the header is validated before Grove, e.g. by the client or an authenticating proxy.
+	Header string `json:"header"`
+	// Rate is the number of requests per second each key is allowed, on average.
+	Rate float64 `json:"rate"`
+	// Burst is the number of requests each key may make at once, after making none for Burst/Rate seconds. If 0, it defaults to Rate rounded up.
+	Burst int `json:"burst"`
+	// ResponseCode is the code of responses to limited requests. If 0, it defaults to 429 Too Many Requests.
+	ResponseCode int `json:"response_code"`
+	// RetryAfter is the Retry-After header in seconds of responses to limited requests. If nil, it's the time until the key is allowed another request, rounded up. If 0, the header isn't sent.
+	RetryAfter *int `json:"retry_after"`
+	// MaxKeys is the maximum number of keys with buckets, which bounds the memory of limits with many keys, e.g. clients sending many different header values. Past it, an arbitrary key's bucket is evicted for each new key, so the evicted key starts with a full bucket. If 0, it defaults to DefaultMaxKeys.
+	MaxKeys int `json:"max_keys"`
+}
+
+// WithDefaults returns the limit with the default of each unset field, or an error if the limit is invalid.
+func (l Limit) WithDefaults() (Limit, error) {
+	switch l.Key {
+	case KeyClientIP, KeyRule:
+		if l.Header != "" {
+			return Limit{}, errors.New("header must only be set for the " + KeyHeader + " key")
+		}
+		if l.Name == "" {
+			l.Name = l.Key
+		}
+	case KeyHeader:
+		if l.Header == "" {
+			return Limit{}, errors.New("header missing for the " + KeyHeader + " key")
+		}
+		l.Header = http.CanonicalHeaderKey(l.Header)
+		if l.Name == "" {
+			l.Name = l.Header
+		}
+	default:
+		return Limit{}, errors.New("key must be " + KeyClientIP + ", " + KeyRule + ", or " + KeyHeader + ", actual '" + l.Key + "'")
+	}
+	if !(l.Rate > 0) || math.IsInf(l.Rate, 0) {
+		return Limit{}, errors.New("rate must be positive")
+	}
+	if l.Burst < 0 {
+		return Limit{}, errors.New("burst must not be negative")
+	}
+	if l.Burst == 0 {
+		l.Burst = int(math.Ceil(l.Rate))
+	}
+	if l.ResponseCode == 0 {
+		l.ResponseCode = DefaultResponseCode
+	}
+	if l.ResponseCode < 100 || l.ResponseCode > 599 {
+		return Limit{}, errors.New("response_code must be a valid HTTP code")
+	}
+	if l.RetryAfter != nil && *l.RetryAfter < 0 {
+		return Limit{}, errors.New("retry_after must not be negative")
+	}
+	if l.MaxKeys < 0 {
+		return Limit{}, errors.New("max_keys must not be negative")
+	}
+	if l.MaxKeys == 0 {
+		l.MaxKeys = DefaultMaxKeys
+	}
+	return l, nil
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a token bucket per key of a Limit. It's threadsafe.
+type Limiter struct {
+	limit     Limit
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	m         sync.Mutex
+}
+
+// NewLimiter creates a Limiter of the given limit, which must have its defaults set by WithDefaults.
+func NewLimiter(limit Limit) *Limiter {
+	return &Limiter{limit: limit, buckets: map[string]*bucket{}, lastSweep: time.Now()}
+}
+
+// Limit returns the limit of the limiter.
+func (l *Limiter) Limit() Limit {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.limit
+}
+
+// SetLimit changes the limit of the limiter. Existing buckets keep their tokens, up to the new burst, so keys which are limited stay limited.
+func (l *Limiter) SetLimit(limit Limit) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.limit = limit
+}
+
+// Key returns the bucket key of the given request and client IP, and false if the request isn't limited by this limiter.
+func (l *Limiter) Key(r *http.Request, clientIP string) (string, bool) {
+	l.m.Lock()
+	limit := l.limit
+	l.m.Unlock()
+	switch limit.Key {
+	case KeyClientIP:
+		return clientIP, true
+	case KeyHeader:
+		v := r.Header.Get(limit.Header)
+		return v, v != ""
+	default:
+		return "", true
+	}
+}
+
+// Allow takes a token from the bucket of the given key at the given time. If the bucket is empty, it returns false and the time until the bucket has a token.
+func (l *Limiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	if now.Sub(l.lastSweep) >= SweepInterval {
+		l.sweep(now)
+	}
+
+	burst := float64(l.limit.Burst)
+	b, ok := l.buckets[key]
+	if !ok {
+		l.evict()
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * l.limit.Rate
+		b.last = now
+	}
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.limit.Rate * float64(time.Second))
+}
+
+// sweep deletes the buckets which are full at the given time, which are the same as new buckets. It must be called with the lock held.
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.limit.Rate >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// evict deletes arbitrary buckets until there's room for a new key within MaxKeys. It must be called with the lock held.
+func (l *Limiter) evict() {
+	for key := range l.buckets {
+		if len(l.buckets) < l.limit.MaxKeys {
+			return
+		}
+		delete(l.buckets, key)
+	}
+}
+
+// Len returns the number of keys with buckets.
+func (l *Limiter) Len() int {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return len(l.buckets)
+}
+
+// RetryAfterSeconds returns the Retry-After header value in seconds of a limited request which may retry after the given duration, and false if the header shouldn't be sent.
+func (l Limit) RetryAfterSeconds(retry time.Duration) (int, bool) {
+	if l.RetryAfter != nil {
+		return *l.RetryAfter, *l.RetryAfter > 0
+	}
+	secs := int(math.Ceil(retry.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	return secs, true
+}
@@ -0,0 +1,210 @@
+package ratelimit
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	limit, err := Limit{Key: KeyClientIP, Rate: 2, Burst: 3}.WithDefaults()
+	if err != nil {
+		t.Fatalf("WithDefaults error expected nil, actual %v", err)
+	}
+	l := NewLimiter(limit)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if allowed, _ := l.Allow("a", now); !allowed {
+			t.Errorf("Allow request %v within burst expected allowed, actual limited", i)
+		}
+	}
+	allowed, retry := l.Allow("a", now)
+	if allowed {
+		t.Errorf("Allow request beyond burst expected limited, actual allowed")
+	}
+	if retry != 500*time.Millisecond {
+		t.Errorf("Allow retry expected 500ms, actual %v", retry)
+	}
+	if allowed, _ := l.Allow("b", now); !allowed {
+		t.Errorf("Allow other key expected allowed, actual limited")
+	}
+
+	if allowed, _ := l.Allow("a", now.Add(250*time.Millisecond)); allowed {
+		t.Errorf("Allow before a token refilled expected limited, actual allowed")
+	}
+	if allowed, _ := l.Allow("a", now.Add(500*time.Millisecond)); !allowed {
+		t.Errorf("Allow after a token refilled expected allowed, actual limited")
+	}
+	if allowed, _ := l.Allow("a", now.Add(500*time.Millisecond)); allowed {
+		t.Errorf("Allow after the refilled token was taken expected limited, actual allowed")
+	}
+
+	// refilling never exceeds the burst
+	later := now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if allowed, _ := l.Allow("a", later); !allowed {
+			t.Errorf("Allow request %v after refilling expected allowed, actual limited", i)
+		}
+	}
+	if allowed, _ := l.Allow("a", later); allowed {
+		t.Errorf("Allow request beyond burst after refilling expected limited, actual allowed")
+	}
+}
+
+func TestSetLimit(t *testing.T) {
+	limit, _ := Limit{Key: KeyRule, Rate: 1, Burst: 10}.WithDefaults()
+	l := NewLimiter(limit)
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		l.Allow("", now)
+	}
+
+	limit.Burst = 2
+	l.SetLimit(limit)
+	for i := 0; i < 2; i++ {
+		if allowed, _ := l.Allow("", now); !allowed {
+			t.Errorf("Allow request %v within reduced burst expected allowed, actual limited", i)
+		}
+	}
+	if allowed, _ := l.Allow("", now); allowed {
+		t.Errorf("Allow request beyond reduced burst expected limited, actual allowed")
+	}
+}
+
+func TestSweep(t *testing.T) {
+	limit, _ := Limit{Key: KeyClientIP, Rate: 1, Burst: 120}.WithDefaults()
+	l := NewLimiter(limit)
+	now := time.Now()
+	l.Allow("idle", now)
+	for i := 0; i < 100; i++ {
+		l.Allow("busy", now)
+	}
+	if n := l.Len(); n != 2 {
+		t.Fatalf("Len expected 2, actual %v", n)
+	}
+
+	// after the sweep interval, idle has refilled and is deleted, but busy hasn't
+	l.Allow("new", now.Add(SweepInterval))
+	if n := l.Len(); n != 2 {
+		t.Errorf("Len after sweep expected 2, actual %v", n)
+	}
+	if _, ok := l.buckets["idle"]; ok {
+		t.Errorf("sweep expected to delete refilled bucket, actual kept")
+	}
+	if _, ok := l.buckets["busy"]; !ok {
+		t.Errorf("sweep expected to keep bucket which hasn't refilled, actual deleted")
+	}
+}
+
+func TestMaxKeys(t *testing.T) {
+	limit, _ := Limit{Key: KeyHeader, Header: "X-Api-Key", Rate: 1, MaxKeys: 3}.WithDefaults()
+	l := NewLimiter(limit)
+	now := time.Now()
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		if allowed, _ := l.Allow(key, now); !allowed {
+			t.Errorf("Allow new key %v expected allowed, actual limited", key)
+		}
+		if n := l.Len(); n > 3 {
+			t.Errorf("Len after key %v expected at most max keys 3, actual %v", key, n)
+		}
+	}
+	if _, ok := l.buckets["e"]; !ok {
+		t.Errorf("expected the newest key to have a bucket, actual evicted")
+	}
+
+	limit.MaxKeys = 1
+	l.SetLimit(limit)
+	l.Allow("f", now)
+	if n := l.Len(); n != 1 {
+		t.Errorf("Len after reducing max keys expected 1, actual %v", n)
+	}
+}
+
+func TestKey(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Api-Key", "sekrit")
+
+	for _, test := range []struct {
+		limit    Limit
+		key      string
+		expected bool
+	}{
+		{Limit{Key: KeyClientIP, Rate: 1}, "192.0.2.1", true},
+		{Limit{Key: KeyRule, Rate: 1}, "", true},
+		{Limit{Key: KeyHeader, Header: "x-api-key", Rate: 1}, "sekrit", true},
+		{Limit{Key: KeyHeader, Header: "X-Missing", Rate: 1}, "", false},
+	} {
+		limit, err := test.limit.WithDefaults()
+		if err != nil {
+			t.Fatalf("WithDefaults(%+v) error expected nil, actual %v", test.limit, err)
+		}
+		key, ok := NewLimiter(limit).Key(r, "192.0.2.1")
+		if key != test.key || ok != test.expected {
+			t.Errorf("Key %+v expected '%v' %v, actual '%v' %v", limit, test.key, test.expected, key, ok)
+		}
+	}
+}
+
+func TestWithDefaults(t *testing.T) {
+	limit, err := Limit{Key: KeyHeader, Header: "x-api-key", Rate: 2.5}.WithDefaults()
+	if err != nil {
+		t.Fatalf("WithDefaults error expected nil, actual %v", err)
+	}
+	if limit.Name != "X-Api-Key" || limit.Burst != 3 || limit.ResponseCode != http.StatusTooManyRequests || limit.MaxKeys != DefaultMaxKeys {
+		t.Errorf("WithDefaults expected name X-Api-Key burst 3 code 429 max keys %v, actual %+v", DefaultMaxKeys, limit)
+	}
+
+	negative := -1
+	for _, invalid := range []Limit{
+		{Key: "", Rate: 1},
+		{Key: "cookie", Rate: 1},
+		{Key: KeyHeader, Rate: 1},
+		{Key: KeyClientIP, Header: "X-Api-Key", Rate: 1},
+		{Key: KeyClientIP},
+		{Key: KeyClientIP, Rate: -1},
+		{Key: KeyClientIP, Rate: 1, Burst: -1},
+		{Key: KeyClientIP, Rate: 1, ResponseCode: 1000},
+		{Key: KeyClientIP, Rate: 1, RetryAfter: &negative},
+		{Key: KeyClientIP, Rate: 1, MaxKeys: -1},
+	} {
+		if _, err := invalid.WithDefaults(); err == nil {
+			t.Errorf("WithDefaults(%+v) expected error, actual nil", invalid)
+		}
+	}
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	limit := Limit{}
+	if secs, ok := limit.RetryAfterSeconds(1100 * time.Millisecond); secs != 2 || !ok {
+		t.Errorf("RetryAfterSeconds expected 2 true, actual %v %v", secs, ok)
+	}
+	if secs, ok := limit.RetryAfterSeconds(time.Millisecond); secs != 1 || !ok {
+		t.Errorf("RetryAfterSeconds expected 1 true, actual %v %v", secs, ok)
+	}
+	fixed := 30
+	limit.RetryAfter = &fixed
+	if secs, ok := limit.RetryAfterSeconds(time.Millisecond); secs != 30 || !ok {
+		t.Errorf("RetryAfterSeconds with retry_after expected 30 true, actual %v %v", secs, ok)
+	}
+	none := 0
+	limit.RetryAfter = &none
+	if _, ok := limit.RetryAfterSeconds(time.Millisecond); ok {
+		t.Errorf("RetryAfterSeconds with retry_after 0 expected false, actual true")
+	}
+}
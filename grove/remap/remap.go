@@ -25,9 +25,13 @@ import (
 	"strings"
 	"time"
 
+	"github.com/apache/trafficcontrol/v8/grove/accesslog"
 	"github.com/apache/trafficcontrol/v8/grove/chash"
+	"github.com/apache/trafficcontrol/v8/grove/health"
 	"github.com/apache/trafficcontrol/v8/grove/icache"
+	"github.com/apache/trafficcontrol/v8/grove/peer"
 	"github.com/apache/trafficcontrol/v8/grove/plugin"
+	"github.com/apache/trafficcontrol/v8/grove/ratelimit"
 	"github.com/apache/trafficcontrol/v8/grove/remapdata"
 	"github.com/apache/trafficcontrol/v8/grove/web"
 
@@ -99,6 +103,7 @@ func NewRemappingTransport(reqTimeout time.Duration, reqKeepAlive time.Duration,
 
 type Remapping struct {
 	Request         *http.Request
+	Parent          string
 	ProxyURL        *url.URL
 	Name            string
 	CacheKey        string
@@ -106,17 +111,26 @@ type Remapping struct {
 	Timeout         time.Duration
 	RetryNum        int
 	RetryCodes      map[int]struct{}
+	MaxVariants     int
 	Cache           icache.Cache
 	Transport       *http.Transport
+	Peer            bool // whether the request is to a peer, rather than a parent
 }
 
 // RemappingProducer takes an HTTP Request and returns a Remapping to be used for that request.
 // TODO rename? interface?
 type RemappingProducer struct {
-	oldURI   string
-	rule     remapdata.RemapRule
-	cacheKey string
-	failures int
+	oldURI    string
+	rule      remapdata.RemapRule
+	cacheKey  string
+	failures  int
+	peerTried bool
+}
+
+// Copy returns a copy of the producer, which makes its own parent requests, so it may be used concurrently with the producer, e.g. by a revalidation after the client's request is finished.
+func (p *RemappingProducer) Copy() *RemappingProducer {
+	c := *p
+	return &c
 }
 
 func (p *RemappingProducer) CacheKey() string                  { return p.cacheKey }
@@ -126,6 +140,20 @@ func (p *RemappingProducer) Name() string                      { return p.rule.N
 func (p *RemappingProducer) DSCP() int                         { return p.rule.DSCP }
 func (p *RemappingProducer) PluginCfg() map[string]interface{} { return p.rule.Plugins }
 func (p *RemappingProducer) Cache() icache.Cache               { return p.rule.Cache }
+func (p *RemappingProducer) Stream() bool                      { return p.rule.Stream }
+func (p *RemappingProducer) AccessLogs() []accesslog.Selection { return p.rule.AccessLogs }
+func (p *RemappingProducer) MaxVariants() int {
+	if p.rule.MaxVariants == 0 {
+		return remapdata.DefaultMaxVariants
+	}
+	return p.rule.MaxVariants
+}
+func (p *RemappingProducer) StaleWhileRevalidate() time.Duration {
+	return time.Duration(p.rule.StaleWhileRevalidate) * time.Second
+}
+func (p *RemappingProducer) StaleIfError() time.Duration {
+	return time.Duration(p.rule.StaleIfError) * time.Second
+}
 func (p *RemappingProducer) FirstFQDN() string {
 	// TODO verify To is not allowed to be constructed with < 1 element
 	return strings.TrimPrefix(strings.TrimPrefix(p.rule.To[0].URL, "http://"), "https://")
@@ -171,11 +199,20 @@ func (hr simpleHTTPRequestRemapper) RemappingProducer(r *http.Request, scheme st
 
 // GetNext returns the remapping to use to request, whether retries are allowed (i.e. if this is the last retry), or any error
 func (p *RemappingProducer) GetNext(r *http.Request) (Remapping, bool, error) {
+	if !p.peerTried {
+		p.peerTried = true
+		if p.rule.Peers != nil && r.Header.Get(peer.Header) == "" {
+			if owner, ok := p.rule.Peers.Owner(p.cacheKey); ok {
+				return p.peerRemapping(r, owner)
+			}
+		}
+	}
+
 	if *p.rule.RetryNum < p.failures {
 		return Remapping{}, false, ErrNoMoreRetries
 	}
 
-	newURI, proxyURL, transport := p.rule.URI(p.oldURI, r.URL.Path, r.URL.RawQuery, p.failures)
+	newURI, parent, proxyURL, transport := p.rule.URI(p.oldURI, r.URL.Path, r.URL.RawQuery, p.failures)
 	p.failures++
 	newReq, err := http.NewRequest(r.Method, newURI, nil)
 	if err != nil {
@@ -188,10 +225,12 @@ func (p *RemappingProducer) GetNext(r *http.Request) (Remapping, bool, error) {
 	log.Debugf("GetNext rule name: %v\n", p.rule.Name)
 
 	newReq.Header.Set("Host", getFQDN(newURI))
+	newReq.Header.Del(peer.Header)
 
 	retryAllowed := *p.rule.RetryNum < p.failures
 	return Remapping{
 		Request:         newReq,
+		Parent:          parent,
 		ProxyURL:        proxyURL,
 		Name:            p.rule.Name,
 		CacheKey:        p.cacheKey,
@@ -199,11 +238,51 @@ func (p *RemappingProducer) GetNext(r *http.Request) (Remapping, bool, error) {
 		Timeout:         *p.rule.Timeout,
 		RetryNum:        *p.rule.RetryNum,
 		RetryCodes:      p.rule.RetryCodes,
+		MaxVariants:     p.MaxVariants(),
 		Cache:           p.rule.Cache,
 		Transport:       transport,
 	}, retryAllowed, nil
 }
 
+// peerRemapping returns the remapping to request the object from the given peer URL. The request is the client request with the peer Header, and failures aren't cached, so the parent is requested next. It doesn't count against the rule's retries.
+func (p *RemappingProducer) peerRemapping(r *http.Request, owner string) (Remapping, bool, error) {
+	newReq, err := http.NewRequest(r.Method, owner+r.RequestURI, nil)
+	if err != nil {
+		return Remapping{}, false, fmt.Errorf("creating new peer request: %v\n", err)
+	}
+	web.CopyHeaderTo(r.Header, &newReq.Header)
+	newReq.Host = r.Host
+	newReq.Header.Set(peer.Header, p.oldURI[:strings.Index(p.oldURI, "://")])
+
+	log.Debugf("GetNext oldUri: %v, peer: %v\n", p.oldURI, owner)
+
+	return Remapping{
+		Request:         newReq,
+		Parent:          owner,
+		Name:            p.rule.Name,
+		CacheKey:        p.cacheKey,
+		ConnectionClose: p.rule.ConnectionClose,
+		Timeout:         *p.rule.Timeout,
+		RetryNum:        *p.rule.RetryNum,
+		RetryCodes:      peer.RetryCodes,
+		MaxVariants:     p.MaxVariants(),
+		Cache:           p.rule.Cache,
+		Transport:       p.rule.Peers.Transport(),
+		Peer:            true,
+	}, false, nil
+}
+
+// ReportResult records whether the request of the given remapping succeeded, for the health tracking of its parent or peer.
+func (p *RemappingProducer) ReportResult(remapping Remapping, success bool) {
+	if remapping.Peer {
+		p.rule.Peers.Report(remapping.Parent, success)
+		return
+	}
+	if p.rule.Health != nil {
+		p.rule.Health.Report(remapping.Parent, success)
+	}
+}
+
 func RemapperToHTTP(r Remapper, statRules *remapdata.RemapRulesStats) HTTPRequestRemapper {
 	return simpleHTTPRequestRemapper{remapper: r, stats: statRules}
 }
@@ -272,6 +351,10 @@ type RemapRulesStatsJSON struct {
 type RemapRulesBase struct {
 	RetryNum      *int                       `json:"retry_num"`
 	PluginsShared map[string]json.RawMessage `json:"plugins_shared"`
+	HealthCheck   *health.Check              `json:"health_check"`
+	PeerCache     *bool                      `json:"peer_cache"`
+	AccessLogs    []accesslog.Selection      `json:"access_logs"`
+	RateLimits    []ratelimit.Limit          `json:"rate_limits"`
 }
 
 type RemapRulesJSON struct {
@@ -318,8 +401,8 @@ type RemapRuleJSON struct {
 	Plugins         map[string]json.RawMessage `json:"plugins"`
 }
 
-// LoadRemapRules returns the loaded rules, the global plugins, the Stats remap rules, and any error
-func LoadRemapRules(path string, pluginConfigLoaders map[string]plugin.LoadFunc, caches map[string]icache.Cache, baseTransport *http.Transport) ([]remapdata.RemapRule, map[string]interface{}, *remapdata.RemapRulesStats, error) {
+// LoadRemapRules returns the loaded rules, the global plugins, the Stats remap rules, and any error. The parentHealth is used by the rules to skip parents marked down, and must be updated with HealthChecks of the rules when they're used. The peers are used by rules with peer_cache.
+func LoadRemapRules(path string, pluginConfigLoaders map[string]plugin.LoadFunc, caches map[string]icache.Cache, baseTransport *http.Transport, parentHealth *health.Parents, peers *peer.Peers) ([]remapdata.RemapRule, map[string]interface{}, *remapdata.RemapRulesStats, error) {
 	fmt.Println(time.Now().Format(time.RFC3339Nano) + " Loading Remap Rules")
 	defer func() {
 		fmt.Println(time.Now().Format(time.RFC3339Nano) + " Loaded Remap Rules")
@@ -418,10 +501,55 @@ func LoadRemapRules(path string, pluginConfigLoaders map[string]plugin.LoadFunc,
 			rule.RetryNum = remapRules.RetryNum
 		}
 
+		if rule.MaxVariants < 0 {
+			return nil, nil, nil, fmt.Errorf("error parsing rule %v max_variants must not be negative: %v", rule.Name, rule.MaxVariants)
+		}
+		if rule.StaleWhileRevalidate < 0 {
+			return nil, nil, nil, fmt.Errorf("error parsing rule %v stale_while_revalidate must not be negative: %v", rule.Name, rule.StaleWhileRevalidate)
+		}
+		if rule.StaleIfError < 0 {
+			return nil, nil, nil, fmt.Errorf("error parsing rule %v stale_if_error must not be negative: %v", rule.Name, rule.StaleIfError)
+		}
+
 		if rule.PluginsShared == nil {
 			rule.PluginsShared = remapRules.PluginsShared
 		}
 
+		if rule.HealthCheck == nil {
+			rule.HealthCheck = remapRules.HealthCheck
+		}
+		if rule.HealthCheck != nil {
+			check, err := rule.HealthCheck.WithDefaults()
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("error parsing rule %v health_check: %v", rule.Name, err)
+			}
+			rule.HealthCheck = &check
+		}
+		rule.Health = parentHealth
+
+		if rule.PeerCache == nil {
+			rule.PeerCache = remapRules.PeerCache
+		}
+		if rule.PeerCache != nil && *rule.PeerCache {
+			rule.Peers = peers
+		}
+
+		if rule.AccessLogs == nil {
+			rule.AccessLogs = remapRules.AccessLogs
+		}
+		for _, sel := range rule.AccessLogs {
+			if err := sel.Validate(); err != nil {
+				return nil, nil, nil, fmt.Errorf("error parsing rule %v access_logs: %v", rule.Name, err)
+			}
+		}
+
+		if rule.RateLimits == nil {
+			rule.RateLimits = remapRules.RateLimits
+		}
+		if rule.RateLimits, err = makeRateLimits(rule.RateLimits); err != nil {
+			return nil, nil, nil, fmt.Errorf("error parsing rule %v rate_limits: %v", rule.Name, err)
+		}
+
 		cacheName := "" // default string is the default cache
 		if jsonRule.CacheName != nil {
 			cacheName = *jsonRule.CacheName
@@ -460,6 +588,7 @@ func LoadRemapRules(path string, pluginConfigLoaders map[string]plugin.LoadFunc,
 		if *rule.ParentSelection == remapdata.ParentSelectionTypeConsistentHash {
 			rule.ConsistentHash = makeRuleHash(rule)
 		} else {
+			rule.RoundRobin = &remapdata.RoundRobin{}
 		}
 		rules[i] = rule
 	}
@@ -563,14 +692,51 @@ func makeIPNet(cidr string) (*net.IPNet, error) {
 	return cidrnet, nil
 }
 
-func LoadRemapper(path string, pluginConfigLoaders map[string]plugin.LoadFunc, caches map[string]icache.Cache, baseTransport *http.Transport) (HTTPRequestRemapper, error) {
-	rules, plugins, statRules, err := LoadRemapRules(path, pluginConfigLoaders, caches, baseTransport)
+// makeRateLimits returns the given limits with their defaults, or an error if any is invalid or their names aren't unique.
+func makeRateLimits(limits []ratelimit.Limit) ([]ratelimit.Limit, error) {
+	if limits == nil {
+		return nil, nil
+	}
+	newLimits := make([]ratelimit.Limit, 0, len(limits))
+	names := map[string]struct{}{}
+	for _, limit := range limits {
+		limit, err := limit.WithDefaults()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := names[limit.Name]; ok {
+			return nil, fmt.Errorf("limit name '%v' is duplicated", limit.Name)
+		}
+		names[limit.Name] = struct{}{}
+		newLimits = append(newLimits, limit)
+	}
+	return newLimits, nil
+}
+
+func LoadRemapper(path string, pluginConfigLoaders map[string]plugin.LoadFunc, caches map[string]icache.Cache, baseTransport *http.Transport, parentHealth *health.Parents, peers *peer.Peers) (HTTPRequestRemapper, error) {
+	rules, plugins, statRules, err := LoadRemapRules(path, pluginConfigLoaders, caches, baseTransport, parentHealth, peers)
 	if err != nil {
 		return nil, err
 	}
 	return NewHTTPRequestRemapper(rules, plugins, statRules), nil
 }
 
+// HealthChecks returns the health checks of the parents of the given rules with a health_check, keyed by parent URL, for health.Parents.Update. If rules with the same parent have different health checks, the first rule's is used.
+func HealthChecks(rules []remapdata.RemapRule) map[string]health.ParentCheck {
+	checks := map[string]health.ParentCheck{}
+	for _, rule := range rules {
+		if rule.HealthCheck == nil {
+			continue
+		}
+		for _, to := range rule.To {
+			if _, ok := checks[to.URL]; !ok {
+				checks[to.URL] = health.ParentCheck{Check: *rule.HealthCheck, Transport: to.Transport}
+			}
+		}
+	}
+	return checks
+}
+
 func RemapRulesToJSON(r RemapRules) (RemapRulesJSON, error) {
 	j := RemapRulesJSON{RemapRulesBase: r.RemapRulesBase}
 	if r.Timeout != nil {
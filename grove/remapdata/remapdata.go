@@ -22,10 +22,15 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/apache/trafficcontrol/v8/grove/accesslog"
 	"github.com/apache/trafficcontrol/v8/grove/chash"
+	"github.com/apache/trafficcontrol/v8/grove/health"
 	"github.com/apache/trafficcontrol/v8/grove/icache"
+	"github.com/apache/trafficcontrol/v8/grove/peer"
+	"github.com/apache/trafficcontrol/v8/grove/ratelimit"
 
 	"github.com/apache/trafficcontrol/v8/lib/go-log"
 )
@@ -98,8 +103,30 @@ type RemapRuleBase struct {
 	RetryNum               *int                       `json:"retry_num"`
 	DSCP                   int                        `json:"dscp"`
 	PluginsShared          map[string]json.RawMessage `json:"plugins_shared"`
+	// Stream is whether to serve responses to clients as they're received from the parent, storing their bodies in chunks, rather than receiving the entire body before responding. This requires a cache which supports chunks, and is intended for large objects.
+	Stream bool `json:"stream"`
+	// MaxVariants is the maximum number of variants stored for each object whose response has a Vary header. When a new variant is stored beyond this, the oldest is removed. If this is 0, DefaultMaxVariants is used.
+	MaxVariants int `json:"max_variants"`
+	// StaleWhileRevalidate is the number of seconds a stale object may be served while it's revalidated in the background, for responses without a stale-while-revalidate Cache-Control directive, per RFC5861§3. If this is 0, stale objects without the directive are revalidated before they're served.
+	StaleWhileRevalidate int `json:"stale_while_revalidate"`
+	// StaleIfError is the number of seconds a stale object may be served if revalidating it fails with an error, for responses without a stale-if-error Cache-Control directive, per RFC5861§4. If this is 0, stale objects without the directive are only served if the parent can't be reached.
+	StaleIfError int `json:"stale_if_error"`
+	// HealthCheck is the health tracking of the rule's parents. Parents marked down are skipped by parent selection, unless all parents are down. If nil, the rule's parents aren't tracked.
+	HealthCheck *health.Check `json:"health_check"`
+	// PeerCache is whether to request objects from the peer their cache key is hashed to, before the parent. Peers are configured in the global config. If nil, the rules default is used.
+	PeerCache *bool `json:"peer_cache"`
+	// AccessLogs are the access logs to write the rule's requests to, by name, with optional sample rates. If nil, the rules default is used, and if that is nil, requests are written to all access logs. If empty, requests aren't logged.
+	AccessLogs []accesslog.Selection `json:"access_logs"`
+	// RateLimits are the token bucket limits of client requests to the rule. Requests exceeding any limit are rejected. If nil, the rules default is used.
+	RateLimits []ratelimit.Limit `json:"rate_limits"`
 }
 
+// DefaultMaxVariants is the maximum number of variants stored for each object, for remap rules which don't specify max_variants.
+const DefaultMaxVariants = 8
+
+// MethodPurge is the HTTP method of requests to remove an object from the cache. It isn't a standard method, but is widely used by caches.
+const MethodPurge = "PURGE"
+
 type RemapRule struct {
 	RemapRuleBase
 	Timeout         *time.Duration
@@ -109,10 +136,23 @@ type RemapRule struct {
 	Deny            []*net.IPNet
 	RetryCodes      map[int]struct{}
 	ConsistentHash  chash.ATSConsistentHash
+	RoundRobin      *RoundRobin
+	Health          *health.Parents
+	Peers           *peer.Peers // nil if the rule doesn't use peers
 	Cache           icache.Cache
 	Plugins         map[string]interface{}
 }
 
+// RoundRobin selects parents in turn, for the round-robin parent selection type. It's a pointer in RemapRule, so copies of a rule share it.
+type RoundRobin struct {
+	next uint64 // Atomic - DO NOT access or modify without atomic operations
+}
+
+// Next returns the index of the next parent to select, which must be taken modulo the number of parents.
+func (rr *RoundRobin) Next() uint64 {
+	return atomic.AddUint64(&rr.next, 1) - 1
+}
+
 func (r *RemapRule) Allowed(ip net.IP) bool {
 	for _, network := range r.Deny {
 		if network.Contains(ip) {
@@ -133,8 +173,8 @@ func (r *RemapRule) Allowed(ip net.IP) bool {
 	return false
 }
 
-// URI takes a request URI and maps it to the real URI to proxy-and-cache. The `failures` parameter indicates how many parents have tried and failed, indicating to skip to the nth hashed parent. Returns the URI to request, and the proxy URL (if any)
-func (r RemapRule) URI(fromURI string, path string, query string, failures int) (string, *url.URL, *http.Transport) {
+// URI takes a request URI and maps it to the real URI to proxy-and-cache. The `failures` parameter indicates how many parents have tried and failed, indicating to skip to the nth hashed parent. Returns the URI to request, the URL of the parent it's for, and the proxy URL (if any)
+func (r RemapRule) URI(fromURI string, path string, query string, failures int) (string, string, *url.URL, *http.Transport) {
 	fromHash := path
 	if r.QueryString.Remap && query != "" {
 		fromHash += "?" + query
@@ -148,7 +188,7 @@ func (r RemapRule) URI(fromURI string, path string, query string, failures int)
 			uri = uri[:i]
 		}
 	}
-	return uri, proxyURI, transport
+	return uri, to, proxyURI, transport
 }
 
 // uriGetTo is a helper func for URI. It returns the To URL, based on the Parent Selection type. In the event of failure, it logs the error and returns the first parent. Also returns the URL's Proxy URI (if any).
@@ -156,13 +196,30 @@ func (r RemapRule) uriGetTo(fromURI string, failures int) (string, *url.URL, *ht
 	switch *r.ParentSelection {
 	case ParentSelectionTypeConsistentHash:
 		return r.uriGetToConsistentHash(fromURI, failures)
+	case ParentSelectionTypeRoundRobin:
+		return r.uriGetToRoundRobin()
 	default:
 		log.Errorf("RemapRule.URI: Rule '%v': Unknown Parent Selection type %v - using first URI in rule\n", r.Name, r.ParentSelection)
 		return r.To[0].URL, r.To[0].ProxyURL, r.To[0].Transport
 	}
 }
 
-// uriGetToConsistentHash is a helper func for URI, uriGetTo. It returns the To URL using Consistent Hashing. In the event of failure, it logs the error and returns the first parent. Also returns the Proxy URI (if any).
+// healthy returns whether the parent with the given URL isn't marked down.
+func (r RemapRule) healthy(parentURL string) bool {
+	return r.Health == nil || r.Health.Healthy(parentURL)
+}
+
+// anyHealthy returns whether any of the rule's parents aren't marked down. If all parents are down, parent selection ignores health, so requests are still attempted.
+func (r RemapRule) anyHealthy() bool {
+	for _, to := range r.To {
+		if r.healthy(to.URL) {
+			return true
+		}
+	}
+	return false
+}
+
+// uriGetToConsistentHash is a helper func for URI, uriGetTo. It returns the To URL using Consistent Hashing, skipping parents marked down. In the event of failure, it logs the error and returns the first parent. Also returns the Proxy URI (if any).
 func (r RemapRule) uriGetToConsistentHash(fromURI string, failures int) (string, *url.URL, *http.Transport) {
 	// fmt.Printf("DEBUGL uriGetToConsistentHash RemapRule %+v\n", r)
 	if r.ConsistentHash == nil {
@@ -181,13 +238,58 @@ func (r RemapRule) uriGetToConsistentHash(fromURI string, failures int) (string,
 		return r.To[0].URL, r.To[0].ProxyURL, r.To[0].Transport
 	}
 
-	for i := 0; i < failures; i++ {
+	owner := iter
+	skipUnhealthy := r.anyHealthy()
+	found := true
+	if skipUnhealthy {
+		iter, found = r.nextHealthy(iter)
+	}
+	for i := 0; found && i < failures; i++ {
 		iter = iter.NextWrap()
+		if skipUnhealthy {
+			iter, found = r.nextHealthy(iter)
+		}
+	}
+	if !found {
+		// every parent in the ring was down, e.g. marked down since anyHealthy, so ignore health, as when all parents are down
+		iter = owner
+		for i := 0; i < failures; i++ {
+			iter = iter.NextWrap()
+		}
 	}
 
 	return iter.Val().Name, iter.Val().ProxyURL, iter.Val().Transport
 }
 
+// nextHealthy returns the first parent at or after iter in the ring which isn't marked down. Returns false if it walked the whole ring without finding one.
+func (r RemapRule) nextHealthy(iter chash.OrderedMapUint64NodeIterator) (chash.OrderedMapUint64NodeIterator, bool) {
+	start := iter.Index()
+	for !r.healthy(iter.Val().Name) {
+		iter = iter.NextWrap()
+		if iter.Index() == start {
+			return iter, false
+		}
+	}
+	return iter, true
+}
+
+// uriGetToRoundRobin is a helper func for URI, uriGetTo. It returns the next To URL in turn, skipping parents marked down. Each request and retry takes the next parent, so retries go to a different parent. Also returns the Proxy URI (if any).
+func (r RemapRule) uriGetToRoundRobin() (string, *url.URL, *http.Transport) {
+	if r.RoundRobin == nil {
+		log.Errorf("RemapRule.URI: Rule '%v': Parent Selection Type RoundRobin, but rule.RoundRobin is nil! Using first parent\n", r.Name)
+		return r.To[0].URL, r.To[0].ProxyURL, r.To[0].Transport
+	}
+	skipUnhealthy := r.anyHealthy()
+	start := r.RoundRobin.Next()
+	for i := uint64(0); i < uint64(len(r.To)); i++ {
+		to := r.To[(start+i)%uint64(len(r.To))]
+		if !skipUnhealthy || r.healthy(to.URL) {
+			return to.URL, to.ProxyURL, to.Transport
+		}
+	}
+	return r.To[0].URL, r.To[0].ProxyURL, r.To[0].Transport // never reached, because a parent is healthy if skipUnhealthy
+}
+
 func (r RemapRule) CacheKey(method string, fromURI string) string {
 	// TODO don't cache on `to`, since it's affected by Parent Selection
 	// TODO add parent selection
@@ -198,7 +300,7 @@ func (r RemapRule) CacheKey(method string, fromURI string) string {
 			uri = uri[:i]
 		}
 	}
-	if method == http.MethodHead { // HEAD uses the same key as GET
+	if method == http.MethodHead || method == MethodPurge { // HEAD and PURGE use the same key as GET
 		method = http.MethodGet
 	}
 	key := method + ":" + uri
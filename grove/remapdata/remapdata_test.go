@@ -0,0 +1,114 @@
+package remapdata
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/grove/chash"
+	"github.com/apache/trafficcontrol/v8/grove/health"
+)
+
+func testRule(selection ParentSelectionType, parents ...string) RemapRule {
+	rule := RemapRule{RemapRuleBase: RemapRuleBase{Name: "test", From: "http://from.example"}, ParentSelection: &selection, Health: health.New()}
+	h := chash.NewSimpleATSConsistentHash(1024)
+	for _, parent := range parents {
+		rule.To = append(rule.To, RemapRuleTo{RemapRuleToBase: RemapRuleToBase{URL: parent}})
+		h.Insert(&chash.ATSConsistentHashNode{Name: parent}, 1)
+	}
+	rule.ConsistentHash = h
+	rule.RoundRobin = &RoundRobin{}
+	checks := map[string]health.ParentCheck{}
+	for _, parent := range parents {
+		checks[parent] = health.ParentCheck{Check: health.Check{Failures: 1, RecoverSeconds: 3600}}
+	}
+	rule.Health.Update(checks)
+	return rule
+}
+
+func TestURISkipsUnhealthyConsistentHash(t *testing.T) {
+	rule := testRule(ParentSelectionTypeConsistentHash, "http://a", "http://b", "http://c")
+	paths := []string{"/0", "/1", "/2", "/3", "/4", "/5", "/6", "/7", "/8", "/9"}
+	hashed := map[string]string{}
+	for _, path := range paths {
+		_, parent, _, _ := rule.URI("http://from.example"+path, path, "", 0)
+		hashed[path] = parent
+	}
+
+	rule.Health.Report("http://a", false)
+	for _, path := range paths {
+		_, parent, _, _ := rule.URI("http://from.example"+path, path, "", 0)
+		if parent == "http://a" {
+			t.Errorf("expected path %v to skip down parent, actual %v", path, parent)
+		} else if hashed[path] != "http://a" && parent != hashed[path] {
+			t.Errorf("expected path %v hashed to up parent %v to keep it, actual %v", path, hashed[path], parent)
+		}
+	}
+
+	rule.Health.Report("http://b", false)
+	rule.Health.Report("http://c", false)
+	for _, path := range paths {
+		if _, parent, _, _ := rule.URI("http://from.example"+path, path, "", 0); parent != hashed[path] {
+			t.Errorf("expected path %v to use its hashed parent %v when all parents are down, actual %v", path, hashed[path], parent)
+		}
+	}
+}
+
+func TestURIConsistentHashAllRingParentsDown(t *testing.T) {
+	rule := testRule(ParentSelectionTypeConsistentHash, "http://a", "http://b", "http://c")
+	paths := []string{"/0", "/1", "/2", "/3", "/4", "/5", "/6", "/7", "/8", "/9"}
+	hashed := map[string]string{}
+	retried := map[string]string{}
+	for _, path := range paths {
+		_, hashed[path], _, _ = rule.URI("http://from.example"+path, path, "", 0)
+		_, retried[path], _, _ = rule.URI("http://from.example"+path, path, "", 2)
+	}
+
+	// A healthy parent which isn't in the ring makes anyHealthy true while every parent in the ring is down, as when parents are marked down during selection.
+	rule.To = append(rule.To, RemapRuleTo{RemapRuleToBase: RemapRuleToBase{URL: "http://d"}})
+	rule.Health.Report("http://a", false)
+	rule.Health.Report("http://b", false)
+	rule.Health.Report("http://c", false)
+
+	for _, path := range paths {
+		if _, parent, _, _ := rule.URI("http://from.example"+path, path, "", 0); parent != hashed[path] {
+			t.Errorf("expected path %v to fall back to its hashed parent %v when all ring parents are down, actual %v", path, hashed[path], parent)
+		}
+		if _, parent, _, _ := rule.URI("http://from.example"+path, path, "", 2); parent != retried[path] {
+			t.Errorf("expected path %v retry to fall back to its hashed retry parent %v when all ring parents are down, actual %v", path, retried[path], parent)
+		}
+	}
+}
+
+func TestURISkipsUnhealthyRoundRobin(t *testing.T) {
+	rule := testRule(ParentSelectionTypeRoundRobin, "http://a", "http://b", "http://c")
+	counts := map[string]int{}
+	for i := 0; i < 6; i++ {
+		_, parent, _, _ := rule.URI("http://from.example/", "/", "", 0)
+		counts[parent]++
+	}
+	for _, parent := range []string{"http://a", "http://b", "http://c"} {
+		if counts[parent] != 2 {
+			t.Errorf("expected round robin to select each parent twice, actual %v", counts)
+		}
+	}
+
+	rule.Health.Report("http://b", false)
+	for i := 0; i < 6; i++ {
+		if _, parent, _, _ := rule.URI("http://from.example/", "/", "", 0); parent == "http://b" {
+			t.Errorf("expected round robin to skip down parent, actual %v", parent)
+		}
+	}
+}
@@ -17,6 +17,7 @@ package stat
 import (
 	"net/http"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -54,6 +55,10 @@ type Stats interface {
 	CacheMisses() uint64
 	AddCacheMiss()
 
+	// RateLimited is the number of client requests rejected by remap rule rate limits.
+	RateLimited() uint64
+	AddRateLimited()
+
 	CacheSize() uint64
 	CacheCapacity() uint64
 
@@ -65,16 +70,21 @@ type Stats interface {
 	CacheCapacityByName(string) (uint64, bool)
 	CacheNames() []string
 	CachePeek(string, string) (*cacheobj.CacheObj, bool)
+
+	// Reload returns new Stats for the given remap rules and connections, which continue the system, cache, and remap rule stats of these Stats. Remap rules are matched by their From FQDN, so rules whose FQDN didn't change keep their stats, and requests still in progress on these Stats are counted in the new Stats.
+	Reload(remapRules []remapdata.RemapRule, httpConns *web.ConnMap, httpsConns *web.ConnMap) Stats
 }
 
 func New(remapRules []remapdata.RemapRule, caches map[string]icache.Cache, cacheCapacityBytes uint64, httpConns *web.ConnMap, httpsConns *web.ConnMap, version string) Stats {
 	cacheHits := uint64(0)
 	cacheMisses := uint64(0)
+	rateLimited := uint64(0)
 	return &stats{
 		system:             NewStatsSystem(version),
 		remap:              NewStatsRemaps(remapRules),
 		cacheHits:          &cacheHits,
 		cacheMisses:        &cacheMisses,
+		rateLimited:        &rateLimited,
 		caches:             caches,
 		cacheCapacityBytes: cacheCapacityBytes,
 		httpConns:          httpConns,
@@ -82,6 +92,20 @@ func New(remapRules []remapdata.RemapRule, caches map[string]icache.Cache, cache
 	}
 }
 
+func (s *stats) Reload(remapRules []remapdata.RemapRule, httpConns *web.ConnMap, httpsConns *web.ConnMap) Stats {
+	return &stats{
+		system:             s.system,
+		remap:              NewStatsRemapsFrom(s.remap, remapRules),
+		cacheHits:          s.cacheHits,
+		cacheMisses:        s.cacheMisses,
+		rateLimited:        s.rateLimited,
+		caches:             s.caches,
+		cacheCapacityBytes: s.cacheCapacityBytes,
+		httpConns:          httpConns,
+		httpsConns:         httpsConns,
+	}
+}
+
 // Write writes to the remapRuleStats of s, and returns the bytes written to the connection
 func (stats *stats) Write(w http.ResponseWriter, conn *web.InterceptConn, reqFQDN string, remoteAddr string, code int, bytesWritten uint64, cacheHit bool) uint64 {
 	remapRuleStats, ok := stats.Remap().Stats(reqFQDN)
@@ -138,6 +162,7 @@ type stats struct {
 	remap              StatsRemaps
 	cacheHits          *uint64
 	cacheMisses        *uint64
+	rateLimited        *uint64
 	caches             map[string]icache.Cache
 	cacheCapacityBytes uint64
 	httpConns          *web.ConnMap
@@ -158,6 +183,8 @@ func (s stats) CacheHits() uint64    { return atomic.LoadUint64(s.cacheHits) }
 func (s stats) AddCacheHit()         { atomic.AddUint64(s.cacheHits, 1) }
 func (s stats) CacheMisses() uint64  { return atomic.LoadUint64(s.cacheMisses) }
 func (s stats) AddCacheMiss()        { atomic.AddUint64(s.cacheMisses, 1) }
+func (s stats) RateLimited() uint64  { return atomic.LoadUint64(s.rateLimited) }
+func (s stats) AddRateLimited()      { atomic.AddUint64(s.rateLimited, 1) }
 func (s *stats) System() StatsSystem { return StatsSystem(s.system) }
 func (s *stats) Remap() StatsRemaps  { return s.remap }
 
@@ -229,6 +256,11 @@ type StatsRemap interface {
 	AddCacheHit()
 	CacheMisses() uint64
 	AddCacheMiss()
+
+	// RateLimited is the number of requests rejected by the rule's rate limits, and RateLimitedByLimit is the number rejected by each limit name.
+	RateLimited() uint64
+	RateLimitedByLimit() map[string]uint64
+	AddRateLimited(limit string)
 }
 
 func getFromFQDN(r remapdata.RemapRule) string {
@@ -252,6 +284,20 @@ func NewStatsRemaps(remapRules []remapdata.RemapRule) StatsRemaps {
 	return statsRemaps(m)
 }
 
+// NewStatsRemapsFrom returns the StatsRemaps of the given remap rules, using the stats in old of each rule FQDN in both, and new stats for FQDNs which aren't in old.
+func NewStatsRemapsFrom(old StatsRemaps, remapRules []remapdata.RemapRule) StatsRemaps {
+	m := make(map[string]StatsRemap, len(remapRules))
+	for _, rule := range remapRules {
+		fqdn := getFromFQDN(rule)
+		if oldStats, ok := old.Stats(fqdn); ok {
+			m[fqdn] = oldStats
+			continue
+		}
+		m[fqdn] = NewStatsRemap()
+	}
+	return statsRemaps(m)
+}
+
 // statsRemaps fulfills the StatsRemaps interface
 type statsRemaps map[string]StatsRemap
 
@@ -281,6 +327,9 @@ type statsRemap struct {
 	status5xx   uint64
 	cacheHits   uint64
 	cacheMisses uint64
+	rateLimited uint64
+	// rateLimitedByLimit is a map of limit names to *uint64 counts.
+	rateLimitedByLimit sync.Map
 }
 
 func (r *statsRemap) InBytes() uint64       { return atomic.LoadUint64(&r.inBytes) }
@@ -302,6 +351,24 @@ func (r *statsRemap) AddCacheHit()      { atomic.AddUint64(&r.cacheHits, 1) }
 func (r *statsRemap) CacheMisses() uint64 { return atomic.LoadUint64(&r.cacheMisses) }
 func (r *statsRemap) AddCacheMiss()       { atomic.AddUint64(&r.cacheMisses, 1) }
 
+func (r *statsRemap) RateLimited() uint64 { return atomic.LoadUint64(&r.rateLimited) }
+func (r *statsRemap) AddRateLimited(limit string) {
+	atomic.AddUint64(&r.rateLimited, 1)
+	count, ok := r.rateLimitedByLimit.Load(limit)
+	if !ok {
+		count, _ = r.rateLimitedByLimit.LoadOrStore(limit, new(uint64))
+	}
+	atomic.AddUint64(count.(*uint64), 1)
+}
+func (r *statsRemap) RateLimitedByLimit() map[string]uint64 {
+	counts := map[string]uint64{}
+	r.rateLimitedByLimit.Range(func(limit, count interface{}) bool {
+		counts[limit.(string)] = atomic.LoadUint64(count.(*uint64))
+		return true
+	})
+	return counts
+}
+
 func NewStatsSystem(version string) StatsSystem {
 	return &statsSystem{version: version}
 }
@@ -173,3 +173,64 @@ func TestStatsCount(t *testing.T) {
 	}
 
 }
+
+func TestStatsReload(t *testing.T) {
+	kept := remapdata.RemapRule{RemapRuleBase: remapdata.RemapRuleBase{Name: "kept", From: "http://kept.example.net"}}
+	removed := remapdata.RemapRule{RemapRuleBase: remapdata.RemapRuleBase{Name: "removed", From: "http://removed.example.net"}}
+	added := remapdata.RemapRule{RemapRuleBase: remapdata.RemapRuleBase{Name: "added", From: "http://added.example.net"}}
+
+	stats := New([]remapdata.RemapRule{kept, removed}, nil, 0, web.NewConnMap(), web.NewConnMap(), "fakeversion")
+	stats.AddCacheHit()
+	stats.System().AddConfigReload()
+	keptStats, _ := stats.Remap().Stats("kept.example.net")
+	keptStats.AddStatus2xx(3)
+
+	reloaded := stats.Reload([]remapdata.RemapRule{kept, added}, web.NewConnMap(), web.NewConnMap())
+	keptStats.AddStatus2xx(1) // a request in progress on the old stats
+
+	if actual := reloaded.CacheHits(); actual != 1 {
+		t.Errorf("Stats.Reload CacheHits expected 1 actual %v", actual)
+	}
+	if actual := reloaded.System().ConfigReloads(); actual != 1 {
+		t.Errorf("Stats.Reload System().ConfigReloads() expected 1 actual %v", actual)
+	}
+	if s, ok := reloaded.Remap().Stats("kept.example.net"); !ok {
+		t.Errorf("Stats.Reload expected kept rule stats, actual none")
+	} else if actual := s.Status2xx(); actual != 4 {
+		t.Errorf("Stats.Reload kept rule Status2xx expected 4 actual %v", actual)
+	}
+	if s, ok := reloaded.Remap().Stats("added.example.net"); !ok {
+		t.Errorf("Stats.Reload expected added rule stats, actual none")
+	} else if actual := s.Status2xx(); actual != 0 {
+		t.Errorf("Stats.Reload added rule Status2xx expected 0 actual %v", actual)
+	}
+	if _, ok := reloaded.Remap().Stats("removed.example.net"); ok {
+		t.Errorf("Stats.Reload expected no removed rule stats, actual stats")
+	}
+}
+
+func TestStatsRateLimited(t *testing.T) {
+	r := remapdata.RemapRule{RemapRuleBase: remapdata.RemapRuleBase{Name: "foo", From: "http://foo.example.net"}}
+	stats := New([]remapdata.RemapRule{r}, nil, 0, web.NewConnMap(), web.NewConnMap(), "fakeversion")
+	remapStats, ok := stats.Remap().Stats("foo.example.net")
+	if !ok {
+		t.Fatalf("Stats.Remap().Stats expected rule stats, actual none")
+	}
+	remapStats.AddRateLimited("client_ip")
+	remapStats.AddRateLimited("client_ip")
+	remapStats.AddRateLimited("X-Api-Key")
+	stats.AddRateLimited()
+
+	if actual := remapStats.RateLimited(); actual != 3 {
+		t.Errorf("StatsRemap.RateLimited() expected 3 actual %v", actual)
+	}
+	byLimit := remapStats.RateLimitedByLimit()
+	if byLimit["client_ip"] != 2 || byLimit["X-Api-Key"] != 1 || len(byLimit) != 2 {
+		t.Errorf("StatsRemap.RateLimitedByLimit() expected client_ip 2 X-Api-Key 1, actual %v", byLimit)
+	}
+
+	reloaded := stats.Reload([]remapdata.RemapRule{r}, web.NewConnMap(), web.NewConnMap())
+	if actual := reloaded.RateLimited(); actual != 1 {
+		t.Errorf("Stats.RateLimited() after reload expected 1 actual %v", actual)
+	}
+}
@@ -0,0 +1,365 @@
+package stream
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// stream exists to serve large objects as they're received from a parent, while storing them in an icache.ChunkCache.
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/apache/trafficcontrol/v8/grove/cacheobj"
+	"github.com/apache/trafficcontrol/v8/grove/icache"
+)
+
+// Streams is a threadsafe set of the Bodys currently being requested from parents, by cache key. This allows requests for an object being received from a parent to read it as it's received, rather than making another parent request.
+type Streams struct {
+	bodies map[string]*Body
+	m      sync.Mutex
+}
+
+func NewStreams() *Streams {
+	return &Streams{bodies: map[string]*Body{}}
+}
+
+// GetOrCreate returns the Body being requested for the given key, and false. If none is being requested, it creates and returns a new Body, and true, in which case the caller must request the object and call SetObj, Write, and Finish, or Fail or SetPassthrough, on the Body.
+func (s *Streams) GetOrCreate(key string, cache icache.ChunkCache) (*Body, bool) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if b, ok := s.bodies[key]; ok {
+		return b, false
+	}
+	b := NewBody(key, cache)
+	b.streams = s
+	s.bodies[key] = b
+	return b, true
+}
+
+func (s *Streams) remove(key string, b *Body) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.bodies[key] == b {
+		delete(s.bodies, key)
+	}
+}
+
+// Body is the body of an object being received from a parent. Each chunk is stored in the cache as it's written, and any number of readers may concurrently read the chunks received so far, blocking until more are received.
+//
+// Chunks are only held in memory if they couldn't be stored in the cache, and only until every reader has read them.
+type Body struct {
+	key   string
+	cache icache.ChunkCache
+	// storeKey is the key the chunks are stored under, which differs from key if the response varies.
+	storeKey string
+	// chunkID is the chunk ID of the object, which its chunks are stored under.
+	chunkID uint64
+	streams *Streams
+
+	m    sync.Mutex
+	cond *sync.Cond
+
+	// obj is the object without its body, set when the parent responds. Readers wait until it's set.
+	obj         *cacheobj.CacheObj
+	objSet      bool
+	passthrough io.ReadCloser
+	err         error
+
+	count   int
+	size    uint64
+	stored  bool           // whether every chunk so far was stored in the cache
+	mem     map[int][]byte // chunks which weren't stored in the cache
+	dropped bool           // whether any chunk in mem was dropped, so new readers can't read from the start
+	done    bool
+	readers map[*bodyReader]struct{}
+}
+
+// NewBody creates a new Body for the given key. If cache is nil, chunks are never stored, and the Body is only useful for SetPassthrough.
+func NewBody(key string, cache icache.ChunkCache) *Body {
+	b := &Body{
+		key:      key,
+		cache:    cache,
+		storeKey: key,
+		stored:   cache != nil,
+		mem:      map[int][]byte{},
+		readers:  map[*bodyReader]struct{}{},
+	}
+	b.cond = sync.NewCond(&b.m)
+	return b
+}
+
+// SetObj sets the object received from the parent, whose body will be written via Write, and stored under the given key and the object's ChunkID. The key may differ from the key the Body was created with, if the response varies by request headers.
+func (b *Body) SetObj(storeKey string, obj *cacheobj.CacheObj) {
+	b.m.Lock()
+	b.storeKey = storeKey
+	b.chunkID = obj.ChunkID
+	b.obj = obj
+	b.objSet = true
+	b.m.Unlock()
+	b.cond.Broadcast()
+}
+
+// FinishCached sets the object received from the parent, whose chunks are all already in the cache, and finishes the body. This is used when a revalidation finds the cached object is still valid.
+func (b *Body) FinishCached(obj *cacheobj.CacheObj) {
+	b.m.Lock()
+	b.chunkID = obj.ChunkID
+	b.obj = obj
+	b.objSet = true
+	b.count = obj.ChunkCount
+	b.size = obj.Size
+	b.done = true
+	b.m.Unlock()
+	b.cond.Broadcast()
+	b.removeStream()
+}
+
+// SetPassthrough sets the object received from the parent, which can't be shared with other requests, typically because it's uncacheable. Its body may only be read by the requestor who created the Body, via Passthrough. The returned chan is closed when the body is closed.
+func (b *Body) SetPassthrough(obj *cacheobj.CacheObj, body io.ReadCloser) <-chan struct{} {
+	closed := make(chan struct{})
+	b.m.Lock()
+	b.obj = obj
+	b.objSet = true
+	b.passthrough = &passthroughBody{ReadCloser: body, closed: closed}
+	b.done = true
+	b.m.Unlock()
+	b.cond.Broadcast()
+	b.removeStream()
+	return closed
+}
+
+// Fail sets the error requesting the object from the parent, if no response was received.
+func (b *Body) Fail(err error) {
+	b.m.Lock()
+	b.err = err
+	b.objSet = true
+	b.done = true
+	b.m.Unlock()
+	b.cond.Broadcast()
+	b.removeStream()
+}
+
+// WaitObj blocks until the parent responds, and returns the object without its body, whether the body may be read via NewReader, and any error requesting the object. If the body can't be shared, only the creator of the Body may read it, via Passthrough.
+func (b *Body) WaitObj() (*cacheobj.CacheObj, bool, error) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	for !b.objSet {
+		b.cond.Wait()
+	}
+	return b.obj, b.passthrough == nil, b.err
+}
+
+// Passthrough returns the body of an object which can't be shared, or nil if the body can be shared. The caller must close it.
+func (b *Body) Passthrough() io.ReadCloser {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return b.passthrough
+}
+
+// Write adds p as the next chunk of the body. It is stored in the cache, or kept in memory if it can't be. Write must only be called by the Body's creator. The Body takes ownership of p.
+func (b *Body) Write(p []byte) (int, error) {
+	b.m.Lock()
+	idx := b.count
+	stored := b.stored
+	b.m.Unlock()
+
+	// Once a chunk isn't stored, later chunks aren't either, because the object can't be added without all of them.
+	if stored && !b.cache.AddChunk(b.storeKey, b.chunkID, idx, p) {
+		stored = false
+	}
+
+	b.m.Lock()
+	if !stored {
+		b.stored = false
+		b.mem[idx] = p
+	}
+	b.count++
+	b.size += uint64(len(p))
+	b.dropRead()
+	b.m.Unlock()
+	b.cond.Broadcast()
+	return len(p), nil
+}
+
+// Finish marks the body complete, with the given error if the parent failed before the entire body was received.
+func (b *Body) Finish(err error) {
+	b.m.Lock()
+	b.err = err
+	b.done = true
+	b.m.Unlock()
+	b.cond.Broadcast()
+	b.removeStream()
+}
+
+// Stored returns whether every chunk written so far was stored in the cache.
+func (b *Body) Stored() bool {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return b.stored
+}
+
+// Count returns the number of chunks written.
+func (b *Body) Count() int {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return b.count
+}
+
+// Size returns the number of bytes written.
+func (b *Body) Size() uint64 {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return b.size
+}
+
+func (b *Body) removeStream() {
+	if b.streams != nil {
+		b.streams.remove(b.key, b)
+	}
+}
+
+// NewReader returns a reader of the body from the start, which blocks until chunks are written, and returns io.EOF when the body is finished. Returns false if the body can't be read from the start, because chunks which couldn't be stored were already dropped. The caller must close the reader.
+func (b *Body) NewReader() (io.ReadCloser, bool) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	if b.dropped || b.passthrough != nil {
+		return nil, false
+	}
+	r := &bodyReader{b: b}
+	b.readers[r] = struct{}{}
+	return r, true
+}
+
+// dropRead drops chunks held in memory which every reader has read. The b.m mutex must be locked.
+func (b *Body) dropRead() {
+	if len(b.mem) == 0 {
+		return
+	}
+	minIdx := b.count
+	for r := range b.readers {
+		if r.idx < minIdx {
+			minIdx = r.idx
+		}
+	}
+	for idx := range b.mem {
+		if idx < minIdx {
+			delete(b.mem, idx)
+			b.dropped = true
+		}
+	}
+}
+
+type bodyReader struct {
+	b     *Body
+	idx   int // the index of the next chunk to get
+	chunk []byte
+}
+
+func (r *bodyReader) Read(p []byte) (int, error) {
+	if len(r.chunk) == 0 {
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+}
+
+// next gets the next chunk, blocking until it's written.
+func (r *bodyReader) next() error {
+	b := r.b
+	b.m.Lock()
+	for r.idx >= b.count && !b.done {
+		b.cond.Wait()
+	}
+	if r.idx >= b.count {
+		err := b.err
+		b.m.Unlock()
+		if err != nil {
+			return errors.New("requesting parent: " + err.Error())
+		}
+		return io.EOF
+	}
+	idx := r.idx
+	chunk, inMem := b.mem[idx]
+	r.idx++
+	b.dropRead()
+	b.m.Unlock()
+
+	if !inMem {
+		ok := false
+		if chunk, ok = b.cache.GetChunk(b.storeKey, b.chunkID, idx); !ok {
+			return fmt.Errorf("chunk %v of '%v' was evicted before it was read", idx, b.storeKey)
+		}
+	}
+	r.chunk = chunk
+	return nil
+}
+
+func (r *bodyReader) Close() error {
+	b := r.b
+	b.m.Lock()
+	delete(b.readers, r)
+	b.dropRead()
+	b.m.Unlock()
+	return nil
+}
+
+// NewCacheReader returns a reader of the body of a Chunked object in the cache. It only reads the chunks of the given object, so if the object is replaced or removed before it's read, reading returns an error, rather than the body of another object.
+func NewCacheReader(cache icache.ChunkCache, key string, obj *cacheobj.CacheObj) io.ReadCloser {
+	return &cacheReader{cache: cache, key: key, id: obj.ChunkID, count: obj.ChunkCount}
+}
+
+type cacheReader struct {
+	cache icache.ChunkCache
+	key   string
+	id    uint64
+	count int
+	idx   int
+	chunk []byte
+}
+
+func (r *cacheReader) Read(p []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		if r.idx >= r.count {
+			return 0, io.EOF
+		}
+		chunk, ok := r.cache.GetChunk(r.key, r.id, r.idx)
+		if !ok {
+			return 0, fmt.Errorf("chunk %v of '%v' was evicted before it was read", r.idx, r.key)
+		}
+		r.chunk = chunk
+		r.idx++
+	}
+	n := copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+}
+
+func (r *cacheReader) Close() error { return nil }
+
+// passthroughBody is a body which isn't stored, and closes the closed chan when it's closed.
+type passthroughBody struct {
+	io.ReadCloser
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (b *passthroughBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.closeOnce.Do(func() { close(b.closed) })
+	return err
+}
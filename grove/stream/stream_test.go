@@ -0,0 +1,251 @@
+package stream
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/grove/cacheobj"
+	"github.com/apache/trafficcontrol/v8/grove/memcache"
+)
+
+// failChunkCache is a ChunkCache which fails to add any chunks.
+type failChunkCache struct {
+	*memcache.MemCache
+}
+
+func (c failChunkCache) AddChunk(key string, id uint64, idx int, chunk []byte) bool { return false }
+
+func testObj() *cacheobj.CacheObj {
+	now := time.Now()
+	obj := cacheobj.New(http.Header{}, nil, http.StatusOK, http.StatusOK, "", http.Header{}, now, now, now, now)
+	obj.Chunked = true
+	obj.ChunkID = cacheobj.NewChunkID()
+	return obj
+}
+
+func readAll(t *testing.T, r io.ReadCloser, wg *sync.WaitGroup, out *[]byte) {
+	defer wg.Done()
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Errorf("reading body: expected no error, actual: %v", err)
+	}
+	*out = b
+}
+
+func TestStreamsMultipleReaders(t *testing.T) {
+	cache := memcache.New(1024 * 1024)
+	streams := NewStreams()
+
+	body, isAuthor := streams.GetOrCreate("foo", cache)
+	if !isAuthor {
+		t.Fatalf("GetOrCreate new key: expected author, actual not author")
+	}
+	if _, isAuthor := streams.GetOrCreate("foo", cache); isAuthor {
+		t.Fatalf("GetOrCreate in-flight key: expected not author, actual author")
+	}
+
+	body.SetObj("foo", testObj())
+	if _, shared, err := body.WaitObj(); err != nil || !shared {
+		t.Fatalf("WaitObj: expected shared and no error, actual shared %v error %v", shared, err)
+	}
+
+	chunks := [][]byte{[]byte("abc"), []byte("def"), []byte("gh")}
+	expected := []byte("abcdefgh")
+
+	const numReaders = 5
+	wg := sync.WaitGroup{}
+	outs := make([][]byte, numReaders)
+	for i := 0; i < numReaders; i++ {
+		r, ok := body.NewReader()
+		if !ok {
+			t.Fatalf("NewReader: expected ok, actual not ok")
+		}
+		wg.Add(1)
+		go readAll(t, r, &wg, &outs[i])
+	}
+
+	for _, chunk := range chunks {
+		body.Write(chunk)
+	}
+	if !body.Stored() {
+		t.Errorf("Stored: expected true, actual false")
+	}
+	body.Finish(nil)
+	wg.Wait()
+
+	for i, out := range outs {
+		if !bytes.Equal(out, expected) {
+			t.Errorf("reader %v: expected body '%s', actual '%s'", i, expected, out)
+		}
+	}
+	if body.Count() != len(chunks) {
+		t.Errorf("Count: expected %v, actual %v", len(chunks), body.Count())
+	}
+	if body.Size() != uint64(len(expected)) {
+		t.Errorf("Size: expected %v, actual %v", len(expected), body.Size())
+	}
+
+	if _, isAuthor := streams.GetOrCreate("foo", cache); !isAuthor {
+		t.Errorf("GetOrCreate finished key: expected author, actual not author")
+	}
+}
+
+func TestStreamsCacheReader(t *testing.T) {
+	cache := memcache.New(1024 * 1024)
+	body := NewBody("foo", cache)
+	obj := testObj()
+	body.SetObj("foo", obj)
+	body.Write([]byte("abc"))
+	body.Write([]byte("def"))
+	body.Finish(nil)
+
+	obj.ChunkCount = body.Count()
+	obj.Size = body.Size()
+	cache.Add("foo", obj)
+	cached, ok := cache.Get("foo")
+	if !ok {
+		t.Fatalf("cache Get chunked object: expected ok, actual not ok")
+	}
+	r := NewCacheReader(cache, "foo", cached)
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading cache reader: expected no error, actual: %v", err)
+	}
+	if string(out) != "abcdef" {
+		t.Errorf("cache reader: expected body 'abcdef', actual '%s'", out)
+	}
+
+	cache.Remove("foo")
+	r = NewCacheReader(cache, "foo", cached)
+	if _, err := io.ReadAll(r); err == nil {
+		t.Errorf("reading removed object: expected error, actual nil")
+	}
+}
+
+func TestStreamsCacheReaderReplaced(t *testing.T) {
+	cache := memcache.New(1024 * 1024)
+	store := func(chunks ...string) *cacheobj.CacheObj {
+		body := NewBody("foo", cache)
+		obj := testObj()
+		body.SetObj("foo", obj)
+		for _, chunk := range chunks {
+			body.Write([]byte(chunk))
+		}
+		body.Finish(nil)
+		obj.ChunkCount = body.Count()
+		obj.Size = body.Size()
+		cache.Add("foo", obj)
+		return obj
+	}
+
+	old := store("abc", "def")
+	r := NewCacheReader(cache, "foo", old)
+	p := make([]byte, 3)
+	if n, err := r.Read(p); err != nil || string(p[:n]) != "abc" {
+		t.Fatalf("reading first chunk: expected 'abc', actual '%s' %v", p[:n], err)
+	}
+
+	// a new object is stored while the old one is read
+	body := NewBody("foo", cache)
+	body.SetObj("foo", testObj())
+	body.Write([]byte("ghi"))
+	if n, err := r.Read(p); err != nil || string(p[:n]) != "def" {
+		t.Errorf("reading stored object while a new one is stored: expected 'def', actual '%s' %v", p[:n], err)
+	}
+
+	store("jkl", "mno")
+	r = NewCacheReader(cache, "foo", old)
+	if out, err := io.ReadAll(r); err == nil {
+		t.Errorf("reading replaced object: expected error, actual body '%s'", out)
+	}
+}
+
+func TestStreamsNotStored(t *testing.T) {
+	cache := failChunkCache{memcache.New(1024 * 1024)}
+	body := NewBody("foo", cache)
+	body.SetObj("foo", testObj())
+
+	r0, ok := body.NewReader()
+	if !ok {
+		t.Fatalf("NewReader: expected ok, actual not ok")
+	}
+	r1, ok := body.NewReader()
+	if !ok {
+		t.Fatalf("NewReader: expected ok, actual not ok")
+	}
+
+	body.Write([]byte("abc"))
+	if body.Stored() {
+		t.Errorf("Stored with failing cache: expected false, actual true")
+	}
+
+	// chunks must be kept in memory until every reader has read them
+	buf := make([]byte, 3)
+	if _, err := io.ReadFull(r0, buf); err != nil || string(buf) != "abc" {
+		t.Fatalf("reader 0: expected 'abc', actual '%s' error %v", buf, err)
+	}
+	if r2, ok := body.NewReader(); !ok {
+		t.Errorf("NewReader before any chunk was read by every reader: expected ok, actual not ok")
+	} else {
+		r2.Close()
+	}
+	body.Write([]byte("def"))
+	body.Finish(nil)
+
+	out1, err := io.ReadAll(r1)
+	if err != nil || string(out1) != "abcdef" {
+		t.Errorf("reader 1: expected 'abcdef', actual '%s' error %v", out1, err)
+	}
+	out0, err := io.ReadAll(r0)
+	if err != nil || string(out0) != "def" {
+		t.Errorf("reader 0: expected 'def', actual '%s' error %v", out0, err)
+	}
+	r0.Close()
+	r1.Close()
+
+	if _, ok := body.NewReader(); ok {
+		t.Errorf("NewReader after in-memory chunks were dropped: expected not ok, actual ok")
+	}
+}
+
+func TestStreamsFail(t *testing.T) {
+	streams := NewStreams()
+	cache := memcache.New(1024 * 1024)
+	body, _ := streams.GetOrCreate("foo", cache)
+	body.Fail(errors.New("parent unreachable"))
+	if _, _, err := body.WaitObj(); err == nil {
+		t.Errorf("WaitObj after Fail: expected error, actual nil")
+	}
+	if _, isAuthor := streams.GetOrCreate("foo", cache); !isAuthor {
+		t.Errorf("GetOrCreate failed key: expected author, actual not author")
+	}
+
+	body = NewBody("bar", cache)
+	body.SetObj("foo", testObj())
+	r, _ := body.NewReader()
+	body.Write([]byte("abc"))
+	body.Finish(errors.New("parent closed connection"))
+	if _, err := io.ReadAll(r); err == nil {
+		t.Errorf("reading body finished with error: expected error, actual nil")
+	}
+}
@@ -39,6 +39,59 @@ func (l *throttler) Throttle(f func()) {
 	<-l.c
 }
 
+// ResizableThrottler is a Throttler whose max may be changed while it's in use. Calls already in progress are never interrupted, so if the max is reduced below the number in progress, new calls wait until enough finish.
+type ResizableThrottler interface {
+	Throttler
+	SetMax(max uint64)
+	Max() uint64
+}
+
+type resizableThrottler struct {
+	max     uint64
+	running uint64
+	m       sync.Mutex
+	cond    *sync.Cond
+}
+
+// NewResizableThrottler creates a ResizableThrottler allowing max concurrent calls. As with NewThrottler, a max of 0 doesn't throttle.
+func NewResizableThrottler(max uint64) ResizableThrottler {
+	t := &resizableThrottler{max: max}
+	t.cond = sync.NewCond(&t.m)
+	return t
+}
+
+func (t *resizableThrottler) Throttle(f func()) {
+	t.m.Lock()
+	for t.max > 0 && t.running >= t.max {
+		t.cond.Wait()
+	}
+	t.running++
+	t.m.Unlock()
+
+	defer t.release() // deferred, so a panicking f doesn't leak its slot
+	f()
+}
+
+func (t *resizableThrottler) release() {
+	t.m.Lock()
+	t.running--
+	t.m.Unlock()
+	t.cond.Signal()
+}
+
+func (t *resizableThrottler) SetMax(max uint64) {
+	t.m.Lock()
+	t.max = max
+	t.m.Unlock()
+	t.cond.Broadcast() // wake all waiters, in case the max increased
+}
+
+func (t *resizableThrottler) Max() uint64 {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return t.max
+}
+
 func NewThrottlers(max uint64) Throttlers {
 	return &throttlers{max: max, throttlers: map[string]Throttler{}}
 }
@@ -43,8 +43,8 @@ func (c *TierCache) Get(key string) (*cacheobj.CacheObj, bool) {
 	log.Debugf("TierCache.Get '"+key+"' FOUND FIRST: %+v\n", ok)
 	if !ok {
 		v, ok = c.second.Get(key)
-		if ok {
-			// if it was in second but not first, add back to first (LRU behavior)
+		if ok && !v.Chunked {
+			// if it was in second but not first, add back to first (LRU behavior). Chunked objects are only stored in the second.
 			c.first.Add(key, v)
 		}
 		log.Debugf("TierCache.Get '"+key+"' FOUND SECOND: %+v\n", ok)
@@ -62,13 +62,42 @@ func (c *TierCache) Peek(key string) (*cacheobj.CacheObj, bool) {
 	return v, ok
 }
 
-// Add adds to both internal caches. Returns whether either reported an eviction.
+// Add adds to both internal caches, or only the second if the object is Chunked. Returns whether either reported an eviction.
 func (c *TierCache) Add(key string, val *cacheobj.CacheObj) bool {
-	aevict := c.first.Add(key, val)
+	aevict := false
+	if val.Chunked {
+		c.first.Remove(key)
+	} else {
+		aevict = c.first.Add(key, val)
+	}
 	bevict := c.second.Add(key, val)
 	return aevict || bevict
 }
 
+// AddChunk adds the chunk to the second cache only, if it's an icache.ChunkCache, so large objects don't evict the frequently-requested objects in the first. The key is removed from the first cache when the Chunked object is added.
+func (c *TierCache) AddChunk(key string, id uint64, idx int, chunk []byte) bool {
+	second, ok := c.second.(icache.ChunkCache)
+	if !ok {
+		return false
+	}
+	return second.AddChunk(key, id, idx, chunk)
+}
+
+// GetChunk returns the chunk from the second cache. Chunks are never added to the first.
+func (c *TierCache) GetChunk(key string, id uint64, idx int) ([]byte, bool) {
+	second, ok := c.second.(icache.ChunkCache)
+	if !ok {
+		return nil, false
+	}
+	return second.GetChunk(key, id, idx)
+}
+
+// Remove removes the key from both internal caches.
+func (c *TierCache) Remove(key string) {
+	c.first.Remove(key)
+	c.second.Remove(key)
+}
+
 // Size returns the size of the second cache. This is because, since all objects are added to both, they are presumed to have the same content, and the second is presumed to be larger.
 //
 // For example, if the first is a memory cache and the second is a disk cache, it's most useful to report the size used on disk.
@@ -16,6 +16,7 @@ package web
 
 import (
 	"errors"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -145,6 +146,34 @@ func Respond(w http.ResponseWriter, code int, header http.Header, body []byte, c
 	return uint64(bytesWritten), err
 }
 
+// RespondStream writes the given code and header to the ResponseWriter, and then copies the body from the given reader, flushing after each write, so the client receives the body as it's read. If connectionClose, a Connection: Close header is also written. Returns the body bytes written, and any error.
+func RespondStream(w http.ResponseWriter, code int, header http.Header, body io.Reader, connectionClose bool) (uint64, error) {
+	dH := w.Header()
+	CopyHeaderTo(header, &dH)
+	if connectionClose {
+		dH.Add("Connection", "close")
+	}
+	w.WriteHeader(code)
+	bytesWritten := uint64(0)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			written, writeErr := w.Write(buf[:n])
+			bytesWritten += uint64(written)
+			if writeErr != nil {
+				return bytesWritten, writeErr
+			}
+			TryFlush(w)
+		}
+		if err == io.EOF {
+			return bytesWritten, nil
+		} else if err != nil {
+			return bytesWritten, errors.New("reading body: " + err.Error())
+		}
+	}
+}
+
 // ServeReqErr writes the appropriate response to the client, via given writer, for a generic request error. Returns the code sent, the body bytes written, and any write error.
 func ServeReqErr(w http.ResponseWriter) (int, uint64, error) {
 	code := http.StatusBadRequest
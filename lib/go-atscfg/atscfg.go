@@ -542,6 +542,17 @@ nodeFor:
 	}, nil
 }
 
+// GetTopologyPlacement returns information about the cachegroup's placement in
+// the topology, for generators of non-ATS config which need to know the tier of
+// a server for a Topology Delivery Service.
+func GetTopologyPlacement(cacheGroup tc.CacheGroupName, topology tc.TopologyV5, cacheGroups []tc.CacheGroupNullableV5, ds *DeliveryService) (TopologyPlacement, error) {
+	cgMap, err := makeCGMap(cacheGroups)
+	if err != nil {
+		return TopologyPlacement{}, errors.New("making cachegroup map: " + err.Error())
+	}
+	return getTopologyPlacement(cacheGroup, topology, cgMap, ds)
+}
+
 func makeTopologyNameMap(topologies []tc.TopologyV5) map[TopologyName]tc.TopologyV5 {
 	topoNames := map[TopologyName]tc.TopologyV5{}
 	for _, to := range topologies {
@@ -0,0 +1,72 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"time"
+)
+
+// ConfigDriftStatus is how a cache server's config file on disk differs from the config generated from Traffic Ops.
+type ConfigDriftStatus string
+
+const (
+	// ConfigDriftModified is the status of a config file whose contents on disk differ from the generated config, ignoring comments.
+	ConfigDriftModified = ConfigDriftStatus("MODIFIED")
+	// ConfigDriftMissing is the status of a generated config file which doesn't exist on disk.
+	ConfigDriftMissing = ConfigDriftStatus("MISSING")
+)
+
+// ConfigDriftFile is a config file of a cache server which has drifted from the config generated from Traffic Ops.
+type ConfigDriftFile struct {
+	// Name is the name of the file.
+	Name string `json:"name"`
+	// Path is the directory of the file on the cache server.
+	Path string `json:"path"`
+	// Status is how the file differs from the generated config.
+	Status ConfigDriftStatus `json:"status"`
+}
+
+// ServerConfigDriftV5 is the config drift of a cache server, as last reported by 't3c-apply --check-only --report', as of Traffic Ops API v5.
+type ServerConfigDriftV5 struct {
+	// ServerID is the ID of the cache server. It is ignored in requests.
+	ServerID int `json:"serverId" db:"server_id"`
+	// HostName is the host name of the cache server. It is ignored in requests.
+	HostName string `json:"hostName" db:"host_name"`
+	// CDNName is the name of the CDN of the cache server. It is ignored in requests.
+	CDNName string `json:"cdnName" db:"cdn_name"`
+	// Drifted is whether any of the cache server's config files have drifted. It is ignored in requests.
+	Drifted bool `json:"drifted" db:"drifted"`
+	// Files are the config files which have drifted, or empty if none have.
+	Files []ConfigDriftFile `json:"files" db:"files"`
+	// LastChecked is when the cache server last reported its config drift. It is ignored in requests.
+	LastChecked time.Time `json:"lastChecked" db:"last_checked"`
+}
+
+// ServerConfigDriftResponseV5 is the type of a response from Traffic Ops to a PUT request to /servers/{id-or-name}/config_drift.
+type ServerConfigDriftResponseV5 struct {
+	Response ServerConfigDriftV5 `json:"response"`
+	Alerts
+}
+
+// ServerConfigDriftsResponseV5 is the type of a response from Traffic Ops to a GET request to /config_drift.
+type ServerConfigDriftsResponseV5 struct {
+	Response []ServerConfigDriftV5 `json:"response"`
+	Alerts
+}
@@ -0,0 +1,97 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"time"
+)
+
+// ConfigRolloutStatus is the state of a ConfigRolloutV5.
+type ConfigRolloutStatus string
+
+const (
+	// ConfigRolloutRunning is the status of a rollout which is queueing updates on its waves.
+	ConfigRolloutRunning = ConfigRolloutStatus("RUNNING")
+	// ConfigRolloutHalted is the status of a rollout which was stopped, by a user or because cache health degraded,
+	// and will not queue any more updates until it's resumed.
+	ConfigRolloutHalted = ConfigRolloutStatus("HALTED")
+	// ConfigRolloutSucceeded is the status of a rollout whose servers have all applied their updates.
+	ConfigRolloutSucceeded = ConfigRolloutStatus("SUCCEEDED")
+)
+
+// ConfigRolloutWave is a single wave of a ConfigRolloutV5: the servers to queue updates on together.
+// Exactly one of PerCacheGroup and Percent must be set.
+type ConfigRolloutWave struct {
+	// PerCacheGroup is the number of servers in each Cache Group to queue updates on in the wave.
+	PerCacheGroup *int `json:"perCacheGroup,omitempty"`
+	// Percent is the percent of all the rollout's servers which will have been queued once the wave is queued, including servers queued in previous waves.
+	Percent *float64 `json:"percent,omitempty"`
+}
+
+// ConfigRolloutV5 is a staged rollout of config updates to the cache servers of a CDN, as of Traffic Ops API v5.
+//
+// Updates are queued on the servers of each wave in turn. The next wave is only queued once every server of the current wave has
+// applied its update, and Traffic Monitor still reports them available. If a server fails to apply its update, too many servers
+// become unavailable, or a wave takes longer than its timeout, the rollout is halted.
+type ConfigRolloutV5 struct {
+	ID int `json:"id" db:"id"`
+	// CDNID is the CDN whose cache servers the rollout queues updates on.
+	CDNID int `json:"cdnId" db:"cdn_id"`
+	// CDNName is the name of the CDN. It is ignored in requests.
+	CDNName string `json:"cdnName" db:"cdn_name"`
+	// CacheGroupID optionally limits the rollout to the cache servers in a single Cache Group.
+	CacheGroupID *int `json:"cacheGroupId" db:"cachegroup_id"`
+	// Waves are the waves to queue updates in. The last wave must be 100 percent.
+	Waves []ConfigRolloutWave `json:"waves" db:"waves"`
+	// WaveTimeoutSeconds is how long a wave's servers have to apply their updates before the rollout is halted. If 0, waves never time out.
+	WaveTimeoutSeconds int `json:"waveTimeoutSeconds" db:"wave_timeout_seconds"`
+	// MaxUnhealthy is how many of the rollout's servers which were available when queued may become unavailable before the rollout is halted.
+	MaxUnhealthy int `json:"maxUnhealthy" db:"max_unhealthy"`
+
+	// CurrentWave is the index in Waves of the wave being rolled out. It is ignored in requests.
+	CurrentWave int `json:"currentWave" db:"current_wave"`
+	// WaveStartTime is when the current wave was queued. It is ignored in requests.
+	WaveStartTime time.Time `json:"waveStartTime" db:"wave_start_time"`
+	// Status is the state of the rollout. It is ignored in requests.
+	Status ConfigRolloutStatus `json:"status" db:"status"`
+	// Message is why the rollout has its Status, such as why it was halted. It is ignored in requests.
+	Message string `json:"message" db:"message"`
+	// Username is the user who started the rollout. It is ignored in requests.
+	Username string `json:"username" db:"username"`
+	// QueuedServers is how many of the rollout's servers have had updates queued. It is ignored in requests.
+	QueuedServers int `json:"queuedServers" db:"queued_servers"`
+	// TotalServers is how many servers the rollout queues updates on. It is ignored in requests.
+	TotalServers int `json:"totalServers" db:"total_servers"`
+	// StartTime is when the rollout was created. It is ignored in requests.
+	StartTime   time.Time `json:"startTime" db:"start_time"`
+	LastUpdated time.Time `json:"lastUpdated" db:"last_updated"`
+}
+
+// ConfigRolloutResponseV5 is the type of a response from Traffic Ops to a request to create or change a single ConfigRolloutV5.
+type ConfigRolloutResponseV5 struct {
+	Response ConfigRolloutV5 `json:"response"`
+	Alerts
+}
+
+// ConfigRolloutsResponseV5 is the type of a response from Traffic Ops to a GET request to /config_rollouts.
+type ConfigRolloutsResponseV5 struct {
+	Response []ConfigRolloutV5 `json:"response"`
+	Alerts
+}
@@ -0,0 +1,99 @@
+package nginxcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// configureAccessControl returns the http context directives setting
+// $tc_denied for requests the cache must refuse, as ip_allow.yaml does for ATS.
+// Edges only restrict PUSH, PURGE and DELETE to the purge allow list, while
+// mids also only accept requests from their child caches.
+func (cb *ConfBuilder) configureAccessControl() ([]string, []string, error) {
+	warnings := make([]string, 0)
+	allowAllIPs := append([]string{"127.0.0.1", "::1"}, atscfg.GetPurgeIPs(cb.toData.ServerParams)...)
+	lines := makeGeo("$tc_allow_all", allowAllIPs)
+
+	if cb.toData.Server.Type != tc.CacheTypeMid.String() {
+		lines = append(lines, makeRestrictedMethodsMap("PUSH", "PURGE", "DELETE")...)
+		lines = append(lines, []string{
+			`map "$tc_restricted_method$tc_allow_all" $tc_denied {`,
+			`	default 0;`,
+			`	"10" 1;`,
+			`}`,
+		}...)
+		return lines, warnings, nil
+	}
+
+	coalesceMaskLenV4, coalesceNumberV4, coalesceMaskLenV6, coalesceNumberV6, ws := atscfg.GetCoalesceMaskAndNumber(cb.toData.ServerParams)
+	warnings = append(warnings, ws...)
+	cidrs, cidr6s, ws, err := atscfg.GetAllowedCIDRsForMid(
+		cb.toData.Server,
+		cb.toData.Servers,
+		cb.toData.CacheGroups,
+		cb.toData.Topologies,
+		coalesceNumberV4,
+		coalesceMaskLenV4,
+		coalesceNumberV6,
+		coalesceMaskLenV6,
+	)
+	warnings = append(warnings, ws...)
+	if err != nil {
+		return nil, warnings, err
+	}
+	childIPs := make([]string, 0, len(cidrs)+len(cidr6s)+3)
+	for _, cidr := range append(cidrs, cidr6s...) {
+		childIPs = append(childIPs, cidr.String())
+	}
+	childIPs = append(childIPs, "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16")
+
+	lines = append(lines, makeGeo("$tc_allow_children", childIPs)...)
+	lines = append(lines, makeRestrictedMethodsMap("PUSH", "PURGE")...)
+	lines = append(lines, []string{
+		`map "$tc_restricted_method$tc_allow_all$tc_allow_children" $tc_denied {`,
+		`	default 0;`,
+		// push and purge are only allowed from the allow all list
+		`	~^10 1;`,
+		// mids only accept requests from their children and the allow all list
+		`	~^.00$ 1;`,
+		`}`,
+	}...)
+	return lines, warnings, nil
+}
+
+// makeGeo returns a geo block setting the variable to 1 for the given IPs and CIDRs.
+func makeGeo(variable string, ips []string) []string {
+	lines := []string{"geo " + variable + " {", "\tdefault 0;"}
+	for _, ip := range ips {
+		lines = append(lines, "\t"+ip+" 1;")
+	}
+	return append(lines, "}")
+}
+
+// makeRestrictedMethodsMap returns a map setting $tc_restricted_method to 1 for the given methods.
+func makeRestrictedMethodsMap(methods ...string) []string {
+	lines := []string{"map $request_method $tc_restricted_method {", "\tdefault 0;"}
+	for _, method := range methods {
+		lines = append(lines, "\t"+method+" 1;")
+	}
+	return append(lines, "}")
+}
@@ -0,0 +1,78 @@
+package nginxcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+func TestConfigureAccessControl(t *testing.T) {
+	edge := atscfg.Server{}
+	edge.Type = tc.EdgeTypePrefix
+	purgeParams := []tc.ParameterV5{{Name: atscfg.ParamPurgeAllowIP, ConfigFile: atscfg.IPAllowConfigFileName, Value: "192.0.2.1,192.0.2.2"}}
+
+	cb := NewConfBuilder(&t3cutil.ConfigData{Server: &edge, ServerParams: purgeParams}, "/ssl")
+	lines, _, err := cb.configureAccessControl()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	txt := strings.Join(lines, "\n")
+	for _, expected := range []string{
+		"geo $tc_allow_all {\n\tdefault 0;\n\t127.0.0.1 1;\n\t::1 1;\n\t192.0.2.1 1;\n\t192.0.2.2 1;\n}",
+		"\tDELETE 1;",
+		`map "$tc_restricted_method$tc_allow_all" $tc_denied {`,
+	} {
+		if !strings.Contains(txt, expected) {
+			t.Errorf("expected edge access control to contain:\n%s\nactual:\n%s", expected, txt)
+		}
+	}
+	if strings.Contains(txt, "$tc_allow_children") {
+		t.Errorf("expected edge access control not to restrict children, actual:\n%s", txt)
+	}
+
+	mid := atscfg.Server{}
+	mid.Type = tc.MidTypePrefix
+	mid.CacheGroup = "midCG"
+	cgs := []tc.CacheGroupNullableV5{{Name: util.Ptr("midCG"), Type: util.Ptr(tc.CacheGroupMidTypeName)}}
+	cb = NewConfBuilder(&t3cutil.ConfigData{Server: &mid, CacheGroups: cgs}, "/ssl")
+	lines, _, err = cb.configureAccessControl()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	txt = strings.Join(lines, "\n")
+	for _, expected := range []string{
+		"geo $tc_allow_children {",
+		"\t10.0.0.0/8 1;",
+		`map "$tc_restricted_method$tc_allow_all$tc_allow_children" $tc_denied {`,
+	} {
+		if !strings.Contains(txt, expected) {
+			t.Errorf("expected mid access control to contain:\n%s\nactual:\n%s", expected, txt)
+		}
+	}
+	if strings.Contains(txt, "DELETE") {
+		t.Errorf("expected mid access control not to restrict DELETE, actual:\n%s", txt)
+	}
+}
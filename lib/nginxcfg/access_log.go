@@ -0,0 +1,161 @@
+package nginxcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// logDir is the directory nginx writes access logs to.
+const logDir = "/var/log/nginx"
+
+// logFormatName is the name of the generated log_format.
+const logFormatName = "tc"
+
+// logVariables are the nginx variable equivalents of ATS log fields.
+var logVariables = map[string]string{
+	"chi":   "$remote_addr",
+	"caun":  "$remote_user",
+	"cqtq":  "$msec",
+	"cqtn":  "$time_local",
+	"cqhm":  "$request_method",
+	"cqhv":  "$server_protocol",
+	"cqtx":  "$request",
+	"cquc":  "$scheme://$host$request_uri",
+	"cquuc": "$scheme://$host$request_uri",
+	"cqu":   "$request_uri",
+	"cqup":  "$uri",
+	"cquup": "$uri",
+	"cqql":  "$request_length",
+	"pssc":  "$status",
+	"pscl":  "$body_bytes_sent",
+	"psql":  "$bytes_sent",
+	"psct":  "$sent_http_content_type",
+	"ttms":  "$request_time",
+	"crc":   "$upstream_cache_status",
+	"shn":   "$upstream_addr",
+	"sssc":  "$upstream_status",
+	"phn":   "$hostname",
+	"php":   "$server_port",
+}
+
+var logFieldRe = regexp.MustCompile(`%<([^>]*)>`)
+var logHeaderFieldRe = regexp.MustCompile(`^\{([^}]+)\}(cqh|psh|ssh)$`)
+
+// logHeaderPrefixes are the nginx variable prefixes of ATS header log fields.
+var logHeaderPrefixes = map[string]string{
+	"cqh": "$http_",
+	"psh": "$sent_http_",
+	"ssh": "$upstream_http_",
+}
+
+// configureAccessLog returns the log_format and access_log directives of the
+// first logging.yaml log object Parameters. Fields without an nginx variable
+// are logged as "-". Note the ATS ttms field is logged in seconds, with
+// millisecond resolution.
+func (cb *ConfBuilder) configureAccessLog() ([]string, []string) {
+	warnings := make([]string, 0)
+	params := getParamValues(cb.toData.ServerParams, atscfg.LoggingYAMLFileName)
+
+	fileName := params["LogObject.Filename"]
+	if fileName == "" {
+		return nil, warnings
+	}
+	for i := 1; i < atscfg.MaxLogObjects; i++ {
+		if name := params["LogObject"+strconv.Itoa(i)+".Filename"]; name != "" {
+			warnings = append(warnings, fmt.Sprintf("only the first log object is written by nginx, ignoring log object '%s'", name))
+		}
+	}
+	if params["LogObject.Filters"] != "" {
+		warnings = append(warnings, "log object filters are not supported by nginx, logging all requests")
+	}
+
+	formatName := params["LogObject.Format"]
+	format := ""
+	for i := 0; i < atscfg.MaxLogObjects; i++ {
+		field := "LogFormat"
+		if i > 0 {
+			field += strconv.Itoa(i)
+		}
+		if params[field+".Name"] == formatName {
+			format = params[field+".Format"]
+			break
+		}
+	}
+	accessLog := "access_log " + filepath.Join(logDir, fileName+".log")
+	if format == "" {
+		warnings = append(warnings, fmt.Sprintf("log format '%s' not found, using nginx default format", formatName))
+		return []string{accessLog + ";"}, warnings
+	}
+
+	unsupported := make(map[string]struct{})
+	format = logFieldRe.ReplaceAllStringFunc(format, func(field string) string {
+		name := field[2 : len(field)-1]
+		if variable, ok := logVariables[name]; ok {
+			return variable
+		}
+		if match := logHeaderFieldRe.FindStringSubmatch(name); match != nil {
+			return logHeaderPrefixes[match[2]] + strings.ToLower(strings.ReplaceAll(match[1], "-", "_"))
+		}
+		unsupported[name] = struct{}{}
+		return "-"
+	})
+	if len(unsupported) > 0 {
+		names := make([]string, 0, len(unsupported))
+		for name := range unsupported {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		warnings = append(warnings, fmt.Sprintf("log fields not supported by nginx, logging '-': %s", strings.Join(names, ", ")))
+	}
+
+	format = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(format)
+	return []string{
+		fmt.Sprintf("log_format %s '%s';", logFormatName, format),
+		fmt.Sprintf("%s %s;", accessLog, logFormatName),
+	}, warnings
+}
+
+// getParamValues returns the values of the Parameters with the given config
+// file, by name. If a name is repeated, the lowest value is used, so the result
+// doesn't depend on the order of the Parameters.
+func getParamValues(params []tc.ParameterV5, configFile string) map[string]string {
+	values := make(map[string][]string)
+	for _, param := range params {
+		if param.ConfigFile != configFile {
+			continue
+		}
+		values[param.Name] = append(values[param.Name], param.Value)
+	}
+	paramValues := make(map[string]string, len(values))
+	for name, vals := range values {
+		sort.Strings(vals)
+		paramValues[name] = vals[0]
+	}
+	return paramValues
+}
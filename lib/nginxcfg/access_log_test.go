@@ -0,0 +1,88 @@
+package nginxcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+func makeLoggingParams(format string) []tc.ParameterV5 {
+	return []tc.ParameterV5{
+		{Name: "LogFormat.Name", ConfigFile: atscfg.LoggingYAMLFileName, Value: "custom"},
+		{Name: "LogFormat.Format", ConfigFile: atscfg.LoggingYAMLFileName, Value: format},
+		{Name: "LogObject.Filename", ConfigFile: atscfg.LoggingYAMLFileName, Value: "custom_ats_2"},
+		{Name: "LogObject.Format", ConfigFile: atscfg.LoggingYAMLFileName, Value: "custom"},
+	}
+}
+
+func TestConfigureAccessLog(t *testing.T) {
+	tests := []struct {
+		name             string
+		params           []tc.ParameterV5
+		expected         []string
+		expectedWarnings int
+	}{
+		{
+			name:   "fields and headers",
+			params: makeLoggingParams(`%<cqtq> chi=%<chi> ua="%<{User-Agent}cqh>" ct=%<{Content-Type}ssh> 'ttms=%<ttms>'`),
+			expected: []string{
+				`log_format tc '$msec chi=$remote_addr ua="$http_user_agent" ct=$upstream_http_content_type \'ttms=$request_time\'';`,
+				"access_log /var/log/nginx/custom_ats_2.log tc;",
+			},
+		},
+		{
+			name:   "unsupported field",
+			params: makeLoggingParams("%<chi> %<sscl>"),
+			expected: []string{
+				"log_format tc '$remote_addr -';",
+				"access_log /var/log/nginx/custom_ats_2.log tc;",
+			},
+			expectedWarnings: 1,
+		},
+		{
+			name:             "missing format",
+			params:           makeLoggingParams("")[2:],
+			expected:         []string{"access_log /var/log/nginx/custom_ats_2.log;"},
+			expectedWarnings: 1,
+		},
+		{
+			name:   "no log object",
+			params: makeLoggingParams("%<chi>")[:2],
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cb := NewConfBuilder(&t3cutil.ConfigData{ServerParams: tc.params}, "/ssl")
+			actual, warnings := cb.configureAccessLog()
+			if !reflect.DeepEqual(tc.expected, actual) {
+				t.Errorf("expected %q, got %q", tc.expected, actual)
+			}
+			if len(warnings) != tc.expectedWarnings {
+				t.Errorf("expected %d warnings, got %v", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+// Package nginxcfg manages generating configuration files
+// for nginx caches using data from Traffic Ops APIs.
+package nginxcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+)
+
+// ConfigFileName is the name of the generated nginx config file. It contains
+// http context directives, and is meant to be included in the http block of
+// nginx.conf, as the distribution nginx.conf does for conf.d/*.conf.
+const ConfigFileName = "trafficcontrol.conf"
+
+// ConfigSubDir is the directory under the nginx config directory that
+// ConfigFileName is written to.
+const ConfigSubDir = "conf.d"
+
+// cacheZone is the name of the proxy_cache shared memory zone.
+const cacheZone = "tc"
+
+// cacheDir is the directory cached content is stored in.
+const cacheDir = "/var/cache/nginx/trafficcontrol"
+
+// ConfBuilder builds the nginx config file using TO data.
+type ConfBuilder struct {
+	toData *t3cutil.ConfigData
+	sslDir string
+}
+
+// NewConfBuilder returns a new ConfBuilder object. The sslDir is the directory
+// Delivery Service certificates and keys are written to.
+func NewConfBuilder(toData *t3cutil.ConfigData, sslDir string) ConfBuilder {
+	return ConfBuilder{
+		toData: toData,
+		sslDir: sslDir,
+	}
+}
+
+// BuildConfFile builds the nginx config file.
+func (cb *ConfBuilder) BuildConfFile() (string, []string, error) {
+	warnings := make([]string, 0)
+	lines := make([]string, 0)
+
+	logLines, logWarnings := cb.configureAccessLog()
+	warnings = append(warnings, logWarnings...)
+	lines = append(lines, logLines...)
+
+	lines = append(lines, fmt.Sprintf("proxy_cache_path %s levels=1:2 keys_zone=%s:100m inactive=1d use_temp_path=off;", cacheDir, cacheZone))
+
+	aclLines, aclWarnings, err := cb.configureAccessControl()
+	warnings = append(warnings, aclWarnings...)
+	if err != nil {
+		return "", nil, fmt.Errorf("(warnings: %s) %w", strings.Join(warnings, ", "), err)
+	}
+	lines = append(lines, aclLines...)
+
+	atsMajorVersion := uint(9)
+
+	parents, dataWarns, err := atscfg.MakeParentDotConfigData(
+		cb.toData.DeliveryServices,
+		cb.toData.Server,
+		cb.toData.Servers,
+		cb.toData.Topologies,
+		cb.toData.ServerParams,
+		cb.toData.ParentConfigParams,
+		cb.toData.ServerCapabilities,
+		cb.toData.DSRequiredCapabilities,
+		cb.toData.CacheGroups,
+		cb.toData.DeliveryServiceServers,
+		cb.toData.CDN,
+		&atscfg.ParentConfigOpts{},
+		atsMajorVersion,
+	)
+	warnings = append(warnings, dataWarns...)
+	if err != nil {
+		return "", nil, fmt.Errorf("(warnings: %s) %w", strings.Join(warnings, ", "), err)
+	}
+
+	for _, svc := range parents.Services {
+		upstreamLines, upstreamWarnings := configureUpstreams(svc)
+		warnings = append(warnings, upstreamWarnings...)
+		if len(upstreamLines) == 0 {
+			continue
+		}
+		serverLines, serverWarnings := cb.configureServer(svc)
+		warnings = append(warnings, serverWarnings...)
+		if len(serverLines) == 0 {
+			continue
+		}
+		lines = append(lines, upstreamLines...)
+		lines = append(lines, serverLines...)
+	}
+
+	return strings.Join(lines, "\n") + "\n", warnings, nil
+}
+
+// getUpstreamName returns the name of the upstream of the service.
+func getUpstreamName(svc *atscfg.ParentAbstractionService) string {
+	return "tc_" + strings.NewReplacer(".", "_", "-", "_").Replace(svc.Name)
+}
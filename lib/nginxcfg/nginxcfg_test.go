@@ -0,0 +1,112 @@
+package nginxcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+func TestBuildConfFile(t *testing.T) {
+	// an edge with an HTTP and HTTPS topology Delivery Service, whose edge
+	// Cache Group has a primary and a secondary parent Cache Group
+	servers := make([]atscfg.Server, 3)
+	for i, name := range []string{"edge0", "mid0", "mid1"} {
+		servers[i].ID = i + 1
+		servers[i].HostName = name
+		servers[i].DomainName = "example.net"
+		servers[i].CacheGroup = "midCG" + strconv.Itoa(i-1)
+		servers[i].CDN = "mycdn"
+		servers[i].Profiles = []string{"serverprofile"}
+		servers[i].TCPPort = util.Ptr(80)
+		servers[i].Type = tc.MidTypePrefix
+		servers[i].Status = string(tc.CacheStatusReported)
+	}
+	servers[0].CacheGroup = "edgeCG"
+	servers[0].Type = tc.EdgeTypePrefix
+	cgs := []tc.CacheGroupNullableV5{
+		{ID: util.Ptr(1), Name: util.Ptr("edgeCG"), Type: util.Ptr(tc.CacheGroupEdgeTypeName)},
+		{ID: util.Ptr(2), Name: util.Ptr("midCG0"), Type: util.Ptr(tc.CacheGroupMidTypeName)},
+		{ID: util.Ptr(3), Name: util.Ptr("midCG1"), Type: util.Ptr(tc.CacheGroupMidTypeName)},
+	}
+
+	ds := atscfg.DeliveryService{}
+	ds.ID = util.Ptr(10)
+	ds.XMLID = "ds0"
+	ds.Type = util.Ptr(string(tc.DSTypeHTTP))
+	ds.Protocol = util.Ptr(2)
+	ds.ExampleURLs = []string{"https://ds0.mycdn.example.net"}
+	ds.OrgServerFQDN = util.Ptr("http://origin.example.net")
+	ds.QStringIgnore = util.Ptr(int(tc.QStringIgnoreIgnoreInCacheKeyAndPassUp))
+	ds.Topology = util.Ptr("topo0")
+
+	cb := NewConfBuilder(&t3cutil.ConfigData{
+		Server:           &servers[0],
+		Servers:          servers,
+		DeliveryServices: []atscfg.DeliveryService{ds},
+		DeliveryServiceRegexes: []tc.DeliveryServiceRegexes{{
+			DSName:  "ds0",
+			Regexes: []tc.DeliveryServiceRegex{{Type: string(tc.DSMatchTypeHostRegex), Pattern: `.*\.ds0\..*`}},
+		}},
+		CacheGroups: cgs,
+		CDN:         &tc.CDNV5{Name: "mycdn", DomainName: "mycdn.example.net"},
+		ParentConfigParams: []tc.ParameterV5{{
+			Name:       atscfg.ParentConfigRetryKeysDefault.Algorithm,
+			ConfigFile: "parent.config",
+			Value:      tc.AlgorithmConsistentHash,
+			Profiles:   []byte(`["serverprofile"]`),
+		}},
+		Topologies: []tc.TopologyV5{{
+			Name: "topo0",
+			Nodes: []tc.TopologyNodeV5{
+				{Cachegroup: "edgeCG", Parents: []int{1, 2}},
+				{Cachegroup: "midCG0"},
+				{Cachegroup: "midCG1"},
+			},
+		}},
+	}, "/etc/nginx/ssl/")
+	txt, warnings, err := cb.BuildConfFile()
+	if err != nil {
+		t.Fatalf("expected no error, got %v (warnings %v)", err, warnings)
+	}
+
+	for _, expected := range []string{
+		"proxy_cache_path /var/cache/nginx/trafficcontrol levels=1:2 keys_zone=tc:100m inactive=1d use_temp_path=off;\n",
+		"map \"$tc_restricted_method$tc_allow_all\" $tc_denied {\n",
+		"upstream tc_ds0 {\n\thash $uri consistent;\n\tserver mid0.example.net:80;\n}\n",
+		"upstream tc_ds0_secondary {\n\thash $uri consistent;\n\tserver mid1.example.net:80;\n}\n",
+		"\tlisten 80;\n\tlisten 443 ssl;\n",
+		"\tssl_certificate /etc/nginx/ssl/ds0_mycdn_example_net_cert.cer;\n\tssl_certificate_key /etc/nginx/ssl/ds0.mycdn.example.net.key;\n",
+		"\tserver_name edge0.ds0.mycdn.example.net;\n",
+		"\t\tproxy_pass http://tc_ds0;\n\t\tproxy_set_header Host origin.example.net;\n\t\tproxy_cache tc;\n\t\tproxy_cache_key ds0$uri;\n",
+		"\t\terror_page 502 504 = @tc_ds0_secondary;\n",
+		"\tlocation @tc_ds0_secondary {\n\t\tproxy_pass http://tc_ds0_secondary;\n",
+	} {
+		if !strings.Contains(txt, expected) {
+			t.Errorf("expected config to contain:\n%s\nactual:\n%s", expected, txt)
+		}
+	}
+}
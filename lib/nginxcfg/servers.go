@@ -0,0 +1,187 @@
+package nginxcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// Delivery Service protocols, which are not named in lib/go-tc.
+const (
+	protocolHTTP        = 0
+	protocolHTTPS       = 1
+	protocolHTTPToHTTPS = 3
+)
+
+// configureServer returns the server block of the service's Delivery Service.
+func (cb *ConfBuilder) configureServer(svc *atscfg.ParentAbstractionService) ([]string, []string) {
+	warnings := make([]string, 0)
+	isEdge := cb.toData.Server.Type == tc.CacheTypeEdge.String()
+
+	requestFQDNs := []string{svc.DestDomain}
+	protocol := protocolHTTP
+	if isEdge {
+		dsRegexes := atscfg.MakeDSRegexMap(cb.toData.DeliveryServiceRegexes)
+		anyCastPartners := atscfg.GetAnyCastPartners(cb.toData.Server, cb.toData.Servers)
+		var err error
+		requestFQDNs, err = atscfg.GetDSRequestFQDNs(
+			&svc.DS,
+			dsRegexes[tc.DeliveryServiceName(svc.DS.XMLID)],
+			cb.toData.Server,
+			anyCastPartners,
+			cb.toData.CDN.DomainName,
+		)
+		if err != nil {
+			warnings = append(warnings, "error getting ds '"+svc.DS.XMLID+"' request fqdns, skipping! Error: "+err.Error())
+			return nil, warnings
+		}
+		// mids are only requested over http by the edges
+		if svc.DS.Protocol != nil {
+			protocol = *svc.DS.Protocol
+		}
+	}
+	if len(requestFQDNs) == 0 {
+		warnings = append(warnings, "ds '"+svc.DS.XMLID+"' has no request fqdns, skipping!")
+		return nil, warnings
+	}
+
+	lines := []string{"server {"}
+	if protocol != protocolHTTPS {
+		lines = append(lines, fmt.Sprintf("\tlisten %d;", cb.getPort(cb.toData.Server.TCPPort, 80)))
+	}
+	if protocol != protocolHTTP {
+		sslLines, err := cb.makeSSLLines(svc)
+		if err != nil {
+			warnings = append(warnings, "ds '"+svc.DS.XMLID+"' "+err.Error()+", skipping!")
+			return nil, warnings
+		}
+		lines = append(lines, fmt.Sprintf("\tlisten %d ssl;", cb.getPort(cb.toData.Server.HTTPSPort, 443)))
+		lines = append(lines, sslLines...)
+	}
+	lines = append(lines, []string{
+		"\tserver_name " + strings.Join(requestFQDNs, " ") + ";",
+		"\tif ($tc_denied) {",
+		"\t\treturn 405;",
+		"\t}",
+	}...)
+	if protocol == protocolHTTPToHTTPS {
+		lines = append(lines, []string{
+			"\tif ($scheme = http) {",
+			"\t\treturn 301 https://$host$request_uri;",
+			"\t}",
+		}...)
+	}
+
+	proxyLines, proxyWarnings := makeProxyLines(svc)
+	warnings = append(warnings, proxyWarnings...)
+	name := getUpstreamName(svc)
+	hasSecondary := len(svc.Parents) != 0 && len(svc.SecondaryParents) != 0
+
+	lines = append(lines, "\tlocation / {")
+	if isEdge && svc.DS.QStringIgnore != nil && *svc.DS.QStringIgnore == tc.QueryStringIgnoreDropAtEdge {
+		// a trailing '?' drops the query string
+		lines = append(lines, "\t\trewrite ^(.*)$ $1? break;")
+	}
+	lines = append(lines, fmt.Sprintf("\t\tproxy_pass %s://%s;", getUpstreamScheme(svc), name))
+	lines = append(lines, indent(proxyLines, 2)...)
+	if hasSecondary {
+		// nginx errors connecting to the primary parents fall back to the secondary parents
+		lines = append(lines, fmt.Sprintf("\t\terror_page 502 504 = @%s_secondary;", name))
+	}
+	lines = append(lines, "\t}")
+
+	if hasSecondary {
+		lines = append(lines, fmt.Sprintf("\tlocation @%s_secondary {", name))
+		lines = append(lines, fmt.Sprintf("\t\tproxy_pass %s://%s_secondary;", getUpstreamScheme(svc), name))
+		lines = append(lines, indent(proxyLines, 2)...)
+		lines = append(lines, "\t}")
+	}
+	lines = append(lines, "}")
+	return lines, warnings
+}
+
+// makeProxyLines returns the proxy directives shared by the locations of the
+// service, for the origin host, caching and retries.
+func makeProxyLines(svc *atscfg.ParentAbstractionService) ([]string, []string) {
+	lines := []string{fmt.Sprintf("proxy_set_header Host %s;", svc.DestDomain)}
+	if getUpstreamScheme(svc) == "https" {
+		lines = append(lines, "proxy_ssl_server_name on;", fmt.Sprintf("proxy_ssl_name %s;", svc.DestDomain))
+	}
+
+	if svc.DS.Type != nil && tc.DSType(*svc.DS.Type) == tc.DSTypeHTTPNoCache {
+		lines = append(lines, "proxy_cache off;")
+	} else {
+		// the key is prefixed with the DS, so it's the same for the primary and secondary upstreams
+		key := svc.DS.XMLID + "$uri$is_args$args"
+		if svc.DS.QStringIgnore != nil && *svc.DS.QStringIgnore == tc.QueryStringIgnoreIgnoreInCacheKeyAndPassUp {
+			key = svc.DS.XMLID + "$uri"
+		}
+		lines = append(lines, fmt.Sprintf("proxy_cache %s;", cacheZone), fmt.Sprintf("proxy_cache_key %s;", key))
+	}
+
+	nextUpstreamLines, warnings := makeNextUpstream(svc)
+	return append(lines, nextUpstreamLines...), warnings
+}
+
+// getUpstreamScheme returns the scheme requests to the service parents are
+// made with. Parent caches are always requested over http, origins over the
+// scheme of the Delivery Service origin.
+func getUpstreamScheme(svc *atscfg.ParentAbstractionService) string {
+	if !svc.ParentIsProxy && svc.DS.OrgServerFQDN != nil && strings.HasPrefix(*svc.DS.OrgServerFQDN, "https://") {
+		return "https"
+	}
+	return "http"
+}
+
+// makeSSLLines returns the certificate directives of the service's Delivery Service.
+func (cb *ConfBuilder) makeSSLLines(svc *atscfg.ParentAbstractionService) ([]string, error) {
+	dses, _ := atscfg.DeliveryServicesToSSLMultiCertDSes([]atscfg.DeliveryService{svc.DS})
+	dses = atscfg.GetSSLMultiCertDotConfigDeliveryServices(dses)
+	ds, ok := dses[tc.DeliveryServiceName(svc.DS.XMLID)]
+	if !ok {
+		return nil, errors.New("uses https but has no certificate")
+	}
+	cerName, keyName := atscfg.GetSSLMultiCertDotConfigCertAndKeyName(tc.DeliveryServiceName(svc.DS.XMLID), ds)
+	return []string{
+		fmt.Sprintf("\tssl_certificate %s/%s;", strings.TrimSuffix(cb.sslDir, "/"), cerName),
+		fmt.Sprintf("\tssl_certificate_key %s/%s;", strings.TrimSuffix(cb.sslDir, "/"), keyName),
+	}, nil
+}
+
+// getPort returns the server port, or the default if the server has none.
+func (cb *ConfBuilder) getPort(port *int, defaultPort int) int {
+	if port == nil || *port <= 0 {
+		return defaultPort
+	}
+	return *port
+}
+
+func indent(lines []string, depth int) []string {
+	indented := make([]string, 0, len(lines))
+	for _, line := range lines {
+		indented = append(indented, strings.Repeat("\t", depth)+line)
+	}
+	return indented
+}
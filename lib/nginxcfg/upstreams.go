@@ -0,0 +1,127 @@
+package nginxcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+)
+
+// nextUpstreamCodes are the response codes nginx can retry the next upstream
+// server on, with their proxy_next_upstream names.
+var nextUpstreamCodes = map[int]string{
+	403: "http_403",
+	404: "http_404",
+	429: "http_429",
+	500: "http_500",
+	502: "http_502",
+	503: "http_503",
+	504: "http_504",
+}
+
+// configureUpstreams returns the upstream blocks of the service: one for the
+// primary parents, named after the service, and one with the "_secondary"
+// suffix for the secondary parents. nginx can't mix backup servers with hash
+// balancing, so secondary parents are a separate upstream the server block
+// falls back to.
+func configureUpstreams(svc *atscfg.ParentAbstractionService) ([]string, []string) {
+	warnings := make([]string, 0)
+	if len(svc.Parents) == 0 && len(svc.SecondaryParents) == 0 {
+		warnings = append(warnings, fmt.Sprintf("ds '%s' has no parents, skipping!", svc.DS.XMLID))
+		return nil, warnings
+	}
+
+	name := getUpstreamName(svc)
+	if len(svc.Parents) == 0 {
+		return makeUpstream(name, svc, svc.SecondaryParents), warnings
+	}
+	lines := makeUpstream(name, svc, svc.Parents)
+	if len(svc.SecondaryParents) != 0 {
+		lines = append(lines, makeUpstream(name+"_secondary", svc, svc.SecondaryParents)...)
+	}
+	return lines, warnings
+}
+
+func makeUpstream(name string, svc *atscfg.ParentAbstractionService, parents []*atscfg.ParentAbstractionServiceParent) []string {
+	lines := []string{fmt.Sprintf("upstream %s {", name)}
+	backup := false
+	switch svc.RetryPolicy {
+	case atscfg.ParentAbstractionServiceRetryPolicyRoundRobinIP:
+		lines = append(lines, "\tip_hash;")
+	case atscfg.ParentAbstractionServiceRetryPolicyRoundRobinStrict:
+		// round robin is the nginx default
+	case atscfg.ParentAbstractionServiceRetryPolicyFirst, atscfg.ParentAbstractionServiceRetryPolicyLatched:
+		// only the first parent is used until it fails
+		backup = true
+	default:
+		key := "$request_uri"
+		if svc.IgnoreQueryStringInParentSelection {
+			key = "$uri"
+		}
+		lines = append(lines, fmt.Sprintf("\thash %s consistent;", key))
+	}
+
+	for i, parent := range parents {
+		server := fmt.Sprintf("\tserver %s:%d", parent.FQDN, parent.Port)
+		if backup && i > 0 {
+			server += " backup"
+		}
+		lines = append(lines, server+";")
+	}
+	return append(lines, "}")
+}
+
+// makeNextUpstream returns the proxy_next_upstream directives of the service's
+// retry codes and retries, and warnings for codes nginx can't retry on.
+func makeNextUpstream(svc *atscfg.ParentAbstractionService) ([]string, []string) {
+	warnings := make([]string, 0)
+	conditions := map[string]struct{}{}
+	unsupported := make([]string, 0)
+	for _, code := range append(append([]int{}, svc.MarkdownResponseCodes...), svc.ErrorResponseCodes...) {
+		if condition, ok := nextUpstreamCodes[code]; ok {
+			conditions[condition] = struct{}{}
+			continue
+		}
+		unsupported = append(unsupported, strconv.Itoa(code))
+	}
+	if len(unsupported) > 0 {
+		warnings = append(warnings, fmt.Sprintf("ds '%s' retry response codes %s are not supported by nginx, ignoring", svc.DS.XMLID, strings.Join(unsupported, ", ")))
+	}
+
+	sortedConditions := make([]string, 0, len(conditions))
+	for condition := range conditions {
+		sortedConditions = append(sortedConditions, condition)
+	}
+	sort.Strings(sortedConditions)
+	lines := []string{fmt.Sprintf("proxy_next_upstream %s;", strings.Join(append([]string{"error", "timeout"}, sortedConditions...), " "))}
+
+	retries := svc.MaxSimpleRetries
+	if svc.MaxMarkdownRetries > retries {
+		retries = svc.MaxMarkdownRetries
+	}
+	if retries > 0 {
+		lines = append(lines, fmt.Sprintf("proxy_next_upstream_tries %d;", retries+1))
+	}
+	return lines, warnings
+}
@@ -0,0 +1,163 @@
+package nginxcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+)
+
+func TestConfigureUpstreams(t *testing.T) {
+	parents := []*atscfg.ParentAbstractionServiceParent{
+		{FQDN: "mid0.example.net", Port: 80},
+		{FQDN: "mid1.example.net", Port: 80},
+	}
+	secondaryParents := []*atscfg.ParentAbstractionServiceParent{{FQDN: "mid2.example.net", Port: 8080}}
+
+	tests := []struct {
+		name     string
+		svc      atscfg.ParentAbstractionService
+		expected []string
+	}{
+		{
+			name: "consistent hash with secondary parents",
+			svc: atscfg.ParentAbstractionService{
+				Name:             "ds-0.example.net",
+				RetryPolicy:      atscfg.ParentAbstractionServiceRetryPolicyConsistentHash,
+				Parents:          parents,
+				SecondaryParents: secondaryParents,
+			},
+			expected: []string{
+				"upstream tc_ds_0_example_net {",
+				"\thash $request_uri consistent;",
+				"\tserver mid0.example.net:80;",
+				"\tserver mid1.example.net:80;",
+				"}",
+				"upstream tc_ds_0_example_net_secondary {",
+				"\thash $request_uri consistent;",
+				"\tserver mid2.example.net:8080;",
+				"}",
+			},
+		},
+		{
+			name: "consistent hash ignoring the query string",
+			svc: atscfg.ParentAbstractionService{
+				Name:                               "ds0",
+				RetryPolicy:                        atscfg.ParentAbstractionServiceRetryPolicyConsistentHash,
+				IgnoreQueryStringInParentSelection: true,
+				Parents:                            parents[:1],
+			},
+			expected: []string{
+				"upstream tc_ds0 {",
+				"\thash $uri consistent;",
+				"\tserver mid0.example.net:80;",
+				"}",
+			},
+		},
+		{
+			name: "first parent",
+			svc: atscfg.ParentAbstractionService{
+				Name:        "ds0",
+				RetryPolicy: atscfg.ParentAbstractionServiceRetryPolicyFirst,
+				Parents:     parents,
+			},
+			expected: []string{
+				"upstream tc_ds0 {",
+				"\tserver mid0.example.net:80;",
+				"\tserver mid1.example.net:80 backup;",
+				"}",
+			},
+		},
+		{
+			name: "round robin ip",
+			svc: atscfg.ParentAbstractionService{
+				Name:        "ds0",
+				RetryPolicy: atscfg.ParentAbstractionServiceRetryPolicyRoundRobinIP,
+				Parents:     parents,
+			},
+			expected: []string{
+				"upstream tc_ds0 {",
+				"\tip_hash;",
+				"\tserver mid0.example.net:80;",
+				"\tserver mid1.example.net:80;",
+				"}",
+			},
+		},
+		{
+			name: "only secondary parents",
+			svc: atscfg.ParentAbstractionService{
+				Name:             "ds0",
+				RetryPolicy:      atscfg.ParentAbstractionServiceRetryPolicyRoundRobinStrict,
+				SecondaryParents: secondaryParents,
+			},
+			expected: []string{
+				"upstream tc_ds0 {",
+				"\tserver mid2.example.net:8080;",
+				"}",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, warnings := configureUpstreams(&tc.svc)
+			if len(warnings) != 0 {
+				t.Errorf("expected no warnings, got %v", warnings)
+			}
+			if !reflect.DeepEqual(tc.expected, actual) {
+				t.Errorf("expected %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+
+	svc := atscfg.ParentAbstractionService{Name: "ds0"}
+	if actual, warnings := configureUpstreams(&svc); actual != nil || len(warnings) != 1 {
+		t.Errorf("expected no upstreams and a warning for a service without parents, got %q and %v", actual, warnings)
+	}
+}
+
+func TestMakeNextUpstream(t *testing.T) {
+	svc := atscfg.ParentAbstractionService{
+		Name:                  "ds0",
+		MarkdownResponseCodes: []int{503, 502},
+		ErrorResponseCodes:    []int{404, 502, 599},
+		MaxSimpleRetries:      1,
+		MaxMarkdownRetries:    2,
+	}
+	expected := []string{
+		"proxy_next_upstream error timeout http_404 http_502 http_503;",
+		"proxy_next_upstream_tries 3;",
+	}
+	actual, warnings := makeNextUpstream(&svc)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("expected a warning for the unsupported 599 code, got %v", warnings)
+	}
+
+	svc = atscfg.ParentAbstractionService{Name: "ds0"}
+	expected = []string{"proxy_next_upstream error timeout;"}
+	if actual, _ := makeNextUpstream(&svc); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+}
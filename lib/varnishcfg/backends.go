@@ -38,22 +38,10 @@ func (v *VCLBuilder) configureDirectors(vclFile *vclFile, parents *atscfg.Parent
 		addBackends(vclFile.backends, append(svc.Parents, svc.SecondaryParents...), svc.DestDomain, svc.Port)
 		addDirectors(vclFile.subroutines, svc)
 
-		requestFQDNs = []string{svc.DestDomain}
-
-		if v.toData.Server.Type == tc.CacheTypeEdge.String() {
-			dsRegexes := atscfg.MakeDSRegexMap(v.toData.DeliveryServiceRegexes)
-			anyCastPartners := atscfg.GetAnyCastPartners(v.toData.Server, v.toData.Servers)
-			requestFQDNs, err = atscfg.GetDSRequestFQDNs(
-				&svc.DS,
-				dsRegexes[tc.DeliveryServiceName(svc.DS.XMLID)],
-				v.toData.Server,
-				anyCastPartners,
-				v.toData.CDN.DomainName,
-			)
-			if err != nil {
-				warnings = append(warnings, "error getting ds '"+svc.DS.XMLID+"' request fqdns, skipping! Error: "+err.Error())
-				continue
-			}
+		requestFQDNs, err = v.getRequestFQDNs(svc)
+		if err != nil {
+			warnings = append(warnings, "error getting ds '"+svc.DS.XMLID+"' request fqdns, skipping! Error: "+err.Error())
+			continue
 		}
 
 		assignBackends(vclFile.subroutines, svc, requestFQDNs)
@@ -62,6 +50,22 @@ func (v *VCLBuilder) configureDirectors(vclFile *vclFile, parents *atscfg.Parent
 	return warnings, nil
 }
 
+// getRequestFQDNs returns the hosts clients use to request the service on this server.
+func (v *VCLBuilder) getRequestFQDNs(svc *atscfg.ParentAbstractionService) ([]string, error) {
+	if v.toData.Server.Type != tc.CacheTypeEdge.String() {
+		return []string{svc.DestDomain}, nil
+	}
+	dsRegexes := atscfg.MakeDSRegexMap(v.toData.DeliveryServiceRegexes)
+	anyCastPartners := atscfg.GetAnyCastPartners(v.toData.Server, v.toData.Servers)
+	return atscfg.GetDSRequestFQDNs(
+		&svc.DS,
+		dsRegexes[tc.DeliveryServiceName(svc.DS.XMLID)],
+		v.toData.Server,
+		anyCastPartners,
+		v.toData.CDN.DomainName,
+	)
+}
+
 func assignBackends(subroutines map[string][]string, svc *atscfg.ParentAbstractionService, requestFQDNs []string) {
 	lines := make([]string, 0)
 	hostHeaderLines := make([]string, 0)
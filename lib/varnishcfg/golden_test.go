@@ -0,0 +1,61 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// checkGolden compares actual to the golden file testdata/name. Run the tests
+// with -update to rewrite the golden files after intended changes.
+func checkGolden(t *testing.T, name string, actual string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, []byte(actual), 0644); err != nil {
+			t.Fatalf("updating golden file %s: %v", path, err)
+		}
+	}
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file %s: %v", path, err)
+	}
+	if actual != string(expected) {
+		t.Errorf("%s mismatch, expected:\n%s\ngot:\n%s", path, expected, actual)
+	}
+}
+
+func makeTestRemapDS(xmlID string, firstTier bool, requestFQDNs ...string) remapDS {
+	ds := &atscfg.DeliveryService{}
+	ds.XMLID = xmlID
+	return remapDS{
+		ds:           ds,
+		requestFQDNs: requestFQDNs,
+		originHost:   "origin." + xmlID + ".example.org",
+		firstTier:    firstTier,
+	}
+}
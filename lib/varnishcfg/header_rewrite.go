@@ -0,0 +1,198 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// headerRewriteTarget is the VCL subroutine and HTTP object that header_rewrite
+// operators of a hook translate to.
+type headerRewriteTarget struct {
+	subroutine string
+	object     string
+}
+
+// headerRewriteHooks are the header_rewrite hook conditions which can be
+// translated to VCL.
+var headerRewriteHooks = map[string]headerRewriteTarget{
+	"READ_REQUEST_HDR_HOOK":       {subroutine: "vcl_recv", object: "req"},
+	"READ_REQUEST_PRE_REMAP_HOOK": {subroutine: "vcl_recv", object: "req"},
+	"REMAP_PSEUDO_HOOK":           {subroutine: "vcl_recv", object: "req"},
+	"SEND_REQUEST_HDR_HOOK":       {subroutine: "vcl_backend_fetch", object: "bereq"},
+	"READ_RESPONSE_HDR_HOOK":      {subroutine: "vcl_backend_response", object: "beresp"},
+	"SEND_RESPONSE_HDR_HOOK":      {subroutine: "vcl_deliver", object: "resp"},
+}
+
+// headerRewriteSubroutines is the order DS header rewrites are added to subroutines.
+var headerRewriteSubroutines = []string{"vcl_recv", "vcl_backend_fetch", "vcl_backend_response", "vcl_deliver"}
+
+// defaultHeaderRewriteHook is the hook of header_rewrite rules without a hook
+// condition, when the plugin is used as a remap plugin.
+const defaultHeaderRewriteHook = "REMAP_PSEUDO_HOOK"
+
+var headerNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+var statusCodeRe = regexp.MustCompile(`^[1-9][0-9][0-9]$`)
+var headerRewriteReturnRe = regexp.MustCompile(`\s*__RETURN__\s*`)
+
+// configureHeaderRewrites translates the header_rewrite rules of the DS for
+// this server's tier into VCL.
+// Only hook conditions and the set-header, add-header, rm-header and
+// set-status operators are supported, rules using anything else are skipped
+// with a warning.
+func (v *VCLBuilder) configureHeaderRewrites(vclFile *vclFile, ds remapDS) []string {
+	warnings := make([]string, 0)
+	lines := make(map[string][]string)
+
+	for _, rewrite := range ds.headerRewrites {
+		target := headerRewriteHooks[defaultHeaderRewriteHook]
+		skipRule := false
+		inOperators := false
+
+		rewrite = headerRewriteReturnRe.ReplaceAllString(rewrite, "\n")
+		for _, line := range strings.Split(rewrite, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			fields := strings.Fields(line)
+			if fields[0] == "cond" {
+				// a condition after operators begins a new rule
+				if inOperators {
+					target = headerRewriteHooks[defaultHeaderRewriteHook]
+					skipRule = false
+					inOperators = false
+				}
+				if len(fields) < 2 {
+					warnings = append(warnings, fmt.Sprintf("ds '%s' header rewrite has empty condition, skipping rule", ds.ds.XMLID))
+					skipRule = true
+					continue
+				}
+				hook := strings.TrimSuffix(strings.TrimPrefix(fields[1], "%{"), "}")
+				if hookTarget, ok := headerRewriteHooks[hook]; ok {
+					target = hookTarget
+					continue
+				}
+				warnings = append(warnings, fmt.Sprintf("ds '%s' header rewrite condition '%s' is not supported, skipping rule", ds.ds.XMLID, line))
+				skipRule = true
+				continue
+			}
+
+			inOperators = true
+			if skipRule {
+				continue
+			}
+			if last := fields[len(fields)-1]; len(fields) > 1 && strings.HasPrefix(last, "[") && strings.HasSuffix(last, "]") {
+				warnings = append(warnings, fmt.Sprintf("ds '%s' header rewrite flag '%s' is not supported, ignoring", ds.ds.XMLID, last))
+				fields = fields[:len(fields)-1]
+			}
+			opLines, err := translateHeaderRewriteOperator(target.object, fields)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("ds '%s' header rewrite operator '%s' skipped: %s", ds.ds.XMLID, line, err))
+				continue
+			}
+			lines[target.subroutine] = append(lines[target.subroutine], opLines...)
+		}
+	}
+
+	for _, subroutine := range headerRewriteSubroutines {
+		if len(lines[subroutine]) == 0 {
+			continue
+		}
+		condition := ds.hostCondition("req.http.host")
+		switch subroutine {
+		case "vcl_backend_fetch":
+			condition = ds.hostCondition("bereq.http.host")
+		case "vcl_backend_response":
+			// the host header has already been changed to the origin when the response is fetched
+			condition = fmt.Sprintf(`bereq.http.host == "%s"`, ds.originHost)
+		}
+		vclFile.subroutines[subroutine] = append(vclFile.subroutines[subroutine], fmt.Sprintf("if (%s) {", condition))
+		for _, line := range lines[subroutine] {
+			vclFile.subroutines[subroutine] = append(vclFile.subroutines[subroutine], "\t"+line)
+		}
+		vclFile.subroutines[subroutine] = append(vclFile.subroutines[subroutine], "}")
+	}
+
+	return warnings
+}
+
+// translateHeaderRewriteOperator returns the VCL lines for a header_rewrite
+// operator acting on the given HTTP object.
+func translateHeaderRewriteOperator(object string, fields []string) ([]string, error) {
+	operator := fields[0]
+	switch operator {
+	case "set-header", "add-header", "rm-header":
+	case "set-status":
+		if len(fields) != 2 {
+			return nil, errors.New("expected a status code")
+		}
+		if object != "resp" && object != "beresp" {
+			return nil, errors.New("only supported on response hooks")
+		}
+		if !statusCodeRe.MatchString(fields[1]) {
+			return nil, fmt.Errorf("invalid status code '%s'", fields[1])
+		}
+		return []string{fmt.Sprintf("set %s.status = %s;", object, fields[1])}, nil
+	default:
+		return nil, errors.New("operator not supported")
+	}
+
+	if len(fields) < 2 {
+		return nil, errors.New("expected a header name")
+	}
+	header := fmt.Sprintf("%s.http.%s", object, fields[1])
+	if !headerNameRe.MatchString(fields[1]) {
+		return nil, fmt.Errorf("invalid header name '%s'", fields[1])
+	}
+	if operator == "rm-header" {
+		return []string{fmt.Sprintf("unset %s;", header)}, nil
+	}
+
+	if len(fields) < 3 {
+		return nil, errors.New("expected a header value")
+	}
+	value := strings.Join(fields[2:], " ")
+	if len(value) > 1 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		value = value[1 : len(value)-1]
+	}
+	if strings.Contains(value, "%{") {
+		return nil, errors.New("variables are not supported")
+	}
+	value, err := vclString(value)
+	if err != nil {
+		return nil, err
+	}
+	if operator == "set-header" {
+		return []string{fmt.Sprintf("set %s = %s;", header, value)}, nil
+	}
+	// Varnish only keeps one header per name, so added values are appended to
+	// the existing header as a list.
+	return []string{
+		fmt.Sprintf("if (%s) {", header),
+		fmt.Sprintf("\tset %s = %s + \", \" + %s;", header, header, value),
+		"} else {",
+		fmt.Sprintf("\tset %s = %s;", header, value),
+		"}",
+	}, nil
+}
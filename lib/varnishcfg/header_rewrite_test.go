@@ -0,0 +1,56 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+)
+
+func TestConfigureHeaderRewrites(t *testing.T) {
+	ds1 := makeTestRemapDS("ds1", true, "ds1.example.org", "edge.ds1.example.org")
+	ds1.headerRewrites = []string{
+		`cond %{REMAP_PSEUDO_HOOK}__RETURN__set-header X-Request-From "tc edge"__RETURN__rm-header Cookie`,
+		`cond %{SEND_REQUEST_HDR_HOOK}__RETURN__add-header X-Forwarded-Via edge`,
+		`cond %{READ_RESPONSE_HDR_HOOK}__RETURN__rm-header Set-Cookie__RETURN__set-header Cache-Control "max-age=60"`,
+		`cond %{SEND_RESPONSE_HDR_HOOK}__RETURN__add-header Access-Control-Allow-Origin * [L]__RETURN__cond %{SEND_RESPONSE_HDR_HOOK}__RETURN__cond %{STATUS} =404__RETURN__set-status 410`,
+		`set-header X-Client-IP %{INBOUND:REMOTE-ADDR}`,
+	}
+	ds2 := makeTestRemapDS("ds2", false, "origin.ds2.example.org")
+	ds2.headerRewrites = []string{"cond %{SEND_RESPONSE_HDR_HOOK}\nset-status 200\ncond %{READ_REQUEST_HDR_HOOK}\nset-status 403"}
+
+	vb := NewVCLBuilder(&t3cutil.ConfigData{})
+	vclFile := newVCLFile(defaultVCLVersion)
+	warnings := vb.configureHeaderRewrites(&vclFile, ds1)
+	warnings = append(warnings, vb.configureHeaderRewrites(&vclFile, ds2)...)
+
+	checkGolden(t, "header_rewrite.vcl", vclFile.String())
+	expectedWarnings := []string{
+		"ds 'ds1' header rewrite flag '[L]' is not supported, ignoring",
+		"ds 'ds1' header rewrite condition 'cond %{STATUS} =404' is not supported, skipping rule",
+		"ds 'ds1' header rewrite operator 'set-header X-Client-IP %{INBOUND:REMOTE-ADDR}' skipped: variables are not supported",
+		"ds 'ds2' header rewrite operator 'set-status 403' skipped: only supported on response hooks",
+	}
+	if !reflect.DeepEqual(warnings, expectedWarnings) {
+		t.Errorf("expected warnings %v got %v", expectedWarnings, warnings)
+	}
+}
@@ -0,0 +1,69 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// VarnishParamsFileName is the name of the environment file with the varnishd
+// and varnishncsa options, for use as a systemd EnvironmentFile.
+const VarnishParamsFileName = "varnish.params"
+
+// GetVarnishParams returns the environment file with the varnishd storage
+// options from the storage.config Parameters, and the varnishncsa options from
+// the logging.yaml Parameters. configDir is the directory Varnish config files
+// are written to.
+func GetVarnishParams(serverParams []tc.ParameterV5, configDir string) (string, []string) {
+	warnings := make([]string, 0)
+
+	storageArgs, storageWarnings := getStorageArgs(serverParams)
+	warnings = append(warnings, storageWarnings...)
+	ncsaArgs, ncsaWarnings := getVarnishncsaArgs(serverParams, configDir)
+	warnings = append(warnings, ncsaWarnings...)
+
+	lines := []string{
+		`VARNISH_STORAGE_OPTS="` + strings.Join(storageArgs, " ") + `"`,
+		`VARNISHNCSA_OPTS="` + strings.Join(ncsaArgs, " ") + `"`,
+	}
+	return strings.Join(lines, "\n") + "\n", warnings
+}
+
+// getParamValues returns the values of the Parameters with the given config
+// file, by name. If a name is repeated, the lowest value is used, so the result
+// doesn't depend on the order of the Parameters.
+func getParamValues(params []tc.ParameterV5, configFile string) map[string]string {
+	values := make(map[string][]string)
+	for _, param := range params {
+		if param.ConfigFile != configFile {
+			continue
+		}
+		values[param.Name] = append(values[param.Name], param.Value)
+	}
+	paramValues := make(map[string]string, len(values))
+	for name, vals := range values {
+		sort.Strings(vals)
+		paramValues[name] = vals[0]
+	}
+	return paramValues
+}
@@ -0,0 +1,97 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+func TestGetVarnishParams(t *testing.T) {
+	params := makeLoggingParams(map[string]string{
+		"LogFormat.Name":           "custom_ats_2",
+		"LogFormat.Format":         "%<chi> %<cqtx> %<pssc>",
+		"LogObject.Filename":       "custom_ats_2",
+		"LogObject.Format":         "custom_ats_2",
+		"LogObject.RollingEnabled": "3",
+	})
+	params = append(params, []tc.ParameterV5{
+		{ConfigFile: atscfg.StorageFileName, Name: "Drive_Prefix", Value: "/dev/sd"},
+		{ConfigFile: atscfg.StorageFileName, Name: "Drive_Letters", Value: "b, c"},
+		{ConfigFile: atscfg.StorageFileName, Name: "Drive_Size", Value: "500G"},
+		{ConfigFile: atscfg.StorageFileName, Name: "RAM_Drive_Prefix", Value: "/dev/ram"},
+		{ConfigFile: atscfg.StorageFileName, Name: "RAM_Drive_Letters", Value: "0"},
+		{ConfigFile: atscfg.StorageFileName, Name: "RAM_Drive_Size", Value: "8G"},
+		{ConfigFile: atscfg.StorageFileName, Name: "SSD_Drive_Prefix", Value: "/dev/nvme"},
+		{ConfigFile: atscfg.StorageFileName, Name: "SSD_Drive_Letters", Value: "0n1"},
+	}...)
+
+	txt, warnings := GetVarnishParams(params, "/etc/varnish")
+	checkGolden(t, VarnishParamsFileName, txt)
+	expectedWarnings := []string{"log rolling is not supported by varnishncsa, logs must be rotated externally"}
+	if !reflect.DeepEqual(warnings, expectedWarnings) {
+		t.Errorf("expected warnings %v got %v", expectedWarnings, warnings)
+	}
+}
+
+func TestGetStorageArgs(t *testing.T) {
+	testCases := []struct {
+		name             string
+		params           []tc.ParameterV5
+		expectedArgs     []string
+		expectedWarnings []string
+	}{
+		{
+			name:             "no storage params",
+			params:           []tc.ParameterV5{{ConfigFile: "records.config", Name: "Drive_Prefix", Value: "/dev/sd"}},
+			expectedArgs:     []string{},
+			expectedWarnings: []string{},
+		},
+		{
+			name: "unsized RAM drive",
+			params: []tc.ParameterV5{
+				{ConfigFile: atscfg.StorageFileName, Name: "RAM_Drive_Prefix", Value: "/dev/ram"},
+				{ConfigFile: atscfg.StorageFileName, Name: "RAM_Drive_Letters", Value: "0,1"},
+			},
+			expectedArgs:     []string{"-s ram_0=malloc", "-s ram_1=malloc"},
+			expectedWarnings: []string{"RAM_Drive_Prefix parameter has no RAM_Drive_Size, RAM storage will be unbounded"},
+		},
+		{
+			name:             "drive prefix without letters",
+			params:           []tc.ParameterV5{{ConfigFile: atscfg.StorageFileName, Name: "Drive_Prefix", Value: "/dev/sd"}},
+			expectedArgs:     []string{},
+			expectedWarnings: []string{"Drive_Prefix parameter has no Drive_Letters, skipping"},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			args, warnings := getStorageArgs(tC.params)
+			if !reflect.DeepEqual(args, tC.expectedArgs) {
+				t.Errorf("expected args %v got %v", tC.expectedArgs, args)
+			}
+			if !reflect.DeepEqual(warnings, tC.expectedWarnings) {
+				t.Errorf("expected warnings %v got %v", tC.expectedWarnings, warnings)
+			}
+		})
+	}
+}
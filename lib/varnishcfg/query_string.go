@@ -0,0 +1,58 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"fmt"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// configureQueryStringHandling translates the DS query string handling into VCL.
+// Query strings are dropped from requests on the first tier, or left out of
+// the cache key while still being sent upstream.
+func (v *VCLBuilder) configureQueryStringHandling(vclFile *vclFile, ds remapDS) []string {
+	warnings := make([]string, 0)
+	if ds.ds.QStringIgnore == nil {
+		return warnings
+	}
+
+	switch *ds.ds.QStringIgnore {
+	case tc.QueryStringIgnoreDropAtEdge:
+		if !ds.firstTier {
+			return warnings
+		}
+		vclFile.subroutines["vcl_recv"] = append(vclFile.subroutines["vcl_recv"], []string{
+			fmt.Sprintf("if (%s) {", ds.hostCondition("req.http.host")),
+			`	set req.url = regsub(req.url, "\?.*$", "");`,
+			"}",
+		}...)
+	case tc.QueryStringIgnoreIgnoreInCacheKeyAndPassUp:
+		// returning skips the builtin vcl_hash, which would hash the full URL
+		vclFile.subroutines["vcl_hash"] = append(vclFile.subroutines["vcl_hash"], []string{
+			fmt.Sprintf("if (%s) {", ds.hostCondition("req.http.host")),
+			`	hash_data(regsub(req.url, "\?.*$", ""));`,
+			`	hash_data(req.http.host);`,
+			`	return (lookup);`,
+			"}",
+		}...)
+	}
+	return warnings
+}
@@ -0,0 +1,49 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+func TestConfigureQueryStringHandling(t *testing.T) {
+	dropDS := makeTestRemapDS("drop", true, "drop.example.org")
+	dropDS.ds.QStringIgnore = util.Ptr(tc.QueryStringIgnoreDropAtEdge)
+	dropMidDS := makeTestRemapDS("dropmid", false, "origin.dropmid.example.org")
+	dropMidDS.ds.QStringIgnore = util.Ptr(tc.QueryStringIgnoreDropAtEdge)
+	ignoreDS := makeTestRemapDS("ignore", true, "ignore.example.org", "www.ignore.example.org")
+	ignoreDS.ds.QStringIgnore = util.Ptr(tc.QueryStringIgnoreIgnoreInCacheKeyAndPassUp)
+	useDS := makeTestRemapDS("use", true, "use.example.org")
+	useDS.ds.QStringIgnore = util.Ptr(tc.QueryStringIgnoreUseInCacheKeyAndPassUp)
+	nilDS := makeTestRemapDS("nil", true, "nil.example.org")
+
+	vb := NewVCLBuilder(&t3cutil.ConfigData{})
+	vclFile := newVCLFile(defaultVCLVersion)
+	for _, ds := range []remapDS{dropDS, dropMidDS, ignoreDS, useDS, nilDS} {
+		if warnings := vb.configureQueryStringHandling(&vclFile, ds); len(warnings) != 0 {
+			t.Errorf("expected no warnings for ds %s got %v", ds.ds.XMLID, warnings)
+		}
+	}
+	checkGolden(t, "query_string.vcl", vclFile.String())
+}
@@ -0,0 +1,126 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var regexRemapVariableRe = regexp.MustCompile(`\$([0-9]|[a-zA-Z])`)
+
+// configureRegexRemap translates the regex_remap rules of the DS into VCL.
+// As with the regex_remap plugin, the rules are matched in order against the
+// path and query string, and the first matching rule's substitution becomes
+// the new path and query string.
+func (v *VCLBuilder) configureRegexRemap(vclFile *vclFile, ds remapDS) []string {
+	warnings := make([]string, 0)
+	if !ds.firstTier || ds.ds.RegexRemap == nil || *ds.ds.RegexRemap == "" {
+		return warnings
+	}
+
+	ruleLines := make([]string, 0)
+	for _, line := range strings.Split(strings.Replace(*ds.ds.RegexRemap, `__RETURN__`, "\n", -1), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines, err := translateRegexRemapRule(line, len(ruleLines) == 0)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("ds '%s' regex remap rule '%s' skipped: %s", ds.ds.XMLID, line, err))
+			continue
+		}
+		ruleLines = append(ruleLines, lines...)
+	}
+	if len(ruleLines) == 0 {
+		return warnings
+	}
+	ruleLines = append(ruleLines, "}")
+
+	vclFile.subroutines["vcl_recv"] = append(vclFile.subroutines["vcl_recv"], fmt.Sprintf("if (%s) {", ds.hostCondition("req.http.host")))
+	for _, line := range ruleLines {
+		vclFile.subroutines["vcl_recv"] = append(vclFile.subroutines["vcl_recv"], "\t"+line)
+	}
+	vclFile.subroutines["vcl_recv"] = append(vclFile.subroutines["vcl_recv"], "}")
+	return warnings
+}
+
+// translateRegexRemapRule returns the VCL lines of a regex_remap rule, without
+// the closing brace of its if statement.
+func translateRegexRemapRule(rule string, first bool) ([]string, error) {
+	fields := strings.Fields(rule)
+	if len(fields) < 2 {
+		return nil, errors.New("expected a regex and a substitution")
+	}
+	regex := fields[0]
+	substitution := fields[1]
+	for _, option := range fields[2:] {
+		switch {
+		case option == "@caseless":
+			regex = "(?i)" + regex
+		case strings.HasPrefix(option, "@status="):
+			return nil, errors.New("redirects are not supported")
+		default:
+			return nil, fmt.Errorf("option '%s' is not supported", option)
+		}
+	}
+	if strings.HasPrefix(substitution, "http://") || strings.HasPrefix(substitution, "https://") {
+		return nil, errors.New("substitutions changing the host are not supported")
+	}
+
+	var err error
+	substitution = regexRemapVariableRe.ReplaceAllStringFunc(substitution, func(variable string) string {
+		if variable[1] >= '1' && variable[1] <= '9' {
+			return `\` + variable[1:]
+		}
+		err = fmt.Errorf("variable '%s' is not supported", variable)
+		return variable
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The whole URL is replaced by the substitution, not just the matched part.
+	// The lazy prefix keeps the leftmost match, and the non-capturing group
+	// keeps the capture numbering of the rule.
+	match, err := vclString(regex)
+	if err != nil {
+		return nil, err
+	}
+	replace, err := vclString("^.*?(?:" + regex + ").*$")
+	if err != nil {
+		return nil, err
+	}
+	with, err := vclString(substitution)
+	if err != nil {
+		return nil, err
+	}
+
+	condition := "if"
+	if !first {
+		condition = "} elsif"
+	}
+	return []string{
+		fmt.Sprintf("%s (req.url ~ %s) {", condition, match),
+		fmt.Sprintf("\tset req.url = regsub(req.url, %s, %s);", replace, with),
+	}, nil
+}
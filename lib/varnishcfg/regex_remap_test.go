@@ -0,0 +1,49 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+func TestConfigureRegexRemap(t *testing.T) {
+	ds1 := makeTestRemapDS("ds1", true, "ds1.example.org")
+	ds1.ds.RegexRemap = util.Ptr(`^/old/(.*)$ /new/$1__RETURN__^/Images/(.*)\.JPG /img/$1.jpg @caseless__RETURN__^/go/(.*) https://elsewhere.example.org/$1 @status=301__RETURN__^/q/(.*) /search?q=$1&host=$h__RETURN__/"quoted"/ /unquoted/`)
+	ds2 := makeTestRemapDS("ds2", false, "origin.ds2.example.org")
+	ds2.ds.RegexRemap = util.Ptr(`^/a /b`)
+
+	vb := NewVCLBuilder(&t3cutil.ConfigData{})
+	vclFile := newVCLFile(defaultVCLVersion)
+	warnings := vb.configureRegexRemap(&vclFile, ds1)
+	warnings = append(warnings, vb.configureRegexRemap(&vclFile, ds2)...)
+
+	checkGolden(t, "regex_remap.vcl", vclFile.String())
+	expectedWarnings := []string{
+		"ds 'ds1' regex remap rule '^/go/(.*) https://elsewhere.example.org/$1 @status=301' skipped: redirects are not supported",
+		"ds 'ds1' regex remap rule '^/q/(.*) /search?q=$1&host=$h' skipped: variable '$h' is not supported",
+	}
+	if !reflect.DeepEqual(warnings, expectedWarnings) {
+		t.Errorf("expected warnings %v got %v", expectedWarnings, warnings)
+	}
+}
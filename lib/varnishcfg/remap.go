@@ -0,0 +1,118 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// remapDS is a Delivery Service assigned to the server, with the data needed
+// to translate its remap features into VCL.
+type remapDS struct {
+	ds *atscfg.DeliveryService
+	// requestFQDNs are the hosts clients use to request the DS from this server.
+	requestFQDNs []string
+	// originHost is the host requests are sent upstream with.
+	originHost string
+	// firstTier is whether the server is the client-facing tier of the DS,
+	// where ATS applies regex_remap, url_sig and query string dropping.
+	firstTier bool
+	// headerRewrites are the DS header rewrites which apply to this server's tier.
+	headerRewrites []string
+}
+
+// hostCondition returns a VCL condition matching requests for the DS, using
+// the given request header object, e.g. "req.http.host".
+func (ds remapDS) hostCondition(hostVar string) string {
+	conditions := make([]string, 0, len(ds.requestFQDNs))
+	for _, fqdn := range ds.requestFQDNs {
+		conditions = append(conditions, hostVar+` == "`+fqdn+`"`)
+	}
+	return strings.Join(conditions, " || ")
+}
+
+// getRemapDSes returns the Delivery Services of the parent abstraction services
+// with the remap data of the tier this server is in.
+func (v *VCLBuilder) getRemapDSes(parents *atscfg.ParentAbstraction) ([]remapDS, []string) {
+	warnings := make([]string, 0)
+	dses := make([]remapDS, 0, len(parents.Services))
+	for _, svc := range parents.Services {
+		requestFQDNs, err := v.getRequestFQDNs(svc)
+		if err != nil {
+			warnings = append(warnings, "error getting ds '"+svc.DS.XMLID+"' request fqdns, skipping! Error: "+err.Error())
+			continue
+		}
+		ds := remapDS{
+			ds:           &svc.DS,
+			requestFQDNs: requestFQDNs,
+			originHost:   svc.DestDomain,
+		}
+
+		if svc.DS.Topology == nil || *svc.DS.Topology == "" {
+			if strings.HasPrefix(v.toData.Server.Type, tc.MidTypePrefix) {
+				ds.headerRewrites = appendHeaderRewrite(ds.headerRewrites, svc.DS.MidHeaderRewrite)
+			} else {
+				ds.firstTier = true
+				ds.headerRewrites = appendHeaderRewrite(ds.headerRewrites, svc.DS.EdgeHeaderRewrite)
+			}
+			dses = append(dses, ds)
+			continue
+		}
+
+		placement, err := v.getTopologyPlacement(&svc.DS)
+		if err != nil {
+			warnings = append(warnings, "error getting ds '"+svc.DS.XMLID+"' topology placement, skipping! Error: "+err.Error())
+			continue
+		}
+		ds.firstTier = placement.IsFirstCacheTier
+		if placement.IsFirstCacheTier {
+			ds.headerRewrites = appendHeaderRewrite(ds.headerRewrites, svc.DS.FirstHeaderRewrite)
+		}
+		if placement.IsInnerCacheTier {
+			ds.headerRewrites = appendHeaderRewrite(ds.headerRewrites, svc.DS.InnerHeaderRewrite)
+		}
+		if placement.IsLastCacheTier {
+			ds.headerRewrites = appendHeaderRewrite(ds.headerRewrites, svc.DS.LastHeaderRewrite)
+		}
+		dses = append(dses, ds)
+	}
+	return dses, warnings
+}
+
+func (v *VCLBuilder) getTopologyPlacement(ds *atscfg.DeliveryService) (atscfg.TopologyPlacement, error) {
+	for _, topology := range v.toData.Topologies {
+		if topology.Name != *ds.Topology {
+			continue
+		}
+		return atscfg.GetTopologyPlacement(tc.CacheGroupName(v.toData.Server.CacheGroup), topology, v.toData.CacheGroups, ds)
+	}
+	return atscfg.TopologyPlacement{}, fmt.Errorf("topology '%s' not found", *ds.Topology)
+}
+
+func appendHeaderRewrite(rewrites []string, rewrite *string) []string {
+	if rewrite == nil || *rewrite == "" {
+		return rewrites
+	}
+	return append(rewrites, *rewrite)
+}
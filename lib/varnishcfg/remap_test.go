@@ -0,0 +1,91 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+func TestGetRemapDSes(t *testing.T) {
+	ds1 := atscfg.DeliveryService{}
+	ds1.XMLID = "ds1"
+	ds1.EdgeHeaderRewrite = util.Ptr("edge")
+	ds1.MidHeaderRewrite = util.Ptr("mid")
+
+	ds2 := atscfg.DeliveryService{}
+	ds2.XMLID = "ds2"
+	ds2.Topology = util.Ptr("topo")
+	ds2.FirstHeaderRewrite = util.Ptr("first")
+	ds2.InnerHeaderRewrite = util.Ptr("inner")
+	ds2.LastHeaderRewrite = util.Ptr("last")
+
+	ds3 := atscfg.DeliveryService{}
+	ds3.XMLID = "ds3"
+	ds3.Topology = util.Ptr("missing")
+
+	toData := &t3cutil.ConfigData{
+		Server: &atscfg.Server{},
+		Topologies: []tc.TopologyV5{{
+			Name:  "topo",
+			Nodes: []tc.TopologyNodeV5{{Cachegroup: "mid-cg"}},
+		}},
+		CacheGroups: []tc.CacheGroupNullableV5{{Name: util.Ptr("mid-cg"), Type: util.Ptr(tc.CacheGroupMidTypeName)}},
+	}
+	toData.Server.Type = tc.MidTypePrefix
+	toData.Server.CacheGroup = "mid-cg"
+	parents := &atscfg.ParentAbstraction{Services: []*atscfg.ParentAbstractionService{
+		{DS: ds1, DestDomain: "origin.ds1.example.org"},
+		{DS: ds2, DestDomain: "origin.ds2.example.org"},
+		{DS: ds3, DestDomain: "origin.ds3.example.org"},
+	}}
+
+	vb := NewVCLBuilder(toData)
+	dses, warnings := vb.getRemapDSes(parents)
+	if len(warnings) != 1 {
+		t.Errorf("expected a warning for the missing topology, got %v", warnings)
+	}
+	if len(dses) != 2 {
+		t.Fatalf("expected 2 remap dses, got %d", len(dses))
+	}
+
+	if dses[0].firstTier {
+		t.Errorf("expected ds1 to not be first tier on a mid")
+	}
+	if !reflect.DeepEqual(dses[0].headerRewrites, []string{"mid"}) {
+		t.Errorf("expected ds1 mid header rewrite, got %v", dses[0].headerRewrites)
+	}
+	if !reflect.DeepEqual(dses[0].requestFQDNs, []string{"origin.ds1.example.org"}) {
+		t.Errorf("expected ds1 requested with the origin host, got %v", dses[0].requestFQDNs)
+	}
+
+	// the only node of a topology is both its first and last tier
+	if !dses[1].firstTier {
+		t.Errorf("expected ds2 to be first tier")
+	}
+	if !reflect.DeepEqual(dses[1].headerRewrites, []string{"first", "last"}) {
+		t.Errorf("expected ds2 first and last header rewrites, got %v", dses[1].headerRewrites)
+	}
+}
@@ -0,0 +1,89 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// storageDrives are the storage.config Parameters of each kind of drive, in the
+// order ATS assigns them volumes. Sizes are Varnish specific, as ATS uses the
+// size of the whole device.
+var storageDrives = []struct {
+	prefixParam  string
+	lettersParam string
+	sizeParam    string
+	name         string
+	inMemory     bool
+}{
+	{prefixParam: "Drive_Prefix", lettersParam: "Drive_Letters", sizeParam: "Drive_Size", name: "disk"},
+	{prefixParam: "RAM_Drive_Prefix", lettersParam: "RAM_Drive_Letters", sizeParam: "RAM_Drive_Size", name: "ram", inMemory: true},
+	{prefixParam: "SSD_Drive_Prefix", lettersParam: "SSD_Drive_Letters", sizeParam: "SSD_Drive_Size", name: "ssd"},
+}
+
+var storageNameRe = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// getStorageArgs returns the varnishd storage arguments for the storage.config
+// Parameters. Each drive becomes a named file storage, except RAM drives which
+// become malloc storages as Varnish keeps them in memory itself.
+func getStorageArgs(serverParams []tc.ParameterV5) ([]string, []string) {
+	warnings := make([]string, 0)
+	args := make([]string, 0)
+	params := getParamValues(serverParams, atscfg.StorageFileName)
+
+	for _, drive := range storageDrives {
+		prefix := params[drive.prefixParam]
+		if prefix == "" {
+			continue
+		}
+		letters := strings.TrimSpace(params[drive.lettersParam])
+		if letters == "" {
+			warnings = append(warnings, fmt.Sprintf("%s parameter has no %s, skipping", drive.prefixParam, drive.lettersParam))
+			continue
+		}
+		size := strings.TrimSpace(params[drive.sizeParam])
+		if drive.inMemory && size == "" {
+			warnings = append(warnings, fmt.Sprintf("%s parameter has no %s, RAM storage will be unbounded", drive.prefixParam, drive.sizeParam))
+		}
+
+		for _, letter := range strings.Split(letters, ",") {
+			letter = strings.TrimSpace(letter)
+			if letter == "" {
+				continue
+			}
+			storage := drive.name + "_" + storageNameRe.ReplaceAllString(letter, "_") + "="
+			if drive.inMemory {
+				storage += "malloc"
+			} else {
+				storage += "file," + prefix + letter
+			}
+			if size != "" {
+				storage += "," + size
+			}
+			args = append(args, "-s "+storage)
+		}
+	}
+	return args, warnings
+}
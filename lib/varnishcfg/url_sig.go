@@ -0,0 +1,127 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// urlSigForbidden is the VCL statement denying a request failing url_sig validation.
+const urlSigForbidden = `return (synth(403, "Forbidden"));`
+
+// configureURLSig translates url_sig signed URL validation of the DS into VCL.
+// Validation requires the digest vmod for HMAC signatures. As with the url_sig
+// plugin, the expiration, optional client IP and the signature over the host,
+// path and query string up to and including the "S=" parameter are checked.
+// Only URLs signed with all parts ("P=1") are supported.
+func (v *VCLBuilder) configureURLSig(vclFile *vclFile, ds remapDS) []string {
+	warnings := make([]string, 0)
+	if !ds.firstTier || ds.ds.SigningAlgorithm == nil || *ds.ds.SigningAlgorithm != tc.SigningAlgorithmURLSig {
+		return warnings
+	}
+
+	lines := []string{fmt.Sprintf("if (%s) {", ds.hostCondition("req.http.host"))}
+	keyLines, keyWarnings := urlSigKeyLines(v.toData.URLSigKeys[tc.DeliveryServiceName(ds.ds.XMLID)])
+	for _, warning := range keyWarnings {
+		warnings = append(warnings, fmt.Sprintf("ds '%s' url sig: %s", ds.ds.XMLID, warning))
+	}
+	if len(keyLines) == 0 {
+		warnings = append(warnings, fmt.Sprintf("ds '%s' uses url sig but has no valid keys, denying all requests", ds.ds.XMLID))
+		lines = append(lines, "\t"+urlSigForbidden, "}")
+		vclFile.subroutines["vcl_recv"] = append(vclFile.subroutines["vcl_recv"], lines...)
+		return warnings
+	}
+
+	vclFile.addImport("std")
+	vclFile.addImport("digest")
+	lines = append(lines, []string{
+		`	if (req.url !~ "[?&]E=[0-9]+(&|$)" || req.url !~ "[?&]A=[12](&|$)" || req.url !~ "[?&]K=[0-9]+(&|$)" || req.url !~ "[?&]P=1(&|$)" || req.url !~ "[?&]S=[0-9a-fA-F]+(&|$)") {`,
+		"\t\t" + urlSigForbidden,
+		`	}`,
+		`	if (std.time(regsub(req.url, "^.*[?&]E=([0-9]+).*$", "\1"), now) < now) {`,
+		"\t\t" + urlSigForbidden,
+		`	}`,
+		`	if (req.url ~ "[?&]C=" && std.ip(regsub(req.url, "^.*[?&]C=([^&]*).*$", "\1"), "0.0.0.0") != client.ip) {`,
+		"\t\t" + urlSigForbidden,
+		`	}`,
+	}...)
+	lines = append(lines, keyLines...)
+	lines = append(lines, []string{
+		`	set req.http.X-TC-URL-Sig-Data = req.http.host + regsub(req.url, "([?&]S=).*$", "\1");`,
+		`	if (req.url ~ "[?&]A=1(&|$)") {`,
+		`		set req.http.X-TC-URL-Sig-Expected = digest.hmac_sha1(req.http.X-TC-URL-Sig-Key, req.http.X-TC-URL-Sig-Data);`,
+		`	} else {`,
+		`		set req.http.X-TC-URL-Sig-Expected = digest.hmac_md5(req.http.X-TC-URL-Sig-Key, req.http.X-TC-URL-Sig-Data);`,
+		`	}`,
+		`	unset req.http.X-TC-URL-Sig-Key;`,
+		`	unset req.http.X-TC-URL-Sig-Data;`,
+		`	if (req.http.X-TC-URL-Sig-Expected != "0x" + std.tolower(regsub(req.url, "^.*[?&]S=([0-9a-fA-F]+).*$", "\1"))) {`,
+		`		unset req.http.X-TC-URL-Sig-Expected;`,
+		"\t\t" + urlSigForbidden,
+		`	}`,
+		`	unset req.http.X-TC-URL-Sig-Expected;`,
+		`}`,
+	}...)
+	vclFile.subroutines["vcl_recv"] = append(vclFile.subroutines["vcl_recv"], lines...)
+	return warnings
+}
+
+// urlSigKeyLines returns the VCL lines selecting the key from the "K="
+// parameter, in order of key index.
+func urlSigKeyLines(keys tc.URLSigKeys) ([]string, []string) {
+	warnings := make([]string, 0)
+	indexes := make([]int, 0, len(keys))
+	values := make(map[int]string, len(keys))
+	for name, key := range keys {
+		index, err := strconv.Atoi(strings.TrimPrefix(name, "key"))
+		if err != nil || !strings.HasPrefix(name, "key") {
+			warnings = append(warnings, fmt.Sprintf("key '%s' is not named 'key<index>', skipping", name))
+			continue
+		}
+		value, err := vclString(key)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("key '%s' can't be used in VCL, skipping: %s", name, err))
+			continue
+		}
+		indexes = append(indexes, index)
+		values[index] = value
+	}
+	if len(indexes) == 0 {
+		return nil, warnings
+	}
+	sort.Ints(indexes)
+
+	lines := make([]string, 0, len(indexes)*2+3)
+	for i, index := range indexes {
+		condition := "\tif"
+		if i > 0 {
+			condition = "\t} elsif"
+		}
+		lines = append(lines, fmt.Sprintf(`%s (req.url ~ "[?&]K=%d(&|$)") {`, condition, index))
+		lines = append(lines, fmt.Sprintf("\t\tset req.http.X-TC-URL-Sig-Key = %s;", values[index]))
+	}
+	lines = append(lines, "\t} else {", "\t\t"+urlSigForbidden, "\t}")
+	return lines, warnings
+}
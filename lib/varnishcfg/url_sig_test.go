@@ -0,0 +1,65 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+func TestConfigureURLSig(t *testing.T) {
+	signedDS := makeTestRemapDS("signed", true, "signed.example.org")
+	signedDS.ds.SigningAlgorithm = util.Ptr(tc.SigningAlgorithmURLSig)
+	noKeysDS := makeTestRemapDS("nokeys", true, "nokeys.example.org")
+	noKeysDS.ds.SigningAlgorithm = util.Ptr(tc.SigningAlgorithmURLSig)
+	midDS := makeTestRemapDS("signed", false, "origin.signed.example.org")
+	midDS.ds.SigningAlgorithm = util.Ptr(tc.SigningAlgorithmURLSig)
+	uriSigningDS := makeTestRemapDS("urisigning", true, "urisigning.example.org")
+	uriSigningDS.ds.SigningAlgorithm = util.Ptr(tc.SigningAlgorithmURISigning)
+
+	vb := NewVCLBuilder(&t3cutil.ConfigData{
+		URLSigKeys: map[tc.DeliveryServiceName]tc.URLSigKeys{
+			"signed": {
+				"key10":  "tenthKey",
+				"key0":   "zerothKey",
+				"key1":   `"quoted"Key`,
+				"secret": "notAKey",
+			},
+		},
+	})
+	vclFile := newVCLFile(defaultVCLVersion)
+	warnings := make([]string, 0)
+	for _, ds := range []remapDS{signedDS, noKeysDS, midDS, uriSigningDS} {
+		warnings = append(warnings, vb.configureURLSig(&vclFile, ds)...)
+	}
+
+	checkGolden(t, "url_sig.vcl", vclFile.String())
+	expectedWarnings := []string{
+		"ds 'signed' url sig: key 'secret' is not named 'key<index>', skipping",
+		"ds 'nokeys' uses url sig but has no valid keys, denying all requests",
+	}
+	if !reflect.DeepEqual(warnings, expectedWarnings) {
+		t.Errorf("expected warnings %v got %v", expectedWarnings, warnings)
+	}
+}
@@ -0,0 +1,153 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// VarnishncsaFormatFileName is the name of the file varnishncsa reads its
+// log format from.
+const VarnishncsaFormatFileName = "varnishncsa.format"
+
+// varnishncsaLogDir is the directory varnishncsa writes logs to.
+const varnishncsaLogDir = "/var/log/varnish"
+
+// varnishncsaFields are the varnishncsa equivalents of ATS log fields.
+var varnishncsaFields = map[string]string{
+	"chi":   "%h",
+	"caun":  "%u",
+	"cqtq":  "%{sec}t.%{msec_frac}t",
+	"cqts":  "%{sec}t",
+	"cqtn":  "%t",
+	"cqtd":  "%{%Y-%m-%d}t",
+	"cqtt":  "%{%H:%M:%S}t",
+	"cqhm":  "%m",
+	"cqhv":  "%H",
+	"cqtx":  "%r",
+	"cquc":  "http://%{Host}i%U%q",
+	"cquuc": "http://%{Host}i%U%q",
+	"cqu":   "%U%q",
+	"cqup":  "%U",
+	"cquup": "%U",
+	"cqql":  "%I",
+	"pssc":  "%s",
+	"pscl":  "%b",
+	"psql":  "%O",
+	"psct":  "%{Content-Type}o",
+	"ttms":  "%{ms}T",
+	"tts":   "%T",
+	"crc":   "%{Varnish:handling}x",
+}
+
+var logFieldRe = regexp.MustCompile(`%<([^>]*)>`)
+var logHeaderFieldRe = regexp.MustCompile(`^\{([^}]+)\}(cqh|psh)$`)
+
+// GetVarnishncsaFormat returns the varnishncsa format file translated from the
+// format of the first logging.yaml log object Parameters. varnishncsa writes a
+// single log, so other log objects are ignored with a warning. Fields without
+// a varnishncsa equivalent are logged as "-".
+func GetVarnishncsaFormat(serverParams []tc.ParameterV5, hostName string) (string, []string) {
+	warnings := make([]string, 0)
+	params := getParamValues(serverParams, atscfg.LoggingYAMLFileName)
+
+	if params["LogObject.Filename"] == "" {
+		return "", warnings
+	}
+	for i := 1; i < atscfg.MaxLogObjects; i++ {
+		if name := params["LogObject"+strconv.Itoa(i)+".Filename"]; name != "" {
+			warnings = append(warnings, fmt.Sprintf("varnishncsa writes a single log, ignoring log object '%s'", name))
+		}
+	}
+	if params["LogObject.Filters"] != "" {
+		warnings = append(warnings, "log object filters are not supported by varnishncsa, logging all requests")
+	}
+
+	formatName := params["LogObject.Format"]
+	format := ""
+	for i := 0; i < atscfg.MaxLogObjects; i++ {
+		field := "LogFormat"
+		if i > 0 {
+			field += strconv.Itoa(i)
+		}
+		if params[field+".Name"] == formatName {
+			format = params[field+".Format"]
+			break
+		}
+	}
+	if format == "" {
+		warnings = append(warnings, fmt.Sprintf("log format '%s' not found, using varnishncsa default format", formatName))
+		return "", warnings
+	}
+
+	unsupported := make(map[string]struct{})
+	format = logFieldRe.ReplaceAllStringFunc(format, func(field string) string {
+		name := field[2 : len(field)-1]
+		if name == "phn" {
+			return hostName
+		}
+		if ncsaField, ok := varnishncsaFields[name]; ok {
+			return ncsaField
+		}
+		if match := logHeaderFieldRe.FindStringSubmatch(name); match != nil {
+			if match[2] == "cqh" {
+				return "%{" + match[1] + "}i"
+			}
+			return "%{" + match[1] + "}o"
+		}
+		unsupported[name] = struct{}{}
+		return "-"
+	})
+	if len(unsupported) > 0 {
+		warnings = append(warnings, fmt.Sprintf("log fields not supported by varnishncsa, logging '-': %s", strings.Join(sortedKeys(unsupported), ", ")))
+	}
+	return format + "\n", warnings
+}
+
+// getVarnishncsaArgs returns the varnishncsa arguments to write the first
+// logging.yaml log object Parameters log, or nil if there is no log object.
+func getVarnishncsaArgs(serverParams []tc.ParameterV5, configDir string) ([]string, []string) {
+	warnings := make([]string, 0)
+	params := getParamValues(serverParams, atscfg.LoggingYAMLFileName)
+
+	fileName := params["LogObject.Filename"]
+	if fileName == "" {
+		return nil, warnings
+	}
+	if logType := params["LogObject.Type"]; logType != "" && logType != "ascii" {
+		warnings = append(warnings, fmt.Sprintf("log object type '%s' is not supported by varnishncsa, writing ascii", logType))
+	}
+	if params["LogObject.RollingEnabled"] != "" && params["LogObject.RollingEnabled"] != "0" {
+		warnings = append(warnings, "log rolling is not supported by varnishncsa, logs must be rotated externally")
+	}
+
+	args := []string{"-a", "-w " + filepath.Join(varnishncsaLogDir, fileName+".log")}
+	if format, _ := GetVarnishncsaFormat(serverParams, ""); format != "" {
+		args = append([]string{"-f " + filepath.Join(configDir, VarnishncsaFormatFileName)}, args...)
+	}
+	return args, warnings
+}
@@ -0,0 +1,74 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+func makeLoggingParams(params map[string]string) []tc.ParameterV5 {
+	serverParams := []tc.ParameterV5{{ConfigFile: "records.config", Name: "LogFormat.Name", Value: "wrong_file"}}
+	for name, value := range params {
+		serverParams = append(serverParams, tc.ParameterV5{ConfigFile: atscfg.LoggingYAMLFileName, Name: name, Value: value})
+	}
+	return serverParams
+}
+
+func TestGetVarnishncsaFormat(t *testing.T) {
+	params := makeLoggingParams(map[string]string{
+		"LogFormat.Name":      "custom_ats_2",
+		"LogFormat.Format":    `%<cqtq> chi=%<chi> phn=%<phn> php=%<php> shn=%<shn> url=%<cquuc> cqhm=%<cqhm> cqhv=%<cqhv> pssc=%<pssc> ttms=%<ttms> b=%<pscl> sssc=%<sssc> crc=%<crc> uas="%<{User-Agent}cqh>" xmt="%<{X-MoneyTrace}cqh>" ct="%<{Content-Type}psh>"`,
+		"LogFormat1.Name":     "other",
+		"LogFormat1.Format":   "%<chi>",
+		"LogObject.Filename":  "custom_ats_2",
+		"LogObject.Format":    "custom_ats_2",
+		"LogObject.Filters":   "COMBINED",
+		"LogObject1.Filename": "other",
+		"LogObject1.Format":   "other",
+	})
+
+	txt, warnings := GetVarnishncsaFormat(params, "edge-1")
+	checkGolden(t, VarnishncsaFormatFileName, txt)
+	expectedWarnings := []string{
+		"varnishncsa writes a single log, ignoring log object 'other'",
+		"log object filters are not supported by varnishncsa, logging all requests",
+		"log fields not supported by varnishncsa, logging '-': php, shn, sssc",
+	}
+	if !reflect.DeepEqual(warnings, expectedWarnings) {
+		t.Errorf("expected warnings %v got %v", expectedWarnings, warnings)
+	}
+
+	txt, warnings = GetVarnishncsaFormat(makeLoggingParams(map[string]string{"LogObject.Filename": "missing", "LogObject.Format": "missing"}), "edge-1")
+	if txt != "" {
+		t.Errorf("expected no format for missing log format, got %s", txt)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("expected one warning for missing log format, got %v", warnings)
+	}
+
+	txt, warnings = GetVarnishncsaFormat(makeLoggingParams(nil), "edge-1")
+	if txt != "" || len(warnings) != 0 {
+		t.Errorf("expected no format or warnings without log objects, got %s %v", txt, warnings)
+	}
+}
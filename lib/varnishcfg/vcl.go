@@ -19,7 +19,12 @@ package varnishcfg
  * under the License.
  */
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
 
 const defaultVCLVersion = "4.1"
 
@@ -48,9 +53,10 @@ func (v vclFile) String() string {
 		txt += fmt.Sprintf("import %s;\n", i)
 	}
 
-	for name, backend := range v.backends {
+	// map keys are sorted so the generated file is stable between runs
+	for _, name := range sortedKeys(v.backends) {
 		txt += fmt.Sprintf("backend %s {\n", name)
-		txt += fmt.Sprint(backend)
+		txt += fmt.Sprint(v.backends[name])
 		txt += fmt.Sprint("}\n")
 	}
 	// varnishd will fail if there are no backends defined
@@ -58,9 +64,9 @@ func (v vclFile) String() string {
 		txt += fmt.Sprint("backend default none;\n")
 	}
 
-	for name, acl := range v.acls {
+	for _, name := range sortedKeys(v.acls) {
 		txt += fmt.Sprintf("acl %s {\n", name)
-		for _, entry := range acl {
+		for _, entry := range v.acls[name] {
 			txt += fmt.Sprintf("\t%s;\n", entry)
 		}
 		txt += fmt.Sprint("}\n")
@@ -75,12 +81,12 @@ func (v vclFile) String() string {
 		txt += fmt.Sprint("}\n")
 	}
 
-	for name, subroutine := range v.subroutines {
+	for _, name := range sortedKeys(v.subroutines) {
 		if name == "vcl_init" {
 			continue
 		}
 		txt += fmt.Sprintf("sub %s {\n", name)
-		for _, entry := range subroutine {
+		for _, entry := range v.subroutines[name] {
 			txt += fmt.Sprintf("\t%s\n", entry)
 		}
 		txt += fmt.Sprint("}\n")
@@ -89,6 +95,40 @@ func (v vclFile) String() string {
 	return txt
 }
 
+// addImport adds the vmod to the file imports, if it isn't already imported.
+func (v *vclFile) addImport(vmod string) {
+	for _, i := range v.imports {
+		if i == vmod {
+			return
+		}
+	}
+	v.imports = append(v.imports, vmod)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// vclString returns s as a VCL string literal. VCL strings have no escape
+// sequences, so strings containing double quotes use the long string form.
+func vclString(s string) (string, error) {
+	if strings.ContainsAny(s, "\n\r") {
+		return "", errors.New("VCL strings cannot contain line breaks")
+	}
+	if !strings.Contains(s, `"`) {
+		return `"` + s + `"`, nil
+	}
+	if strings.Contains(s, `"}`) {
+		return "", errors.New(`VCL strings cannot contain '"}'`)
+	}
+	return `{"` + s + `"}`, nil
+}
+
 type backend struct {
 	host string
 	port int
@@ -85,6 +85,18 @@ func (vb *VCLBuilder) BuildVCLFile() (string, []string, error) {
 	cacheWarnings := vb.configureUncacheableDSes(&v, profileDSes)
 	warnings = append(warnings, cacheWarnings...)
 
+	// remap features must be added before the directors, so they see the
+	// request as the client sent it and before the host is changed to the origin.
+	remapDSes, remapWarnings := vb.getRemapDSes(parents)
+	warnings = append(warnings, remapWarnings...)
+	for _, ds := range remapDSes {
+		// signed URLs are validated first, as the other features may change the URL
+		warnings = append(warnings, vb.configureURLSig(&v, ds)...)
+		warnings = append(warnings, vb.configureHeaderRewrites(&v, ds)...)
+		warnings = append(warnings, vb.configureQueryStringHandling(&v, ds)...)
+		warnings = append(warnings, vb.configureRegexRemap(&v, ds)...)
+	}
+
 	dirWarnings, err := vb.configureDirectors(&v, parents)
 	warnings = append(warnings, dirWarnings...)
 
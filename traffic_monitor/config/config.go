@@ -97,6 +97,181 @@ func (t *PollingProtocol) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// DistributedPollingMode is a string value indicating how caches are divided
+// among Traffic Monitors when distributed polling is enabled.
+type DistributedPollingMode string
+
+const (
+	// DistributedPollingCacheGroup assigns cache groups to Traffic Monitor
+	// groups by geographic distance, with every Traffic Monitor in a group
+	// polling the same caches.
+	DistributedPollingCacheGroup = DistributedPollingMode("cachegroup")
+	// DistributedPollingConsistentHash shards caches across all live Traffic
+	// Monitors in the CDN by consistent hashing, with each cache polled by
+	// exactly one Traffic Monitor.
+	DistributedPollingConsistentHash = DistributedPollingMode("consistent_hash")
+	// InvalidDistributedPollingMode is returned for unknown mode strings.
+	InvalidDistributedPollingMode = DistributedPollingMode("invalid_distributed_polling_mode")
+)
+
+// String returns a string representation of this DistributedPollingMode.
+func (m DistributedPollingMode) String() string {
+	return string(m)
+}
+
+// DistributedPollingModeFromString returns a DistributedPollingMode based on the string input.
+func DistributedPollingModeFromString(s string) DistributedPollingMode {
+	s = strings.ToLower(s)
+	switch s {
+	case DistributedPollingCacheGroup.String():
+		return DistributedPollingCacheGroup
+	case DistributedPollingConsistentHash.String():
+		return DistributedPollingConsistentHash
+	default:
+		return InvalidDistributedPollingMode
+	}
+}
+
+// UnmarshalJSON implements the json.Unmarshaller interface
+func (m *DistributedPollingMode) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+	*m = DistributedPollingModeFromString(s)
+	if *m == InvalidDistributedPollingMode {
+		return errors.New("parsed invalid DistributedPollingMode: " + s)
+	}
+	return nil
+}
+
+// PeerCombinationPolicy is a string value indicating how the local and peer
+// states of caches and delivery services are combined.
+type PeerCombinationPolicy string
+
+const (
+	// PeerCombinationOptimistic considers a cache or delivery service
+	// available if it is available locally or on any available peer.
+	PeerCombinationOptimistic = PeerCombinationPolicy("optimistic")
+	// PeerCombinationMajority considers a cache or delivery service available
+	// if it is available on a majority of the local Traffic Monitor and its
+	// available peers.
+	PeerCombinationMajority = PeerCombinationPolicy("majority")
+	// PeerCombinationWeighted is like PeerCombinationMajority, but each vote
+	// is weighted by the Traffic Monitor's location, per
+	// peer_location_weights.
+	PeerCombinationWeighted = PeerCombinationPolicy("weighted")
+	// PeerCombinationLocalPlusN considers a cache or delivery service
+	// unavailable only if it is unavailable locally and on at least
+	// peer_combination_min_peers available peers.
+	PeerCombinationLocalPlusN = PeerCombinationPolicy("local_plus_n")
+	// InvalidPeerCombinationPolicy is returned for unknown policy strings.
+	InvalidPeerCombinationPolicy = PeerCombinationPolicy("invalid_peer_combination_policy")
+)
+
+// String returns a string representation of this PeerCombinationPolicy.
+func (p PeerCombinationPolicy) String() string {
+	return string(p)
+}
+
+// PeerCombinationPolicyFromString returns a PeerCombinationPolicy based on the string input.
+func PeerCombinationPolicyFromString(s string) PeerCombinationPolicy {
+	s = strings.ToLower(s)
+	switch s {
+	case PeerCombinationOptimistic.String():
+		return PeerCombinationOptimistic
+	case PeerCombinationMajority.String():
+		return PeerCombinationMajority
+	case PeerCombinationWeighted.String():
+		return PeerCombinationWeighted
+	case PeerCombinationLocalPlusN.String():
+		return PeerCombinationLocalPlusN
+	default:
+		return InvalidPeerCombinationPolicy
+	}
+}
+
+// UnmarshalJSON implements the json.Unmarshaller interface
+func (p *PeerCombinationPolicy) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+	*p = PeerCombinationPolicyFromString(s)
+	if *p == InvalidPeerCombinationPolicy {
+		return errors.New("parsed invalid PeerCombinationPolicy: " + s)
+	}
+	return nil
+}
+
+// DeliveryServiceProbe is a synthetic HTTP(S) request made periodically to a
+// delivery service through its edge caches, to detect delivery service
+// failures, such as an origin outage, which aren't visible in cache health.
+type DeliveryServiceProbe struct {
+	// The XMLID of the delivery service to probe.
+	DeliveryService string `json:"delivery_service"`
+	// The Host header, and TLS SNI name, of the probe requests.
+	Host string `json:"host"`
+	// The request path of the probe requests.
+	Path string `json:"path"`
+	// The request scheme, "http" or "https".
+	Scheme string `json:"scheme"`
+	// The response status code which indicates a successful probe.
+	ExpectedStatus int `json:"expected_status"`
+	// The maximum latency in milliseconds of a successful probe. If zero,
+	// latency is not checked.
+	MaxLatencyMs uint64 `json:"max_latency_ms"`
+	// Whether to verify the TLS certificate of HTTPS probes.
+	ValidateTLS bool `json:"validate_tls"`
+	// The host names of the caches through which to probe. If empty, up to
+	// MaxCaches available edge caches assigned to the delivery service are
+	// used.
+	Caches []string `json:"caches"`
+	// The maximum number of edge caches through which to probe, if Caches is
+	// empty.
+	MaxCaches int `json:"max_caches"`
+}
+
+// Default values of optional DeliveryServiceProbe fields.
+const (
+	DefaultProbePath           = "/"
+	DefaultProbeScheme         = "http"
+	DefaultProbeExpectedStatus = 200
+	DefaultProbeMaxCaches      = 3
+)
+
+// validateDeliveryServiceProbe checks the given probe for errors, and sets the defaults of any unset optional fields.
+func validateDeliveryServiceProbe(p *DeliveryServiceProbe) error {
+	if p.DeliveryService == "" {
+		return errors.New("delivery_service must not be empty")
+	}
+	if p.Host == "" {
+		return errors.New("host must not be empty")
+	}
+	if p.Path == "" {
+		p.Path = DefaultProbePath
+	} else if !strings.HasPrefix(p.Path, "/") {
+		p.Path = "/" + p.Path
+	}
+	p.Scheme = strings.ToLower(p.Scheme)
+	if p.Scheme == "" {
+		p.Scheme = DefaultProbeScheme
+	} else if p.Scheme != "http" && p.Scheme != "https" {
+		return fmt.Errorf("scheme '%s' must be http or https", p.Scheme)
+	}
+	if p.ExpectedStatus == 0 {
+		p.ExpectedStatus = DefaultProbeExpectedStatus
+	}
+	if p.MaxCaches < 0 {
+		return errors.New("max_caches must not be negative")
+	} else if p.MaxCaches == 0 {
+		p.MaxCaches = DefaultProbeMaxCaches
+	}
+	return nil
+}
+
 // Config is the configuration for the application. It includes myriad data,
 // such as polling intervals and log locations.
 type Config struct {
@@ -106,8 +281,26 @@ type Config struct {
 	CRConfigBackupFile string `json:"crconfig_backup_file"`
 	// The number of historical CDN Snapshots to store.
 	CRConfigHistoryCount uint64 `json:"crconfig_history_count"`
+	// The interval on which the synthetic delivery service probes are made.
+	DeliveryServiceProbeInterval time.Duration `json:"-"`
+	// Synthetic delivery service probes. If empty, delivery services are not
+	// probed.
+	DeliveryServiceProbes []DeliveryServiceProbe `json:"delivery_service_probes"`
 	// Controls whether Distributed Polling is enabled.
 	DistributedPolling bool `json:"distributed_polling"`
+	// Controls how caches are divided among Traffic Monitors when Distributed
+	// Polling is enabled.
+	DistributedPollingMode DistributedPollingMode `json:"distributed_polling_mode"`
+	// A path to a file to which all events are appended, such that they
+	// survive restarts and may be queried beyond MaxEvents. If empty, events
+	// are only kept in memory.
+	EventLogFile string `json:"event_log_file"`
+	// The number of rotated event log files to keep, in addition to the
+	// current file.
+	EventLogMaxFiles int `json:"event_log_max_files"`
+	// The size in megabytes at which the event log file is rotated. If zero,
+	// the file is never rotated.
+	EventLogMaxSizeMB uint64 `json:"event_log_max_size_mb"`
 	// Defines an interval on which Traffic Monitor will flush its collected
 	// health data such that it is made available through the API.
 	HealthFlushInterval time.Duration `json:"-"`
@@ -136,9 +329,24 @@ type Config struct {
 	MaxEvents uint64 `json:"max_events"`
 	// The interval on which to poll for this TM's CDN's "monitoring config".
 	MonitorConfigPollingInterval time.Duration `json:"-"`
+	// The policy by which local and peer cache and delivery service states
+	// are combined.
+	PeerCombinationPolicy PeerCombinationPolicy `json:"peer_combination_policy"`
+	// The number of available peers which must agree with the local Traffic
+	// Monitor that a cache or delivery service is unavailable, when using the
+	// "local_plus_n" combination policy.
+	PeerCombinationMinPeers int `json:"peer_combination_min_peers"`
+	// The weights of votes from Traffic Monitors, by Traffic Monitor location
+	// (Cache Group), when using the "weighted" combination policy. Traffic
+	// Monitors in locations not in this map have a weight of 1.
+	PeerLocationWeights map[string]float64 `json:"peer_location_weights"`
 	// Specifies the minimum number of peers that must be available in order to
 	// participate in the optimistic health protocol.
 	PeerOptimisticQuorumMin int `json:"peer_optimistic_quorum_min"`
+	// A path to a file to which every cache poll result, monitoring config
+	// and CRConfig is recorded, to be replayed later. If empty, nothing is
+	// recorded.
+	RecordFile string `json:"record_file"`
 	// The timeout for the API server for reading requests.
 	ServeReadTimeout time.Duration `json:"-"`
 	// The timeout for the API server for writing responses.
@@ -190,6 +398,12 @@ var DefaultConfig = Config{
 	CachePollingProtocol:         Both,
 	CRConfigBackupFile:           CRConfigBackupFile,
 	CRConfigHistoryCount:         100,
+	DeliveryServiceProbeInterval: 10 * time.Second,
+	DeliveryServiceProbes:        nil,
+	DistributedPollingMode:       DistributedPollingCacheGroup,
+	EventLogFile:                 "",
+	EventLogMaxFiles:             5,
+	EventLogMaxSizeMB:            100,
 	HealthFlushInterval:          200 * time.Millisecond,
 	HTTPPollingFormat:            HTTPPollingFormat,
 	HTTPTimeout:                  2 * time.Second,
@@ -201,7 +415,11 @@ var DefaultConfig = Config{
 	LogLocationWarning:           LogLocationStdout,
 	MaxEvents:                    200,
 	MonitorConfigPollingInterval: 5 * time.Second,
+	PeerCombinationPolicy:        PeerCombinationOptimistic,
+	PeerCombinationMinPeers:      1,
+	PeerLocationWeights:          nil,
 	PeerOptimisticQuorumMin:      0,
+	RecordFile:                   "",
 	ServeReadTimeout:             10 * time.Second,
 	ServeWriteTimeout:            10 * time.Second,
 	ShortHostnameOverride:        "",
@@ -227,6 +445,7 @@ func (c *Config) MarshalJSON() ([]byte, error) {
 		StatBufferIntervalMs           uint64 `json:"stat_buffer_interval_ms"`
 		ServeReadTimeoutMs             uint64 `json:"serve_read_timeout_ms"`
 		ServeWriteTimeoutMs            uint64 `json:"serve_write_timeout_ms"`
+		DeliveryServiceProbeIntervalMs uint64 `json:"delivery_service_probe_interval_ms"`
 		*Alias
 	}{
 		MonitorConfigPollingIntervalMs: uint64(c.MonitorConfigPollingInterval / time.Millisecond),
@@ -234,6 +453,7 @@ func (c *Config) MarshalJSON() ([]byte, error) {
 		HealthFlushIntervalMs:          uint64(c.HealthFlushInterval / time.Millisecond),
 		StatFlushIntervalMs:            uint64(c.StatFlushInterval / time.Millisecond),
 		StatBufferIntervalMs:           uint64(c.StatBufferInterval / time.Millisecond),
+		DeliveryServiceProbeIntervalMs: uint64(c.DeliveryServiceProbeInterval / time.Millisecond),
 		Alias:                          (*Alias)(c),
 	})
 }
@@ -251,6 +471,7 @@ func (c *Config) UnmarshalJSON(data []byte) error {
 		ServeWriteTimeoutMs            *uint64 `json:"serve_write_timeout_ms"`
 		TrafficOpsMinRetryIntervalMs   *uint64 `json:"traffic_ops_min_retry_interval_ms"`
 		TrafficOpsMaxRetryIntervalMs   *uint64 `json:"traffic_ops_max_retry_interval_ms"`
+		DeliveryServiceProbeIntervalMs *uint64 `json:"delivery_service_probe_interval_ms"`
 		*Alias
 	}{
 		Alias: (*Alias)(c),
@@ -287,6 +508,28 @@ func (c *Config) UnmarshalJSON(data []byte) error {
 	if aux.TrafficOpsMaxRetryIntervalMs != nil {
 		c.TrafficOpsMaxRetryInterval = time.Duration(*aux.TrafficOpsMaxRetryIntervalMs) * time.Millisecond
 	}
+	if aux.DeliveryServiceProbeIntervalMs != nil {
+		c.DeliveryServiceProbeInterval = time.Duration(*aux.DeliveryServiceProbeIntervalMs) * time.Millisecond
+	}
+	if len(c.DeliveryServiceProbes) > 0 && c.DeliveryServiceProbeInterval <= 0 {
+		return errors.New("invalid configuration: delivery_service_probe_interval_ms must be positive")
+	}
+	for i := range c.DeliveryServiceProbes {
+		if err := validateDeliveryServiceProbe(&c.DeliveryServiceProbes[i]); err != nil {
+			return fmt.Errorf("invalid configuration: delivery_service_probes[%d]: %v", i, err)
+		}
+	}
+	if c.PeerCombinationMinPeers < 0 {
+		return errors.New("invalid configuration: peer_combination_min_peers must not be negative")
+	}
+	for location, weight := range c.PeerLocationWeights {
+		if weight < 0 {
+			return fmt.Errorf("invalid configuration: peer_location_weights weight for '%s' must not be negative", location)
+		}
+	}
+	if c.DistributedPollingMode == DistributedPollingConsistentHash && !c.DistributedPolling {
+		return errors.New("invalid configuration: distributed_polling_mode consistent_hash requires distributed_polling to be enabled")
+	}
 	if c.StatPolling && c.DistributedPolling {
 		return errors.New("invalid configuration: stat_polling cannot be enabled if distributed_polling is also enabled")
 	}
@@ -144,3 +144,27 @@ func TestConfigLoadDefaults(t *testing.T) {
 		t.Errorf("DistributedPolling default - expected: false, actual: %t", c.DistributedPolling)
 	}
 }
+
+func TestConfigLoadDeliveryServiceProbes(t *testing.T) {
+	c, err := LoadBytes([]byte(`{"delivery_service_probe_interval_ms": 30000, "delivery_service_probes": [{"delivery_service": "ds0", "host": "ds0.example.net", "path": "health"}]}`))
+	if err != nil {
+		t.Fatalf("loading config with probes - expected: no error, actual: %v", err)
+	}
+	if c.DeliveryServiceProbeInterval.Seconds() != 30 {
+		t.Errorf("DeliveryServiceProbeInterval - expected: 30s, actual: %v", c.DeliveryServiceProbeInterval)
+	}
+	if len(c.DeliveryServiceProbes) != 1 {
+		t.Fatalf("DeliveryServiceProbes - expected: 1, actual: %d", len(c.DeliveryServiceProbes))
+	}
+	p := c.DeliveryServiceProbes[0]
+	if p.Path != "/health" || p.Scheme != DefaultProbeScheme || p.ExpectedStatus != DefaultProbeExpectedStatus || p.MaxCaches != DefaultProbeMaxCaches {
+		t.Errorf("DeliveryServiceProbes[0] defaults - expected: path /health scheme http status 200 max caches 3, actual: %+v", p)
+	}
+
+	if _, err := LoadBytes([]byte(`{"delivery_service_probes": [{"delivery_service": "ds0"}]}`)); err == nil {
+		t.Errorf("loading probe without host - expected: error, actual: nil")
+	}
+	if _, err := LoadBytes([]byte(`{"delivery_service_probes": [{"delivery_service": "ds0", "host": "ds0.example.net", "scheme": "ftp"}]}`)); err == nil {
+		t.Errorf("loading probe with scheme ftp - expected: error, actual: nil")
+	}
+}
@@ -27,17 +27,31 @@ import (
 	"github.com/apache/trafficcontrol/v8/lib/go-log"
 	"github.com/apache/trafficcontrol/v8/lib/go-tc"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/peer"
+
+	jsoniter "github.com/json-iterator/go"
 )
 
+// CRStatesWithVotes is the local CRStates, along with the breakdown of how the local Traffic Monitor and each peer voted when the combined states were last computed.
+type CRStatesWithVotes struct {
+	tc.CRStates
+	Votes peer.CombinationVotes `json:"votes"`
+}
+
 func srvTRState(
 	params url.Values,
 	localStates peer.CRStatesThreadsafe,
 	combinedStates peer.CRStatesThreadsafe,
+	combinationVotes peer.CombinationVotesThreadsafe,
 	peerStates peer.CRStatesPeersThreadsafe,
 	distributedPollingEnabled bool,
 ) ([]byte, int, error) {
 	_, raw := params["raw"]     // peer polling case
 	_, local := params["local"] // distributed peer polling case
+	_, votes := params["votes"]
+	if raw && votes {
+		data, err := srvTRStateSelfVotes(localStates, combinationVotes)
+		return data, http.StatusOK, err
+	}
 	if raw {
 		data, err := srvTRStateSelf(localStates, distributedPollingEnabled)
 		return data, http.StatusOK, err
@@ -91,3 +105,8 @@ func srvTRStateSelf(localStates peer.CRStatesThreadsafe, directlyPolledOnly bool
 	unfiltered := localStates.Get()
 	return tc.CRStatesMarshall(filterDirectlyPolledCaches(unfiltered))
 }
+
+func srvTRStateSelfVotes(localStates peer.CRStatesThreadsafe, combinationVotes peer.CombinationVotesThreadsafe) ([]byte, error) {
+	json := jsoniter.ConfigFastest
+	return json.Marshal(CRStatesWithVotes{CRStates: localStates.Get(), Votes: combinationVotes.Get()})
+}
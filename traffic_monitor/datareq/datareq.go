@@ -48,6 +48,7 @@ func MakeDispatchMap(
 	peerStates peer.CRStatesPeersThreadsafe,
 	distributedPeerStates peer.CRStatesPeersThreadsafe,
 	combinedStates peer.CRStatesThreadsafe,
+	combinationVotes peer.CombinationVotesThreadsafe,
 	statInfoHistory threadsafe.ResultInfoHistory,
 	statResultHistory threadsafe.ResultStatHistory,
 	statMaxKbpses threadsafe.CacheKbpses,
@@ -84,7 +85,7 @@ func MakeDispatchMap(
 			return srvTRConfig(opsConfig, toSession)
 		}, rfc.ApplicationJSON)),
 		"/publish/CrStates": wrap(WrapParams(func(params url.Values, path string) ([]byte, int) {
-			bytes, statusCode, err := srvTRState(params, localStates, combinedStates, peerStates, distributedPollingEnabled)
+			bytes, statusCode, err := srvTRState(params, localStates, combinedStates, combinationVotes, peerStates, distributedPollingEnabled)
 			return WrapErrStatusCode(errorCount, path, bytes, statusCode, err)
 		}, rfc.ApplicationJSON)),
 		"/publish/CacheStatsNew": wrap(WrapParams(func(params url.Values, path string) ([]byte, int) {
@@ -96,8 +97,8 @@ func MakeDispatchMap(
 		"/publish/DsStats": wrap(WrapParams(func(params url.Values, path string) ([]byte, int) {
 			return srvDSStats(params, errorCount, path, toData, dsStats)
 		}, rfc.ApplicationJSON)),
-		"/publish/EventLog": wrap(WrapErr(errorCount, func() ([]byte, error) {
-			return srvEventLog(events)
+		"/publish/EventLog": wrap(WrapParams(func(params url.Values, path string) ([]byte, int) {
+			return srvEventLog(params, errorCount, path, toData, events)
 		}, rfc.ApplicationJSON)),
 		"/publish/PeerStates": wrap(WrapParams(func(params url.Values, path string) ([]byte, int) {
 			return srvPeerStates(params, errorCount, path, toData, peerStates)
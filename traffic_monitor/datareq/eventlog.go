@@ -20,7 +20,17 @@
 package datareq
 
 import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/health"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/threadsafe"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/todata"
 
 	"github.com/json-iterator/go"
 )
@@ -30,7 +40,143 @@ type JSONEvents struct {
 	Events []health.Event `json:"events"`
 }
 
-func srvEventLog(events health.ThreadsafeEvents) ([]byte, error) {
+// EventFilter filters events. See the `NewEventFilter` documentation for details on which query parameters are used to filter.
+type EventFilter struct {
+	caches      map[string]struct{}
+	cacheGroups map[tc.CacheGroupName]struct{}
+	dses        map[tc.DeliveryServiceName]struct{}
+	types       map[string]struct{}
+	start       time.Time
+	end         time.Time
+	limit       int
+	toData      todata.TOData
+}
+
+// NewEventFilter takes the HTTP query parameters and creates an EventFilter, filtering according to the query parameters passed.
+// Query parameters used are `cache`, `cachegroup`, `ds`, `type`, `start`, `end`, and `limit`.
+// The `cache`, `cachegroup`, `ds`, and `type` params are comma-delimited lists; an event matches if it matches any item in each given list.
+// The `ds` param matches both delivery service events and events for caches assigned to the delivery service.
+// The `start` and `end` params are unix epoch seconds, inclusive.
+// If `limit` is empty or 0, all matching events are returned.
+func NewEventFilter(params url.Values, toData todata.TOData) (*EventFilter, error) {
+	validParams := map[string]struct{}{"cache": {}, "cachegroup": {}, "ds": {}, "type": {}, "start": {}, "end": {}, "limit": {}}
+	for param := range params {
+		if _, ok := validParams[param]; !ok {
+			return nil, fmt.Errorf("invalid query parameter '%v'", param)
+		}
+	}
+
+	f := &EventFilter{
+		caches:      map[string]struct{}{},
+		cacheGroups: map[tc.CacheGroupName]struct{}{},
+		dses:        map[tc.DeliveryServiceName]struct{}{},
+		types:       map[string]struct{}{},
+		toData:      toData,
+	}
+	for _, name := range commaParam(params, "cache") {
+		f.caches[name] = struct{}{}
+	}
+	for _, name := range commaParam(params, "cachegroup") {
+		f.cacheGroups[tc.CacheGroupName(name)] = struct{}{}
+	}
+	for _, name := range commaParam(params, "ds") {
+		f.dses[tc.DeliveryServiceName(name)] = struct{}{}
+	}
+	for _, name := range commaParam(params, "type") {
+		f.types[strings.ToUpper(name)] = struct{}{}
+	}
+
+	var err error
+	if f.start, err = unixParam(params, "start"); err != nil {
+		return nil, err
+	}
+	if f.end, err = unixParam(params, "end"); err != nil {
+		return nil, err
+	}
+	if limit := params.Get("limit"); limit != "" {
+		if f.limit, err = strconv.Atoi(limit); err != nil || f.limit < 0 {
+			return nil, fmt.Errorf("invalid query parameter limit '%v' - must be a non-negative integer", limit)
+		}
+	}
+	return f, nil
+}
+
+func commaParam(params url.Values, name string) []string {
+	val := params.Get(name)
+	if val == "" {
+		return nil
+	}
+	return strings.Split(val, ",")
+}
+
+func unixParam(params url.Values, name string) (time.Time, error) {
+	val := params.Get(name)
+	if val == "" {
+		return time.Time{}, nil
+	}
+	secs, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid query parameter %v '%v' - must be a unix epoch integer", name, val)
+	}
+	return time.Unix(secs, 0), nil
+}
+
+// UseEvent returns whether the given event is in this filter.
+func (f *EventFilter) UseEvent(e health.Event) bool {
+	t := time.Time(e.Time)
+	if !f.start.IsZero() && t.Before(f.start) {
+		return false
+	}
+	if !f.end.IsZero() && t.After(f.end) {
+		return false
+	}
+	if _, ok := f.types[strings.ToUpper(e.Type)]; len(f.types) != 0 && !ok {
+		return false
+	}
+	if _, ok := f.caches[e.Name]; len(f.caches) != 0 && !ok {
+		return false
+	}
+	if _, ok := f.cacheGroups[f.toData.ServerCachegroups[tc.CacheName(e.Name)]]; len(f.cacheGroups) != 0 && !ok {
+		return false
+	}
+	if len(f.dses) != 0 && !f.useEventDS(e) {
+		return false
+	}
+	return true
+}
+
+func (f *EventFilter) useEventDS(e health.Event) bool {
+	if e.Type == health.DeliveryServiceEventType {
+		_, ok := f.dses[tc.DeliveryServiceName(e.Name)]
+		return ok
+	}
+	for _, ds := range f.toData.ServerDeliveryServices[tc.CacheName(e.Name)] {
+		if _, ok := f.dses[ds]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func srvEventLog(params url.Values, errorCount threadsafe.Uint, path string, toData todata.TODataThreadsafe, events health.ThreadsafeEvents) ([]byte, int) {
 	json := jsoniter.ConfigFastest
-	return json.Marshal(JSONEvents{Events: events.Get()})
+	if len(params) == 0 {
+		bytes, err := json.Marshal(JSONEvents{Events: events.Get()})
+		return WrapErrCode(errorCount, path, bytes, err)
+	}
+
+	filter, err := NewEventFilter(params, toData.Get())
+	if err != nil {
+		HandleErr(errorCount, path, err)
+		return []byte(err.Error()), http.StatusBadRequest
+	}
+	matched, err := events.Query(filter.UseEvent, filter.limit)
+	if err != nil {
+		return WrapErrCode(errorCount, path, nil, err)
+	}
+	if filter.limit > 0 && len(matched) > filter.limit {
+		matched = matched[:filter.limit]
+	}
+	bytes, err := json.Marshal(JSONEvents{Events: matched})
+	return WrapErrCode(errorCount, path, bytes, err)
 }
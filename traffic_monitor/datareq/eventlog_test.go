@@ -0,0 +1,89 @@
+package datareq
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/health"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/todata"
+)
+
+func TestEventFilter(t *testing.T) {
+	toData := *todata.New()
+	toData.ServerCachegroups = map[tc.CacheName]tc.CacheGroupName{"edge0": "cg0", "edge1": "cg1"}
+	toData.ServerDeliveryServices = map[tc.CacheName][]tc.DeliveryServiceName{"edge0": {"ds0"}, "edge1": {"ds1"}}
+
+	events := []health.Event{
+		{Time: health.Time(time.Unix(100, 0)), Name: "edge0", Type: "EDGE"},
+		{Time: health.Time(time.Unix(200, 0)), Name: "edge1", Type: "EDGE"},
+		{Time: health.Time(time.Unix(300, 0)), Name: "ds0", Type: health.DeliveryServiceEventType},
+		{Time: health.Time(time.Unix(400, 0)), Name: "tm0", Type: "PEER"},
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"cache=edge0,edge1", []string{"edge0", "edge1"}},
+		{"cachegroup=cg1", []string{"edge1"}},
+		{"ds=ds0", []string{"edge0", "ds0"}},
+		{"type=peer", []string{"tm0"}},
+		{"start=200&end=300", []string{"edge1", "ds0"}},
+		{"type=edge&start=150", []string{"edge1"}},
+	}
+
+	for _, test := range tests {
+		params, err := url.ParseQuery(test.query)
+		if err != nil {
+			t.Fatalf("parsing query '%s': %v", test.query, err)
+		}
+		filter, err := NewEventFilter(params, toData)
+		if err != nil {
+			t.Fatalf("creating filter for '%s': %v", test.query, err)
+		}
+		actual := []string{}
+		for _, e := range events {
+			if filter.UseEvent(e) {
+				actual = append(actual, e.Name)
+			}
+		}
+		if len(actual) != len(test.expected) {
+			t.Errorf("query '%s' expected: %v, actual: %v", test.query, test.expected, actual)
+			continue
+		}
+		for i := range actual {
+			if actual[i] != test.expected[i] {
+				t.Errorf("query '%s' expected: %v, actual: %v", test.query, test.expected, actual)
+				break
+			}
+		}
+	}
+
+	for _, query := range []string{"start=yesterday", "limit=-1", "host=edge0"} {
+		params, _ := url.ParseQuery(query)
+		if _, err := NewEventFilter(params, toData); err == nil {
+			t.Errorf("query '%s' expected: error, actual: nil", query)
+		}
+	}
+}
@@ -31,6 +31,7 @@ import (
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/dsdata"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/health"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/peer"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/threadsafe"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/todata"
 )
 
@@ -225,7 +226,7 @@ func addLastDSStatTotals(lastStat *dsdata.LastDSStat, cachesReporting map[tc.Cac
 // addDSPerSecStats calculates and adds the per-second delivery service stats to
 // both the Stats and LastStats structures. Note this mutates both dsStats and
 // lastStats, adding the per-second stats to them.
-func addDSPerSecStats(lastStats *dsdata.LastStats, dsName tc.DeliveryServiceName, stat *dsdata.Stat, serverCachegroups map[tc.CacheName]tc.CacheGroupName, serverTypes map[tc.CacheName]tc.CacheType, mc tc.TrafficMonitorConfigMap, events health.ThreadsafeEvents, precomputed map[tc.CacheName]cache.PrecomputedData, states peer.CRStatesThreadsafe) {
+func addDSPerSecStats(lastStats *dsdata.LastStats, dsName tc.DeliveryServiceName, stat *dsdata.Stat, serverCachegroups map[tc.CacheName]tc.CacheGroupName, serverTypes map[tc.CacheName]tc.CacheType, mc tc.TrafficMonitorConfigMap, events health.ThreadsafeEvents, precomputed map[tc.CacheName]cache.PrecomputedData, states peer.CRStatesThreadsafe, dsProbeResults threadsafe.DSProbeResults) {
 	lastStat, lastStatExists := lastStats.DeliveryServices[dsName]
 	if !lastStatExists {
 		lastStat = &dsdata.LastDSStat{
@@ -278,6 +279,12 @@ func addDSPerSecStats(lastStats *dsdata.LastStats, dsName tc.DeliveryServiceName
 	addLastStatsToStatCacheStats(&stat.TotalStats, &lastStat.Total)
 
 	dsErr := getDSErr(dsName, stat.TotalStats, mc)
+	if probe, ok := dsProbeResults.GetDeliveryService(dsName); ok {
+		stat.CommonStats.Probe = &probe
+		if !probe.Available && dsErr == nil {
+			dsErr = errors.New("synthetic probe failed: " + probe.ErrorStr())
+		}
+	}
 	if dsErr != nil {
 		stat.CommonStats.IsAvailable.Value = false
 		stat.CommonStats.IsHealthy.Value = false
@@ -352,9 +359,9 @@ func addCachePerSecStats(lastStats *dsdata.LastStats, cacheName tc.CacheName, pr
 //
 // Note this mutates both dsStats and lastStats, adding the per-second stats to
 // them.
-func addPerSecStats(precomputed map[tc.CacheName]cache.PrecomputedData, dsStats *dsdata.Stats, lastStats *dsdata.LastStats, serverCachegroups map[tc.CacheName]tc.CacheGroupName, serverTypes map[tc.CacheName]tc.CacheType, mc tc.TrafficMonitorConfigMap, events health.ThreadsafeEvents, states peer.CRStatesThreadsafe) {
+func addPerSecStats(precomputed map[tc.CacheName]cache.PrecomputedData, dsStats *dsdata.Stats, lastStats *dsdata.LastStats, serverCachegroups map[tc.CacheName]tc.CacheGroupName, serverTypes map[tc.CacheName]tc.CacheType, mc tc.TrafficMonitorConfigMap, events health.ThreadsafeEvents, states peer.CRStatesThreadsafe, dsProbeResults threadsafe.DSProbeResults) {
 	for dsName, stat := range dsStats.DeliveryService {
-		addDSPerSecStats(lastStats, dsName, stat, serverCachegroups, serverTypes, mc, events, precomputed, states)
+		addDSPerSecStats(lastStats, dsName, stat, serverCachegroups, serverTypes, mc, events, precomputed, states, dsProbeResults)
 	}
 	for cacheName, precomputedData := range precomputed {
 		addCachePerSecStats(lastStats, cacheName, precomputedData)
@@ -363,7 +370,7 @@ func addPerSecStats(precomputed map[tc.CacheName]cache.PrecomputedData, dsStats
 
 // CreateStats aggregates and creates statistics from given precomputed stat history. It returns the created stats, information about these stats necessary for the next calculation, and any error.
 // Note lastStats is mutated, being set with the new last stats.
-func CreateStats(precomputed map[tc.CacheName]cache.PrecomputedData, toData todata.TOData, crStates tc.CRStates, lastStats *dsdata.LastStats, mc tc.TrafficMonitorConfigMap, events health.ThreadsafeEvents, states peer.CRStatesThreadsafe) *dsdata.Stats {
+func CreateStats(precomputed map[tc.CacheName]cache.PrecomputedData, toData todata.TOData, crStates tc.CRStates, lastStats *dsdata.LastStats, mc tc.TrafficMonitorConfigMap, events health.ThreadsafeEvents, states peer.CRStatesThreadsafe, dsProbeResults threadsafe.DSProbeResults) *dsdata.Stats {
 	start := time.Now()
 	dsStats := dsdata.NewStats(len(toData.DeliveryServiceServers)) // TODO sync.Pool?
 	for deliveryService := range toData.DeliveryServiceServers {
@@ -428,7 +435,7 @@ func CreateStats(precomputed map[tc.CacheName]cache.PrecomputedData, toData toda
 		}
 	}
 
-	addPerSecStats(precomputed, dsStats, lastStats, toData.ServerCachegroups, toData.ServerTypes, mc, events, states)
+	addPerSecStats(precomputed, dsStats, lastStats, toData.ServerCachegroups, toData.ServerTypes, mc, events, states, dsProbeResults)
 	log.Infof("CreateStats took %v\n", time.Since(start))
 	dsStats.Time = time.Now()
 	return dsStats
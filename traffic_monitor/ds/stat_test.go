@@ -85,13 +85,13 @@ func TestCreateStats(t *testing.T) {
 	lastStatsVal := lastStatsThs.Get()
 	lastStatsCopy := lastStatsVal.Copy()
 
-	dsStats := CreateStats(precomputeds, toData, combinedCRStates.Get(), lastStatsCopy, monitorConfig, events, localCRStates)
+	dsStats := CreateStats(precomputeds, toData, combinedCRStates.Get(), lastStatsCopy, monitorConfig, events, localCRStates, threadsafe.NewDSProbeResults())
 
 	serverDeliveryServices := toData.ServerDeliveryServices
 	toData.ServerDeliveryServices = map[tc.CacheName][]tc.DeliveryServiceName{} // temporarily unassign servers to generate warnings about caches not assigned to delivery services
 	buffer := bytes.NewBuffer(make([]byte, 0, 10000))
 	tc_log.Info = log.New(buffer, "TestAddAvailabilityDataNotFoundInDeliveryService", log.Lshortfile)
-	_ = CreateStats(precomputeds, toData, combinedCRStates.Get(), lastStatsCopy, monitorConfig, events, localCRStates)
+	_ = CreateStats(precomputeds, toData, combinedCRStates.Get(), lastStatsCopy, monitorConfig, events, localCRStates, threadsafe.NewDSProbeResults())
 	checkLogOutput(t, buffer, toData, caches)
 	toData.ServerDeliveryServices = serverDeliveryServices
 
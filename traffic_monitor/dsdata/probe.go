@@ -0,0 +1,65 @@
+package dsdata
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"strings"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// ProbeResult is the result of a single synthetic request of a delivery service, made through a single cache.
+type ProbeResult struct {
+	Cache     tc.CacheName `json:"cache"`
+	Status    int          `json:"status"`
+	LatencyMs float64      `json:"latency_ms"`
+	Success   bool         `json:"success"`
+	Error     string       `json:"error,omitempty"`
+}
+
+// ProbeResults is the most recent round of synthetic probe results for a delivery service.
+type ProbeResults struct {
+	Time      time.Time     `json:"time"`
+	Available bool          `json:"available"`
+	Results   []ProbeResult `json:"results"`
+}
+
+// Successes returns the number of probe requests which succeeded.
+func (p ProbeResults) Successes() int {
+	successes := 0
+	for _, result := range p.Results {
+		if result.Success {
+			successes++
+		}
+	}
+	return successes
+}
+
+// ErrorStr returns a human-readable description of the failed probe requests, or the empty string if none failed.
+func (p ProbeResults) ErrorStr() string {
+	errs := []string{}
+	for _, result := range p.Results {
+		if !result.Success {
+			errs = append(errs, string(result.Cache)+": "+result.Error)
+		}
+	}
+	return strings.Join(errs, "; ")
+}
@@ -121,6 +121,7 @@ type StatCommon struct {
 	IsAvailable         StatBool              `json:"is_available"`
 	CachesAvailableNum  StatInt               `json:"caches_available"`
 	CachesDisabled      []string              `json:"disabled_locations"`
+	Probe               *ProbeResults         `json:"probe,omitempty"`
 }
 
 // Copy returns a deep copy of this StatCommon object.
@@ -441,6 +442,12 @@ func addCommonData(s *StatsOld, c *StatCommon, deliveryService tc.DeliveryServic
 	add("isAvailable", fmt.Sprintf("%t", c.IsAvailable.Value))
 	add("caches-available", fmt.Sprintf("%d", c.CachesAvailableNum.Value))
 	add("disabledLocations", c.CachesDisabled)
+	if c.Probe != nil {
+		add("probe-available", fmt.Sprintf("%t", c.Probe.Available))
+		add("probe-caches", fmt.Sprintf("%d", len(c.Probe.Results)))
+		add("probe-successes", fmt.Sprintf("%d", c.Probe.Successes()))
+		add("probe-error", c.Probe.ErrorStr())
+	}
 	return s
 }
 
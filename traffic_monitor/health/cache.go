@@ -276,6 +276,7 @@ func CalcAvailability(
 	toData todata.TOData,
 	localCacheStatusThreadsafe threadsafe.CacheAvailableStatus,
 	localStates peer.CRStatesThreadsafe,
+	dsProbeResults threadsafe.DSProbeResults,
 	events ThreadsafeEvents,
 	protocol config.PollingProtocol,
 ) {
@@ -388,7 +389,7 @@ func CalcAvailability(
 
 		localCacheStatuses[result.ID] = availStatus
 	}
-	calculateDeliveryServiceState(localStates)
+	calculateDeliveryServiceState(localStates, dsProbeResults)
 	localCacheStatusThreadsafe.Set(localCacheStatuses)
 }
 
@@ -433,12 +434,16 @@ func eventDesc(status tc.CacheStatus, message string) string {
 }
 
 // calculateDeliveryServiceState calculates the state of delivery services from the new cache state data `cacheState` and the CRConfig data `deliveryServiceServers` and puts the calculated state in the outparam `deliveryServiceStates`
-func calculateDeliveryServiceState(states peer.CRStatesThreadsafe) {
+// Delivery services whose synthetic probes failed are marked unavailable, regardless of the availability of their caches.
+func calculateDeliveryServiceState(states peer.CRStatesThreadsafe, dsProbeResults threadsafe.DSProbeResults) {
 	deliveryServices := states.GetDeliveryServices()
 	for deliveryServiceName, deliveryServiceState := range deliveryServices {
 		// NOTE: DisabledLocations is always empty, and it's important that it isn't nil, so it serialises to the JSON `[]` instead of `null`.
 		// It's no longer populated due to it being an unnecessary optimization for Traffic Router, but the field is kept for compatibility.
 		deliveryServiceState.DisabledLocations = []tc.CacheGroupName{}
+		if probe, ok := dsProbeResults.GetDeliveryService(deliveryServiceName); ok && !probe.Available {
+			deliveryServiceState.IsAvailable = false
+		}
 		states.SetDeliveryService(deliveryServiceName, deliveryServiceState)
 	}
 }
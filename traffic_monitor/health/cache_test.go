@@ -26,6 +26,7 @@ import (
 
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/cache"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/config"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/dsdata"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/peer"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/threadsafe"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/todata"
@@ -428,10 +429,10 @@ func TestCalcAvailabilityThresholds(t *testing.T) {
 	original := results[0].Statistics.Interfaces
 	statResultHistory := (*threadsafe.ResultStatHistory)(nil)
 	results[0].Statistics.Interfaces = make(map[string]cache.Interface)
-	CalcAvailability(results, pollerName, statResultHistory, mc, toData, localCacheStatusThreadsafe, localStates, events, config.Both)
+	CalcAvailability(results, pollerName, statResultHistory, mc, toData, localCacheStatusThreadsafe, localStates, threadsafe.NewDSProbeResults(), events, config.Both)
 	results[0].Statistics.Interfaces = original
 
-	CalcAvailability(results, pollerName, statResultHistory, mc, toData, localCacheStatusThreadsafe, localStates, events, config.Both)
+	CalcAvailability(results, pollerName, statResultHistory, mc, toData, localCacheStatusThreadsafe, localStates, threadsafe.NewDSProbeResults(), events, config.Both)
 
 	// ensure that the DisabledLocations is an empty, non-nil slice
 	for _, ds := range localStates.GetDeliveryServices() {
@@ -478,7 +479,7 @@ func TestCalcAvailabilityThresholds(t *testing.T) {
 	GetVitals(&healthResult, &result, nil)
 	healthPollerName := "health"
 	healthResults := []cache.Result{healthResult}
-	CalcAvailability(healthResults, healthPollerName, nil, mc, toData, localCacheStatusThreadsafe, localStates, events, config.Both)
+	CalcAvailability(healthResults, healthPollerName, nil, mc, toData, localCacheStatusThreadsafe, localStates, threadsafe.NewDSProbeResults(), events, config.Both)
 
 	localCacheStatuses = localCacheStatusThreadsafe.Get()
 	if _, ok := localCacheStatuses[result.ID]; !ok {
@@ -575,3 +576,28 @@ func TestEvalInterface(t *testing.T) {
 		t.Errorf("Incorrect reason for interface exceeding threshold to be unavailable; expected: 'maximum bandwidth exceeded', got: '%s'", why)
 	}
 }
+
+func TestCalculateDeliveryServiceStateProbes(t *testing.T) {
+	states := peer.NewCRStatesThreadsafe()
+	states.SetDeliveryService("probe-ok", tc.CRStatesDeliveryService{IsAvailable: true})
+	states.SetDeliveryService("probe-failed", tc.CRStatesDeliveryService{IsAvailable: true})
+	states.SetDeliveryService("not-probed", tc.CRStatesDeliveryService{IsAvailable: true})
+
+	probes := threadsafe.NewDSProbeResults()
+	probes.Set(map[tc.DeliveryServiceName]dsdata.ProbeResults{
+		"probe-ok":     {Time: time.Now(), Available: true},
+		"probe-failed": {Time: time.Now(), Available: false},
+	})
+
+	calculateDeliveryServiceState(states, probes)
+
+	for ds, expected := range map[tc.DeliveryServiceName]bool{"probe-ok": true, "probe-failed": false, "not-probed": true} {
+		state, _ := states.GetDeliveryService(ds)
+		if state.IsAvailable != expected {
+			t.Errorf("expected delivery service '%s' available %t, actual: %t", ds, expected, state.IsAvailable)
+		}
+		if state.DisabledLocations == nil {
+			t.Errorf("expected delivery service '%s' disabled locations to be non-nil", ds)
+		}
+	}
+}
@@ -67,6 +67,7 @@ type ThreadsafeEvents struct {
 	m         *sync.RWMutex
 	nextIndex *uint64
 	max       uint64
+	file      *EventFile
 }
 
 func copyEvents(a []Event) []Event {
@@ -81,6 +82,31 @@ func NewThreadsafeEvents(maxEvents uint64) ThreadsafeEvents {
 	return ThreadsafeEvents{m: &sync.RWMutex{}, events: &[]Event{}, nextIndex: &i, max: maxEvents}
 }
 
+// NewPersistentThreadsafeEvents creates a new single-writer-multiple-reader Threadsafe object, which additionally appends every added Event to the given EventFile.
+// The most recent maxEvents events in the file are loaded into memory, and event indices continue from the last persisted event, so the event log survives restarts.
+func NewPersistentThreadsafeEvents(maxEvents uint64, file *EventFile) (ThreadsafeEvents, error) {
+	o := NewThreadsafeEvents(maxEvents)
+	o.file = file
+
+	persisted, err := file.Read(nil, int(maxEvents), 0)
+	if err != nil {
+		return o, fmt.Errorf("loading persisted events: %v", err)
+	}
+	if len(persisted) == 0 {
+		return o, nil
+	}
+	*o.nextIndex = persisted[len(persisted)-1].Index + 1
+	if uint64(len(persisted)) > maxEvents {
+		persisted = persisted[uint64(len(persisted))-maxEvents:]
+	}
+	events := make([]Event, 0, len(persisted))
+	for i := len(persisted) - 1; i >= 0; i-- {
+		events = append(events, persisted[i])
+	}
+	*o.events = events
+	return o, nil
+}
+
 // Get returns the internal slice of Events for reading. This MUST NOT be modified. If modification is necessary, copy the slice.
 func (o *ThreadsafeEvents) Get() []Event {
 	o.m.RLock()
@@ -103,4 +129,36 @@ func (o *ThreadsafeEvents) Add(e Event) {
 	*o.events = events
 	*o.nextIndex++
 	o.m.Unlock()
+
+	// Written without the lock, so readers aren't blocked by the file. Events are still written in order, because there's a single writer.
+	if o.file != nil {
+		if err := o.file.Write(e); err != nil {
+			log.Errorf("persisting event: %v", err)
+		}
+	}
+}
+
+// Query returns the events for which the given filter returns true, newest first. If limit is positive, at most limit events are returned. If the events are persisted, the newest QueryMaxReadBytes of the persisted log is searched, which may include events far older than the in-memory maximum; otherwise, only the in-memory events are searched.
+func (o *ThreadsafeEvents) Query(filter func(Event) bool, limit int) ([]Event, error) {
+	if o.file == nil {
+		matched := []Event{}
+		for _, e := range o.Get() {
+			if limit > 0 && len(matched) >= limit {
+				break
+			}
+			if filter(e) {
+				matched = append(matched, e)
+			}
+		}
+		return matched, nil
+	}
+
+	persisted, err := o.file.Read(filter, limit, QueryMaxReadBytes)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(persisted)-1; i < j; i, j = i+1, j-1 {
+		persisted[i], persisted[j] = persisted[j], persisted[i]
+	}
+	return persisted, nil
 }
@@ -0,0 +1,252 @@
+package health
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// EventFile is an append-only file of Events, one JSON object per line. When the file exceeds its maximum size, it is rotated to path.1, path.1 to path.2, and so on, with the oldest file beyond the maximum number of files being removed.
+type EventFile struct {
+	path     string
+	maxBytes int64
+	maxFiles int
+	m        *sync.Mutex
+	file     *os.File
+	size     int64
+}
+
+// OpenEventFile opens, or creates, the event file at the given path. If maxBytes is 0, the file is never rotated. The maxFiles is the number of rotated files to keep, in addition to the current file.
+func OpenEventFile(path string, maxBytes int64, maxFiles int) (*EventFile, error) {
+	if path == "" {
+		return nil, errors.New("event file path must not be empty")
+	}
+	f := &EventFile{path: path, maxBytes: maxBytes, maxFiles: maxFiles, m: &sync.Mutex{}}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *EventFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("opening event file '%s': %v", f.path, err)
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("getting event file '%s' size: %v", f.path, err)
+	}
+	f.file = file
+	f.size = fi.Size()
+	return nil
+}
+
+// Write appends the given Event to the file, rotating first if the file has reached its maximum size.
+func (f *EventFile) Write(e Event) error {
+	json := jsoniter.ConfigFastest
+	bts, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshalling event: %v", err)
+	}
+	bts = append(bts, '\n')
+
+	f.m.Lock()
+	defer f.m.Unlock()
+	if f.maxBytes > 0 && f.size > 0 && f.size+int64(len(bts)) > f.maxBytes {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := f.file.Write(bts)
+	f.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("writing event file '%s': %v", f.path, err)
+	}
+	return nil
+}
+
+// rotate closes the current file, shifts all rotated files up by one, and opens a new empty file. It MUST be called with the mutex held.
+func (f *EventFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		log.Errorf("closing event file '%s' for rotation: %v", f.path, err)
+	}
+	if f.maxFiles < 1 {
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing event file '%s' for rotation: %v", f.path, err)
+		}
+		return f.open()
+	}
+	if err := os.Remove(f.rotatedPath(f.maxFiles)); err != nil && !os.IsNotExist(err) {
+		log.Errorf("removing oldest event file '%s': %v", f.rotatedPath(f.maxFiles), err)
+	}
+	for i := f.maxFiles - 1; i >= 1; i-- {
+		if err := os.Rename(f.rotatedPath(i), f.rotatedPath(i+1)); err != nil && !os.IsNotExist(err) {
+			log.Errorf("rotating event file '%s': %v", f.rotatedPath(i), err)
+		}
+	}
+	if err := os.Rename(f.path, f.rotatedPath(1)); err != nil {
+		return fmt.Errorf("rotating event file '%s': %v", f.path, err)
+	}
+	return f.open()
+}
+
+func (f *EventFile) rotatedPath(i int) string {
+	return f.path + "." + strconv.Itoa(i)
+}
+
+// QueryMaxReadBytes is the most of the newest persisted events a query reads, so a query for rare events can't read every rotated file.
+const QueryMaxReadBytes = 100 * 1024 * 1024
+
+// eventFileSnapshot is an opened event file, and the size to read it to.
+type eventFileSnapshot struct {
+	file *os.File
+	size int64
+}
+
+// Read returns the newest Events in the current and rotated files for which the given filter returns true, oldest first. A nil filter returns all Events. Lines which fail to parse are logged and skipped.
+//
+// If maxEvents is positive, at most the newest maxEvents matching Events are returned, and older files aren't read once that many are found. If maxBytes is positive, at most the newest maxBytes of the files are read.
+//
+// The files are opened with the lock held, so they can't be rotated away, but are read without it, so reading never blocks writing.
+func (f *EventFile) Read(filter func(Event) bool, maxEvents int, maxBytes int64) ([]Event, error) {
+	snapshots, err := f.snapshot()
+	defer func() {
+		for _, snapshot := range snapshots {
+			snapshot.file.Close()
+		}
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	// newestFirst holds the matching events of each file, newest file first, each oldest first.
+	newestFirst := [][]Event{}
+	numEvents := 0
+	for _, snapshot := range snapshots {
+		offset := int64(0)
+		if maxBytes > 0 && snapshot.size > maxBytes {
+			offset = snapshot.size - maxBytes
+		}
+		events, err := readEventFile(snapshot.file, offset, snapshot.size, filter)
+		if err != nil {
+			return nil, err
+		}
+		newestFirst = append(newestFirst, events)
+		numEvents += len(events)
+		if maxBytes > 0 {
+			if maxBytes -= snapshot.size - offset; maxBytes <= 0 {
+				break
+			}
+		}
+		if maxEvents > 0 && numEvents >= maxEvents {
+			break
+		}
+	}
+
+	events := make([]Event, 0, numEvents)
+	for i := len(newestFirst) - 1; i >= 0; i-- {
+		events = append(events, newestFirst[i]...)
+	}
+	if maxEvents > 0 && len(events) > maxEvents {
+		events = events[len(events)-maxEvents:]
+	}
+	return events, nil
+}
+
+// snapshot opens the current and rotated files which exist, newest first, with their current sizes.
+func (f *EventFile) snapshot() ([]eventFileSnapshot, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	paths := []string{f.path}
+	for i := 1; i <= f.maxFiles; i++ {
+		paths = append(paths, f.rotatedPath(i))
+	}
+
+	snapshots := []eventFileSnapshot{}
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return snapshots, fmt.Errorf("opening event file '%s': %v", path, err)
+		}
+		fi, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return snapshots, fmt.Errorf("getting event file '%s' size: %v", path, err)
+		}
+		snapshots = append(snapshots, eventFileSnapshot{file: file, size: fi.Size()})
+	}
+	return snapshots, nil
+}
+
+// readEventFile returns the Events in the given file from offset to size for which the filter returns true. If offset isn't 0, the line it falls in is skipped, unless it starts at offset.
+func readEventFile(file *os.File, offset int64, size int64, filter func(Event) bool) ([]Event, error) {
+	json := jsoniter.ConfigFastest
+	events := []Event{}
+	if offset > 0 {
+		offset-- // start at the preceding byte, so the skipped line is empty if offset is the start of a line
+	}
+	scanner := bufio.NewScanner(io.NewSectionReader(file, offset, size-offset))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	skipLine := offset > 0
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if skipLine {
+			skipLine = false
+			continue
+		}
+		if len(line) == 0 {
+			continue
+		}
+		e := Event{}
+		if err := json.Unmarshal(line, &e); err != nil {
+			log.Warnf("event file '%s': skipping malformed event: %v", file.Name(), err)
+			continue
+		}
+		if filter == nil || filter(e) {
+			events = append(events, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading event file '%s': %v", file.Name(), err)
+	}
+	return events, nil
+}
+
+// Close closes the underlying file. The EventFile must not be written to after it is closed.
+func (f *EventFile) Close() error {
+	f.m.Lock()
+	defer f.m.Unlock()
+	return f.file.Close()
+}
@@ -0,0 +1,166 @@
+package health
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEventFileRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	f, err := OpenEventFile(path, 512, 2)
+	if err != nil {
+		t.Fatalf("opening event file: %v", err)
+	}
+	defer f.Close()
+
+	const numEvents = 20
+	for i := 0; i < numEvents; i++ {
+		e := Event{Time: Time(time.Unix(int64(i), 0)), Index: uint64(i), Name: "cache" + strconv.Itoa(i), Type: "EDGE", Description: "REPORTED"}
+		if err := f.Write(e); err != nil {
+			t.Fatalf("writing event %d: %v", i, err)
+		}
+	}
+
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Errorf("expected rotated file '%s.1', actual: %v", path, err)
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected rotated file '%s.3' to not exist beyond max files, actual: %v", path, err)
+	}
+
+	events, err := f.Read(nil, 0, 0)
+	if err != nil {
+		t.Fatalf("reading event file: %v", err)
+	}
+	if len(events) == 0 || len(events) >= numEvents {
+		t.Fatalf("expected some but not all events to be retained after rotation, actual: %d", len(events))
+	}
+	if last := events[len(events)-1]; last.Index != numEvents-1 {
+		t.Errorf("expected last event index %d, actual: %d", numEvents-1, last.Index)
+	}
+	for i := 1; i < len(events); i++ {
+		if events[i].Index != events[i-1].Index+1 {
+			t.Errorf("expected events oldest first and contiguous, actual: index %d followed by %d", events[i-1].Index, events[i].Index)
+		}
+	}
+}
+
+func TestPersistentThreadsafeEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	f, err := OpenEventFile(path, 0, 0)
+	if err != nil {
+		t.Fatalf("opening event file: %v", err)
+	}
+	events, err := NewPersistentThreadsafeEvents(2, f)
+	if err != nil {
+		t.Fatalf("creating persistent events: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		events.Add(Event{Time: Time(time.Unix(int64(i), 0)), Name: "cache" + strconv.Itoa(i%2), Type: "EDGE"})
+	}
+	f.Close()
+
+	// simulate a restart
+	f, err = OpenEventFile(path, 0, 0)
+	if err != nil {
+		t.Fatalf("reopening event file: %v", err)
+	}
+	defer f.Close()
+	events, err = NewPersistentThreadsafeEvents(2, f)
+	if err != nil {
+		t.Fatalf("creating persistent events from existing file: %v", err)
+	}
+
+	if inMem := events.Get(); len(inMem) != 2 || inMem[0].Index != 4 || inMem[1].Index != 3 {
+		t.Errorf("expected in-memory events loaded newest first with indices [4 3], actual: %+v", inMem)
+	}
+
+	events.Add(Event{Time: Time(time.Unix(5, 0)), Name: "cache1", Type: "EDGE"})
+	if newest := events.Get()[0]; newest.Index != 5 {
+		t.Errorf("expected index to continue from persisted events, expected: 5, actual: %d", newest.Index)
+	}
+
+	matched, err := events.Query(func(e Event) bool { return e.Name == "cache1" }, 0)
+	if err != nil {
+		t.Fatalf("querying events: %v", err)
+	}
+	if len(matched) != 3 {
+		t.Fatalf("expected 3 events for cache1 beyond the in-memory max, actual: %d", len(matched))
+	}
+	if matched[0].Index != 5 || matched[2].Index != 1 {
+		t.Errorf("expected queried events newest first, actual: %+v", matched)
+	}
+}
+
+func TestEventFileReadLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	f, err := OpenEventFile(path, 512, 20)
+	if err != nil {
+		t.Fatalf("opening event file: %v", err)
+	}
+	defer f.Close()
+
+	const numEvents = 40
+	for i := 0; i < numEvents; i++ {
+		e := Event{Time: Time(time.Unix(int64(i), 0)), Index: uint64(i), Name: "cache" + strconv.Itoa(i%2), Type: "EDGE", Description: "REPORTED"}
+		if err := f.Write(e); err != nil {
+			t.Fatalf("writing event %d: %v", i, err)
+		}
+	}
+
+	events, err := f.Read(func(e Event) bool { return e.Name == "cache1" }, 3, 0)
+	if err != nil {
+		t.Fatalf("reading event file: %v", err)
+	}
+	if len(events) != 3 || events[0].Index != numEvents-5 || events[2].Index != numEvents-1 {
+		t.Errorf("expected the newest 3 matching events [35 37 39] oldest first, actual: %+v", events)
+	}
+
+	all, err := f.Read(nil, 0, 0)
+	if err != nil {
+		t.Fatalf("reading event file: %v", err)
+	}
+	if len(all) != numEvents {
+		t.Fatalf("expected all %d events, actual: %d", numEvents, len(all))
+	}
+
+	// limiting the bytes read must return only the newest, whole, events
+	for _, maxBytes := range []int64{300, 512, 700, 2000} {
+		limited, err := f.Read(nil, 0, maxBytes)
+		if err != nil {
+			t.Fatalf("reading event file: %v", err)
+		}
+		if len(limited) == 0 || len(limited) >= numEvents {
+			t.Errorf("expected some but not all events reading %d bytes, actual: %d", maxBytes, len(limited))
+			continue
+		}
+		for i, e := range limited {
+			if expected := all[len(all)-len(limited)+i]; e != expected {
+				t.Errorf("expected the newest events reading %d bytes, actual event %d: %+v expected %+v", maxBytes, i, e, expected)
+				break
+			}
+		}
+	}
+}
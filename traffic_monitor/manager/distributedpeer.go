@@ -41,6 +41,7 @@ func StartDistributedPeerManager(
 			compareDistributedPeerState(events, distributedPeerResult, distributedPeerStates)
 			distributedPeerStates.Set(distributedPeerResult)
 			for name, availability := range distributedPeerResult.PeerStates.Caches {
+				availability.DirectlyPolled = false // the peer polled it, so it's not served as locally polled
 				localStates.SetCache(name, availability)
 			}
 			if len(distributedPeerResult.Errors) == 0 {
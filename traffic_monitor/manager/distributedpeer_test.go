@@ -0,0 +1,62 @@
+package manager
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/health"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/peer"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/threadsafe"
+)
+
+func TestDistributedPeerCachesNotDirectlyPolled(t *testing.T) {
+	distributedPeerChan := make(chan peer.Result)
+	localStates := peer.NewCRStatesThreadsafe()
+	localStates.AddCache("cache0", tc.IsAvailable{DirectlyPolled: true})
+	StartDistributedPeerManager(distributedPeerChan, localStates, peer.NewCRStatesPeersThreadsafe(0), health.NewThreadsafeEvents(10), threadsafe.NewUnpolledCaches())
+
+	pollFinished := make(chan uint64, 1)
+	states := tc.NewCRStates(1, 0)
+	states.Caches["cache0"] = tc.IsAvailable{IsAvailable: true, DirectlyPolled: true}
+	distributedPeerChan <- peer.Result{
+		ID:           "tm-group-1",
+		Available:    true,
+		PeerStates:   states,
+		PollID:       1,
+		PollFinished: pollFinished,
+		Time:         time.Now(),
+	}
+	<-pollFinished
+	close(distributedPeerChan)
+
+	availability, ok := localStates.GetCache("cache0")
+	if !ok {
+		t.Fatal("expected the distributed peer's cache in the local states")
+	}
+	if !availability.IsAvailable {
+		t.Error("expected the distributed peer's cache to be available")
+	}
+	if availability.DirectlyPolled {
+		t.Error("expected a cache polled by a distributed peer not to be directly polled")
+	}
+}
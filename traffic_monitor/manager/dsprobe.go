@@ -0,0 +1,255 @@
+package manager
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/config"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/dsdata"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/health"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/peer"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/threadsafe"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/todata"
+)
+
+// StartDSProbeManager starts making the configured synthetic delivery service probes on the configured interval, and returns the latest probe results. If no probes are configured, no probes are made and the returned results are always empty.
+func StartDSProbeManager(
+	cfg config.Config,
+	appData config.StaticAppData,
+	toData todata.TODataThreadsafe,
+	monitorConfig threadsafe.TrafficMonitorConfigMap,
+	combinedStates peer.CRStatesThreadsafe,
+	events health.ThreadsafeEvents,
+) threadsafe.DSProbeResults {
+	probeResults := threadsafe.NewDSProbeResults()
+	if len(cfg.DeliveryServiceProbes) == 0 {
+		return probeResults
+	}
+	clients := make([]*http.Client, len(cfg.DeliveryServiceProbes))
+	for i, probe := range cfg.DeliveryServiceProbes {
+		clients[i] = newProbeClient(probe, cfg.HTTPTimeout)
+	}
+	go func() {
+		tick := time.NewTicker(cfg.DeliveryServiceProbeInterval)
+		defer tick.Stop()
+		for range tick.C {
+			results := probeDeliveryServices(cfg.DeliveryServiceProbes, clients, appData.UserAgent, toData.Get(), monitorConfig.Get(), combinedStates.GetCaches())
+			addProbeEvents(events, probeResults.Get(), results)
+			probeResults.Set(results)
+		}
+	}()
+	return probeResults
+}
+
+// newProbeClient returns an HTTP client for the given probe. Redirects are not followed, so they may be checked as the expected status.
+func newProbeClient(probe config.DeliveryServiceProbe, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				ServerName:         probe.Host,
+				InsecureSkipVerify: !probe.ValidateTLS,
+			},
+			DisableKeepAlives: true,
+		},
+		Timeout: timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// probeDeliveryServices makes all the given probes concurrently, and returns the results of each probed delivery service. Probes through no caches are omitted. If multiple probes are configured for the same delivery service, it is available only if all of them are.
+func probeDeliveryServices(
+	probes []config.DeliveryServiceProbe,
+	clients []*http.Client,
+	userAgent string,
+	toData todata.TOData,
+	mc tc.TrafficMonitorConfigMap,
+	caches map[tc.CacheName]tc.IsAvailable,
+) map[tc.DeliveryServiceName]dsdata.ProbeResults {
+	probeResults := make([]dsdata.ProbeResults, len(probes))
+	wg := sync.WaitGroup{}
+	for i, probe := range probes {
+		servers := selectProbeCaches(probe, toData, mc, caches)
+		if len(servers) == 0 {
+			log.Warnf("delivery service '%s' probe: no available caches to probe through, skipping", probe.DeliveryService)
+			continue
+		}
+		wg.Add(1)
+		go func(i int, probe config.DeliveryServiceProbe, servers []tc.TrafficServer) {
+			defer wg.Done()
+			probeResults[i] = probeDeliveryService(probe, clients[i], userAgent, servers)
+		}(i, probe, servers)
+	}
+	wg.Wait()
+
+	results := map[tc.DeliveryServiceName]dsdata.ProbeResults{}
+	for i, probe := range probes {
+		result := probeResults[i]
+		if result.Time.IsZero() {
+			continue
+		}
+		ds := tc.DeliveryServiceName(probe.DeliveryService)
+		if existing, ok := results[ds]; ok {
+			result.Available = result.Available && existing.Available
+			result.Results = append(existing.Results, result.Results...)
+		}
+		results[ds] = result
+	}
+	return results
+}
+
+// selectProbeCaches returns the caches through which to make the given probe. These are the probe's configured caches if it has any, otherwise the first of the available edge caches assigned to the delivery service, sorted by name.
+func selectProbeCaches(probe config.DeliveryServiceProbe, toData todata.TOData, mc tc.TrafficMonitorConfigMap, caches map[tc.CacheName]tc.IsAvailable) []tc.TrafficServer {
+	servers := []tc.TrafficServer{}
+	if len(probe.Caches) > 0 {
+		for _, name := range probe.Caches {
+			srv, ok := mc.TrafficServer[name]
+			if !ok {
+				log.Warnf("delivery service '%s' probe: cache '%s' not found in monitoring config, skipping", probe.DeliveryService, name)
+				continue
+			}
+			servers = append(servers, srv)
+		}
+		return servers
+	}
+
+	names := []string{}
+	for _, name := range toData.DeliveryServiceServers[tc.DeliveryServiceName(probe.DeliveryService)] {
+		if toData.ServerTypes[name] != tc.CacheTypeEdge || !caches[name].IsAvailable {
+			continue
+		}
+		if _, ok := mc.TrafficServer[string(name)]; !ok {
+			continue
+		}
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	if len(names) > probe.MaxCaches {
+		names = names[:probe.MaxCaches]
+	}
+	for _, name := range names {
+		servers = append(servers, mc.TrafficServer[name])
+	}
+	return servers
+}
+
+// probeDeliveryService makes the given probe through each of the given caches. The delivery service is available if the probe succeeded through any cache.
+func probeDeliveryService(probe config.DeliveryServiceProbe, client *http.Client, userAgent string, servers []tc.TrafficServer) dsdata.ProbeResults {
+	results := dsdata.ProbeResults{Time: time.Now(), Results: make([]dsdata.ProbeResult, 0, len(servers))}
+	for _, srv := range servers {
+		result := probeCache(probe, client, userAgent, srv)
+		results.Available = results.Available || result.Success
+		results.Results = append(results.Results, result)
+	}
+	return results
+}
+
+// probeCache makes a single probe request through the given cache.
+func probeCache(probe config.DeliveryServiceProbe, client *http.Client, userAgent string, srv tc.TrafficServer) dsdata.ProbeResult {
+	result := dsdata.ProbeResult{Cache: tc.CacheName(srv.HostName)}
+	probeURL, err := probeURL(probe, srv)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	req, err := http.NewRequest(http.MethodGet, probeURL, nil)
+	if err != nil {
+		result.Error = "creating request: " + err.Error()
+		return result
+	}
+	req.Host = probe.Host
+	req.Header.Set("User-Agent", userAgent)
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	result.LatencyMs = float64(time.Since(start)) / float64(time.Millisecond)
+	if err != nil {
+		result.Error = "requesting: " + err.Error()
+		return result
+	}
+	resp.Body.Close()
+	result.Status = resp.StatusCode
+
+	if resp.StatusCode != probe.ExpectedStatus {
+		result.Error = fmt.Sprintf("status %d, expected %d", resp.StatusCode, probe.ExpectedStatus)
+	} else if probe.MaxLatencyMs != 0 && result.LatencyMs > float64(probe.MaxLatencyMs) {
+		result.Error = fmt.Sprintf("latency %.0fms exceeded %dms", result.LatencyMs, probe.MaxLatencyMs)
+	} else {
+		result.Success = true
+	}
+	return result
+}
+
+// probeURL returns the URL of the given probe's request through the given cache, preferring the cache's IPv4 address.
+func probeURL(probe config.DeliveryServiceProbe, srv tc.TrafficServer) (string, error) {
+	host := srv.IPv4()
+	if host == "" {
+		host = ipv6CIDRStrToAddr(srv.IPv6())
+	}
+	if host == "" {
+		return "", fmt.Errorf("cache '%s' has no service address", srv.HostName)
+	}
+	port := srv.Port
+	if probe.Scheme == "https" {
+		port = srv.HTTPSPort
+	}
+	if port != 0 {
+		host = net.JoinHostPort(host, strconv.Itoa(port))
+	} else if srv.IPv4() == "" {
+		host = "[" + host + "]"
+	}
+	return probe.Scheme + "://" + host + probe.Path, nil
+}
+
+// addProbeEvents adds an event for each delivery service whose probe availability changed.
+func addProbeEvents(events health.ThreadsafeEvents, oldResults map[tc.DeliveryServiceName]dsdata.ProbeResults, newResults map[tc.DeliveryServiceName]dsdata.ProbeResults) {
+	for ds, result := range newResults {
+		old, ok := oldResults[ds]
+		if ok && old.Available == result.Available {
+			continue
+		}
+		if !ok && result.Available {
+			continue
+		}
+		desc := "Synthetic probe succeeded"
+		if !result.Available {
+			desc = "Synthetic probe failed: " + result.ErrorStr()
+		}
+		events.Add(health.Event{
+			Time:        health.Time(time.Now()),
+			Description: desc,
+			Name:        ds.String(),
+			Hostname:    ds.String(),
+			Type:        health.DeliveryServiceEventType,
+			Available:   result.Available,
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package manager
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/config"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/todata"
+)
+
+func probeTestServer(t *testing.T, name string, serverURL string) tc.TrafficServer {
+	host, portStr, err := net.SplitHostPort(serverURL[len("http://"):])
+	if err != nil {
+		t.Fatalf("splitting test server address '%s': %v", serverURL, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatalf("parsing test server port '%s': %v", portStr, err)
+	}
+	return tc.TrafficServer{
+		HostName: name,
+		Port:     port,
+		Type:     string(tc.CacheTypeEdge),
+		Interfaces: []tc.ServerInterfaceInfo{
+			{
+				Name:        "eth0",
+				Monitor:     true,
+				IPAddresses: []tc.ServerIPAddress{{Address: host, ServiceAddress: true}},
+			},
+		},
+	}
+}
+
+func TestProbeDeliveryService(t *testing.T) {
+	gotHost := ""
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHost = r.Host
+		switch r.URL.Path {
+		case "/ok":
+			w.WriteHeader(http.StatusOK)
+		case "/slow":
+			time.Sleep(50 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer origin.Close()
+
+	servers := []tc.TrafficServer{probeTestServer(t, "edge0", origin.URL)}
+	probe := config.DeliveryServiceProbe{DeliveryService: "ds0", Host: "ds0.example.net", Path: "/ok", Scheme: "http", ExpectedStatus: http.StatusOK}
+
+	results := probeDeliveryService(probe, newProbeClient(probe, time.Second), "test", servers)
+	if !results.Available || len(results.Results) != 1 || !results.Results[0].Success {
+		t.Fatalf("expected probe to succeed, actual: %+v", results)
+	}
+	if gotHost != "ds0.example.net" {
+		t.Errorf("expected probe Host header 'ds0.example.net', actual: '%s'", gotHost)
+	}
+	if results.Results[0].Cache != "edge0" || results.Results[0].Status != http.StatusOK {
+		t.Errorf("expected probe result cache 'edge0' status 200, actual: %+v", results.Results[0])
+	}
+
+	probe.Path = "/broken"
+	if results = probeDeliveryService(probe, newProbeClient(probe, time.Second), "test", servers); results.Available {
+		t.Errorf("expected probe with unexpected status to fail, actual: %+v", results)
+	} else if results.ErrorStr() != "edge0: status 502, expected 200" {
+		t.Errorf("expected status error, actual: '%s'", results.ErrorStr())
+	}
+
+	probe.Path = "/slow"
+	probe.MaxLatencyMs = 1
+	if results = probeDeliveryService(probe, newProbeClient(probe, time.Second), "test", servers); results.Available {
+		t.Errorf("expected probe exceeding max latency to fail, actual: %+v", results)
+	}
+}
+
+func TestProbeDeliveryServicesAnyCacheAvailable(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ok.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer broken.Close()
+
+	mc := tc.TrafficMonitorConfigMap{TrafficServer: map[string]tc.TrafficServer{
+		"edge0": probeTestServer(t, "edge0", ok.URL),
+		"edge1": probeTestServer(t, "edge1", broken.URL),
+	}}
+	probes := []config.DeliveryServiceProbe{
+		{DeliveryService: "ds0", Host: "ds0.example.net", Path: "/", Scheme: "http", ExpectedStatus: http.StatusOK, Caches: []string{"edge0", "edge1"}},
+		{DeliveryService: "ds1", Host: "ds1.example.net", Path: "/", Scheme: "http", ExpectedStatus: http.StatusOK, Caches: []string{"edge1"}},
+		{DeliveryService: "ds2", Host: "ds2.example.net", Path: "/", Scheme: "http", ExpectedStatus: http.StatusOK, MaxCaches: 1},
+	}
+	clients := []*http.Client{}
+	for _, probe := range probes {
+		clients = append(clients, newProbeClient(probe, time.Second))
+	}
+
+	results := probeDeliveryServices(probes, clients, "test", *todata.New(), mc, map[tc.CacheName]tc.IsAvailable{})
+	if !results["ds0"].Available || len(results["ds0"].Results) != 2 {
+		t.Errorf("expected ds0 available through one of two caches, actual: %+v", results["ds0"])
+	}
+	if results["ds1"].Available {
+		t.Errorf("expected ds1 unavailable, actual: %+v", results["ds1"])
+	}
+	if _, ok := results["ds2"]; ok {
+		t.Errorf("expected ds2 with no caches to probe through to be omitted, actual: %+v", results["ds2"])
+	}
+}
+
+func TestSelectProbeCaches(t *testing.T) {
+	toData := todata.New()
+	toData.DeliveryServiceServers["ds0"] = []tc.CacheName{"edge3", "edge2", "edge1", "edge0", "mid0"}
+	toData.ServerTypes = map[tc.CacheName]tc.CacheType{"edge0": tc.CacheTypeEdge, "edge1": tc.CacheTypeEdge, "edge2": tc.CacheTypeEdge, "edge3": tc.CacheTypeEdge, "mid0": tc.CacheTypeMid}
+	mc := tc.TrafficMonitorConfigMap{TrafficServer: map[string]tc.TrafficServer{}}
+	for _, name := range []string{"edge0", "edge1", "edge2", "edge3", "mid0"} {
+		mc.TrafficServer[name] = tc.TrafficServer{HostName: name}
+	}
+	caches := map[tc.CacheName]tc.IsAvailable{
+		"edge0": {IsAvailable: false},
+		"edge1": {IsAvailable: true},
+		"edge2": {IsAvailable: true},
+		"edge3": {IsAvailable: true},
+		"mid0":  {IsAvailable: true},
+	}
+
+	probe := config.DeliveryServiceProbe{DeliveryService: "ds0", MaxCaches: 2}
+	servers := selectProbeCaches(probe, *toData, mc, caches)
+	if len(servers) != 2 || servers[0].HostName != "edge1" || servers[1].HostName != "edge2" {
+		t.Errorf("expected first two available edges [edge1 edge2], actual: %+v", servers)
+	}
+
+	probe.Caches = []string{"edge0", "nonexistent"}
+	servers = selectProbeCaches(probe, *toData, mc, caches)
+	if len(servers) != 1 || servers[0].HostName != "edge0" {
+		t.Errorf("expected configured cache edge0 regardless of availability, actual: %+v", servers)
+	}
+}
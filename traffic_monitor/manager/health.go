@@ -45,6 +45,7 @@ func StartHealthResultManager(
 	fetchCount threadsafe.Uint,
 	cfg config.Config,
 	events health.ThreadsafeEvents,
+	dsProbeResults threadsafe.DSProbeResults,
 	localCacheStatus threadsafe.CacheAvailableStatus,
 	cachesChanged <-chan struct{},
 	combineStates func(),
@@ -61,6 +62,7 @@ func StartHealthResultManager(
 		monitorConfig,
 		fetchCount,
 		events,
+		dsProbeResults,
 		localCacheStatus,
 		cfg,
 		healthUnpolledCaches,
@@ -79,6 +81,7 @@ func healthResultManagerListen(
 	monitorConfig threadsafe.TrafficMonitorConfigMap,
 	fetchCount threadsafe.Uint,
 	events health.ThreadsafeEvents,
+	dsProbeResults threadsafe.DSProbeResults,
 	localCacheStatus threadsafe.CacheAvailableStatus,
 	cfg config.Config,
 	healthUnpolledCaches threadsafe.UnpolledCaches,
@@ -110,6 +113,7 @@ func healthResultManagerListen(
 			monitorConfig,
 			fetchCount,
 			events,
+			dsProbeResults,
 			localCacheStatus,
 			lastHealthEndTimes,
 			healthHistory,
@@ -155,6 +159,7 @@ func processHealthResult(
 	monitorConfig threadsafe.TrafficMonitorConfigMap,
 	fetchCount threadsafe.Uint,
 	events health.ThreadsafeEvents,
+	dsProbeResults threadsafe.DSProbeResults,
 	localCacheStatusThreadsafe threadsafe.CacheAvailableStatus,
 	lastHealthEndTimes map[tc.CacheName]time.Time,
 	healthHistory threadsafe.ResultHistory,
@@ -199,7 +204,7 @@ func processHealthResult(
 
 	pollerName := "health"
 	statResultHistoryNil := (*threadsafe.ResultStatHistory)(nil) // health poller doesn't have stats
-	health.CalcAvailability(results, pollerName, statResultHistoryNil, monitorConfigCopy, toDataCopy, localCacheStatusThreadsafe, localStates, events, cfg.CachePollingProtocol)
+	health.CalcAvailability(results, pollerName, statResultHistoryNil, monitorConfigCopy, toDataCopy, localCacheStatusThreadsafe, localStates, dsProbeResults, events, cfg.CachePollingProtocol)
 	combineStates()
 
 	healthHistory.Set(healthHistoryCopy)
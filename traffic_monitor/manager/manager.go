@@ -25,6 +25,7 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"time"
 
 	"golang.org/x/sys/unix"
 
@@ -35,6 +36,7 @@ import (
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/health"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/peer"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/poller"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/recording"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/threadsafe"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/todata"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/towrap"
@@ -51,12 +53,26 @@ func Start(opsConfigFile string, cfg config.Config, appData config.StaticAppData
 
 	toData := todata.NewThreadsafe()
 
+	var recorder *recording.Recorder
+	if cfg.RecordFile != "" {
+		var err error
+		if recorder, err = recording.Create(cfg.RecordFile); err != nil {
+			return fmt.Errorf("creating record file: %v", err)
+		}
+		go flushRecording(recorder)
+	}
+
 	// makes results chan
 	cacheHealthHandler := cache.NewHandler()
-	// passes results chan to poller
-	cacheHealthPoller := poller.NewCache(true, cacheHealthHandler, cfg, appData)
 	cacheStatHandler := cache.NewPrecomputeHandler(toData)
-	cacheStatPoller := poller.NewCache(false, cacheStatHandler, cfg, appData)
+	healthPollHandler, statPollHandler := handler.Handler(cacheHealthHandler), handler.Handler(cacheStatHandler)
+	if recorder != nil {
+		healthPollHandler = recording.NewHandler(cacheHealthHandler, recorder, recording.KindHealth)
+		statPollHandler = recording.NewHandler(cacheStatHandler, recorder, recording.KindStat)
+	}
+	// passes results chan to poller
+	cacheHealthPoller := poller.NewCache(true, healthPollHandler, cfg, appData)
+	cacheStatPoller := poller.NewCache(false, statPollHandler, cfg, appData)
 	monitorConfigPoller := poller.NewMonitorConfig(cfg.MonitorConfigPollingInterval)
 	peerHandler := peer.NewHandler()
 	peerPoller := poller.NewPeer(peerHandler, cfg, appData)
@@ -74,6 +90,15 @@ func Start(opsConfigFile string, cfg config.Config, appData config.StaticAppData
 	}
 
 	events := health.NewThreadsafeEvents(cfg.MaxEvents)
+	if cfg.EventLogFile != "" {
+		eventFile, err := health.OpenEventFile(cfg.EventLogFile, int64(cfg.EventLogMaxSizeMB)*1024*1024, cfg.EventLogMaxFiles)
+		if err != nil {
+			return fmt.Errorf("opening event log file: %v", err)
+		}
+		if events, err = health.NewPersistentThreadsafeEvents(cfg.MaxEvents, eventFile); err != nil {
+			log.Errorf("event log file '%s': %v", cfg.EventLogFile, err)
+		}
+	}
 
 	var cachesChangedForStatMgr chan struct{}
 	var cachesChangedForHealthMgr chan struct{}
@@ -103,9 +128,12 @@ func Start(opsConfigFile string, cfg config.Config, appData config.StaticAppData
 		appData,
 		toSession,
 		toData,
+		recorder,
 	)
 
-	combinedStates, combineStateFunc := StartStateCombiner(events, peerStates, localStates, toData)
+	combinedStates, combinationVotes, combineStateFunc := StartStateCombiner(events, peerStates, localStates, toData, monitorConfig, cfg, appData.Hostname)
+
+	dsProbeResults := StartDSProbeManager(cfg, appData, toData, monitorConfig, combinedStates, events)
 
 	StartPeerManager(
 		peerHandler.ResultChannel,
@@ -123,6 +151,7 @@ func Start(opsConfigFile string, cfg config.Config, appData config.StaticAppData
 		cfg,
 		monitorConfig,
 		events,
+		dsProbeResults,
 		combineStateFunc,
 	)
 
@@ -134,6 +163,7 @@ func Start(opsConfigFile string, cfg config.Config, appData config.StaticAppData
 		fetchCount,
 		cfg,
 		events,
+		dsProbeResults,
 		localCacheStatus,
 		cachesChangedForHealthMgr,
 		combineStateFunc,
@@ -157,6 +187,7 @@ func Start(opsConfigFile string, cfg config.Config, appData config.StaticAppData
 		peerStates,
 		distributedPeerStates,
 		combinedStates,
+		combinationVotes,
 		statInfoHistory,
 		statResultHistory,
 		statMaxKbpses,
@@ -187,6 +218,18 @@ func Start(opsConfigFile string, cfg config.Config, appData config.StaticAppData
 	return nil
 }
 
+// RecordingFlushInterval is how often the record file is flushed to disk.
+const RecordingFlushInterval = time.Second
+
+// flushRecording periodically flushes the given recording, so it's complete up to the last interval if Traffic Monitor stops. Does not return.
+func flushRecording(recorder *recording.Recorder) {
+	for range time.Tick(RecordingFlushInterval) {
+		if err := recorder.Flush(); err != nil {
+			log.Errorf("flushing record file: %v", err)
+		}
+	}
+}
+
 // healthTickListener listens for health ticks, and writes to the health iteration variable. Does not return.
 func healthTickListener(cacheHealthTick <-chan uint64, healthIteration threadsafe.Uint) {
 	for i := range cacheHealthTick {
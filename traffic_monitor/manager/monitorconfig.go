@@ -35,6 +35,7 @@ import (
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/config"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/peer"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/poller"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/recording"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/threadsafe"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/todata"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/towrap"
@@ -135,6 +136,7 @@ func StartMonitorConfigManager(
 	staticAppData config.StaticAppData,
 	toSession towrap.TrafficOpsSessionThreadsafe,
 	toData todata.TODataThreadsafe,
+	recorder *recording.Recorder,
 ) threadsafe.TrafficMonitorConfigMap {
 	monitorConfig := threadsafe.NewTrafficMonitorConfigMap()
 	go monitorConfigListen(monitorConfig,
@@ -152,6 +154,7 @@ func StartMonitorConfigManager(
 		staticAppData,
 		toSession,
 		toData,
+		recorder,
 	)
 	return monitorConfig
 }
@@ -209,6 +212,7 @@ func monitorConfigListen(
 	staticAppData config.StaticAppData,
 	toSession towrap.TrafficOpsSessionThreadsafe,
 	toData todata.TODataThreadsafe,
+	recorder *recording.Recorder,
 ) {
 	defer func() {
 		if err := recover(); err != nil {
@@ -220,6 +224,8 @@ func monitorConfigListen(
 	}()
 
 	logMissingIntervalParams := true
+	shardByHash := cfg.DistributedPolling && cfg.DistributedPollingMode == config.DistributedPollingConsistentHash
+	lastShardMonitors := ""
 
 	for pollerMonitorCfg := range monitorConfigPollChan {
 		monitorConfig := pollerMonitorCfg.Cfg
@@ -228,6 +234,9 @@ func monitorConfigListen(
 		if err := toData.Update(toSession, cdn, monitorConfig); err != nil {
 			log.Errorln("Updating Traffic Ops Data: " + err.Error())
 		}
+		if recorder != nil {
+			recordConfig(recorder, toSession, cdn, monitorConfig)
+		}
 
 		healthURLs := map[string]poller.PollConfig{}
 		statURLs := map[string]poller.PollConfig{}
@@ -241,7 +250,7 @@ func monitorConfigListen(
 		}
 
 		thisTMGroup, thisTMStatus, cacheGroupsToPoll, err := getCacheGroupsToPoll(
-			cfg.DistributedPolling,
+			cfg.DistributedPolling && !shardByHash,
 			staticAppData.Hostname,
 			monitorConfig.TrafficMonitor,
 			monitorConfig.TrafficServer,
@@ -252,6 +261,16 @@ func monitorConfigListen(
 			continue
 		}
 		log.Debugf("this TM's cachegroup: %s, cachegroups to poll: %v", thisTMGroup, cacheGroupsToPoll)
+
+		var shards shardRing
+		if shardByHash {
+			shardMonitors := getShardMonitors(staticAppData.Hostname, thisTMStatus, monitorConfig.TrafficMonitor, distributedPeerStates)
+			if joined := strings.Join(shardMonitors, ","); joined != lastShardMonitors {
+				log.Infof("rebalancing polled caches across %d live Traffic Monitors: %s", len(shardMonitors), joined)
+				lastShardMonitors = joined
+			}
+			shards = newShardRing(shardMonitors)
+		}
 		for _, srv := range monitorConfig.TrafficServer {
 			cacheName := tc.CacheName(srv.HostName)
 
@@ -264,9 +283,16 @@ func monitorConfigListen(
 				continue
 			}
 			_, isDirectlyPolled := cacheGroupsToPoll[srv.CacheGroup]
+			if shardByHash {
+				isDirectlyPolled = shards.owner(srv.HostName) == staticAppData.Hostname
+			}
 			// seed states with available = false until our polling cycle picks up a result
-			if _, exists := localStates.GetCache(cacheName); !exists {
+			if state, exists := localStates.GetCache(cacheName); !exists {
 				localStates.AddCache(cacheName, tc.IsAvailable{IsAvailable: false, DirectlyPolled: isDirectlyPolled})
+			} else if shardByHash && state.DirectlyPolled != isDirectlyPolled {
+				// the cache's shard moved to or from this TM, so stop (or start) serving it as locally polled
+				state.DirectlyPolled = isDirectlyPolled
+				localStates.SetCache(cacheName, state)
 			}
 
 			if !isDirectlyPolled {
@@ -316,7 +342,7 @@ func monitorConfigListen(
 		}
 
 		for _, srv := range monitorConfig.TrafficMonitor {
-			if srv.HostName == staticAppData.Hostname || (cfg.DistributedPolling && srv.Location != thisTMGroup) {
+			if srv.HostName == staticAppData.Hostname || (cfg.DistributedPolling && srv.Location != thisTMGroup) || shardByHash {
 				continue
 			}
 			if srv.ServerStatus != thisTMStatus {
@@ -329,12 +355,23 @@ func monitorConfigListen(
 		}
 		distributedPeerURLs := make(map[string]poller.PeerPollConfig)
 		distributedPeerSet := make(map[tc.TrafficMonitorName]struct{}, len(tmsByGroup)-1)
-		for tmGroup, tms := range tmsByGroup {
-			if tmGroup == thisTMGroup {
-				continue
+		if shardByHash {
+			// every other TM is its own shard, so each is polled individually for the caches it owns
+			for _, srv := range monitorConfig.TrafficMonitor {
+				if srv.HostName == staticAppData.Hostname || srv.ServerStatus != thisTMStatus {
+					continue
+				}
+				distributedPeerURLs[srv.HostName] = poller.PeerPollConfig{URLs: getDistributedPeerURLs([]tc.TrafficMonitor{srv})}
+				distributedPeerSet[tc.TrafficMonitorName(srv.HostName)] = struct{}{}
+			}
+		} else {
+			for tmGroup, tms := range tmsByGroup {
+				if tmGroup == thisTMGroup {
+					continue
+				}
+				distributedPeerURLs[tmGroup] = poller.PeerPollConfig{URLs: getDistributedPeerURLs(tms)}
+				distributedPeerSet[tc.TrafficMonitorName(tmGroup)] = struct{}{}
 			}
-			distributedPeerURLs[tmGroup] = poller.PeerPollConfig{URLs: getDistributedPeerURLs(tms)}
-			distributedPeerSet[tc.TrafficMonitorName(tmGroup)] = struct{}{}
 		}
 		distributedPeerStates.SetTimeout((intervals.Peer + cfg.HTTPTimeout) * 2)
 		distributedPeerStates.SetPeers(distributedPeerSet)
@@ -365,20 +402,37 @@ func monitorConfigListen(
 		cachesChangeSubscriber <- struct{}{}
 
 		// TODO because there are multiple writers to localStates.DeliveryService, there is a race condition, where MonitorConfig (this func) and HealthResultManager could write at the same time, and the HealthResultManager could overwrite a delivery service addition or deletion here. Probably the simplest and most performant fix would be a lock-free algorithm using atomic compare-and-swaps.
-		for _, ds := range monitorConfig.DeliveryService {
-			// since caches default to unavailable, also default DS false
-			if _, exists := localStates.GetDeliveryService(tc.DeliveryServiceName(ds.XMLID)); !exists {
-				localStates.SetDeliveryService(tc.DeliveryServiceName(ds.XMLID), tc.CRStatesDeliveryService{IsAvailable: false, DisabledLocations: []tc.CacheGroupName{}}) // important to initialize DisabledLocations, so JSON is `[]` not `null`
-			}
+		updateDeliveryServiceStates(localStates, monitorConfig)
+	}
+}
+
+// updateDeliveryServiceStates adds the delivery services in the given monitoring config which aren't in the local states, and removes those which no longer exist.
+func updateDeliveryServiceStates(localStates peer.CRStatesThreadsafe, monitorConfig tc.TrafficMonitorConfigMap) {
+	for _, ds := range monitorConfig.DeliveryService {
+		// since caches default to unavailable, also default DS false
+		if _, exists := localStates.GetDeliveryService(tc.DeliveryServiceName(ds.XMLID)); !exists {
+			localStates.SetDeliveryService(tc.DeliveryServiceName(ds.XMLID), tc.CRStatesDeliveryService{IsAvailable: false, DisabledLocations: []tc.CacheGroupName{}}) // important to initialize DisabledLocations, so JSON is `[]` not `null`
 		}
-		for ds := range localStates.GetDeliveryServices() {
-			if _, exists := monitorConfig.DeliveryService[string(ds)]; !exists {
-				localStates.DeleteDeliveryService(ds)
-			}
+	}
+	for ds := range localStates.GetDeliveryServices() {
+		if _, exists := monitorConfig.DeliveryService[string(ds)]; !exists {
+			localStates.DeleteDeliveryService(ds)
 		}
 	}
 }
 
+// recordConfig records the given monitoring config and the last CRConfig fetched for the given CDN.
+func recordConfig(recorder *recording.Recorder, toSession towrap.TrafficOpsSessionThreadsafe, cdn string, monitorConfig tc.TrafficMonitorConfigMap) {
+	crConfig, _, err := toSession.LastCRConfig(cdn)
+	if err != nil {
+		log.Errorf("recording config: getting last CRConfig: %v", err)
+		return
+	}
+	if err := recorder.RecordConfig(monitorConfig, crConfig); err != nil {
+		log.Errorf("recording config: %v", err)
+	}
+}
+
 // getCacheGroupsToPoll returns the name of this Traffic Monitor's cache group,
 // the status of this Traffic Monitor, and the set of cache groups it needs to poll.
 func getCacheGroupsToPoll(distributedPolling bool, hostname string, monitors map[string]tc.TrafficMonitor,
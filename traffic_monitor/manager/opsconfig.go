@@ -54,6 +54,7 @@ func StartOpsConfigManager(
 	peerStates peer.CRStatesPeersThreadsafe,
 	distributedPeerStates peer.CRStatesPeersThreadsafe,
 	combinedStates peer.CRStatesThreadsafe,
+	combinationVotes peer.CombinationVotesThreadsafe,
 	statInfoHistory threadsafe.ResultInfoHistory,
 	statResultHistory threadsafe.ResultStatHistory,
 	statMaxKbpses threadsafe.CacheKbpses,
@@ -112,6 +113,7 @@ func StartOpsConfigManager(
 			peerStates,
 			distributedPeerStates,
 			combinedStates,
+			combinationVotes,
 			statInfoHistory,
 			statResultHistory,
 			statMaxKbpses,
@@ -0,0 +1,280 @@
+package manager
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/cache"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/config"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/health"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/peer"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/poller"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/recording"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/threadsafe"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/todata"
+)
+
+// ReplayResult is the outcome of replaying a recording.
+type ReplayResult struct {
+	// CRStates are the combined states after the last recorded result.
+	CRStates tc.CRStates `json:"crStates"`
+	// Events are all the events raised during the replay, oldest first.
+	Events []health.Event `json:"events"`
+}
+
+// replayer holds the state of Traffic Monitor built up while replaying a recording. It is the same state the health and stat managers build from live polls, but is only ever used by a single goroutine.
+type replayer struct {
+	cfg            config.Config
+	hostname       string
+	toData         todata.TODataThreadsafe
+	monitorConfig  threadsafe.TrafficMonitorConfigMap
+	localStates    peer.CRStatesThreadsafe
+	combinedStates peer.CRStatesThreadsafe
+	peerStates     peer.CRStatesPeersThreadsafe
+	overrideMap    map[tc.CacheName]bool
+	events         health.ThreadsafeEvents
+	dsProbeResults threadsafe.DSProbeResults
+	crConfig       []byte
+
+	healthHandler cache.Handler
+	statHandler   cache.Handler
+
+	localCacheStatus     threadsafe.CacheAvailableStatus
+	fetchCount           threadsafe.Uint
+	lastHealthDurations  threadsafe.DurationMap
+	healthUnpolledCaches threadsafe.UnpolledCaches
+	healthHistory        threadsafe.ResultHistory
+	lastHealthEndTimes   map[tc.CacheName]time.Time
+
+	statInfoHistory    threadsafe.ResultInfoHistory
+	statResultHistory  threadsafe.ResultStatHistory
+	statMaxKbpses      threadsafe.CacheKbpses
+	lastStatDurations  threadsafe.DurationMap
+	lastStatEndTimes   map[tc.CacheName]time.Time
+	lastStats          threadsafe.LastStats
+	dsStats            threadsafe.DSStats
+	statUnpolledCaches threadsafe.UnpolledCaches
+	precomputedData    map[tc.CacheName]cache.PrecomputedData
+	lastResults        map[tc.CacheName]cache.Result
+
+	pollID       uint64
+	eventIndex   uint64
+	replayEvents []health.Event
+}
+
+func newReplayer(cfg config.Config, hostname string) *replayer {
+	toData := todata.NewThreadsafe()
+	return &replayer{
+		cfg:                  cfg,
+		hostname:             hostname,
+		toData:               toData,
+		monitorConfig:        threadsafe.NewTrafficMonitorConfigMap(),
+		localStates:          peer.NewCRStatesThreadsafe(),
+		combinedStates:       peer.NewCRStatesThreadsafe(),
+		peerStates:           peer.NewCRStatesPeersThreadsafe(0),
+		overrideMap:          map[tc.CacheName]bool{},
+		events:               health.NewThreadsafeEvents(cfg.MaxEvents),
+		dsProbeResults:       threadsafe.NewDSProbeResults(),
+		healthHandler:        cache.NewHandler(),
+		statHandler:          cache.NewPrecomputeHandler(toData),
+		localCacheStatus:     threadsafe.NewCacheAvailableStatus(),
+		fetchCount:           threadsafe.NewUint(),
+		lastHealthDurations:  threadsafe.NewDurationMap(),
+		healthUnpolledCaches: threadsafe.NewUnpolledCaches(),
+		healthHistory:        threadsafe.NewResultHistory(),
+		lastHealthEndTimes:   map[tc.CacheName]time.Time{},
+		statInfoHistory:      threadsafe.NewResultInfoHistory(),
+		statResultHistory:    threadsafe.NewResultStatHistory(),
+		statMaxKbpses:        threadsafe.NewCacheKbpses(),
+		lastStatDurations:    threadsafe.NewDurationMap(),
+		lastStatEndTimes:     map[tc.CacheName]time.Time{},
+		lastStats:            threadsafe.NewLastStats(),
+		dsStats:              threadsafe.NewDSStats(),
+		statUnpolledCaches:   threadsafe.NewUnpolledCaches(),
+		precomputedData:      map[tc.CacheName]cache.PrecomputedData{},
+		lastResults:          map[tc.CacheName]cache.Result{},
+		replayEvents:         []health.Event{},
+	}
+}
+
+// Replay feeds the poll results of the recording at the given path through the cache decoders and the health and stat logic, in the order they were recorded, and returns the resulting combined CRStates and events.
+//
+// Replay is deterministic: the same recording and config always produce the same result. Peers are not replayed, so the combined states are those of the recording Traffic Monitor alone. Events are given the recorded time of the result which caused them, and events caused by the same result are sorted.
+//
+// If speed is zero, results are replayed as fast as they can be processed; otherwise, the recorded time between results is divided by speed.
+func Replay(path string, cfg config.Config, hostname string, speed float64) (ReplayResult, error) {
+	reader, err := recording.Open(path)
+	if err != nil {
+		return ReplayResult{}, err
+	}
+	defer reader.Close()
+
+	r := newReplayer(cfg, hostname)
+	lastTime := time.Time{}
+	for {
+		rec, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if err == io.ErrUnexpectedEOF {
+			log.Warnf("replay: recording '%s' was not closed cleanly, replaying the records before it was cut off", path)
+			break
+		} else if err != nil {
+			return ReplayResult{}, fmt.Errorf("replaying '%s': %v", path, err)
+		}
+
+		if speed > 0 && !lastTime.IsZero() && rec.Time.After(lastTime) {
+			time.Sleep(time.Duration(float64(rec.Time.Sub(lastTime)) / speed))
+		}
+		lastTime = rec.Time
+
+		if err := r.replay(rec); err != nil {
+			return ReplayResult{}, fmt.Errorf("replaying '%s': %v", path, err)
+		}
+		r.collectEvents(rec.Time)
+	}
+	return ReplayResult{CRStates: r.combinedStates.Get(), Events: r.replayEvents}, nil
+}
+
+func (r *replayer) replay(rec recording.Record) error {
+	switch rec.Kind {
+	case recording.KindConfig:
+		return r.replayConfig(rec)
+	case recording.KindHealth:
+		if r.toData.Get().ServerTypes == nil {
+			return errors.New("health result recorded before any config")
+		}
+		result := r.decode(r.healthHandler, rec)
+		processHealthResult(r.toData, r.localStates, r.lastHealthDurations, r.healthUnpolledCaches, r.monitorConfig, r.fetchCount, r.events, r.dsProbeResults, r.localCacheStatus, r.lastHealthEndTimes, r.healthHistory, []cache.Result{result}, r.cfg, r.combineStates)
+	case recording.KindStat:
+		if r.toData.Get().ServerTypes == nil {
+			return errors.New("stat result recorded before any config")
+		}
+		result := r.decode(r.statHandler, rec)
+		processStatResults([]cache.Result{result}, r.statInfoHistory, r.statResultHistory, r.statMaxKbpses, r.combinedStates, r.lastStats, r.toData.Get(), r.dsStats, r.lastStatEndTimes, r.lastStatDurations, r.statUnpolledCaches, r.monitorConfig.Get(), r.precomputedData, r.lastResults, r.localStates, r.dsProbeResults, r.events, r.localCacheStatus, r.combineStates, r.cfg.CachePollingProtocol)
+	default:
+		log.Warnf("replay: skipping record of unknown kind '%s'", rec.Kind)
+	}
+	return nil
+}
+
+// replayConfig applies a recorded monitoring config and CRConfig, seeding the local states as the monitor config manager does. Every cache is treated as directly polled, because only the caches this Traffic Monitor polled were recorded.
+func (r *replayer) replayConfig(rec recording.Record) error {
+	if len(rec.CRConfig) > 0 {
+		r.crConfig = rec.CRConfig
+	}
+	mc := r.monitorConfig.Get()
+	if rec.MonitorConfig != nil {
+		mc = *rec.MonitorConfig
+		r.monitorConfig.Set(mc)
+	}
+	if r.crConfig == nil {
+		return errors.New("config recorded without a CRConfig")
+	}
+	if err := r.toData.UpdateFromCRConfig(r.crConfig, mc); err != nil {
+		return fmt.Errorf("updating Traffic Ops data: %v", err)
+	}
+
+	for _, srv := range mc.TrafficServer {
+		cacheName := tc.CacheName(srv.HostName)
+		switch tc.CacheStatusFromString(srv.ServerStatus) {
+		case tc.CacheStatusOnline:
+			r.localStates.AddCache(cacheName, tc.IsAvailable{IsAvailable: true, Ipv6Available: srv.IPv6() != "", Ipv4Available: srv.IPv4() != ""})
+		case tc.CacheStatusReported, tc.CacheStatusAdminDown:
+			if _, exists := r.localStates.GetCache(cacheName); !exists {
+				r.localStates.AddCache(cacheName, tc.IsAvailable{IsAvailable: false, DirectlyPolled: true})
+			}
+		}
+	}
+	for cacheName := range r.localStates.GetCaches() {
+		if _, exists := mc.TrafficServer[string(cacheName)]; !exists {
+			r.localStates.DeleteCache(cacheName)
+		}
+	}
+	updateDeliveryServiceStates(r.localStates, mc)
+	r.healthUnpolledCaches.SetNewCaches(getNewCaches(r.localStates, r.monitorConfig))
+	r.statUnpolledCaches.SetNewCaches(getNewCaches(r.localStates, r.monitorConfig))
+	r.combineStates()
+	return nil
+}
+
+// decode passes the recorded poll through the given cache handler, exactly as the poller does for a live poll, and returns the decoded result.
+func (r *replayer) decode(handler cache.Handler, rec recording.Record) cache.Result {
+	r.pollID++
+	var reqErr error
+	if rec.Error != "" {
+		reqErr = errors.New(rec.Error)
+	}
+	rdr := io.Reader(nil)
+	if rec.Body != nil {
+		rdr = bytes.NewReader(rec.Body)
+	}
+	pollCtx := &poller.HTTPPollCtx{HTTPHeader: http.Header{}}
+	if rec.ContentType != "" {
+		pollCtx.HTTPHeader.Set("Content-Type", rec.ContentType)
+	}
+	// processing sends on the poll finished chan, so it must be buffered for a single result
+	pollFinished := make(chan uint64, 1)
+	go handler.Handle(rec.ID, rdr, rec.Format, rec.Duration, rec.Time, reqErr, r.pollID, rec.UsingIPv4, pollCtx, pollFinished)
+	return <-handler.ResultChan()
+}
+
+// combineStates combines the local states without peers, synchronously so the replay is deterministic.
+func (r *replayer) combineStates() {
+	policy := newCombinationPolicy(r.cfg, r.monitorConfig.Get().TrafficMonitor, r.hostname)
+	combineCrStates(r.events, r.peerStates.GetCRStatesPeersInfo(), r.localStates.Get(), r.combinedStates, r.overrideMap, r.toData.Get(), policy)
+}
+
+// collectEvents appends the events raised since the last call to the replay events, with the given time, in a deterministic order.
+func (r *replayer) collectEvents(t time.Time) {
+	newEvents := []health.Event{}
+	for _, e := range r.events.Get() {
+		if e.Index >= r.eventIndex {
+			newEvents = append(newEvents, e)
+		}
+	}
+	if len(newEvents) == 0 {
+		return
+	}
+	r.eventIndex = newEvents[0].Index + 1 // events are newest first
+	sort.Slice(newEvents, func(i, j int) bool {
+		a, b := newEvents[i], newEvents[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Description < b.Description
+	})
+	for _, e := range newEvents {
+		e.Time = health.Time(t)
+		e.Index = uint64(len(r.replayEvents))
+		r.replayEvents = append(r.replayEvents, e)
+	}
+}
@@ -0,0 +1,139 @@
+package manager
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/config"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/recording"
+)
+
+const replayTestAstats = `{"ats": {}, "system": {"inf.name": "eth0", "inf.speed": 70000, "proc.net.dev": "eth0:47907832129 14601260    0    0    0     0          0   790726 728207677726 10210700052    0    0    0     0       0          0", "proc.loadavg": "0.30 0.12 0.21 803/863 1421", "notAvailable": false}}`
+
+func writeReplayTestRecording(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "recording.gz")
+	recorder, err := recording.Create(path)
+	if err != nil {
+		t.Fatalf("creating recording: %v", err)
+	}
+
+	mc := tc.TrafficMonitorConfigMap{
+		TrafficServer: map[string]tc.TrafficServer{
+			"edge0": {
+				HostName:     "edge0",
+				CacheGroup:   "cg0",
+				Profile:      "EDGE",
+				ServerStatus: string(tc.CacheStatusReported),
+				Type:         string(tc.CacheTypeEdge),
+				Interfaces: []tc.ServerInterfaceInfo{
+					{
+						Name:        "eth0",
+						Monitor:     true,
+						IPAddresses: []tc.ServerIPAddress{{Address: "192.0.2.1", ServiceAddress: true}},
+					},
+				},
+			},
+		},
+		CacheGroup:      map[string]tc.TMCacheGroup{"cg0": {Name: "cg0"}},
+		TrafficMonitor:  map[string]tc.TrafficMonitor{},
+		DeliveryService: map[string]tc.TMDeliveryService{},
+		Profile:         map[string]tc.TMProfile{"EDGE": {Name: "EDGE", Parameters: tc.TMParameters{HistoryCount: 5}}},
+	}
+	if err := recorder.RecordConfig(mc, []byte(`{}`)); err != nil {
+		t.Fatalf("recording config: %v", err)
+	}
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	polls := []struct {
+		body string
+		err  error
+	}{
+		{body: replayTestAstats}, // the first result only establishes the cache's status
+		{body: replayTestAstats},
+		{err: errors.New("connection refused")},
+		{body: replayTestAstats},
+	}
+	for i, poll := range polls {
+		rec := recording.Record{
+			Kind:        recording.KindHealth,
+			Time:        start.Add(time.Duration(i) * time.Second),
+			ID:          "edge0",
+			Format:      "astats",
+			ContentType: "text/json",
+			UsingIPv4:   true,
+			Duration:    10 * time.Millisecond,
+		}
+		if poll.err != nil {
+			rec.Error = poll.err.Error()
+		} else {
+			rec.Body = []byte(poll.body)
+		}
+		if err := recorder.Record(rec); err != nil {
+			t.Fatalf("recording poll %d: %v", i, err)
+		}
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("closing recording: %v", err)
+	}
+	return path
+}
+
+func TestReplay(t *testing.T) {
+	path := writeReplayTestRecording(t)
+	cfg := config.DefaultConfig
+	cfg.CachePollingProtocol = config.IPv4Only
+
+	result, err := Replay(path, cfg, "tm0", 0)
+	if err != nil {
+		t.Fatalf("replaying: %v", err)
+	}
+	if state, ok := result.CRStates.Caches["edge0"]; !ok || !state.IsAvailable {
+		t.Errorf("expected edge0 available after the last poll succeeded, actual: %+v", result.CRStates.Caches)
+	}
+
+	available := []bool{}
+	for _, e := range result.Events {
+		if e.Hostname == "edge0" && e.Type == string(tc.CacheTypeEdge) {
+			available = append(available, e.Available)
+		}
+	}
+	if !reflect.DeepEqual(available, []bool{true, false, true}) {
+		t.Errorf("expected edge0 events available, unavailable, available; actual: %+v", result.Events)
+	}
+	for i, e := range result.Events {
+		if e.Index != uint64(i) {
+			t.Errorf("expected event %d to have index %d, actual: %d", i, i, e.Index)
+		}
+	}
+
+	again, err := Replay(path, cfg, "tm0", 0)
+	if err != nil {
+		t.Fatalf("replaying again: %v", err)
+	}
+	if !reflect.DeepEqual(result, again) {
+		t.Errorf("expected replays of the same recording to be identical, actual: %+v and %+v", result, again)
+	}
+}
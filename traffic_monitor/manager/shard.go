@@ -0,0 +1,103 @@
+package manager
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/peer"
+)
+
+// shardVirtualNodes is the number of points each Traffic Monitor is given on the shard ring. More points spread caches more evenly, at the cost of a larger ring.
+const shardVirtualNodes = 128
+
+// shardRing is a consistent hash ring of Traffic Monitors, used to assign each cache to exactly one Traffic Monitor for polling. When a Traffic Monitor joins or leaves the ring, only the caches it owns (or will own) move.
+type shardRing struct {
+	points []uint64
+	owners map[uint64]string
+}
+
+// newShardRing creates a shardRing from the given Traffic Monitor host names. The ring is deterministic, so every Traffic Monitor with the same list of live monitors computes the same assignments.
+func newShardRing(monitors []string) shardRing {
+	ring := shardRing{
+		points: make([]uint64, 0, len(monitors)*shardVirtualNodes),
+		owners: make(map[uint64]string, len(monitors)*shardVirtualNodes),
+	}
+	for _, monitor := range monitors {
+		for i := 0; i < shardVirtualNodes; i++ {
+			point := shardHash(monitor + "-" + strconv.Itoa(i))
+			if existing, ok := ring.owners[point]; ok && existing < monitor {
+				continue // resolve (extremely unlikely) collisions deterministically
+			}
+			if _, ok := ring.owners[point]; !ok {
+				ring.points = append(ring.points, point)
+			}
+			ring.owners[point] = monitor
+		}
+	}
+	sort.Slice(ring.points, func(i, j int) bool { return ring.points[i] < ring.points[j] })
+	return ring
+}
+
+// owner returns the Traffic Monitor which owns the given key, or the empty string if the ring is empty.
+func (r shardRing) owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	hash := shardHash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= hash })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+// shardHash hashes the given string with FNV-1a, then mixes the result with the SplitMix64 finalizer, because FNV alone distributes similar short strings (like host names with numeric suffixes) poorly.
+func shardHash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// getShardMonitors returns the sorted host names of the Traffic Monitors among which caches are sharded: this Traffic Monitor, and every other Traffic Monitor with the same status which was available the last time it was polled as a distributed peer.
+// Traffic Monitors which have never been polled are excluded until they answer, so a joining Traffic Monitor is only given caches once it is serving, and a dead one is removed once its polls time out.
+func getShardMonitors(hostname string, status string, monitors map[string]tc.TrafficMonitor, distributedPeerStates peer.CRStatesPeersThreadsafe) []string {
+	live := []string{hostname}
+	for _, monitor := range monitors {
+		if monitor.HostName == hostname || monitor.ServerStatus != status {
+			continue
+		}
+		if !distributedPeerStates.GetPeerAvailability(tc.TrafficMonitorName(monitor.HostName)) {
+			continue
+		}
+		live = append(live, monitor.HostName)
+	}
+	sort.Strings(live)
+	return live
+}
@@ -0,0 +1,108 @@
+package manager
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/peer"
+)
+
+func TestShardRingBalanceAndRebalance(t *testing.T) {
+	monitors := []string{"tm0", "tm1", "tm2", "tm3"}
+	ring := newShardRing(monitors)
+
+	const numCaches = 4000
+	owners := map[string]string{}
+	counts := map[string]int{}
+	for i := 0; i < numCaches; i++ {
+		cache := fmt.Sprintf("edge-%d", i)
+		owner := ring.owner(cache)
+		owners[cache] = owner
+		counts[owner]++
+	}
+	for _, monitor := range monitors {
+		share := float64(counts[monitor]) / numCaches
+		if share < 0.15 || share > 0.35 {
+			t.Errorf("expected %s to own roughly a quarter of caches, actual: %.2f", monitor, share)
+		}
+	}
+
+	if again := newShardRing([]string{"tm3", "tm2", "tm1", "tm0"}); again.owner("edge-42") != owners["edge-42"] {
+		t.Errorf("expected ring to be independent of monitor order")
+	}
+
+	// removing a monitor must only move the caches it owned
+	shrunk := newShardRing([]string{"tm0", "tm1", "tm3"})
+	for cache, owner := range owners {
+		newOwner := shrunk.owner(cache)
+		if owner != "tm2" && newOwner != owner {
+			t.Fatalf("expected cache %s owned by live monitor %s to stay, actual: moved to %s", cache, owner, newOwner)
+		}
+		if newOwner == "tm2" {
+			t.Fatalf("expected cache %s to move off removed monitor tm2", cache)
+		}
+	}
+
+	// adding a monitor must only move caches to the new monitor
+	grown := newShardRing(append(monitors, "tm4"))
+	moved := 0
+	for cache, owner := range owners {
+		newOwner := grown.owner(cache)
+		if newOwner != owner {
+			if newOwner != "tm4" {
+				t.Fatalf("expected cache %s to stay on %s or move to tm4, actual: moved to %s", cache, owner, newOwner)
+			}
+			moved++
+		}
+	}
+	if moved == 0 || moved > numCaches/3 {
+		t.Errorf("expected roughly a fifth of caches to move to the new monitor, actual: %d of %d", moved, numCaches)
+	}
+
+	if owner := newShardRing(nil).owner("edge-0"); owner != "" {
+		t.Errorf("expected empty ring to have no owner, actual: %s", owner)
+	}
+}
+
+func TestGetShardMonitors(t *testing.T) {
+	monitors := map[string]tc.TrafficMonitor{
+		"tm0": {HostName: "tm0", ServerStatus: "ONLINE"},
+		"tm1": {HostName: "tm1", ServerStatus: "ONLINE"},
+		"tm2": {HostName: "tm2", ServerStatus: "ONLINE"},
+		"tm3": {HostName: "tm3", ServerStatus: "OFFLINE"},
+		"tm4": {HostName: "tm4", ServerStatus: "ONLINE"},
+	}
+
+	distributedPeerStates := peer.NewCRStatesPeersThreadsafe(0)
+	distributedPeerStates.Set(peer.Result{ID: "tm1", Available: true, Time: time.Now()})
+	distributedPeerStates.Set(peer.Result{ID: "tm2", Available: false, Time: time.Now()})
+	distributedPeerStates.Set(peer.Result{ID: "tm3", Available: true, Time: time.Now()})
+	distributedPeerStates.SetPeers(map[tc.TrafficMonitorName]struct{}{"tm1": {}, "tm2": {}, "tm3": {}, "tm4": {}})
+
+	actual := getShardMonitors("tm0", "ONLINE", monitors, distributedPeerStates)
+	expected := []string{"tm0", "tm1"}
+	if fmt.Sprint(actual) != fmt.Sprint(expected) {
+		t.Errorf("expected shard monitors %v (self, plus available peers with the same status), actual: %v", expected, actual)
+	}
+}
@@ -21,19 +21,31 @@ package manager
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/apache/trafficcontrol/v8/lib/go-log"
 	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/config"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/health"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/peer"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/threadsafe"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/todata"
 )
 
-// StartStateCombiner starts the State Combiner goroutine, and returns the threadsafe CombinedStates, and a func to signal to combine states.
-func StartStateCombiner(events health.ThreadsafeEvents, peerStates peer.CRStatesPeersThreadsafe, localStates peer.CRStatesThreadsafe, toData todata.TODataThreadsafe) (peer.CRStatesThreadsafe, func()) {
+// StartStateCombiner starts the State Combiner goroutine, and returns the threadsafe CombinedStates, the threadsafe breakdown of how each Traffic Monitor voted in the last combination, and a func to signal to combine states.
+func StartStateCombiner(
+	events health.ThreadsafeEvents,
+	peerStates peer.CRStatesPeersThreadsafe,
+	localStates peer.CRStatesThreadsafe,
+	toData todata.TODataThreadsafe,
+	monitorConfig threadsafe.TrafficMonitorConfigMap,
+	cfg config.Config,
+	hostname string,
+) (peer.CRStatesThreadsafe, peer.CombinationVotesThreadsafe, func()) {
 	combinedStates := peer.NewCRStatesThreadsafe()
+	combinationVotes := peer.NewCombinationVotesThreadsafe()
 
 	// the chan buffer just reduces the number of goroutines on our infinite buffer hack in combineState(), no real writer will block, since combineState() writes in a goroutine.
 	combineStateChan := make(chan struct{}, 1)
@@ -47,11 +59,174 @@ func StartStateCombiner(events health.ThreadsafeEvents, peerStates peer.CRStates
 	go func() {
 		overrideMap := map[tc.CacheName]bool{}
 		for range combineStateChan {
-			combineCrStates(events, peerStates.GetCRStatesPeersInfo(), localStates.Get(), combinedStates, overrideMap, toData.Get())
+			policy := newCombinationPolicy(cfg, monitorConfig.Get().TrafficMonitor, hostname)
+			votes := combineCrStates(events, peerStates.GetCRStatesPeersInfo(), localStates.Get(), combinedStates, overrideMap, toData.Get(), policy)
+			combinationVotes.Set(votes)
 		}
 	}()
 
-	return combinedStates, combineState
+	return combinedStates, combinationVotes, combineState
+}
+
+// combinationPolicy is the policy by which local and peer states are combined, along with the weight of each voting Traffic Monitor.
+type combinationPolicy struct {
+	policy      config.PeerCombinationPolicy
+	minPeers    int
+	localWeight float64
+	peerWeights map[tc.TrafficMonitorName]float64
+}
+
+// newCombinationPolicy creates the combinationPolicy from the given config. Weights are only looked up for the weighted policy; for all others, every Traffic Monitor has a weight of 1.
+func newCombinationPolicy(cfg config.Config, monitors map[string]tc.TrafficMonitor, hostname string) combinationPolicy {
+	policy := combinationPolicy{
+		policy:      cfg.PeerCombinationPolicy,
+		minPeers:    cfg.PeerCombinationMinPeers,
+		localWeight: 1,
+		peerWeights: map[tc.TrafficMonitorName]float64{},
+	}
+	if policy.policy == "" {
+		policy.policy = config.PeerCombinationOptimistic
+	}
+	if policy.policy != config.PeerCombinationWeighted {
+		return policy
+	}
+
+	locationWeight := func(location string) float64 {
+		if weight, ok := cfg.PeerLocationWeights[location]; ok {
+			return weight
+		}
+		return 1
+	}
+	for _, monitor := range monitors {
+		if monitor.HostName == hostname {
+			policy.localWeight = locationWeight(monitor.Location)
+			continue
+		}
+		policy.peerWeights[tc.TrafficMonitorName(monitor.HostName)] = locationWeight(monitor.Location)
+	}
+	return policy
+}
+
+// peerWeight returns the weight of the given peer's vote.
+func (p combinationPolicy) peerWeight(name tc.TrafficMonitorName) float64 {
+	if weight, ok := p.peerWeights[name]; ok {
+		return weight
+	}
+	return 1
+}
+
+// decide returns the combined availability, given the local availability and the availability reported by each voting peer.
+func (p combinationPolicy) decide(local bool, peers map[tc.TrafficMonitorName]bool) bool {
+	switch p.policy {
+	case config.PeerCombinationMajority, config.PeerCombinationWeighted:
+		availableWeight := 0.0
+		totalWeight := p.localWeight
+		if local {
+			availableWeight = p.localWeight
+		}
+		for name, available := range peers {
+			weight := p.peerWeight(name)
+			totalWeight += weight
+			if available {
+				availableWeight += weight
+			}
+		}
+		if availableWeight*2 == totalWeight {
+			return local // ties are broken by the local state
+		}
+		return availableWeight*2 > totalWeight
+	case config.PeerCombinationLocalPlusN:
+		if local || len(peers) < p.minPeers {
+			return local // not enough peers to confirm, so the local state stands
+		}
+		unavailableOn := 0
+		for _, available := range peers {
+			if !available {
+				unavailableOn++
+			}
+		}
+		return unavailableOn < p.minPeers
+	default:
+		if local {
+			return true
+		}
+		for _, available := range peers {
+			if available {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// newStateVotes returns the breakdown of the given local and peer votes, without the combined result.
+func (p combinationPolicy) newStateVotes(local bool, peers map[tc.TrafficMonitorName]bool) peer.StateVotes {
+	votes := peer.StateVotes{
+		Local: peer.Vote{Available: local, Weight: p.localWeight},
+		Peers: make(map[tc.TrafficMonitorName]peer.Vote, len(peers)),
+	}
+	for name, available := range peers {
+		votes.Peers[name] = peer.Vote{Available: available, Weight: p.peerWeight(name)}
+	}
+	return votes
+}
+
+// peerCacheVotes returns the availability of the given cache on each available peer. Peers which have no state for the cache abstain.
+func peerCacheVotes(cacheName tc.CacheName, peerCrStatesInfo peer.CRStatesPeersInfo) (map[tc.TrafficMonitorName]bool, map[tc.TrafficMonitorName]bool, map[tc.TrafficMonitorName]bool) {
+	available := map[tc.TrafficMonitorName]bool{}
+	ipv4Available := map[tc.TrafficMonitorName]bool{}
+	ipv6Available := map[tc.TrafficMonitorName]bool{}
+	for peerName, peerCrStates := range peerCrStatesInfo.GetCrStates() {
+		if !peerCrStatesInfo.GetPeerAvailability(peerName) {
+			continue
+		}
+		peerCacheState, ok := peerCrStates.Caches[cacheName]
+		if !ok {
+			continue
+		}
+		available[peerName] = peerCacheState.IsAvailable
+		ipv4Available[peerName] = peerCacheState.Ipv4Available
+		ipv6Available[peerName] = peerCacheState.Ipv6Available
+	}
+	return available, ipv4Available, ipv6Available
+}
+
+// peerDSVotes returns the availability of the given delivery service on each available peer. Peers which have no state for the delivery service abstain.
+func peerDSVotes(deliveryServiceName tc.DeliveryServiceName, peerCrStatesInfo peer.CRStatesPeersInfo) map[tc.TrafficMonitorName]bool {
+	available := map[tc.TrafficMonitorName]bool{}
+	for peerName, iPeerStates := range peerCrStatesInfo.GetCrStates() {
+		if !peerCrStatesInfo.GetPeerAvailability(peerName) {
+			continue
+		}
+		peerDeliveryService, ok := iPeerStates.DeliveryService[deliveryServiceName]
+		if !ok {
+			log.Infof("local delivery service %s not found in peer %s\n", deliveryServiceName, peerName)
+			continue
+		}
+		available[peerName] = peerDeliveryService.IsAvailable
+	}
+	return available
+}
+
+// votesSummary returns a human-readable summary of which Traffic Monitors voted which way.
+func votesSummary(votes peer.StateVotes) string {
+	availableOn := []string{}
+	unavailableOn := []string{}
+	if votes.Local.Available {
+		availableOn = append(availableOn, "local")
+	} else {
+		unavailableOn = append(unavailableOn, "local")
+	}
+	for name, vote := range votes.Peers {
+		if vote.Available {
+			availableOn = append(availableOn, name.String())
+		} else {
+			unavailableOn = append(unavailableOn, name.String())
+		}
+	}
+	sort.Strings(availableOn)
+	sort.Strings(unavailableOn)
+	return fmt.Sprintf("available on [%s], unavailable on [%s]", strings.Join(availableOn, ", "), strings.Join(unavailableOn, ", "))
 }
 
 func combineCacheState(
@@ -131,6 +306,72 @@ func combineCacheState(
 	combinedStates.AddCache(cacheName, tc.IsAvailable{IsAvailable: available, Ipv4Available: ipv4Available, Ipv6Available: ipv6Available, DirectlyPolled: localCacheState.DirectlyPolled, Status: localCacheState.Status, LastPoll: localCacheState.LastPoll})
 }
 
+// combineCacheStateVoting combines the local and peer states of the given cache according to the given (non-optimistic) policy, and returns the breakdown of votes.
+func combineCacheStateVoting(
+	cacheName tc.CacheName,
+	localCacheState tc.IsAvailable,
+	events health.ThreadsafeEvents,
+	peerCrStatesInfo peer.CRStatesPeersInfo,
+	combinedStates peer.CRStatesThreadsafe,
+	overrideMap map[tc.CacheName]bool,
+	toData todata.TOData,
+	policy combinationPolicy,
+) peer.StateVotes {
+	peerAvailable, peerIPv4Available, peerIPv6Available := peerCacheVotes(cacheName, peerCrStatesInfo)
+	if !peerCrStatesInfo.HasOptimisticQuorum() {
+		// too few peers are available to trust, so the local state stands, as it does for the optimistic policy
+		peerAvailable, peerIPv4Available, peerIPv6Available = map[tc.TrafficMonitorName]bool{}, map[tc.TrafficMonitorName]bool{}, map[tc.TrafficMonitorName]bool{}
+	}
+	available := policy.decide(localCacheState.IsAvailable, peerAvailable)
+	ipv4Available := policy.decide(localCacheState.Ipv4Available, peerIPv4Available)
+	ipv6Available := policy.decide(localCacheState.Ipv6Available, peerIPv6Available)
+
+	votes := policy.newStateVotes(localCacheState.IsAvailable, peerAvailable)
+	votes.Available = available
+
+	override := available != localCacheState.IsAvailable
+	if override != overrideMap[cacheName] {
+		overrideMap[cacheName] = override
+		overrideCondition := fmt.Sprintf("cleared; %s vote agrees with local state", policy.policy)
+		if override {
+			overrideCondition = fmt.Sprintf("detected; %s vote %s", policy.policy, votesSummary(votes))
+		}
+		events.Add(
+			health.Event{
+				Time:          health.Time(time.Now()),
+				Description:   fmt.Sprintf("Health protocol override condition %s", overrideCondition),
+				Name:          cacheName.String(),
+				Hostname:      cacheName.String(),
+				Type:          toData.ServerTypes[cacheName].String(),
+				Available:     available,
+				IPv4Available: ipv4Available,
+				IPv6Available: ipv6Available})
+	}
+
+	combinedStates.AddCache(cacheName, tc.IsAvailable{IsAvailable: available, Ipv4Available: ipv4Available, Ipv6Available: ipv6Available, DirectlyPolled: localCacheState.DirectlyPolled, Status: localCacheState.Status, LastPoll: localCacheState.LastPoll})
+	return votes
+}
+
+// combineDSStateVoting combines the local and peer states of the given delivery service according to the given (non-optimistic) policy, and returns the breakdown of votes.
+func combineDSStateVoting(
+	deliveryServiceName tc.DeliveryServiceName,
+	localDeliveryService tc.CRStatesDeliveryService,
+	peerCrStatesInfo peer.CRStatesPeersInfo,
+	combinedStates peer.CRStatesThreadsafe,
+	policy combinationPolicy,
+) peer.StateVotes {
+	peerAvailable := peerDSVotes(deliveryServiceName, peerCrStatesInfo)
+	if !peerCrStatesInfo.HasOptimisticQuorum() {
+		peerAvailable = map[tc.TrafficMonitorName]bool{} // too few peers are available to trust, so the local state stands
+	}
+
+	votes := policy.newStateVotes(localDeliveryService.IsAvailable, peerAvailable)
+	votes.Available = policy.decide(localDeliveryService.IsAvailable, peerAvailable)
+
+	combinedStates.SetDeliveryService(deliveryServiceName, tc.CRStatesDeliveryService{IsAvailable: votes.Available, DisabledLocations: []tc.CacheGroupName{}}) // important to initialize DisabledLocations, so JSON is `[]` not `null`
+	return votes
+}
+
 func combineDSState(
 	deliveryServiceName tc.DeliveryServiceName,
 	localDeliveryService tc.CRStatesDeliveryService,
@@ -190,17 +431,44 @@ func pruneCombinedCaches(combinedStates peer.CRStatesThreadsafe, localStates tc.
 	}
 }
 
-func combineCrStates(events health.ThreadsafeEvents, peerCrStatesInfo peer.CRStatesPeersInfo, localStates tc.CRStates, combinedStates peer.CRStatesThreadsafe, overrideMap map[tc.CacheName]bool, toData todata.TOData) {
+// combineCrStates combines the local and peer states into the combined states according to the given policy, and returns the breakdown of how each Traffic Monitor voted.
+func combineCrStates(events health.ThreadsafeEvents, peerCrStatesInfo peer.CRStatesPeersInfo, localStates tc.CRStates, combinedStates peer.CRStatesThreadsafe, overrideMap map[tc.CacheName]bool, toData todata.TOData, policy combinationPolicy) peer.CombinationVotes {
+	votes := peer.NewCombinationVotes(policy.policy.String())
+
 	for cacheName, localCacheState := range localStates.Caches { // localStates gets pruned when servers are disabled, it's the source of truth
+		if policy.policy != config.PeerCombinationOptimistic {
+			votes.Caches[cacheName] = combineCacheStateVoting(cacheName, localCacheState, events, peerCrStatesInfo, combinedStates, overrideMap, toData, policy)
+			continue
+		}
 		combineCacheState(cacheName, localCacheState, events, peerCrStatesInfo, combinedStates, overrideMap, toData)
+		peerAvailable, _, _ := peerCacheVotes(cacheName, peerCrStatesInfo)
+		cacheVotes := policy.newStateVotes(localCacheState.IsAvailable, peerAvailable)
+		combinedCacheState, _ := combinedStates.GetCache(cacheName)
+		cacheVotes.Available = combinedCacheState.IsAvailable
+		votes.Caches[cacheName] = cacheVotes
 	}
 
 	for deliveryServiceName, localDeliveryService := range localStates.DeliveryService {
+		if policy.policy != config.PeerCombinationOptimistic {
+			votes.DeliveryService[deliveryServiceName] = combineDSStateVoting(deliveryServiceName, localDeliveryService, peerCrStatesInfo, combinedStates, policy)
+			continue
+		}
 		combineDSState(deliveryServiceName, localDeliveryService, peerCrStatesInfo, combinedStates)
+		peerAvailable := map[tc.TrafficMonitorName]bool{}
+		for peerName, iPeerStates := range peerCrStatesInfo.GetCrStates() {
+			if peerDeliveryService, ok := iPeerStates.DeliveryService[deliveryServiceName]; ok {
+				peerAvailable[peerName] = peerDeliveryService.IsAvailable
+			}
+		}
+		dsVotes := policy.newStateVotes(localDeliveryService.IsAvailable, peerAvailable)
+		combinedDeliveryService, _ := combinedStates.GetDeliveryService(deliveryServiceName)
+		dsVotes.Available = combinedDeliveryService.IsAvailable
+		votes.DeliveryService[deliveryServiceName] = dsVotes
 	}
 
 	pruneCombinedDSState(combinedStates, localStates, peerCrStatesInfo)
 	pruneCombinedCaches(combinedStates, localStates)
+	return votes
 }
 
 // CacheGroupNameSlice is a slice of cache names, which fulfills the `sort.Interface` interface.
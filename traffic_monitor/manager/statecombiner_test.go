@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/config"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/health"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/peer"
 	"github.com/apache/trafficcontrol/v8/traffic_monitor/todata"
@@ -153,3 +154,212 @@ func TestCombineCacheStateCacheDown(t *testing.T) {
 		t.Fatalf("cache IPv6 is unavailable and should be available")
 	}
 }
+
+func TestCombinationPolicyDecide(t *testing.T) {
+	peers := map[tc.TrafficMonitorName]bool{"tm-east": false, "tm-west": true, "tm-north": false}
+
+	tests := []struct {
+		name     string
+		policy   combinationPolicy
+		local    bool
+		peers    map[tc.TrafficMonitorName]bool
+		expected bool
+	}{
+		{"optimistic any peer available", combinationPolicy{policy: config.PeerCombinationOptimistic, localWeight: 1}, false, peers, true},
+		{"majority unavailable", combinationPolicy{policy: config.PeerCombinationMajority, localWeight: 1}, false, peers, false},
+		{"majority tie of four uses local", combinationPolicy{policy: config.PeerCombinationMajority, localWeight: 1}, true, peers, true},
+		{"majority tie uses local", combinationPolicy{policy: config.PeerCombinationMajority, localWeight: 1}, true, map[tc.TrafficMonitorName]bool{"tm-east": false}, true},
+		{"majority no peers uses local", combinationPolicy{policy: config.PeerCombinationMajority, localWeight: 1}, false, map[tc.TrafficMonitorName]bool{}, false},
+		{"weighted heavy peer wins", combinationPolicy{policy: config.PeerCombinationWeighted, localWeight: 1, peerWeights: map[tc.TrafficMonitorName]float64{"tm-west": 5}}, false, peers, true},
+		{"weighted light peer loses", combinationPolicy{policy: config.PeerCombinationWeighted, localWeight: 1, peerWeights: map[tc.TrafficMonitorName]float64{"tm-west": 1.5}}, false, peers, false},
+		{"local plus n locally available", combinationPolicy{policy: config.PeerCombinationLocalPlusN, minPeers: 2, localWeight: 1}, true, peers, true},
+		{"local plus n confirmed", combinationPolicy{policy: config.PeerCombinationLocalPlusN, minPeers: 2, localWeight: 1}, false, peers, false},
+		{"local plus n unconfirmed", combinationPolicy{policy: config.PeerCombinationLocalPlusN, minPeers: 3, localWeight: 1}, false, peers, true},
+		{"local plus n too few peers", combinationPolicy{policy: config.PeerCombinationLocalPlusN, minPeers: 4, localWeight: 1}, false, peers, false},
+	}
+
+	for _, test := range tests {
+		if actual := test.policy.decide(test.local, test.peers); actual != test.expected {
+			t.Errorf("%s: expected: %t, actual: %t", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestNewCombinationPolicyWeights(t *testing.T) {
+	cfg := config.DefaultConfig
+	cfg.PeerCombinationPolicy = config.PeerCombinationWeighted
+	cfg.PeerLocationWeights = map[string]float64{"east": 3, "west": 0.5}
+	monitors := map[string]tc.TrafficMonitor{
+		"tm-local": {HostName: "tm-local", Location: "west"},
+		"tm-east":  {HostName: "tm-east", Location: "east"},
+		"tm-other": {HostName: "tm-other", Location: "other"},
+	}
+
+	policy := newCombinationPolicy(cfg, monitors, "tm-local")
+	if policy.localWeight != 0.5 {
+		t.Errorf("local weight expected: 0.5, actual: %v", policy.localWeight)
+	}
+	if weight := policy.peerWeight("tm-east"); weight != 3 {
+		t.Errorf("tm-east weight expected: 3, actual: %v", weight)
+	}
+	if weight := policy.peerWeight("tm-other"); weight != 1 {
+		t.Errorf("tm-other weight expected: 1, actual: %v", weight)
+	}
+}
+
+func TestCombineCrStatesVoting(t *testing.T) {
+	cacheName := tc.CacheName("testCache")
+	dsName := tc.DeliveryServiceName("testDS")
+
+	events := health.NewThreadsafeEvents(10)
+	peerStates := peer.NewCRStatesPeersThreadsafe(0)
+	peerSet := map[tc.TrafficMonitorName]struct{}{}
+	for _, name := range []tc.TrafficMonitorName{"TestTM-01", "TestTM-02"} {
+		peerStates.Set(peer.Result{
+			ID:        name,
+			Available: true,
+			PeerStates: tc.CRStates{
+				Caches:          map[tc.CacheName]tc.IsAvailable{cacheName: {IsAvailable: false}},
+				DeliveryService: map[tc.DeliveryServiceName]tc.CRStatesDeliveryService{dsName: {IsAvailable: false}},
+			},
+			Time: time.Now(),
+		})
+		peerSet[name] = struct{}{}
+	}
+	peerStates.SetPeers(peerSet)
+
+	localStates := tc.CRStates{
+		Caches:          map[tc.CacheName]tc.IsAvailable{cacheName: {IsAvailable: true, Ipv4Available: true, Ipv6Available: true}},
+		DeliveryService: map[tc.DeliveryServiceName]tc.CRStatesDeliveryService{dsName: {IsAvailable: true}},
+	}
+	combinedStates := peer.NewCRStatesThreadsafe()
+	overrideMap := map[tc.CacheName]bool{}
+	toData := todata.TOData{ServerTypes: map[tc.CacheName]tc.CacheType{cacheName: tc.CacheTypeEdge}}
+	policy := combinationPolicy{policy: config.PeerCombinationMajority, localWeight: 1}
+
+	votes := combineCrStates(events, peerStates.GetCRStatesPeersInfo(), localStates, combinedStates, overrideMap, toData, policy)
+
+	if combinedStates.Get().Caches[cacheName].IsAvailable {
+		t.Errorf("cache expected: unavailable by majority, actual: available")
+	}
+	if combinedStates.Get().DeliveryService[dsName].IsAvailable {
+		t.Errorf("delivery service expected: unavailable by majority, actual: available")
+	}
+	if !overrideMap[cacheName] {
+		t.Errorf("expected override to be recorded for cache")
+	}
+	if len(events.Get()) != 1 {
+		t.Errorf("expected 1 override event, actual: %d", len(events.Get()))
+	}
+
+	cacheVotes, ok := votes.Caches[cacheName]
+	if !ok {
+		t.Fatalf("expected votes for cache, actual: missing")
+	}
+	if votes.Policy != string(config.PeerCombinationMajority) {
+		t.Errorf("votes policy expected: %s, actual: %s", config.PeerCombinationMajority, votes.Policy)
+	}
+	if cacheVotes.Available || !cacheVotes.Local.Available || len(cacheVotes.Peers) != 2 {
+		t.Errorf("expected cache votes unavailable, local available, and 2 peer votes, actual: %+v", cacheVotes)
+	}
+	if dsVotes := votes.DeliveryService[dsName]; dsVotes.Available || len(dsVotes.Peers) != 2 {
+		t.Errorf("expected delivery service votes unavailable with 2 peer votes, actual: %+v", dsVotes)
+	}
+}
+
+// newTestPeerStates returns peer states where each given peer reports the cache and delivery service with the given availability. Peers not in availablePeers are unavailable.
+func newTestPeerStates(quorumMin int, cacheName tc.CacheName, dsName tc.DeliveryServiceName, peerAvailability map[tc.TrafficMonitorName]bool, availablePeers map[tc.TrafficMonitorName]bool) peer.CRStatesPeersThreadsafe {
+	peerStates := peer.NewCRStatesPeersThreadsafe(quorumMin)
+	peerSet := map[tc.TrafficMonitorName]struct{}{}
+	for name, available := range peerAvailability {
+		peerStates.Set(peer.Result{
+			ID:        name,
+			Available: availablePeers[name],
+			PeerStates: tc.CRStates{
+				Caches:          map[tc.CacheName]tc.IsAvailable{cacheName: {IsAvailable: available, Ipv4Available: available, Ipv6Available: available}},
+				DeliveryService: map[tc.DeliveryServiceName]tc.CRStatesDeliveryService{dsName: {IsAvailable: available}},
+			},
+			Time: time.Now(),
+		})
+		peerSet[name] = struct{}{}
+	}
+	peerStates.SetPeers(peerSet)
+	return peerStates
+}
+
+func TestCombineCrStatesUnavailablePeers(t *testing.T) {
+	cacheName := tc.CacheName("testCache")
+	dsName := tc.DeliveryServiceName("testDS")
+	peerStates := newTestPeerStates(0, cacheName, dsName,
+		map[tc.TrafficMonitorName]bool{"TestTM-01": true, "TestTM-02": false},
+		map[tc.TrafficMonitorName]bool{"TestTM-02": true}, // only TestTM-01, which reports available, is unavailable
+	)
+	localStates := tc.CRStates{
+		Caches:          map[tc.CacheName]tc.IsAvailable{cacheName: {}},
+		DeliveryService: map[tc.DeliveryServiceName]tc.CRStatesDeliveryService{dsName: {IsAvailable: false}},
+	}
+	toData := todata.TOData{ServerTypes: map[tc.CacheName]tc.CacheType{cacheName: tc.CacheTypeEdge}}
+
+	// the optimistic policy ignores unavailable peers for caches, but not delivery services
+	combinedStates := peer.NewCRStatesThreadsafe()
+	policy := combinationPolicy{policy: config.PeerCombinationOptimistic, localWeight: 1}
+	votes := combineCrStates(health.NewThreadsafeEvents(10), peerStates.GetCRStatesPeersInfo(), localStates, combinedStates, map[tc.CacheName]bool{}, toData, policy)
+	if combinedStates.Get().Caches[cacheName].IsAvailable {
+		t.Errorf("optimistic cache expected: unavailable, ignoring the unavailable peer, actual: available")
+	}
+	if !combinedStates.Get().DeliveryService[dsName].IsAvailable {
+		t.Errorf("optimistic delivery service expected: available on the unavailable peer, actual: unavailable")
+	}
+	if len(votes.DeliveryService[dsName].Peers) != 2 {
+		t.Errorf("expected optimistic delivery service votes from both peers, actual: %+v", votes.DeliveryService[dsName].Peers)
+	}
+
+	// voting policies ignore unavailable peers for both
+	combinedStates = peer.NewCRStatesThreadsafe()
+	policy = combinationPolicy{policy: config.PeerCombinationMajority, localWeight: 1}
+	localStates.Caches[cacheName] = tc.IsAvailable{IsAvailable: true, Ipv4Available: true, Ipv6Available: true}
+	localStates.DeliveryService[dsName] = tc.CRStatesDeliveryService{IsAvailable: true}
+	votes = combineCrStates(health.NewThreadsafeEvents(10), peerStates.GetCRStatesPeersInfo(), localStates, combinedStates, map[tc.CacheName]bool{}, toData, policy)
+	dsVotes := votes.DeliveryService[dsName]
+	if _, ok := dsVotes.Peers["TestTM-01"]; ok || len(dsVotes.Peers) != 1 {
+		t.Errorf("expected majority delivery service votes from only the available peer, actual: %+v", dsVotes.Peers)
+	}
+	if _, ok := votes.Caches[cacheName].Peers["TestTM-01"]; ok {
+		t.Errorf("expected majority cache votes from only the available peer, actual: %+v", votes.Caches[cacheName].Peers)
+	}
+}
+
+func TestCombineCrStatesVotingQuorum(t *testing.T) {
+	cacheName := tc.CacheName("testCache")
+	dsName := tc.DeliveryServiceName("testDS")
+	localStates := tc.CRStates{
+		Caches:          map[tc.CacheName]tc.IsAvailable{cacheName: {IsAvailable: true, Ipv4Available: true, Ipv6Available: true}},
+		DeliveryService: map[tc.DeliveryServiceName]tc.CRStatesDeliveryService{dsName: {IsAvailable: true}},
+	}
+	toData := todata.TOData{ServerTypes: map[tc.CacheName]tc.CacheType{cacheName: tc.CacheTypeEdge}}
+	policy := combinationPolicy{policy: config.PeerCombinationMajority, localWeight: 1}
+	peerAvailability := map[tc.TrafficMonitorName]bool{"TestTM-01": false, "TestTM-02": false, "TestTM-03": false}
+
+	for _, test := range []struct {
+		name           string
+		availablePeers map[tc.TrafficMonitorName]bool
+		expected       bool
+	}{
+		{"quorum met", map[tc.TrafficMonitorName]bool{"TestTM-01": true, "TestTM-02": true}, false},
+		{"quorum not met", map[tc.TrafficMonitorName]bool{"TestTM-01": true}, true},
+	} {
+		peerStates := newTestPeerStates(2, cacheName, dsName, peerAvailability, test.availablePeers)
+		combinedStates := peer.NewCRStatesThreadsafe()
+		votes := combineCrStates(health.NewThreadsafeEvents(10), peerStates.GetCRStatesPeersInfo(), localStates, combinedStates, map[tc.CacheName]bool{}, toData, policy)
+
+		if actual := combinedStates.Get().Caches[cacheName].IsAvailable; actual != test.expected {
+			t.Errorf("%s: cache availability expected: %v, actual: %v", test.name, test.expected, actual)
+		}
+		if actual := combinedStates.Get().DeliveryService[dsName].IsAvailable; actual != test.expected {
+			t.Errorf("%s: delivery service availability expected: %v, actual: %v", test.name, test.expected, actual)
+		}
+		if test.expected && (len(votes.Caches[cacheName].Peers) != 0 || len(votes.DeliveryService[dsName].Peers) != 0) {
+			t.Errorf("%s: expected no peer votes without quorum, actual: %+v", test.name, votes)
+		}
+	}
+}
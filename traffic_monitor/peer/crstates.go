@@ -210,6 +210,8 @@ type CRStatesPeersInfo struct {
 	peerTimes  map[tc.TrafficMonitorName]time.Time
 	crStates   map[tc.TrafficMonitorName]tc.CRStates
 	timeout    time.Duration
+	peerCount  int
+	quorumMin  int
 }
 
 func (i *CRStatesPeersInfo) GetCrStates() map[tc.TrafficMonitorName]tc.CRStates {
@@ -220,6 +222,20 @@ func (i *CRStatesPeersInfo) GetPeerAvailability(peer tc.TrafficMonitorName) bool
 	return i.peerStates[peer] && i.peerOnline[peer] && time.Since(i.peerTimes[peer]) < i.timeout
 }
 
+// HasOptimisticQuorum returns false if optimistic peer quorum is enabled, and fewer peers than the peer_optimistic_quorum_min setting are available, according to GetPeerAvailability. Otherwise, it returns true.
+func (i *CRStatesPeersInfo) HasOptimisticQuorum() bool {
+	if i.quorumMin <= 0 || i.peerCount <= 1 {
+		return true // quorum isn't enabled
+	}
+	available := 0
+	for peerName := range i.peerStates {
+		if i.GetPeerAvailability(peerName) {
+			available++
+		}
+	}
+	return available >= i.quorumMin
+}
+
 func (i *CRStatesPeersInfo) HasAvailablePeers() bool {
 	for _, available := range i.peerStates {
 		if available {
@@ -243,6 +259,8 @@ func (t *CRStatesPeersThreadsafe) GetCRStatesPeersInfo() CRStatesPeersInfo {
 		peerTimes:  copyPeerTimes(t.peerTimes),
 		crStates:   make(map[tc.TrafficMonitorName]tc.CRStates, len(t.crStates)),
 		timeout:    *t.timeout,
+		peerCount:  *t.peerCount,
+		quorumMin:  *t.quorumMin,
 	}
 	for k, v := range t.crStates {
 		info.crStates[k] = v.Copy()
@@ -0,0 +1,81 @@
+package peer
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"sync"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// Vote is a single Traffic Monitor's view of the availability of a cache or delivery service, as used when combining local and peer states.
+type Vote struct {
+	Available bool    `json:"available"`
+	Weight    float64 `json:"weight"`
+}
+
+// StateVotes is the breakdown of how the local Traffic Monitor and each of its available peers voted on the availability of a single cache or delivery service, and the combined result.
+type StateVotes struct {
+	Available bool                           `json:"available"`
+	Local     Vote                           `json:"local"`
+	Peers     map[tc.TrafficMonitorName]Vote `json:"peers"`
+}
+
+// CombinationVotes is the breakdown of votes for all caches and delivery services from the last time local and peer states were combined.
+type CombinationVotes struct {
+	Policy          string                                `json:"policy"`
+	Caches          map[tc.CacheName]StateVotes           `json:"caches"`
+	DeliveryService map[tc.DeliveryServiceName]StateVotes `json:"deliveryServices"`
+}
+
+// NewCombinationVotes creates a new, empty CombinationVotes for the given policy.
+func NewCombinationVotes(policy string) CombinationVotes {
+	return CombinationVotes{
+		Policy:          policy,
+		Caches:          map[tc.CacheName]StateVotes{},
+		DeliveryService: map[tc.DeliveryServiceName]StateVotes{},
+	}
+}
+
+// CombinationVotesThreadsafe provides safe access for multiple goroutines to read a single CombinationVotes object, with a single goroutine writer.
+type CombinationVotesThreadsafe struct {
+	votes *CombinationVotes
+	m     *sync.RWMutex
+}
+
+// NewCombinationVotesThreadsafe creates a new CombinationVotesThreadsafe object safe for multiple goroutine readers and a single writer.
+func NewCombinationVotesThreadsafe() CombinationVotesThreadsafe {
+	votes := NewCombinationVotes("")
+	return CombinationVotesThreadsafe{m: &sync.RWMutex{}, votes: &votes}
+}
+
+// Get returns the internal CombinationVotes object for reading. This MUST NOT be modified.
+func (t *CombinationVotesThreadsafe) Get() CombinationVotes {
+	t.m.RLock()
+	defer t.m.RUnlock()
+	return *t.votes
+}
+
+// Set sets the internal CombinationVotes. The given object MUST NOT be modified after calling Set.
+func (t *CombinationVotesThreadsafe) Set(votes CombinationVotes) {
+	t.m.Lock()
+	*t.votes = votes
+	t.m.Unlock()
+}
@@ -0,0 +1,73 @@
+package recording
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/handler"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/poller"
+)
+
+// Handler is a handler.Handler which records each poll result before passing it to the wrapped Handler.
+type Handler struct {
+	handler  handler.Handler
+	recorder *Recorder
+	kind     Kind
+}
+
+// NewHandler returns a Handler which records poll results as the given kind, and passes them to the given handler.
+func NewHandler(h handler.Handler, recorder *Recorder, kind Kind) Handler {
+	return Handler{handler: h, recorder: recorder, kind: kind}
+}
+
+// Handle records the given poll result, and passes it to the wrapped Handler. Recording errors are logged, and never prevent the result from being handled.
+func (h Handler) Handle(id string, rdr io.Reader, format string, reqTime time.Duration, reqEnd time.Time, reqErr error, pollID uint64, usingIPv4 bool, pollCtx interface{}, pollFinished chan<- uint64) {
+	rec := Record{
+		Kind:      h.kind,
+		Time:      reqEnd,
+		ID:        id,
+		Format:    format,
+		UsingIPv4: usingIPv4,
+		Duration:  reqTime,
+	}
+	if reqErr != nil {
+		rec.Error = reqErr.Error()
+	}
+	if ctx, ok := pollCtx.(*poller.HTTPPollCtx); ok && ctx.HTTPHeader != nil {
+		rec.ContentType = ctx.HTTPHeader.Get("Content-Type")
+	}
+	if rdr != nil {
+		bts, err := ioutil.ReadAll(rdr)
+		if err != nil {
+			log.Errorf("recording poll %v of '%s': reading body: %v", pollID, id, err)
+		}
+		rec.Body = bts
+		rdr = bytes.NewReader(bts)
+	}
+	if err := h.recorder.Record(rec); err != nil {
+		log.Errorf("recording poll %v of '%s': %v", pollID, id, err)
+	}
+	h.handler.Handle(id, rdr, format, reqTime, reqEnd, reqErr, pollID, usingIPv4, pollCtx, pollFinished)
+}
@@ -0,0 +1,204 @@
+// Package recording records the raw cache poll results, monitoring configs
+// and CRConfigs received by Traffic Monitor to a compact file, so they may
+// later be replayed through Traffic Monitor's health logic without live caches.
+package recording
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Kind is the kind of data in a Record.
+type Kind string
+
+const (
+	// KindConfig is a monitoring config and CRConfig.
+	KindConfig = Kind("config")
+	// KindHealth is the result of a cache health poll.
+	KindHealth = Kind("health")
+	// KindStat is the result of a cache stat poll.
+	KindStat = Kind("stat")
+)
+
+// Record is a single recorded event: either a poll result, or the monitoring config and CRConfig in use from that time on.
+type Record struct {
+	Kind Kind `json:"kind"`
+	// Time is the time the poll finished, or the time the config was received.
+	Time time.Time `json:"time"`
+
+	// ID is the name of the polled cache.
+	ID string `json:"id,omitempty"`
+	// Format is the stats format of the polled cache, used to select its decoder.
+	Format string `json:"format,omitempty"`
+	// ContentType is the Content-Type header of the poll response.
+	ContentType string `json:"contentType,omitempty"`
+	UsingIPv4   bool   `json:"usingIPv4,omitempty"`
+	// Duration is the time taken by the poll request.
+	Duration time.Duration `json:"duration,omitempty"`
+	// Body is the raw poll response body.
+	Body []byte `json:"body,omitempty"`
+	// Error is the poll error, if any.
+	Error string `json:"error,omitempty"`
+
+	MonitorConfig *tc.TrafficMonitorConfigMap `json:"monitorConfig,omitempty"`
+	CRConfig      jsoniter.RawMessage         `json:"crConfig,omitempty"`
+}
+
+// Recorder appends Records to a recording file, which is gzipped, with one JSON Record per line. It is safe for use by multiple goroutines.
+type Recorder struct {
+	m    *sync.Mutex
+	file *os.File
+	gz   *gzip.Writer
+	buf  *bufio.Writer
+
+	lastMonitorConfig *tc.TrafficMonitorConfigMap
+	lastCRConfig      []byte
+}
+
+// Create creates the recording file at the given path, truncating it if it exists.
+func Create(path string) (*Recorder, error) {
+	if path == "" {
+		return nil, errors.New("recording path must not be empty")
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating recording file '%s': %v", path, err)
+	}
+	gz := gzip.NewWriter(file)
+	return &Recorder{m: &sync.Mutex{}, file: file, gz: gz, buf: bufio.NewWriter(gz)}, nil
+}
+
+// Record appends the given Record.
+func (r *Recorder) Record(rec Record) error {
+	json := jsoniter.ConfigFastest
+	bts, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("marshalling record: %v", err)
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	if _, err := r.buf.Write(append(bts, '\n')); err != nil {
+		return fmt.Errorf("writing record: %v", err)
+	}
+	return nil
+}
+
+// RecordConfig records the given monitoring config and CRConfig. To keep recordings compact, each is only recorded if it changed since it was last recorded.
+func (r *Recorder) RecordConfig(mc tc.TrafficMonitorConfigMap, crConfig []byte) error {
+	rec := Record{Kind: KindConfig, Time: time.Now()}
+	r.m.Lock()
+	if r.lastMonitorConfig == nil || !reflect.DeepEqual(mc, *r.lastMonitorConfig) {
+		rec.MonitorConfig = &mc
+		r.lastMonitorConfig = &mc
+	}
+	if !bytes.Equal(crConfig, r.lastCRConfig) {
+		rec.CRConfig = crConfig
+		r.lastCRConfig = crConfig
+	}
+	r.m.Unlock()
+	if rec.MonitorConfig == nil && rec.CRConfig == nil {
+		return nil
+	}
+	return r.Record(rec)
+}
+
+// Flush writes all buffered Records to the file. Records are buffered and compressed, so the file isn't complete until it's flushed or closed.
+func (r *Recorder) Flush() error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if err := r.buf.Flush(); err != nil {
+		return err
+	}
+	return r.gz.Flush()
+}
+
+// Close flushes and closes the recording file. The Recorder must not be used after it is closed.
+func (r *Recorder) Close() error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if err := r.buf.Flush(); err != nil {
+		r.file.Close()
+		return err
+	}
+	if err := r.gz.Close(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+// Reader reads Records from a recording file, in the order they were recorded.
+type Reader struct {
+	file *os.File
+	gz   *gzip.Reader
+	buf  *bufio.Reader
+}
+
+// Open opens the recording file at the given path for reading.
+func Open(path string) (*Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening recording file '%s': %v", path, err)
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("reading recording file '%s': %v", path, err)
+	}
+	return &Reader{file: file, gz: gz, buf: bufio.NewReader(gz)}, nil
+}
+
+// Next returns the next Record. At the end of the recording, it returns io.EOF. A recording which was not cleanly closed, such as by a crash, returns io.ErrUnexpectedEOF at the point it was cut off.
+func (r *Reader) Next() (Record, error) {
+	rec := Record{}
+	line, err := r.buf.ReadBytes('\n')
+	if err == io.EOF && len(line) == 0 {
+		return rec, io.EOF
+	} else if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return rec, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return rec, fmt.Errorf("reading record: %v", err)
+	}
+	json := jsoniter.ConfigFastest
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return rec, fmt.Errorf("decoding record: %v", err)
+	}
+	return rec, nil
+}
+
+// Close closes the recording file.
+func (r *Reader) Close() error {
+	r.gz.Close()
+	return r.file.Close()
+}
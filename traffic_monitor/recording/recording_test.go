@@ -0,0 +1,78 @@
+package recording
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+func TestRecordAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recording.gz")
+	recorder, err := Create(path)
+	if err != nil {
+		t.Fatalf("creating recording: %v", err)
+	}
+
+	mc := tc.TrafficMonitorConfigMap{TrafficServer: map[string]tc.TrafficServer{"edge0": {HostName: "edge0"}}}
+	if err := recorder.RecordConfig(mc, []byte(`{"stats":{}}`)); err != nil {
+		t.Fatalf("recording config: %v", err)
+	}
+	if err := recorder.RecordConfig(mc, []byte(`{"stats":{}}`)); err != nil {
+		t.Fatalf("recording unchanged config: %v", err)
+	}
+	poll := Record{Kind: KindHealth, Time: time.Unix(1700000000, 0).UTC(), ID: "edge0", Format: "astats", Duration: time.Second, Body: []byte(`{"ats":{}}`)}
+	if err := recorder.Record(poll); err != nil {
+		t.Fatalf("recording poll: %v", err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("closing recording: %v", err)
+	}
+
+	reader, err := Open(path)
+	if err != nil {
+		t.Fatalf("opening recording: %v", err)
+	}
+	defer reader.Close()
+
+	rec, err := reader.Next()
+	if err != nil {
+		t.Fatalf("reading config record: %v", err)
+	}
+	if rec.Kind != KindConfig || rec.MonitorConfig == nil || rec.MonitorConfig.TrafficServer["edge0"].HostName != "edge0" || string(rec.CRConfig) != `{"stats":{}}` {
+		t.Errorf("expected config record with monitor config and CRConfig, actual: %+v", rec)
+	}
+
+	rec, err = reader.Next()
+	if err != nil {
+		t.Fatalf("reading poll record: %v", err)
+	}
+	if rec.Kind != KindHealth || rec.ID != "edge0" || string(rec.Body) != `{"ats":{}}` || !rec.Time.Equal(poll.Time) || rec.Duration != time.Second {
+		t.Errorf("expected unchanged config not to be recorded again, and poll record %+v, actual: %+v", poll, rec)
+	}
+
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF at end of recording, actual: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package threadsafe
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"sync"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/dsdata"
+)
+
+// DSProbeResults wraps the latest synthetic probe results of each delivery service, to be safe for multiple reader goroutines and a single writer.
+type DSProbeResults struct {
+	results *map[tc.DeliveryServiceName]dsdata.ProbeResults
+	m       *sync.RWMutex
+}
+
+// NewDSProbeResults returns a new, empty DSProbeResults.
+func NewDSProbeResults() DSProbeResults {
+	results := map[tc.DeliveryServiceName]dsdata.ProbeResults{}
+	return DSProbeResults{results: &results, m: &sync.RWMutex{}}
+}
+
+// Get returns the probe results of all probed delivery services. Callers MUST NOT modify the returned map.
+func (o DSProbeResults) Get() map[tc.DeliveryServiceName]dsdata.ProbeResults {
+	o.m.RLock()
+	defer o.m.RUnlock()
+	return *o.results
+}
+
+// GetDeliveryService returns the probe results of the given delivery service, and whether it is probed.
+func (o DSProbeResults) GetDeliveryService(name tc.DeliveryServiceName) (dsdata.ProbeResults, bool) {
+	o.m.RLock()
+	defer o.m.RUnlock()
+	results, ok := (*o.results)[name]
+	return results, ok
+}
+
+// Set replaces the probe results of all delivery services. This MUST NOT be called by multiple goroutines, and the given map MUST NOT be modified after it is set.
+func (o DSProbeResults) Set(results map[tc.DeliveryServiceName]dsdata.ProbeResults) {
+	o.m.Lock()
+	*o.results = results
+	o.m.Unlock()
+}
@@ -147,12 +147,16 @@ func (d TODataThreadsafe) Update(to towrap.TrafficOpsSessionThreadsafe, cdn stri
 	if err != nil {
 		return fmt.Errorf("getting last CRConfig: %v", err)
 	}
+	return d.UpdateFromCRConfig(crConfigBytes, mc)
+}
 
+// UpdateFromCRConfig updates the internal TOData from the given raw CRConfig and monitoring config, rather than fetching the CRConfig from Traffic Ops.
+func (d TODataThreadsafe) UpdateFromCRConfig(crConfigBytes []byte, mc tc.TrafficMonitorConfigMap) error {
 	newTOData := TOData{}
 
 	var crConfig CRConfig
 	json := jsoniter.ConfigFastest
-	err = json.Unmarshal(crConfigBytes, &crConfig)
+	err := json.Unmarshal(crConfigBytes, &crConfig)
 	if err != nil {
 		return fmt.Errorf("unmarshalling CRconfig: %v", err)
 	}
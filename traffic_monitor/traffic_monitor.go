@@ -20,8 +20,10 @@ package main
  */
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"os"
 	"runtime"
@@ -58,9 +60,12 @@ func main() {
 
 	opsConfigFile := flag.String("opsCfg", "", "The traffic ops config file")
 	configFileName := flag.String("config", "", "The Traffic Monitor config file path")
+	replayFile := flag.String("replay", "", "A recording file to replay, writing the resulting CRStates and events as JSON and exiting, instead of monitoring the CDN")
+	replaySpeed := flag.Float64("replaySpeed", 0, "The speed multiplier at which to replay the recording; 0 replays as fast as possible")
+	replayOutput := flag.String("replayOutput", "", "The file to write the replay result to; stdout if empty")
 	flag.Parse()
 
-	if *opsConfigFile == "" {
+	if *opsConfigFile == "" && *replayFile == "" {
 		fmt.Println("Error starting service: The --opsCfg argument is required")
 		os.Exit(1)
 	}
@@ -86,6 +91,14 @@ func main() {
 		staticData.Hostname = cfg.ShortHostnameOverride
 	}
 
+	if *replayFile != "" {
+		if err := replay(*replayFile, *replaySpeed, *replayOutput, cfg, staticData.Hostname); err != nil {
+			fmt.Printf("Error replaying: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	rand.Seed(time.Now().UnixNano())
 	log.Infof("Starting with config %+v\n", cfg)
 
@@ -95,3 +108,21 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// replay replays the given recording, and writes the result to the given output file, or stdout if it's empty.
+func replay(recordingFile string, speed float64, outputFile string, cfg config.Config, hostname string) error {
+	result, err := manager.Replay(recordingFile, cfg, hostname, speed)
+	if err != nil {
+		return err
+	}
+	bts, err := json.MarshalIndent(result, "", "\t")
+	if err != nil {
+		return fmt.Errorf("marshalling replay result: %v", err)
+	}
+	bts = append(bts, '\n')
+	if outputFile == "" {
+		_, err = os.Stdout.Write(bts)
+		return err
+	}
+	return ioutil.WriteFile(outputFile, bts, 0644)
+}
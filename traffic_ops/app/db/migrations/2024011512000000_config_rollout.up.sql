@@ -0,0 +1,56 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with this
+ * work for additional information regarding copyright ownership.  The ASF
+ * licenses this file to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+CREATE TABLE IF NOT EXISTS public.config_rollout (
+    id bigserial NOT NULL,
+    cdn_id bigint NOT NULL,
+    cachegroup_id bigint,
+    waves jsonb NOT NULL,
+    wave_timeout_seconds integer NOT NULL DEFAULT 0,
+    max_unhealthy integer NOT NULL DEFAULT 0,
+    current_wave integer NOT NULL DEFAULT 0,
+    wave_start_time timestamp with time zone DEFAULT now() NOT NULL,
+    status text NOT NULL DEFAULT 'RUNNING',
+    message text NOT NULL DEFAULT '',
+    username text NOT NULL,
+    start_time timestamp with time zone DEFAULT now() NOT NULL,
+    last_updated timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT config_rollout_pkey PRIMARY KEY (id),
+    CONSTRAINT config_rollout_cdn_fkey FOREIGN KEY (cdn_id) REFERENCES public.cdn(id) ON DELETE CASCADE,
+    CONSTRAINT config_rollout_cachegroup_fkey FOREIGN KEY (cachegroup_id) REFERENCES public.cachegroup(id) ON DELETE CASCADE,
+    CONSTRAINT config_rollout_status_check CHECK (status IN ('RUNNING', 'HALTED', 'SUCCEEDED'))
+);
+
+-- Only one rollout may be in progress per CDN, because the queued updates of concurrent rollouts can't be told apart.
+CREATE UNIQUE INDEX IF NOT EXISTS config_rollout_cdn_in_progress_idx ON public.config_rollout (cdn_id) WHERE status IN ('RUNNING', 'HALTED');
+
+CREATE TABLE IF NOT EXISTS public.config_rollout_server (
+    rollout_id bigint NOT NULL,
+    server_id bigint NOT NULL,
+    wave integer NOT NULL,
+    available_when_queued boolean NOT NULL,
+    queued_time timestamp with time zone DEFAULT now() NOT NULL,
+    last_updated timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT config_rollout_server_pkey PRIMARY KEY (rollout_id, server_id),
+    CONSTRAINT config_rollout_server_rollout_fkey FOREIGN KEY (rollout_id) REFERENCES public.config_rollout(id) ON DELETE CASCADE,
+    CONSTRAINT config_rollout_server_server_fkey FOREIGN KEY (server_id) REFERENCES public.server(id) ON DELETE CASCADE
+);
+
+CREATE TRIGGER on_update_current_timestamp
+    BEFORE UPDATE ON public.config_rollout
+    FOR EACH ROW
+    EXECUTE PROCEDURE on_update_current_timestamp_last_updated();
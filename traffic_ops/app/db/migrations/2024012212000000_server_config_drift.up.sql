@@ -0,0 +1,25 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with this
+ * work for additional information regarding copyright ownership.  The ASF
+ * licenses this file to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+CREATE TABLE IF NOT EXISTS public.server_config_drift (
+    server_id bigint NOT NULL,
+    drifted boolean NOT NULL,
+    files jsonb NOT NULL,
+    last_checked timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT server_config_drift_pkey PRIMARY KEY (server_id),
+    CONSTRAINT server_config_drift_server_fkey FOREIGN KEY (server_id) REFERENCES public.server(id) ON DELETE CASCADE
+);
@@ -0,0 +1,218 @@
+package v5
+
+/*
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+	"github.com/apache/trafficcontrol/v8/lib/go-util/assert"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/testing/api/utils"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/toclientlib"
+	client "github.com/apache/trafficcontrol/v8/traffic_ops/v5-client"
+
+	"github.com/lib/pq"
+)
+
+func TestConfigRollouts(t *testing.T) {
+	WithObjs(t, []TCObj{CDNs, Types, Profiles, Statuses, Divisions, Regions, PhysLocations, CacheGroups, Servers}, func() {
+
+		methodTests := utils.TestCase[client.Session, client.RequestOptions, tc.ConfigRolloutV5]{
+			"GET": {
+				"OK when VALID request": {
+					ClientSession: TOSession,
+					Expectations:  utils.CkRequest(utils.NoError(), utils.HasStatus(http.StatusOK)),
+				},
+				"BAD REQUEST when INVALID ID parameter": {
+					ClientSession: TOSession,
+					RequestOpts:   client.RequestOptions{QueryParameters: map[string][]string{"id": {"abc"}}},
+					Expectations:  utils.CkRequest(utils.HasError(), utils.HasStatus(http.StatusBadRequest)),
+				},
+			},
+			"POST": {
+				"BAD REQUEST when MISSING CDN ID": {
+					ClientSession: TOSession,
+					RequestBody: tc.ConfigRolloutV5{
+						Waves: []tc.ConfigRolloutWave{{Percent: util.Ptr(100.0)}},
+					},
+					Expectations: utils.CkRequest(utils.HasError(), utils.HasStatus(http.StatusBadRequest)),
+				},
+				"BAD REQUEST when LAST WAVE is NOT 100 PERCENT": {
+					ClientSession: TOSession,
+					RequestBody: tc.ConfigRolloutV5{
+						CDNID: GetCDNID(t, "cdn1")(),
+						Waves: []tc.ConfigRolloutWave{{PerCacheGroup: util.Ptr(1)}, {Percent: util.Ptr(50.0)}},
+					},
+					Expectations: utils.CkRequest(utils.HasError(), utils.HasStatus(http.StatusBadRequest)),
+				},
+				"BAD REQUEST when WAVE has BOTH PERCENT and PER CACHE GROUP": {
+					ClientSession: TOSession,
+					RequestBody: tc.ConfigRolloutV5{
+						CDNID: GetCDNID(t, "cdn1")(),
+						Waves: []tc.ConfigRolloutWave{{PerCacheGroup: util.Ptr(1), Percent: util.Ptr(100.0)}},
+					},
+					Expectations: utils.CkRequest(utils.HasError(), utils.HasStatus(http.StatusBadRequest)),
+				},
+				"BAD REQUEST when NEGATIVE MAX UNHEALTHY": {
+					ClientSession: TOSession,
+					RequestBody: tc.ConfigRolloutV5{
+						CDNID:        GetCDNID(t, "cdn1")(),
+						Waves:        []tc.ConfigRolloutWave{{Percent: util.Ptr(100.0)}},
+						MaxUnhealthy: -1,
+					},
+					Expectations: utils.CkRequest(utils.HasError(), utils.HasStatus(http.StatusBadRequest)),
+				},
+				"BAD REQUEST when NON-EXISTENT CDN": {
+					ClientSession: TOSession,
+					RequestBody: tc.ConfigRolloutV5{
+						CDNID: 999999,
+						Waves: []tc.ConfigRolloutWave{{Percent: util.Ptr(100.0)}},
+					},
+					Expectations: utils.CkRequest(utils.HasError(), utils.HasStatus(http.StatusBadRequest)),
+				},
+			},
+			"HALT": {
+				"NOT FOUND when NON-EXISTENT ID": {
+					EndpointID:    func() int { return 999999 },
+					ClientSession: TOSession,
+					Expectations:  utils.CkRequest(utils.HasError(), utils.HasStatus(http.StatusNotFound)),
+				},
+			},
+			"RESUME": {
+				"NOT FOUND when NON-EXISTENT ID": {
+					EndpointID:    func() int { return 999999 },
+					ClientSession: TOSession,
+					Expectations:  utils.CkRequest(utils.HasError(), utils.HasStatus(http.StatusNotFound)),
+				},
+			},
+			"DELETE": {
+				"NOT FOUND when NON-EXISTENT ID": {
+					EndpointID:    func() int { return 999999 },
+					ClientSession: TOSession,
+					Expectations:  utils.CkRequest(utils.HasError(), utils.HasStatus(http.StatusNotFound)),
+				},
+			},
+		}
+
+		for method, testCases := range methodTests {
+			t.Run(method, func(t *testing.T) {
+				for name, testCase := range testCases {
+					switch method {
+					case "GET":
+						t.Run(name, func(t *testing.T) {
+							resp, reqInf, err := testCase.ClientSession.GetConfigRollouts(testCase.RequestOpts)
+							for _, check := range testCase.Expectations {
+								check(t, reqInf, resp.Response, resp.Alerts, err)
+							}
+						})
+					case "POST":
+						t.Run(name, func(t *testing.T) {
+							resp, reqInf, err := testCase.ClientSession.CreateConfigRollout(testCase.RequestBody, testCase.RequestOpts)
+							for _, check := range testCase.Expectations {
+								check(t, reqInf, resp.Response, resp.Alerts, err)
+							}
+						})
+					case "HALT":
+						t.Run(name, func(t *testing.T) {
+							resp, reqInf, err := testCase.ClientSession.HaltConfigRollout(testCase.EndpointID(), testCase.RequestOpts)
+							for _, check := range testCase.Expectations {
+								check(t, reqInf, resp.Response, resp.Alerts, err)
+							}
+						})
+					case "RESUME":
+						t.Run(name, func(t *testing.T) {
+							resp, reqInf, err := testCase.ClientSession.ResumeConfigRollout(testCase.EndpointID(), testCase.RequestOpts)
+							for _, check := range testCase.Expectations {
+								check(t, reqInf, resp.Response, resp.Alerts, err)
+							}
+						})
+					case "DELETE":
+						t.Run(name, func(t *testing.T) {
+							resp, reqInf, err := testCase.ClientSession.DeleteConfigRollout(testCase.EndpointID(), testCase.RequestOpts)
+							for _, check := range testCase.Expectations {
+								check(t, reqInf, resp.Response, resp.Alerts, err)
+							}
+						})
+					}
+				}
+			})
+		}
+	})
+}
+
+// TestConfigRolloutHaltResumeDelete tests a rollout through its lifecycle. Starting a rollout requires Traffic Monitor,
+// which the API tests don't run, so the rollout is created in the database, as if its first wave had been queued.
+func TestConfigRolloutHaltResumeDelete(t *testing.T) {
+	WithObjs(t, []TCObj{CDNs, Types, Profiles, Statuses, Divisions, Regions, PhysLocations, CacheGroups, Servers}, func() {
+		rolloutServers := []string{"atlanta-edge-01", "atlanta-edge-03"}
+		id := createQueuedConfigRollout(t, rolloutServers)
+		validateUpdPendingSpecificServers(map[string]bool{"atlanta-edge-01": true, "atlanta-edge-03": true})(t, toclientlib.ReqInf{}, nil, tc.Alerts{}, nil)
+
+		// an update queued on a rollout server by something else than the rollout must not be dequeued when the rollout is halted
+		_, _, err := TOSession.SetServerQueueUpdate(GetServerID(t, "atlanta-edge-03")(), true, client.RequestOptions{})
+		assert.RequireNoError(t, err, "Unexpected error queueing update on server: %v", err)
+
+		resp, _, err := TOSession.HaltConfigRollout(id, client.RequestOptions{})
+		assert.RequireNoError(t, err, "Unexpected error halting config rollout: %v - alerts: %+v", err, resp.Alerts)
+		assert.Equal(t, tc.ConfigRolloutHalted, resp.Response.Status, "Expected halted config rollout status %s, actual %s", tc.ConfigRolloutHalted, resp.Response.Status)
+		validateUpdPendingSpecificServers(map[string]bool{"atlanta-edge-01": false, "atlanta-edge-03": true})(t, toclientlib.ReqInf{}, nil, tc.Alerts{}, nil)
+
+		_, reqInf, err := TOSession.HaltConfigRollout(id, client.RequestOptions{})
+		assert.Equal(t, http.StatusConflict, reqInf.StatusCode, "Expected halting a halted config rollout to be a conflict, actual status %d error %v", reqInf.StatusCode, err)
+
+		resp, _, err = TOSession.ResumeConfigRollout(id, client.RequestOptions{})
+		assert.RequireNoError(t, err, "Unexpected error resuming config rollout: %v - alerts: %+v", err, resp.Alerts)
+		assert.Equal(t, tc.ConfigRolloutRunning, resp.Response.Status, "Expected resumed config rollout status %s, actual %s", tc.ConfigRolloutRunning, resp.Response.Status)
+		validateUpdPendingSpecificServers(map[string]bool{"atlanta-edge-01": true, "atlanta-edge-03": true})(t, toclientlib.ReqInf{}, nil, tc.Alerts{}, nil)
+
+		opts := client.NewRequestOptions()
+		opts.QueryParameters.Set("id", strconv.Itoa(id))
+		rollouts, _, err := TOSession.GetConfigRollouts(opts)
+		assert.RequireNoError(t, err, "Unexpected error getting config rollout: %v - alerts: %+v", err, rollouts.Alerts)
+		assert.RequireEqual(t, 1, len(rollouts.Response), "Expected exactly one config rollout by ID %d, actual %d", id, len(rollouts.Response))
+		assert.Equal(t, tc.ConfigRolloutRunning, rollouts.Response[0].Status, "Expected config rollout status %s, actual %s", tc.ConfigRolloutRunning, rollouts.Response[0].Status)
+
+		// the resumed rollout queued both servers again, so deleting it dequeues both
+		resp, _, err = TOSession.DeleteConfigRollout(id, client.RequestOptions{})
+		assert.RequireNoError(t, err, "Unexpected error deleting config rollout: %v - alerts: %+v", err, resp.Alerts)
+		validateUpdPendingSpecificServers(map[string]bool{"atlanta-edge-01": false, "atlanta-edge-03": false})(t, toclientlib.ReqInf{}, nil, tc.Alerts{}, nil)
+
+		rollouts, _, err = TOSession.GetConfigRollouts(opts)
+		assert.RequireNoError(t, err, "Unexpected error getting config rollout: %v - alerts: %+v", err, rollouts.Alerts)
+		assert.Equal(t, 0, len(rollouts.Response), "Expected deleted config rollout not to exist, actual %d rollouts by ID %d", len(rollouts.Response), id)
+	})
+}
+
+// createQueuedConfigRollout creates a running config rollout of the CDN of the given servers in the database, as if it had queued updates on the servers in its first wave.
+// Returns the ID of the rollout.
+func createQueuedConfigRollout(t *testing.T, hostNames []string) int {
+	tx, err := db.Begin()
+	assert.RequireNoError(t, err, "Unexpected error beginning transaction: %v", err)
+	defer tx.Rollback()
+
+	id := 0
+	err = tx.QueryRow(`INSERT INTO config_rollout (cdn_id, waves, username) SELECT cdn_id, '[{"percent": 100}]', $2 FROM server WHERE host_name = $1 RETURNING id`, hostNames[0], Config.TrafficOps.Users.Admin).Scan(&id)
+	assert.RequireNoError(t, err, "Unexpected error inserting config rollout: %v", err)
+	_, err = tx.Exec(`INSERT INTO config_rollout_server (rollout_id, server_id, wave, available_when_queued, queued_time) SELECT $1, id, 0, TRUE, now() FROM server WHERE host_name = ANY($2)`, id, pq.Array(hostNames))
+	assert.RequireNoError(t, err, "Unexpected error inserting config rollout servers: %v", err)
+	_, err = tx.Exec(`UPDATE server SET config_update_time = now(), config_update_failed = FALSE WHERE host_name = ANY($1)`, pq.Array(hostNames))
+	assert.RequireNoError(t, err, "Unexpected error queueing config rollout servers: %v", err)
+	assert.RequireNoError(t, tx.Commit(), "Unexpected error committing config rollout")
+	return id
+}
@@ -83,6 +83,7 @@ type Config struct {
 	ConfigLDAP                                *ConfigLDAP
 	UserCacheRefreshIntervalSec               int `json:"user_cache_refresh_interval_sec"`
 	ServerUpdateStatusCacheRefreshIntervalSec int `json:"server_update_status_cache_refresh_interval_sec"`
+	ConfigRolloutIntervalSec                  int `json:"config_rollout_interval_sec"`
 	LDAPEnabled                               bool
 	LDAPConfPath                              string `json:"ldap_conf_location"`
 	ConfigInflux                              *ConfigInflux
@@ -290,6 +291,8 @@ const (
 	DefaultDBPort             = "5432"
 	MinPort                   = 1
 	MaxPort                   = 65535
+
+	DefaultConfigRolloutIntervalSecs = 30
 )
 
 // ErrorLog - critical messages
@@ -502,6 +505,9 @@ func ParseConfig(cfg Config) (Config, error) {
 	if cfg.ServerUpdateStatusCacheRefreshIntervalSec < 0 {
 		cfg.ServerUpdateStatusCacheRefreshIntervalSec = 0
 	}
+	if cfg.ConfigRolloutIntervalSec <= 0 {
+		cfg.ConfigRolloutIntervalSec = DefaultConfigRolloutIntervalSecs
+	}
 
 	invalidTOURLStr := ""
 	var err error
@@ -0,0 +1,209 @@
+// Package configdrift contains the handlers of cache server config drift: the config files which differ
+// on disk from the config generated from Traffic Ops, as reported by 't3c-apply --check-only --report'.
+package configdrift
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/dbhelpers"
+)
+
+const selectDriftQuery = `
+SELECT
+  d.server_id,
+  s.host_name,
+  c.name AS cdn_name,
+  d.drifted,
+  d.files,
+  d.last_checked
+FROM server_config_drift d
+JOIN server s ON s.id = d.server_id
+JOIN cdn c ON c.id = s.cdn_id
+`
+
+const upsertDriftQuery = `
+INSERT INTO server_config_drift (server_id, drifted, files)
+VALUES ($1, $2, $3)
+ON CONFLICT (server_id) DO UPDATE SET drifted = EXCLUDED.drifted, files = EXCLUDED.files, last_checked = now()
+`
+
+// Read is the handler for GET requests to /config_drift.
+func Read(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	tx := inf.Tx.Tx
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+
+	cols := map[string]dbhelpers.WhereColumnInfo{
+		"cdn":      {Column: "c.name", Checker: nil},
+		"cdnId":    {Column: "s.cdn_id", Checker: api.IsInt},
+		"drifted":  {Column: "d.drifted", Checker: api.IsBool},
+		"hostName": {Column: "s.host_name", Checker: nil},
+		"serverId": {Column: "d.server_id", Checker: api.IsInt},
+	}
+	where, orderBy, pagination, queryValues, errs := dbhelpers.BuildWhereAndOrderByAndPagination(inf.Params, cols)
+	if len(errs) > 0 {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, util.JoinErrs(errs), nil)
+		return
+	}
+	if orderBy == "" {
+		orderBy = "\nORDER BY s.host_name"
+	}
+
+	rows, err := inf.Tx.NamedQuery(selectDriftQuery+where+orderBy+pagination, queryValues)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("querying config drift: "+err.Error()))
+		return
+	}
+	defer rows.Close()
+	drifts := []tc.ServerConfigDriftV5{}
+	for rows.Next() {
+		drift, err := scanDrift(rows.Rows)
+		if err != nil {
+			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+			return
+		}
+		drifts = append(drifts, drift)
+	}
+	api.WriteResp(w, r, drifts)
+}
+
+// Update is the handler for PUT requests to /servers/{id-or-name}/config_drift.
+// It replaces the server's config drift with the files in the request.
+//
+// Like the server update status, the config drift is reported by the cache
+// itself, so the CDN lock isn't checked, and no change log entry is created.
+func Update(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"id-or-name"}, nil)
+	tx := inf.Tx.Tx
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+
+	idOrName := inf.Params["id-or-name"]
+	serverID, err := strconv.Atoi(idOrName)
+	if err != nil {
+		id, ok, err := dbhelpers.GetServerIDFromName(idOrName, tx)
+		if err != nil {
+			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("getting server id from name '%s': %w", idOrName, err))
+			return
+		} else if !ok {
+			api.HandleErr(w, r, tx, http.StatusNotFound, errors.New("server name '"+idOrName+"' not found"), nil)
+			return
+		}
+		serverID = id
+	} else if _, ok, err := dbhelpers.GetServerNameFromID(tx, int64(serverID)); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("getting server name from id %d: %w", serverID, err))
+		return
+	} else if !ok {
+		api.HandleErr(w, r, tx, http.StatusNotFound, fmt.Errorf("no server exists by ID %d", serverID), nil)
+		return
+	}
+
+	drift := tc.ServerConfigDriftV5{}
+	if err := json.NewDecoder(r.Body).Decode(&drift); err != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, errors.New("malformed JSON: "+err.Error()), nil)
+		return
+	}
+	if err := validate(drift); err != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, err, nil)
+		return
+	}
+	if drift.Files == nil {
+		drift.Files = []tc.ConfigDriftFile{}
+	}
+
+	files, err := json.Marshal(drift.Files)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("encoding files: "+err.Error()))
+		return
+	}
+	if _, err := tx.Exec(upsertDriftQuery, serverID, len(drift.Files) > 0, files); err != nil {
+		userErr, sysErr, errCode := api.ParseDBError(err)
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+
+	rows, err := tx.Query(selectDriftQuery+`WHERE d.server_id = $1`, serverID)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("querying updated config drift: "+err.Error()))
+		return
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("updated config drift of server %d not found", serverID))
+		return
+	}
+	drift, err = scanDrift(rows)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+	api.WriteRespAlertObj(w, r, tc.SuccessLevel, "server config drift updated", drift)
+}
+
+// scanDrift scans a row of selectDriftQuery.
+func scanDrift(rows *sql.Rows) (tc.ServerConfigDriftV5, error) {
+	drift := tc.ServerConfigDriftV5{}
+	files := []byte{}
+	if err := rows.Scan(
+		&drift.ServerID,
+		&drift.HostName,
+		&drift.CDNName,
+		&drift.Drifted,
+		&files,
+		&drift.LastChecked,
+	); err != nil {
+		return tc.ServerConfigDriftV5{}, errors.New("scanning config drift: " + err.Error())
+	}
+	if err := json.Unmarshal(files, &drift.Files); err != nil {
+		return tc.ServerConfigDriftV5{}, errors.New("decoding config drift files: " + err.Error())
+	}
+	return drift, nil
+}
+
+// validate returns an error describing every invalid file of a config drift request.
+func validate(drift tc.ServerConfigDriftV5) error {
+	errs := []error{}
+	for i, file := range drift.Files {
+		if file.Name == "" {
+			errs = append(errs, fmt.Errorf("file %d 'name' is required", i))
+		}
+		if file.Status != tc.ConfigDriftModified && file.Status != tc.ConfigDriftMissing {
+			errs = append(errs, fmt.Errorf("file %d 'status' must be %s or %s", i, tc.ConfigDriftModified, tc.ConfigDriftMissing))
+		}
+	}
+	return util.JoinErrs(errs)
+}
@@ -0,0 +1,52 @@
+package configdrift
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+func TestValidate(t *testing.T) {
+	valid := []tc.ServerConfigDriftV5{
+		{},
+		{Files: []tc.ConfigDriftFile{
+			{Name: "remap.config", Path: "/opt/trafficserver/etc/trafficserver", Status: tc.ConfigDriftModified},
+			{Name: "hosting.config", Path: "/opt/trafficserver/etc/trafficserver", Status: tc.ConfigDriftMissing},
+		}},
+	}
+	for _, drift := range valid {
+		if err := validate(drift); err != nil {
+			t.Errorf("expected valid config drift %+v, actual error: %v", drift, err)
+		}
+	}
+
+	invalid := map[string]tc.ServerConfigDriftV5{
+		"no name":        {Files: []tc.ConfigDriftFile{{Path: "/opt/trafficserver/etc/trafficserver", Status: tc.ConfigDriftModified}}},
+		"no status":      {Files: []tc.ConfigDriftFile{{Name: "remap.config"}}},
+		"unknown status": {Files: []tc.ConfigDriftFile{{Name: "remap.config", Status: "CHANGED"}}},
+	}
+	for name, drift := range invalid {
+		if err := validate(drift); err == nil {
+			t.Errorf("expected config drift with %s to be invalid, actual: valid", name)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+// Package configrollout contains the handlers and controller of staged config rollouts, which queue
+// config updates on a CDN's cache servers in waves, halting if the caches' health degrades.
+package configrollout
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/dbhelpers"
+)
+
+const insertRolloutQuery = `
+INSERT INTO config_rollout (cdn_id, cachegroup_id, waves, wave_timeout_seconds, max_unhealthy, username)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id
+`
+
+const inProgressQuery = `SELECT EXISTS(SELECT 1 FROM config_rollout WHERE cdn_id = $1 AND status IN ($2, $3))`
+
+// Read is the handler for GET requests to /config_rollouts.
+func Read(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	tx := inf.Tx.Tx
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+
+	cols := map[string]dbhelpers.WhereColumnInfo{
+		"id":     {Column: "r.id", Checker: api.IsInt},
+		"cdn":    {Column: "c.name", Checker: nil},
+		"cdnId":  {Column: "r.cdn_id", Checker: api.IsInt},
+		"status": {Column: "r.status", Checker: nil},
+	}
+	where, orderBy, pagination, queryValues, errs := dbhelpers.BuildWhereAndOrderByAndPagination(inf.Params, cols)
+	if len(errs) > 0 {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, util.JoinErrs(errs), nil)
+		return
+	}
+	if orderBy == "" {
+		orderBy = "\nORDER BY r.id"
+	}
+
+	rows, err := inf.Tx.NamedQuery(selectRolloutQuery+where+orderBy+pagination, queryValues)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("querying config rollouts: "+err.Error()))
+		return
+	}
+	rollouts := []tc.ConfigRolloutV5{}
+	for rows.Next() {
+		rollout, err := scanRollout(rows.Rows)
+		if err != nil {
+			rows.Close()
+			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+			return
+		}
+		rollouts = append(rollouts, rollout)
+	}
+	rows.Close()
+
+	for i := range rollouts {
+		if err := tx.QueryRow(countRolloutServersQuery, rollouts[i].ID).Scan(&rollouts[i].TotalServers); err != nil {
+			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("counting config rollout servers: "+err.Error()))
+			return
+		}
+	}
+	api.WriteResp(w, r, rollouts)
+}
+
+// Create is the handler for POST requests to /config_rollouts.
+// It starts the rollout by queueing updates on the servers of its first wave.
+func Create(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	tx := inf.Tx.Tx
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+
+	rollout := tc.ConfigRolloutV5{}
+	if err := json.NewDecoder(r.Body).Decode(&rollout); err != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, errors.New("malformed JSON: "+err.Error()), nil)
+		return
+	}
+	if err := validate(rollout); err != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	cdnName, ok, err := dbhelpers.GetCDNNameFromID(tx, int64(rollout.CDNID))
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("getting CDN name from ID: "+err.Error()))
+		return
+	} else if !ok {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, fmt.Errorf("no CDN exists by ID %d", rollout.CDNID), nil)
+		return
+	}
+	if rollout.CacheGroupID != nil {
+		if _, ok, err := dbhelpers.GetCacheGroupNameFromID(tx, *rollout.CacheGroupID); err != nil {
+			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("getting Cache Group name from ID: "+err.Error()))
+			return
+		} else if !ok {
+			api.HandleErr(w, r, tx, http.StatusBadRequest, fmt.Errorf("no Cache Group exists by ID %d", *rollout.CacheGroupID), nil)
+			return
+		}
+	}
+	userErr, sysErr, errCode = dbhelpers.CheckIfCurrentUserHasCdnLock(tx, string(cdnName), inf.User.UserName)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+
+	inProgress := false
+	if err := tx.QueryRow(inProgressQuery, rollout.CDNID, tc.ConfigRolloutRunning, tc.ConfigRolloutHalted).Scan(&inProgress); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("checking for config rollouts in progress: "+err.Error()))
+		return
+	} else if inProgress {
+		api.HandleErr(w, r, tx, http.StatusConflict, errors.New("CDN '"+string(cdnName)+"' already has a config rollout in progress, which must succeed or be deleted first"), nil)
+		return
+	}
+
+	// Rollouts can't detect degraded health without knowing which servers were available before they were queued.
+	available, err := getAvailability(tx, cdnName)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusServiceUnavailable, errors.New("cache availability could not be retrieved from Traffic Monitor, which is required to start a config rollout"), errors.New("getting cache availability: "+err.Error()))
+		return
+	}
+
+	waves, err := json.Marshal(rollout.Waves)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("encoding waves: "+err.Error()))
+		return
+	}
+	id := 0
+	if err := tx.QueryRow(insertRolloutQuery, rollout.CDNID, rollout.CacheGroupID, waves, rollout.WaveTimeoutSeconds, rollout.MaxUnhealthy, inf.User.UserName).Scan(&id); err != nil {
+		userErr, sysErr, errCode := api.ParseDBError(err)
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+
+	servers, err := getRolloutServers(tx, id)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+		return
+	} else if len(servers) == 0 {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, errors.New("the config rollout has no cache servers to queue updates on"), nil)
+		return
+	}
+
+	rollout, ok, err = getRollout(tx, id, false)
+	if err != nil || !ok {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("getting created config rollout %d: exists %v error %v", id, ok, err))
+		return
+	}
+	rollout.CurrentWave = -1
+	if err := queueNextWaves(tx, &rollout, servers, available); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("queueing first wave: "+err.Error()))
+		return
+	}
+	rollout.TotalServers = len(servers)
+
+	api.CreateChangeLogRawTx(api.ApiChange, fmt.Sprintf("CDN: %s, ID: %d, ACTION: config rollout started with %d waves on %d servers", cdnName, id, len(rollout.Waves), len(servers)), inf.User, tx)
+	api.WriteAlertsObj(w, r, http.StatusCreated, tc.CreateAlerts(tc.SuccessLevel, "config rollout started"), rollout)
+}
+
+// Halt is the handler for POST requests to /config_rollouts/{id}/halt.
+// It dequeues the updates of servers which haven't applied them, and stops queueing waves.
+func Halt(w http.ResponseWriter, r *http.Request) {
+	changeStatus(w, r, tc.ConfigRolloutRunning, func(inf *api.APIInfo, rollout *tc.ConfigRolloutV5) error {
+		return haltRollout(inf.Tx.Tx, rollout, "halted by user '"+inf.User.UserName+"'")
+	}, "halted")
+}
+
+// Resume is the handler for POST requests to /config_rollouts/{id}/resume.
+// It queues updates on the current wave's servers again, and resumes queueing waves.
+func Resume(w http.ResponseWriter, r *http.Request) {
+	changeStatus(w, r, tc.ConfigRolloutHalted, func(inf *api.APIInfo, rollout *tc.ConfigRolloutV5) error {
+		return resumeRollout(inf.Tx.Tx, rollout)
+	}, "resumed")
+}
+
+// changeStatus changes the status of the rollout in the request path with change, if it has the status from.
+func changeStatus(w http.ResponseWriter, r *http.Request, from tc.ConfigRolloutStatus, change func(*api.APIInfo, *tc.ConfigRolloutV5) error, action string) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"id"}, []string{"id"})
+	tx := inf.Tx.Tx
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+
+	rollout, ok := getRolloutForUpdate(w, r, inf)
+	if !ok {
+		return
+	}
+	if rollout.Status != from {
+		api.HandleErr(w, r, tx, http.StatusConflict, fmt.Errorf("config rollout %d is %s, only a %s rollout can be %s", rollout.ID, rollout.Status, from, action), nil)
+		return
+	}
+	if err := change(inf, &rollout); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+	if err := tx.QueryRow(countRolloutServersQuery, rollout.ID).Scan(&rollout.TotalServers); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("counting config rollout servers: "+err.Error()))
+		return
+	}
+
+	api.CreateChangeLogRawTx(api.ApiChange, fmt.Sprintf("CDN: %s, ID: %d, ACTION: config rollout %s", rollout.CDNName, rollout.ID, action), inf.User, tx)
+	api.WriteRespAlertObj(w, r, tc.SuccessLevel, "config rollout "+action, rollout)
+}
+
+// Delete is the handler for DELETE requests to /config_rollouts/{id}.
+// Deleting a rollout in progress dequeues the updates of servers which haven't applied them.
+func Delete(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"id"}, []string{"id"})
+	tx := inf.Tx.Tx
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+
+	rollout, ok := getRolloutForUpdate(w, r, inf)
+	if !ok {
+		return
+	}
+	if rollout.Status != tc.ConfigRolloutSucceeded {
+		if _, err := tx.Exec(dequeueRolloutServersQuery, rollout.ID); err != nil {
+			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("dequeueing config rollout servers: "+err.Error()))
+			return
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM config_rollout WHERE id = $1`, rollout.ID); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("deleting config rollout: "+err.Error()))
+		return
+	}
+
+	api.CreateChangeLogRawTx(api.ApiChange, fmt.Sprintf("CDN: %s, ID: %d, ACTION: config rollout deleted", rollout.CDNName, rollout.ID), inf.User, tx)
+	api.WriteRespAlertObj(w, r, tc.SuccessLevel, "config rollout deleted", rollout)
+}
+
+// getRolloutForUpdate gets and locks the rollout with the request's id, and checks the user has the lock of its CDN.
+// If it returns false, an error was written to w, and the handler must return.
+func getRolloutForUpdate(w http.ResponseWriter, r *http.Request, inf *api.APIInfo) (tc.ConfigRolloutV5, bool) {
+	tx := inf.Tx.Tx
+	rollout, ok, err := getRollout(tx, inf.IntParams["id"], false)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+		return tc.ConfigRolloutV5{}, false
+	} else if !ok {
+		api.HandleErr(w, r, tx, http.StatusNotFound, fmt.Errorf("no config rollout exists by ID %d", inf.IntParams["id"]), nil)
+		return tc.ConfigRolloutV5{}, false
+	}
+	userErr, sysErr, errCode := dbhelpers.CheckIfCurrentUserHasCdnLock(tx, rollout.CDNName, inf.User.UserName)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return tc.ConfigRolloutV5{}, false
+	}
+	return rollout, true
+}
+
+// validate returns an error describing every invalid field of a rollout request.
+func validate(rollout tc.ConfigRolloutV5) error {
+	errs := []error{}
+	if rollout.CDNID <= 0 {
+		errs = append(errs, errors.New("'cdnId' is required"))
+	}
+	if len(rollout.Waves) == 0 {
+		errs = append(errs, errors.New("'waves' must have at least one wave"))
+	}
+	for i, wave := range rollout.Waves {
+		switch {
+		case (wave.PerCacheGroup == nil) == (wave.Percent == nil):
+			errs = append(errs, fmt.Errorf("wave %d must have exactly one of 'perCacheGroup' and 'percent'", i))
+		case wave.PerCacheGroup != nil && *wave.PerCacheGroup <= 0:
+			errs = append(errs, fmt.Errorf("wave %d 'perCacheGroup' must be greater than 0", i))
+		case wave.Percent != nil && (*wave.Percent <= 0 || *wave.Percent > 100):
+			errs = append(errs, fmt.Errorf("wave %d 'percent' must be greater than 0 and at most 100", i))
+		}
+	}
+	if len(rollout.Waves) > 0 {
+		if last := rollout.Waves[len(rollout.Waves)-1]; last.Percent == nil || *last.Percent != 100 {
+			errs = append(errs, errors.New("the last wave must be 100 percent, so every server is queued"))
+		}
+	}
+	if rollout.WaveTimeoutSeconds < 0 {
+		errs = append(errs, errors.New("'waveTimeoutSeconds' must not be negative"))
+	}
+	if rollout.MaxUnhealthy < 0 {
+		errs = append(errs, errors.New("'maxUnhealthy' must not be negative"))
+	}
+	return util.JoinErrs(errs)
+}
@@ -0,0 +1,55 @@
+package configrollout
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+func TestValidate(t *testing.T) {
+	valid := tc.ConfigRolloutV5{
+		CDNID: 1,
+		Waves: []tc.ConfigRolloutWave{{PerCacheGroup: util.IntPtr(1)}, {Percent: util.FloatPtr(100)}},
+	}
+	if err := validate(valid); err != nil {
+		t.Errorf("expected valid rollout, actual error: %v", err)
+	}
+
+	invalid := map[string]tc.ConfigRolloutV5{
+		"no CDN":                 {Waves: valid.Waves},
+		"no waves":               {CDNID: 1},
+		"last wave not 100":      {CDNID: 1, Waves: []tc.ConfigRolloutWave{{Percent: util.FloatPtr(50)}}},
+		"last wave per CG":       {CDNID: 1, Waves: []tc.ConfigRolloutWave{{PerCacheGroup: util.IntPtr(1)}}},
+		"wave with both":         {CDNID: 1, Waves: []tc.ConfigRolloutWave{{PerCacheGroup: util.IntPtr(1), Percent: util.FloatPtr(10)}, {Percent: util.FloatPtr(100)}}},
+		"wave with neither":      {CDNID: 1, Waves: []tc.ConfigRolloutWave{{}, {Percent: util.FloatPtr(100)}}},
+		"zero per CG":            {CDNID: 1, Waves: []tc.ConfigRolloutWave{{PerCacheGroup: util.IntPtr(0)}, {Percent: util.FloatPtr(100)}}},
+		"percent over 100":       {CDNID: 1, Waves: []tc.ConfigRolloutWave{{Percent: util.FloatPtr(101)}, {Percent: util.FloatPtr(100)}}},
+		"negative timeout":       {CDNID: 1, Waves: valid.Waves, WaveTimeoutSeconds: -1},
+		"negative max unhealthy": {CDNID: 1, Waves: valid.Waves, MaxUnhealthy: -1},
+	}
+	for name, rollout := range invalid {
+		if err := validate(rollout); err == nil {
+			t.Errorf("expected rollout with %s to be invalid, actual: valid", name)
+		}
+	}
+}
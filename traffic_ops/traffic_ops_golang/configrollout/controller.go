@@ -0,0 +1,425 @@
+package configrollout
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/dbhelpers"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/util/monitorhlp"
+
+	"github.com/lib/pq"
+)
+
+const selectRolloutQuery = `
+SELECT
+  r.id,
+  r.cdn_id,
+  c.name AS cdn_name,
+  r.cachegroup_id,
+  r.waves,
+  r.wave_timeout_seconds,
+  r.max_unhealthy,
+  r.current_wave,
+  r.wave_start_time,
+  r.status,
+  r.message,
+  r.username,
+  (SELECT COUNT(*) FROM config_rollout_server rs WHERE rs.rollout_id = r.id) AS queued_servers,
+  r.start_time,
+  r.last_updated
+FROM config_rollout r
+JOIN cdn c ON c.id = r.cdn_id
+`
+
+// rolloutServersFrom selects the servers of the rollout r: the cache servers of its CDN and Cache Group,
+// which either have already been queued, or are in a status which runs t3c and is monitored.
+const rolloutServersFrom = `
+FROM config_rollout r
+JOIN server s ON s.cdn_id = r.cdn_id AND (r.cachegroup_id IS NULL OR s.cachegroup = r.cachegroup_id)
+JOIN type t ON t.id = s.type
+JOIN status st ON st.id = s.status
+LEFT JOIN config_rollout_server rs ON rs.server_id = s.id AND rs.rollout_id = r.id
+WHERE r.id = $1
+AND (t.name LIKE '` + tc.EdgeTypePrefix + `%' OR t.name LIKE '` + tc.MidTypePrefix + `%')
+AND (rs.server_id IS NOT NULL OR st.name IN ('` + string(tc.CacheStatusOnline) + `', '` + string(tc.CacheStatusReported) + `', '` + string(tc.CacheStatusAdminDown) + `'))
+`
+
+const selectRolloutServersQuery = `
+SELECT
+  s.id,
+  s.host_name,
+  s.cachegroup,
+  rs.server_id IS NOT NULL AS queued,
+  COALESCE(rs.wave, 0) AS wave,
+  COALESCE(rs.available_when_queued, FALSE) AS available_when_queued,
+  s.config_update_time <= s.config_apply_time AS applied,
+  s.config_update_failed
+` + rolloutServersFrom
+
+const countRolloutServersQuery = `SELECT COUNT(*)` + rolloutServersFrom
+
+const queueServersQuery = `
+UPDATE public.server
+SET config_update_time = now(), config_update_failed = FALSE
+WHERE id = ANY($1::BIGINT[])
+`
+
+const insertRolloutServersQuery = `
+INSERT INTO config_rollout_server (rollout_id, server_id, wave, available_when_queued, queued_time)
+SELECT $1, UNNEST($2::BIGINT[]), $3, UNNEST($4::BOOLEAN[]), now()
+ON CONFLICT (rollout_id, server_id) DO UPDATE SET wave = EXCLUDED.wave, available_when_queued = EXCLUDED.available_when_queued, queued_time = EXCLUDED.queued_time
+`
+
+// requeueWaveServersQuery queues updates again on the servers the rollout queued in a wave.
+const requeueWaveServersQuery = `
+WITH requeued AS (
+  UPDATE config_rollout_server
+  SET queued_time = now()
+  WHERE rollout_id = $1 AND wave = $2
+  RETURNING server_id
+)
+UPDATE public.server
+SET config_update_time = now(), config_update_failed = FALSE
+WHERE id IN (SELECT server_id FROM requeued)
+`
+
+// dequeueRolloutServersQuery dequeues the updates the rollout queued on its servers which haven't applied them yet.
+// Updates queued on the servers since, by anything but the rollout, have a different time than the rollout queued, and are kept.
+const dequeueRolloutServersQuery = `
+UPDATE public.server s
+SET config_update_time = s.config_apply_time
+FROM config_rollout_server rs
+WHERE rs.server_id = s.id
+AND rs.rollout_id = $1
+AND s.config_update_time = rs.queued_time
+AND s.config_update_time > s.config_apply_time
+`
+
+var controllerOnce = sync.Once{}
+
+// InitController starts the goroutine which advances running config rollouts every interval.
+//
+// Every Traffic Ops instance runs a controller. Each rollout is locked while it's advanced, so only one instance advances it at a time.
+func InitController(interval time.Duration, db *sql.DB, timeout time.Duration) {
+	controllerOnce.Do(func() {
+		if interval <= 0 {
+			return
+		}
+		go func() {
+			for {
+				time.Sleep(interval)
+				advanceRollouts(db, timeout)
+			}
+		}()
+	})
+}
+
+// advanceRollouts advances every running rollout. Errors are logged, not returned.
+func advanceRollouts(db *sql.DB, timeout time.Duration) {
+	ids, err := getRunningRolloutIDs(db, timeout)
+	if err != nil {
+		log.Errorln("config rollout controller: getting running rollouts: " + err.Error())
+		return
+	}
+	for _, id := range ids {
+		if err := advanceRolloutDB(db, timeout, id); err != nil {
+			log.Errorf("config rollout controller: advancing rollout %d: %v\n", id, err)
+		}
+	}
+}
+
+func getRunningRolloutIDs(db *sql.DB, timeout time.Duration) ([]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	rows, err := db.QueryContext(ctx, `SELECT id FROM config_rollout WHERE status = $1`, tc.ConfigRolloutRunning)
+	if err != nil {
+		return nil, errors.New("querying rollouts: " + err.Error())
+	}
+	defer log.Close(rows, "closing running rollout rows")
+	ids := []int{}
+	for rows.Next() {
+		id := 0
+		if err := rows.Scan(&id); err != nil {
+			return nil, errors.New("scanning rollouts: " + err.Error())
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// advanceRolloutDB advances the rollout in its own transaction, if it's still running and not locked by another Traffic Ops.
+func advanceRolloutDB(db *sql.DB, timeout time.Duration, id int) error {
+	// Traffic Monitors are requested while the rollout is locked, so the transaction must outlast them.
+	ctx, cancel := context.WithTimeout(context.Background(), timeout+monitorhlp.MonitorRequestTimeout)
+	defer cancel()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New("beginning transaction: " + err.Error())
+	}
+	commit := false
+	defer dbhelpers.CommitIf(tx, &commit)
+
+	rollout, ok, err := getRollout(tx, id, true)
+	if err != nil {
+		return err
+	}
+	if !ok || rollout.Status != tc.ConfigRolloutRunning {
+		commit = true
+		return nil
+	}
+	if err := advanceRollout(tx, &rollout, time.Now()); err != nil {
+		return err
+	}
+	commit = true
+	return nil
+}
+
+// advanceRollout halts the running rollout if its servers' health degraded, or else queues its next waves whose previous waves have applied their updates.
+func advanceRollout(tx *sql.Tx, rollout *tc.ConfigRolloutV5, now time.Time) error {
+	servers, err := getRolloutServers(tx, rollout.ID)
+	if err != nil {
+		return err
+	}
+	available, err := getAvailability(tx, tc.CDNName(rollout.CDNName))
+	if err != nil {
+		log.Warnf("config rollout %d: getting cache availability, not queueing the next wave until Traffic Monitor is available: %v\n", rollout.ID, err)
+		available = nil
+	}
+	if reason := haltReason(*rollout, servers, available, now); reason != "" {
+		log.Warnf("config rollout %d: halting: %s\n", rollout.ID, reason)
+		if err := haltRollout(tx, rollout, reason); err != nil {
+			return err
+		}
+		return createSystemChangeLog(tx, *rollout, fmt.Sprintf("CDN: %s, ID: %d, ACTION: config rollout halted: %s", rollout.CDNName, rollout.ID, reason))
+	}
+	if available == nil {
+		return nil
+	}
+	return queueNextWaves(tx, rollout, servers, available)
+}
+
+// queueNextWaves queues waves until one has servers which haven't applied their updates, or the rollout succeeds.
+// The rollout.CurrentWave must have been queued; it may be -1 to start the rollout.
+func queueNextWaves(tx *sql.Tx, rollout *tc.ConfigRolloutV5, servers []rolloutServer, available map[tc.CacheName]bool) error {
+	for waveApplied(servers, rollout.CurrentWave) {
+		wave := rollout.CurrentWave + 1
+		if wave >= len(rollout.Waves) {
+			// The last wave is 100 percent, but servers may have been added since it was queued. They're queued in the last wave.
+			wave = len(rollout.Waves) - 1
+			if len(waveServers(servers, rollout.Waves[wave])) == 0 {
+				log.Infof("config rollout %d: all servers applied their updates, rollout succeeded\n", rollout.ID)
+				return setRolloutStatus(tx, rollout, tc.ConfigRolloutSucceeded, "all servers applied their updates")
+			}
+		}
+		var err error
+		if servers, err = queueWave(tx, rollout, servers, available, wave); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queueWave queues updates on the wave's servers, and makes it the rollout's current wave.
+// Returns the servers with the queued servers' state updated.
+func queueWave(tx *sql.Tx, rollout *tc.ConfigRolloutV5, servers []rolloutServer, available map[tc.CacheName]bool, wave int) ([]rolloutServer, error) {
+	queued := map[int]struct{}{}
+	ids := []int64{}
+	availableWhenQueued := []bool{}
+	for _, sv := range waveServers(servers, rollout.Waves[wave]) {
+		queued[sv.ID] = struct{}{}
+		ids = append(ids, int64(sv.ID))
+		availableWhenQueued = append(availableWhenQueued, available[tc.CacheName(sv.HostName)])
+	}
+	if len(ids) > 0 {
+		if _, err := tx.Exec(queueServersQuery, pq.Array(ids)); err != nil {
+			return nil, errors.New("queueing server updates: " + err.Error())
+		}
+		if _, err := tx.Exec(insertRolloutServersQuery, rollout.ID, pq.Array(ids), wave, pq.Array(availableWhenQueued)); err != nil {
+			return nil, errors.New("inserting rollout servers: " + err.Error())
+		}
+	}
+	if err := tx.QueryRow(`UPDATE config_rollout SET current_wave = $2, wave_start_time = now() WHERE id = $1 RETURNING wave_start_time`, rollout.ID, wave).Scan(&rollout.WaveStartTime); err != nil {
+		return nil, errors.New("updating rollout wave: " + err.Error())
+	}
+	rollout.CurrentWave = wave
+	rollout.QueuedServers += len(ids)
+	log.Infof("config rollout %d: queued %d servers in wave %d\n", rollout.ID, len(ids), wave)
+
+	updated := make([]rolloutServer, 0, len(servers))
+	for _, sv := range servers {
+		if _, ok := queued[sv.ID]; ok {
+			sv.Queued = true
+			sv.Wave = wave
+			sv.AvailableWhenQueued = available[tc.CacheName(sv.HostName)]
+			sv.Applied = false
+			sv.UpdateFailed = false
+		}
+		updated = append(updated, sv)
+	}
+	return updated, nil
+}
+
+// haltRollout dequeues the updates the rollout queued on its servers which haven't applied them yet, so the updates don't spread further, and halts the rollout.
+func haltRollout(tx *sql.Tx, rollout *tc.ConfigRolloutV5, reason string) error {
+	if _, err := tx.Exec(dequeueRolloutServersQuery, rollout.ID); err != nil {
+		return errors.New("dequeueing rollout servers: " + err.Error())
+	}
+	return setRolloutStatus(tx, rollout, tc.ConfigRolloutHalted, reason)
+}
+
+// resumeRollout queues updates on the current wave's servers again, and resumes the halted rollout.
+// The current wave's timeout starts over.
+func resumeRollout(tx *sql.Tx, rollout *tc.ConfigRolloutV5) error {
+	if _, err := tx.Exec(requeueWaveServersQuery, rollout.ID, rollout.CurrentWave); err != nil {
+		return errors.New("queueing current wave server updates: " + err.Error())
+	}
+	if err := tx.QueryRow(`UPDATE config_rollout SET wave_start_time = now() WHERE id = $1 RETURNING wave_start_time`, rollout.ID).Scan(&rollout.WaveStartTime); err != nil {
+		return errors.New("updating rollout wave start time: " + err.Error())
+	}
+	return setRolloutStatus(tx, rollout, tc.ConfigRolloutRunning, "")
+}
+
+func setRolloutStatus(tx *sql.Tx, rollout *tc.ConfigRolloutV5, status tc.ConfigRolloutStatus, message string) error {
+	if err := tx.QueryRow(`UPDATE config_rollout SET status = $2, message = $3 WHERE id = $1 RETURNING last_updated`, rollout.ID, status, message).Scan(&rollout.LastUpdated); err != nil {
+		return errors.New("updating rollout status: " + err.Error())
+	}
+	rollout.Status = status
+	rollout.Message = message
+	return nil
+}
+
+// createSystemChangeLog creates a change log entry for a change the controller made, attributed to the user who started the rollout.
+func createSystemChangeLog(tx *sql.Tx, rollout tc.ConfigRolloutV5, msg string) error {
+	if _, err := tx.Exec(`INSERT INTO log (level, message, tm_user) SELECT $1, $2, id FROM tm_user WHERE username = $3`, "APICHANGE", msg, rollout.Username); err != nil {
+		return errors.New("inserting change log: " + err.Error())
+	}
+	return nil
+}
+
+// getRollout returns the rollout with the id, locked for update, and whether it exists.
+// If skipLocked, a rollout locked by another transaction is returned as not existing, rather than waiting for the lock.
+func getRollout(tx *sql.Tx, id int, skipLocked bool) (tc.ConfigRolloutV5, bool, error) {
+	q := selectRolloutQuery + `WHERE r.id = $1 FOR UPDATE OF r`
+	if skipLocked {
+		q += ` SKIP LOCKED`
+	}
+	rows, err := tx.Query(q, id)
+	if err != nil {
+		return tc.ConfigRolloutV5{}, false, errors.New("querying rollout: " + err.Error())
+	}
+	defer log.Close(rows, "closing rollout rows")
+	if !rows.Next() {
+		return tc.ConfigRolloutV5{}, false, rows.Err()
+	}
+	rollout, err := scanRollout(rows)
+	if err != nil {
+		return tc.ConfigRolloutV5{}, false, err
+	}
+	return rollout, true, nil
+}
+
+// scanRollout scans a row of selectRolloutQuery.
+func scanRollout(rows *sql.Rows) (tc.ConfigRolloutV5, error) {
+	rollout := tc.ConfigRolloutV5{}
+	waves := []byte{}
+	cgID := sql.NullInt64{}
+	if err := rows.Scan(
+		&rollout.ID,
+		&rollout.CDNID,
+		&rollout.CDNName,
+		&cgID,
+		&waves,
+		&rollout.WaveTimeoutSeconds,
+		&rollout.MaxUnhealthy,
+		&rollout.CurrentWave,
+		&rollout.WaveStartTime,
+		&rollout.Status,
+		&rollout.Message,
+		&rollout.Username,
+		&rollout.QueuedServers,
+		&rollout.StartTime,
+		&rollout.LastUpdated,
+	); err != nil {
+		return tc.ConfigRolloutV5{}, errors.New("scanning rollout: " + err.Error())
+	}
+	if cgID.Valid {
+		rollout.CacheGroupID = util.IntPtr(int(cgID.Int64))
+	}
+	if err := json.Unmarshal(waves, &rollout.Waves); err != nil {
+		return tc.ConfigRolloutV5{}, fmt.Errorf("decoding rollout %d waves: %w", rollout.ID, err)
+	}
+	return rollout, nil
+}
+
+func getRolloutServers(tx *sql.Tx, id int) ([]rolloutServer, error) {
+	rows, err := tx.Query(selectRolloutServersQuery, id)
+	if err != nil {
+		return nil, errors.New("querying rollout servers: " + err.Error())
+	}
+	defer log.Close(rows, "closing rollout server rows")
+	servers := []rolloutServer{}
+	for rows.Next() {
+		sv := rolloutServer{}
+		if err := rows.Scan(&sv.ID, &sv.HostName, &sv.CacheGroupID, &sv.Queued, &sv.Wave, &sv.AvailableWhenQueued, &sv.Applied, &sv.UpdateFailed); err != nil {
+			return nil, errors.New("scanning rollout servers: " + err.Error())
+		}
+		servers = append(servers, sv)
+	}
+	return servers, rows.Err()
+}
+
+// getAvailability returns whether each cache in the CDN is available, from the first of the CDN's Traffic Monitors which responds.
+func getAvailability(tx *sql.Tx, cdn tc.CDNName) (map[tc.CacheName]bool, error) {
+	monitors, err := monitorhlp.GetURLs(tx)
+	if err != nil {
+		return nil, errors.New("getting monitors: " + err.Error())
+	}
+	if len(monitors[cdn]) == 0 {
+		return nil, errors.New("CDN '" + string(cdn) + "' has no online Traffic Monitors")
+	}
+	client, err := monitorhlp.GetClient(tx)
+	if err != nil {
+		return nil, errors.New("getting monitor client: " + err.Error())
+	}
+	errs := []error{}
+	for _, monitorFQDN := range monitors[cdn] {
+		crStates, err := monitorhlp.GetCRStates(monitorFQDN, client)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		available := make(map[tc.CacheName]bool, len(crStates.Caches))
+		for name, cache := range crStates.Caches {
+			available[name] = cache.IsAvailable
+		}
+		return available, nil
+	}
+	return nil, util.JoinErrs(errs)
+}
@@ -0,0 +1,141 @@
+package configrollout
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// rolloutServer is a server a rollout queues updates on, with its rollout and update state.
+type rolloutServer struct {
+	ID           int
+	HostName     string
+	CacheGroupID int
+	// Queued is whether the rollout has queued an update on the server.
+	Queued bool
+	// Wave is the wave the server was queued in. Only valid if Queued.
+	Wave int
+	// AvailableWhenQueued is whether Traffic Monitor reported the server available when it was queued. Only valid if Queued.
+	AvailableWhenQueued bool
+	// Applied is whether the server has no pending config update.
+	Applied bool
+	// UpdateFailed is whether the server reported it failed to apply its config update.
+	UpdateFailed bool
+}
+
+// waveServers returns the servers to queue updates on for the wave: the servers which haven't been queued yet,
+// up to the wave's number per Cache Group or percent of all servers.
+// Servers are chosen in host name order, and a percent wave spreads them across Cache Groups.
+func waveServers(servers []rolloutServer, wave tc.ConfigRolloutWave) []rolloutServer {
+	unqueuedByCG := map[int][]rolloutServer{}
+	cgs := []int{}
+	numQueued := 0
+	for _, sv := range servers {
+		if sv.Queued {
+			numQueued++
+			continue
+		}
+		if _, ok := unqueuedByCG[sv.CacheGroupID]; !ok {
+			cgs = append(cgs, sv.CacheGroupID)
+		}
+		unqueuedByCG[sv.CacheGroupID] = append(unqueuedByCG[sv.CacheGroupID], sv)
+	}
+	sort.Ints(cgs)
+	for _, cg := range cgs {
+		sort.Slice(unqueuedByCG[cg], func(i, j int) bool { return unqueuedByCG[cg][i].HostName < unqueuedByCG[cg][j].HostName })
+	}
+
+	selected := []rolloutServer{}
+	if wave.PerCacheGroup != nil {
+		for _, cg := range cgs {
+			num := *wave.PerCacheGroup
+			if num > len(unqueuedByCG[cg]) {
+				num = len(unqueuedByCG[cg])
+			}
+			selected = append(selected, unqueuedByCG[cg][:num]...)
+		}
+		return selected
+	}
+	if wave.Percent == nil {
+		return selected
+	}
+
+	numToQueue := int(math.Ceil(*wave.Percent/100*float64(len(servers)))) - numQueued
+	for i := 0; len(selected) < numToQueue; i++ {
+		added := false
+		for _, cg := range cgs {
+			if i < len(unqueuedByCG[cg]) && len(selected) < numToQueue {
+				selected = append(selected, unqueuedByCG[cg][i])
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+	return selected
+}
+
+// waveApplied returns whether every server queued in the wave has applied its update.
+func waveApplied(servers []rolloutServer, wave int) bool {
+	for _, sv := range servers {
+		if sv.Queued && sv.Wave == wave && !sv.Applied {
+			return false
+		}
+	}
+	return true
+}
+
+// haltReason returns why the rollout must be halted, or the empty string if it may continue.
+//
+// The available is the Traffic Monitor availability of each server by host name, or nil if Traffic Monitor couldn't be reached,
+// in which case only failures and the wave timeout are checked.
+func haltReason(rollout tc.ConfigRolloutV5, servers []rolloutServer, available map[tc.CacheName]bool, now time.Time) string {
+	unhealthy := []string{}
+	for _, sv := range servers {
+		if !sv.Queued {
+			continue
+		}
+		if sv.UpdateFailed && !sv.Applied {
+			return "server '" + sv.HostName + "' failed to apply its config update"
+		}
+		if !sv.AvailableWhenQueued || available == nil {
+			continue
+		}
+		if isAvailable, ok := available[tc.CacheName(sv.HostName)]; ok && !isAvailable {
+			unhealthy = append(unhealthy, sv.HostName)
+		}
+	}
+	if len(unhealthy) > rollout.MaxUnhealthy {
+		sort.Strings(unhealthy)
+		return fmt.Sprintf("%d servers became unavailable, more than the maximum %d: %v", len(unhealthy), rollout.MaxUnhealthy, unhealthy)
+	}
+	if rollout.WaveTimeoutSeconds > 0 && !waveApplied(servers, rollout.CurrentWave) {
+		if timeout := time.Duration(rollout.WaveTimeoutSeconds) * time.Second; now.Sub(rollout.WaveStartTime) > timeout {
+			return fmt.Sprintf("wave %d servers did not apply their updates within %v", rollout.CurrentWave, timeout)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,171 @@
+package configrollout
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+func testServers() []rolloutServer {
+	return []rolloutServer{
+		{ID: 1, HostName: "edge-a-2", CacheGroupID: 1},
+		{ID: 2, HostName: "edge-a-1", CacheGroupID: 1},
+		{ID: 3, HostName: "edge-a-3", CacheGroupID: 1},
+		{ID: 4, HostName: "edge-b-1", CacheGroupID: 2},
+		{ID: 5, HostName: "edge-b-2", CacheGroupID: 2},
+		{ID: 6, HostName: "edge-c-1", CacheGroupID: 3},
+		{ID: 7, HostName: "edge-c-2", CacheGroupID: 3},
+		{ID: 8, HostName: "edge-c-3", CacheGroupID: 3},
+	}
+}
+
+func hostNames(servers []rolloutServer) string {
+	names := []string{}
+	for _, sv := range servers {
+		names = append(names, sv.HostName)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestWaveServersPerCacheGroup(t *testing.T) {
+	servers := testServers()
+	actual := hostNames(waveServers(servers, tc.ConfigRolloutWave{PerCacheGroup: util.IntPtr(1)}))
+	if expected := "edge-a-1,edge-b-1,edge-c-1"; actual != expected {
+		t.Errorf("expected first wave %s, actual %s", expected, actual)
+	}
+
+	servers[1].Queued = true
+	servers[3].Queued = true
+	actual = hostNames(waveServers(servers, tc.ConfigRolloutWave{PerCacheGroup: util.IntPtr(2)}))
+	if expected := "edge-a-2,edge-a-3,edge-b-2,edge-c-1,edge-c-2"; actual != expected {
+		t.Errorf("expected per cache group wave to skip queued servers and be %s, actual %s", expected, actual)
+	}
+}
+
+func TestWaveServersPercent(t *testing.T) {
+	servers := testServers()
+	actual := hostNames(waveServers(servers, tc.ConfigRolloutWave{Percent: util.FloatPtr(25)}))
+	if expected := "edge-a-1,edge-b-1"; actual != expected {
+		t.Errorf("expected 25 percent wave %s, actual %s", expected, actual)
+	}
+
+	// Percent waves include already queued servers, so a 50 percent wave after 3 queued servers queues 1 more.
+	servers[0].Queued = true
+	servers[1].Queued = true
+	servers[2].Queued = true
+	actual = hostNames(waveServers(servers, tc.ConfigRolloutWave{Percent: util.FloatPtr(50)}))
+	if expected := "edge-b-1"; actual != expected {
+		t.Errorf("expected 50 percent wave %s, actual %s", expected, actual)
+	}
+
+	actual = hostNames(waveServers(servers, tc.ConfigRolloutWave{Percent: util.FloatPtr(100)}))
+	if expected := "edge-b-1,edge-c-1,edge-b-2,edge-c-2,edge-c-3"; actual != expected {
+		t.Errorf("expected 100 percent wave %s, actual %s", expected, actual)
+	}
+
+	for i := range servers {
+		servers[i].Queued = true
+	}
+	if actual := waveServers(servers, tc.ConfigRolloutWave{Percent: util.FloatPtr(100)}); len(actual) != 0 {
+		t.Errorf("expected no servers when all are queued, actual %s", hostNames(actual))
+	}
+}
+
+func TestWaveApplied(t *testing.T) {
+	servers := testServers()
+	servers[0].Queued, servers[0].Wave, servers[0].Applied = true, 0, true
+	servers[1].Queued, servers[1].Wave, servers[1].Applied = true, 1, false
+	if !waveApplied(servers, 0) {
+		t.Error("expected wave 0 applied")
+	}
+	if waveApplied(servers, 1) {
+		t.Error("expected wave 1 not applied")
+	}
+	if !waveApplied(servers, 2) {
+		t.Error("expected wave with no servers applied")
+	}
+}
+
+func TestHaltReason(t *testing.T) {
+	now := time.Now()
+	rollout := tc.ConfigRolloutV5{CurrentWave: 0, MaxUnhealthy: 1, WaveTimeoutSeconds: 60, WaveStartTime: now.Add(-time.Second)}
+	queued := func() []rolloutServer {
+		servers := testServers()
+		for i := range servers[:3] {
+			servers[i].Queued = true
+			servers[i].AvailableWhenQueued = true
+		}
+		return servers
+	}
+	available := map[tc.CacheName]bool{"edge-a-1": true, "edge-a-2": true, "edge-a-3": true}
+
+	if reason := haltReason(rollout, queued(), available, now); reason != "" {
+		t.Errorf("expected healthy rollout to continue, actual halt reason '%s'", reason)
+	}
+
+	servers := queued()
+	servers[0].UpdateFailed = true
+	if reason := haltReason(rollout, servers, nil, now); !strings.Contains(reason, "edge-a-2") {
+		t.Errorf("expected failed update of edge-a-2 to halt rollout, actual halt reason '%s'", reason)
+	}
+	servers[0].Applied = true
+	if reason := haltReason(rollout, servers, nil, now); reason != "" {
+		t.Errorf("expected failed update which was later applied not to halt rollout, actual halt reason '%s'", reason)
+	}
+
+	available["edge-a-1"] = false
+	if reason := haltReason(rollout, queued(), available, now); reason != "" {
+		t.Errorf("expected max unhealthy servers to continue, actual halt reason '%s'", reason)
+	}
+	available["edge-a-2"] = false
+	if reason := haltReason(rollout, queued(), available, now); !strings.Contains(reason, "2 servers became unavailable") {
+		t.Errorf("expected more than max unhealthy servers to halt rollout, actual halt reason '%s'", reason)
+	}
+	if reason := haltReason(rollout, queued(), nil, now); reason != "" {
+		t.Errorf("expected unknown availability not to halt rollout, actual halt reason '%s'", reason)
+	}
+
+	servers = queued()
+	servers[0].AvailableWhenQueued = false
+	if reason := haltReason(rollout, servers, available, now); reason != "" {
+		t.Errorf("expected servers unavailable when queued not to count as unhealthy, actual halt reason '%s'", reason)
+	}
+
+	rollout.WaveStartTime = now.Add(-2 * time.Minute)
+	if reason := haltReason(rollout, queued(), nil, now); !strings.Contains(reason, "did not apply") {
+		t.Errorf("expected wave timeout to halt rollout, actual halt reason '%s'", reason)
+	}
+	servers = queued()
+	for i := range servers {
+		servers[i].Applied = true
+	}
+	if reason := haltReason(rollout, servers, nil, now); reason != "" {
+		t.Errorf("expected applied wave not to time out, actual halt reason '%s'", reason)
+	}
+	rollout.WaveTimeoutSeconds = 0
+	if reason := haltReason(rollout, queued(), nil, now); reason != "" {
+		t.Errorf("expected wave with no timeout not to time out, actual halt reason '%s'", reason)
+	}
+}
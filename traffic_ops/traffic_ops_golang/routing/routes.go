@@ -47,6 +47,8 @@ import (
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/cdnfederation"
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/cdni"
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/cdnnotification"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/configdrift"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/configrollout"
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/coordinate"
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/crconfig"
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/crstats"
@@ -147,6 +149,17 @@ func Routes(d ServerData) ([]Route, http.Handler, error) {
 		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodPost, Path: `cdn_locks/?$`, Handler: cdn_lock.Create, RequiredPrivLevel: auth.PrivLevelOperations, RequiredPermissions: []string{"CDN-LOCK:CREATE", "CDN:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 41343905621},
 		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodDelete, Path: `cdn_locks/?$`, Handler: cdn_lock.Delete, RequiredPrivLevel: auth.PrivLevelOperations, RequiredPermissions: []string{"CDN-LOCK:DELETE", "CDN:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 41343905641},
 
+		// Config rollouts
+		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodGet, Path: `config_rollouts/?$`, Handler: configrollout.Read, RequiredPrivLevel: auth.PrivLevelReadOnly, RequiredPermissions: []string{"SERVER:READ", "CDN:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 41370552611},
+		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodPost, Path: `config_rollouts/?$`, Handler: configrollout.Create, RequiredPrivLevel: auth.PrivLevelOperations, RequiredPermissions: []string{"SERVER:QUEUE", "SERVER:READ", "CDN:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 41370552621},
+		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodPost, Path: `config_rollouts/{id}/halt/?$`, Handler: configrollout.Halt, RequiredPrivLevel: auth.PrivLevelOperations, RequiredPermissions: []string{"SERVER:QUEUE", "SERVER:READ", "CDN:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 41370552631},
+		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodPost, Path: `config_rollouts/{id}/resume/?$`, Handler: configrollout.Resume, RequiredPrivLevel: auth.PrivLevelOperations, RequiredPermissions: []string{"SERVER:QUEUE", "SERVER:READ", "CDN:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 41370552641},
+		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodDelete, Path: `config_rollouts/{id}/?$`, Handler: configrollout.Delete, RequiredPrivLevel: auth.PrivLevelOperations, RequiredPermissions: []string{"SERVER:QUEUE", "SERVER:READ", "CDN:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 41370552651},
+
+		// Config drift
+		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodGet, Path: `config_drift/?$`, Handler: configdrift.Read, RequiredPrivLevel: auth.PrivLevelReadOnly, RequiredPermissions: []string{"SERVER:READ", "CDN:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 41388410211},
+		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodPut, Path: `servers/{id-or-name}/config_drift/?$`, Handler: configdrift.Update, RequiredPrivLevel: auth.PrivLevelOperations, RequiredPermissions: []string{"SERVER:UPDATE", "SERVER:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 41388410221},
+
 		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodGet, Path: `acme_accounts/providers?$`, Handler: acme.ReadProviders, RequiredPrivLevel: auth.PrivLevelOperations, RequiredPermissions: []string{"ACME:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 40343905651},
 		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodPost, Path: `deliveryservices/sslkeys/generate/acme/?$`, Handler: deliveryservice.GenerateAcmeCertificates, RequiredPrivLevel: auth.PrivLevelOperations, RequiredPermissions: []string{"DS-SECURITY-KEY:UPDATE", "ACME:READ", "DELIVERY-SERVICE:READ", "DELIVERY-SERVICE:UPDATE"}, Authenticated: Authenticated, Middlewares: nil, ID: 25343905761},
 
@@ -39,6 +39,7 @@ import (
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/about"
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/auth"
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/config"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/configrollout"
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/plugin"
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/routing"
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/server"
@@ -148,6 +149,7 @@ func main() {
 
 	auth.InitUsersCache(time.Duration(cfg.UserCacheRefreshIntervalSec)*time.Second, db.DB, time.Duration(cfg.DBQueryTimeoutSeconds)*time.Second)
 	server.InitServerUpdateStatusCache(time.Duration(cfg.ServerUpdateStatusCacheRefreshIntervalSec)*time.Second, db.DB, time.Duration(cfg.DBQueryTimeoutSeconds)*time.Second)
+	configrollout.InitController(time.Duration(cfg.ConfigRolloutIntervalSec)*time.Second, db.DB, time.Duration(cfg.DBQueryTimeoutSeconds)*time.Second)
 
 	trafficVault := setupTrafficVault(*riakConfigFileName, &cfg)
 
@@ -0,0 +1,47 @@
+package client
+
+/*
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/toclientlib"
+)
+
+// apiConfigDrift is the API version-relative path for the /config_drift API endpoint.
+const apiConfigDrift = "/config_drift"
+
+// apiServerConfigDrift is the API version-relative path for the /servers/{id-or-name}/config_drift API endpoint.
+const apiServerConfigDrift = "/servers/%s/config_drift"
+
+// GetConfigDrift retrieves the config drift of cache servers, as last reported by the servers.
+func (to *Session) GetConfigDrift(opts RequestOptions) (tc.ServerConfigDriftsResponseV5, toclientlib.ReqInf, error) {
+	var data tc.ServerConfigDriftsResponseV5
+	reqInf, err := to.get(apiConfigDrift, opts, &data)
+	return data, reqInf, err
+}
+
+// SetServerConfigDrift replaces the config drift of the server with the given
+// host name or ID with the given drifted files. An empty files list reports
+// that the server's config hasn't drifted.
+func (to *Session) SetServerConfigDrift(serverIDOrHostName string, files []tc.ConfigDriftFile, opts RequestOptions) (tc.ServerConfigDriftResponseV5, toclientlib.ReqInf, error) {
+	var response tc.ServerConfigDriftResponseV5
+	path := fmt.Sprintf(apiServerConfigDrift, url.PathEscape(serverIDOrHostName))
+	reqInf, err := to.put(path, opts, tc.ServerConfigDriftV5{Files: files}, &response)
+	return response, reqInf, err
+}
@@ -0,0 +1,65 @@
+package client
+
+/*
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"fmt"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/toclientlib"
+)
+
+// apiConfigRollouts is the API version-relative path for the /config_rollouts API endpoint.
+const apiConfigRollouts = "/config_rollouts"
+
+// apiConfigRolloutID is the API version-relative path for the /config_rollouts/{id} API endpoint.
+const apiConfigRolloutID = apiConfigRollouts + "/%d"
+
+// CreateConfigRollout starts a Config Rollout, queueing updates on the servers of its first wave.
+func (to *Session) CreateConfigRollout(rollout tc.ConfigRolloutV5, opts RequestOptions) (tc.ConfigRolloutResponseV5, toclientlib.ReqInf, error) {
+	var response tc.ConfigRolloutResponseV5
+	reqInf, err := to.post(apiConfigRollouts, opts, rollout, &response)
+	return response, reqInf, err
+}
+
+// GetConfigRollouts retrieves Config Rollouts.
+func (to *Session) GetConfigRollouts(opts RequestOptions) (tc.ConfigRolloutsResponseV5, toclientlib.ReqInf, error) {
+	var data tc.ConfigRolloutsResponseV5
+	reqInf, err := to.get(apiConfigRollouts, opts, &data)
+	return data, reqInf, err
+}
+
+// HaltConfigRollout halts the running Config Rollout with the given ID.
+func (to *Session) HaltConfigRollout(id int, opts RequestOptions) (tc.ConfigRolloutResponseV5, toclientlib.ReqInf, error) {
+	var response tc.ConfigRolloutResponseV5
+	reqInf, err := to.post(fmt.Sprintf(apiConfigRolloutID, id)+"/halt", opts, nil, &response)
+	return response, reqInf, err
+}
+
+// ResumeConfigRollout resumes the halted Config Rollout with the given ID.
+func (to *Session) ResumeConfigRollout(id int, opts RequestOptions) (tc.ConfigRolloutResponseV5, toclientlib.ReqInf, error) {
+	var response tc.ConfigRolloutResponseV5
+	reqInf, err := to.post(fmt.Sprintf(apiConfigRolloutID, id)+"/resume", opts, nil, &response)
+	return response, reqInf, err
+}
+
+// DeleteConfigRollout deletes the Config Rollout with the given ID, dequeueing
+// the updates of its servers which haven't applied them if it's in progress.
+func (to *Session) DeleteConfigRollout(id int, opts RequestOptions) (tc.ConfigRolloutResponseV5, toclientlib.ReqInf, error) {
+	var response tc.ConfigRolloutResponseV5
+	reqInf, err := to.del(fmt.Sprintf(apiConfigRolloutID, id), opts, &response)
+	return response, reqInf, err
+}